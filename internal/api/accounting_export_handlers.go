@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/statements"
+)
+
+// GetAccountingExportAPI - выгрузка документов для 1С: /accounting-export?from=2025-01-01&to=2025-01-31&format=xml.
+// Форматы: xml (EnterpriseData, по умолчанию), csv и json. Границы периода включительно;
+// по умолчанию - прошлый календарный месяц. GUID документов стабильны между выгрузками.
+func GetAccountingExportAPI(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 1, -1)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		writeJSONError(w, http.StatusBadRequest, "'to' must not be before 'from'")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = statements.FormatXML
+	}
+	contentTypes := map[string]string{
+		statements.FormatXML: "application/xml; charset=utf-8",
+		statements.FormatCSV: "text/csv; charset=utf-8",
+	}
+	contentType, isFile := contentTypes[format]
+	if format != "json" && !isFile {
+		writeJSONError(w, http.StatusBadRequest, "Unsupported format, expected xml, csv or json")
+		return
+	}
+
+	export, err := db.GetAccountingExport(from, to)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build accounting export")
+		return
+	}
+	if !isFile {
+		if export.Documents == nil {
+			export.Documents = []models.AccountingDocument{}
+		}
+		writeJSONSuccess(w, "Accounting export retrieved successfully", export)
+		return
+	}
+
+	content, err := statements.RenderAccountingExport(export, format)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render accounting export")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statements.AccountingExportFileName(export, format)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
@@ -0,0 +1,271 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"Original/internal/db"
+	"Original/internal/handlers"
+	"Original/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CashHandoverRequest - тело запроса на запись фактической сдачи наличных.
+type CashHandoverRequest struct {
+	DriverUserID   int64    `json:"driver_user_id"`
+	Amount         float64  `json:"amount"`
+	ExpectedAmount *float64 `json:"expected_amount"` // Если указано и не совпадает с amount, пишется расхождение
+	Comment        string   `json:"comment"`
+}
+
+// CashHandoverAckRequest - ответ водителя на запрос подтверждения сдачи.
+type CashHandoverAckRequest struct {
+	Confirmed bool   `json:"confirmed"`
+	Comment   string `json:"comment"`
+}
+
+// CashDiscrepancyResolveRequest - комментарий, с которым закрывается расхождение.
+type CashDiscrepancyResolveRequest struct {
+	Comment string `json:"comment"`
+}
+
+// cashPageParams разбирает limit/offset из query; по умолчанию 50 записей.
+func cashPageParams(r *http.Request) (int, int) {
+	limit := 50
+	if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 && value <= 500 {
+		limit = value
+	}
+	offset := 0
+	if value, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && value > 0 {
+		offset = value
+	}
+	return limit, offset
+}
+
+// GetCashDebtsAPI - несданные остатки водителей с разбивкой по возрасту долга, самые старые первыми.
+func GetCashDebtsAPI(w http.ResponseWriter, r *http.Request) {
+	debts, _, err := db.GetAggregatedDriverSettlements("actual")
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load driver debts")
+		return
+	}
+	if debts == nil {
+		debts = []models.AggregatedDriverSettlementInfo{}
+	}
+	writeJSONSuccess(w, "Driver debts retrieved successfully", debts)
+}
+
+// GetDriverCashBalanceAPI - несданный остаток одного водителя.
+func GetDriverCashBalanceAPI(w http.ResponseWriter, r *http.Request) {
+	driverUserID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid driver ID")
+		return
+	}
+	balance, err := db.GetDriverCashBalance(driverUserID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load driver cash balance")
+		return
+	}
+	writeJSONSuccess(w, "Driver cash balance retrieved successfully", balance)
+}
+
+// GetCashHandoversAPI - история сдачи наличных: /cash/handovers?driver_id=5&limit=50&offset=0.
+func GetCashHandoversAPI(w http.ResponseWriter, r *http.Request) {
+	var driverUserID int64
+	if value := r.URL.Query().Get("driver_id"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'driver_id'")
+			return
+		}
+		driverUserID = parsed
+	}
+	limit, offset := cashPageParams(r)
+	handovers, total, err := db.GetCashHandovers(driverUserID, limit, offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load cash handovers")
+		return
+	}
+	if handovers == nil {
+		handovers = []models.CashHandover{}
+	}
+	writeJSONSuccess(w, "Cash handovers retrieved successfully", map[string]interface{}{
+		"handovers": handovers,
+		"total":     total,
+	})
+}
+
+// CreateCashHandoverAPI записывает фактически сданную сумму и отправляет водителю запрос подтверждения.
+func CreateCashHandoverAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	var req CashHandoverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.DriverUserID <= 0 || req.Amount <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "'driver_user_id' and positive 'amount' are required")
+		return
+	}
+	var expected sql.NullFloat64
+	if req.ExpectedAmount != nil {
+		if *req.ExpectedAmount < 0 {
+			writeJSONError(w, http.StatusBadRequest, "'expected_amount' must not be negative")
+			return
+		}
+		expected = sql.NullFloat64{Float64: *req.ExpectedAmount, Valid: true}
+	}
+
+	handover, err := db.RecordCashHandover(req.DriverUserID, req.Amount, expected, user.ID, req.Comment)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to record cash handover: "+err.Error())
+		return
+	}
+	log.Printf("API CreateCashHandover: пользователь %d принял от водителя %d %.2f ₽ (сдача #%d)", user.ID, req.DriverUserID, handover.Amount, handover.ID)
+	bot.NotifyDriverCashHandover(handover)
+	writeJSONSuccess(w, "Cash handover recorded successfully", handover)
+}
+
+// CancelCashHandoverAPI отменяет ошибочную запись о сдаче; отчеты снова числятся несданными.
+func CancelCashHandoverAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	handoverID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid cash handover ID")
+		return
+	}
+	if err := db.CancelCashHandover(handoverID, user.ID); err != nil {
+		if writeClosedPeriodError(w, err) {
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "Failed to cancel cash handover: "+err.Error())
+		return
+	}
+	writeJSONSuccess(w, "Cash handover canceled successfully", nil)
+}
+
+// GetCashDiscrepanciesAPI - журнал расхождений: /cash/discrepancies?open=1 - только незакрытые.
+func GetCashDiscrepanciesAPI(w http.ResponseWriter, r *http.Request) {
+	onlyOpen := r.URL.Query().Get("open") == "1"
+	limit, offset := cashPageParams(r)
+	discrepancies, total, err := db.GetCashDiscrepancies(onlyOpen, limit, offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load cash discrepancies")
+		return
+	}
+	if discrepancies == nil {
+		discrepancies = []models.CashDiscrepancy{}
+	}
+	writeJSONSuccess(w, "Cash discrepancies retrieved successfully", map[string]interface{}{
+		"discrepancies": discrepancies,
+		"total":         total,
+	})
+}
+
+// ResolveCashDiscrepancyAPI закрывает расхождение с комментарием.
+func ResolveCashDiscrepancyAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	discrepancyID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid discrepancy ID")
+		return
+	}
+	var req CashDiscrepancyResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	discrepancy, err := db.ResolveCashDiscrepancy(discrepancyID, user.ID, req.Comment)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to resolve discrepancy: "+err.Error())
+		return
+	}
+	writeJSONSuccess(w, "Cash discrepancy resolved successfully", discrepancy)
+}
+
+// GetMyCashHandoversAPI - сдачи наличных текущего водителя и его несданный остаток.
+func GetMyCashHandoversAPI(w http.ResponseWriter, r *http.Request) {
+	driver, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	limit, offset := cashPageParams(r)
+	handovers, total, err := db.GetCashHandovers(driver.ID, limit, offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load cash handovers")
+		return
+	}
+	if handovers == nil {
+		handovers = []models.CashHandover{}
+	}
+	balance, err := db.GetDriverCashBalance(driver.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load cash balance")
+		return
+	}
+	writeJSONSuccess(w, "Cash handovers retrieved successfully", map[string]interface{}{
+		"balance":   balance,
+		"handovers": handovers,
+		"total":     total,
+	})
+}
+
+// AcknowledgeCashHandoverAPI - водитель подтверждает сумму сдачи или оспаривает ее с комментарием.
+func AcknowledgeCashHandoverAPI(w http.ResponseWriter, r *http.Request) {
+	driver, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	handoverID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid cash handover ID")
+		return
+	}
+	var req CashHandoverAckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if !req.Confirmed && req.Comment == "" {
+		writeJSONError(w, http.StatusBadRequest, "'comment' is required when disputing a handover")
+		return
+	}
+	handover, err := db.AcknowledgeCashHandover(handoverID, driver.ID, req.Confirmed, req.Comment)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to acknowledge cash handover: "+err.Error())
+		return
+	}
+	if !req.Confirmed {
+		bot.NotifyOwnersCashHandoverDisputed(driver, handover, req.Comment)
+	}
+	writeJSONSuccess(w, "Cash handover acknowledged successfully", handover)
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// CompensationRuleRequest - тело запроса на создание правила доли водителя.
+// Пустые driver_user_id и category означают "любой водитель" и "любая категория";
+// даты передаются в формате YYYY-MM-DD, пустой effective_to - бессрочно.
+type CompensationRuleRequest struct {
+	DriverUserID  *int64  `json:"driver_user_id"`
+	Category      string  `json:"category"`
+	SharePercent  float64 `json:"share_percent"` // Процент, например 35
+	EffectiveFrom string  `json:"effective_from"`
+	EffectiveTo   string  `json:"effective_to"`
+	Comment       string  `json:"comment"`
+}
+
+// GetCompensationRulesAPI возвращает правила долей водителей; ?all=true - включая отключенные.
+func GetCompensationRulesAPI(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("all") != "true"
+	rules, err := db.GetCompensationRules(activeOnly)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load compensation rules")
+		return
+	}
+	if rules == nil {
+		rules = []models.CompensationRule{}
+	}
+	writeJSONSuccess(w, "Compensation rules retrieved successfully", rules)
+}
+
+// CreateCompensationRuleAPI добавляет правило доли водителя.
+func CreateCompensationRuleAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req CompensationRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if req.SharePercent < 0 || req.SharePercent > 100 {
+		writeJSONError(w, http.StatusBadRequest, "share_percent must be between 0 and 100")
+		return
+	}
+
+	rule := models.CompensationRule{
+		Share:           req.SharePercent / 100,
+		CreatedByUserID: sql.NullInt64{Int64: user.ID, Valid: true},
+		Comment:         sql.NullString{String: req.Comment, Valid: req.Comment != ""},
+	}
+	if req.DriverUserID != nil {
+		driver, err := db.GetUserByID(int(*req.DriverUserID))
+		if err != nil || driver.Role != constants.ROLE_DRIVER {
+			writeJSONError(w, http.StatusBadRequest, "Driver not found")
+			return
+		}
+		rule.DriverUserID = sql.NullInt64{Int64: *req.DriverUserID, Valid: true}
+	}
+	if req.Category != "" {
+		if _, known := constants.CategoryDisplayMap[req.Category]; !known {
+			writeJSONError(w, http.StatusBadRequest, "Unknown category")
+			return
+		}
+		rule.Category = sql.NullString{String: req.Category, Valid: true}
+	}
+
+	effectiveFrom, err := time.ParseInLocation("2006-01-02", req.EffectiveFrom, time.Local)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid 'effective_from' date, expected YYYY-MM-DD")
+		return
+	}
+	rule.EffectiveFrom = effectiveFrom
+	if req.EffectiveTo != "" {
+		effectiveTo, errTo := time.ParseInLocation("2006-01-02", req.EffectiveTo, time.Local)
+		if errTo != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'effective_to' date, expected YYYY-MM-DD")
+			return
+		}
+		rule.EffectiveTo = sql.NullTime{Time: effectiveTo, Valid: true}
+	}
+
+	id, err := db.CreateCompensationRule(rule)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to create compensation rule: "+err.Error())
+		return
+	}
+	rule.ID = id
+	rule.IsActive = true
+	log.Printf("API CreateCompensationRule: пользователь %d добавил правило #%d", user.ID, id)
+	writeJSONSuccess(w, "Compensation rule created successfully", rule)
+}
+
+// DeleteCompensationRuleAPI отключает правило; сохраненные отчеты продолжают на него ссылаться.
+func DeleteCompensationRuleAPI(w http.ResponseWriter, r *http.Request) {
+	ruleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+	if err := db.DeactivateCompensationRule(ruleID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Active compensation rule not found")
+		return
+	}
+	writeJSONSuccess(w, "Compensation rule deactivated successfully", nil)
+}
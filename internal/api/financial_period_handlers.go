@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// FinancialPeriodReopenRequest - причина открытия закрытого месяца.
+type FinancialPeriodReopenRequest struct {
+	Reason string `json:"reason"`
+}
+
+// writeClosedPeriodError отвечает 409, если изменение затрагивает закрытый месяц.
+// Возвращает true, если ответ уже записан.
+func writeClosedPeriodError(w http.ResponseWriter, err error) bool {
+	if !errors.Is(err, db.ErrFinancialPeriodClosed) {
+		return false
+	}
+	writeJSONError(w, http.StatusConflict, err.Error())
+	return true
+}
+
+// financialPeriodMonthParam разбирает месяц из URL: YYYY-MM.
+func financialPeriodMonthParam(r *http.Request) (time.Time, error) {
+	return time.ParseInLocation("2006-01", chi.URLParam(r, "month"), time.Local)
+}
+
+// GetFinancialPeriodsAPI - состояние последних завершенных месяцев и журнал закрытий/открытий:
+// /financial-periods?months=6.
+func GetFinancialPeriodsAPI(w http.ResponseWriter, r *http.Request) {
+	months := constants.FinancialPeriodsShownMonths
+	if value := r.URL.Query().Get("months"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 36 {
+			writeJSONError(w, http.StatusBadRequest, "'months' must be between 1 and 36")
+			return
+		}
+		months = parsed
+	}
+	periods, err := db.GetFinancialPeriods(months)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load financial periods")
+		return
+	}
+	events, err := db.GetFinancialPeriodEvents(100)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load financial period log")
+		return
+	}
+	if events == nil {
+		events = []models.FinancialPeriodEvent{}
+	}
+	writeJSONSuccess(w, "Financial periods retrieved successfully", map[string]interface{}{
+		"periods": periods,
+		"events":  events,
+	})
+}
+
+// CloseFinancialPeriodAPI закрывает завершившийся месяц: POST /financial-periods/{month}/close, month = YYYY-MM.
+func CloseFinancialPeriodAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	month, err := financialPeriodMonthParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid month, expected YYYY-MM")
+		return
+	}
+	period, err := db.CloseFinancialPeriod(month, user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, "Failed to close financial period: "+err.Error())
+		return
+	}
+	log.Printf("API CloseFinancialPeriod: пользователь %d закрыл месяц %s", user.ID, month.Format("01.2006"))
+	writeJSONSuccess(w, "Financial period closed successfully", period)
+}
+
+// ReopenFinancialPeriodAPI открывает закрытый месяц с обязательной причиной:
+// POST /financial-periods/{month}/reopen, тело {"reason": "..."}.
+func ReopenFinancialPeriodAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	month, err := financialPeriodMonthParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid month, expected YYYY-MM")
+		return
+	}
+	var req FinancialPeriodReopenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "'reason' is required")
+		return
+	}
+	period, err := db.ReopenFinancialPeriod(month, user.ID, req.Reason)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, "Failed to reopen financial period: "+err.Error())
+		return
+	}
+	log.Printf("API ReopenFinancialPeriod: пользователь %d открыл месяц %s", user.ID, month.Format("01.2006"))
+	writeJSONSuccess(w, "Financial period reopened successfully", period)
+}
@@ -6,9 +6,11 @@ import (
 	"Original/internal/db"
 	"Original/internal/handlers"
 	"Original/internal/models"
+	"Original/internal/payments"
 	"Original/internal/utils"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -35,12 +37,11 @@ var (
 // Папка будет создана в той же директории, где находится исполняемый файл.
 func initStoragePath() {
 	once.Do(func() {
-		executable, err := os.Executable()
+		storagePath, err := utils.MediaStoragePath()
 		if err != nil {
 			log.Fatalf("FATAL: Cannot get executable path: %v", err)
 		}
-		executableDir := filepath.Dir(executable)
-		mediaStoragePath = filepath.Join(executableDir, "media_storage")
+		mediaStoragePath = storagePath
 
 		// Создаем директорию, если её нет
 		if err := os.MkdirAll(mediaStoragePath, os.ModePerm); err != nil {
@@ -69,6 +70,7 @@ type OrderActionRequest struct {
 	Action string      `json:"action"`
 	Reason string      `json:"reason,omitempty"`
 	Cost   json.Number `json:"cost,omitempty"`
+	Amount json.Number `json:"amount,omitempty"` // Сумма возврата для action=refund; пусто - полный возврат
 }
 
 // SettlementStatusRequest - структура для запросов на изменение статуса отчета
@@ -203,6 +205,7 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 		Nickname    string `json:"Nickname,omitempty"`
 		Phone       string `json:"Phone,omitempty"`
 		CardNumber  string `json:"CardNumber,omitempty"`
+		Email       string `json:"Email,omitempty"` // Email для кассовых чеков
 		IsBlocked   bool   `json:"IsBlocked"`
 		BlockReason string `json:"BlockReason,omitempty"`
 	}
@@ -228,6 +231,10 @@ func GetUserProfile(w http.ResponseWriter, r *http.Request) {
 		response.BlockReason = user.BlockReason.String
 	}
 
+	if email, errEmail := db.GetUserEmail(user.ID); errEmail == nil {
+		response.Email = email
+	}
+
 	// Карту не возвращаем для безопасности
 
 	writeJSONSuccess(w, "Profile retrieved successfully", response)
@@ -356,6 +363,11 @@ func CreateOrder(w http.ResponseWriter, r *http.Request) {
 	orderData.UserChatID = operator.ChatID
 	orderData.Status = "in_progress"
 
+	if err := utils.NormalizeOrderDetails(&orderData); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Некорректные объем и доступ: "+err.Error())
+		return
+	}
+
 	newOrderID, err := db.CreateFullOrder(orderData)
 	if err != nil {
 		log.Printf("API CreateOrder: db.CreateFullOrder вернула ошибку: %v", err)
@@ -574,6 +586,9 @@ func HandleAdminOrderAction(w http.ResponseWriter, r *http.Request) {
 
 		if err := db.UpdateOrderField(int64(orderID), "cost", cost); err != nil {
 			log.Printf("API HandleAdminOrderAction: Failed to update cost for order %d. Error: %v", orderID, err)
+			if writeClosedPeriodError(w, err) {
+				return
+			}
 			writeJSONError(w, http.StatusInternalServerError, "Failed to update cost")
 			return
 		}
@@ -639,6 +654,7 @@ func HandleAdminOrderAction(w http.ResponseWriter, r *http.Request) {
 
 		clientMessage := fmt.Sprintf("✅ Ваш заказ №%d выполнен!", orderID)
 		sendBotMessage(order.UserChatID, clientMessage)
+		bot.AccrueReferralBonus(orderID)
 
 		log.Printf("Admin action: User '%s' (ID: %d) marked order %d as completed", utils.GetUserDisplayName(user), user.ID, orderID)
 		writeJSONSuccess(w, "Заказ отмечен как выполненный", nil)
@@ -658,7 +674,38 @@ func HandleAdminOrderAction(w http.ResponseWriter, r *http.Request) {
 		sendBotMessage(order.UserChatID, clientMessage)
 
 		log.Printf("Admin action: User '%s' (ID: %d) canceled order %d. Reason: %s", utils.GetUserDisplayName(user), user.ID, orderID, req.Reason)
-		writeJSONSuccess(w, "Заказ успешно отменён", nil)
+		refundNote := bot.RefundAfterCancel(int64(orderID), req.Reason, user)
+		writeJSONSuccess(w, "Заказ успешно отменён", map[string]string{"refund": refundNote})
+
+	case "refund":
+		if req.Reason == "" {
+			writeJSONError(w, http.StatusBadRequest, "Refund reason is required")
+			return
+		}
+		var amount float64
+		if req.Amount != "" {
+			amount, err = req.Amount.Float64()
+			if err != nil || amount <= 0 {
+				writeJSONError(w, http.StatusBadRequest, "Invalid refund amount")
+				return
+			}
+		}
+		refunded, errRefund := bot.RefundOrderPayment(int64(orderID), amount, req.Reason, user)
+		if errRefund != nil && refunded == 0 {
+			log.Printf("API HandleAdminOrderAction: Refund for order %d failed. Error: %v", orderID, errRefund)
+			status := http.StatusBadGateway
+			if errors.Is(errRefund, handlers.ErrNoRefundablePayments) || errors.Is(errRefund, db.ErrRefundExceedsCaptured) {
+				status = http.StatusConflict
+			}
+			writeJSONError(w, status, errRefund.Error())
+			return
+		}
+		log.Printf("Admin action: User '%s' (ID: %d) refunded %.2f for order %d. Reason: %s", utils.GetUserDisplayName(user), user.ID, refunded, orderID, req.Reason)
+		message := "Возврат оформлен"
+		if errRefund != nil {
+			message = errRefund.Error()
+		}
+		writeJSONSuccess(w, message, map[string]float64{"refunded": refunded})
 
 	case "resume":
 		if order.Status != constants.STATUS_CANCELED {
@@ -855,6 +902,7 @@ func HandleDriverOrderAction(w http.ResponseWriter, r *http.Request) {
 		}
 		msg := tgbotapi.NewMessage(order.UserChatID, fmt.Sprintf("✅ Ваш заказ №%d выполнен!", orderID))
 		bot.Deps.BotClient.Send(msg)
+		bot.AccrueReferralBonus(orderID)
 
 		writeJSONSuccess(w, "Заказ отмечен как выполненный", nil)
 
@@ -913,7 +961,7 @@ func HandleUserOrderAction(w http.ResponseWriter, r *http.Request) {
 		}
 		var newStatus string
 		var clientMessageText string
-		if order.Payment == "now" {
+		if bot.RequiresPrepayment(order) {
 			newStatus = constants.STATUS_AWAITING_PAYMENT
 			clientMessageText = fmt.Sprintf("✅ Стоимость по заказу №%d подтверждена. Теперь вы можете оплатить его в меню 'Мои заказы'.", orderID)
 		} else {
@@ -922,6 +970,9 @@ func HandleUserOrderAction(w http.ResponseWriter, r *http.Request) {
 		}
 
 		db.UpdateOrderStatus(int64(orderID), newStatus)
+		if newStatus == constants.STATUS_INPROGRESS {
+			bot.RegisterCashPayment(order)
+		}
 		sendBotMessage(order.UserChatID, clientMessageText)
 
 		writeJSONSuccess(w, "Стоимость принята", nil)
@@ -953,6 +1004,18 @@ func HandleUserOrderAction(w http.ResponseWriter, r *http.Request) {
 		db.UpdateOrderStatusAndReason(int64(orderID), constants.STATUS_CANCELED, sql.NullString{String: "Отменено клиентом: " + req.Reason, Valid: true})
 		writeJSONSuccess(w, "Заказ отменён", nil)
 
+	case "repeat":
+		if order.Status == constants.STATUS_DRAFT {
+			writeJSONError(w, http.StatusConflict, "Draft order cannot be repeated")
+			return
+		}
+		// Оформление продолжается в чате с ботом: там клиент выбирает дату и время и подтверждает заказ
+		if err := bot.StartRepeatOrder(user.ChatID, user, int64(orderID), 0); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Failed to start repeat order")
+			return
+		}
+		writeJSONSuccess(w, "Выберите дату и время в чате с ботом", nil)
+
 	default:
 		writeJSONError(w, http.StatusBadRequest, "Unknown action")
 	}
@@ -1016,6 +1079,9 @@ func UpdateSettlementStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := db.UpdateDriverSettlementStatus(settlementID, newStatus, comment); err != nil {
+		if writeClosedPeriodError(w, err) {
+			return
+		}
 		writeJSONError(w, http.StatusInternalServerError, "Failed to update settlement status")
 		return
 	}
@@ -1037,8 +1103,21 @@ func GetClientConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	response := map[string]string{
+	// Способы оплаты, включенные на сервере: WebApp показывает только их
+	paymentMethods := []map[string]interface{}{}
+	if bot, botOk := r.Context().Value(BotContextKey).(*handlers.BotHandler); botOk {
+		for _, provider := range bot.EnabledPaymentProviders() {
+			paymentMethods = append(paymentMethods, map[string]interface{}{
+				"id":      provider.Name(),
+				"title":   provider.Title(),
+				"prepaid": provider.Prepaid(),
+			})
+		}
+	}
+
+	response := map[string]interface{}{
 		"telegramBotUsername": cfg.BotUsername,
+		"paymentMethods":      paymentMethods,
 	}
 
 	writeJSONSuccess(w, "Config retrieved", response)
@@ -1090,6 +1169,21 @@ func CreateUserOrder(w http.ResponseWriter, r *http.Request) {
 	orderData.UserID = int(user.ID)
 	orderData.UserChatID = user.ChatID
 
+	// Способ оплаты приводим к ключу провайдера и принимаем только включенные на сервере.
+	orderData.Payment = payments.NormalizeMethod(orderData.Payment)
+	if botOk {
+		if _, enabled := bot.Deps.PaymentProviders.Get(orderData.Payment); !enabled {
+			writeJSONError(w, http.StatusBadRequest, "Выбранный способ оплаты недоступен")
+			return
+		}
+	}
+
+	// Объем и доступ проверяем и оставляем только те, что спрашиваются для подкатегории.
+	if err := utils.NormalizeOrderDetails(&orderData); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Некорректные объем и доступ: "+err.Error())
+		return
+	}
+
 	// Шаг 4: Устанавливаем статус "новый", так как заказ от пользователя требует оценки.
 	orderData.Status = constants.STATUS_NEW
 
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"Original/internal/db"
+	"Original/internal/models"
+)
+
+// GetLedgerBalances возвращает все счета главной книги с остатками.
+func GetLedgerBalances(w http.ResponseWriter, r *http.Request) {
+	accounts, err := db.GetLedgerAccountBalances()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load ledger balances")
+		return
+	}
+	if accounts == nil {
+		accounts = []models.LedgerAccount{}
+	}
+	writeJSONSuccess(w, "Ledger balances retrieved successfully", accounts)
+}
+
+// GetLedgerStatement возвращает выписку по счету: ?account=staff_payable:7&from=2025-01-01&to=2025-02-01.
+// По умолчанию период - последние 30 дней; to не включается в период.
+func GetLedgerStatement(w http.ResponseWriter, r *http.Request) {
+	account := r.URL.Query().Get("account")
+	if account == "" {
+		writeJSONError(w, http.StatusBadRequest, "Account code is required")
+		return
+	}
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+	if !from.Before(to) {
+		writeJSONError(w, http.StatusBadRequest, "'from' must be before 'to'")
+		return
+	}
+
+	statement, err := db.GetLedgerAccountStatement(account, from, to)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load ledger statement")
+		return
+	}
+	if statement.Lines == nil {
+		statement.Lines = []models.LedgerStatementLine{}
+	}
+	writeJSONSuccess(w, "Ledger statement retrieved successfully", statement)
+}
+
+// CheckLedger запускает проверку целостности главной книги.
+func CheckLedger(w http.ResponseWriter, r *http.Request) {
+	issues, err := db.CheckLedgerIntegrity()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to check ledger integrity")
+		return
+	}
+	if issues == nil {
+		issues = []models.LedgerIntegrityIssue{}
+	}
+	writeJSONSuccess(w, "Ledger integrity check completed", map[string]interface{}{
+		"ok":     len(issues) == 0,
+		"issues": issues,
+	})
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// LoaderPayRateRequest - тело запроса на создание тарифа грузчика.
+// Пустые loader_user_id и category означают "любой грузчик" и "любая категория";
+// даты передаются в формате YYYY-MM-DD, пустой effective_to - бессрочно.
+type LoaderPayRateRequest struct {
+	LoaderUserID  *int64  `json:"loader_user_id"`
+	Category      string  `json:"category"`
+	PerOrder      float64 `json:"per_order"`
+	PerHour       float64 `json:"per_hour"`
+	PerFloor      float64 `json:"per_floor"`
+	PerTon        float64 `json:"per_ton"`
+	EffectiveFrom string  `json:"effective_from"`
+	EffectiveTo   string  `json:"effective_to"`
+	Comment       string  `json:"comment"`
+}
+
+// GetLoaderPayRatesAPI возвращает тарифы грузчиков; ?all=true - включая отключенные.
+func GetLoaderPayRatesAPI(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("all") != "true"
+	rates, err := db.GetLoaderPayRates(activeOnly)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load loader pay rates")
+		return
+	}
+	if rates == nil {
+		rates = []models.LoaderPayRate{}
+	}
+	writeJSONSuccess(w, "Loader pay rates retrieved successfully", rates)
+}
+
+// CreateLoaderPayRateAPI добавляет тариф грузчика.
+func CreateLoaderPayRateAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req LoaderPayRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	rate := models.LoaderPayRate{
+		PerOrder:        req.PerOrder,
+		PerHour:         req.PerHour,
+		PerFloor:        req.PerFloor,
+		PerTon:          req.PerTon,
+		CreatedByUserID: sql.NullInt64{Int64: user.ID, Valid: true},
+		Comment:         sql.NullString{String: req.Comment, Valid: req.Comment != ""},
+	}
+	if req.LoaderUserID != nil {
+		loader, err := db.GetUserByID(int(*req.LoaderUserID))
+		if err != nil || loader.Role != constants.ROLE_LOADER {
+			writeJSONError(w, http.StatusBadRequest, "Loader not found")
+			return
+		}
+		rate.LoaderUserID = sql.NullInt64{Int64: *req.LoaderUserID, Valid: true}
+	}
+	if req.Category != "" {
+		if _, known := constants.CategoryDisplayMap[req.Category]; !known {
+			writeJSONError(w, http.StatusBadRequest, "Unknown category")
+			return
+		}
+		rate.Category = sql.NullString{String: req.Category, Valid: true}
+	}
+
+	effectiveFrom, err := time.ParseInLocation("2006-01-02", req.EffectiveFrom, time.Local)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid 'effective_from' date, expected YYYY-MM-DD")
+		return
+	}
+	rate.EffectiveFrom = effectiveFrom
+	if req.EffectiveTo != "" {
+		effectiveTo, errTo := time.ParseInLocation("2006-01-02", req.EffectiveTo, time.Local)
+		if errTo != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'effective_to' date, expected YYYY-MM-DD")
+			return
+		}
+		rate.EffectiveTo = sql.NullTime{Time: effectiveTo, Valid: true}
+	}
+
+	id, err := db.CreateLoaderPayRate(rate)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to create loader pay rate: "+err.Error())
+		return
+	}
+	rate.ID = id
+	rate.IsActive = true
+	log.Printf("API CreateLoaderPayRate: пользователь %d добавил тариф грузчика #%d", user.ID, id)
+	writeJSONSuccess(w, "Loader pay rate created successfully", rate)
+}
+
+// DeleteLoaderPayRateAPI отключает тариф; сохраненные отчеты продолжают на него ссылаться.
+func DeleteLoaderPayRateAPI(w http.ResponseWriter, r *http.Request) {
+	rateID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid rate ID")
+		return
+	}
+	if err := db.DeactivateLoaderPayRate(rateID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Active loader pay rate not found")
+		return
+	}
+	writeJSONSuccess(w, "Loader pay rate deactivated successfully", nil)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"Original/internal/db"
+	"Original/internal/models"
+)
+
+// GetOrderPayments возвращает журнал платежей и возвратов по заказу.
+func GetOrderPayments(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	orderPayments, err := db.GetPaymentsByOrderID(orderID)
+	if err != nil {
+		log.Printf("API GetOrderPayments: failed to load payments for order %d: %v", orderID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load payments")
+		return
+	}
+	if orderPayments == nil {
+		orderPayments = []models.Payment{}
+	}
+
+	orderRefunds, err := db.GetRefundsByOrderID(orderID)
+	if err != nil {
+		log.Printf("API GetOrderPayments: failed to load refunds for order %d: %v", orderID, err)
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load refunds")
+		return
+	}
+	if orderRefunds == nil {
+		orderRefunds = []models.Refund{}
+	}
+
+	writeJSONSuccess(w, "Payments retrieved successfully", map[string]interface{}{
+		"payments": orderPayments,
+		"refunds":  orderRefunds,
+	})
+}
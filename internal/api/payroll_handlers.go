@@ -0,0 +1,292 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Original/internal/db"
+	"Original/internal/handlers"
+	"Original/internal/models"
+	"Original/internal/statements"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PayrollRunRequest - тело запроса на создание ведомости; даты в формате YYYY-MM-DD.
+type PayrollRunRequest struct {
+	PeriodFrom string `json:"period_from"`
+	PeriodTo   string `json:"period_to"`
+}
+
+// PayrollLineRequest - новая сумма строки черновика.
+type PayrollLineRequest struct {
+	Amount  float64 `json:"amount"`
+	Comment string  `json:"comment"`
+}
+
+// PayrollReverseRequest - причина сторно ведомости.
+type PayrollReverseRequest struct {
+	Reason string `json:"reason"`
+}
+
+func payrollRunIDParam(r *http.Request) (int64, error) {
+	return strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+}
+
+// GetPayrollRunsAPI возвращает последние ведомости; ?limit= (по умолчанию 50).
+func GetPayrollRunsAPI(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if parsed, err := strconv.Atoi(limitStr); err == nil && parsed > 0 {
+			limit = parsed
+		}
+	}
+	runs, err := db.GetPayrollRuns(limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load payroll runs")
+		return
+	}
+	if runs == nil {
+		runs = []models.PayrollRun{}
+	}
+	writeJSONSuccess(w, "Payroll runs retrieved successfully", runs)
+}
+
+// CreatePayrollRunAPI создает черновик ведомости. Если за период уже есть ведомость, возвращает ее.
+func CreatePayrollRunAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	var req PayrollRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	from, errFrom := time.ParseInLocation("2006-01-02", req.PeriodFrom, time.Local)
+	to, errTo := time.ParseInLocation("2006-01-02", req.PeriodTo, time.Local)
+	if errFrom != nil || errTo != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid period, expected YYYY-MM-DD")
+		return
+	}
+
+	run, existing, err := db.CreatePayrollRun(from, to, user.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to create payroll run: "+err.Error())
+		return
+	}
+	if existing {
+		writeJSONSuccess(w, "Payroll run for this period already exists", run)
+		return
+	}
+	log.Printf("API CreatePayrollRun: пользователь %d создал ведомость #%d", user.ID, run.ID)
+	writeJSONSuccess(w, "Payroll run created successfully", run)
+}
+
+// GetPayrollRunAPI возвращает ведомость со строками.
+func GetPayrollRunAPI(w http.ResponseWriter, r *http.Request) {
+	runID, err := payrollRunIDParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payroll run ID")
+		return
+	}
+	run, err := db.GetPayrollRun(runID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Payroll run not found")
+		return
+	}
+	if run.Lines == nil {
+		run.Lines = []models.PayrollRunLine{}
+	}
+	writeJSONSuccess(w, "Payroll run retrieved successfully", run)
+}
+
+// DeletePayrollRunAPI удаляет черновик ведомости.
+func DeletePayrollRunAPI(w http.ResponseWriter, r *http.Request) {
+	runID, err := payrollRunIDParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payroll run ID")
+		return
+	}
+	if err := db.DeletePayrollRunDraft(runID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Draft payroll run not found")
+		return
+	}
+	writeJSONSuccess(w, "Payroll run deleted successfully", nil)
+}
+
+// UpdatePayrollRunLineAPI меняет сумму строки черновика.
+func UpdatePayrollRunLineAPI(w http.ResponseWriter, r *http.Request) {
+	runID, err := payrollRunIDParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payroll run ID")
+		return
+	}
+	lineID, err := strconv.ParseInt(chi.URLParam(r, "lineID"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid line ID")
+		return
+	}
+	var req PayrollLineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if err := db.UpdatePayrollRunLine(runID, lineID, req.Amount, req.Comment); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to update payroll line: "+err.Error())
+		return
+	}
+	run, err := db.GetPayrollRun(runID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load payroll run")
+		return
+	}
+	writeJSONSuccess(w, "Payroll line updated successfully", run)
+}
+
+// RecalculatePayrollRunAPI пересчитывает черновик по текущим долгам.
+func RecalculatePayrollRunAPI(w http.ResponseWriter, r *http.Request) {
+	runID, err := payrollRunIDParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payroll run ID")
+		return
+	}
+	if err := db.RecalculatePayrollRun(runID); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to recalculate payroll run: "+err.Error())
+		return
+	}
+	run, err := db.GetPayrollRun(runID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load payroll run")
+		return
+	}
+	writeJSONSuccess(w, "Payroll run recalculated successfully", run)
+}
+
+// ConfirmPayrollRunAPI проводит ведомость и уведомляет сотрудников. Повторный вызов безопасен.
+func ConfirmPayrollRunAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	runID, err := payrollRunIDParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payroll run ID")
+		return
+	}
+
+	run, alreadyConfirmed, err := db.ConfirmPayrollRun(runID, user.ID)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, db.ErrPayrollOwedChanged) {
+			status = http.StatusConflict
+		}
+		writeJSONError(w, status, "Failed to confirm payroll run: "+err.Error())
+		return
+	}
+	if alreadyConfirmed {
+		writeJSONSuccess(w, "Payroll run already confirmed", run)
+		return
+	}
+	bot.NotifyPayrollRunConfirmed(run)
+	log.Printf("API ConfirmPayrollRun: пользователь %d провел ведомость #%d", user.ID, run.ID)
+	writeJSONSuccess(w, "Payroll run confirmed successfully", run)
+}
+
+// ReversePayrollRunAPI сторнирует проведенную ведомость и уведомляет сотрудников.
+func ReversePayrollRunAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	runID, err := payrollRunIDParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payroll run ID")
+		return
+	}
+	var req PayrollReverseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reason == "" {
+		writeJSONError(w, http.StatusBadRequest, "Reversal reason is required")
+		return
+	}
+
+	run, alreadyReversed, err := db.ReversePayrollRun(runID, user.ID, req.Reason)
+	if err != nil {
+		if writeClosedPeriodError(w, err) {
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "Failed to reverse payroll run: "+err.Error())
+		return
+	}
+	if alreadyReversed {
+		writeJSONSuccess(w, "Payroll run already reversed", run)
+		return
+	}
+	bot.NotifyPayrollRunReversed(run)
+	log.Printf("API ReversePayrollRun: пользователь %d сторнировал ведомость #%d", user.ID, run.ID)
+	writeJSONSuccess(w, "Payroll run reversed successfully", run)
+}
+
+// GetPayrollRegistryAPI отдает реестр на перечисление; ?format=csv|xlsx|json (по умолчанию csv).
+func GetPayrollRegistryAPI(w http.ResponseWriter, r *http.Request) {
+	runID, err := payrollRunIDParam(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid payroll run ID")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = statements.FormatCSV
+	}
+	contentTypes := map[string]string{
+		statements.FormatCSV:  "text/csv; charset=utf-8",
+		statements.FormatXLSX: statementContentTypes[statements.FormatXLSX],
+	}
+	contentType, isFile := contentTypes[format]
+	if format != "json" && !isFile {
+		writeJSONError(w, http.StatusBadRequest, "Unsupported format, expected csv, xlsx or json")
+		return
+	}
+
+	run, registry, err := db.GetPayrollRegistry(runID)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to build payroll registry: "+err.Error())
+		return
+	}
+	if !isFile {
+		if registry == nil {
+			registry = []models.PayrollRegistryLine{}
+		}
+		writeJSONSuccess(w, "Payroll registry retrieved successfully", registry)
+		return
+	}
+
+	content, err := statements.RenderRegistry(run, registry, format)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render payroll registry")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statements.RegistryFileName(run, format)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
@@ -0,0 +1,269 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/handlers"
+	"Original/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// PriceRuleRequest - тело запроса на создание правила цены.
+// Пустые category и subcategory означают "любая"; даты в формате YYYY-MM-DD, пустой effective_to - бессрочно.
+type PriceRuleRequest struct {
+	Category         string  `json:"category"`
+	Subcategory      string  `json:"subcategory"`
+	BasePrice        float64 `json:"base_price"`
+	IncludedVolumeM3 float64 `json:"included_volume_m3"`
+	PerM3            float64 `json:"per_m3"`
+	PerFloor         float64 `json:"per_floor"`
+	UrgentPercent    float64 `json:"urgent_percent"`
+	WeekendPercent   float64 `json:"weekend_percent"`
+	FreeKm           float64 `json:"free_km"`
+	PerKm            float64 `json:"per_km"`
+	MinPrice         float64 `json:"min_price"`
+	EffectiveFrom    string  `json:"effective_from"`
+	EffectiveTo      string  `json:"effective_to"`
+	Comment          string  `json:"comment"`
+}
+
+// PriceEstimateRequest - параметры заказа для предварительного расчета цены клиентом.
+type PriceEstimateRequest struct {
+	Category    string  `json:"category"`
+	Subcategory string  `json:"subcategory"`
+	Date        string  `json:"date"` // YYYY-MM-DD, пусто - день неизвестен
+	Time        string  `json:"time"` // "СРОЧНО" или HH:MM
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+}
+
+// GetPriceRulesAPI возвращает правила цены; ?all=true - включая отключенные.
+func GetPriceRulesAPI(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("all") != "true"
+	rules, err := db.GetPriceRules(activeOnly)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load price rules")
+		return
+	}
+	if rules == nil {
+		rules = []models.PriceRule{}
+	}
+	writeJSONSuccess(w, "Price rules retrieved successfully", rules)
+}
+
+// priceRuleFromRequest проверяет тело запроса и собирает из него правило цены.
+// Возвращает текст ошибки для ответа 400, если запрос некорректен.
+func priceRuleFromRequest(req PriceRuleRequest, userID int64) (models.PriceRule, string) {
+	rule := models.PriceRule{
+		BasePrice:        req.BasePrice,
+		IncludedVolumeM3: req.IncludedVolumeM3,
+		PerM3:            req.PerM3,
+		PerFloor:         req.PerFloor,
+		UrgentPercent:    req.UrgentPercent,
+		WeekendPercent:   req.WeekendPercent,
+		FreeKm:           req.FreeKm,
+		PerKm:            req.PerKm,
+		MinPrice:         req.MinPrice,
+		CreatedByUserID:  sql.NullInt64{Int64: userID, Valid: true},
+		Comment:          sql.NullString{String: req.Comment, Valid: req.Comment != ""},
+	}
+	if req.Category != "" {
+		if _, known := constants.CategoryDisplayMap[req.Category]; !known {
+			return rule, "Unknown category"
+		}
+		rule.Category = sql.NullString{String: req.Category, Valid: true}
+	}
+	if req.Subcategory != "" {
+		var subcategories map[string]string
+		switch req.Category {
+		case constants.CAT_WASTE:
+			subcategories = constants.WasteSubcategoryMap
+		case constants.CAT_DEMOLITION:
+			subcategories = constants.DemolitionSubcategoryMap
+		}
+		if _, known := subcategories[req.Subcategory]; !known {
+			return rule, "Unknown subcategory for category"
+		}
+		rule.Subcategory = sql.NullString{String: req.Subcategory, Valid: true}
+	}
+
+	effectiveFrom, err := time.ParseInLocation("2006-01-02", req.EffectiveFrom, time.Local)
+	if err != nil {
+		return rule, "Invalid 'effective_from' date, expected YYYY-MM-DD"
+	}
+	rule.EffectiveFrom = effectiveFrom
+	if req.EffectiveTo != "" {
+		effectiveTo, errTo := time.ParseInLocation("2006-01-02", req.EffectiveTo, time.Local)
+		if errTo != nil {
+			return rule, "Invalid 'effective_to' date, expected YYYY-MM-DD"
+		}
+		rule.EffectiveTo = sql.NullTime{Time: effectiveTo, Valid: true}
+	}
+	return rule, ""
+}
+
+// CreatePriceRuleAPI добавляет правило цены.
+func CreatePriceRuleAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req PriceRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	rule, errText := priceRuleFromRequest(req, user.ID)
+	if errText != "" {
+		writeJSONError(w, http.StatusBadRequest, errText)
+		return
+	}
+
+	id, err := db.CreatePriceRule(rule)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to create price rule: "+err.Error())
+		return
+	}
+	rule.ID = id
+	rule.IsActive = true
+	log.Printf("API CreatePriceRule: пользователь %d добавил правило цены #%d", user.ID, id)
+	writeJSONSuccess(w, "Price rule created successfully", rule)
+}
+
+// ReplacePriceRuleAPI изменяет правило цены: прежнее правило отключается, вместо него добавляется новое
+// с новым ID, который и возвращается в ответе.
+func ReplacePriceRuleAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	oldRuleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+
+	var req PriceRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	rule, errText := priceRuleFromRequest(req, user.ID)
+	if errText != "" {
+		writeJSONError(w, http.StatusBadRequest, errText)
+		return
+	}
+
+	id, err := db.ReplacePriceRule(oldRuleID, rule)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to replace price rule: "+err.Error())
+		return
+	}
+	rule.ID = id
+	rule.IsActive = true
+	log.Printf("API ReplacePriceRule: пользователь %d заменил правило цены #%d правилом #%d", user.ID, oldRuleID, id)
+	writeJSONSuccess(w, "Price rule replaced successfully", rule)
+}
+
+// DeletePriceRuleAPI отключает правило цены.
+func DeletePriceRuleAPI(w http.ResponseWriter, r *http.Request) {
+	ruleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid rule ID")
+		return
+	}
+	if err := db.DeactivatePriceRule(ruleID); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Active price rule not found")
+		return
+	}
+	writeJSONSuccess(w, "Price rule deactivated successfully", nil)
+}
+
+// GetOrderPriceEstimateAPI возвращает расчетную цену заказа с расшифровкой для оператора.
+func GetOrderPriceEstimateAPI(w http.ResponseWriter, r *http.Request) {
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	orderID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+	order, err := db.GetOrderByID(orderID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	estimate, found, err := db.EstimateOrderPrice(order, bot.Deps.Config.DepotLatitude, bot.Deps.Config.DepotLongitude)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to estimate order price")
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, "No price rule matches the order")
+		return
+	}
+	writeJSONSuccess(w, "Price estimate calculated successfully", estimate)
+}
+
+// EstimatePriceAPI - предварительный диапазон цены для клиента до создания заказа.
+// Расшифровка и точная сумма клиенту не возвращаются.
+func EstimatePriceAPI(w http.ResponseWriter, r *http.Request) {
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	var req PriceEstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if _, known := constants.CategoryDisplayMap[req.Category]; !known {
+		writeJSONError(w, http.StatusBadRequest, "Unknown category")
+		return
+	}
+	if req.Date != "" {
+		if _, err := time.ParseInLocation("2006-01-02", req.Date, time.Local); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'date', expected YYYY-MM-DD")
+			return
+		}
+	}
+
+	order := models.Order{
+		Category:    req.Category,
+		Subcategory: req.Subcategory,
+		Date:        req.Date,
+		Time:        strings.TrimSpace(req.Time),
+		Latitude:    req.Latitude,
+		Longitude:   req.Longitude,
+	}
+	estimate, found, err := db.EstimateOrderPrice(order, bot.Deps.Config.DepotLatitude, bot.Deps.Config.DepotLongitude)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to estimate price")
+		return
+	}
+	if !found {
+		writeJSONSuccess(w, "No price estimate available", map[string]interface{}{"available": false})
+		return
+	}
+	writeJSONSuccess(w, "Price estimate calculated successfully", map[string]interface{}{
+		"available": true,
+		"range_min": estimate.RangeMin,
+		"range_max": estimate.RangeMax,
+	})
+}
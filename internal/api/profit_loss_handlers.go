@@ -0,0 +1,73 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/statements"
+)
+
+// GetProfitLossAPI - отчет о прибылях и убытках: /profit-loss?from=2025-01-01&to=2025-03-31&format=xlsx.
+// Границы периода включительно; по умолчанию - с начала текущего месяца по сегодня.
+func GetProfitLossAPI(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := now
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		writeJSONError(w, http.StatusBadRequest, "'to' must not be before 'from'")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != statements.FormatXLSX {
+		writeJSONError(w, http.StatusBadRequest, "Unsupported format, expected json or xlsx")
+		return
+	}
+
+	report, err := db.GetProfitAndLoss(from, to)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build profit and loss report")
+		return
+	}
+	if format != statements.FormatXLSX {
+		if report.Lines == nil {
+			report.Lines = []models.ProfitLossLine{}
+		}
+		if report.Categories == nil {
+			report.Categories = []models.ProfitLossCategory{}
+		}
+		writeJSONSuccess(w, "Profit and loss report retrieved successfully", report)
+		return
+	}
+
+	content, err := statements.RenderProfitLossXLSX(report)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render profit and loss report")
+		return
+	}
+	w.Header().Set("Content-Type", statementContentTypes[statements.FormatXLSX])
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statements.ProfitLossFileName(report)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
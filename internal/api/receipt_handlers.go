@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+)
+
+// GetOrderCostItems возвращает расшифровку стоимости заказа (позиции будущего чека).
+func GetOrderCostItems(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	items, err := db.GetOrderCostItems(orderID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load cost items")
+		return
+	}
+	if items == nil {
+		items = []models.OrderCostItem{}
+	}
+	writeJSONSuccess(w, "Cost items retrieved successfully", map[string]interface{}{
+		"items": items,
+		"kinds": constants.CostItemDisplayMap,
+	})
+}
+
+// UpdateOrderCostItems заменяет расшифровку стоимости заказа; стоимость заказа становится суммой статей.
+// Пустой список удаляет расшифровку, не меняя стоимость.
+func UpdateOrderCostItems(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var payload struct {
+		Items []struct {
+			Kind        string  `json:"kind"`
+			Description string  `json:"description"`
+			Amount      float64 `json:"amount"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var total float64
+	items := make([]models.OrderCostItem, 0, len(payload.Items))
+	for _, in := range payload.Items {
+		description := strings.TrimSpace(in.Description)
+		kind := in.Kind
+		if kind == "" {
+			kind = utils.DetectCostItemKind(description)
+		}
+		if _, known := constants.CostItemDisplayMap[kind]; !known {
+			writeJSONError(w, http.StatusBadRequest, "Unknown cost item kind: "+kind)
+			return
+		}
+		if description == "" {
+			description = constants.CostItemDisplayMap[kind]
+		}
+		if in.Amount <= 0 {
+			writeJSONError(w, http.StatusBadRequest, "Cost item amount must be positive")
+			return
+		}
+		items = append(items, models.OrderCostItem{Kind: kind, Description: description, Amount: in.Amount})
+		total += in.Amount
+	}
+
+	if len(items) > 0 {
+		if err := db.UpdateOrderField(orderID, "cost", total); err != nil {
+			log.Printf("API UpdateOrderCostItems: failed to update cost for order %d: %v", orderID, err)
+			if writeClosedPeriodError(w, err) {
+				return
+			}
+			writeJSONError(w, http.StatusInternalServerError, "Failed to update order cost")
+			return
+		}
+	}
+	if err := db.ReplaceOrderCostItems(orderID, items); err != nil {
+		if writeClosedPeriodError(w, err) {
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save cost items")
+		return
+	}
+	writeJSONSuccess(w, "Cost items updated successfully", map[string]interface{}{
+		"items": items,
+		"cost":  total,
+	})
+}
+
+// UpdateReceiptEmail сохраняет email пользователя для кассовых чеков. Пустая строка удаляет email.
+func UpdateReceiptEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var payload struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	email := ""
+	if strings.TrimSpace(payload.Email) != "" {
+		validEmail, err := utils.ValidateEmail(payload.Email)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid email format")
+			return
+		}
+		email = validEmail
+	}
+	if err := db.UpdateUserEmail(user.ChatID, email); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to save email")
+		return
+	}
+	writeJSONSuccess(w, "Receipt email updated successfully", map[string]string{"email": email})
+}
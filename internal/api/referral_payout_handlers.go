@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/handlers"
+	"Original/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ReferralPayoutDecisionRequest - комментарий к выплате или причина отказа.
+type ReferralPayoutDecisionRequest struct {
+	Comment string `json:"comment"`
+}
+
+// GetReferralPayoutsAPI - очередь запросов на выплату бонусов: /referral-payouts?status=pending&limit=50&offset=0.
+// Без status возвращаются запросы во всех статусах.
+func GetReferralPayoutsAPI(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if _, ok := constants.PayoutRequestStatusDisplayMap[status]; status != "" && !ok {
+		writeJSONError(w, http.StatusBadRequest, "Invalid 'status'")
+		return
+	}
+	limit, offset := cashPageParams(r)
+	requests, total, err := db.GetReferralPayoutRequests(status, limit, offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load referral payout requests")
+		return
+	}
+	if requests == nil {
+		requests = []models.ReferralPayoutRequest{}
+	}
+	writeJSONSuccess(w, "Referral payout requests retrieved successfully", map[string]interface{}{
+		"requests": requests,
+		"total":    total,
+	})
+}
+
+// GetReferralPayoutAPI - один запрос на выплату с реквизитами клиента.
+func GetReferralPayoutAPI(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid referral payout request ID")
+		return
+	}
+	req, err := db.GetReferralPayoutRequestByID(requestID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Referral payout request not found")
+		return
+	}
+	writeJSONSuccess(w, "Referral payout request retrieved successfully", req)
+}
+
+// CompleteReferralPayoutAPI фиксирует перевод денег по запросу, создает выплату и уведомляет клиента.
+func CompleteReferralPayoutAPI(w http.ResponseWriter, r *http.Request) {
+	decideReferralPayout(w, r, true)
+}
+
+// RejectReferralPayoutAPI отклоняет запрос с причиной; бонусы снова доступны клиенту для запроса.
+func RejectReferralPayoutAPI(w http.ResponseWriter, r *http.Request) {
+	decideReferralPayout(w, r, false)
+}
+
+func decideReferralPayout(w http.ResponseWriter, r *http.Request, paid bool) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	requestID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid referral payout request ID")
+		return
+	}
+	var body ReferralPayoutDecisionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	var req models.ReferralPayoutRequest
+	if paid {
+		req, err = db.CompleteReferralPayoutRequest(requestID, user.ID, body.Comment)
+	} else {
+		if body.Comment == "" {
+			writeJSONError(w, http.StatusBadRequest, "'comment' with the rejection reason is required")
+			return
+		}
+		req, err = db.RejectReferralPayoutRequest(requestID, user.ID, body.Comment)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to process referral payout request: "+err.Error())
+		return
+	}
+	log.Printf("API decideReferralPayout: пользователь %d обработал запрос #%d, статус %s", user.ID, req.ID, req.Status)
+	bot.NotifyClientReferralPayoutProcessed(req)
+	writeJSONSuccess(w, "Referral payout request processed successfully", req)
+}
+
+// GetReferralReviewsAPI - реферальные бонусы, удержанные на проверке: /referral-reviews?limit=50&offset=0.
+func GetReferralReviewsAPI(w http.ResponseWriter, r *http.Request) {
+	limit, offset := cashPageParams(r)
+	referrals, total, err := db.GetReferralsOnReview(limit, offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load referrals on review")
+		return
+	}
+	if referrals == nil {
+		referrals = []models.Referral{}
+	}
+	writeJSONSuccess(w, "Referrals on review retrieved successfully", map[string]interface{}{
+		"referrals": referrals,
+		"total":     total,
+	})
+}
+
+// ApproveReferralReviewAPI подтверждает удержанный бонус - он становится доступен к выплате.
+func ApproveReferralReviewAPI(w http.ResponseWriter, r *http.Request) {
+	decideReferralReview(w, r, true)
+}
+
+// RejectReferralReviewAPI отклоняет удержанный бонус - он не выплачивается.
+func RejectReferralReviewAPI(w http.ResponseWriter, r *http.Request) {
+	decideReferralReview(w, r, false)
+}
+
+func decideReferralReview(w http.ResponseWriter, r *http.Request, approve bool) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	bot, ok := r.Context().Value(BotContextKey).(*handlers.BotHandler)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "Bot context not found")
+		return
+	}
+	referralID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid referral ID")
+		return
+	}
+	var body ReferralPayoutDecisionRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+			return
+		}
+	}
+
+	referral, err := db.ReviewReferral(referralID, approve, user.ID, body.Comment)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to review referral: "+err.Error())
+		return
+	}
+	log.Printf("API decideReferralReview: пользователь %d вынес решение '%s' по бонусу #%d", user.ID, referral.ReviewStatus.String, referral.ID)
+	bot.NotifyClientReferralReviewed(referral)
+	writeJSONSuccess(w, "Referral reviewed successfully", referral)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Original/internal/db"
+	"Original/internal/models"
+)
+
+// ReferralRuleRequest - тело запроса на новую версию условий реферальной программы.
+// bonus_type - fixed (сумма в рублях) или percent (процент от заказа); нулевые ограничения не действуют.
+// effective_from передается в RFC 3339; пустое значение - условия действуют сразу.
+type ReferralRuleRequest struct {
+	BonusType       string   `json:"bonus_type"`
+	BonusValue      float64  `json:"bonus_value"`
+	MinOrderAmount  float64  `json:"min_order_amount"`
+	Categories      []string `json:"categories"`
+	MonthlyCap      float64  `json:"monthly_cap"`
+	ExpiryDays      int      `json:"expiry_days"`
+	MinPayoutAmount float64  `json:"min_payout_amount"`
+	EffectiveFrom   string   `json:"effective_from"`
+	Comment         string   `json:"comment"`
+}
+
+// GetReferralRulesAPI возвращает действующие условия и историю версий (?limit=, по умолчанию 50).
+func GetReferralRulesAPI(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	rules, err := db.GetReferralRules(limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load referral rules")
+		return
+	}
+	if rules == nil {
+		rules = []models.ReferralRule{}
+	}
+	var active *models.ReferralRule
+	if rule, errActive := db.GetActiveReferralRule(); errActive == nil {
+		active = &rule
+	}
+	writeJSONSuccess(w, "Referral rules retrieved successfully", map[string]interface{}{
+		"active":   active,
+		"versions": rules,
+	})
+}
+
+// CreateReferralRuleAPI сохраняет новую версию условий реферальной программы.
+func CreateReferralRuleAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+
+	var req ReferralRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	rule := models.ReferralRule{
+		BonusType:       req.BonusType,
+		BonusValue:      req.BonusValue,
+		MinOrderAmount:  req.MinOrderAmount,
+		Categories:      req.Categories,
+		MonthlyCap:      req.MonthlyCap,
+		ExpiryDays:      req.ExpiryDays,
+		MinPayoutAmount: req.MinPayoutAmount,
+		Comment:         sql.NullString{String: req.Comment, Valid: req.Comment != ""},
+		CreatedByUserID: sql.NullInt64{Int64: user.ID, Valid: true},
+	}
+	if req.EffectiveFrom != "" {
+		effectiveFrom, err := time.Parse(time.RFC3339, req.EffectiveFrom)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'effective_from', expected RFC 3339")
+			return
+		}
+		rule.EffectiveFrom = effectiveFrom
+	}
+
+	created, err := db.CreateReferralRule(rule)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to create referral rule: "+err.Error())
+		return
+	}
+	log.Printf("API CreateReferralRule: пользователь %d добавил версию %d условий реферальной программы", user.ID, created.Version)
+	writeJSONSuccess(w, "Referral rule created successfully", created)
+}
@@ -21,6 +21,7 @@ func SetupRoutes(r *chi.Mux, deps ApiDependencies) {
 
 	r.Group(func(r chi.Router) {
 		r.Use(ConfigMiddleware(deps.Config))
+		r.Use(BotMiddleware(deps.Bot))
 		r.Get("/api/client-config", GetClientConfig)
 	})
 
@@ -36,6 +37,9 @@ func SetupRoutes(r *chi.Mux, deps ApiDependencies) {
 	// Используем MediaProxyHandler вместо ServeMediaHandler для безопасной отдачи файлов
 	r.Get("/api/media/{filename}", MediaProxyHandler)
 
+	// Вебхук YooKassa: без аутентификации, состояние платежа перепроверяется через API провайдера
+	r.Post("/api/payments/yookassa/webhook", deps.Bot.HandleYooKassaNotification)
+
 	r.Group(func(r chi.Router) {
 		r.Use(AuthMiddleware(deps.SecretKey))
 		r.Use(BotMiddleware(deps.Bot))
@@ -45,6 +49,8 @@ func SetupRoutes(r *chi.Mux, deps ApiDependencies) {
 
 		// --- Маршруты для обычных пользователей ---
 		r.Get("/api/user/profile", GetUserProfile)
+		r.Post("/api/user/receipt-email", UpdateReceiptEmail)
+		r.Post("/api/user/price-estimate", EstimatePriceAPI)
 		r.Get("/api/user/orders", GetOrders)
 		// --- НАЧАЛО ИЗМЕНЕНИЯ ---
 		// Связываем маршрут пользователя с правильным обработчиком CreateUserOrder.
@@ -60,6 +66,8 @@ func SetupRoutes(r *chi.Mux, deps ApiDependencies) {
 			r.Get("/orders", GetOrders)
 			r.Get("/clients", GetClients)
 			r.Get("/stats", GetStats)
+			r.With(RoleMiddleware(constants.ROLE_MAINOPERATOR)).Get("/profit-loss", GetProfitLossAPI)
+			r.With(RoleMiddleware(constants.ROLE_MAINOPERATOR)).Get("/accounting-export", GetAccountingExportAPI)
 			// Маршрут оператора остается связанным с CreateOrder, который не отправляет уведомления.
 			r.Post("/create-order", CreateOrder)
 			r.Get("/client/{id}", GetClientDetails)
@@ -70,14 +78,80 @@ func SetupRoutes(r *chi.Mux, deps ApiDependencies) {
 			r.Post("/order/{id}/action", HandleAdminOrderAction)
 			r.Post("/order/{id}/update-field", UpdateOrderFieldHandler)
 			r.Post("/order/{id}/add-media", AddOrderMedia)
+			r.Get("/order/{id}/payments", GetOrderPayments)
+			r.Get("/order/{id}/cost-items", GetOrderCostItems)
+			r.Get("/order/{id}/vehicle-suggestions", GetOrderVehicleSuggestionsAPI)
+			r.Get("/order/{id}/price-estimate", GetOrderPriceEstimateAPI)
+			r.Put("/order/{id}/vehicle", SetOrderVehicleAPI)
+			r.Put("/order/{id}/cost-items", UpdateOrderCostItems)
 			r.Post("/settlement/{id}/status", UpdateSettlementStatus)
+
+			// Главная книга, ставки, выписки водителей и ведомости доступны только владельцу
+			r.Group(func(r chi.Router) {
+				r.Use(RoleMiddleware(constants.ROLE_OWNER))
+				r.Get("/ledger/balances", GetLedgerBalances)
+				r.Get("/ledger/statement", GetLedgerStatement)
+				r.Get("/ledger/check", CheckLedger)
+				r.Get("/compensation-rules", GetCompensationRulesAPI)
+				r.Post("/compensation-rules", CreateCompensationRuleAPI)
+				r.Delete("/compensation-rules/{id}", DeleteCompensationRuleAPI)
+				r.Get("/loader-rates", GetLoaderPayRatesAPI)
+				r.Post("/loader-rates", CreateLoaderPayRateAPI)
+				r.Delete("/loader-rates/{id}", DeleteLoaderPayRateAPI)
+				r.Get("/price-rules", GetPriceRulesAPI)
+				r.Post("/price-rules", CreatePriceRuleAPI)
+				r.Put("/price-rules/{id}", ReplacePriceRuleAPI)
+				r.Delete("/price-rules/{id}", DeletePriceRuleAPI)
+				r.Get("/driver-statement/{id}", GetDriverStatementAPI)
+				r.Get("/payroll-runs", GetPayrollRunsAPI)
+				r.Post("/payroll-runs", CreatePayrollRunAPI)
+				r.Get("/payroll-runs/{id}", GetPayrollRunAPI)
+				r.Delete("/payroll-runs/{id}", DeletePayrollRunAPI)
+				r.Put("/payroll-runs/{id}/lines/{lineID}", UpdatePayrollRunLineAPI)
+				r.Post("/payroll-runs/{id}/recalculate", RecalculatePayrollRunAPI)
+				r.Post("/payroll-runs/{id}/confirm", ConfirmPayrollRunAPI)
+				r.Post("/payroll-runs/{id}/reverse", ReversePayrollRunAPI)
+				r.Get("/payroll-runs/{id}/registry", GetPayrollRegistryAPI)
+				r.Get("/vehicles", GetVehiclesAPI)
+				r.Post("/vehicles", CreateVehicleAPI)
+				r.Get("/vehicles/fuel-report", GetVehicleFuelReportAPI)
+				r.Put("/vehicles/{id}", UpdateVehicleAPI)
+				r.Delete("/vehicles/{id}", DeleteVehicleAPI)
+				r.Put("/vehicles/{id}/documents", UpdateVehicleDocumentsAPI)
+				r.Get("/vehicles/{id}/maintenance", GetVehicleMaintenanceAPI)
+				r.Post("/vehicles/{id}/maintenance", CreateVehicleMaintenanceAPI)
+				r.Delete("/vehicle-maintenance/{id}", CancelVehicleMaintenanceAPI)
+				r.Get("/cash/debts", GetCashDebtsAPI)
+				r.Get("/cash/drivers/{id}/balance", GetDriverCashBalanceAPI)
+				r.Get("/cash/handovers", GetCashHandoversAPI)
+				r.Post("/cash/handovers", CreateCashHandoverAPI)
+				r.Delete("/cash/handovers/{id}", CancelCashHandoverAPI)
+				r.Get("/cash/discrepancies", GetCashDiscrepanciesAPI)
+				r.Post("/cash/discrepancies/{id}/resolve", ResolveCashDiscrepancyAPI)
+				r.Get("/referral-payouts", GetReferralPayoutsAPI)
+				r.Get("/referral-payouts/{id}", GetReferralPayoutAPI)
+				r.Post("/referral-payouts/{id}/paid", CompleteReferralPayoutAPI)
+				r.Post("/referral-payouts/{id}/reject", RejectReferralPayoutAPI)
+				r.Get("/referral-reviews", GetReferralReviewsAPI)
+				r.Post("/referral-reviews/{id}/approve", ApproveReferralReviewAPI)
+				r.Post("/referral-reviews/{id}/reject", RejectReferralReviewAPI)
+				r.Get("/financial-periods", GetFinancialPeriodsAPI)
+				r.Post("/financial-periods/{month}/close", CloseFinancialPeriodAPI)
+				r.Post("/financial-periods/{month}/reopen", ReopenFinancialPeriodAPI)
+				r.Get("/referral-rules", GetReferralRulesAPI)
+				r.Post("/referral-rules", CreateReferralRuleAPI)
+			})
 		})
 
 		// --- Маршруты для водителя ---
 		r.Route("/api/driver", func(r chi.Router) {
 			r.Use(RoleMiddleware(constants.ROLE_DRIVER))
 			r.Post("/start-report", StartDriverReport)
+			r.Get("/statement", GetMyDriverStatementAPI)
 			r.Post("/order/{id}/action", HandleDriverOrderAction)
+			r.Post("/settlement/{id}/receipts", AddSettlementReceiptsAPI)
+			r.Get("/cash-handovers", GetMyCashHandoversAPI)
+			r.Post("/cash-handovers/{id}/ack", AcknowledgeCashHandoverAPI)
 		})
 	})
 }
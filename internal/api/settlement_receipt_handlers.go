@@ -0,0 +1,54 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// SettlementReceiptsRequest - чеки, загруженные через /api/upload-media, для расхода отчета.
+// Target: "fuel" или "other"; для "other" ExpenseIndex - номер прочего расхода в отчете с нуля.
+type SettlementReceiptsRequest struct {
+	Target       string   `json:"target"`
+	ExpenseIndex int      `json:"expense_index"`
+	Files        []string `json:"files"`
+}
+
+// AddSettlementReceiptsAPI прикрепляет фото чеков к своему отчету водителя, пока он на проверке.
+// Файлы предварительно загружаются через /api/upload-media.
+func AddSettlementReceiptsAPI(w http.ResponseWriter, r *http.Request) {
+	driver, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	settlementID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid settlement ID")
+		return
+	}
+	var req SettlementReceiptsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	if len(req.Files) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "No receipt files provided")
+		return
+	}
+
+	settlement, err := db.AddSettlementReceipts(settlementID, driver.ID, req.Target, req.ExpenseIndex, utils.ExtractFilenamesFromUrls(req.Files))
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to attach receipts: "+err.Error())
+		return
+	}
+	log.Printf("API AddSettlementReceipts: водитель %d приложил %d чеков к отчету #%d", driver.ID, len(req.Files), settlementID)
+	writeJSONSuccess(w, "Receipts attached successfully", settlement)
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/statements"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var statementContentTypes = map[string]string{
+	statements.FormatPDF:  "application/pdf",
+	statements.FormatXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// GetDriverStatementAPI - выписка владельца по водителю: /driver-statement/{id}?from=2025-01-01&to=2025-01-31&format=pdf.
+func GetDriverStatementAPI(w http.ResponseWriter, r *http.Request) {
+	driverUserID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil || driverUserID <= 0 {
+		writeJSONError(w, http.StatusBadRequest, "Invalid driver ID")
+		return
+	}
+	serveDriverStatement(w, r, driverUserID)
+}
+
+// GetMyDriverStatementAPI - выписка водителя по собственным отчетам, параметры как у GetDriverStatementAPI.
+func GetMyDriverStatementAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	serveDriverStatement(w, r, user.ID)
+}
+
+// serveDriverStatement отдает выписку в JSON (по умолчанию), PDF или XLSX.
+// Границы периода включительно; по умолчанию - с начала текущего месяца по сегодня.
+func serveDriverStatement(w http.ResponseWriter, r *http.Request, driverUserID int64) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := now
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		writeJSONError(w, http.StatusBadRequest, "'to' must not be before 'from'")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	contentType, isFile := statementContentTypes[format]
+	if format != "" && format != "json" && !isFile {
+		writeJSONError(w, http.StatusBadRequest, "Unsupported format, expected json, pdf or xlsx")
+		return
+	}
+
+	statement, err := db.GetDriverStatement(driverUserID, from, to)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build driver statement")
+		return
+	}
+	if !isFile {
+		if statement.Settlements == nil {
+			statement.Settlements = []models.DriverStatementSettlement{}
+		}
+		if statement.Movements == nil {
+			statement.Movements = []models.DriverStatementMovement{}
+		}
+		writeJSONSuccess(w, "Driver statement retrieved successfully", statement)
+		return
+	}
+
+	content, err := statements.Render(statement, format)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to render driver statement")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", statements.FileName(statement, format)))
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
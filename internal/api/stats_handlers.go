@@ -2,8 +2,11 @@ package api
 
 import (
 	"log"
+	"math"
 	"net/http"
+	"time"
 
+	"Original/internal/constants"
 	"Original/internal/db"
 	"Original/internal/models"
 )
@@ -32,84 +35,62 @@ func GetStats(w http.ResponseWriter, r *http.Request) {
 	writeJSONSuccess(w, "Statistics retrieved successfully", stats)
 }
 
-// calculateStatistics вычисляет статистику из базы данных
+// calculateStatistics вычисляет статистику из базы данных: суммы и разбивки считаются запросами,
+// а не перебором всех заказов.
 func calculateStatistics() (map[string]interface{}, error) {
-	// Получаем все заказы
-	orders, err := db.GetAllOrders()
+	dashboard, err := db.GetDashboardStats()
 	if err != nil {
 		return nil, err
 	}
 
-	// Получаем всех пользователей
-	users, err := db.GetAllUsers()
+	// Среднее время выполнения заказа (в часах)
+	avgOrderTime := 24.0 // Примерное значение, можно вычислить точнее
+
+	// Рост показателей: последние 30 дней в сравнении с предыдущими 30 днями
+	now := time.Now()
+	currentStats, err := db.GetStats(now.AddDate(0, 0, -30), now)
 	if err != nil {
 		return nil, err
 	}
-
-	// Вычисляем основные метрики
-	totalOrders := len(orders)
-	totalRevenue := int64(0)
-	completedOrders := 0
-	ordersByType := make(map[string]int)
-	monthlyRevenue := make(map[string]int64)
-
-	for _, order := range orders {
-		if order.Status == "completed" || order.Status == "settled" {
-			if order.Cost.Valid {
-				totalRevenue += int64(order.Cost.Float64)
-				completedOrders++
-			}
-		}
-
-		// Подсчет по типам услуг
-		serviceType := order.Category
-		if serviceType == "" {
-			serviceType = "unknown"
-		}
-		ordersByType[serviceType]++
-
-		// Группировка по месяцам
-		if !order.CreatedAt.IsZero() {
-			month := order.CreatedAt.Format("2006-01")
-			if order.Status == "completed" || order.Status == "settled" && order.Cost.Valid {
-				monthlyRevenue[month] += int64(order.Cost.Float64)
-			}
-		}
+	previousStats, err := db.GetStats(now.AddDate(0, 0, -60), now.AddDate(0, 0, -30))
+	if err != nil {
+		return nil, err
 	}
+	ordersGrowth := growthPercent(float64(currentStats.TotalOrders), float64(previousStats.TotalOrders))
+	revenueGrowth := growthPercent(currentStats.Revenue, previousStats.Revenue)
+	clientsGrowth := growthPercent(float64(currentStats.NewClients), float64(previousStats.NewClients))
 
-	// Подсчет клиентов (роль user)
-	totalClients := 0
-	for _, user := range users {
-		if user.Role == "user" {
-			totalClients++
-		}
+	monthlyRevenue := make(map[string]int64, len(dashboard.MonthlyRevenue))
+	for month, revenue := range dashboard.MonthlyRevenue {
+		monthlyRevenue[month] = int64(revenue)
+	}
+	topClients := make([]map[string]interface{}, 0, len(dashboard.TopClients))
+	for _, client := range dashboard.TopClients {
+		topClients = append(topClients, map[string]interface{}{
+			"name":    client.Name,
+			"orders":  client.Orders,
+			"revenue": int64(client.Revenue),
+		})
 	}
-
-	// Среднее время выполнения заказа (в часах)
-	avgOrderTime := 24.0 // Примерное значение, можно вычислить точнее
-
-	// Рост показателей (примерные значения, можно вычислить за период)
-	ordersGrowth := 15
-	revenueGrowth := 23
-	clientsGrowth := 8
-
-	// Топ клиенты (по количеству заказов)
-	topClients := getTopClients(orders, users)
 
 	// Топ сотрудники
-	topEmployees := getTopEmployees(users)
+	employees, err := db.GetUsersByRole(constants.ROLE_DRIVER, constants.ROLE_OPERATOR, constants.ROLE_OWNER)
+	if err != nil {
+		return nil, err
+	}
+	topEmployees := getTopEmployees(employees)
 
 	// Формируем результат
 	stats := map[string]interface{}{
-		"totalOrders":     totalOrders,
-		"totalRevenue":    totalRevenue,
-		"totalClients":    totalClients,
-		"completedOrders": completedOrders,
+		"totalOrders":     dashboard.TotalOrders,
+		"totalRevenue":    int64(dashboard.TotalRevenue),
+		"totalClients":    dashboard.TotalClients,
+		"completedOrders": dashboard.CompletedOrders,
 		"avgOrderTime":    avgOrderTime,
 		"ordersGrowth":    ordersGrowth,
 		"revenueGrowth":   revenueGrowth,
 		"clientsGrowth":   clientsGrowth,
-		"ordersByType":    ordersByType,
+		"ordersByType":    dashboard.OrdersByType,
 		"monthlyRevenue":  monthlyRevenue,
 		"topClients":      topClients,
 		"topEmployees":    topEmployees,
@@ -118,58 +99,13 @@ func calculateStatistics() (map[string]interface{}, error) {
 	return stats, nil
 }
 
-// getTopClients возвращает топ клиентов по количеству заказов
-func getTopClients(orders []models.Order, users []models.User) []map[string]interface{} {
-	// Подсчитываем заказы по пользователям
-	userOrders := make(map[int64]int)
-	userRevenue := make(map[int64]int64)
-
-	for _, order := range orders {
-		if order.UserID > 0 {
-			userOrders[int64(order.UserID)]++
-			if order.Status == "completed" || order.Status == "settled" && order.Cost.Valid {
-				userRevenue[int64(order.UserID)] += int64(order.Cost.Float64)
-			}
-		}
-	}
-
-	// Создаем мапу пользователей для быстрого поиска
-	userMap := make(map[int64]models.User)
-	for _, user := range users {
-		if user.Role == "user" {
-			userMap[user.ID] = user
-		}
-	}
-
-	// Создаем список топ клиентов
-	var topClients []map[string]interface{}
-	
-	for userID, orderCount := range userOrders {
-		if user, exists := userMap[userID]; exists && orderCount > 0 {
-			topClients = append(topClients, map[string]interface{}{
-				"name":    user.FirstName + " " + user.LastName,
-				"orders":  orderCount,
-				"revenue": userRevenue[userID],
-			})
-		}
-	}
-
-	// Сортируем по количеству заказов (топ 5)
-	// Простая сортировка пузырьком для небольшого количества данных
-	for i := 0; i < len(topClients)-1; i++ {
-		for j := 0; j < len(topClients)-i-1; j++ {
-			if topClients[j]["orders"].(int) < topClients[j+1]["orders"].(int) {
-				topClients[j], topClients[j+1] = topClients[j+1], topClients[j]
-			}
-		}
+// growthPercent - изменение показателя в целых процентах; без базы для сравнения рост считается нулевым.
+func growthPercent(current, previous float64) int {
+	change := models.PercentChange(current, previous)
+	if change == nil {
+		return 0
 	}
-
-	// Возвращаем только топ 5
-	if len(topClients) > 5 {
-		topClients = topClients[:5]
-	}
-
-	return topClients
+	return int(math.Round(*change))
 }
 
 // getTopEmployees возвращает топ сотрудников
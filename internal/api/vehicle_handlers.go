@@ -0,0 +1,391 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// VehicleRequest - тело запроса на добавление или изменение машины.
+type VehicleRequest struct {
+	Plate               string  `json:"plate"`
+	Model               string  `json:"model"`
+	CapacityM3          float64 `json:"capacity_m3"`
+	CapacityTonnes      float64 `json:"capacity_tonnes"`
+	FuelNormLPer100Km   float64 `json:"fuel_norm_l_per_100km"`
+	FuelPricePerL       float64 `json:"fuel_price_per_l"`
+	DefaultDriverUserID *int64  `json:"default_driver_user_id"`
+}
+
+// OrderVehicleRequest - назначение машины на заказ; null снимает машину.
+type OrderVehicleRequest struct {
+	VehicleID *int64 `json:"vehicle_id"`
+}
+
+// toVehicle проверяет закрепленного водителя и собирает модель машины.
+func (req VehicleRequest) toVehicle() (models.Vehicle, string) {
+	vehicle := models.Vehicle{
+		Plate:             strings.ToUpper(strings.TrimSpace(req.Plate)),
+		Model:             sql.NullString{String: strings.TrimSpace(req.Model), Valid: strings.TrimSpace(req.Model) != ""},
+		CapacityM3:        req.CapacityM3,
+		CapacityTonnes:    req.CapacityTonnes,
+		FuelNormLPer100Km: req.FuelNormLPer100Km,
+		FuelPricePerL:     req.FuelPricePerL,
+	}
+	if req.DefaultDriverUserID != nil {
+		driver, err := db.GetUserByID(int(*req.DefaultDriverUserID))
+		if err != nil || driver.Role != constants.ROLE_DRIVER {
+			return vehicle, "Driver not found"
+		}
+		vehicle.DefaultDriverUserID = sql.NullInt64{Int64: *req.DefaultDriverUserID, Valid: true}
+	}
+	return vehicle, ""
+}
+
+// GetVehiclesAPI возвращает машины автопарка; ?all=true - включая выведенные из работы.
+func GetVehiclesAPI(w http.ResponseWriter, r *http.Request) {
+	activeOnly := r.URL.Query().Get("all") != "true"
+	vehicles, err := db.GetVehicles(activeOnly)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load vehicles")
+		return
+	}
+	if vehicles == nil {
+		vehicles = []models.Vehicle{}
+	}
+	writeJSONSuccess(w, "Vehicles retrieved successfully", vehicles)
+}
+
+// CreateVehicleAPI добавляет машину в автопарк.
+func CreateVehicleAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	var req VehicleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	vehicle, problem := req.toVehicle()
+	if problem != "" {
+		writeJSONError(w, http.StatusBadRequest, problem)
+		return
+	}
+	id, err := db.CreateVehicle(vehicle)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to create vehicle: "+err.Error())
+		return
+	}
+	created, _ := db.GetVehicleByID(id)
+	log.Printf("API CreateVehicle: пользователь %d добавил машину #%d (%s)", user.ID, id, vehicle.Plate)
+	writeJSONSuccess(w, "Vehicle created successfully", created)
+}
+
+// UpdateVehicleAPI изменяет параметры машины.
+func UpdateVehicleAPI(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid vehicle ID")
+		return
+	}
+	var req VehicleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	vehicle, problem := req.toVehicle()
+	if problem != "" {
+		writeJSONError(w, http.StatusBadRequest, problem)
+		return
+	}
+	vehicle.ID = vehicleID
+	if err := db.UpdateVehicle(vehicle); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to update vehicle: "+err.Error())
+		return
+	}
+	updated, _ := db.GetVehicleByID(vehicleID)
+	writeJSONSuccess(w, "Vehicle updated successfully", updated)
+}
+
+// DeleteVehicleAPI выводит машину из работы; отчеты и заказы продолжают на нее ссылаться.
+func DeleteVehicleAPI(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid vehicle ID")
+		return
+	}
+	if err := db.SetVehicleActive(vehicleID, false); err != nil {
+		writeJSONError(w, http.StatusNotFound, "Vehicle not found")
+		return
+	}
+	writeJSONSuccess(w, "Vehicle deactivated successfully", nil)
+}
+
+// GetVehicleFuelReportAPI - расход топлива на 100 км: /vehicles/fuel-report?from=2025-01-01&to=2025-01-31.
+// Границы периода включительно; по умолчанию - последние constants.FUEL_REPORT_PERIOD_DAYS дней.
+func GetVehicleFuelReportAPI(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	from := to.AddDate(0, 0, -constants.FUEL_REPORT_PERIOD_DAYS+1)
+	if value := r.URL.Query().Get("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'from' date, expected YYYY-MM-DD")
+			return
+		}
+		from = parsed
+	}
+	if value := r.URL.Query().Get("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'to' date, expected YYYY-MM-DD")
+			return
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		writeJSONError(w, http.StatusBadRequest, "'to' must not be before 'from'")
+		return
+	}
+	report, err := db.GetVehicleFuelReport(from, to)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to build fuel report")
+		return
+	}
+	writeJSONSuccess(w, "Fuel report generated successfully", report)
+}
+
+// GetOrderVehicleSuggestionsAPI - машины для заказа с пометками о занятости и закрепленном водителе;
+// вместимость сверяется с объемом и весом груза из заказа.
+func GetOrderVehicleSuggestionsAPI(w http.ResponseWriter, r *http.Request) {
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Order not found")
+		return
+	}
+	suggestions, err := db.GetVehicleSuggestionsForOrder(orderID, order.VolumeM3.Float64, order.TonnageT.Float64)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load vehicle suggestions")
+		return
+	}
+	if suggestions == nil {
+		suggestions = []models.VehicleSuggestion{}
+	}
+	writeJSONSuccess(w, "Vehicle suggestions retrieved successfully", suggestions)
+}
+
+// SetOrderVehicleAPI назначает машину на заказ или снимает ее.
+func SetOrderVehicleAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	orderID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+	var req OrderVehicleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	vehicleID := sql.NullInt64{}
+	if req.VehicleID != nil {
+		vehicleID = sql.NullInt64{Int64: *req.VehicleID, Valid: true}
+	}
+	if err := db.SetOrderVehicle(orderID, vehicleID); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to set order vehicle: "+err.Error())
+		return
+	}
+	log.Printf("API SetOrderVehicle: пользователь %d, заказ #%d, машина %v", user.ID, orderID, vehicleID)
+	writeJSONSuccess(w, "Order vehicle updated successfully", map[string]interface{}{"order_id": orderID, "vehicle_id": req.VehicleID})
+}
+
+// VehicleDocumentsRequest - сроки документов (YYYY-MM-DD, пустая строка - не отслеживать) и интервалы ТО.
+type VehicleDocumentsRequest struct {
+	OsagoExpiresOn      string `json:"osago_expires_on"`
+	InspectionExpiresOn string `json:"inspection_expires_on"`
+	ServiceIntervalKm   int64  `json:"service_interval_km"`
+	ServiceIntervalDays int    `json:"service_interval_days"`
+}
+
+// VehicleMaintenanceRequest - запись об обслуживании. Пустая performed_on - сегодня,
+// valid_until - новый срок ОСАГО или техосмотра.
+type VehicleMaintenanceRequest struct {
+	Kind        string  `json:"kind"`
+	Cost        float64 `json:"cost"`
+	PerformedOn string  `json:"performed_on"`
+	Odometer    *int64  `json:"odometer"`
+	Description string  `json:"description"`
+	ValidUntil  string  `json:"valid_until"`
+}
+
+// parseOptionalAPIDate разбирает дату YYYY-MM-DD; пустая строка дает невалидный NullTime.
+func parseOptionalAPIDate(value string) (sql.NullTime, error) {
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return sql.NullTime{}, err
+	}
+	return sql.NullTime{Time: parsed, Valid: true}, nil
+}
+
+// UpdateVehicleDocumentsAPI задает сроки ОСАГО, техосмотра и интервалы ТО машины.
+func UpdateVehicleDocumentsAPI(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid vehicle ID")
+		return
+	}
+	var req VehicleDocumentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+	osago, errOsago := parseOptionalAPIDate(req.OsagoExpiresOn)
+	inspection, errInspection := parseOptionalAPIDate(req.InspectionExpiresOn)
+	if errOsago != nil || errInspection != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid document date, expected YYYY-MM-DD")
+		return
+	}
+	if err := db.SetVehicleDocuments(vehicleID, osago, inspection, req.ServiceIntervalKm, req.ServiceIntervalDays); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Failed to update vehicle documents: "+err.Error())
+		return
+	}
+	updated, _ := db.GetVehicleByID(vehicleID)
+	writeJSONSuccess(w, "Vehicle documents updated successfully", updated)
+}
+
+// GetVehicleMaintenanceAPI возвращает допуск машины на сегодня и последние записи об обслуживании.
+func GetVehicleMaintenanceAPI(w http.ResponseWriter, r *http.Request) {
+	vehicleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid vehicle ID")
+		return
+	}
+	vehicle, err := db.GetVehicleByID(vehicleID)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Vehicle not found")
+		return
+	}
+	limit := 50
+	if value, errLimit := strconv.Atoi(r.URL.Query().Get("limit")); errLimit == nil && value > 0 && value <= 500 {
+		limit = value
+	}
+	records, err := db.GetVehicleMaintenance(vehicleID, limit)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to load maintenance records")
+		return
+	}
+	if records == nil {
+		records = []models.VehicleMaintenance{}
+	}
+	compliance, err := db.GetVehicleCompliance(vehicle, time.Now())
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to check vehicle compliance")
+		return
+	}
+	writeJSONSuccess(w, "Vehicle maintenance retrieved successfully", map[string]interface{}{
+		"vehicle":    vehicle,
+		"compliance": compliance,
+		"records":    records,
+	})
+}
+
+// CreateVehicleMaintenanceAPI добавляет запись об обслуживании; стоимость проводится расходом из кассы.
+func CreateVehicleMaintenanceAPI(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value(UserContextKey).(models.User)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, "User context not found")
+		return
+	}
+	vehicleID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid vehicle ID")
+		return
+	}
+	var req VehicleMaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid request body: "+err.Error())
+		return
+	}
+
+	record := models.VehicleMaintenance{
+		VehicleID:       vehicleID,
+		Kind:            req.Kind,
+		Cost:            req.Cost,
+		Description:     sql.NullString{String: req.Description, Valid: req.Description != ""},
+		CreatedByUserID: sql.NullInt64{Int64: user.ID, Valid: true},
+	}
+	now := time.Now()
+	record.PerformedOn = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	if req.PerformedOn != "" {
+		performedOn, errDate := time.ParseInLocation("2006-01-02", req.PerformedOn, time.Local)
+		if errDate != nil || performedOn.After(now) {
+			writeJSONError(w, http.StatusBadRequest, "Invalid 'performed_on' date, expected YYYY-MM-DD not in the future")
+			return
+		}
+		record.PerformedOn = performedOn
+	}
+	if req.Odometer != nil {
+		if *req.Odometer < 0 {
+			writeJSONError(w, http.StatusBadRequest, "Odometer must not be negative")
+			return
+		}
+		record.Odometer = sql.NullInt64{Int64: *req.Odometer, Valid: true}
+	}
+	if record.ValidUntil, err = parseOptionalAPIDate(req.ValidUntil); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid 'valid_until' date, expected YYYY-MM-DD")
+		return
+	}
+
+	id, err := db.AddVehicleMaintenance(record)
+	if err != nil {
+		if writeClosedPeriodError(w, err) {
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "Failed to add maintenance record: "+err.Error())
+		return
+	}
+	record.ID = id
+	log.Printf("API CreateVehicleMaintenance: пользователь %d добавил запись #%d для машины #%d", user.ID, id, vehicleID)
+	writeJSONSuccess(w, "Maintenance record created successfully", record)
+}
+
+// CancelVehicleMaintenanceAPI отменяет запись об обслуживании со сторно проводки.
+func CancelVehicleMaintenanceAPI(w http.ResponseWriter, r *http.Request) {
+	recordID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid maintenance record ID")
+		return
+	}
+	if _, err := db.CancelVehicleMaintenance(recordID); err != nil {
+		if writeClosedPeriodError(w, err) {
+			return
+		}
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSONSuccess(w, "Maintenance record canceled successfully", nil)
+}
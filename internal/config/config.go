@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config хранит все конфигурационные параметры приложения.
@@ -26,6 +27,28 @@ type Config struct {
 	DriverSharePercentage float64
 	YooKassaShopID        string
 	YooKassaSecretKey     string
+	YooKassaAPIURL        string        // Базовый адрес API YooKassa (можно указать локальную заглушку)
+	PaymentReconcileEvery time.Duration // Период сверки незавершенных платежей с провайдером
+	VehicleCheckEvery     time.Duration // Период проверки документов и ТО машин автопарка
+	CashAckReminderEvery  time.Duration // Период проверки неподтвержденных водителями сдач наличных
+	ReferralExpiryEvery   time.Duration // Период списания реферальных бонусов с истекшим сроком
+	QuoteExpiryEvery      time.Duration // Период проверки истекших предложений с вариантами стоимости
+	// Автоотмена заказов, которые клиент не подтвердил или не оплатил (0 - не отменять)
+	ConfirmationExpiry   time.Duration // Срок подтверждения стоимости клиентом (awaiting_confirmation)
+	PaymentExpiry        time.Duration // Срок оплаты заказа (awaiting_payment)
+	ExpiryReminderBefore time.Duration // За сколько до автоотмены напомнить клиенту (0 - без напоминания)
+	StaleOrderCheckEvery time.Duration // Период проверки зависших заказов
+	// Регулярные заказы
+	RecurringOrdersEvery     time.Duration // Период запуска планировщика регулярных заказов
+	RecurringOrdersAheadDays int           // За сколько дней до даты создавать заказ по графику
+	// PaymentMethods - включенные способы оплаты в порядке показа клиенту (yookassa, telegram, sbp, cash)
+	PaymentMethods               []string
+	TelegramPaymentProviderToken string // Токен платежного провайдера Telegram Payments (из @BotFather)
+	ReceiptVATCode               int    // Код ставки НДС для позиций чека (1 = без НДС)
+	ReceiptTaxSystemCode         int    // Код системы налогообложения для чека (0 = не передавать)
+	// Координаты базы, от которой считается расстояние до адреса заказа (0, 0 - не заданы)
+	DepotLatitude  float64
+	DepotLongitude float64
 
 	// --- ДОБАВЛЕНО: ID канала для хранения файлов ---
 	StorageChannelID int64 `yaml:"storage_channel_id"`
@@ -83,6 +106,135 @@ func LoadConfig() (*Config, error) {
 	cfg.YooKassaShopID = os.Getenv("YOOKASSA_SHOP_ID")
 	cfg.YooKassaSecretKey = os.Getenv("YOOKASSA_SECRET_KEY")
 
+	cfg.YooKassaAPIURL = os.Getenv("YOOKASSA_API_URL")
+	if cfg.YooKassaAPIURL == "" {
+		cfg.YooKassaAPIURL = "https://api.yookassa.ru/v3"
+	}
+
+	cfg.PaymentReconcileEvery = 5 * time.Minute
+	if reconcileStr := os.Getenv("PAYMENT_RECONCILE_MINUTES"); reconcileStr != "" {
+		minutes, errParse := strconv.Atoi(reconcileStr)
+		if errParse != nil || minutes <= 0 {
+			log.Printf("Предупреждение: Некорректное значение PAYMENT_RECONCILE_MINUTES ('%s'). Используется значение по умолчанию 5 минут.", reconcileStr)
+		} else {
+			cfg.PaymentReconcileEvery = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	cfg.VehicleCheckEvery = time.Hour
+	if vehicleCheckStr := os.Getenv("VEHICLE_CHECK_MINUTES"); vehicleCheckStr != "" {
+		minutes, errParse := strconv.Atoi(vehicleCheckStr)
+		if errParse != nil || minutes <= 0 {
+			log.Printf("Предупреждение: Некорректное значение VEHICLE_CHECK_MINUTES ('%s'). Используется значение по умолчанию 60 минут.", vehicleCheckStr)
+		} else {
+			cfg.VehicleCheckEvery = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	cfg.CashAckReminderEvery = time.Hour
+	if cashAckStr := os.Getenv("CASH_ACK_REMINDER_MINUTES"); cashAckStr != "" {
+		minutes, errParse := strconv.Atoi(cashAckStr)
+		if errParse != nil || minutes <= 0 {
+			log.Printf("Предупреждение: Некорректное значение CASH_ACK_REMINDER_MINUTES ('%s'). Используется значение по умолчанию 60 минут.", cashAckStr)
+		} else {
+			cfg.CashAckReminderEvery = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	cfg.ReferralExpiryEvery = time.Hour
+	if referralExpiryStr := os.Getenv("REFERRAL_EXPIRY_CHECK_MINUTES"); referralExpiryStr != "" {
+		minutes, errParse := strconv.Atoi(referralExpiryStr)
+		if errParse != nil || minutes <= 0 {
+			log.Printf("Предупреждение: Некорректное значение REFERRAL_EXPIRY_CHECK_MINUTES ('%s'). Используется значение по умолчанию 60 минут.", referralExpiryStr)
+		} else {
+			cfg.ReferralExpiryEvery = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	cfg.QuoteExpiryEvery = 15 * time.Minute
+	if quoteExpiryStr := os.Getenv("QUOTE_EXPIRY_CHECK_MINUTES"); quoteExpiryStr != "" {
+		minutes, errParse := strconv.Atoi(quoteExpiryStr)
+		if errParse != nil || minutes <= 0 {
+			log.Printf("Предупреждение: Некорректное значение QUOTE_EXPIRY_CHECK_MINUTES ('%s'). Используется значение по умолчанию 15 минут.", quoteExpiryStr)
+		} else {
+			cfg.QuoteExpiryEvery = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	cfg.ConfirmationExpiry = parseHoursEnv("ORDER_CONFIRMATION_EXPIRY_HOURS", 72)
+	cfg.PaymentExpiry = parseHoursEnv("ORDER_PAYMENT_EXPIRY_HOURS", 24)
+	cfg.ExpiryReminderBefore = parseHoursEnv("ORDER_EXPIRY_REMINDER_HOURS", 6)
+
+	cfg.StaleOrderCheckEvery = 30 * time.Minute
+	if staleCheckStr := os.Getenv("STALE_ORDER_CHECK_MINUTES"); staleCheckStr != "" {
+		minutes, errParse := strconv.Atoi(staleCheckStr)
+		if errParse != nil || minutes <= 0 {
+			log.Printf("Предупреждение: Некорректное значение STALE_ORDER_CHECK_MINUTES ('%s'). Используется значение по умолчанию 30 минут.", staleCheckStr)
+		} else {
+			cfg.StaleOrderCheckEvery = time.Duration(minutes) * time.Minute
+		}
+	}
+
+	cfg.RecurringOrdersEvery = time.Hour
+	if recurringEveryStr := os.Getenv("RECURRING_ORDERS_CHECK_MINUTES"); recurringEveryStr != "" {
+		minutes, errParse := strconv.Atoi(recurringEveryStr)
+		if errParse != nil || minutes <= 0 {
+			log.Printf("Предупреждение: Некорректное значение RECURRING_ORDERS_CHECK_MINUTES ('%s'). Используется значение по умолчанию 60 минут.", recurringEveryStr)
+		} else {
+			cfg.RecurringOrdersEvery = time.Duration(minutes) * time.Minute
+		}
+	}
+	cfg.RecurringOrdersAheadDays = 3
+	if aheadStr := os.Getenv("RECURRING_ORDERS_AHEAD_DAYS"); aheadStr != "" {
+		days, errParse := strconv.Atoi(aheadStr)
+		if errParse != nil || days < 0 {
+			log.Printf("Предупреждение: Некорректное значение RECURRING_ORDERS_AHEAD_DAYS ('%s'). Используется значение по умолчанию 3 дня.", aheadStr)
+		} else {
+			cfg.RecurringOrdersAheadDays = days
+		}
+	}
+
+	methodsStr := os.Getenv("PAYMENT_METHODS")
+	if methodsStr == "" {
+		methodsStr = "yookassa,cash"
+	}
+	for _, method := range strings.Split(methodsStr, ",") {
+		if method = strings.TrimSpace(method); method != "" {
+			cfg.PaymentMethods = append(cfg.PaymentMethods, method)
+		}
+	}
+	cfg.TelegramPaymentProviderToken = os.Getenv("TELEGRAM_PAYMENT_PROVIDER_TOKEN")
+
+	cfg.ReceiptVATCode = 1
+	if vatStr := os.Getenv("RECEIPT_VAT_CODE"); vatStr != "" {
+		vatCode, errParse := strconv.Atoi(vatStr)
+		if errParse != nil || vatCode < 1 || vatCode > 12 {
+			log.Printf("Предупреждение: Некорректное значение RECEIPT_VAT_CODE ('%s'). Используется значение по умолчанию 1 (без НДС).", vatStr)
+		} else {
+			cfg.ReceiptVATCode = vatCode
+		}
+	}
+	if taxStr := os.Getenv("RECEIPT_TAX_SYSTEM_CODE"); taxStr != "" {
+		taxCode, errParse := strconv.Atoi(taxStr)
+		if errParse != nil || taxCode < 1 || taxCode > 6 {
+			log.Printf("Предупреждение: Некорректное значение RECEIPT_TAX_SYSTEM_CODE ('%s'). Код системы налогообложения не будет передаваться в чеке.", taxStr)
+		} else {
+			cfg.ReceiptTaxSystemCode = taxCode
+		}
+	}
+
+	if depotLatStr, depotLonStr := os.Getenv("DEPOT_LATITUDE"), os.Getenv("DEPOT_LONGITUDE"); depotLatStr != "" || depotLonStr != "" {
+		lat, errLat := strconv.ParseFloat(depotLatStr, 64)
+		lon, errLon := strconv.ParseFloat(depotLonStr, 64)
+		if errLat != nil || errLon != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			log.Printf("Предупреждение: Некорректные координаты базы DEPOT_LATITUDE/DEPOT_LONGITUDE ('%s', '%s'). Надбавка за расстояние не будет начисляться.", depotLatStr, depotLonStr)
+		} else {
+			cfg.DepotLatitude, cfg.DepotLongitude = lat, lon
+		}
+	} else {
+		log.Println("Предупреждение: DEPOT_LATITUDE/DEPOT_LONGITUDE не установлены. Надбавка за расстояние не будет начисляться.")
+	}
+
 	if cfg.YooKassaShopID == "" {
 		log.Println("Предупреждение: YOOKASSA_SHOP_ID не установлен. Функции оплаты картой не будут работать.")
 	}
@@ -117,3 +269,18 @@ func LoadConfig() (*Config, error) {
 	log.Println("Конфигурация загружена.")
 	return cfg, nil
 }
+
+// parseHoursEnv читает срок в часах из переменной окружения. 0 допустим и отключает срок;
+// при некорректном значении используется defaultHours.
+func parseHoursEnv(name string, defaultHours int) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return time.Duration(defaultHours) * time.Hour
+	}
+	hours, errParse := strconv.Atoi(value)
+	if errParse != nil || hours < 0 {
+		log.Printf("Предупреждение: Некорректное значение %s ('%s'). Используется значение по умолчанию %d ч.", name, value, defaultHours)
+		return time.Duration(defaultHours) * time.Hour
+	}
+	return time.Duration(hours) * time.Hour
+}
@@ -20,11 +20,15 @@ const (
 	STATE_ORDER_ADDRESS          = "order_address"
 	STATE_ORDER_ADDRESS_LOCATION = "order_address_location"
 	STATE_ORDER_ADDRESS_CONFIRM  = "order_address_confirm"
-	STATE_ORDER_VOLUME           = "order_volume"  // Может быть не используется, но есть в списке
-	STATE_ORDER_TONNAGE          = "order_tonnage" // Может быть не используется, но есть в списке
+	STATE_ORDER_VOLUME           = "order_volume"   // Объем мусора, м³
+	STATE_ORDER_TONNAGE          = "order_tonnage"  // Вес мусора, т (металл)
+	STATE_ORDER_FLOOR            = "order_floor"    // Этаж, с которого выносить
+	STATE_ORDER_ELEVATOR         = "order_elevator" // Есть ли лифт (спрашивается, если этаж выше первого)
+	STATE_ORDER_LOADERS          = "order_loaders"  // Нужны ли грузчики
 	STATE_ORDER_PHOTO            = "order_photo"
 	STATE_ORDER_PAYMENT          = "order_payment"
-	STATE_ORDER_CONFIRM          = "order_confirm" // Общее состояние подтверждения, может быть адаптировано
+	STATE_RECEIPT_EMAIL_INPUT    = "receipt_email_input" // Клиент вводит email для получения чека
+	STATE_ORDER_CONFIRM          = "order_confirm"       // Общее состояние подтверждения, может быть адаптировано
 	STATE_ORDER_EDIT             = "order_edit"
 	STATE_ORDER_NAME_CONFIRM     = "order_name_confirm" // Кажется, это для подтверждения конкретного поля, не всего заказа
 	STATE_OPERATOR_SELECT_CLIENT = "operator_select_client"
@@ -58,11 +62,16 @@ const (
 // Referral Program States
 // Состояния реферальной программы
 const (
-	STATE_INVITE_FRIEND           = "invite_friend"
-	STATE_REFERRAL_LINK           = "referral_link"
-	STATE_REFERRAL_QR             = "referral_qr"
-	STATE_MY_REFERRALS            = "my_referrals"
-	STATE_REFERRAL_PAYOUT_CONFIRM = "referral_payout_confirm"
+	STATE_INVITE_FRIEND             = "invite_friend"
+	STATE_REFERRAL_LINK             = "referral_link"
+	STATE_REFERRAL_QR               = "referral_qr"
+	STATE_MY_REFERRALS              = "my_referrals"
+	STATE_REFERRAL_PAYOUT_CONFIRM   = "referral_payout_confirm"
+	STATE_REFERRAL_PAYOUT_CARD      = "referral_payout_card"      // Клиент вводит номер карты для выплаты бонусов
+	STATE_REFERRAL_PAYOUT_SBP       = "referral_payout_sbp"       // Клиент вводит телефон для выплаты по СБП
+	STATE_OWNER_REFERRAL_REJECT     = "owner_referral_reject"     // Владелец вводит причину отказа в выплате
+	STATE_OWNER_REFERRAL_RULES      = "owner_referral_rules"      // Владелец смотрит условия реферальной программы
+	STATE_OWNER_REFERRAL_RULE_INPUT = "owner_referral_rule_input" // Владелец вводит новую версию условий
 )
 
 // Staff Management States
@@ -116,14 +125,16 @@ const (
 	STATE_COST_INPUT             = "cost_input" // Общее состояние для ввода стоимости (может использоваться и оператором для любого заказа)
 	STATE_CANCEL_REASON          = "cancel_reason"
 	STATE_ORDER_FINAL_COST_INPUT = "order_final_cost_input" // Для изменения финальной стоимости уже завершенного заказа
+	STATE_QUOTE_OPTIONS_INPUT    = "quote_options_input"    // Оператор вводит варианты стоимости для клиента
 )
 
 // Salary, Expenses, and Payout States (New Section)
 // Состояния зарплат, расходов и выплат (Новый раздел)
 const (
-	STATE_MY_SALARY_MENU     = "my_salary_menu"
-	STATE_VIEW_SALARY_OWED   = "view_salary_owed"
-	STATE_VIEW_SALARY_EARNED = "view_salary_earned"
+	STATE_MY_SALARY_MENU        = "my_salary_menu"
+	STATE_VIEW_SALARY_OWED      = "view_salary_owed"
+	STATE_VIEW_SALARY_EARNED    = "view_salary_earned"
+	STATE_VIEW_SALARY_STATEMENT = "view_salary_statement"
 
 	// Driver Inline Report States
 
@@ -140,7 +151,10 @@ const (
 	STATE_DRIVER_REPORT_INPUT_LOADER_NAME               = "driver_report_input_loader_name"
 	STATE_DRIVER_REPORT_INPUT_LOADER_SALARY             = "driver_report_input_loader_salary"
 	STATE_DRIVER_REPORT_EDIT_LOADER_SALARY              = "driver_report_edit_loader_salary"
+	STATE_DRIVER_REPORT_INPUT_LOADER_QUANTITIES         = "driver_report_input_loader_quantities"
 	STATE_DRIVER_REPORT_CONFIRM_DELETE_LOADER           = "driver_report_confirm_delete_loader"
+	STATE_DRIVER_REPORT_VEHICLE_MENU                    = "driver_report_vehicle_menu"
+	STATE_DRIVER_REPORT_INPUT_ODOMETER                  = "driver_report_input_odometer" // Водитель вводит показания одометра
 
 	// Owner Payouts States
 	STATE_OWNER_STAFF_PAYOUTS_MENU      = "owner_staff_payouts_menu"
@@ -153,7 +167,26 @@ const (
 	STATE_OWNER_FINANCIAL_EDIT_RECORD             = "owner_financial_edit_record" // Для редактирования полей старого отчета
 	STATE_OWNER_FINANCIAL_EDIT_FIELD              = "owner_financial_edit_field"  // Для ввода значения поля старого отчета
 	STATE_OWNER_CASH_MANAGEMENT_MENU              = "owner_cash_management_menu"
+	STATE_OWNER_CASH_LEDGER_CHECK                 = "owner_cash_ledger_check"
+	STATE_OWNER_COMP_RULES                        = "owner_comp_rules"
+	STATE_OWNER_COMP_RULE_INPUT                   = "owner_comp_rule_input" // Владелец вводит новое правило доли водителя
+	STATE_OWNER_LOADER_RATES                      = "owner_loader_rates"
+	STATE_OWNER_LOADER_RATE_INPUT                 = "owner_loader_rate_input" // Владелец вводит новый тариф грузчика
+	STATE_OWNER_PRICE_RULES                       = "owner_price_rules"
+	STATE_OWNER_PRICE_RULE_INPUT                  = "owner_price_rule_input"      // Владелец вводит новое или исправленное правило цены заказа
+	STATE_STATEMENT_PERIOD_INPUT                  = "statement_period_input"      // Ввод периода выписки водителя
+	STATE_OWNER_PAYROLL_LINE_INPUT                = "owner_payroll_line_input"    // Владелец меняет сумму строки ведомости
+	STATE_OWNER_PAYROLL_REVERSE_INPUT             = "owner_payroll_reverse_input" // Владелец вводит причину сторно ведомости
+	STATE_OWNER_VEHICLES                          = "owner_vehicles"
+	STATE_OWNER_VEHICLE_INPUT                     = "owner_vehicle_input"       // Владелец вводит параметры машины
+	STATE_OWNER_VEHICLE_DOCS_INPUT                = "owner_vehicle_docs_input"  // Владелец вводит сроки документов и интервалы ТО
+	STATE_OWNER_VEHICLE_MAINT_INPUT               = "owner_vehicle_maint_input" // Владелец вводит запись об обслуживании
 	STATE_OWNER_CASH_ACTUAL_LIST                  = "owner_cash_actual_list"
+	STATE_OWNER_CASH_HANDOVER_INPUT               = "owner_cash_handover_input"    // Владелец вводит фактически сданную сумму
+	STATE_OWNER_CASH_DISCREPANCY_RESOLVE          = "owner_cash_discrepancy_input" // Владелец вводит комментарий к закрытию расхождения
+	STATE_OWNER_FINANCIAL_PERIODS                 = "owner_financial_periods"
+	STATE_OWNER_PERIOD_REOPEN_REASON              = "owner_period_reopen_reason"   // Владелец вводит причину открытия закрытого месяца
+	STATE_DRIVER_CASH_HANDOVER_DISPUTE            = "driver_cash_handover_dispute" // Водитель объясняет, почему не согласен с суммой
 	STATE_OWNER_CASH_SETTLED_LIST                 = "owner_cash_settled_list"
 	STATE_OWNER_CASH_VIEW_DRIVER_SETTLEMENTS      = "owner_cash_view_driver_settlements"
 	STATE_OWNER_CASH_EDIT_SETTLEMENT_FIELD        = "owner_cash_edit_settlement_field"
@@ -166,9 +199,157 @@ const (
 	SETTLEMENT_STATUS_APPROVED = "approved"
 	SETTLEMENT_STATUS_REJECTED = "rejected"
 )
+
+// Статусы платежной ведомости (payroll_runs).
+const (
+	PAYROLL_STATUS_DRAFT     = "draft"     // Черновик: суммы можно править
+	PAYROLL_STATUS_CONFIRMED = "confirmed" // Проведена: выплаты записаны
+	PAYROLL_STATUS_REVERSED  = "reversed"  // Сторнирована: выплаты отменены
+)
+
+// PayrollStatusDisplayMap - названия статусов ведомости.
+var PayrollStatusDisplayMap = map[string]string{
+	PAYROLL_STATUS_DRAFT:     "📝 Черновик",
+	PAYROLL_STATUS_CONFIRMED: "✅ Проведена",
+	PAYROLL_STATUS_REVERSED:  "↩️ Сторнирована",
+}
+
+// Счета главной книги. Персональные счета строятся из префикса и users.id: driver_cash:12.
+const (
+	LEDGER_ACCOUNT_TYPE_ASSET     = "asset"
+	LEDGER_ACCOUNT_TYPE_LIABILITY = "liability"
+	LEDGER_ACCOUNT_TYPE_INCOME    = "income"
+	LEDGER_ACCOUNT_TYPE_EXPENSE   = "expense"
+
+	LEDGER_ACCOUNT_COMPANY_CASH     = "company_cash"     // Касса компании (деньги у владельца)
+	LEDGER_ACCOUNT_ONLINE_RECEIPTS  = "online_receipts"  // Деньги, поступившие онлайн через платежного провайдера
+	LEDGER_ACCOUNT_REVENUE          = "revenue"          // Выручка по заказам
+	LEDGER_ACCOUNT_EXPENSE_FUEL     = "expense_fuel"     // Расходы на топливо
+	LEDGER_ACCOUNT_EXPENSE_OTHER    = "expense_other"    // Прочие расходы водителей
+	LEDGER_ACCOUNT_EXPENSE_PAYROLL  = "expense_payroll"  // Начисленная зарплата водителей и грузчиков
+	LEDGER_ACCOUNT_EXPENSE_REFERRAL = "expense_referral" // Начисленные реферальные бонусы
+	LEDGER_ACCOUNT_EXPENSE_VEHICLE  = "expense_vehicle"  // ТО, ремонт, страховка и техосмотр машин
+
+	LEDGER_ACCOUNT_DRIVER_CASH_PREFIX        = "driver_cash"        // Наличные на руках у водителя
+	LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX      = "staff_payable"      // Долг компании перед сотрудником по зарплате
+	LEDGER_ACCOUNT_REFERRAL_LIABILITY_PREFIX = "referral_liability" // Долг компании перед пригласившим по бонусам
+)
+
+// Виды проводок главной книги.
+const (
+	LEDGER_KIND_SETTLEMENT_ACCRUAL  = "settlement_accrual"  // Выручка, расходы и начисления по отчету водителя
+	LEDGER_KIND_SETTLEMENT_HANDOVER = "settlement_handover" // Водитель сдал деньги в кассу
+	LEDGER_KIND_DRIVER_SALARY_PAID  = "driver_salary_paid"  // Водитель забрал зарплату из собранных наличных
+	LEDGER_KIND_STAFF_PAYOUT        = "staff_payout"        // Выплата сотруднику
+	LEDGER_KIND_REFERRAL_ACCRUAL    = "referral_accrual"    // Начисление реферального бонуса
+	LEDGER_KIND_REFERRAL_PAYOUT     = "referral_payout"     // Выплата реферальных бонусов
+	LEDGER_KIND_VEHICLE_MAINTENANCE = "vehicle_maintenance" // Оплата обслуживания машины из кассы
+	LEDGER_KIND_CASH_HANDOVER       = "cash_handover"       // Водитель сдал наличные в кассу (фактическая сумма)
+	LEDGER_KIND_ORDER_EXPENSE       = "order_expense"       // Заработок водителя и грузчиков по расходам заказа до появления отчетов
+
+	LEDGER_SOURCE_DRIVER_SETTLEMENT       = "driver_settlement"
+	LEDGER_SOURCE_PAYOUT                  = "payout"
+	LEDGER_SOURCE_REFERRAL                = "referral"
+	LEDGER_SOURCE_REFERRAL_PAYOUT_REQUEST = "referral_payout_request"
+	LEDGER_SOURCE_VEHICLE_MAINTENANCE     = "vehicle_maintenance"
+	LEDGER_SOURCE_CASH_HANDOVER           = "cash_handover"
+	LEDGER_SOURCE_EXPENSE                 = "expense"
+)
+
+// LedgerKindDisplayMap - названия видов проводок для выписок.
+var LedgerKindDisplayMap = map[string]string{
+	LEDGER_KIND_SETTLEMENT_ACCRUAL:  "Отчет водителя",
+	LEDGER_KIND_SETTLEMENT_HANDOVER: "Сдача денег в кассу",
+	LEDGER_KIND_DRIVER_SALARY_PAID:  "ЗП из выручки",
+	LEDGER_KIND_STAFF_PAYOUT:        "Выплата",
+	LEDGER_KIND_REFERRAL_ACCRUAL:    "Реферальный бонус",
+	LEDGER_KIND_REFERRAL_PAYOUT:     "Выплата бонусов",
+	LEDGER_KIND_VEHICLE_MAINTENANCE: "Обслуживание машины",
+	LEDGER_KIND_CASH_HANDOVER:       "Сдача денег в кассу",
+	LEDGER_KIND_ORDER_EXPENSE:       "Расходы по заказу",
+}
+
+// Действия журнала закрытия периодов (financial_period_events.action).
+const (
+	FINANCIAL_PERIOD_ACTION_CLOSE  = "close"
+	FINANCIAL_PERIOD_ACTION_REOPEN = "reopen"
+)
+
+// FinancialPeriodsShownMonths - сколько последних завершенных месяцев показывать в меню закрытия периодов.
+const FinancialPeriodsShownMonths = 6
+
+// FinancialPeriodEventsShown - сколько последних записей журнала закрытия периодов показывать в меню.
+const FinancialPeriodEventsShown = 10
+
+// Расчет цены заказа по правилам price_rules.
+const (
+	PriceRoundingStep          = 100.0 // Расчетная цена и границы диапазона округляются до этого шага, ₽
+	PriceEstimateSpreadPercent = 15.0  // Разброс предварительной цены для клиента, ± %
+)
+
+// Шаги уточнения объема и доступа при оформлении заказа (ключи в callback ord_det_STEP_VALUE).
+const (
+	ORDER_DETAIL_VOLUME   = "volume"
+	ORDER_DETAIL_TONNAGE  = "tonnage"
+	ORDER_DETAIL_FLOOR    = "floor"
+	ORDER_DETAIL_ELEVATOR = "lift"
+	ORDER_DETAIL_LOADERS  = "loaders"
+)
+
+// OrderDetailStepsMap - какие вопросы задаются для подкатегории и в каком порядке.
+// Вопрос о лифте задается, только если указан этаж выше первого.
+var OrderDetailStepsMap = map[string][]string{
+	"construct":   {ORDER_DETAIL_VOLUME, ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"household":   {ORDER_DETAIL_VOLUME, ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"junk":        {ORDER_DETAIL_VOLUME, ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"other_waste": {ORDER_DETAIL_VOLUME, ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"metal":       {ORDER_DETAIL_TONNAGE, ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"greenery":    {ORDER_DETAIL_VOLUME, ORDER_DETAIL_LOADERS},
+	"tires":       {ORDER_DETAIL_VOLUME, ORDER_DETAIL_LOADERS},
+	"walls":       {ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"partitions":  {ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"floors":      {ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"ceilings":    {ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"plumbing":    {ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"tiles":       {ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+	"other_demo":  {ORDER_DETAIL_FLOOR, ORDER_DETAIL_ELEVATOR, ORDER_DETAIL_LOADERS},
+}
+
+// Варианты быстрого ответа на вопросы об объеме и доступе.
+var (
+	OrderVolumeOptionsM3 = []float64{1, 2, 4, 8, 15}
+	OrderTonnageOptionsT = []float64{0.1, 0.5, 1, 3, 5}
+	OrderFloorOptions    = []int64{1, 2, 3, 5, 9}
+)
+
+// Допустимые значения объема и доступа.
+const (
+	MaxOrderVolumeM3 = 100.0
+	MaxOrderTonnageT = 50.0
+	MaxOrderFloor    = 100
+)
+
+// Типы документов выгрузки в 1С (models.AccountingDocument.Type).
+const (
+	ACCOUNTING_DOC_SALE           = "sale"           // Реализация услуг по выполненному заказу
+	ACCOUNTING_DOC_CASH_RECEIPT   = "cash_receipt"   // Поступление денег: онлайн-оплата или сдача наличных в кассу
+	ACCOUNTING_DOC_PAYROLL_PAYOUT = "payroll_payout" // Выплата зарплаты сотруднику
+	ACCOUNTING_DOC_EXPENSE        = "expense"        // Прочий расход: топливо, расходы водителей, обслуживание машин, реферальные бонусы
+)
+
+// AccountingDocTypeDisplayMap - названия типов документов выгрузки.
+var AccountingDocTypeDisplayMap = map[string]string{
+	ACCOUNTING_DOC_SALE:           "Реализация",
+	ACCOUNTING_DOC_CASH_RECEIPT:   "Поступление денег",
+	ACCOUNTING_DOC_PAYROLL_PAYOUT: "Выплата зарплаты",
+	ACCOUNTING_DOC_EXPENSE:        "Прочий расход",
+}
+
 const (
 	CALLBACK_PREFIX_OPERATOR_APPROVE_SETTLEMENT = "op_approve_set"
 	CALLBACK_PREFIX_OPERATOR_REJECT_SETTLEMENT  = "op_reject_set"
+	CALLBACK_PREFIX_SETTLEMENT_RECEIPTS         = "sett_receipts"  // Фото чеков отчета водителя: sett_receipts_SETTLEMENTID
+	CALLBACK_PREFIX_ASSIGN_VEHICLE              = "assign_vehicle" // assign_vehicle_ORDERID_VEHICLEID, VEHICLEID=0 снимает машину с заказа
 )
 
 // General Text Messages
@@ -176,6 +357,8 @@ const (
 const (
 	AccessDeniedMessage = "❌ У вас нет прав доступа для этого действия."
 	InvisibleMessage    = "⌨️" // Используется для удаления ReplyKeyboard
+	// CostBreakdownHint подсказывает оператору формат расшифровки стоимости (каждая строка - позиция чека)
+	CostBreakdownHint = "Можно одним числом или по статьям, каждая с новой строки:\n`Вывоз 9000`\n`Погрузка 4000`\n`Утилизация 2000`"
 )
 
 // Order Categories, Statuses, User Roles
@@ -213,8 +396,67 @@ const (
 const (
 	MAX_PHOTOS = 30
 	MAX_VIDEOS = 30
+
+	MAX_RECEIPTS_PER_EXPENSE = 10 // Фото чеков на одну строку расходов в отчете водителя
+)
+
+// Vehicle Fleet
+// Автопарк
+const (
+	FUEL_NORM_TOLERANCE     = 0.2  // Допустимое отклонение расхода топлива от нормы машины (доля)
+	MAX_ODOMETER_SHIFT_KM   = 2000 // Больше этого пробега за один отчет считается ошибкой ввода
+	FUEL_REPORT_PERIOD_DAYS = 30   // Период отчета о расходе топлива по умолчанию
+
+	VEHICLE_EXPIRY_WARNING_DAYS = 14  // За сколько дней предупреждать об окончании ОСАГО, техосмотра и сроке ТО
+	VEHICLE_SERVICE_WARNING_KM  = 500 // За сколько км до планового ТО предупреждать владельца
+
+	VEHICLE_MAINTENANCE_SERVICE    = "service"    // Плановое ТО
+	VEHICLE_MAINTENANCE_REPAIR     = "repair"     // Ремонт
+	VEHICLE_MAINTENANCE_OSAGO      = "osago"      // Покупка или продление ОСАГО
+	VEHICLE_MAINTENANCE_INSPECTION = "inspection" // Технический осмотр
+	VEHICLE_MAINTENANCE_OTHER      = "other"      // Прочие расходы на машину
 )
 
+// Cash Handovers
+// Сдача наличных водителями
+const (
+	CASH_ACK_PENDING   = "pending"   // Водитель еще не подтвердил сдачу денег
+	CASH_ACK_CONFIRMED = "confirmed" // Водитель подтвердил сумму
+	CASH_ACK_DISPUTED  = "disputed"  // Водитель не согласен с суммой
+
+	CASH_DISCREPANCY_SHORTAGE = "shortage" // Сдано меньше ожидаемого
+	CASH_DISCREPANCY_OVERAGE  = "overage"  // Сдано больше ожидаемого
+	CASH_DISCREPANCY_DISPUTE  = "dispute"  // Водитель оспорил сумму сдачи
+
+	CASH_DEBT_AGE_FRESH_DAYS = 7  // Долг не старше стольких дней считается свежим
+	CASH_DEBT_AGE_LATE_DAYS  = 30 // Долг старше стольких дней считается просроченным
+
+	CASH_ACK_REMIND_AFTER_HOURS = 24 // Через сколько часов напомнить водителю о неподтвержденной сдаче
+)
+
+// CashDiscrepancyKindDisplayMap - названия видов расхождений по сдаче наличных.
+var CashDiscrepancyKindDisplayMap = map[string]string{
+	CASH_DISCREPANCY_SHORTAGE: "Недостача",
+	CASH_DISCREPANCY_OVERAGE:  "Излишек",
+	CASH_DISCREPANCY_DISPUTE:  "Спор водителя",
+}
+
+// CashAckStatusDisplayMap - названия статусов подтверждения сдачи наличных.
+var CashAckStatusDisplayMap = map[string]string{
+	CASH_ACK_PENDING:   "⏳ ждет подтверждения",
+	CASH_ACK_CONFIRMED: "✅ подтверждено",
+	CASH_ACK_DISPUTED:  "⚠️ оспорено",
+}
+
+// VehicleMaintenanceKindDisplayMap - названия видов обслуживания; ключи - то, что владелец вводит в боте.
+var VehicleMaintenanceKindDisplayMap = map[string]string{
+	VEHICLE_MAINTENANCE_SERVICE:    "ТО",
+	VEHICLE_MAINTENANCE_REPAIR:     "Ремонт",
+	VEHICLE_MAINTENANCE_OSAGO:      "ОСАГО",
+	VEHICLE_MAINTENANCE_INSPECTION: "Техосмотр",
+	VEHICLE_MAINTENANCE_OTHER:      "Прочее",
+}
+
 // Pagination
 // Пагинация
 const (
@@ -233,6 +475,150 @@ const (
 	PAYOUT_REQUEST_STATUS_COMPLETED = "completed"
 )
 
+// Referral Payout Methods
+// Способы выплаты реферальных бонусов (referral_payout_requests.payment_method)
+const (
+	REFERRAL_PAYOUT_METHOD_CARD = "card" // Перевод на карту; номер хранится зашифрованным
+	REFERRAL_PAYOUT_METHOD_SBP  = "sbp"  // Перевод по СБП на номер телефона
+)
+
+// Order Quote Statuses
+// Предложение клиенту нескольких вариантов стоимости (order_quotes.status)
+const (
+	QUOTE_STATUS_PENDING    = "pending"    // Ждет выбора клиента
+	QUOTE_STATUS_ACCEPTED   = "accepted"   // Клиент выбрал вариант
+	QUOTE_STATUS_REJECTED   = "rejected"   // Клиенту не подошел ни один вариант
+	QUOTE_STATUS_EXPIRED    = "expired"    // Срок ответа истек, заказ возвращен в новые
+	QUOTE_STATUS_SUPERSEDED = "superseded" // Оператор отправил новые варианты или стоимость
+	QUOTE_STATUS_CANCELED   = "canceled"   // Заказ отменен до ответа клиента
+)
+
+// Ограничения предложения с вариантами стоимости
+const (
+	MinQuoteOptions = 2
+	MaxQuoteOptions = 3
+)
+
+// QuoteValidityHoursOptions - сроки ответа клиента на варианты стоимости, из которых выбирает оператор.
+var QuoteValidityHoursOptions = []int{6, 24, 48, 72}
+
+// Статусы регулярного заказа (recurring_orders.status)
+const (
+	RECURRING_STATUS_ACTIVE = "active" // Планировщик создает заказы по графику
+	RECURRING_STATUS_PAUSED = "paused" // Заказы временно не создаются
+	RECURRING_STATUS_ENDED  = "ended"  // График завершен
+)
+
+// Статусы даты графика (recurring_order_occurrences.status)
+const (
+	OCCURRENCE_STATUS_CREATED = "created" // Заказ на дату создан
+	OCCURRENCE_STATUS_SKIPPED = "skipped" // Дата пропущена клиентом или оператором
+)
+
+// RecurringIntervalOptions - периодичность регулярного заказа в днях, из которой выбирает оператор.
+var RecurringIntervalOptions = []int{7, 14, 28}
+
+// RecurringIntervalDisplayMap - подписи периодичности регулярного заказа.
+var RecurringIntervalDisplayMap = map[int]string{
+	1:  "каждый день",
+	7:  "каждую неделю",
+	14: "раз в 2 недели",
+	28: "раз в 4 недели",
+}
+
+// RecurringStatusDisplayMap - подписи статусов регулярного заказа.
+var RecurringStatusDisplayMap = map[string]string{
+	RECURRING_STATUS_ACTIVE: "▶️ Действует",
+	RECURRING_STATUS_PAUSED: "⏸ На паузе",
+	RECURRING_STATUS_ENDED:  "⏹ Завершен",
+}
+
+// RecurringSkipReason - причина отмены заказа, дату которого пропустили в графике.
+const RecurringSkipReason = "Пропуск даты регулярного заказа"
+
+// Причины автоматической отмены заказов, которые клиент не подтвердил или не оплатил в срок
+const (
+	OrderExpiredConfirmationReason = "Автоотмена: клиент не подтвердил стоимость в срок"
+	OrderExpiredPaymentReason      = "Автоотмена: заказ не оплачен в срок"
+)
+
+// Referral Bonus Types
+// Способ расчета реферального бонуса (referral_rules.bonus_type)
+const (
+	REFERRAL_BONUS_TYPE_FIXED   = "fixed"   // Фиксированная сумма за первый заказ друга
+	REFERRAL_BONUS_TYPE_PERCENT = "percent" // Процент от стоимости первого заказа друга
+)
+
+// Referral Review Statuses
+// Проверка подозрительных реферальных бонусов (referrals.review_status); NULL - проверка не требовалась
+const (
+	REFERRAL_REVIEW_STATUS_PENDING  = "pending"  // Бонус удержан до решения владельца
+	REFERRAL_REVIEW_STATUS_APPROVED = "approved" // Владелец подтвердил бонус
+	REFERRAL_REVIEW_STATUS_REJECTED = "rejected" // Владелец признал бонус мошенническим
+)
+
+// Пороги проверки реферальных бонусов на мошенничество
+const (
+	REFERRAL_FRAUD_BURST_WINDOW_HOURS = 24  // Окно подсчета новых приглашенных у одного пригласившего
+	REFERRAL_FRAUD_BURST_LIMIT        = 5   // Больше приглашенных за окно - подозрительно
+	REFERRAL_FRAUD_NEARBY_METERS      = 150 // Заказ ближе к заказу пригласившего - подозрительно
+)
+
+var ReferralPayoutMethodDisplayMap = map[string]string{
+	REFERRAL_PAYOUT_METHOD_CARD: "Карта",
+	REFERRAL_PAYOUT_METHOD_SBP:  "СБП",
+}
+
+var PayoutRequestStatusDisplayMap = map[string]string{
+	PAYOUT_REQUEST_STATUS_PENDING:   "Ожидает",
+	PAYOUT_REQUEST_STATUS_APPROVED:  "Одобрен",
+	PAYOUT_REQUEST_STATUS_REJECTED:  "Отклонен",
+	PAYOUT_REQUEST_STATUS_COMPLETED: "Выплачен",
+}
+
+// Payment Providers and Statuses (payments table)
+// Платежные провайдеры и статусы записей в таблице payments
+const (
+	PAYMENT_PROVIDER_YOOKASSA = "yookassa" // Оплата картой через YooKassa
+	PAYMENT_PROVIDER_TELEGRAM = "telegram" // Счет Telegram Payments (оплата внутри Telegram)
+	PAYMENT_PROVIDER_SBP      = "sbp"      // Оплата по QR-коду СБП через YooKassa
+	PAYMENT_PROVIDER_CASH     = "cash"     // Наличные исполнителю, сдаются в кассу через отчет водителя
+
+	// Устаревшие значения orders.payment, сохраненные до появления провайдеров
+	PAYMENT_LEGACY_NOW   = "now"   // соответствует PAYMENT_PROVIDER_YOOKASSA
+	PAYMENT_LEGACY_LATER = "later" // соответствует PAYMENT_PROVIDER_CASH
+
+	PAYMENT_STATUS_CREATED             = "created" // Запись создана, ответ провайдера еще не получен
+	PAYMENT_STATUS_PENDING             = "pending"
+	PAYMENT_STATUS_WAITING_FOR_CAPTURE = "waiting_for_capture"
+	PAYMENT_STATUS_SUCCEEDED           = "succeeded"
+	PAYMENT_STATUS_CANCELED            = "canceled"
+	PAYMENT_STATUS_FAILED              = "failed" // Провайдер отклонил запрос на создание платежа
+
+	REFUND_STATUS_CREATED   = "created" // Запись создана, ответ провайдера еще не получен
+	REFUND_STATUS_PENDING   = "pending"
+	REFUND_STATUS_SUCCEEDED = "succeeded"
+	REFUND_STATUS_CANCELED  = "canceled"
+	REFUND_STATUS_FAILED    = "failed" // Провайдер отклонил запрос на возврат
+)
+
+// Order Cost Items (order_cost_items table)
+// Статьи расшифровки стоимости заказа; каждая статья - отдельная позиция фискального чека
+const (
+	COST_ITEM_REMOVAL      = "removal"      // Вывоз
+	COST_ITEM_LOADING      = "loading"      // Погрузка
+	COST_ITEM_DEMOLITION   = "demolition"   // Демонтаж
+	COST_ITEM_DISPOSAL_FEE = "disposal_fee" // Утилизация (плата полигону)
+	COST_ITEM_MATERIALS    = "materials"    // Стройматериалы (товар)
+	COST_ITEM_OTHER        = "other"        // Прочие услуги
+
+	// Признаки предмета и способа расчета для чека (54-ФЗ)
+	RECEIPT_SUBJECT_SERVICE   = "service"
+	RECEIPT_SUBJECT_COMMODITY = "commodity"
+	RECEIPT_MODE_PREPAYMENT   = "full_prepayment" // Оплата до оказания услуги
+	RECEIPT_MODE_FULL_PAYMENT = "full_payment"    // Оплата в момент или после оказания услуги
+)
+
 // Callback Data Prefixes
 // Префиксы данных обратного вызова
 const (
@@ -249,6 +635,7 @@ const (
 	CALLBACK_PREFIX_ORDER_SET_FINAL_COST = "ord_set_final_cst" // Для установки финальной стоимости УЖЕ ЗАВЕРШЕННОГО заказа
 	CALLBACK_PREFIX_ORDER_RESUME         = "ord_resume"
 	CALLBACK_PREFIX_PAY_ORDER            = "pay_order"
+	CALLBACK_PREFIX_RECEIPT_EMAIL        = "receipt_email" // receipt_email_ORDERID - ввод email для чека перед оплатой
 
 	CALLBACK_PREFIX_DRIVER_SETTLEMENT = "drv_settle" // Запускает инлайн-отчет водителя
 	CALLBACK_PREFIX_OWNER_FINANCIALS  = "own_fin"    // Старый, для фин.отчетов по датам (DEPRECATED)
@@ -265,6 +652,78 @@ const (
 	CALLBACK_PREFIX_OWNER_CASH_MARK_SALARY_UNPAID  = "own_cash_mark_sal_unpaid"
 	CALLBACK_PREFIX_OWNER_MARK_ALL_SALARY_PAID     = "own_mark_all_sal_paid"
 	CALLBACK_PREFIX_OWNER_MARK_ALL_MONEY_DEPOSITED = "own_mark_all_mon_dep"
+	CALLBACK_PREFIX_OWNER_LEDGER_CHECK             = "own_ledger_check"    // Балансы счетов и проверка целостности книги
+	CALLBACK_PREFIX_OWNER_COMP_RULES               = "own_comp_rules"      // Список правил долей водителей
+	CALLBACK_PREFIX_OWNER_COMP_RULE_ADD            = "own_comp_add"        // Добавление правила
+	CALLBACK_PREFIX_OWNER_COMP_RULE_DELETE         = "own_comp_del"        // own_comp_del_RULEID - отключение правила
+	CALLBACK_PREFIX_OWNER_LOADER_RATES             = "own_load_rates"      // Список тарифов грузчиков
+	CALLBACK_PREFIX_OWNER_LOADER_RATE_ADD          = "own_load_rate_add"   // Добавление тарифа
+	CALLBACK_PREFIX_OWNER_LOADER_RATE_DELETE       = "own_load_rate_del"   // own_load_rate_del_RATEID - отключение тарифа
+	CALLBACK_PREFIX_OWNER_PRICE_RULES              = "own_price_rules"     // Правила расчета цены заказа
+	CALLBACK_PREFIX_OWNER_PRICE_RULE_ADD           = "own_price_add"       // Добавление правила цены
+	CALLBACK_PREFIX_OWNER_PRICE_RULE_DELETE        = "own_price_del"       // own_price_del_RULEID - отключение правила цены
+	CALLBACK_PREFIX_OWNER_PRICE_RULE_EDIT          = "own_price_edit"      // own_price_edit_RULEID - замена правила цены исправленным
+	CALLBACK_PREFIX_PRICE_ESTIMATE_ACCEPT          = "price_accept"        // price_accept_ORDERID - оператор принимает расчетную цену
+	CALLBACK_PREFIX_ORDER_DETAIL                   = "ord_det"             // ord_det_STEP_VALUE - ответ на вопрос об объеме и доступе
+	CALLBACK_PREFIX_QUOTE_NEW                      = "quote_new"           // quote_new_ORDERID - оператор готовит варианты стоимости
+	CALLBACK_PREFIX_QUOTE_TTL                      = "quote_ttl"           // quote_ttl_ORDERID_HOURS - срок ответа и отправка вариантов клиенту
+	CALLBACK_PREFIX_QUOTE_PICK                     = "quote_pick"          // quote_pick_OPTIONID - клиент выбирает вариант
+	CALLBACK_PREFIX_REPEAT_ORDER                   = "repeat_order"        // repeat_order_ORDERID - клиент повторяет заказ из истории
+	CALLBACK_PREFIX_RECURRING_LIST                 = "rec_list"            // Список регулярных заказов (клиента или всех для оператора)
+	CALLBACK_PREFIX_RECURRING_NEW                  = "rec_new"             // rec_new_ORDERID - выбор периодичности для нового графика
+	CALLBACK_PREFIX_RECURRING_CREATE               = "rec_create"          // rec_create_ORDERID_DAYS - создание графика по заказу
+	CALLBACK_PREFIX_RECURRING_VIEW                 = "rec_view"            // rec_view_ID - карточка регулярного заказа
+	CALLBACK_PREFIX_RECURRING_PAUSE                = "rec_pause"           // rec_pause_ID - пауза или возобновление графика
+	CALLBACK_PREFIX_RECURRING_SKIP                 = "rec_skip"            // rec_skip_ID - пропуск ближайшей даты
+	CALLBACK_PREFIX_RECURRING_END                  = "rec_end"             // rec_end_ID - запрос подтверждения завершения графика
+	CALLBACK_PREFIX_RECURRING_END_CONFIRM          = "rec_end_ok"          // rec_end_ok_ID - завершение графика
+	CALLBACK_PREFIX_OWNER_STATEMENTS               = "own_stmt"            // Выбор водителя для выписки
+	CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER         = "own_stmt_drv"        // own_stmt_drv_DRIVERID - выбор периода
+	CALLBACK_PREFIX_DRIVER_STATEMENT               = "drv_stmt"            // Водитель запрашивает свою выписку
+	CALLBACK_PREFIX_STATEMENT_GENERATE             = "stmt_gen"            // stmt_gen_DRIVERID_YYYYMMDD_YYYYMMDD_FORMAT
+	CALLBACK_PREFIX_STATEMENT_PERIOD               = "stmt_period"         // stmt_period_DRIVERID - ввод произвольного периода
+	CALLBACK_PREFIX_STATS_PROFIT_LOSS              = "stats_pnl"           // stats_pnl_YYYYMMDD_YYYYMMDD_FORMAT - P&L за период (view или xlsx)
+	CALLBACK_PREFIX_STATS_ACCOUNTING_EXPORT        = "stats_1c"            // stats_1c_YYYYMMDD_YYYYMMDD_FORMAT - выгрузка в 1С (xml или csv)
+	CALLBACK_PREFIX_OWNER_PAYROLL                  = "own_payroll"         // Список ведомостей на выплату
+	CALLBACK_PREFIX_OWNER_PAYROLL_NEW              = "own_payroll_new"     // own_payroll_new_YYYYMMDD_YYYYMMDD - черновик за период
+	CALLBACK_PREFIX_OWNER_PAYROLL_VIEW             = "own_payroll_view"    // own_payroll_view_RUNID
+	CALLBACK_PREFIX_OWNER_PAYROLL_LINE             = "own_payroll_line"    // own_payroll_line_RUNID_LINEID - изменение суммы строки
+	CALLBACK_PREFIX_OWNER_PAYROLL_RECALC           = "own_payroll_recalc"  // own_payroll_recalc_RUNID
+	CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM          = "own_payroll_confirm" // own_payroll_confirm_RUNID - запрос подтверждения
+	CALLBACK_PREFIX_OWNER_PAYROLL_POST             = "own_payroll_post"    // own_payroll_post_RUNID - проведение выплат
+	CALLBACK_PREFIX_OWNER_PAYROLL_DELETE           = "own_payroll_del"     // own_payroll_del_RUNID - удаление черновика
+	CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY         = "own_payroll_reg"     // own_payroll_reg_RUNID_FORMAT - реестр на перечисление
+	CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE          = "own_payroll_rev"     // own_payroll_rev_RUNID - сторно проведенной ведомости
+	CALLBACK_PREFIX_OWNER_VEHICLES                 = "own_vehicles"        // Список машин автопарка
+	CALLBACK_PREFIX_OWNER_VEHICLE_ADD              = "own_veh_add"         // Добавление машины
+	CALLBACK_PREFIX_OWNER_VEHICLE_EDIT             = "own_veh_edit"        // own_veh_edit_VEHICLEID - изменение параметров
+	CALLBACK_PREFIX_OWNER_VEHICLE_TOGGLE           = "own_veh_toggle"      // own_veh_toggle_VEHICLEID - вывод из работы / возврат
+	CALLBACK_PREFIX_OWNER_FUEL_REPORT              = "own_fuel_rep"        // own_fuel_rep_DAYS - расход топлива на 100 км
+	CALLBACK_PREFIX_OWNER_VEHICLE_CARD             = "own_veh_card"        // own_veh_card_VEHICLEID - документы, ТО и история обслуживания
+	CALLBACK_PREFIX_OWNER_VEHICLE_DOCS             = "own_veh_docs"        // own_veh_docs_VEHICLEID - сроки ОСАГО, техосмотра и интервалы ТО
+	CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_ADD        = "own_veh_mnt"         // own_veh_mnt_VEHICLEID - новая запись об обслуживании
+	CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_CANCEL     = "own_veh_mntx"        // own_veh_mntx_RECORDID - отмена записи об обслуживании
+	CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW        = "own_cash_ho_new"     // own_cash_ho_new_DRIVERID - ввод фактически сданной суммы
+	CALLBACK_PREFIX_OWNER_CASH_HANDOVERS           = "own_cash_ho_list"    // own_cash_ho_list_DRIVERID_PAGE - история сдачи наличных
+	CALLBACK_PREFIX_OWNER_CASH_HANDOVER_CANCEL     = "own_cash_ho_cancel"  // own_cash_ho_cancel_HANDOVERID - отмена ошибочной записи
+	CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES       = "own_cash_disc_list"  // own_cash_disc_list_PAGE - открытые расхождения
+	CALLBACK_PREFIX_OWNER_CASH_DISCREPANCY_RESOLVE = "own_cash_disc_res"   // own_cash_disc_res_DISCREPANCYID - закрытие расхождения
+	CALLBACK_PREFIX_CASH_HANDOVER_ACK              = "cash_ho_ack"         // cash_ho_ack_HANDOVERID - водитель подтверждает сумму
+	CALLBACK_PREFIX_CASH_HANDOVER_DISPUTE          = "cash_ho_dispute"     // cash_ho_dispute_HANDOVERID - водитель не согласен
+	CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS         = "own_refpay_list"     // own_refpay_list_STATUS_PAGE - очередь запросов на выплату бонусов
+	CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW     = "own_refpay_view"     // own_refpay_view_REQUESTID - карточка запроса
+	CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_PAID     = "own_refpay_paid"     // own_refpay_paid_REQUESTID - деньги переведены
+	CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_REJECT   = "own_refpay_rej"      // own_refpay_rej_REQUESTID - отказ с причиной
+	CALLBACK_PREFIX_REFERRAL_PAYOUT_METHOD         = "ref_payout_method"   // ref_payout_method_METHOD - клиент выбрал способ выплаты
+	CALLBACK_PREFIX_REFERRAL_PAYOUT_PROFILE_CARD   = "ref_payout_profile"  // Клиент выбрал карту из профиля
+	CALLBACK_PREFIX_OWNER_REFERRAL_RULES           = "own_ref_rules"       // Условия реферальной программы и история версий
+	CALLBACK_PREFIX_OWNER_REFERRAL_RULE_ADD        = "own_ref_rule_add"    // Новая версия условий
+	CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS         = "own_refrev_list"     // own_refrev_list_PAGE - бонусы на проверке
+	CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE  = "own_refrev_ok"       // own_refrev_ok_REFERRALID - бонус подтвержден
+	CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_REJECT   = "own_refrev_no"       // own_refrev_no_REFERRALID - бонус отклонен
+	CALLBACK_PREFIX_OWNER_PERIODS                  = "own_periods"         // Закрытие месяцев и журнал открытий
+	CALLBACK_PREFIX_OWNER_PERIOD_CLOSE             = "own_period_close"    // own_period_close_YYYYMM - закрыть месяц
+	CALLBACK_PREFIX_OWNER_PERIOD_REOPEN            = "own_period_reopen"   // own_period_reopen_YYYYMM - открыть месяц с причиной
 
 	CALLBACK_PREFIX_DRIVER_CREATE_ORDER = "drv_create_new_order"
 
@@ -332,6 +791,54 @@ var StatusDisplayMap = map[string]string{
 	STATUS_CALCULATED:            "Рассчитан",
 	STATUS_SETTLED:               "Закрыт (оплачен)",
 }
+var PaymentMethodDisplayMap = map[string]string{
+	PAYMENT_PROVIDER_YOOKASSA: "Картой онлайн",
+	PAYMENT_PROVIDER_TELEGRAM: "Через Telegram",
+	PAYMENT_PROVIDER_SBP:      "СБП по QR-коду",
+	PAYMENT_PROVIDER_CASH:     "Наличными по выполнению",
+}
+var PaymentMethodEmojiMap = map[string]string{
+	PAYMENT_PROVIDER_YOOKASSA: "💳",
+	PAYMENT_PROVIDER_TELEGRAM: "✈️",
+	PAYMENT_PROVIDER_SBP:      "📱",
+	PAYMENT_PROVIDER_CASH:     "💵",
+}
+var CostItemDisplayMap = map[string]string{
+	COST_ITEM_REMOVAL:      "Вывоз мусора",
+	COST_ITEM_LOADING:      "Погрузка",
+	COST_ITEM_DEMOLITION:   "Демонтаж",
+	COST_ITEM_DISPOSAL_FEE: "Утилизация отходов",
+	COST_ITEM_MATERIALS:    "Стройматериалы",
+	COST_ITEM_OTHER:        "Прочие услуги",
+}
+
+// CostItemPaymentSubjectMap - признак предмета расчета для каждой статьи.
+var CostItemPaymentSubjectMap = map[string]string{
+	COST_ITEM_REMOVAL:      RECEIPT_SUBJECT_SERVICE,
+	COST_ITEM_LOADING:      RECEIPT_SUBJECT_SERVICE,
+	COST_ITEM_DEMOLITION:   RECEIPT_SUBJECT_SERVICE,
+	COST_ITEM_DISPOSAL_FEE: RECEIPT_SUBJECT_SERVICE,
+	COST_ITEM_MATERIALS:    RECEIPT_SUBJECT_COMMODITY,
+	COST_ITEM_OTHER:        RECEIPT_SUBJECT_SERVICE,
+}
+
+// CostItemKeywords - начала слов, по которым статья распознается в расшифровке, введенной оператором.
+var CostItemKeywords = map[string][]string{
+	COST_ITEM_REMOVAL:      {"вывоз", "транспорт", "машин"},
+	COST_ITEM_LOADING:      {"погруз", "грузчик", "спуск", "подъем", "подъём"},
+	COST_ITEM_DEMOLITION:   {"демонтаж", "разбор", "снос"},
+	COST_ITEM_DISPOSAL_FEE: {"утилиз", "полигон", "сбор"},
+	COST_ITEM_MATERIALS:    {"материал", "песок", "щебень", "цемент"},
+}
+
+// CategoryDefaultCostItemMap - статья, в которую попадает стоимость, введенная одним числом.
+var CategoryDefaultCostItemMap = map[string]string{
+	CAT_WASTE:      COST_ITEM_REMOVAL,
+	CAT_DEMOLITION: COST_ITEM_DEMOLITION,
+	CAT_MATERIALS:  COST_ITEM_MATERIALS,
+	CAT_OTHER:      COST_ITEM_OTHER,
+}
+
 var StatusEmojiMap = map[string]string{
 	STATUS_NEW:                   "🆕",
 	STATUS_AWAITING_COST:         "💰",
@@ -421,6 +928,12 @@ const (
 	CALLBACK_PREFIX_DRIVER_REPORT_ADD_LOADER_PROMPT                    = "drv_rpt_add_load_p"
 	CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT                   = "drv_rpt_edit_load_p"
 	CALLBACK_PREFIX_DRIVER_REPORT_DELETE_LOADER_CONFIRM                = "drv_rpt_del_load_c"
+	CALLBACK_PREFIX_DRIVER_REPORT_PICK_LOADER                          = "drv_rpt_pick_load"   // drv_rpt_pick_load_USERID - выбор грузчика из списка
+	CALLBACK_PREFIX_DRIVER_REPORT_ACCEPT_LOADER_RATE                   = "drv_rpt_load_accept" // drv_rpt_load_accept_INDEX - сумма по тарифу
+	CALLBACK_PREFIX_DRIVER_REPORT_LOADER_QUANTITIES                    = "drv_rpt_load_qty"    // drv_rpt_load_qty_INDEX - ввод часов, этажей, тонн
+	CALLBACK_PREFIX_DRIVER_REPORT_VEHICLE                              = "drv_rpt_vehicle"     // Машина и одометр
+	CALLBACK_PREFIX_DRIVER_REPORT_PICK_VEHICLE                         = "drv_rpt_pick_veh"    // drv_rpt_pick_veh_VEHICLEID - выбор машины
+	CALLBACK_PREFIX_DRIVER_REPORT_ODOMETER                             = "drv_rpt_odometer"    // Ввод показаний одометра
 	CALLBACK_PREFIX_DRIVER_REPORT_SAVE_FINAL                           = "drv_rpt_save"
 	CALLBACK_PREFIX_DRIVER_REPORT_CANCEL_ALL                           = "drv_rpt_cancel"
 	CALLBACK_PREFIX_DRIVER_REPORT_OTHER_EXPENSES_MENU                  = "drv_rpt_oth_menu"
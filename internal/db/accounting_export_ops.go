@@ -0,0 +1,174 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// accountingGUIDNamespace - пространство имен для UUID документов выгрузки в 1С.
+// Не менять: от него зависят GUID всех ранее выгруженных документов.
+var accountingGUIDNamespace = uuid.MustParse("6f1d3c52-8a7e-4b0f-9d61-2c5e7a9b4f10")
+
+// accountingSource - один вид исходных записей выгрузки. Запрос получает границы периода ($1, $2)
+// и возвращает id, дату, сумму, users.id и имя контрагента, статью, заказ и комментарий.
+// itemNames переводит код статьи (категорию, вид обслуживания) в название.
+type accountingSource struct {
+	docType      string
+	keyPrefix    string
+	numberPrefix string
+	query        string
+	args         []interface{}
+	itemNames    map[string]string
+}
+
+const accountingUserName = `TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))`
+
+func accountingSources() []accountingSource {
+	return []accountingSource{
+		{
+			docType: constants.ACCOUNTING_DOC_SALE, keyPrefix: "sale", numberPrefix: "РУ",
+			query: `
+                SELECT o.id, COALESCE(o.date, o.created_at::date), o.cost, COALESCE(o.user_id, 0),
+                       ` + accountingUserName + ` || COALESCE(' ' || NULLIF(o.phone, ''), ''),
+                       COALESCE(o.category, ''), o.id, COALESCE(o.address, '')
+                FROM orders o LEFT JOIN users u ON u.id = o.user_id
+                WHERE o.status IN ($3, $4, $5) AND COALESCE(o.cost, 0) > 0
+                  AND COALESCE(o.date, o.created_at::date) BETWEEN $1 AND $2`,
+			args:      []interface{}{constants.STATUS_COMPLETED, constants.STATUS_CALCULATED, constants.STATUS_SETTLED},
+			itemNames: constants.CategoryDisplayMap,
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_CASH_RECEIPT, keyPrefix: "receipt_online", numberPrefix: "ПБ",
+			query: `
+                SELECT p.id, p.paid_at::date, p.amount, COALESCE(o.user_id, 0), ` + accountingUserName + `,
+                       'Онлайн-оплата (' || p.provider || ')', p.order_id, COALESCE(p.external_id, '')
+                FROM payments p JOIN orders o ON o.id = p.order_id LEFT JOIN users u ON u.id = o.user_id
+                WHERE p.status = $3 AND p.paid_at IS NOT NULL AND p.paid_at::date BETWEEN $1 AND $2`,
+			args: []interface{}{constants.PAYMENT_STATUS_SUCCEEDED},
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_CASH_RECEIPT, keyPrefix: "receipt_cash", numberPrefix: "ПК",
+			query: `
+                SELECT h.id, h.handed_at::date, h.amount, h.driver_user_id, ` + accountingUserName + `,
+                       'Сдача наличных водителем', 0, COALESCE(h.comment, '')
+                FROM cash_handovers h JOIN users u ON u.id = h.driver_user_id
+                WHERE h.canceled_at IS NULL AND h.amount > 0 AND h.handed_at::date BETWEEN $1 AND $2`,
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_PAYROLL_PAYOUT, keyPrefix: "payout", numberPrefix: "ЗП",
+			query: `
+                SELECT p.id, p.payout_date::date, p.amount, p.user_id, ` + accountingUserName + `,
+                       CASE WHEN p.payroll_run_id IS NOT NULL THEN 'Ведомость #' || p.payroll_run_id ELSE 'Выплата зарплаты' END,
+                       COALESCE(p.order_id, 0), COALESCE(p.comment, '')
+                FROM payouts p JOIN users u ON u.id = p.user_id
+                WHERE p.reversed_at IS NULL AND p.referral_payout_request_id IS NULL AND p.amount > 0
+                  AND p.payout_date::date BETWEEN $1 AND $2`,
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_PAYROLL_PAYOUT, keyPrefix: "driver_salary", numberPrefix: "ЗВ",
+			query: `
+                SELECT s.id, s.driver_salary_paid_at::date, s.driver_calculated_salary, s.driver_user_id, ` + accountingUserName + `,
+                       'ЗП водителя из выручки', 0, 'Отчет водителя #' || s.id
+                FROM driver_settlements s JOIN users u ON u.id = s.driver_user_id
+                WHERE s.status <> $3 AND s.driver_salary_paid_at IS NOT NULL AND s.driver_calculated_salary > 0
+                  AND s.driver_salary_paid_at::date BETWEEN $1 AND $2`,
+			args: []interface{}{constants.SETTLEMENT_STATUS_REJECTED},
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_EXPENSE, keyPrefix: "fuel", numberPrefix: "ТП",
+			query: `
+                SELECT s.id, s.report_date, s.fuel_expense, s.driver_user_id, ` + accountingUserName + `,
+                       'Топливо', 0, 'Отчет водителя #' || s.id
+                FROM driver_settlements s JOIN users u ON u.id = s.driver_user_id
+                WHERE s.status <> $3 AND s.fuel_expense > 0 AND s.report_date BETWEEN $1 AND $2`,
+			args: []interface{}{constants.SETTLEMENT_STATUS_REJECTED},
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_EXPENSE, keyPrefix: "driver_expense", numberPrefix: "РВ",
+			query: `
+                SELECT s.id, s.report_date, e.amount, s.driver_user_id, ` + accountingUserName + `,
+                       'Прочие расходы водителя', 0, 'Отчет водителя #' || s.id || COALESCE(': ' || NULLIF(e.descriptions, ''), '')
+                FROM driver_settlements s JOIN users u ON u.id = s.driver_user_id
+                CROSS JOIN LATERAL (
+                    SELECT COALESCE(SUM((x->>'amount')::float), 0) AS amount, string_agg(x->>'description', ', ') AS descriptions
+                    FROM jsonb_array_elements(COALESCE(s.other_expenses_json, '[]'::jsonb)) x
+                ) e
+                WHERE s.status <> $3 AND e.amount > 0 AND s.report_date BETWEEN $1 AND $2`,
+			args: []interface{}{constants.SETTLEMENT_STATUS_REJECTED},
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_EXPENSE, keyPrefix: "vehicle", numberPrefix: "ТО",
+			query: `
+                SELECT m.id, m.performed_on, m.cost, 0, '', m.kind, 0,
+                       v.plate || COALESCE(' - ' || NULLIF(m.description, ''), '')
+                FROM vehicle_maintenance m JOIN vehicles v ON v.id = m.vehicle_id
+                WHERE m.canceled_at IS NULL AND m.cost > 0 AND m.performed_on BETWEEN $1 AND $2`,
+			itemNames: constants.VehicleMaintenanceKindDisplayMap,
+		},
+		{
+			docType: constants.ACCOUNTING_DOC_EXPENSE, keyPrefix: "referral_payout", numberPrefix: "РБ",
+			query: `
+                SELECT p.id, p.payout_date::date, p.amount, p.user_id, ` + accountingUserName + `,
+                       'Реферальные бонусы', 0, 'Запрос на выплату #' || p.referral_payout_request_id
+                FROM payouts p JOIN users u ON u.id = p.user_id
+                WHERE p.reversed_at IS NULL AND p.referral_payout_request_id IS NOT NULL AND p.amount > 0
+                  AND p.payout_date::date BETWEEN $1 AND $2`,
+		},
+	}
+}
+
+// AccountingDocumentGUID - стабильный UUID документа по его ключу.
+func AccountingDocumentGUID(key string) string {
+	return uuid.NewSHA1(accountingGUIDNamespace, []byte(key)).String()
+}
+
+// GetAccountingExport собирает за период from..to (включительно) документы для 1С:
+// реализацию по выполненным заказам, поступления денег, выплаты зарплаты и прочие расходы.
+func GetAccountingExport(from, to time.Time) (models.AccountingExport, error) {
+	export := models.AccountingExport{From: from, To: to, ExportedAt: time.Now()}
+	for _, source := range accountingSources() {
+		args := append([]interface{}{from.Format("2006-01-02"), to.Format("2006-01-02")}, source.args...)
+		rows, err := DB.Query(source.query, args...)
+		if err != nil {
+			log.Printf("GetAccountingExport: ошибка выборки '%s': %v", source.keyPrefix, err)
+			return export, err
+		}
+		for rows.Next() {
+			var id int64
+			doc := models.AccountingDocument{Type: source.docType}
+			if err = rows.Scan(&id, &doc.Date, &doc.Amount, &doc.CounterpartyID, &doc.Counterparty, &doc.Item, &doc.OrderID, &doc.Comment); err != nil {
+				rows.Close()
+				log.Printf("GetAccountingExport: ошибка сканирования '%s': %v", source.keyPrefix, err)
+				return export, err
+			}
+			doc.Key = fmt.Sprintf("%s:%d", source.keyPrefix, id)
+			doc.GUID = AccountingDocumentGUID(doc.Key)
+			doc.Number = fmt.Sprintf("%s-%08d", source.numberPrefix, id)
+			if name, ok := source.itemNames[doc.Item]; ok {
+				doc.Item = name
+			}
+			export.Documents = append(export.Documents, doc)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			log.Printf("GetAccountingExport: ошибка после итерации '%s': %v", source.keyPrefix, err)
+			return export, err
+		}
+	}
+
+	sort.SliceStable(export.Documents, func(i, j int) bool {
+		if !export.Documents[i].Date.Equal(export.Documents[j].Date) {
+			return export.Documents[i].Date.Before(export.Documents[j].Date)
+		}
+		return export.Documents[i].Number < export.Documents[j].Number
+	})
+	log.Printf("GetAccountingExport: за %s - %s собрано документов: %d", from.Format("02.01.2006"), to.Format("02.01.2006"), len(export.Documents))
+	return export, nil
+}
@@ -0,0 +1,524 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrCashHandoverExceedsDebt - сдаваемая сумма больше, чем водитель должен сдать по своим отчетам.
+var ErrCashHandoverExceedsDebt = fmt.Errorf("сумма сдачи больше несданного остатка водителя")
+
+// openCashDueSQL - условие для отчета ds, по которому водитель еще не сдал все наличные.
+const openCashDueSQL = `ds.paid_to_owner_at IS NULL AND ds.status <> '` + constants.SETTLEMENT_STATUS_REJECTED + `'
+	AND ds.amount_to_cashier > ds.handed_over_amount`
+
+const cashHandoverColumns = `h.id, h.driver_user_id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')),
+	h.amount, h.expected_amount, h.handed_at, h.received_by_user_id, h.comment, h.ack_status, h.ack_at, h.ack_comment,
+	h.ack_reminded_at, h.canceled_at, h.created_at`
+
+func scanCashHandover(row rowScanner) (models.CashHandover, error) {
+	var h models.CashHandover
+	err := row.Scan(&h.ID, &h.DriverUserID, &h.DriverName, &h.Amount, &h.ExpectedAmount, &h.HandedAt, &h.ReceivedByUserID,
+		&h.Comment, &h.AckStatus, &h.AckAt, &h.AckComment, &h.AckRemindedAt, &h.CanceledAt, &h.CreatedAt)
+	return h, err
+}
+
+const cashDiscrepancyColumns = `d.id, d.driver_user_id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')),
+	d.handover_id, d.kind, d.amount, d.note, d.created_at, d.resolved_at, d.resolved_by_user_id, d.resolution_comment`
+
+func scanCashDiscrepancy(row rowScanner) (models.CashDiscrepancy, error) {
+	var d models.CashDiscrepancy
+	err := row.Scan(&d.ID, &d.DriverUserID, &d.DriverName, &d.HandoverID, &d.Kind, &d.Amount, &d.Note, &d.CreatedAt,
+		&d.ResolvedAt, &d.ResolvedByUserID, &d.ResolutionComment)
+	return d, err
+}
+
+// recordCashHandoverInTx записывает фактическую сдачу наличных и распределяет ее по несданным отчетам водителя
+// от старых к новым (или только по settlementIDs, если они указаны). Полностью покрытый отчет помечается
+// как сданный, сдача ждет подтверждения водителя. Если ожидаемая сумма указана и не совпадает со сданной, в журнал расхождений пишется недостача или излишек.
+func recordCashHandoverInTx(tx *sql.Tx, driverUserID int64, amount float64, expected sql.NullFloat64, receivedByUserID int64,
+	comment string, settlementIDs []int64) (models.CashHandover, error) {
+	amountKopecks := toKopecks(amount)
+	if amountKopecks <= 0 {
+		return models.CashHandover{}, fmt.Errorf("сумма сдачи должна быть больше нуля")
+	}
+
+	query := `SELECT ds.id, ds.amount_to_cashier - ds.handed_over_amount FROM driver_settlements ds
+	          WHERE ds.driver_user_id = $1 AND ` + openCashDueSQL
+	args := []interface{}{driverUserID}
+	if len(settlementIDs) > 0 {
+		query += ` AND ds.id = ANY($2)`
+		args = append(args, pq.Array(settlementIDs))
+	}
+	query += ` ORDER BY ds.report_date, ds.id FOR UPDATE`
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		log.Printf("recordCashHandoverInTx: ошибка получения несданных отчетов водителя %d: %v", driverUserID, err)
+		return models.CashHandover{}, err
+	}
+	type openDue struct {
+		settlementID int64
+		due          int64
+	}
+	var dues []openDue
+	var totalDue int64
+	for rows.Next() {
+		var d openDue
+		var due float64
+		if err := rows.Scan(&d.settlementID, &due); err != nil {
+			rows.Close()
+			return models.CashHandover{}, err
+		}
+		d.due = toKopecks(due)
+		totalDue += d.due
+		dues = append(dues, d)
+	}
+	rows.Close()
+	if amountKopecks > totalDue {
+		return models.CashHandover{}, fmt.Errorf("%w: сдается %.2f, несдано %.2f", ErrCashHandoverExceedsDebt, float64(amountKopecks)/100, float64(totalDue)/100)
+	}
+
+	h := models.CashHandover{
+		DriverUserID:     driverUserID,
+		Amount:           float64(amountKopecks) / 100,
+		ExpectedAmount:   expected,
+		ReceivedByUserID: sql.NullInt64{Int64: receivedByUserID, Valid: receivedByUserID != 0},
+		Comment:          sql.NullString{String: comment, Valid: comment != ""},
+		AckStatus:        constants.CASH_ACK_PENDING,
+	}
+	err = tx.QueryRow(`
+		INSERT INTO cash_handovers (driver_user_id, amount, expected_amount, received_by_user_id, comment, ack_status)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, handed_at, created_at`,
+		driverUserID, h.Amount, h.ExpectedAmount, h.ReceivedByUserID, h.Comment, h.AckStatus).Scan(&h.ID, &h.HandedAt, &h.CreatedAt)
+	if err != nil {
+		log.Printf("recordCashHandoverInTx: ошибка записи сдачи водителя %d: %v", driverUserID, err)
+		return models.CashHandover{}, err
+	}
+
+	remaining := amountKopecks
+	for _, d := range dues {
+		if remaining == 0 {
+			break
+		}
+		part := d.due
+		if part > remaining {
+			part = remaining
+		}
+		remaining -= part
+		fullyCovered := part == d.due
+		if _, err := tx.Exec(`INSERT INTO cash_handover_allocations (handover_id, settlement_id, amount) VALUES ($1, $2, $3)`,
+			h.ID, d.settlementID, float64(part)/100); err != nil {
+			log.Printf("recordCashHandoverInTx: ошибка зачета сдачи #%d в отчет #%d: %v", h.ID, d.settlementID, err)
+			return models.CashHandover{}, err
+		}
+		if _, err := tx.Exec(`
+			UPDATE driver_settlements
+			SET handed_over_amount = handed_over_amount + $1,
+			    paid_to_owner_at = CASE WHEN $2 THEN NOW() ELSE paid_to_owner_at END, updated_at = NOW()
+			WHERE id = $3`, float64(part)/100, fullyCovered, d.settlementID); err != nil {
+			log.Printf("recordCashHandoverInTx: ошибка обновления отчета #%d: %v", d.settlementID, err)
+			return models.CashHandover{}, err
+		}
+		if fullyCovered {
+			if err := CheckAndSettleOrdersForSettlement(tx, d.settlementID); err != nil {
+				return models.CashHandover{}, err
+			}
+		}
+		if err := syncSettlementLedgerInTx(tx, d.settlementID); err != nil {
+			return models.CashHandover{}, err
+		}
+		h.Allocations = append(h.Allocations, models.CashHandoverAllocation{SettlementID: d.settlementID, Amount: float64(part) / 100})
+	}
+
+	if expected.Valid {
+		diff := amountKopecks - toKopecks(expected.Float64)
+		if diff != 0 {
+			kind := constants.CASH_DISCREPANCY_OVERAGE
+			if diff < 0 {
+				kind = constants.CASH_DISCREPANCY_SHORTAGE
+				diff = -diff
+			}
+			note := fmt.Sprintf("Ожидалось %.2f, сдано %.2f", expected.Float64, h.Amount)
+			if _, err := tx.Exec(`INSERT INTO cash_discrepancies (driver_user_id, handover_id, kind, amount, note) VALUES ($1, $2, $3, $4, $5)`,
+				driverUserID, h.ID, kind, float64(diff)/100, note); err != nil {
+				log.Printf("recordCashHandoverInTx: ошибка записи расхождения по сдаче #%d: %v", h.ID, err)
+				return models.CashHandover{}, err
+			}
+		}
+	}
+
+	if err := syncCashHandoverLedgerInTx(tx, h.ID); err != nil {
+		return models.CashHandover{}, err
+	}
+	log.Printf("recordCashHandoverInTx: сдача #%d водителя %d на %.2f зачтена в отчеты: %d", h.ID, driverUserID, h.Amount, len(h.Allocations))
+	return h, nil
+}
+
+// RecordCashHandover записывает фактически сданную водителем сумму (см. recordCashHandoverInTx).
+// Сдача ждет подтверждения водителя.
+func RecordCashHandover(driverUserID int64, amount float64, expected sql.NullFloat64, receivedByUserID int64, comment string) (models.CashHandover, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("RecordCashHandover: ошибка начала транзакции: %v", err)
+		return models.CashHandover{}, err
+	}
+	defer tx.Rollback()
+
+	h, err := recordCashHandoverInTx(tx, driverUserID, amount, expected, receivedByUserID, comment, nil)
+	if err != nil {
+		return models.CashHandover{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("RecordCashHandover: ошибка коммита транзакции: %v", err)
+		return models.CashHandover{}, err
+	}
+	return h, nil
+}
+
+// revertSettlementOrdersInTx возвращает заказы отчета из CALCULATED в COMPLETED, когда отчет снова не рассчитан.
+func revertSettlementOrdersInTx(tx *sql.Tx, settlementID int64) {
+	var coveredOrderIDs pq.Int64Array
+	if err := tx.QueryRow("SELECT covered_order_ids FROM driver_settlements WHERE id = $1", settlementID).Scan(&coveredOrderIDs); err != nil {
+		log.Printf("revertSettlementOrdersInTx: Не удалось получить ID заказов для отчета #%d: %v", settlementID, err)
+		return
+	}
+	if len(coveredOrderIDs) == 0 {
+		return
+	}
+	_, err := tx.Exec(`UPDATE orders SET status = $1, updated_at = NOW() WHERE id = ANY($2::bigint[]) AND status = $3`,
+		constants.STATUS_COMPLETED, coveredOrderIDs, constants.STATUS_CALCULATED)
+	if err != nil {
+		log.Printf("revertSettlementOrdersInTx: Ошибка при попытке вернуть заказы %v из CALCULATED в COMPLETED для отчета #%d: %v", coveredOrderIDs, settlementID, err)
+	}
+}
+
+// cancelCashHandoverInTx отменяет ошибочную сдачу: снимает зачеты с отчетов, сторнирует проводку
+// и закрывает открытые расхождения по ней. Повторная отмена ничего не делает.
+func cancelCashHandoverInTx(tx *sql.Tx, handoverID int64, canceledByUserID int64) error {
+	var canceledAt sql.NullTime
+	var handedAt time.Time
+	if err := tx.QueryRow(`SELECT canceled_at, handed_at FROM cash_handovers WHERE id = $1 FOR UPDATE`, handoverID).Scan(&canceledAt, &handedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("сдача #%d не найдена", handoverID)
+		}
+		log.Printf("cancelCashHandoverInTx: ошибка получения сдачи #%d: %v", handoverID, err)
+		return err
+	}
+	if canceledAt.Valid {
+		log.Printf("cancelCashHandoverInTx: сдача #%d уже отменена.", handoverID)
+		return nil
+	}
+	// Отмена сторнирует проводку сдачи и снимает отметки со всех отчетов - их месяцы должны быть открыты
+	if err := ensurePeriodsOpen(tx, handedAt); err != nil {
+		return err
+	}
+
+	allocations, err := getCashHandoverAllocations(tx, handoverID)
+	if err != nil {
+		return err
+	}
+	for _, a := range allocations {
+		if err := ensureSettlementPeriodOpen(tx, a.SettlementID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`
+			UPDATE driver_settlements SET handed_over_amount = GREATEST(handed_over_amount - $1, 0), paid_to_owner_at = NULL, updated_at = NOW()
+			WHERE id = $2`, a.Amount, a.SettlementID); err != nil {
+			log.Printf("cancelCashHandoverInTx: ошибка снятия зачета сдачи #%d с отчета #%d: %v", handoverID, a.SettlementID, err)
+			return err
+		}
+		revertSettlementOrdersInTx(tx, a.SettlementID)
+		if err := syncSettlementLedgerInTx(tx, a.SettlementID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE cash_handovers SET canceled_at = NOW() WHERE id = $1`, handoverID); err != nil {
+		log.Printf("cancelCashHandoverInTx: ошибка отмены сдачи #%d: %v", handoverID, err)
+		return err
+	}
+	if _, err := tx.Exec(`
+		UPDATE cash_discrepancies SET resolved_at = NOW(), resolved_by_user_id = $2, resolution_comment = 'Сдача отменена'
+		WHERE handover_id = $1 AND resolved_at IS NULL`, handoverID, sql.NullInt64{Int64: canceledByUserID, Valid: canceledByUserID != 0}); err != nil {
+		log.Printf("cancelCashHandoverInTx: ошибка закрытия расхождений по сдаче #%d: %v", handoverID, err)
+		return err
+	}
+	return syncCashHandoverLedgerInTx(tx, handoverID)
+}
+
+// CancelCashHandover отменяет ошибочно записанную сдачу наличных.
+func CancelCashHandover(handoverID int64, canceledByUserID int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CancelCashHandover: ошибка начала транзакции: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = cancelCashHandoverInTx(tx, handoverID, canceledByUserID); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CancelCashHandover: ошибка коммита транзакции: %v", err)
+		return err
+	}
+	log.Printf("CancelCashHandover: сдача #%d отменена пользователем %d.", handoverID, canceledByUserID)
+	return nil
+}
+
+// cashQuerier - *sql.DB или *sql.Tx.
+type cashQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func getCashHandoverAllocations(q cashQuerier, handoverID int64) ([]models.CashHandoverAllocation, error) {
+	rows, err := q.Query(`SELECT settlement_id, amount FROM cash_handover_allocations WHERE handover_id = $1 ORDER BY settlement_id`, handoverID)
+	if err != nil {
+		log.Printf("getCashHandoverAllocations: ошибка получения зачетов сдачи #%d: %v", handoverID, err)
+		return nil, err
+	}
+	defer rows.Close()
+	var allocations []models.CashHandoverAllocation
+	for rows.Next() {
+		var a models.CashHandoverAllocation
+		if err := rows.Scan(&a.SettlementID, &a.Amount); err != nil {
+			return nil, err
+		}
+		allocations = append(allocations, a)
+	}
+	return allocations, rows.Err()
+}
+
+// GetCashHandoverByID возвращает сдачу наличных вместе с зачетами по отчетам.
+func GetCashHandoverByID(handoverID int64) (models.CashHandover, error) {
+	h, err := scanCashHandover(DB.QueryRow(`SELECT `+cashHandoverColumns+`
+		FROM cash_handovers h JOIN users u ON u.id = h.driver_user_id WHERE h.id = $1`, handoverID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return h, fmt.Errorf("сдача #%d не найдена", handoverID)
+		}
+		log.Printf("GetCashHandoverByID: ошибка получения сдачи #%d: %v", handoverID, err)
+		return h, err
+	}
+	h.Allocations, err = getCashHandoverAllocations(DB, handoverID)
+	return h, err
+}
+
+// GetCashHandovers возвращает сдачи наличных водителя (driverUserID = 0 - всех водителей), новые первыми.
+// Отмененные сдачи включаются, чтобы история была полной.
+func GetCashHandovers(driverUserID int64, limit, offset int) ([]models.CashHandover, int, error) {
+	where := ""
+	args := []interface{}{}
+	if driverUserID != 0 {
+		where = "WHERE h.driver_user_id = $1"
+		args = append(args, driverUserID)
+	}
+	var total int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM cash_handovers h `+where, args...).Scan(&total); err != nil {
+		log.Printf("GetCashHandovers: ошибка подсчета сдач водителя %d: %v", driverUserID, err)
+		return nil, 0, err
+	}
+	query := fmt.Sprintf(`SELECT %s FROM cash_handovers h JOIN users u ON u.id = h.driver_user_id %s
+		ORDER BY h.handed_at DESC, h.id DESC LIMIT $%d OFFSET $%d`, cashHandoverColumns, where, len(args)+1, len(args)+2)
+	rows, err := DB.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		log.Printf("GetCashHandovers: ошибка получения сдач водителя %d: %v", driverUserID, err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var handovers []models.CashHandover
+	for rows.Next() {
+		h, err := scanCashHandover(rows)
+		if err != nil {
+			log.Printf("GetCashHandovers: ошибка сканирования сдачи: %v", err)
+			return nil, 0, err
+		}
+		handovers = append(handovers, h)
+	}
+	return handovers, total, rows.Err()
+}
+
+// GetDriverCashBalance возвращает несданный остаток водителя с разбивкой по возрасту долга,
+// число неподтвержденных сдач и открытых расхождений.
+func GetDriverCashBalance(driverUserID int64) (models.DriverCashBalance, error) {
+	balance := models.DriverCashBalance{DriverUserID: driverUserID}
+	err := DB.QueryRow(`
+		SELECT COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount), 0),
+		       COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount) FILTER (WHERE CURRENT_DATE - ds.report_date <= $2), 0),
+		       COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount) FILTER (WHERE CURRENT_DATE - ds.report_date > $2 AND CURRENT_DATE - ds.report_date <= $3), 0),
+		       COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount) FILTER (WHERE CURRENT_DATE - ds.report_date > $3), 0),
+		       MIN(ds.report_date)::timestamptz, COUNT(*)
+		FROM driver_settlements ds
+		WHERE ds.driver_user_id = $1 AND `+openCashDueSQL,
+		driverUserID, constants.CASH_DEBT_AGE_FRESH_DAYS, constants.CASH_DEBT_AGE_LATE_DAYS).Scan(
+		&balance.Outstanding, &balance.Debt0To7Days, &balance.Debt8To30Days, &balance.DebtOver30Days,
+		&balance.OldestDebtDate, &balance.OpenReports)
+	if err != nil {
+		log.Printf("GetDriverCashBalance: ошибка расчета остатка водителя %d: %v", driverUserID, err)
+		return balance, err
+	}
+	err = DB.QueryRow(`SELECT COUNT(*) FROM cash_handovers WHERE driver_user_id = $1 AND ack_status = $2 AND canceled_at IS NULL`,
+		driverUserID, constants.CASH_ACK_PENDING).Scan(&balance.PendingAcks)
+	if err != nil {
+		log.Printf("GetDriverCashBalance: ошибка подсчета неподтвержденных сдач водителя %d: %v", driverUserID, err)
+		return balance, err
+	}
+	err = DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(CASE kind WHEN $2 THEN amount WHEN $3 THEN -amount ELSE 0 END), 0)
+		FROM cash_discrepancies WHERE driver_user_id = $1 AND resolved_at IS NULL`,
+		driverUserID, constants.CASH_DISCREPANCY_SHORTAGE, constants.CASH_DISCREPANCY_OVERAGE).Scan(
+		&balance.OpenDiscrepancies, &balance.OpenDiscrepancyDiff)
+	if err != nil {
+		log.Printf("GetDriverCashBalance: ошибка подсчета расхождений водителя %d: %v", driverUserID, err)
+		return balance, err
+	}
+	return balance, nil
+}
+
+// AcknowledgeCashHandover сохраняет ответ водителя на запрос подтверждения сдачи.
+// Несогласие записывается в журнал расхождений со спорной суммой и комментарием водителя.
+func AcknowledgeCashHandover(handoverID, driverUserID int64, confirmed bool, comment string) (models.CashHandover, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("AcknowledgeCashHandover: ошибка начала транзакции: %v", err)
+		return models.CashHandover{}, err
+	}
+	defer tx.Rollback()
+
+	var ownerID int64
+	var amount float64
+	var ackStatus string
+	var canceledAt sql.NullTime
+	err = tx.QueryRow(`SELECT driver_user_id, amount, ack_status, canceled_at FROM cash_handovers WHERE id = $1 FOR UPDATE`, handoverID).Scan(
+		&ownerID, &amount, &ackStatus, &canceledAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.CashHandover{}, fmt.Errorf("сдача #%d не найдена", handoverID)
+		}
+		log.Printf("AcknowledgeCashHandover: ошибка получения сдачи #%d: %v", handoverID, err)
+		return models.CashHandover{}, err
+	}
+	if ownerID != driverUserID {
+		return models.CashHandover{}, fmt.Errorf("сдача #%d принадлежит другому водителю", handoverID)
+	}
+	if canceledAt.Valid {
+		return models.CashHandover{}, fmt.Errorf("сдача #%d отменена", handoverID)
+	}
+	if ackStatus == constants.CASH_ACK_CONFIRMED || ackStatus == constants.CASH_ACK_DISPUTED {
+		return models.CashHandover{}, fmt.Errorf("ответ по сдаче #%d уже получен", handoverID)
+	}
+
+	newStatus := constants.CASH_ACK_CONFIRMED
+	if !confirmed {
+		newStatus = constants.CASH_ACK_DISPUTED
+	}
+	comment = strings.TrimSpace(comment)
+	if _, err = tx.Exec(`UPDATE cash_handovers SET ack_status = $1, ack_at = NOW(), ack_comment = $2 WHERE id = $3`,
+		newStatus, sql.NullString{String: comment, Valid: comment != ""}, handoverID); err != nil {
+		log.Printf("AcknowledgeCashHandover: ошибка сохранения ответа по сдаче #%d: %v", handoverID, err)
+		return models.CashHandover{}, err
+	}
+	if !confirmed {
+		if _, err = tx.Exec(`INSERT INTO cash_discrepancies (driver_user_id, handover_id, kind, amount, note) VALUES ($1, $2, $3, $4, $5)`,
+			driverUserID, handoverID, constants.CASH_DISCREPANCY_DISPUTE, amount, sql.NullString{String: comment, Valid: comment != ""}); err != nil {
+			log.Printf("AcknowledgeCashHandover: ошибка записи спора по сдаче #%d: %v", handoverID, err)
+			return models.CashHandover{}, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("AcknowledgeCashHandover: ошибка коммита транзакции: %v", err)
+		return models.CashHandover{}, err
+	}
+	log.Printf("AcknowledgeCashHandover: водитель %d ответил по сдаче #%d: %s", driverUserID, handoverID, newStatus)
+	return GetCashHandoverByID(handoverID)
+}
+
+// GetCashDiscrepancies возвращает журнал расхождений; onlyOpen - только незакрытые. Новые первыми.
+func GetCashDiscrepancies(onlyOpen bool, limit, offset int) ([]models.CashDiscrepancy, int, error) {
+	where := ""
+	if onlyOpen {
+		where = "WHERE d.resolved_at IS NULL"
+	}
+	var total int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM cash_discrepancies d ` + where).Scan(&total); err != nil {
+		log.Printf("GetCashDiscrepancies: ошибка подсчета расхождений: %v", err)
+		return nil, 0, err
+	}
+	rows, err := DB.Query(`SELECT `+cashDiscrepancyColumns+` FROM cash_discrepancies d JOIN users u ON u.id = d.driver_user_id `+
+		where+` ORDER BY d.created_at DESC, d.id DESC LIMIT $1 OFFSET $2`, limit, offset)
+	if err != nil {
+		log.Printf("GetCashDiscrepancies: ошибка получения расхождений: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var discrepancies []models.CashDiscrepancy
+	for rows.Next() {
+		d, err := scanCashDiscrepancy(rows)
+		if err != nil {
+			log.Printf("GetCashDiscrepancies: ошибка сканирования расхождения: %v", err)
+			return nil, 0, err
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, total, rows.Err()
+}
+
+// ResolveCashDiscrepancy закрывает расхождение с комментарием (например, "водитель доплатил", "списано").
+func ResolveCashDiscrepancy(discrepancyID, resolvedByUserID int64, comment string) (models.CashDiscrepancy, error) {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return models.CashDiscrepancy{}, fmt.Errorf("укажите, как закрыто расхождение")
+	}
+	result, err := DB.Exec(`
+		UPDATE cash_discrepancies SET resolved_at = NOW(), resolved_by_user_id = $2, resolution_comment = $3
+		WHERE id = $1 AND resolved_at IS NULL`, discrepancyID, resolvedByUserID, comment)
+	if err != nil {
+		log.Printf("ResolveCashDiscrepancy: ошибка закрытия расхождения #%d: %v", discrepancyID, err)
+		return models.CashDiscrepancy{}, err
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return models.CashDiscrepancy{}, fmt.Errorf("расхождение #%d не найдено или уже закрыто", discrepancyID)
+	}
+	log.Printf("ResolveCashDiscrepancy: расхождение #%d закрыто пользователем %d.", discrepancyID, resolvedByUserID)
+	return scanCashDiscrepancy(DB.QueryRow(`SELECT `+cashDiscrepancyColumns+`
+		FROM cash_discrepancies d JOIN users u ON u.id = d.driver_user_id WHERE d.id = $1`, discrepancyID))
+}
+
+// GetCashHandoversAwaitingAck возвращает сдачи, которые водитель не подтвердил дольше olderThan
+// и о которых еще не напоминали.
+func GetCashHandoversAwaitingAck(olderThan time.Duration) ([]models.CashHandover, error) {
+	rows, err := DB.Query(`SELECT `+cashHandoverColumns+`
+		FROM cash_handovers h JOIN users u ON u.id = h.driver_user_id
+		WHERE h.ack_status = $1 AND h.canceled_at IS NULL AND h.ack_reminded_at IS NULL AND h.created_at < $2
+		ORDER BY h.id`, constants.CASH_ACK_PENDING, time.Now().Add(-olderThan))
+	if err != nil {
+		log.Printf("GetCashHandoversAwaitingAck: ошибка получения неподтвержденных сдач: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+	var handovers []models.CashHandover
+	for rows.Next() {
+		h, err := scanCashHandover(rows)
+		if err != nil {
+			return nil, err
+		}
+		handovers = append(handovers, h)
+	}
+	return handovers, rows.Err()
+}
+
+// MarkCashHandoverAckReminded отмечает, что водителю напомнили о подтверждении сдачи.
+func MarkCashHandoverAckReminded(handoverID int64) error {
+	_, err := DB.Exec(`UPDATE cash_handovers SET ack_reminded_at = NOW() WHERE id = $1`, handoverID)
+	if err != nil {
+		log.Printf("MarkCashHandoverAckReminded: ошибка отметки напоминания по сдаче #%d: %v", handoverID, err)
+	}
+	return err
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const compensationRuleColumns = `cr.id, cr.driver_user_id,
+        TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')) AS driver_name,
+        cr.category, cr.share, cr.effective_from, cr.effective_to, cr.comment, cr.is_active,
+        cr.created_by_user_id, cr.created_at`
+
+func scanCompensationRule(row rowScanner) (models.CompensationRule, error) {
+	var r models.CompensationRule
+	var driverName sql.NullString
+	err := row.Scan(&r.ID, &r.DriverUserID, &driverName, &r.Category, &r.Share, &r.EffectiveFrom, &r.EffectiveTo,
+		&r.Comment, &r.IsActive, &r.CreatedByUserID, &r.CreatedAt)
+	r.DriverName = driverName.String
+	return r, err
+}
+
+// CreateCompensationRule добавляет правило доли водителя.
+func CreateCompensationRule(rule models.CompensationRule) (int64, error) {
+	if rule.Share < 0 || rule.Share > 1 {
+		return 0, fmt.Errorf("доля водителя должна быть от 0 до 1, получено %.4f", rule.Share)
+	}
+	if rule.EffectiveFrom.IsZero() {
+		return 0, fmt.Errorf("не указана дата начала действия правила")
+	}
+	if rule.EffectiveTo.Valid && rule.EffectiveTo.Time.Before(rule.EffectiveFrom) {
+		return 0, fmt.Errorf("дата окончания правила раньше даты начала")
+	}
+
+	var id int64
+	err := DB.QueryRow(`
+        INSERT INTO compensation_rules (driver_user_id, category, share, effective_from, effective_to, comment, created_by_user_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`,
+		rule.DriverUserID, rule.Category, rule.Share, rule.EffectiveFrom, rule.EffectiveTo, rule.Comment, rule.CreatedByUserID,
+	).Scan(&id)
+	if err != nil {
+		log.Printf("CreateCompensationRule: ошибка добавления правила: %v", err)
+		return 0, err
+	}
+	log.Printf("CreateCompensationRule: добавлено правило #%d (водитель %v, категория %v, доля %.4f)", id, rule.DriverUserID, rule.Category, rule.Share)
+	return id, nil
+}
+
+// GetCompensationRules возвращает правила долей водителей; при activeOnly - только действующие записи.
+func GetCompensationRules(activeOnly bool) ([]models.CompensationRule, error) {
+	query := `SELECT ` + compensationRuleColumns + `
+        FROM compensation_rules cr
+        LEFT JOIN users u ON u.id = cr.driver_user_id`
+	if activeOnly {
+		query += ` WHERE cr.is_active = TRUE`
+	}
+	query += ` ORDER BY cr.driver_user_id NULLS FIRST, cr.category NULLS FIRST, cr.effective_from DESC, cr.id DESC`
+
+	rows, err := DB.Query(query)
+	if err != nil {
+		log.Printf("GetCompensationRules: ошибка получения правил: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.CompensationRule
+	for rows.Next() {
+		r, errScan := scanCompensationRule(rows)
+		if errScan != nil {
+			log.Printf("GetCompensationRules: ошибка сканирования правила: %v", errScan)
+			return nil, errScan
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// GetCompensationRulesByIDs возвращает правила по списку ID (для расшифровки ставки отчета).
+func GetCompensationRulesByIDs(ids []int64) ([]models.CompensationRule, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	rows, err := DB.Query(`SELECT `+compensationRuleColumns+`
+        FROM compensation_rules cr
+        LEFT JOIN users u ON u.id = cr.driver_user_id
+        WHERE cr.id = ANY($1)
+        ORDER BY cr.id`, pq.Array(ids))
+	if err != nil {
+		log.Printf("GetCompensationRulesByIDs: ошибка получения правил %v: %v", ids, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.CompensationRule
+	for rows.Next() {
+		r, errScan := scanCompensationRule(rows)
+		if errScan != nil {
+			return nil, errScan
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// DeactivateCompensationRule отключает правило. Запись не удаляется:
+// на нее могут ссылаться уже сохраненные отчеты.
+func DeactivateCompensationRule(ruleID int64) error {
+	result, err := DB.Exec(`UPDATE compensation_rules SET is_active = FALSE WHERE id = $1 AND is_active = TRUE`, ruleID)
+	if err != nil {
+		log.Printf("DeactivateCompensationRule: ошибка отключения правила #%d: %v", ruleID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("действующее правило #%d не найдено", ruleID)
+	}
+	log.Printf("DeactivateCompensationRule: правило #%d отключено", ruleID)
+	return nil
+}
+
+// compensationRuleMatches проверяет, применимо ли правило к водителю, категории и дате.
+// Возвращает специфичность: 3 - водитель и категория, 2 - водитель, 1 - категория, 0 - общее правило.
+func compensationRuleMatches(rule models.CompensationRule, driverUserID int64, category string, date time.Time) (int, bool) {
+	if rule.DriverUserID.Valid && rule.DriverUserID.Int64 != driverUserID {
+		return 0, false
+	}
+	if rule.Category.Valid && rule.Category.String != category {
+		return 0, false
+	}
+	if !ruleInEffect(rule.EffectiveFrom, rule.EffectiveTo, date) {
+		return 0, false
+	}
+	return ruleSpecificity(rule.DriverUserID.Valid, rule.Category.Valid), true
+}
+
+// ruleInEffect проверяет, что дата попадает в период действия правила (по календарным дням, границы включительно).
+func ruleInEffect(from time.Time, to sql.NullTime, date time.Time) bool {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	if day.Before(time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)) {
+		return false
+	}
+	if to.Valid && day.After(time.Date(to.Time.Year(), to.Time.Month(), to.Time.Day(), 0, 0, 0, 0, time.UTC)) {
+		return false
+	}
+	return true
+}
+
+// ruleSpecificity - вес правила при выборе: правило для сотрудника важнее правила для категории.
+func ruleSpecificity(hasUser, hasCategory bool) int {
+	specificity := 0
+	if hasUser {
+		specificity += 2
+	}
+	if hasCategory {
+		specificity++
+	}
+	return specificity
+}
+
+// pickCompensationRule выбирает самое специфичное правило; при равной специфичности - самое новое по дате начала.
+func pickCompensationRule(rules []models.CompensationRule, driverUserID int64, category string, date time.Time) (models.CompensationRule, bool) {
+	var best models.CompensationRule
+	bestSpecificity := -1
+	for _, rule := range rules {
+		specificity, ok := compensationRuleMatches(rule, driverUserID, category, date)
+		if !ok {
+			continue
+		}
+		if specificity > bestSpecificity ||
+			(specificity == bestSpecificity && (rule.EffectiveFrom.After(best.EffectiveFrom) ||
+				(rule.EffectiveFrom.Equal(best.EffectiveFrom) && rule.ID > best.ID))) {
+			best = rule
+			bestSpecificity = specificity
+		}
+	}
+	return best, bestSpecificity >= 0
+}
+
+// ResolveDriverShare определяет долю водителя для набора заказов.
+// Для каждого заказа подбирается правило по водителю, категории и дате заказа
+// (водитель+категория > водитель > категория > общее), итоговая ставка взвешивается по стоимости заказов.
+// Если правил нет, используется defaultShare из конфигурации.
+func ResolveDriverShare(driverUserID int64, orders []models.Order, reportDate time.Time, defaultShare float64) (models.DriverShareResolution, error) {
+	result := models.DriverShareResolution{Rate: defaultShare}
+
+	rows, err := DB.Query(`SELECT `+compensationRuleColumns+`
+        FROM compensation_rules cr
+        LEFT JOIN users u ON u.id = cr.driver_user_id
+        WHERE cr.is_active = TRUE AND (cr.driver_user_id IS NULL OR cr.driver_user_id = $1)`, driverUserID)
+	if err != nil {
+		log.Printf("ResolveDriverShare: ошибка получения правил для водителя %d: %v", driverUserID, err)
+		return result, err
+	}
+	defer rows.Close()
+	var rules []models.CompensationRule
+	for rows.Next() {
+		r, errScan := scanCompensationRule(rows)
+		if errScan != nil {
+			log.Printf("ResolveDriverShare: ошибка сканирования правила: %v", errScan)
+			return result, errScan
+		}
+		rules = append(rules, r)
+	}
+	if err = rows.Err(); err != nil {
+		return result, err
+	}
+	if len(rules) == 0 {
+		return result, nil
+	}
+
+	usedRules := make(map[int64]bool)
+	var weighted, totalWeight float64
+	for _, order := range orders {
+		orderDate := reportDate
+		if order.Date != "" {
+			if parsed, errParse := time.Parse("2006-01-02", order.Date); errParse == nil {
+				orderDate = parsed
+			}
+		}
+		share := defaultShare
+		if rule, ok := pickCompensationRule(rules, driverUserID, order.Category, orderDate); ok {
+			share = rule.Share
+			if !usedRules[rule.ID] {
+				usedRules[rule.ID] = true
+				result.RuleIDs = append(result.RuleIDs, rule.ID)
+			}
+		}
+		weight := 0.0
+		if order.Cost.Valid && order.Cost.Float64 > 0 {
+			weight = order.Cost.Float64
+		}
+		weighted += share * weight
+		totalWeight += weight
+	}
+
+	if totalWeight > 0 {
+		result.Rate = weighted / totalWeight
+	} else if rule, ok := pickCompensationRule(rules, driverUserID, "", reportDate); ok {
+		// Выручки нет - берем правило водителя на дату отчета без учета категории.
+		result.Rate = rule.Share
+		if !usedRules[rule.ID] {
+			result.RuleIDs = append(result.RuleIDs, rule.ID)
+		}
+	}
+	return result, nil
+}
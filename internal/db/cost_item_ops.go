@@ -0,0 +1,65 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"Original/internal/models"
+)
+
+// ReplaceOrderCostItems заменяет расшифровку стоимости заказа новым списком статей.
+// Пустой список удаляет расшифровку: чек будет сформирован одной позицией на всю сумму.
+func ReplaceOrderCostItems(orderID int64, items []models.OrderCostItem) (err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ReplaceOrderCostItems: ошибка начала транзакции для заказа #%d: %v", orderID, err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if err = ensureOrderPeriodOpen(tx, orderID); err != nil {
+		return err
+	}
+	if _, err = tx.Exec("DELETE FROM order_cost_items WHERE order_id = $1", orderID); err != nil {
+		log.Printf("ReplaceOrderCostItems: ошибка удаления статей заказа #%d: %v", orderID, err)
+		return err
+	}
+	for i, item := range items {
+		_, err = tx.Exec(`INSERT INTO order_cost_items (order_id, kind, description, amount, position)
+            VALUES ($1, $2, $3, $4, $5)`, orderID, item.Kind, item.Description, item.Amount, i)
+		if err != nil {
+			log.Printf("ReplaceOrderCostItems: ошибка добавления статьи '%s' заказа #%d: %v", item.Kind, orderID, err)
+			return fmt.Errorf("ошибка сохранения расшифровки стоимости: %w", err)
+		}
+	}
+	log.Printf("Расшифровка стоимости заказа #%d сохранена: %d статей", orderID, len(items))
+	return nil
+}
+
+// GetOrderCostItems возвращает расшифровку стоимости заказа в порядке ввода.
+func GetOrderCostItems(orderID int64) ([]models.OrderCostItem, error) {
+	rows, err := DB.Query(`SELECT id, order_id, kind, description, amount, position, created_at
+        FROM order_cost_items WHERE order_id = $1 ORDER BY position, id`, orderID)
+	if err != nil {
+		log.Printf("GetOrderCostItems: ошибка запроса статей заказа #%d: %v", orderID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.OrderCostItem
+	for rows.Next() {
+		var item models.OrderCostItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.Kind, &item.Description, &item.Amount, &item.Position, &item.CreatedAt); err != nil {
+			log.Printf("GetOrderCostItems: ошибка сканирования статьи заказа #%d: %v", orderID, err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
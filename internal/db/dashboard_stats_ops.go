@@ -0,0 +1,97 @@
+package db
+
+import (
+	"log"
+
+	"Original/internal/constants"
+	"Original/internal/models"
+)
+
+// dashboardTopClientsLimit - сколько клиентов показывать в топе по количеству заказов.
+const dashboardTopClientsLimit = 5
+
+// GetDashboardStats считает сводные показатели по всем заказам: итоги, разбивку по категориям
+// и месяцам и топ клиентов. Выручка учитывает выполненные заказы с указанной стоимостью.
+func GetDashboardStats() (models.DashboardStats, error) {
+	stats := models.DashboardStats{
+		OrdersByType:   make(map[string]int),
+		MonthlyRevenue: make(map[string]float64),
+	}
+	doneStatuses := []interface{}{constants.STATUS_COMPLETED, constants.STATUS_CALCULATED, constants.STATUS_SETTLED}
+
+	err := DB.QueryRow(`
+        SELECT COUNT(*),
+               COUNT(*) FILTER (WHERE status IN ($1, $2, $3) AND cost IS NOT NULL),
+               COALESCE(SUM(cost) FILTER (WHERE status IN ($1, $2, $3)), 0),
+               (SELECT COUNT(*) FROM users WHERE role = $4)
+        FROM orders`, append(doneStatuses, constants.ROLE_USER)...).Scan(
+		&stats.TotalOrders, &stats.CompletedOrders, &stats.TotalRevenue, &stats.TotalClients)
+	if err != nil {
+		log.Printf("GetDashboardStats: ошибка расчета итогов: %v", err)
+		return stats, err
+	}
+
+	rows, err := DB.Query(`SELECT COALESCE(NULLIF(category, ''), 'unknown'), COUNT(*) FROM orders GROUP BY 1`)
+	if err != nil {
+		log.Printf("GetDashboardStats: ошибка расчета заказов по категориям: %v", err)
+		return stats, err
+	}
+	for rows.Next() {
+		var category string
+		var count int
+		if err := rows.Scan(&category, &count); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.OrdersByType[category] = count
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	rows, err = DB.Query(`
+        SELECT TO_CHAR(created_at, 'YYYY-MM'), COALESCE(SUM(cost), 0)
+        FROM orders
+        WHERE created_at IS NOT NULL AND status IN ($1, $2, $3)
+        GROUP BY 1`, doneStatuses...)
+	if err != nil {
+		log.Printf("GetDashboardStats: ошибка расчета выручки по месяцам: %v", err)
+		return stats, err
+	}
+	for rows.Next() {
+		var month string
+		var revenue float64
+		if err := rows.Scan(&month, &revenue); err != nil {
+			rows.Close()
+			return stats, err
+		}
+		stats.MonthlyRevenue[month] = revenue
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	rows, err = DB.Query(`
+        SELECT TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')), COUNT(o.id),
+               COALESCE(SUM(o.cost) FILTER (WHERE o.status IN ($1, $2, $3)), 0)
+        FROM orders o JOIN users u ON u.id = o.user_id
+        WHERE u.role = $4
+        GROUP BY u.id, u.first_name, u.last_name
+        ORDER BY COUNT(o.id) DESC, u.id
+        LIMIT $5`, append(doneStatuses, constants.ROLE_USER, dashboardTopClientsLimit)...)
+	if err != nil {
+		log.Printf("GetDashboardStats: ошибка расчета топа клиентов: %v", err)
+		return stats, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var client models.TopClientStats
+		if err := rows.Scan(&client.Name, &client.Orders, &client.Revenue); err != nil {
+			return stats, err
+		}
+		stats.TopClients = append(stats.TopClients, client)
+	}
+	return stats, rows.Err()
+}
@@ -211,8 +211,9 @@ func InitDB() error {
             driver_salary_paid_at TIMESTAMP WITH TIME ZONE NULL,
             -- НАЧАЛО ИЗМЕНЕНИЯ --
             status TEXT DEFAULT 'pending' NOT NULL, -- pending, approved, rejected
-            admin_comment TEXT
+            admin_comment TEXT,
             -- КОНЕЦ ИЗМЕНЕНИЯ --
+            online_paid_revenue FLOAT NOT NULL DEFAULT 0
         );
         CREATE TABLE IF NOT EXISTS owner_cashier_records (
             id SERIAL PRIMARY KEY,
@@ -223,6 +224,312 @@ func InitDB() error {
             last_updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
             UNIQUE (driver_user_id, report_date)
         );
+        CREATE TABLE IF NOT EXISTS payments (
+            id SERIAL PRIMARY KEY,
+            order_id INTEGER REFERENCES orders(id) NOT NULL,
+            provider TEXT NOT NULL,
+            external_id TEXT,
+            amount FLOAT NOT NULL,
+            currency TEXT NOT NULL DEFAULT 'RUB',
+            status TEXT NOT NULL,
+            refunded_amount FLOAT NOT NULL DEFAULT 0,
+            idempotence_key TEXT NOT NULL UNIQUE,
+            confirmation_url TEXT,
+            request_payload JSONB,
+            response_payload JSONB,
+            last_webhook_payload JSONB,
+            paid_at TIMESTAMP WITH TIME ZONE NULL,
+            created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+            updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+            UNIQUE (provider, external_id)
+        );
+        CREATE TABLE IF NOT EXISTS refunds (
+            id SERIAL PRIMARY KEY,
+            payment_id INTEGER REFERENCES payments(id) NOT NULL,
+            order_id INTEGER REFERENCES orders(id) NOT NULL,
+            external_id TEXT UNIQUE,
+            amount FLOAT NOT NULL,
+            status TEXT NOT NULL,
+            reason TEXT,
+            idempotence_key TEXT NOT NULL UNIQUE,
+            requested_by_user_id INTEGER REFERENCES users(id),
+            request_payload JSONB,
+            response_payload JSONB,
+            created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW(),
+            updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+        );
+        CREATE TABLE IF NOT EXISTS order_cost_items (
+            id SERIAL PRIMARY KEY,
+            order_id INTEGER REFERENCES orders(id) ON DELETE CASCADE NOT NULL,
+            kind TEXT NOT NULL,
+            description TEXT NOT NULL,
+            amount FLOAT NOT NULL,
+            position INTEGER NOT NULL DEFAULT 0,
+            created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT NOW()
+        );
+        CREATE INDEX IF NOT EXISTS idx_order_cost_items_order_id ON order_cost_items(order_id);
+        CREATE TABLE IF NOT EXISTS ledger_accounts (
+            id SERIAL PRIMARY KEY,
+            code TEXT NOT NULL UNIQUE,
+            type TEXT NOT NULL,
+            user_id INTEGER REFERENCES users(id),
+            name TEXT NOT NULL,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE TABLE IF NOT EXISTS ledger_journal (
+            id SERIAL PRIMARY KEY,
+            kind TEXT NOT NULL,
+            source_type TEXT NOT NULL,
+            source_id BIGINT NOT NULL,
+            description TEXT,
+            posted_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+            reversal_of_id INTEGER REFERENCES ledger_journal(id),
+            reversed_by_id INTEGER REFERENCES ledger_journal(id)
+        );
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_journal_active_source ON ledger_journal(source_type, source_id, kind)
+            WHERE reversal_of_id IS NULL AND reversed_by_id IS NULL;
+        CREATE TABLE IF NOT EXISTS ledger_postings (
+            id SERIAL PRIMARY KEY,
+            journal_id INTEGER REFERENCES ledger_journal(id) ON DELETE CASCADE NOT NULL,
+            account_id INTEGER REFERENCES ledger_accounts(id) NOT NULL,
+            amount NUMERIC(14,2) NOT NULL
+        );
+        CREATE TABLE IF NOT EXISTS compensation_rules (
+            id SERIAL PRIMARY KEY,
+            driver_user_id INTEGER REFERENCES users(id),
+            category TEXT,
+            share FLOAT NOT NULL CHECK (share >= 0 AND share <= 1),
+            effective_from DATE NOT NULL,
+            effective_to DATE,
+            comment TEXT,
+            is_active BOOLEAN NOT NULL DEFAULT TRUE,
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE INDEX IF NOT EXISTS idx_compensation_rules_driver ON compensation_rules(driver_user_id) WHERE is_active;
+        CREATE TABLE IF NOT EXISTS loader_pay_rates (
+            id SERIAL PRIMARY KEY,
+            loader_user_id INTEGER REFERENCES users(id),
+            category TEXT,
+            per_order FLOAT NOT NULL DEFAULT 0,
+            per_hour FLOAT NOT NULL DEFAULT 0,
+            per_floor FLOAT NOT NULL DEFAULT 0,
+            per_ton FLOAT NOT NULL DEFAULT 0,
+            effective_from DATE NOT NULL,
+            effective_to DATE,
+            comment TEXT,
+            is_active BOOLEAN NOT NULL DEFAULT TRUE,
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE INDEX IF NOT EXISTS idx_loader_pay_rates_loader ON loader_pay_rates(loader_user_id) WHERE is_active;
+        CREATE TABLE IF NOT EXISTS vehicles (
+            id SERIAL PRIMARY KEY,
+            plate TEXT NOT NULL,
+            model TEXT,
+            capacity_m3 FLOAT NOT NULL DEFAULT 0,
+            capacity_tonnes FLOAT NOT NULL DEFAULT 0,
+            fuel_norm_l_per_100km FLOAT NOT NULL DEFAULT 0,
+            fuel_price_per_l FLOAT NOT NULL DEFAULT 0,
+            default_driver_user_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+            is_active BOOLEAN NOT NULL DEFAULT TRUE,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_vehicles_plate ON vehicles(UPPER(plate));
+        CREATE TABLE IF NOT EXISTS vehicle_maintenance (
+            id SERIAL PRIMARY KEY,
+            vehicle_id INTEGER NOT NULL REFERENCES vehicles(id) ON DELETE CASCADE,
+            kind TEXT NOT NULL,
+            performed_on DATE NOT NULL,
+            odometer INTEGER,
+            cost NUMERIC(12,2) NOT NULL DEFAULT 0,
+            description TEXT,
+            valid_until DATE,
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+            canceled_at TIMESTAMP WITH TIME ZONE
+        );
+        CREATE INDEX IF NOT EXISTS idx_vehicle_maintenance_vehicle ON vehicle_maintenance(vehicle_id, performed_on);
+        CREATE TABLE IF NOT EXISTS payroll_runs (
+            id SERIAL PRIMARY KEY,
+            period_from DATE NOT NULL,
+            period_to DATE NOT NULL,
+            status TEXT NOT NULL DEFAULT 'draft',
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+            confirmed_by_user_id INTEGER REFERENCES users(id),
+            confirmed_at TIMESTAMP WITH TIME ZONE,
+            reversed_by_user_id INTEGER REFERENCES users(id),
+            reversed_at TIMESTAMP WITH TIME ZONE,
+            reverse_reason TEXT
+        );
+        CREATE UNIQUE INDEX IF NOT EXISTS idx_payroll_runs_active_period ON payroll_runs(period_from, period_to)
+            WHERE status <> 'reversed';
+        CREATE TABLE IF NOT EXISTS payroll_run_lines (
+            id SERIAL PRIMARY KEY,
+            run_id INTEGER REFERENCES payroll_runs(id) ON DELETE CASCADE NOT NULL,
+            user_id INTEGER REFERENCES users(id) NOT NULL,
+            role TEXT NOT NULL,
+            owed_amount NUMERIC(14,2) NOT NULL,
+            amount NUMERIC(14,2) NOT NULL,
+            comment TEXT,
+            payout_id INTEGER REFERENCES payouts(id),
+            UNIQUE (run_id, user_id)
+        );
+        CREATE TABLE IF NOT EXISTS cash_handovers (
+            id SERIAL PRIMARY KEY,
+            driver_user_id INTEGER REFERENCES users(id) NOT NULL,
+            amount NUMERIC(12,2) NOT NULL,
+            expected_amount NUMERIC(12,2),
+            handed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+            received_by_user_id INTEGER REFERENCES users(id),
+            comment TEXT,
+            ack_status TEXT NOT NULL DEFAULT 'pending',
+            ack_at TIMESTAMP WITH TIME ZONE,
+            ack_comment TEXT,
+            ack_reminded_at TIMESTAMP WITH TIME ZONE,
+            canceled_at TIMESTAMP WITH TIME ZONE,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE INDEX IF NOT EXISTS idx_cash_handovers_driver ON cash_handovers(driver_user_id, handed_at);
+        CREATE TABLE IF NOT EXISTS cash_handover_allocations (
+            handover_id INTEGER REFERENCES cash_handovers(id) ON DELETE CASCADE NOT NULL,
+            settlement_id INTEGER REFERENCES driver_settlements(id) ON DELETE CASCADE NOT NULL,
+            amount NUMERIC(12,2) NOT NULL,
+            PRIMARY KEY (handover_id, settlement_id)
+        );
+        CREATE TABLE IF NOT EXISTS cash_discrepancies (
+            id SERIAL PRIMARY KEY,
+            driver_user_id INTEGER REFERENCES users(id) NOT NULL,
+            handover_id INTEGER REFERENCES cash_handovers(id) ON DELETE SET NULL,
+            kind TEXT NOT NULL,
+            amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+            note TEXT,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+            resolved_at TIMESTAMP WITH TIME ZONE,
+            resolved_by_user_id INTEGER REFERENCES users(id),
+            resolution_comment TEXT
+        );
+        CREATE INDEX IF NOT EXISTS idx_cash_discrepancies_open ON cash_discrepancies(driver_user_id) WHERE resolved_at IS NULL;
+        CREATE TABLE IF NOT EXISTS referral_rules (
+            id SERIAL PRIMARY KEY,
+            version INTEGER NOT NULL UNIQUE,
+            bonus_type TEXT NOT NULL,
+            bonus_value NUMERIC(12,2) NOT NULL CHECK (bonus_value >= 0),
+            min_order_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+            categories TEXT[],
+            monthly_cap NUMERIC(12,2) NOT NULL DEFAULT 0,
+            expiry_days INTEGER NOT NULL DEFAULT 0,
+            min_payout_amount NUMERIC(12,2) NOT NULL DEFAULT 0,
+            effective_from TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+            comment TEXT,
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE TABLE IF NOT EXISTS financial_periods (
+            period_month DATE PRIMARY KEY,
+            is_closed BOOLEAN NOT NULL DEFAULT FALSE,
+            closed_at TIMESTAMP WITH TIME ZONE,
+            closed_by_user_id INTEGER REFERENCES users(id),
+            reopened_at TIMESTAMP WITH TIME ZONE,
+            reopened_by_user_id INTEGER REFERENCES users(id),
+            reopen_reason TEXT
+        );
+        CREATE TABLE IF NOT EXISTS financial_period_events (
+            id SERIAL PRIMARY KEY,
+            period_month DATE NOT NULL,
+            action TEXT NOT NULL,
+            reason TEXT,
+            user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE INDEX IF NOT EXISTS idx_financial_period_events_month ON financial_period_events(period_month, created_at);
+        CREATE TABLE IF NOT EXISTS price_rules (
+            id SERIAL PRIMARY KEY,
+            category TEXT,
+            subcategory TEXT,
+            base_price FLOAT NOT NULL DEFAULT 0,
+            included_volume_m3 FLOAT NOT NULL DEFAULT 0,
+            per_m3 FLOAT NOT NULL DEFAULT 0,
+            per_floor FLOAT NOT NULL DEFAULT 0,
+            urgent_percent FLOAT NOT NULL DEFAULT 0,
+            weekend_percent FLOAT NOT NULL DEFAULT 0,
+            free_km FLOAT NOT NULL DEFAULT 0,
+            per_km FLOAT NOT NULL DEFAULT 0,
+            min_price FLOAT NOT NULL DEFAULT 0,
+            effective_from DATE NOT NULL,
+            effective_to DATE,
+            comment TEXT,
+            is_active BOOLEAN NOT NULL DEFAULT TRUE,
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE INDEX IF NOT EXISTS idx_price_rules_category ON price_rules(category, subcategory) WHERE is_active;
+        CREATE TABLE IF NOT EXISTS order_quotes (
+            id SERIAL PRIMARY KEY,
+            order_id INTEGER NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+            status TEXT NOT NULL DEFAULT 'pending',
+            expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+            chosen_option_id INTEGER,
+            answered_at TIMESTAMP WITH TIME ZONE,
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE INDEX IF NOT EXISTS idx_order_quotes_pending ON order_quotes(expires_at) WHERE status = 'pending';
+        CREATE TABLE IF NOT EXISTS order_quote_options (
+            id SERIAL PRIMARY KEY,
+            quote_id INTEGER NOT NULL REFERENCES order_quotes(id) ON DELETE CASCADE,
+            position INTEGER NOT NULL DEFAULT 0,
+            title TEXT NOT NULL,
+            cost FLOAT NOT NULL,
+            drivers INTEGER NOT NULL DEFAULT 1,
+            loaders INTEGER NOT NULL DEFAULT 0
+        );
+        CREATE TABLE IF NOT EXISTS recurring_orders (
+            id SERIAL PRIMARY KEY,
+            source_order_id INTEGER REFERENCES orders(id) ON DELETE SET NULL,
+            user_id INTEGER REFERENCES users(id),
+            user_chat_id BIGINT NOT NULL,
+            category TEXT NOT NULL,
+            subcategory TEXT,
+            name TEXT,
+            phone TEXT,
+            address TEXT,
+            latitude FLOAT,
+            longitude FLOAT,
+            description TEXT,
+            payment TEXT,
+            time_slot TEXT,
+            cost FLOAT,
+            interval_days INTEGER NOT NULL CHECK (interval_days > 0),
+            start_date DATE NOT NULL,
+            end_date DATE,
+            status TEXT NOT NULL DEFAULT 'active',
+            created_by_user_id INTEGER REFERENCES users(id),
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+            updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
+        CREATE TABLE IF NOT EXISTS recurring_order_executors (
+            recurring_order_id INTEGER NOT NULL REFERENCES recurring_orders(id) ON DELETE CASCADE,
+            user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+            role TEXT NOT NULL CHECK (role IN ('driver', 'loader')),
+            PRIMARY KEY (recurring_order_id, user_id, role)
+        );
+        CREATE TABLE IF NOT EXISTS recurring_order_occurrences (
+            id SERIAL PRIMARY KEY,
+            recurring_order_id INTEGER NOT NULL REFERENCES recurring_orders(id) ON DELETE CASCADE,
+            occurrence_date DATE NOT NULL,
+            order_id INTEGER REFERENCES orders(id) ON DELETE SET NULL,
+            status TEXT NOT NULL,
+            created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+            UNIQUE (recurring_order_id, occurrence_date)
+        );
+        CREATE INDEX IF NOT EXISTS idx_recurring_order_occurrences_order ON recurring_order_occurrences(order_id);
+        CREATE TABLE IF NOT EXISTS data_migrations (
+            name TEXT PRIMARY KEY,
+            applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+        );
     `
 	_, err = tx.Exec(createTablesSQL)
 	if err != nil {
@@ -266,6 +573,12 @@ func InitDB() error {
         CREATE INDEX IF NOT EXISTS idx_driver_settlements_paid_to_owner_at ON driver_settlements(paid_to_owner_at); 
         CREATE INDEX IF NOT EXISTS idx_driver_settlements_salary_paid_at ON driver_settlements(driver_salary_paid_at); 
         CREATE INDEX IF NOT EXISTS idx_owner_cashier_records_driver_date ON owner_cashier_records(driver_user_id, report_date);
+        CREATE INDEX IF NOT EXISTS idx_payments_order_id ON payments(order_id);
+        CREATE INDEX IF NOT EXISTS idx_payments_status ON payments(status);
+        CREATE INDEX IF NOT EXISTS idx_refunds_payment_id ON refunds(payment_id);
+        CREATE INDEX IF NOT EXISTS idx_refunds_order_id ON refunds(order_id);
+        CREATE INDEX IF NOT EXISTS idx_ledger_postings_account_id ON ledger_postings(account_id);
+        CREATE INDEX IF NOT EXISTS idx_ledger_postings_journal_id ON ledger_postings(journal_id);
     `
 	// We'll execute index creation statements one by one to better isolate potential errors
 	indexStatements := strings.Split(strings.TrimSpace(createIndexesSQL), ";")
@@ -284,10 +597,44 @@ func InitDB() error {
 	}
 	log.Println("Создание индексов (если не существуют) завершено.")
 
+	// Step 4: One-time data migrations
+	runDataMigrations()
+
 	log.Println("Инициализация базы данных успешно завершена.")
 	return nil
 }
 
+// runDataMigrations выполняет разовые преобразования данных. Выполненные шаги отмечаются в data_migrations
+// и при следующих запусках пропускаются; шаг с ошибкой будет повторен при следующем запуске.
+func runDataMigrations() {
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		// Дозапись в главную книгу операций, созданных до ее появления, включая заработок по старым расходам заказов
+		{name: "ledger.backfill_with_expenses", run: BackfillLedger},
+	}
+	for _, step := range steps {
+		var applied bool
+		if err := DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM data_migrations WHERE name = $1)`, step.name).Scan(&applied); err != nil {
+			log.Printf("Предупреждение: не удалось проверить разовую миграцию '%s': %v", step.name, err)
+			continue
+		}
+		if applied {
+			continue
+		}
+		if err := step.run(); err != nil {
+			log.Printf("Предупреждение: разовая миграция '%s' не выполнена: %v", step.name, err)
+			continue
+		}
+		if _, err := DB.Exec(`INSERT INTO data_migrations (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, step.name); err != nil {
+			log.Printf("Предупреждение: не удалось отметить разовую миграцию '%s': %v", step.name, err)
+			continue
+		}
+		log.Printf("INFO: Разовая миграция '%s' выполнена.", step.name)
+	}
+}
+
 // migrateDBSchema выполняет необходимые миграции схемы базы данных.
 // This function should be idempotent.
 func migrateDBSchema() error {
@@ -393,6 +740,114 @@ func migrateDBSchema() error {
             `,
 		},
 		// --- КОНЕЦ НОВОЙ МИГРАЦИИ ---
+		{
+			name: "driver_settlements.online_paid_revenue",
+			sql:  `ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS online_paid_revenue FLOAT NOT NULL DEFAULT 0;`,
+		},
+		{
+			name: "users.email",
+			sql:  `ALTER TABLE users ADD COLUMN IF NOT EXISTS email TEXT;`,
+		},
+		{
+			name: "driver_settlements.driver_share_rate",
+			sql: `
+                ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS driver_share_rate FLOAT NULL;
+                ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS share_rule_ids BIGINT[];
+            `,
+		},
+		{
+			name: "payouts.payroll_run_id",
+			sql: `
+                ALTER TABLE payouts ADD COLUMN IF NOT EXISTS payroll_run_id INTEGER REFERENCES payroll_runs(id);
+                ALTER TABLE payouts ADD COLUMN IF NOT EXISTS reversed_at TIMESTAMP;
+                CREATE INDEX IF NOT EXISTS idx_payouts_payroll_run_id ON payouts(payroll_run_id);
+            `,
+		},
+		{
+			name: "driver_settlements.fuel_receipt_file_ids",
+			sql:  `ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS fuel_receipt_file_ids TEXT[];`,
+		},
+		{
+			name: "orders.vehicle_id",
+			sql:  `ALTER TABLE orders ADD COLUMN IF NOT EXISTS vehicle_id INTEGER REFERENCES vehicles(id) ON DELETE SET NULL;`,
+		},
+		{
+			name: "driver_settlements.vehicle_odometer",
+			sql: `ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS vehicle_id INTEGER REFERENCES vehicles(id) ON DELETE SET NULL;
+			      ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS odometer_start INTEGER;
+			      ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS odometer_end INTEGER;
+			      CREATE INDEX IF NOT EXISTS idx_driver_settlements_vehicle ON driver_settlements(vehicle_id) WHERE vehicle_id IS NOT NULL;`,
+		},
+		{
+			name: "vehicles.compliance",
+			sql: `ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS osago_expires_on DATE;
+			      ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS inspection_expires_on DATE;
+			      ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS service_interval_km INTEGER NOT NULL DEFAULT 0;
+			      ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS service_interval_days INTEGER NOT NULL DEFAULT 0;
+			      ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS last_service_on DATE;
+			      ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS last_service_odometer INTEGER;
+			      ALTER TABLE vehicles ADD COLUMN IF NOT EXISTS compliance_notified_on DATE;`,
+		},
+		{
+			name: "driver_settlements.handed_over_amount",
+			sql:  `ALTER TABLE driver_settlements ADD COLUMN IF NOT EXISTS handed_over_amount NUMERIC(12,2) NOT NULL DEFAULT 0;`,
+		},
+		{
+			name: "payouts.referral_payout_request_id",
+			sql: `ALTER TABLE payouts ADD COLUMN IF NOT EXISTS referral_payout_request_id INTEGER REFERENCES referral_payout_requests(id);
+			      CREATE UNIQUE INDEX IF NOT EXISTS idx_payouts_referral_payout_request_id ON payouts(referral_payout_request_id);`,
+		},
+		{
+			name: "referrals.rule_id",
+			sql: `ALTER TABLE referrals ADD COLUMN IF NOT EXISTS rule_id INTEGER REFERENCES referral_rules(id);
+			      ALTER TABLE referrals ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+			      ALTER TABLE referrals ADD COLUMN IF NOT EXISTS expired_at TIMESTAMP WITH TIME ZONE;`,
+		},
+		{
+			name: "users.referred_by_user_id",
+			sql: `ALTER TABLE users ADD COLUMN IF NOT EXISTS referred_by_user_id INTEGER REFERENCES users(id);
+			      ALTER TABLE users ADD COLUMN IF NOT EXISTS referred_at TIMESTAMP WITH TIME ZONE;`,
+		},
+		{
+			name: "referrals.review_status",
+			sql: `ALTER TABLE referrals ADD COLUMN IF NOT EXISTS review_status TEXT;
+			      ALTER TABLE referrals ADD COLUMN IF NOT EXISTS review_reasons TEXT[];
+			      ALTER TABLE referrals ADD COLUMN IF NOT EXISTS review_comment TEXT;
+			      ALTER TABLE referrals ADD COLUMN IF NOT EXISTS reviewed_at TIMESTAMP WITH TIME ZONE;
+			      ALTER TABLE referrals ADD COLUMN IF NOT EXISTS reviewed_by_user_id INTEGER REFERENCES users(id);
+			      ALTER TABLE users ADD COLUMN IF NOT EXISTS referral_flags TEXT[];
+			      CREATE INDEX IF NOT EXISTS idx_referrals_review_pending ON referrals(created_at) WHERE review_status = 'pending';`,
+		},
+		{
+			name: "orders.volume_access",
+			sql: `ALTER TABLE orders ADD COLUMN IF NOT EXISTS volume_m3 NUMERIC(8,2);
+			      ALTER TABLE orders ADD COLUMN IF NOT EXISTS tonnage_t NUMERIC(8,2);
+			      ALTER TABLE orders ADD COLUMN IF NOT EXISTS floor INTEGER;
+			      ALTER TABLE orders ADD COLUMN IF NOT EXISTS has_elevator BOOLEAN;
+			      ALTER TABLE orders ADD COLUMN IF NOT EXISTS needs_loaders BOOLEAN;`,
+		},
+		{
+			name: "orders.required_executors",
+			sql: `ALTER TABLE orders ADD COLUMN IF NOT EXISTS required_drivers INTEGER;
+			      ALTER TABLE orders ADD COLUMN IF NOT EXISTS required_loaders INTEGER;`,
+		},
+		{
+			// Когда клиенту напомнили о скорой автоотмене заказа; напоминание действует, пока заказ не изменился
+			name: "orders.expiry_reminder_at",
+			sql:  `ALTER TABLE orders ADD COLUMN IF NOT EXISTS expiry_reminder_at TIMESTAMP;`,
+		},
+		{
+			// Платеж аннулирован у нас при отмене заказа, но провайдер еще не сообщил окончательный статус
+			name: "payments.canceled_locally_at",
+			sql:  `ALTER TABLE payments ADD COLUMN IF NOT EXISTS canceled_locally_at TIMESTAMP WITH TIME ZONE;`,
+		},
+		{
+			// Версия 1 повторяет прежние условия программы: 500 ₽ за первый заказ друга от 10 000 ₽.
+			name: "referral_rules.default_version",
+			sql: `INSERT INTO referral_rules (version, bonus_type, bonus_value, min_order_amount, comment)
+			      SELECT 1, 'fixed', 500, 10000, 'Условия по умолчанию'
+			      WHERE NOT EXISTS (SELECT 1 FROM referral_rules);`,
+		},
 	}
 
 	for _, migration := range migrations {
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/lib/pq"
@@ -14,9 +15,20 @@ import (
 
 // --- НАЧАЛО НОВОЙ ФУНКЦИИ ---
 // UpdateDriverSettlementStatus обновляет статус и комментарий отчета.
+// Отклоненный отчет снимается с главной книги, при повторном одобрении проводки восстанавливаются.
 func UpdateDriverSettlementStatus(settlementID int64, status string, comment sql.NullString) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("UpdateDriverSettlementStatus: ошибка начала транзакции: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = ensureSettlementPeriodOpen(tx, settlementID); err != nil {
+		return err
+	}
 	query := `UPDATE driver_settlements SET status = $1, admin_comment = $2, updated_at = NOW() WHERE id = $3`
-	result, err := DB.Exec(query, status, comment, settlementID)
+	result, err := tx.Exec(query, status, comment, settlementID)
 	if err != nil {
 		log.Printf("UpdateDriverSettlementStatus: ошибка обновления статуса для отчета #%d: %v", settlementID, err)
 		return err
@@ -25,6 +37,13 @@ func UpdateDriverSettlementStatus(settlementID int64, status string, comment sql
 	if rowsAffected == 0 {
 		return fmt.Errorf("отчет #%d не найден для обновления статуса", settlementID)
 	}
+	if err = syncSettlementLedgerInTx(tx, settlementID); err != nil {
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("UpdateDriverSettlementStatus: ошибка коммита транзакции: %v", err)
+		return err
+	}
 	log.Printf("Статус отчета #%d обновлен на '%s'.", settlementID, status)
 	return nil
 }
@@ -51,7 +70,7 @@ func MarkSettlementAsPaidToOwnerInTx(tx *sql.Tx, settlementID int64) error {
 		return fmt.Errorf("отчет #%d не найден или уже помечен как оплаченный (в транзакции)", settlementID)
 	}
 	log.Printf("Отчет #%d помечен как оплаченный владельцу (в транзакции).", settlementID)
-	return nil
+	return syncSettlementLedgerInTx(tx, settlementID)
 }
 
 // MarkDriverSalaryAsPaidInTx устанавливает время выплаты ЗП водителю по отчету в рамках транзакции.
@@ -74,7 +93,7 @@ func MarkDriverSalaryAsPaidInTx(tx *sql.Tx, settlementID int64) error {
 		return fmt.Errorf("отчет #%d не найден или ЗП уже помечена как выплаченная (в транзакции)", settlementID)
 	}
 	log.Printf("ЗП по отчету #%d помечена как выплаченная водителю (в транзакции).", settlementID)
-	return nil
+	return syncSettlementLedgerInTx(tx, settlementID)
 }
 
 // CheckAndSettleOrdersForSettlement проверяет условия по отчету и обновляет статусы заказов.
@@ -119,12 +138,14 @@ func CheckAndSettleOrdersForSettlement(tx *sql.Tx, settlementID int64) error {
 	return nil
 }
 
-// MarkSettlementAsPaidToOwner sets the paid_to_owner_at timestamp.
-func MarkSettlementAsPaidToOwner(settlementID int64) error {
+// MarkSettlementAsPaidToOwner отмечает, что водитель сдал все деньги по отчету.
+// Несданный остаток записывается как фактическая сдача наличных (cash_handovers), которую водитель
+// должен подтвердить; ее ID возвращается. Если сдавать нечего, отчет просто помечается сданным и ID = 0.
+func MarkSettlementAsPaidToOwner(settlementID int64, receivedByUserID int64) (int64, error) {
 	tx, err := DB.Begin()
 	if err != nil {
 		log.Printf("MarkSettlementAsPaidToOwner: ошибка начала транзакции: %v", err)
-		return err
+		return 0, err
 	}
 	var opErr error
 	defer func() {
@@ -141,15 +162,36 @@ func MarkSettlementAsPaidToOwner(settlementID int64) error {
 		}
 	}()
 
-	opErr = MarkSettlementAsPaidToOwnerInTx(tx, settlementID)
+	if opErr = ensureSettlementPeriodOpen(tx, settlementID); opErr != nil {
+		return 0, opErr
+	}
+
+	var driverUserID int64
+	var due float64
+	var status string
+	var paidToOwnerAt sql.NullTime
+	opErr = tx.QueryRow(`SELECT driver_user_id, amount_to_cashier - handed_over_amount, status, paid_to_owner_at
+	                     FROM driver_settlements WHERE id = $1 FOR UPDATE`, settlementID).Scan(&driverUserID, &due, &status, &paidToOwnerAt)
 	if opErr != nil {
-		return opErr
+		log.Printf("MarkSettlementAsPaidToOwner: ошибка получения отчета #%d: %v", settlementID, opErr)
+		return 0, opErr
 	}
-	opErr = CheckAndSettleOrdersForSettlement(tx, settlementID)
+	if !paidToOwnerAt.Valid && status != constants.SETTLEMENT_STATUS_REJECTED && toKopecks(due) > 0 {
+		var handover models.CashHandover
+		handover, opErr = recordCashHandoverInTx(tx, driverUserID, due, sql.NullFloat64{Float64: due, Valid: true}, receivedByUserID,
+			fmt.Sprintf("Все деньги по отчету #%d", settlementID), []int64{settlementID})
+		if opErr != nil {
+			return 0, opErr
+		}
+		return handover.ID, nil
+	}
+
+	opErr = MarkSettlementAsPaidToOwnerInTx(tx, settlementID)
 	if opErr != nil {
-		return opErr
+		return 0, opErr
 	}
-	return opErr
+	opErr = CheckAndSettleOrdersForSettlement(tx, settlementID)
+	return 0, opErr
 }
 
 // MarkDriverSalaryAsPaid устанавливает время выплаты ЗП водителю.
@@ -174,6 +216,9 @@ func MarkDriverSalaryAsPaid(settlementID int64) error {
 		}
 	}()
 
+	if opErr = ensureSettlementPeriodOpen(tx, settlementID); opErr != nil {
+		return opErr
+	}
 	opErr = MarkDriverSalaryAsPaidInTx(tx, settlementID)
 	if opErr != nil {
 		return opErr
@@ -208,6 +253,20 @@ func AddDriverSettlement(settlement models.DriverSettlement) (int64, error) {
 		}
 	}()
 
+	// Зарплата грузчика начисляется на его счет в главной книге, поэтому строка без users.id недопустима.
+	for _, lp := range settlement.LoaderPayments {
+		if lp.LoaderUserID <= 0 {
+			opErr = fmt.Errorf("грузчик «%s» не привязан к пользователю", lp.LoaderIdentifier)
+			log.Printf("AddDriverSettlement: %v", opErr)
+			return 0, opErr
+		}
+		if lp.IsOverridden() && strings.TrimSpace(lp.OverrideReason) == "" {
+			opErr = fmt.Errorf("для грузчика «%s» сумма отличается от тарифа без указания причины", lp.LoaderIdentifier)
+			log.Printf("AddDriverSettlement: %v", opErr)
+			return 0, opErr
+		}
+	}
+
 	loaderPaymentsJSONBytes, errMarshal := json.Marshal(settlement.LoaderPayments)
 	if errMarshal != nil {
 		opErr = fmt.Errorf("ошибка маршалинга loader_payments: %w", errMarshal)
@@ -230,6 +289,9 @@ func AddDriverSettlement(settlement models.DriverSettlement) (int64, error) {
 		settlement.SettlementTimestamp.Day(),
 		0, 0, 0, 0, settlement.SettlementTimestamp.Location(),
 	)
+	if opErr = ensurePeriodsOpen(tx, settlement.ReportDate); opErr != nil {
+		return 0, opErr
+	}
 	if settlement.CreatedAt.IsZero() {
 		settlement.CreatedAt = time.Now()
 	}
@@ -243,8 +305,9 @@ func AddDriverSettlement(settlement models.DriverSettlement) (int64, error) {
             covered_orders_revenue, fuel_expense, other_expenses_json, loader_payments_json,
             driver_calculated_salary, amount_to_cashier, covered_orders_count,
             created_at, updated_at, covered_order_ids, paid_to_owner_at, driver_salary_paid_at,
-            status, admin_comment
-        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULL, NULL, $14, NULL)
+            status, admin_comment, online_paid_revenue, driver_share_rate, share_rule_ids, fuel_receipt_file_ids,
+            vehicle_id, odometer_start, odometer_end
+        ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NULL, NULL, $14, NULL, $15, $16, $17, $18, $19, $20, $21)
         RETURNING id`
 	var id int64
 	opErr = tx.QueryRow(query,
@@ -252,7 +315,9 @@ func AddDriverSettlement(settlement models.DriverSettlement) (int64, error) {
 		settlement.CoveredOrdersRevenue, settlement.FuelExpense, otherExpensesJSON, loaderPaymentsJSON,
 		settlement.DriverCalculatedSalary, settlement.AmountToCashier, settlement.CoveredOrdersCount,
 		settlement.CreatedAt, settlement.UpdatedAt, pq.Array(settlement.CoveredOrderIDs),
-		constants.SETTLEMENT_STATUS_PENDING,
+		constants.SETTLEMENT_STATUS_PENDING, settlement.OnlinePaidRevenue,
+		settlement.DriverShareRate, pq.Array(settlement.ShareRuleIDs), pq.Array(settlement.FuelReceiptFileIDs),
+		settlement.VehicleID, settlement.OdometerStart, settlement.OdometerEnd,
 	).Scan(&id)
 
 	if opErr != nil {
@@ -266,6 +331,18 @@ func AddDriverSettlement(settlement models.DriverSettlement) (int64, error) {
 		return 0, opErr
 	}
 
+	opErr = SettleCashPaymentsInTx(tx, id, settlement.CoveredOrderIDs)
+	if opErr != nil {
+		log.Printf("AddDriverSettlement: ошибка SettleCashPaymentsInTx: %v", opErr)
+		return 0, opErr
+	}
+
+	opErr = syncSettlementLedgerInTx(tx, id)
+	if opErr != nil {
+		log.Printf("AddDriverSettlement: ошибка проводки отчета #%d в главной книге: %v", id, opErr)
+		return 0, opErr
+	}
+
 	return id, opErr
 }
 
@@ -275,7 +352,8 @@ func GetDriverSettlementsByDriverAndDate(driverUserID int64, reportDate time.Tim
 		SELECT id, driver_user_id, report_date, settlement_timestamp,
 		       covered_orders_revenue, fuel_expense, other_expenses_json, loader_payments_json,
 		       driver_calculated_salary, amount_to_cashier, covered_orders_count,
-		       created_at, updated_at, covered_order_ids, paid_to_owner_at, driver_salary_paid_at
+		       created_at, updated_at, covered_order_ids, paid_to_owner_at, driver_salary_paid_at,
+		       online_paid_revenue
 		FROM driver_settlements
 		WHERE driver_user_id = $1 AND report_date = $2
 		ORDER BY settlement_timestamp ASC`
@@ -299,6 +377,7 @@ func GetDriverSettlementsByDriverAndDate(driverUserID int64, reportDate time.Tim
 			&s.CoveredOrdersRevenue, &s.FuelExpense, &otherExpensesJSON, &loaderPaymentsJSON,
 			&s.DriverCalculatedSalary, &s.AmountToCashier, &s.CoveredOrdersCount,
 			&s.CreatedAt, &s.UpdatedAt, &coveredOrderIDs, &s.PaidToOwnerAt, &s.DriverSalaryPaidAt,
+			&s.OnlinePaidRevenue,
 		)
 		if errScan != nil {
 			log.Printf("GetDriverSettlementsByDriverAndDate: ошибка сканирования отчета: %v", errScan)
@@ -379,13 +458,16 @@ func GetDriverSettlementByID(settlementID int64) (models.DriverSettlement, error
 	var loaderPaymentsJSON sql.NullString
 	var otherExpensesJSON sql.NullString
 	var coveredOrderIDs pq.Int64Array
+	var shareRuleIDs pq.Int64Array
+	var fuelReceipts pq.StringArray
 
 	query := `
 		SELECT id, driver_user_id, report_date, settlement_timestamp,
 		       covered_orders_revenue, fuel_expense, other_expenses_json, loader_payments_json,
 		       driver_calculated_salary, amount_to_cashier, covered_orders_count,
 		       created_at, updated_at, covered_order_ids, paid_to_owner_at, driver_salary_paid_at,
-		       status, admin_comment
+		       online_paid_revenue, status, admin_comment, driver_share_rate, share_rule_ids, fuel_receipt_file_ids,
+		       vehicle_id, odometer_start, odometer_end, handed_over_amount
 		FROM driver_settlements
 		WHERE id = $1`
 
@@ -394,7 +476,8 @@ func GetDriverSettlementByID(settlementID int64) (models.DriverSettlement, error
 		&s.CoveredOrdersRevenue, &s.FuelExpense, &otherExpensesJSON, &loaderPaymentsJSON,
 		&s.DriverCalculatedSalary, &s.AmountToCashier, &s.CoveredOrdersCount,
 		&s.CreatedAt, &s.UpdatedAt, &coveredOrderIDs, &s.PaidToOwnerAt, &s.DriverSalaryPaidAt,
-		&s.Status, &s.AdminComment,
+		&s.OnlinePaidRevenue, &s.Status, &s.AdminComment, &s.DriverShareRate, &shareRuleIDs, &fuelReceipts,
+		&s.VehicleID, &s.OdometerStart, &s.OdometerEnd, &s.HandedOverAmount,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -405,6 +488,8 @@ func GetDriverSettlementByID(settlementID int64) (models.DriverSettlement, error
 	}
 
 	s.CoveredOrderIDs = []int64(coveredOrderIDs)
+	s.ShareRuleIDs = []int64(shareRuleIDs)
+	s.FuelReceiptFileIDs = []string(fuelReceipts)
 
 	if loaderPaymentsJSON.Valid && loaderPaymentsJSON.String != "" && loaderPaymentsJSON.String != "null" {
 		if errUnmarshal := json.Unmarshal([]byte(loaderPaymentsJSON.String), &s.LoaderPayments); errUnmarshal != nil {
@@ -458,7 +543,33 @@ func UpdateDriverSettlement(settlement models.DriverSettlement) error {
 	}
 	settlement.UpdatedAt = time.Now()
 
-	driverSharePercentage := 0.35 // ЗАМЕНИТЬ НА РЕАЛЬНОЕ ЗНАЧЕНИЕ ИЗ CONFIG
+	// Доля водителя берется из самого отчета, а не из текущих правил:
+	// правка старого отчета не должна пересчитывать его по ставке, введенной позже.
+	if !settlement.DriverShareRate.Valid {
+		var storedRate sql.NullFloat64
+		var storedSalary, storedRevenue, storedFuel float64
+		var storedOtherJSON, storedLoaderJSON sql.NullString
+		errRate := DB.QueryRow(`SELECT driver_share_rate, driver_calculated_salary, covered_orders_revenue, fuel_expense,
+		                               other_expenses_json, loader_payments_json
+		                        FROM driver_settlements WHERE id = $1`, settlement.ID).Scan(
+			&storedRate, &storedSalary, &storedRevenue, &storedFuel, &storedOtherJSON, &storedLoaderJSON)
+		if errRate != nil {
+			log.Printf("UpdateDriverSettlement: ошибка получения ставки отчета #%d: %v", settlement.ID, errRate)
+			return errRate
+		}
+		if storedRate.Valid {
+			settlement.DriverShareRate = storedRate
+		} else {
+			// Отчеты, созданные до хранения ставки: восстанавливаем ее из сохраненных сумм.
+			storedNet := storedRevenue - storedFuel - sumExpensesJSON(storedOtherJSON) - sumExpensesJSON(storedLoaderJSON)
+			rate := 0.0
+			if storedNet != 0 {
+				rate = storedSalary / storedNet
+			}
+			settlement.DriverShareRate = sql.NullFloat64{Float64: rate, Valid: true}
+		}
+	}
+	driverSharePercentage := settlement.DriverShareRate.Float64
 	netForDriver := settlement.CoveredOrdersRevenue - settlement.FuelExpense
 
 	totalOtherExpenses := 0.0
@@ -473,7 +584,8 @@ func UpdateDriverSettlement(settlement models.DriverSettlement) error {
 	}
 	netForDriver -= totalLoaderSalary
 	settlement.DriverCalculatedSalary = netForDriver * driverSharePercentage
-	settlement.AmountToCashier = netForDriver - settlement.DriverCalculatedSalary
+	// Оплаченное онлайн водитель не получал на руки, поэтому оно не сдается в кассу.
+	settlement.AmountToCashier = netForDriver - settlement.DriverCalculatedSalary - settlement.OnlinePaidRevenue
 
 	query := `
 		UPDATE driver_settlements SET
@@ -490,10 +602,36 @@ func UpdateDriverSettlement(settlement models.DriverSettlement) error {
 			covered_order_ids = $11,
 			paid_to_owner_at = $12,
 			driver_salary_paid_at = $13,
-			updated_at = $14
+			updated_at = $14,
+			online_paid_revenue = $16,
+			driver_share_rate = $17,
+			fuel_receipt_file_ids = $18,
+			vehicle_id = $19,
+			odometer_start = $20,
+			odometer_end = $21
 		WHERE id = $15`
 
-	result, err := DB.Exec(query,
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("UpdateDriverSettlement: ошибка начала транзакции: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Нельзя ни править отчет закрытого месяца, ни перенести отчет в закрытый месяц
+	if err = ensureSettlementPeriodOpen(tx, settlement.ID); err != nil {
+		return err
+	}
+	if err = ensurePeriodsOpen(tx, settlement.ReportDate); err != nil {
+		return err
+	}
+	// Заказы, убранные из отчета, снова учитываются по своим расходам
+	var previousOrderIDs pq.Int64Array
+	if err = tx.QueryRow(`SELECT covered_order_ids FROM driver_settlements WHERE id = $1 FOR UPDATE`, settlement.ID).Scan(&previousOrderIDs); err != nil {
+		log.Printf("UpdateDriverSettlement: ошибка чтения заказов отчета #%d: %v", settlement.ID, err)
+		return err
+	}
+	result, err := tx.Exec(query,
 		settlement.DriverUserID,
 		settlement.ReportDate,
 		settlement.SettlementTimestamp,
@@ -509,6 +647,12 @@ func UpdateDriverSettlement(settlement models.DriverSettlement) error {
 		settlement.DriverSalaryPaidAt,
 		settlement.UpdatedAt,
 		settlement.ID,
+		settlement.OnlinePaidRevenue,
+		settlement.DriverShareRate,
+		pq.Array(settlement.FuelReceiptFileIDs),
+		settlement.VehicleID,
+		settlement.OdometerStart,
+		settlement.OdometerEnd,
 	)
 	if err != nil {
 		log.Printf("UpdateDriverSettlement: ошибка обновления отчета #%d: %v", settlement.ID, err)
@@ -520,11 +664,26 @@ func UpdateDriverSettlement(settlement models.DriverSettlement) error {
 		return fmt.Errorf("отчет #%d не найден для обновления", settlement.ID)
 	}
 
+	// Исправленный отчет перепроводится: старые проводки сторнируются, новые записываются по текущим суммам.
+	if err = syncSettlementLedgerInTx(tx, settlement.ID); err != nil {
+		log.Printf("UpdateDriverSettlement: ошибка перепроводки отчета #%d: %v", settlement.ID, err)
+		return err
+	}
+	if err = syncOrderExpensesLedgerInTx(tx, previousOrderIDs...); err != nil {
+		log.Printf("UpdateDriverSettlement: ошибка перепроводки расходов заказов отчета #%d: %v", settlement.ID, err)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("UpdateDriverSettlement: ошибка коммита транзакции: %v", err)
+		return err
+	}
+
 	log.Printf("Отчет водителя #%d успешно обновлен. ReportDate: %s, AmountToCashier: %.0f", settlement.ID, settlement.ReportDate.Format("2006-01-02"), settlement.AmountToCashier)
 	return nil
 }
 
 // GetAggregatedDriverSettlements получает агрегированные данные по отчетам водителей.
+// Для каждого водителя считается и несданный остаток с разбивкой по возрасту долга (по дате отчета).
 func GetAggregatedDriverSettlements(viewType string) ([]models.AggregatedDriverSettlementInfo, int, error) {
 	var totalDrivers int
 	var rows *sql.Rows
@@ -538,7 +697,12 @@ func GetAggregatedDriverSettlements(viewType string) ([]models.AggregatedDriverS
 		    u.last_name AS driver_last_name,
 		    u.nickname AS driver_nickname,
 		    SUM(ds.amount_to_cashier) AS total_amount_to_cashier,
-		    COUNT(ds.id) AS total_reports_count
+		    COUNT(ds.id) AS total_reports_count,
+		    COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount) FILTER (WHERE ` + openCashDueSQL + `), 0) AS outstanding_amount,
+		    COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount) FILTER (WHERE ` + openCashDueSQL + ` AND CURRENT_DATE - ds.report_date <= $1), 0) AS debt_0_7_days,
+		    COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount) FILTER (WHERE ` + openCashDueSQL + ` AND CURRENT_DATE - ds.report_date > $1 AND CURRENT_DATE - ds.report_date <= $2), 0) AS debt_8_30_days,
+		    COALESCE(SUM(ds.amount_to_cashier - ds.handed_over_amount) FILTER (WHERE ` + openCashDueSQL + ` AND CURRENT_DATE - ds.report_date > $2), 0) AS debt_over_30_days,
+		    MIN(ds.report_date) FILTER (WHERE ` + openCashDueSQL + `)::timestamptz AS oldest_debt_date
 		FROM driver_settlements ds
 		JOIN users u ON ds.driver_user_id = u.id
 		WHERE ds.amount_to_cashier > 0 `
@@ -548,7 +712,7 @@ func GetAggregatedDriverSettlements(viewType string) ([]models.AggregatedDriverS
 
 	if viewType == "actual" {
 		whereConditions = "AND (ds.paid_to_owner_at IS NULL OR ds.driver_salary_paid_at IS NULL) "
-		orderByClause = "ORDER BY MIN(ds.settlement_timestamp) ASC, driver_first_name, driver_last_name"
+		orderByClause = "ORDER BY oldest_debt_date ASC NULLS LAST, MIN(ds.settlement_timestamp) ASC, driver_first_name, driver_last_name"
 	} else {
 		whereConditions = "AND (ds.paid_to_owner_at IS NOT NULL AND ds.driver_salary_paid_at IS NOT NULL) "
 		orderByClause = "ORDER BY MAX(CASE WHEN ds.paid_to_owner_at IS NOT NULL THEN ds.paid_to_owner_at ELSE ds.driver_salary_paid_at END) DESC, driver_first_name, driver_last_name"
@@ -562,7 +726,7 @@ func GetAggregatedDriverSettlements(viewType string) ([]models.AggregatedDriverS
 	}
 
 	fullQuery := queryBase + whereConditions + "GROUP BY ds.driver_user_id, u.first_name, u.last_name, u.nickname " + orderByClause
-	rows, err = DB.Query(fullQuery)
+	rows, err = DB.Query(fullQuery, constants.CASH_DEBT_AGE_FRESH_DAYS, constants.CASH_DEBT_AGE_LATE_DAYS)
 	if err != nil {
 		log.Printf("GetAggregatedDriverSettlements: ошибка получения агрегированных данных (viewType: %s): %v", viewType, err)
 		return nil, 0, err
@@ -579,6 +743,11 @@ func GetAggregatedDriverSettlements(viewType string) ([]models.AggregatedDriverS
 			&aggInfo.DriverNickname,
 			&aggInfo.TotalAmountToCashier,
 			&aggInfo.TotalReportsCount,
+			&aggInfo.OutstandingAmount,
+			&aggInfo.Debt0To7Days,
+			&aggInfo.Debt8To30Days,
+			&aggInfo.DebtOver30Days,
+			&aggInfo.OldestDebtDate,
 		)
 		if errScan != nil {
 			log.Printf("GetAggregatedDriverSettlements: ошибка сканирования агрегированного отчета (viewType: %s): %v", viewType, errScan)
@@ -589,7 +758,7 @@ func GetAggregatedDriverSettlements(viewType string) ([]models.AggregatedDriverS
 	return aggregatedInfos, totalDrivers, rows.Err()
 }
 
-// GetActualDebts and GetSettledDebts (без изменений)
+// GetActualDebts and GetSettledDebts. Актуальные долги отсортированы по возрасту: сначала самые старые.
 func GetActualDebts(page int, perPage int) ([]models.AggregatedDriverSettlementInfo, int, error) {
 	allAggregated, totalDrivers, err := GetAggregatedDriverSettlements("actual")
 	if err != nil {
@@ -639,7 +808,7 @@ func GetDriverSettlementsForOwnerView(driverUserID int64, viewType string, page
 		    ds.covered_orders_revenue, ds.fuel_expense, ds.other_expenses_json, ds.loader_payments_json,
 		    ds.driver_calculated_salary, ds.amount_to_cashier, ds.covered_orders_count,
 		    ds.created_at, ds.updated_at, ds.covered_order_ids, ds.paid_to_owner_at, ds.driver_salary_paid_at,
-		    u.first_name AS driver_first_name, u.last_name AS driver_last_name, u.nickname AS driver_nickname
+		    ds.online_paid_revenue, ds.handed_over_amount, u.first_name AS driver_first_name, u.last_name AS driver_last_name, u.nickname AS driver_nickname
 		FROM driver_settlements ds
 		JOIN users u ON ds.driver_user_id = u.id
 		WHERE ds.driver_user_id = $1 AND ds.amount_to_cashier > 0 `
@@ -683,7 +852,7 @@ func GetDriverSettlementsForOwnerView(driverUserID int64, viewType string, page
 			&s.CoveredOrdersRevenue, &s.FuelExpense, &otherExpensesJSON, &loaderPaymentsJSON,
 			&s.DriverCalculatedSalary, &s.AmountToCashier, &s.CoveredOrdersCount,
 			&s.CreatedAt, &s.UpdatedAt, &coveredOrderIDs, &s.PaidToOwnerAt, &s.DriverSalaryPaidAt,
-			&s.DriverFirstName, &s.DriverLastName, &s.DriverNickname,
+			&s.OnlinePaidRevenue, &s.HandedOverAmount, &s.DriverFirstName, &s.DriverLastName, &s.DriverNickname,
 		)
 		if errScan != nil {
 			log.Printf("GetDriverSettlementsForOwnerView: ошибка сканирования отчета: %v", errScan)
@@ -715,7 +884,7 @@ func GetDriverSettlementsForOwnerView(driverUserID int64, viewType string, page
 }
 
 // MarkSettlementAsUnpaidToOwner снимает отметку о внесении денег.
-func MarkSettlementAsUnpaidToOwner(settlementID int64) error {
+func MarkSettlementAsUnpaidToOwner(settlementID int64, canceledByUserID int64) error {
 	tx, err := DB.Begin()
 	if err != nil {
 		log.Printf("MarkSettlementAsUnpaidToOwner: ошибка начала транзакции: %v", err)
@@ -736,6 +905,51 @@ func MarkSettlementAsUnpaidToOwner(settlementID int64) error {
 		}
 	}()
 
+	// Снятие отметки меняет и месяц отчета, и месяц сдачи денег
+	reportDate, paidToOwnerAt, _, opErr := settlementPeriodDates(tx, settlementID)
+	if opErr != nil && opErr != sql.ErrNoRows {
+		return opErr
+	}
+	if opErr = ensurePeriodsOpen(tx, reportDate, paidToOwnerAt.Time); opErr != nil {
+		return opErr
+	}
+
+	// Деньги, сданные через cash_handovers, снимаются отменой сдачи. Сдачу, зачтенную и в другие отчеты,
+	// отменяет владелец в истории сдач, иначе пострадают и эти отчеты.
+	handoverRows, opErr := tx.Query(`
+		SELECT a.handover_id, (SELECT COUNT(*) FROM cash_handover_allocations o WHERE o.handover_id = a.handover_id)
+		FROM cash_handover_allocations a JOIN cash_handovers h ON h.id = a.handover_id
+		WHERE a.settlement_id = $1 AND h.canceled_at IS NULL ORDER BY a.handover_id`, settlementID)
+	if opErr != nil {
+		log.Printf("MarkSettlementAsUnpaidToOwner: ошибка получения сдач по отчету #%d: %v", settlementID, opErr)
+		return opErr
+	}
+	var handoverIDs []int64
+	for handoverRows.Next() {
+		var handoverID int64
+		var settlementsCount int
+		if opErr = handoverRows.Scan(&handoverID, &settlementsCount); opErr != nil {
+			handoverRows.Close()
+			return opErr
+		}
+		if settlementsCount > 1 {
+			handoverRows.Close()
+			opErr = fmt.Errorf("деньги по отчету #%d зачтены сдачей #%d вместе с другими отчетами, отмените ее в истории сдач", settlementID, handoverID)
+			return opErr
+		}
+		handoverIDs = append(handoverIDs, handoverID)
+	}
+	handoverRows.Close()
+	for _, handoverID := range handoverIDs {
+		if opErr = cancelCashHandoverInTx(tx, handoverID, canceledByUserID); opErr != nil {
+			return opErr
+		}
+	}
+	if len(handoverIDs) > 0 {
+		log.Printf("Отметка 'деньги внесены' для отчета #%d снята, отменены сдачи: %v.", settlementID, handoverIDs)
+		return nil
+	}
+
 	query := `UPDATE driver_settlements SET paid_to_owner_at = NULL, updated_at = NOW() WHERE id = $1 AND paid_to_owner_at IS NOT NULL`
 	result, opErr := tx.Exec(query, settlementID)
 	if opErr != nil {
@@ -771,6 +985,11 @@ func MarkSettlementAsUnpaidToOwner(settlementID int64) error {
 		}
 	}
 
+	opErr = syncSettlementLedgerInTx(tx, settlementID)
+	if opErr != nil {
+		return opErr
+	}
+
 	log.Printf("Отметка 'деньги внесены' для отчета #%d снята.", settlementID)
 	return opErr
 }
@@ -797,6 +1016,14 @@ func MarkDriverSalaryAsUnpaid(settlementID int64) error {
 		}
 	}()
 
+	// Снятие отметки меняет и месяц отчета, и месяц выплаты
+	reportDate, _, salaryPaidAt, opErr := settlementPeriodDates(tx, settlementID)
+	if opErr != nil && opErr != sql.ErrNoRows {
+		return opErr
+	}
+	if opErr = ensurePeriodsOpen(tx, reportDate, salaryPaidAt.Time); opErr != nil {
+		return opErr
+	}
 	query := `UPDATE driver_settlements SET driver_salary_paid_at = NULL, updated_at = NOW() WHERE id = $1 AND driver_salary_paid_at IS NOT NULL`
 	result, opErr := tx.Exec(query, settlementID)
 	if opErr != nil {
@@ -832,6 +1059,114 @@ func MarkDriverSalaryAsUnpaid(settlementID int64) error {
 		}
 	}
 
+	opErr = syncSettlementLedgerInTx(tx, settlementID)
+	if opErr != nil {
+		return opErr
+	}
+
 	log.Printf("Отметка 'ЗП выплачена' для отчета #%d снята.", settlementID)
 	return opErr
 }
+
+// sumExpensesJSON суммирует поле amount в JSON-массиве расходов (other_expenses_json, loader_payments_json).
+func sumExpensesJSON(raw sql.NullString) float64 {
+	if !raw.Valid || raw.String == "" || raw.String == "null" {
+		return 0
+	}
+	var items []struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(raw.String), &items); err != nil {
+		return 0
+	}
+	total := 0.0
+	for _, item := range items {
+		total += item.Amount
+	}
+	return total
+}
+
+// AddSettlementReceipts прикрепляет файлы чеков к топливу (target "fuel") или к прочему расходу
+// с индексом expenseIndex (target "other") в отчете водителя, который еще ожидает проверки.
+// Суммы не меняются, поэтому проводки главной книги не пересчитываются.
+func AddSettlementReceipts(settlementID, driverUserID int64, target string, expenseIndex int, files []string) (models.DriverSettlement, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("AddSettlementReceipts: ошибка начала транзакции: %v", err)
+		return models.DriverSettlement{}, err
+	}
+	defer tx.Rollback()
+
+	var ownerID int64
+	var status string
+	var fuelReceipts pq.StringArray
+	var otherExpensesJSON sql.NullString
+	err = tx.QueryRow(`SELECT driver_user_id, status, fuel_receipt_file_ids, other_expenses_json
+	                   FROM driver_settlements WHERE id = $1 FOR UPDATE`, settlementID).Scan(
+		&ownerID, &status, &fuelReceipts, &otherExpensesJSON)
+	if err != nil {
+		log.Printf("AddSettlementReceipts: ошибка получения отчета #%d: %v", settlementID, err)
+		return models.DriverSettlement{}, err
+	}
+	if ownerID != driverUserID {
+		return models.DriverSettlement{}, fmt.Errorf("отчет #%d принадлежит другому водителю", settlementID)
+	}
+	if status != constants.SETTLEMENT_STATUS_PENDING {
+		return models.DriverSettlement{}, fmt.Errorf("чеки можно прикладывать только к отчету на проверке (статус отчета #%d: %s)", settlementID, status)
+	}
+
+	appendReceipts := func(existing []string) ([]string, error) {
+		for _, file := range files {
+			if file == "" {
+				continue
+			}
+			if len(existing) >= constants.MAX_RECEIPTS_PER_EXPENSE {
+				return nil, fmt.Errorf("к одному расходу можно приложить не больше %d чеков", constants.MAX_RECEIPTS_PER_EXPENSE)
+			}
+			existing = append(existing, file)
+		}
+		return existing, nil
+	}
+
+	switch target {
+	case "fuel":
+		updated, errAppend := appendReceipts(fuelReceipts)
+		if errAppend != nil {
+			return models.DriverSettlement{}, errAppend
+		}
+		_, err = tx.Exec(`UPDATE driver_settlements SET fuel_receipt_file_ids = $1, updated_at = NOW() WHERE id = $2`, pq.Array(updated), settlementID)
+	case "other":
+		var expenses []models.OtherExpenseDetail
+		if otherExpensesJSON.Valid && otherExpensesJSON.String != "" {
+			if errUnmarshal := json.Unmarshal([]byte(otherExpensesJSON.String), &expenses); errUnmarshal != nil {
+				log.Printf("AddSettlementReceipts: ошибка разбора прочих расходов отчета #%d: %v", settlementID, errUnmarshal)
+				return models.DriverSettlement{}, errUnmarshal
+			}
+		}
+		if expenseIndex < 0 || expenseIndex >= len(expenses) {
+			return models.DriverSettlement{}, fmt.Errorf("в отчете #%d нет прочего расхода с индексом %d", settlementID, expenseIndex)
+		}
+		updated, errAppend := appendReceipts(expenses[expenseIndex].ReceiptFileIDs)
+		if errAppend != nil {
+			return models.DriverSettlement{}, errAppend
+		}
+		expenses[expenseIndex].ReceiptFileIDs = updated
+		expensesBytes, errMarshal := json.Marshal(expenses)
+		if errMarshal != nil {
+			return models.DriverSettlement{}, errMarshal
+		}
+		_, err = tx.Exec(`UPDATE driver_settlements SET other_expenses_json = $1, updated_at = NOW() WHERE id = $2`, string(expensesBytes), settlementID)
+	default:
+		return models.DriverSettlement{}, fmt.Errorf("неизвестный тип расхода %q", target)
+	}
+	if err != nil {
+		log.Printf("AddSettlementReceipts: ошибка сохранения чеков отчета #%d: %v", settlementID, err)
+		return models.DriverSettlement{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("AddSettlementReceipts: ошибка коммита транзакции: %v", err)
+		return models.DriverSettlement{}, err
+	}
+	log.Printf("AddSettlementReceipts: к отчету #%d (%s) приложено чеков: %d", settlementID, target, len(files))
+	return GetDriverSettlementByID(settlementID)
+}
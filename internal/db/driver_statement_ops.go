@@ -0,0 +1,192 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GetDriverStatement собирает выписку по водителю за период from..to (календарные дни, включительно).
+// Отчеты попадают в выписку по report_date, сдача денег - по дате сдачи, выплата ЗП - по дате отметки.
+// Входящий остаток считается по всем движениям до начала периода. Отклоненные отчеты не учитываются.
+func GetDriverStatement(driverUserID int64, from, to time.Time) (models.DriverStatement, error) {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.Local)
+	statement := models.DriverStatement{DriverUserID: driverUserID, From: from, To: to, GeneratedAt: time.Now()}
+	if to.Before(from) {
+		return statement, fmt.Errorf("дата окончания периода раньше даты начала")
+	}
+	periodEnd := to.AddDate(0, 0, 1)
+
+	driver, err := GetUserByID(int(driverUserID))
+	if err != nil {
+		log.Printf("GetDriverStatement: водитель %d не найден: %v", driverUserID, err)
+		return statement, err
+	}
+	statement.DriverName = strings.TrimSpace(driver.FirstName + " " + driver.LastName)
+
+	rows, err := DB.Query(`
+        SELECT `+settlementForLedgerColumns+`, report_date, covered_order_ids, covered_orders_count
+        FROM driver_settlements
+        WHERE driver_user_id = $1 AND status <> $2
+        ORDER BY report_date, id`, driverUserID, constants.SETTLEMENT_STATUS_REJECTED)
+	if err != nil {
+		log.Printf("GetDriverStatement: ошибка получения отчетов водителя %d: %v", driverUserID, err)
+		return statement, err
+	}
+	defer rows.Close()
+
+	var movements []models.DriverStatementMovement
+	for rows.Next() {
+		var s models.DriverSettlement
+		var reportDate time.Time
+		var orderIDs pq.Int64Array
+		var ordersCount int
+		s, err = scanSettlementForLedger(scannerWithExtra{rows, []interface{}{&reportDate, &orderIDs, &ordersCount}})
+		if err != nil {
+			log.Printf("GetDriverStatement: ошибка сканирования отчета: %v", err)
+			return statement, err
+		}
+		reportDay := time.Date(reportDate.Year(), reportDate.Month(), reportDate.Day(), 0, 0, 0, 0, time.Local)
+
+		var otherExpenses, loaderPayments float64
+		for _, oe := range s.OtherExpenses {
+			otherExpenses += oe.Amount
+		}
+		for _, lp := range s.LoaderPayments {
+			loaderPayments += lp.Amount
+		}
+
+		if !reportDay.Before(from) && reportDay.Before(periodEnd) {
+			if len(orderIDs) > 0 {
+				ordersCount = len(orderIDs)
+			}
+			statement.Settlements = append(statement.Settlements, models.DriverStatementSettlement{
+				SettlementID:       s.ID,
+				ReportDate:         reportDay,
+				Status:             s.Status,
+				OrderIDs:           []int64(orderIDs),
+				OrdersCount:        ordersCount,
+				Revenue:            s.CoveredOrdersRevenue,
+				OnlinePaidRevenue:  s.OnlinePaidRevenue,
+				FuelExpense:        s.FuelExpense,
+				OtherExpenses:      otherExpenses,
+				LoaderPayments:     loaderPayments,
+				DriverSalary:       s.DriverCalculatedSalary,
+				AmountToCashier:    s.AmountToCashier,
+				PaidToOwnerAt:      s.PaidToOwnerAt,
+				DriverSalaryPaidAt: s.DriverSalaryPaidAt,
+			})
+			statement.Totals.OrdersCount += ordersCount
+			statement.Totals.Revenue += s.CoveredOrdersRevenue
+			statement.Totals.OnlinePaidRevenue += s.OnlinePaidRevenue
+			statement.Totals.FuelExpense += s.FuelExpense
+			statement.Totals.OtherExpenses += otherExpenses
+			statement.Totals.LoaderPayments += loaderPayments
+			statement.Totals.DriverSalary += s.DriverCalculatedSalary
+		}
+
+		// Движения наличных на руках у водителя - те же, что в проводках главной книги по счету driver_cash.
+		movements = append(movements, models.DriverStatementMovement{
+			Date:         reportDay,
+			Kind:         constants.LEDGER_KIND_SETTLEMENT_ACCRUAL,
+			SettlementID: s.ID,
+			Description:  fmt.Sprintf("Отчет #%d: наличные за вычетом расходов и оплаты грузчикам", s.ID),
+			Amount:       s.CoveredOrdersRevenue - s.OnlinePaidRevenue - s.FuelExpense - otherExpenses - loaderPayments,
+		})
+		if s.PaidToOwnerAt.Valid && s.HandedOverAmount == 0 {
+			movements = append(movements, models.DriverStatementMovement{
+				Date:         s.PaidToOwnerAt.Time,
+				Kind:         constants.LEDGER_KIND_SETTLEMENT_HANDOVER,
+				SettlementID: s.ID,
+				Description:  fmt.Sprintf("Сдано в кассу по отчету #%d", s.ID),
+				Amount:       -s.AmountToCashier,
+			})
+		}
+		if s.DriverSalaryPaidAt.Valid {
+			movements = append(movements, models.DriverStatementMovement{
+				Date:         s.DriverSalaryPaidAt.Time,
+				Kind:         constants.LEDGER_KIND_DRIVER_SALARY_PAID,
+				SettlementID: s.ID,
+				Description:  fmt.Sprintf("Выплачена ЗП по отчету #%d", s.ID),
+				Amount:       -s.DriverCalculatedSalary,
+			})
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return statement, err
+	}
+
+	handoverRows, err := DB.Query(`
+        SELECT id, amount, handed_at FROM cash_handovers
+        WHERE driver_user_id = $1 AND canceled_at IS NULL ORDER BY handed_at, id`, driverUserID)
+	if err != nil {
+		log.Printf("GetDriverStatement: ошибка получения сдач наличных водителя %d: %v", driverUserID, err)
+		return statement, err
+	}
+	defer handoverRows.Close()
+	for handoverRows.Next() {
+		var handoverID int64
+		var amount float64
+		var handedAt time.Time
+		if err = handoverRows.Scan(&handoverID, &amount, &handedAt); err != nil {
+			log.Printf("GetDriverStatement: ошибка сканирования сдачи наличных: %v", err)
+			return statement, err
+		}
+		movements = append(movements, models.DriverStatementMovement{
+			Date:        handedAt,
+			Kind:        constants.LEDGER_KIND_CASH_HANDOVER,
+			Description: fmt.Sprintf("Сдано в кассу (сдача #%d)", handoverID),
+			Amount:      -amount,
+		})
+	}
+	if err = handoverRows.Err(); err != nil {
+		return statement, err
+	}
+
+	sort.SliceStable(movements, func(i, j int) bool { return movements[i].Date.Before(movements[j].Date) })
+	balance := 0.0
+	for _, m := range movements {
+		if !m.Date.Before(periodEnd) {
+			break
+		}
+		if m.Date.Before(from) {
+			balance += m.Amount
+			continue
+		}
+		if len(statement.Movements) == 0 {
+			statement.OpeningBalance = balance
+		}
+		balance += m.Amount
+		m.Balance = balance
+		statement.Movements = append(statement.Movements, m)
+		switch m.Kind {
+		case constants.LEDGER_KIND_SETTLEMENT_HANDOVER, constants.LEDGER_KIND_CASH_HANDOVER:
+			statement.Totals.HandedOver -= m.Amount
+		case constants.LEDGER_KIND_DRIVER_SALARY_PAID:
+			statement.Totals.SalaryPaid -= m.Amount
+		}
+	}
+	if len(statement.Movements) == 0 {
+		statement.OpeningBalance = balance
+	}
+	statement.ClosingBalance = balance
+	return statement, nil
+}
+
+// scannerWithExtra дописывает дополнительные поля в конец Scan - чтобы переиспользовать
+// сканер со стандартным набором колонок в запросах, которые выбирают больше колонок.
+type scannerWithExtra struct {
+	row   rowScanner
+	extra []interface{}
+}
+
+func (s scannerWithExtra) Scan(dest ...interface{}) error {
+	return s.row.Scan(append(dest, s.extra...)...)
+}
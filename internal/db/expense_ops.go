@@ -41,6 +41,10 @@ func AddExpense(expense models.Expense) (int64, error) {
 		}
 	}()
 
+	if err = ensureOrderPeriodOpen(tx, int64(expense.OrderID)); err != nil {
+		return 0, err
+	}
+
 	loaderSalariesJSON, errMarshal := json.Marshal(expense.LoaderSalaries)
 	if errMarshal != nil {
 		log.Printf("AddExpense: ошибка маршалинга loader_salaries для orderID %d: %v", expense.OrderID, errMarshal)
@@ -98,6 +102,9 @@ func AddExpense(expense models.Expense) (int64, error) {
 	}
 	// ---> КОНЕЦ ИСПОЛЬЗОВАНИЯ GetOrderStatusInTx <---
 
+	if err = syncExpenseLedgerInTx(tx, id); err != nil {
+		return 0, err
+	}
 	return id, nil // Если err nil, defer вызовет Commit. В противном случае - Rollback.
 }
 
@@ -169,6 +176,9 @@ func UpdateExpenseInTx(tx *sql.Tx, expense models.Expense) error {
 	if expense.ID == 0 {
 		return fmt.Errorf("невозможно обновить расход: ID расхода не указан (равен 0)")
 	}
+	if err := ensureOrderPeriodOpen(tx, int64(expense.OrderID)); err != nil {
+		return err
+	}
 	loaderSalariesJSON, err := json.Marshal(expense.LoaderSalaries)
 	if err != nil {
 		log.Printf("UpdateExpenseInTx: ошибка маршалинга loader_salaries для expense_id %d: %v", expense.ID, err)
@@ -198,7 +208,7 @@ func UpdateExpenseInTx(tx *sql.Tx, expense models.Expense) error {
 		return fmt.Errorf("расход с ID %d не найден для обновления в транзакции", expense.ID)
 	}
 	log.Printf("Расход #%d успешно обновлен в транзакции.", expense.ID)
-	return nil
+	return syncExpenseLedgerInTx(tx, int64(expense.ID))
 }
 
 // MarkLoaderSalaryAsPaidByDriver отмечает зарплату грузчика как выплаченную водителем по заказу.
@@ -279,6 +289,16 @@ func MarkLoaderSalaryAsPaidByDriver(orderID int, loaderUserID int64, driverUserI
 // DeleteExpense удаляет запись о расходах по ее ID.
 // DeleteExpense deletes an expense record by its ID.
 func DeleteExpense(expenseID int) error {
+	var orderID sql.NullInt64
+	if err := DB.QueryRow("SELECT order_id FROM expenses WHERE id=$1", expenseID).Scan(&orderID); err != nil && err != sql.ErrNoRows {
+		log.Printf("DeleteExpense: ошибка чтения расхода #%d: %v", expenseID, err)
+		return err
+	}
+	if orderID.Valid {
+		if err := ensureOrderPeriodOpen(DB, orderID.Int64); err != nil {
+			return err
+		}
+	}
 	result, err := DB.Exec("DELETE FROM expenses WHERE id=$1", expenseID)
 	if err != nil {
 		log.Printf("DeleteExpense: ошибка удаления расхода #%d: %v", expenseID, err)
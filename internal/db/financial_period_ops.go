@@ -0,0 +1,285 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrFinancialPeriodClosed - изменение затрагивает закрытый месяц.
+var ErrFinancialPeriodClosed = fmt.Errorf("период закрыт для изменений")
+
+// periodQuerier - *sql.DB или *sql.Tx: проверка закрытых месяцев работает и внутри транзакций.
+type periodQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// PeriodMonth - первое число месяца даты t.
+func PeriodMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// closedPeriodError - ошибка с закрытым месяцем; проверяется через errors.Is(err, ErrFinancialPeriodClosed).
+func closedPeriodError(month time.Time) error {
+	return fmt.Errorf("%w: %s. Чтобы внести изменения, откройте месяц с указанием причины", ErrFinancialPeriodClosed, month.Format("01.2006"))
+}
+
+// ensurePeriodsOpen возвращает ErrFinancialPeriodClosed, если хотя бы одна из дат попадает в закрытый месяц.
+// Нулевые даты пропускаются. Строки проверяемых месяцев создаются при необходимости и блокируются FOR SHARE
+// до конца транзакции, поэтому CloseFinancialPeriod (FOR UPDATE) не закроет месяц между проверкой и изменением.
+func ensurePeriodsOpen(q periodQuerier, dates ...time.Time) error {
+	var months []string
+	for _, d := range dates {
+		if !d.IsZero() {
+			months = append(months, PeriodMonth(d).Format("2006-01-02"))
+		}
+	}
+	if len(months) == 0 {
+		return nil
+	}
+	// Только что вставленные строки не видны внешнему SELECT, но уже заблокированы этой транзакцией
+	var closedMonth sql.NullTime
+	err := q.QueryRow(`
+        WITH ins AS (
+            INSERT INTO financial_periods (period_month)
+            SELECT DISTINCT m FROM unnest($1::date[]) AS m
+            ON CONFLICT (period_month) DO NOTHING
+        )
+        SELECT MIN(p.period_month) FILTER (WHERE p.is_closed)
+        FROM (
+            SELECT period_month, is_closed FROM financial_periods
+            WHERE period_month = ANY($1::date[])
+            FOR SHARE
+        ) p`, pq.Array(months)).Scan(&closedMonth)
+	if err != nil {
+		log.Printf("ensurePeriodsOpen: ошибка проверки закрытых периодов: %v", err)
+		return err
+	}
+	if !closedMonth.Valid {
+		return nil
+	}
+	return closedPeriodError(closedMonth.Time)
+}
+
+// ensureOrderPeriodOpen проверяет, что месяц заказа (дата выполнения, без нее - дата создания) открыт.
+func ensureOrderPeriodOpen(q periodQuerier, orderID int64) error {
+	var orderDate time.Time
+	err := q.QueryRow(`SELECT COALESCE(date, created_at::date) FROM orders WHERE id = $1`, orderID).Scan(&orderDate)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		log.Printf("ensureOrderPeriodOpen: ошибка чтения даты заказа #%d: %v", orderID, err)
+		return err
+	}
+	return ensurePeriodsOpen(q, orderDate)
+}
+
+// settlementPeriodDates - дата отчета водителя и даты отметок о сдаче денег и выплате ЗП по нему.
+func settlementPeriodDates(q periodQuerier, settlementID int64) (reportDate time.Time, paidToOwnerAt, salaryPaidAt sql.NullTime, err error) {
+	err = q.QueryRow(`SELECT report_date, paid_to_owner_at, driver_salary_paid_at FROM driver_settlements WHERE id = $1`,
+		settlementID).Scan(&reportDate, &paidToOwnerAt, &salaryPaidAt)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("settlementPeriodDates: ошибка чтения отчета #%d: %v", settlementID, err)
+	}
+	return
+}
+
+// ensureSettlementPeriodOpen проверяет, что отчет водителя относится к открытому месяцу.
+func ensureSettlementPeriodOpen(q periodQuerier, settlementID int64) error {
+	reportDate, _, _, err := settlementPeriodDates(q, settlementID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return ensurePeriodsOpen(q, reportDate)
+}
+
+// CheckPeriodOpen - проверка для обработчиков: можно ли менять данные за дату t.
+func CheckPeriodOpen(t time.Time) error {
+	return ensurePeriodsOpen(DB, t)
+}
+
+// CloseFinancialPeriod закрывает месяц. Закрыть можно только завершившийся месяц.
+func CloseFinancialPeriod(month time.Time, userID int64) (models.FinancialPeriod, error) {
+	month = PeriodMonth(month)
+	if !month.Before(PeriodMonth(time.Now())) {
+		return models.FinancialPeriod{}, fmt.Errorf("месяц %s еще не завершился", month.Format("01.2006"))
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CloseFinancialPeriod: ошибка начала транзакции: %v", err)
+		return models.FinancialPeriod{}, err
+	}
+	defer tx.Rollback()
+
+	// Строка месяца создается и блокируется до конца транзакции: проверки ensurePeriodsOpen ждут закрытия
+	if _, err = tx.Exec(`INSERT INTO financial_periods (period_month) VALUES ($1) ON CONFLICT (period_month) DO NOTHING`, month.Format("2006-01-02")); err != nil {
+		log.Printf("CloseFinancialPeriod: ошибка создания месяца %s: %v", month.Format("01.2006"), err)
+		return models.FinancialPeriod{}, err
+	}
+	var isClosed bool
+	err = tx.QueryRow(`SELECT is_closed FROM financial_periods WHERE period_month = $1 FOR UPDATE`, month.Format("2006-01-02")).Scan(&isClosed)
+	if err != nil {
+		log.Printf("CloseFinancialPeriod: ошибка чтения месяца %s: %v", month.Format("01.2006"), err)
+		return models.FinancialPeriod{}, err
+	}
+	if isClosed {
+		return models.FinancialPeriod{}, fmt.Errorf("месяц %s уже закрыт", month.Format("01.2006"))
+	}
+	_, err = tx.Exec(`
+        INSERT INTO financial_periods (period_month, is_closed, closed_at, closed_by_user_id)
+        VALUES ($1, TRUE, NOW(), $2)
+        ON CONFLICT (period_month) DO UPDATE
+            SET is_closed = TRUE, closed_at = NOW(), closed_by_user_id = EXCLUDED.closed_by_user_id`, month.Format("2006-01-02"), userID)
+	if err != nil {
+		log.Printf("CloseFinancialPeriod: ошибка закрытия месяца %s: %v", month.Format("01.2006"), err)
+		return models.FinancialPeriod{}, err
+	}
+	if err = addFinancialPeriodEventInTx(tx, month, constants.FINANCIAL_PERIOD_ACTION_CLOSE, "", userID); err != nil {
+		return models.FinancialPeriod{}, err
+	}
+	period, err := getFinancialPeriod(tx, month)
+	if err != nil {
+		return period, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CloseFinancialPeriod: ошибка коммита транзакции: %v", err)
+		return period, err
+	}
+	log.Printf("CloseFinancialPeriod: пользователь %d закрыл месяц %s", userID, month.Format("01.2006"))
+	return period, nil
+}
+
+// ReopenFinancialPeriod открывает закрытый месяц для исправлений. Причина обязательна и пишется в журнал.
+func ReopenFinancialPeriod(month time.Time, userID int64, reason string) (models.FinancialPeriod, error) {
+	month = PeriodMonth(month)
+	if reason == "" {
+		return models.FinancialPeriod{}, fmt.Errorf("укажите причину открытия месяца")
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ReopenFinancialPeriod: ошибка начала транзакции: %v", err)
+		return models.FinancialPeriod{}, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        UPDATE financial_periods
+        SET is_closed = FALSE, reopened_at = NOW(), reopened_by_user_id = $2, reopen_reason = $3
+        WHERE period_month = $1 AND is_closed`, month.Format("2006-01-02"), userID, reason)
+	if err != nil {
+		log.Printf("ReopenFinancialPeriod: ошибка открытия месяца %s: %v", month.Format("01.2006"), err)
+		return models.FinancialPeriod{}, err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return models.FinancialPeriod{}, fmt.Errorf("месяц %s не закрыт", month.Format("01.2006"))
+	}
+	if err = addFinancialPeriodEventInTx(tx, month, constants.FINANCIAL_PERIOD_ACTION_REOPEN, reason, userID); err != nil {
+		return models.FinancialPeriod{}, err
+	}
+	period, err := getFinancialPeriod(tx, month)
+	if err != nil {
+		return period, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("ReopenFinancialPeriod: ошибка коммита транзакции: %v", err)
+		return period, err
+	}
+	log.Printf("ReopenFinancialPeriod: пользователь %d открыл месяц %s, причина: %s", userID, month.Format("01.2006"), reason)
+	return period, nil
+}
+
+func addFinancialPeriodEventInTx(tx *sql.Tx, month time.Time, action, reason string, userID int64) error {
+	_, err := tx.Exec(`INSERT INTO financial_period_events (period_month, action, reason, user_id) VALUES ($1, $2, $3, $4)`,
+		month.Format("2006-01-02"), action, sql.NullString{String: reason, Valid: reason != ""}, userID)
+	if err != nil {
+		log.Printf("addFinancialPeriodEventInTx: ошибка записи в журнал периода %s: %v", month.Format("01.2006"), err)
+	}
+	return err
+}
+
+const financialPeriodColumns = `period_month, is_closed, closed_at, closed_by_user_id, reopened_at, reopened_by_user_id, reopen_reason`
+
+func scanFinancialPeriod(row rowScanner) (models.FinancialPeriod, error) {
+	var p models.FinancialPeriod
+	err := row.Scan(&p.Month, &p.IsClosed, &p.ClosedAt, &p.ClosedByUserID, &p.ReopenedAt, &p.ReopenedByUserID, &p.ReopenReason)
+	return p, err
+}
+
+func getFinancialPeriod(q periodQuerier, month time.Time) (models.FinancialPeriod, error) {
+	p, err := scanFinancialPeriod(q.QueryRow(`SELECT `+financialPeriodColumns+` FROM financial_periods WHERE period_month = $1`, month.Format("2006-01-02")))
+	if err != nil {
+		log.Printf("getFinancialPeriod: ошибка чтения месяца %s: %v", month.Format("01.2006"), err)
+	}
+	return p, err
+}
+
+// GetFinancialPeriods возвращает состояние последних завершенных месяцев, начиная с прошлого.
+// Месяцы, которые ни разу не закрывались, возвращаются открытыми.
+func GetFinancialPeriods(count int) ([]models.FinancialPeriod, error) {
+	last := PeriodMonth(time.Now()).AddDate(0, -1, 0)
+	first := last.AddDate(0, -(count - 1), 0)
+	rows, err := DB.Query(`SELECT `+financialPeriodColumns+` FROM financial_periods WHERE period_month BETWEEN $1 AND $2`,
+		first.Format("2006-01-02"), last.Format("2006-01-02"))
+	if err != nil {
+		log.Printf("GetFinancialPeriods: ошибка получения периодов: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	known := make(map[string]models.FinancialPeriod)
+	for rows.Next() {
+		p, errScan := scanFinancialPeriod(rows)
+		if errScan != nil {
+			log.Printf("GetFinancialPeriods: ошибка сканирования периода: %v", errScan)
+			return nil, errScan
+		}
+		known[p.Month.Format("2006-01")] = p
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	periods := make([]models.FinancialPeriod, 0, count)
+	for month := last; !month.Before(first); month = month.AddDate(0, -1, 0) {
+		p := known[month.Format("2006-01")]
+		p.Month = month
+		periods = append(periods, p)
+	}
+	return periods, nil
+}
+
+// GetFinancialPeriodEvents возвращает журнал закрытий и открытий месяцев, новые записи первыми.
+func GetFinancialPeriodEvents(limit int) ([]models.FinancialPeriodEvent, error) {
+	rows, err := DB.Query(`
+        SELECT e.id, e.period_month, e.action, e.reason, e.user_id,
+               TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')), e.created_at
+        FROM financial_period_events e
+        LEFT JOIN users u ON u.id = e.user_id
+        ORDER BY e.created_at DESC, e.id DESC
+        LIMIT $1`, limit)
+	if err != nil {
+		log.Printf("GetFinancialPeriodEvents: ошибка получения журнала: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.FinancialPeriodEvent
+	for rows.Next() {
+		var e models.FinancialPeriodEvent
+		if err = rows.Scan(&e.ID, &e.Month, &e.Action, &e.Reason, &e.UserID, &e.UserName, &e.CreatedAt); err != nil {
+			log.Printf("GetFinancialPeriodEvents: ошибка сканирования записи: %v", err)
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
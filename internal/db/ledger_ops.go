@@ -0,0 +1,1042 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Главная книга: каждое денежное действие записывается сбалансированной проводкой
+// (сумма строк равна нулю, дебет - положительная сумма, кредит - отрицательная).
+// Проводки не редактируются: при изменении источника активная проводка сторнируется
+// и создается новая. Активная проводка - та, что не сторнирована и сама не является сторно.
+
+// ledgerLine - строка проводки до определения ID счета.
+type ledgerLine struct {
+	accountCode string
+	amount      float64
+}
+
+// ledgerSystemAccounts - общие счета компании: тип и название.
+var ledgerSystemAccounts = map[string][2]string{
+	constants.LEDGER_ACCOUNT_COMPANY_CASH:     {constants.LEDGER_ACCOUNT_TYPE_ASSET, "Касса компании"},
+	constants.LEDGER_ACCOUNT_ONLINE_RECEIPTS:  {constants.LEDGER_ACCOUNT_TYPE_ASSET, "Онлайн-поступления"},
+	constants.LEDGER_ACCOUNT_REVENUE:          {constants.LEDGER_ACCOUNT_TYPE_INCOME, "Выручка по заказам"},
+	constants.LEDGER_ACCOUNT_EXPENSE_FUEL:     {constants.LEDGER_ACCOUNT_TYPE_EXPENSE, "Топливо"},
+	constants.LEDGER_ACCOUNT_EXPENSE_OTHER:    {constants.LEDGER_ACCOUNT_TYPE_EXPENSE, "Прочие расходы"},
+	constants.LEDGER_ACCOUNT_EXPENSE_PAYROLL:  {constants.LEDGER_ACCOUNT_TYPE_EXPENSE, "Зарплата персонала"},
+	constants.LEDGER_ACCOUNT_EXPENSE_REFERRAL: {constants.LEDGER_ACCOUNT_TYPE_EXPENSE, "Реферальные бонусы"},
+	constants.LEDGER_ACCOUNT_EXPENSE_VEHICLE:  {constants.LEDGER_ACCOUNT_TYPE_EXPENSE, "Обслуживание автопарка"},
+}
+
+// ledgerPersonalAccounts - персональные счета: префикс -> тип и начало названия.
+var ledgerPersonalAccounts = map[string][2]string{
+	constants.LEDGER_ACCOUNT_DRIVER_CASH_PREFIX:        {constants.LEDGER_ACCOUNT_TYPE_ASSET, "Наличные у водителя"},
+	constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX:      {constants.LEDGER_ACCOUNT_TYPE_LIABILITY, "Зарплата к выплате"},
+	constants.LEDGER_ACCOUNT_REFERRAL_LIABILITY_PREFIX: {constants.LEDGER_ACCOUNT_TYPE_LIABILITY, "Реферальные бонусы к выплате"},
+}
+
+// LedgerUserAccountCode возвращает код персонального счета пользователя, например staff_payable:7.
+func LedgerUserAccountCode(prefix string, userID int64) string {
+	return fmt.Sprintf("%s:%d", prefix, userID)
+}
+
+// toKopecks переводит сумму в целые копейки, чтобы сравнивать суммы без погрешности float.
+func toKopecks(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// ensureLedgerAccountInTx возвращает ID счета по коду, создавая счет при первом обращении.
+func ensureLedgerAccountInTx(tx *sql.Tx, code string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(`SELECT id FROM ledger_accounts WHERE code = $1`, code).Scan(&id)
+	if err == nil {
+		return id, nil
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("ensureLedgerAccountInTx: ошибка поиска счета '%s': %v", code, err)
+		return 0, err
+	}
+
+	var accountType, name string
+	var userID sql.NullInt64
+	if system, ok := ledgerSystemAccounts[code]; ok {
+		accountType, name = system[0], system[1]
+	} else {
+		prefix, idPart, found := strings.Cut(code, ":")
+		personal, known := ledgerPersonalAccounts[prefix]
+		parsedID, errParse := strconv.ParseInt(idPart, 10, 64)
+		if !found || !known || errParse != nil {
+			return 0, fmt.Errorf("неизвестный счет главной книги '%s'", code)
+		}
+		userID = sql.NullInt64{Int64: parsedID, Valid: true}
+		var userName string
+		errName := tx.QueryRow(`SELECT COALESCE(NULLIF(TRIM(CONCAT(first_name, ' ', last_name)), ''), nickname, chat_id::text) FROM users WHERE id = $1`, parsedID).Scan(&userName)
+		if errName != nil {
+			log.Printf("ensureLedgerAccountInTx: не удалось получить имя пользователя ID %d для счета '%s': %v", parsedID, code, errName)
+			userName = fmt.Sprintf("ID %d", parsedID)
+		}
+		accountType, name = personal[0], fmt.Sprintf("%s: %s", personal[1], userName)
+	}
+
+	err = tx.QueryRow(`
+		INSERT INTO ledger_accounts (code, type, user_id, name, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (code) DO UPDATE SET code = EXCLUDED.code
+		RETURNING id`, code, accountType, userID, name).Scan(&id)
+	if err != nil {
+		log.Printf("ensureLedgerAccountInTx: ошибка создания счета '%s': %v", code, err)
+		return 0, err
+	}
+	log.Printf("ensureLedgerAccountInTx: создан счет главной книги '%s' (#%d).", code, id)
+	return id, nil
+}
+
+// resolvedPosting - строка проводки с определенным счетом и суммой в копейках.
+type resolvedPosting struct {
+	accountID int64
+	kopecks   int64
+}
+
+// sortPostings упорядочивает строки для сравнения проводок между собой.
+func sortPostings(postings []resolvedPosting) {
+	sort.Slice(postings, func(i, j int) bool {
+		if postings[i].accountID != postings[j].accountID {
+			return postings[i].accountID < postings[j].accountID
+		}
+		return postings[i].kopecks < postings[j].kopecks
+	})
+}
+
+// insertLedgerJournalInTx записывает проводку после проверки баланса.
+func insertLedgerJournalInTx(tx *sql.Tx, kind, sourceType string, sourceID int64, description string, postedAt time.Time, reversalOfID sql.NullInt64, postings []resolvedPosting) (int64, error) {
+	var total int64
+	for _, p := range postings {
+		total += p.kopecks
+	}
+	if total != 0 || len(postings) < 2 {
+		return 0, fmt.Errorf("несбалансированная проводка %s для %s #%d: сумма строк %.2f, строк %d", kind, sourceType, sourceID, float64(total)/100, len(postings))
+	}
+	if postedAt.IsZero() {
+		postedAt = time.Now()
+	}
+
+	var journalID int64
+	err := tx.QueryRow(`
+		INSERT INTO ledger_journal (kind, source_type, source_id, description, posted_at, reversal_of_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`, kind, sourceType, sourceID, description, postedAt, reversalOfID).Scan(&journalID)
+	if err != nil {
+		log.Printf("insertLedgerJournalInTx: ошибка создания проводки %s для %s #%d: %v", kind, sourceType, sourceID, err)
+		return 0, err
+	}
+	for _, p := range postings {
+		if _, err := tx.Exec(`INSERT INTO ledger_postings (journal_id, account_id, amount) VALUES ($1, $2, $3)`,
+			journalID, p.accountID, float64(p.kopecks)/100); err != nil {
+			log.Printf("insertLedgerJournalInTx: ошибка записи строки проводки #%d: %v", journalID, err)
+			return 0, err
+		}
+	}
+	return journalID, nil
+}
+
+// getJournalPostingsInTx возвращает строки проводки в копейках.
+func getJournalPostingsInTx(tx *sql.Tx, journalID int64) ([]resolvedPosting, error) {
+	rows, err := tx.Query(`SELECT account_id, amount FROM ledger_postings WHERE journal_id = $1`, journalID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var postings []resolvedPosting
+	for rows.Next() {
+		var accountID int64
+		var amount float64
+		if err := rows.Scan(&accountID, &amount); err != nil {
+			return nil, err
+		}
+		postings = append(postings, resolvedPosting{accountID: accountID, kopecks: toKopecks(amount)})
+	}
+	return postings, rows.Err()
+}
+
+// reverseLedgerJournalInTx сторнирует проводку: создает проводку с обратными знаками и связывает их.
+func reverseLedgerJournalInTx(tx *sql.Tx, journalID int64) error {
+	var kind, sourceType string
+	var sourceID int64
+	var description sql.NullString
+	err := tx.QueryRow(`SELECT kind, source_type, source_id, description FROM ledger_journal WHERE id = $1 AND reversed_by_id IS NULL AND reversal_of_id IS NULL`,
+		journalID).Scan(&kind, &sourceType, &sourceID, &description)
+	if err != nil {
+		log.Printf("reverseLedgerJournalInTx: проводка #%d не найдена или уже сторнирована: %v", journalID, err)
+		return err
+	}
+	postings, err := getJournalPostingsInTx(tx, journalID)
+	if err != nil {
+		log.Printf("reverseLedgerJournalInTx: ошибка чтения строк проводки #%d: %v", journalID, err)
+		return err
+	}
+	for i := range postings {
+		postings[i].kopecks = -postings[i].kopecks
+	}
+
+	reversalID, err := insertLedgerJournalInTx(tx, kind, sourceType, sourceID, "Сторно: "+description.String, time.Now(),
+		sql.NullInt64{Int64: journalID, Valid: true}, postings)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`UPDATE ledger_journal SET reversed_by_id = $1 WHERE id = $2`, reversalID, journalID); err != nil {
+		log.Printf("reverseLedgerJournalInTx: ошибка связи проводки #%d со сторно #%d: %v", journalID, reversalID, err)
+		return err
+	}
+	log.Printf("Проводка #%d (%s, %s #%d) сторнирована проводкой #%d.", journalID, kind, sourceType, sourceID, reversalID)
+	return nil
+}
+
+// syncLedgerEntryInTx приводит активную проводку вида kind по источнику к строкам lines.
+// Если строки совпадают с уже записанными, ничего не делает; иначе сторнирует старую проводку
+// и записывает новую. Пустой lines означает, что проводки быть не должно.
+// Повторный вызов с теми же данными безопасен, поэтому функция используется и для дозаписи истории.
+func syncLedgerEntryInTx(tx *sql.Tx, kind, sourceType string, sourceID int64, description string, postedAt time.Time, lines []ledgerLine) error {
+	var desired []resolvedPosting
+	for _, line := range lines {
+		kopecks := toKopecks(line.amount)
+		if kopecks == 0 {
+			continue
+		}
+		accountID, err := ensureLedgerAccountInTx(tx, line.accountCode)
+		if err != nil {
+			return err
+		}
+		desired = append(desired, resolvedPosting{accountID: accountID, kopecks: kopecks})
+	}
+	sortPostings(desired)
+
+	var activeID int64
+	err := tx.QueryRow(`
+		SELECT id FROM ledger_journal
+		WHERE source_type = $1 AND source_id = $2 AND kind = $3 AND reversal_of_id IS NULL AND reversed_by_id IS NULL
+		FOR UPDATE`, sourceType, sourceID, kind).Scan(&activeID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("syncLedgerEntryInTx: ошибка поиска проводки %s для %s #%d: %v", kind, sourceType, sourceID, err)
+		return err
+	}
+
+	if activeID != 0 {
+		current, errPostings := getJournalPostingsInTx(tx, activeID)
+		if errPostings != nil {
+			log.Printf("syncLedgerEntryInTx: ошибка чтения проводки #%d: %v", activeID, errPostings)
+			return errPostings
+		}
+		sortPostings(current)
+		if postingsEqual(current, desired) {
+			return nil
+		}
+		if err := reverseLedgerJournalInTx(tx, activeID); err != nil {
+			return err
+		}
+	}
+
+	if len(desired) == 0 {
+		return nil
+	}
+	journalID, err := insertLedgerJournalInTx(tx, kind, sourceType, sourceID, description, postedAt, sql.NullInt64{}, desired)
+	if err != nil {
+		return err
+	}
+	log.Printf("Проводка #%d (%s) записана для %s #%d.", journalID, kind, sourceType, sourceID)
+	return nil
+}
+
+func postingsEqual(a, b []resolvedPosting) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// settlementForLedgerColumns - поля отчета водителя, от которых зависят проводки.
+const settlementForLedgerColumns = `id, driver_user_id, settlement_timestamp, covered_orders_revenue, online_paid_revenue,
+	fuel_expense, other_expenses_json, loader_payments_json, driver_calculated_salary, amount_to_cashier,
+	paid_to_owner_at, driver_salary_paid_at, status, handed_over_amount`
+
+func scanSettlementForLedger(row rowScanner) (models.DriverSettlement, error) {
+	var s models.DriverSettlement
+	var otherExpensesJSON, loaderPaymentsJSON sql.NullString
+	err := row.Scan(&s.ID, &s.DriverUserID, &s.SettlementTimestamp, &s.CoveredOrdersRevenue, &s.OnlinePaidRevenue,
+		&s.FuelExpense, &otherExpensesJSON, &loaderPaymentsJSON, &s.DriverCalculatedSalary, &s.AmountToCashier,
+		&s.PaidToOwnerAt, &s.DriverSalaryPaidAt, &s.Status, &s.HandedOverAmount)
+	if err != nil {
+		return s, err
+	}
+	if otherExpensesJSON.Valid && otherExpensesJSON.String != "" && otherExpensesJSON.String != "null" {
+		if errUnmarshal := json.Unmarshal([]byte(otherExpensesJSON.String), &s.OtherExpenses); errUnmarshal != nil {
+			return s, fmt.Errorf("ошибка разбора other_expenses отчета #%d: %w", s.ID, errUnmarshal)
+		}
+	}
+	if loaderPaymentsJSON.Valid && loaderPaymentsJSON.String != "" && loaderPaymentsJSON.String != "null" {
+		if errUnmarshal := json.Unmarshal([]byte(loaderPaymentsJSON.String), &s.LoaderPayments); errUnmarshal != nil {
+			return s, fmt.Errorf("ошибка разбора loader_payments отчета #%d: %w", s.ID, errUnmarshal)
+		}
+	}
+	return s, nil
+}
+
+// settlementLedgerLines возвращает строки трех проводок отчета: начисления, сдачи денег в кассу и выплаты ЗП водителю.
+// Отклоненный отчет проводок не имеет.
+func settlementLedgerLines(s models.DriverSettlement) (accrual, handover, salaryPaid []ledgerLine) {
+	if s.Status == constants.SETTLEMENT_STATUS_REJECTED {
+		return nil, nil, nil
+	}
+	driverCash := LedgerUserAccountCode(constants.LEDGER_ACCOUNT_DRIVER_CASH_PREFIX, s.DriverUserID)
+	driverPayable := LedgerUserAccountCode(constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX, s.DriverUserID)
+
+	// Выручка: наличные остаются у водителя, оплаченное онлайн поступило через провайдера.
+	accrual = append(accrual,
+		ledgerLine{driverCash, s.CoveredOrdersRevenue - s.OnlinePaidRevenue},
+		ledgerLine{constants.LEDGER_ACCOUNT_ONLINE_RECEIPTS, s.OnlinePaidRevenue},
+		ledgerLine{constants.LEDGER_ACCOUNT_REVENUE, -s.CoveredOrdersRevenue},
+		ledgerLine{constants.LEDGER_ACCOUNT_EXPENSE_FUEL, s.FuelExpense},
+		ledgerLine{driverCash, -s.FuelExpense},
+	)
+	for _, oe := range s.OtherExpenses {
+		accrual = append(accrual,
+			ledgerLine{constants.LEDGER_ACCOUNT_EXPENSE_OTHER, oe.Amount},
+			ledgerLine{driverCash, -oe.Amount},
+		)
+	}
+	// Грузчикам водитель платит из собранных наличных: начисление и выплата в одной проводке.
+	for _, lp := range s.LoaderPayments {
+		if lp.LoaderUserID > 0 {
+			loaderPayable := LedgerUserAccountCode(constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX, lp.LoaderUserID)
+			accrual = append(accrual,
+				ledgerLine{constants.LEDGER_ACCOUNT_EXPENSE_PAYROLL, lp.Amount},
+				ledgerLine{loaderPayable, -lp.Amount},
+				ledgerLine{loaderPayable, lp.Amount},
+				ledgerLine{driverCash, -lp.Amount},
+			)
+		} else {
+			accrual = append(accrual,
+				ledgerLine{constants.LEDGER_ACCOUNT_EXPENSE_PAYROLL, lp.Amount},
+				ledgerLine{driverCash, -lp.Amount},
+			)
+		}
+	}
+	accrual = append(accrual,
+		ledgerLine{constants.LEDGER_ACCOUNT_EXPENSE_PAYROLL, s.DriverCalculatedSalary},
+		ledgerLine{driverPayable, -s.DriverCalculatedSalary},
+	)
+
+	// Отчеты, отмеченные сданными до учета фактических сдач, проводятся на всю сумму к сдаче.
+	// Деньги, сданные через cash_handovers, проводятся проводками самих сдач.
+	if s.PaidToOwnerAt.Valid && s.HandedOverAmount == 0 {
+		handover = []ledgerLine{
+			{constants.LEDGER_ACCOUNT_COMPANY_CASH, s.AmountToCashier},
+			{driverCash, -s.AmountToCashier},
+		}
+	}
+	if s.DriverSalaryPaidAt.Valid {
+		salaryPaid = []ledgerLine{
+			{driverPayable, s.DriverCalculatedSalary},
+			{driverCash, -s.DriverCalculatedSalary},
+		}
+	}
+	return accrual, handover, salaryPaid
+}
+
+// syncSettlementLedgerInTx приводит проводки отчета водителя к его текущему состоянию.
+// Вызывается после любого изменения отчета: создания, правки, отметок о сдаче денег и выплате ЗП.
+func syncSettlementLedgerInTx(tx *sql.Tx, settlementID int64) error {
+	s, err := scanSettlementForLedger(tx.QueryRow(`SELECT `+settlementForLedgerColumns+` FROM driver_settlements WHERE id = $1`, settlementID))
+	if err != nil {
+		log.Printf("syncSettlementLedgerInTx: ошибка чтения отчета #%d: %v", settlementID, err)
+		return err
+	}
+	accrual, handover, salaryPaid := settlementLedgerLines(s)
+
+	if err := syncLedgerEntryInTx(tx, constants.LEDGER_KIND_SETTLEMENT_ACCRUAL, constants.LEDGER_SOURCE_DRIVER_SETTLEMENT, s.ID,
+		fmt.Sprintf("Отчет водителя #%d", s.ID), s.SettlementTimestamp, accrual); err != nil {
+		return err
+	}
+	if err := syncLedgerEntryInTx(tx, constants.LEDGER_KIND_SETTLEMENT_HANDOVER, constants.LEDGER_SOURCE_DRIVER_SETTLEMENT, s.ID,
+		fmt.Sprintf("Сдача денег по отчету #%d", s.ID), s.PaidToOwnerAt.Time, handover); err != nil {
+		return err
+	}
+	if err := syncLedgerEntryInTx(tx, constants.LEDGER_KIND_DRIVER_SALARY_PAID, constants.LEDGER_SOURCE_DRIVER_SETTLEMENT, s.ID,
+		fmt.Sprintf("ЗП водителя по отчету #%d", s.ID), s.DriverSalaryPaidAt.Time, salaryPaid); err != nil {
+		return err
+	}
+	return syncCoveredExpensesLedgerInTx(tx, settlementID)
+}
+
+// syncCoveredExpensesLedgerInTx пересчитывает проводки расходов заказов отчета: заработок по заказу,
+// вошедшему в отчет, начисляет сам отчет.
+func syncCoveredExpensesLedgerInTx(tx *sql.Tx, settlementID int64) error {
+	var orderIDs pq.Int64Array
+	if err := tx.QueryRow(`SELECT covered_order_ids FROM driver_settlements WHERE id = $1`, settlementID).Scan(&orderIDs); err != nil {
+		log.Printf("syncCoveredExpensesLedgerInTx: ошибка получения заказов отчета #%d: %v", settlementID, err)
+		return err
+	}
+	return syncOrderExpensesLedgerInTx(tx, orderIDs...)
+}
+
+// syncOrderExpensesLedgerInTx пересчитывает проводки расходов заказов. Учет расходов зависит от статуса
+// заказа и от того, вошел ли он в отчет, поэтому пересчет нужен при каждом изменении того и другого.
+func syncOrderExpensesLedgerInTx(tx *sql.Tx, orderIDs ...int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	rows, err := tx.Query(`SELECT id FROM expenses WHERE order_id = ANY($1::bigint[]) ORDER BY id`, pq.Array(orderIDs))
+	if err != nil {
+		log.Printf("syncOrderExpensesLedgerInTx: ошибка получения расходов заказов %v: %v", orderIDs, err)
+		return err
+	}
+	var expenseIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		expenseIDs = append(expenseIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range expenseIDs {
+		if err := syncExpenseLedgerInTx(tx, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncOrderExpensesLedger пересчитывает проводки расходов заказов после смены их статуса вне транзакции.
+func SyncOrderExpensesLedger(orderIDs ...int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("SyncOrderExpensesLedger: ошибка начала транзакции: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+	if err = syncOrderExpensesLedgerInTx(tx, orderIDs...); err != nil {
+		log.Printf("SyncOrderExpensesLedger: ошибка пересчета проводок расходов заказов %v: %v", orderIDs, err)
+		return err
+	}
+	return tx.Commit()
+}
+
+// syncPayoutLedgerInTx записывает проводку выплаты сотруднику.
+// Если выплату сделал водитель из собранных наличных, уменьшаются его наличные, иначе - касса компании.
+func syncPayoutLedgerInTx(tx *sql.Tx, payoutID int64) error {
+	var userID, madeByUserID int64
+	var amount float64
+	var payoutDate time.Time
+	var madeByRole sql.NullString
+	var reversedAt sql.NullTime
+	var referralRequestID sql.NullInt64
+	err := tx.QueryRow(`
+		SELECT p.user_id, p.made_by_user_id, p.amount, p.payout_date, u.role, p.reversed_at, p.referral_payout_request_id
+		FROM payouts p LEFT JOIN users u ON u.id = p.made_by_user_id
+		WHERE p.id = $1`, payoutID).Scan(&userID, &madeByUserID, &amount, &payoutDate, &madeByRole, &reversedAt, &referralRequestID)
+	if err != nil {
+		log.Printf("syncPayoutLedgerInTx: ошибка чтения выплаты #%d: %v", payoutID, err)
+		return err
+	}
+	if reversedAt.Valid || referralRequestID.Valid {
+		// Отмененная выплата проводки не имеет: действующая проводка сторнируется.
+		// Выплата реферальных бонусов проводится по самому запросу (syncReferralPayoutRequestLedgerInTx).
+		return syncLedgerEntryInTx(tx, constants.LEDGER_KIND_STAFF_PAYOUT, constants.LEDGER_SOURCE_PAYOUT, payoutID,
+			fmt.Sprintf("Выплата #%d", payoutID), payoutDate, nil)
+	}
+
+	sourceAccount := constants.LEDGER_ACCOUNT_COMPANY_CASH
+	if madeByRole.String == constants.ROLE_DRIVER && madeByUserID != userID {
+		sourceAccount = LedgerUserAccountCode(constants.LEDGER_ACCOUNT_DRIVER_CASH_PREFIX, madeByUserID)
+	}
+	lines := []ledgerLine{
+		{LedgerUserAccountCode(constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX, userID), amount},
+		{sourceAccount, -amount},
+	}
+	return syncLedgerEntryInTx(tx, constants.LEDGER_KIND_STAFF_PAYOUT, constants.LEDGER_SOURCE_PAYOUT, payoutID,
+		fmt.Sprintf("Выплата #%d", payoutID), payoutDate, lines)
+}
+
+// syncReferralLedgerInTx записывает начисление реферального бонуса пригласившему.
+func syncReferralLedgerInTx(tx *sql.Tx, referralID int64) error {
+	var inviterID sql.NullInt64
+	var amount sql.NullFloat64
+	var createdAt, expiredAt sql.NullTime
+	var reviewStatus sql.NullString
+	err := tx.QueryRow(`SELECT inviter_id, amount, created_at, expired_at, review_status FROM referrals WHERE id = $1`, referralID).
+		Scan(&inviterID, &amount, &createdAt, &expiredAt, &reviewStatus)
+	if err != nil {
+		log.Printf("syncReferralLedgerInTx: ошибка чтения реферала #%d: %v", referralID, err)
+		return err
+	}
+	var lines []ledgerLine
+	// Сгоревший бонус больше не обязательство компании: начисление сторнируется.
+	// Удержанный на проверке или отклоненный бонус не проводится.
+	held := reviewStatus.Valid && reviewStatus.String != constants.REFERRAL_REVIEW_STATUS_APPROVED
+	if inviterID.Valid && !expiredAt.Valid && !held {
+		lines = []ledgerLine{
+			{constants.LEDGER_ACCOUNT_EXPENSE_REFERRAL, amount.Float64},
+			{LedgerUserAccountCode(constants.LEDGER_ACCOUNT_REFERRAL_LIABILITY_PREFIX, inviterID.Int64), -amount.Float64},
+		}
+	}
+	return syncLedgerEntryInTx(tx, constants.LEDGER_KIND_REFERRAL_ACCRUAL, constants.LEDGER_SOURCE_REFERRAL, referralID,
+		fmt.Sprintf("Реферальный бонус #%d", referralID), createdAt.Time, lines)
+}
+
+// syncReferralPayoutRequestLedgerInTx записывает выплату реферальных бонусов из кассы,
+// если запрос выполнен, и сторнирует ее, если запрос вернули из статуса "выполнен".
+func syncReferralPayoutRequestLedgerInTx(tx *sql.Tx, requestID int64) error {
+	var userID sql.NullInt64
+	var amount float64
+	var status string
+	var processedAt sql.NullTime
+	err := tx.QueryRow(`
+		SELECT u.id, r.amount, r.status, r.processed_at
+		FROM referral_payout_requests r LEFT JOIN users u ON u.chat_id = r.user_chat_id
+		WHERE r.id = $1`, requestID).Scan(&userID, &amount, &status, &processedAt)
+	if err != nil {
+		log.Printf("syncReferralPayoutRequestLedgerInTx: ошибка чтения запроса на выплату #%d: %v", requestID, err)
+		return err
+	}
+	var lines []ledgerLine
+	if status == constants.PAYOUT_REQUEST_STATUS_COMPLETED && userID.Valid {
+		lines = []ledgerLine{
+			{LedgerUserAccountCode(constants.LEDGER_ACCOUNT_REFERRAL_LIABILITY_PREFIX, userID.Int64), amount},
+			{constants.LEDGER_ACCOUNT_COMPANY_CASH, -amount},
+		}
+	}
+	return syncLedgerEntryInTx(tx, constants.LEDGER_KIND_REFERRAL_PAYOUT, constants.LEDGER_SOURCE_REFERRAL_PAYOUT_REQUEST, requestID,
+		fmt.Sprintf("Выплата реферальных бонусов по запросу #%d", requestID), processedAt.Time, lines)
+}
+
+// syncVehicleMaintenanceLedgerInTx записывает оплату обслуживания машины из кассы компании
+// и сторнирует ее, если запись об обслуживании отменена.
+func syncVehicleMaintenanceLedgerInTx(tx *sql.Tx, maintenanceID int64) error {
+	var cost float64
+	var plate, kind string
+	var performedOn time.Time
+	var canceledAt sql.NullTime
+	err := tx.QueryRow(`
+		SELECT m.cost, m.kind, m.performed_on, m.canceled_at, v.plate
+		FROM vehicle_maintenance m JOIN vehicles v ON v.id = m.vehicle_id
+		WHERE m.id = $1`, maintenanceID).Scan(&cost, &kind, &performedOn, &canceledAt, &plate)
+	if err != nil {
+		log.Printf("syncVehicleMaintenanceLedgerInTx: ошибка чтения записи об обслуживании #%d: %v", maintenanceID, err)
+		return err
+	}
+	var lines []ledgerLine
+	if !canceledAt.Valid {
+		lines = []ledgerLine{
+			{constants.LEDGER_ACCOUNT_EXPENSE_VEHICLE, cost},
+			{constants.LEDGER_ACCOUNT_COMPANY_CASH, -cost},
+		}
+	}
+	kindName := constants.VehicleMaintenanceKindDisplayMap[kind]
+	return syncLedgerEntryInTx(tx, constants.LEDGER_KIND_VEHICLE_MAINTENANCE, constants.LEDGER_SOURCE_VEHICLE_MAINTENANCE, maintenanceID,
+		fmt.Sprintf("%s: %s (запись #%d)", kindName, plate, maintenanceID), performedOn, lines)
+}
+
+// syncCashHandoverLedgerInTx записывает поступление фактически сданных наличных в кассу
+// и сторнирует его, если сдача отменена.
+func syncCashHandoverLedgerInTx(tx *sql.Tx, handoverID int64) error {
+	var driverUserID int64
+	var amount float64
+	var handedAt time.Time
+	var canceledAt sql.NullTime
+	err := tx.QueryRow(`SELECT driver_user_id, amount, handed_at, canceled_at FROM cash_handovers WHERE id = $1`, handoverID).Scan(
+		&driverUserID, &amount, &handedAt, &canceledAt)
+	if err != nil {
+		log.Printf("syncCashHandoverLedgerInTx: ошибка чтения сдачи #%d: %v", handoverID, err)
+		return err
+	}
+	var lines []ledgerLine
+	if !canceledAt.Valid {
+		lines = []ledgerLine{
+			{constants.LEDGER_ACCOUNT_COMPANY_CASH, amount},
+			{LedgerUserAccountCode(constants.LEDGER_ACCOUNT_DRIVER_CASH_PREFIX, driverUserID), -amount},
+		}
+	}
+	return syncLedgerEntryInTx(tx, constants.LEDGER_KIND_CASH_HANDOVER, constants.LEDGER_SOURCE_CASH_HANDOVER, handoverID,
+		fmt.Sprintf("Сдача наличных #%d", handoverID), handedAt, lines)
+}
+
+// syncExpenseLedgerInTx начисляет водителю и грузчикам заработок, записанный в расходах заказа (expenses).
+// Так учитывались заказы до появления отчетов водителей; если заказ вошел в отчет, начисление делает отчет,
+// и проводка расходов сторнируется, чтобы заработок не учитывался дважды.
+func syncExpenseLedgerInTx(tx *sql.Tx, expenseID int64) error {
+	var orderID int64
+	var driverID sql.NullInt64
+	var driverShare sql.NullFloat64
+	var loaderSalariesJSON []byte
+	var postedAt time.Time
+	var counts bool
+	err := tx.QueryRow(`
+		SELECT e.order_id, e.driver_id, e.driver_share, e.loader_salaries, COALESCE(e.created_at, o.created_at, NOW()),
+		       o.status IN ($2, $3, $4) AND NOT EXISTS (
+		           SELECT 1 FROM driver_settlements s
+		           WHERE e.order_id = ANY(s.covered_order_ids) AND s.status <> $5)
+		FROM expenses e JOIN orders o ON o.id = e.order_id
+		WHERE e.id = $1`, expenseID,
+		constants.STATUS_CALCULATED, constants.STATUS_SETTLED, constants.STATUS_COMPLETED, constants.SETTLEMENT_STATUS_REJECTED).Scan(
+		&orderID, &driverID, &driverShare, &loaderSalariesJSON, &postedAt, &counts)
+	if err != nil {
+		log.Printf("syncExpenseLedgerInTx: ошибка чтения расходов #%d: %v", expenseID, err)
+		return err
+	}
+
+	var lines []ledgerLine
+	if counts {
+		if driverID.Valid && driverShare.Float64 > 0 {
+			lines = append(lines,
+				ledgerLine{constants.LEDGER_ACCOUNT_EXPENSE_PAYROLL, driverShare.Float64},
+				ledgerLine{LedgerUserAccountCode(constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX, driverID.Int64), -driverShare.Float64},
+			)
+		}
+		var loaderSalaries map[string]models.LoaderSalaryDetail
+		if len(loaderSalariesJSON) > 0 {
+			if errUnmarshal := json.Unmarshal(loaderSalariesJSON, &loaderSalaries); errUnmarshal != nil {
+				log.Printf("syncExpenseLedgerInTx: ошибка демаршалинга loader_salaries расходов #%d: %v", expenseID, errUnmarshal)
+				return errUnmarshal
+			}
+		}
+		loaderIDs := make([]string, 0, len(loaderSalaries))
+		for loaderIDStr := range loaderSalaries {
+			loaderIDs = append(loaderIDs, loaderIDStr)
+		}
+		sort.Strings(loaderIDs)
+		for _, loaderIDStr := range loaderIDs {
+			loaderID, errID := strconv.ParseInt(loaderIDStr, 10, 64)
+			amount := loaderSalaries[loaderIDStr].Amount
+			if errID != nil || loaderID <= 0 || amount <= 0 {
+				continue
+			}
+			lines = append(lines,
+				ledgerLine{constants.LEDGER_ACCOUNT_EXPENSE_PAYROLL, amount},
+				ledgerLine{LedgerUserAccountCode(constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX, loaderID), -amount},
+			)
+		}
+	}
+	return syncLedgerEntryInTx(tx, constants.LEDGER_KIND_ORDER_EXPENSE, constants.LEDGER_SOURCE_EXPENSE, expenseID,
+		fmt.Sprintf("Расходы по заказу #%d", orderID), postedAt, lines)
+}
+
+// BackfillLedger дозаписывает проводки для отчетов, выплат, рефералов и расходов заказов, созданных до появления
+// главной книги, и исправляет проводки, разошедшиеся с источниками. Безопасна при повторном запуске;
+// выполняется один раз как разовая миграция (runDataMigrations).
+func BackfillLedger() error {
+	sources := []struct {
+		name  string
+		query string
+		sync  func(tx *sql.Tx, id int64) error
+	}{
+		{"driver_settlements", `SELECT id FROM driver_settlements ORDER BY id`, syncSettlementLedgerInTx},
+		{"payouts", `SELECT id FROM payouts ORDER BY id`, syncPayoutLedgerInTx},
+		{"referrals", `SELECT id FROM referrals ORDER BY id`, syncReferralLedgerInTx},
+		{"referral_payout_requests", `SELECT id FROM referral_payout_requests ORDER BY id`, syncReferralPayoutRequestLedgerInTx},
+		{"vehicle_maintenance", `SELECT id FROM vehicle_maintenance ORDER BY id`, syncVehicleMaintenanceLedgerInTx},
+		{"cash_handovers", `SELECT id FROM cash_handovers ORDER BY id`, syncCashHandoverLedgerInTx},
+		{"expenses", `SELECT id FROM expenses ORDER BY id`, syncExpenseLedgerInTx},
+	}
+
+	for _, source := range sources {
+		ids, err := queryInt64s(source.query)
+		if err != nil {
+			log.Printf("BackfillLedger: ошибка чтения %s: %v", source.name, err)
+			return err
+		}
+		for _, id := range ids {
+			tx, err := DB.Begin()
+			if err != nil {
+				log.Printf("BackfillLedger: ошибка начала транзакции: %v", err)
+				return err
+			}
+			if errSync := source.sync(tx, id); errSync != nil {
+				tx.Rollback()
+				log.Printf("BackfillLedger: не удалось провести %s #%d: %v", source.name, id, errSync)
+				continue
+			}
+			if err := tx.Commit(); err != nil {
+				log.Printf("BackfillLedger: ошибка коммита для %s #%d: %v", source.name, id, err)
+				return err
+			}
+		}
+		log.Printf("BackfillLedger: проверено записей %s: %d.", source.name, len(ids))
+	}
+	return nil
+}
+
+// queryInt64s выполняет запрос, возвращающий один столбец ID.
+func queryInt64s(query string, args ...interface{}) ([]int64, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetLedgerAccountBalance возвращает остаток счета по коду. Несуществующий счет имеет нулевой остаток.
+func GetLedgerAccountBalance(code string) (float64, error) {
+	var balance sql.NullFloat64
+	err := DB.QueryRow(`
+		SELECT SUM(p.amount) FROM ledger_postings p
+		JOIN ledger_accounts a ON a.id = p.account_id
+		WHERE a.code = $1`, code).Scan(&balance)
+	if err != nil {
+		log.Printf("GetLedgerAccountBalance: ошибка расчета остатка счета '%s': %v", code, err)
+		return 0, err
+	}
+	return balance.Float64, nil
+}
+
+// GetLedgerAccountTurnover возвращает обороты счета без учета сторнированных проводок:
+// debit - сумма дебетовых строк, credit - сумма кредитовых (положительным числом).
+// Сторно уменьшает оборот той стороны, которую отменяет.
+func GetLedgerAccountTurnover(code string) (debit float64, credit float64, err error) {
+	var debitSum, creditSum sql.NullFloat64
+	err = DB.QueryRow(`
+		SELECT
+			SUM(CASE WHEN j.reversal_of_id IS NULL THEN GREATEST(p.amount, 0) ELSE -GREATEST(-p.amount, 0) END),
+			SUM(CASE WHEN j.reversal_of_id IS NULL THEN GREATEST(-p.amount, 0) ELSE -GREATEST(p.amount, 0) END)
+		FROM ledger_postings p
+		JOIN ledger_journal j ON j.id = p.journal_id
+		JOIN ledger_accounts a ON a.id = p.account_id
+		WHERE a.code = $1`, code).Scan(&debitSum, &creditSum)
+	if err != nil {
+		log.Printf("GetLedgerAccountTurnover: ошибка расчета оборотов счета '%s': %v", code, err)
+		return 0, 0, err
+	}
+	return debitSum.Float64, creditSum.Float64, nil
+}
+
+// GetLedgerAccountBalances возвращает все счета главной книги с остатками.
+func GetLedgerAccountBalances() ([]models.LedgerAccount, error) {
+	rows, err := DB.Query(`
+		SELECT a.id, a.code, a.type, a.user_id, a.name, a.created_at, COALESCE(SUM(p.amount), 0)
+		FROM ledger_accounts a
+		LEFT JOIN ledger_postings p ON p.account_id = a.id
+		GROUP BY a.id
+		ORDER BY a.type, a.code`)
+	if err != nil {
+		log.Printf("GetLedgerAccountBalances: ошибка получения остатков: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []models.LedgerAccount
+	for rows.Next() {
+		var a models.LedgerAccount
+		if err := rows.Scan(&a.ID, &a.Code, &a.Type, &a.UserID, &a.Name, &a.CreatedAt, &a.Balance); err != nil {
+			log.Printf("GetLedgerAccountBalances: ошибка сканирования счета: %v", err)
+			return nil, err
+		}
+		accounts = append(accounts, a)
+	}
+	return accounts, rows.Err()
+}
+
+// GetLedgerAccountStatement возвращает выписку по счету за период [from, to) с нарастающим остатком.
+// Для счета без движений возвращается пустая выписка.
+func GetLedgerAccountStatement(code string, from, to time.Time) (models.LedgerStatement, error) {
+	statement := models.LedgerStatement{From: from, To: to}
+	statement.Account.Code = code
+
+	err := DB.QueryRow(`SELECT id, code, type, user_id, name, created_at FROM ledger_accounts WHERE code = $1`, code).Scan(
+		&statement.Account.ID, &statement.Account.Code, &statement.Account.Type, &statement.Account.UserID,
+		&statement.Account.Name, &statement.Account.CreatedAt)
+	if err == sql.ErrNoRows {
+		return statement, nil
+	}
+	if err != nil {
+		log.Printf("GetLedgerAccountStatement: ошибка получения счета '%s': %v", code, err)
+		return statement, err
+	}
+
+	var opening sql.NullFloat64
+	err = DB.QueryRow(`
+		SELECT SUM(p.amount) FROM ledger_postings p
+		JOIN ledger_journal j ON j.id = p.journal_id
+		WHERE p.account_id = $1 AND j.posted_at < $2`, statement.Account.ID, from).Scan(&opening)
+	if err != nil {
+		log.Printf("GetLedgerAccountStatement: ошибка расчета входящего остатка счета '%s': %v", code, err)
+		return statement, err
+	}
+	statement.OpeningBalance = opening.Float64
+
+	rows, err := DB.Query(`
+		SELECT j.id, j.posted_at, j.kind, j.source_type, j.source_id, COALESCE(j.description, ''), j.reversal_of_id IS NOT NULL, p.amount
+		FROM ledger_postings p
+		JOIN ledger_journal j ON j.id = p.journal_id
+		WHERE p.account_id = $1 AND j.posted_at >= $2 AND j.posted_at < $3
+		ORDER BY j.posted_at, j.id, p.id`, statement.Account.ID, from, to)
+	if err != nil {
+		log.Printf("GetLedgerAccountStatement: ошибка получения строк выписки счета '%s': %v", code, err)
+		return statement, err
+	}
+	defer rows.Close()
+
+	balance := statement.OpeningBalance
+	for rows.Next() {
+		var line models.LedgerStatementLine
+		if err := rows.Scan(&line.JournalID, &line.PostedAt, &line.Kind, &line.SourceType, &line.SourceID,
+			&line.Description, &line.IsReversal, &line.Amount); err != nil {
+			log.Printf("GetLedgerAccountStatement: ошибка сканирования строки выписки: %v", err)
+			return statement, err
+		}
+		balance += line.Amount
+		line.Balance = balance
+		statement.Lines = append(statement.Lines, line)
+	}
+	statement.ClosingBalance = balance
+	return statement, rows.Err()
+}
+
+// CheckLedgerIntegrity проверяет главную книгу: баланс каждой проводки и книги в целом,
+// наличие проводок для всех отчетов, выплат и рефералов, а также совпадение наличных
+// у водителей с тем, что следует из их отчетов и сделанных ими выплат.
+func CheckLedgerIntegrity() ([]models.LedgerIntegrityIssue, error) {
+	var issues []models.LedgerIntegrityIssue
+
+	rows, err := DB.Query(`
+		SELECT j.id, j.kind, j.source_type, j.source_id, COALESCE(SUM(p.amount), 0), COUNT(p.id)
+		FROM ledger_journal j
+		LEFT JOIN ledger_postings p ON p.journal_id = j.id
+		GROUP BY j.id
+		HAVING COALESCE(SUM(p.amount), 0) <> 0 OR COUNT(p.id) < 2`)
+	if err != nil {
+		log.Printf("CheckLedgerIntegrity: ошибка проверки баланса проводок: %v", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var journalID, sourceID int64
+		var kind, sourceType string
+		var sum float64
+		var count int
+		if err := rows.Scan(&journalID, &kind, &sourceType, &sourceID, &sum, &count); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		issues = append(issues, models.LedgerIntegrityIssue{
+			Kind:        "unbalanced_journal",
+			Description: fmt.Sprintf("Проводка #%d (%s, %s #%d) не сбалансирована: строк %d", journalID, kind, sourceType, sourceID, count),
+			Actual:      sum,
+		})
+	}
+	rows.Close()
+
+	var total sql.NullFloat64
+	if err := DB.QueryRow(`SELECT SUM(amount) FROM ledger_postings`).Scan(&total); err != nil {
+		log.Printf("CheckLedgerIntegrity: ошибка расчета оборотно-сальдовой ведомости: %v", err)
+		return nil, err
+	}
+	if toKopecks(total.Float64) != 0 {
+		issues = append(issues, models.LedgerIntegrityIssue{
+			Kind:        "trial_balance",
+			Description: "Сумма остатков всех счетов не равна нулю",
+			Actual:      total.Float64,
+		})
+	}
+
+	missingChecks := []struct {
+		description string
+		query       string
+		args        []interface{}
+	}{
+		{"Отчеты водителей без проводки начисления", `
+			SELECT COUNT(*) FROM driver_settlements s
+			WHERE s.status <> $1 AND NOT EXISTS (
+				SELECT 1 FROM ledger_journal j WHERE j.source_type = $2 AND j.source_id = s.id AND j.kind = $3
+				AND j.reversal_of_id IS NULL AND j.reversed_by_id IS NULL)`,
+			[]interface{}{constants.SETTLEMENT_STATUS_REJECTED, constants.LEDGER_SOURCE_DRIVER_SETTLEMENT, constants.LEDGER_KIND_SETTLEMENT_ACCRUAL}},
+		{"Выплаты без проводки", `
+			SELECT COUNT(*) FROM payouts p
+			WHERE p.amount <> 0 AND p.reversed_at IS NULL AND p.referral_payout_request_id IS NULL AND NOT EXISTS (
+				SELECT 1 FROM ledger_journal j WHERE j.source_type = $1 AND j.source_id = p.id AND j.kind = $2
+				AND j.reversal_of_id IS NULL AND j.reversed_by_id IS NULL)`,
+			[]interface{}{constants.LEDGER_SOURCE_PAYOUT, constants.LEDGER_KIND_STAFF_PAYOUT}},
+		{"Реферальные бонусы без проводки", `
+			SELECT COUNT(*) FROM referrals r
+			WHERE COALESCE(r.amount, 0) <> 0 AND r.inviter_id IS NOT NULL AND r.expired_at IS NULL
+			AND (r.review_status IS NULL OR r.review_status = 'approved') AND NOT EXISTS (
+				SELECT 1 FROM ledger_journal j WHERE j.source_type = $1 AND j.source_id = r.id AND j.kind = $2
+				AND j.reversal_of_id IS NULL AND j.reversed_by_id IS NULL)`,
+			[]interface{}{constants.LEDGER_SOURCE_REFERRAL, constants.LEDGER_KIND_REFERRAL_ACCRUAL}},
+		{"Расходы на обслуживание машин без проводки", `
+			SELECT COUNT(*) FROM vehicle_maintenance m
+			WHERE m.cost <> 0 AND m.canceled_at IS NULL AND NOT EXISTS (
+				SELECT 1 FROM ledger_journal j WHERE j.source_type = $1 AND j.source_id = m.id AND j.kind = $2
+				AND j.reversal_of_id IS NULL AND j.reversed_by_id IS NULL)`,
+			[]interface{}{constants.LEDGER_SOURCE_VEHICLE_MAINTENANCE, constants.LEDGER_KIND_VEHICLE_MAINTENANCE}},
+		{"Сдачи наличных без проводки", `
+			SELECT COUNT(*) FROM cash_handovers h
+			WHERE h.canceled_at IS NULL AND NOT EXISTS (
+				SELECT 1 FROM ledger_journal j WHERE j.source_type = $1 AND j.source_id = h.id AND j.kind = $2
+				AND j.reversal_of_id IS NULL AND j.reversed_by_id IS NULL)`,
+			[]interface{}{constants.LEDGER_SOURCE_CASH_HANDOVER, constants.LEDGER_KIND_CASH_HANDOVER}},
+	}
+	for _, check := range missingChecks {
+		var count int
+		if err := DB.QueryRow(check.query, check.args...).Scan(&count); err != nil {
+			log.Printf("CheckLedgerIntegrity: ошибка проверки '%s': %v", check.description, err)
+			return nil, err
+		}
+		if count > 0 {
+			issues = append(issues, models.LedgerIntegrityIssue{
+				Kind:        "missing_entry",
+				Description: fmt.Sprintf("%s: %d", check.description, count),
+				Actual:      float64(count),
+			})
+		}
+	}
+
+	driverIssues, err := checkDriverCashBalances()
+	if err != nil {
+		return nil, err
+	}
+	issues = append(issues, driverIssues...)
+
+	log.Printf("CheckLedgerIntegrity: проверка завершена, найдено расхождений: %d.", len(issues))
+	return issues, nil
+}
+
+// checkDriverCashBalances сверяет остатки счетов driver_cash с суммой, которую водитель должен держать
+// на руках по данным отчетов (за вычетом сданного в кассу и забранной ЗП), фактических сдач наличных
+// и выплат, сделанных им самим.
+func checkDriverCashBalances() ([]models.LedgerIntegrityIssue, error) {
+	expected := make(map[int64]int64)
+
+	rows, err := DB.Query(`SELECT ` + settlementForLedgerColumns + ` FROM driver_settlements`)
+	if err != nil {
+		log.Printf("checkDriverCashBalances: ошибка получения отчетов: %v", err)
+		return nil, err
+	}
+	for rows.Next() {
+		s, errScan := scanSettlementForLedger(rows)
+		if errScan != nil {
+			rows.Close()
+			log.Printf("checkDriverCashBalances: ошибка чтения отчета: %v", errScan)
+			return nil, errScan
+		}
+		driverCash := LedgerUserAccountCode(constants.LEDGER_ACCOUNT_DRIVER_CASH_PREFIX, s.DriverUserID)
+		accrual, handover, salaryPaid := settlementLedgerLines(s)
+		for _, group := range [][]ledgerLine{accrual, handover, salaryPaid} {
+			for _, line := range group {
+				if line.accountCode == driverCash {
+					expected[s.DriverUserID] += toKopecks(line.amount)
+				}
+			}
+		}
+	}
+	rows.Close()
+
+	payoutRows, err := DB.Query(`
+		SELECT p.made_by_user_id, SUM(p.amount)
+		FROM payouts p JOIN users u ON u.id = p.made_by_user_id
+		WHERE u.role = $1 AND p.made_by_user_id <> p.user_id AND p.reversed_at IS NULL
+		GROUP BY p.made_by_user_id`, constants.ROLE_DRIVER)
+	if err != nil {
+		log.Printf("checkDriverCashBalances: ошибка получения выплат водителей: %v", err)
+		return nil, err
+	}
+	for payoutRows.Next() {
+		var driverID int64
+		var sum float64
+		if err := payoutRows.Scan(&driverID, &sum); err != nil {
+			payoutRows.Close()
+			return nil, err
+		}
+		expected[driverID] -= toKopecks(sum)
+	}
+	payoutRows.Close()
+
+	handoverRows, err := DB.Query(`SELECT driver_user_id, SUM(amount) FROM cash_handovers WHERE canceled_at IS NULL GROUP BY driver_user_id`)
+	if err != nil {
+		log.Printf("checkDriverCashBalances: ошибка получения сдач наличных: %v", err)
+		return nil, err
+	}
+	for handoverRows.Next() {
+		var driverID int64
+		var sum float64
+		if err := handoverRows.Scan(&driverID, &sum); err != nil {
+			handoverRows.Close()
+			return nil, err
+		}
+		expected[driverID] -= toKopecks(sum)
+	}
+	handoverRows.Close()
+
+	actual := make(map[int64]int64)
+	balanceRows, err := DB.Query(`
+		SELECT a.user_id, COALESCE(SUM(p.amount), 0)
+		FROM ledger_accounts a LEFT JOIN ledger_postings p ON p.account_id = a.id
+		WHERE a.code LIKE $1 AND a.user_id IS NOT NULL
+		GROUP BY a.user_id`, constants.LEDGER_ACCOUNT_DRIVER_CASH_PREFIX+":%")
+	if err != nil {
+		log.Printf("checkDriverCashBalances: ошибка получения остатков наличных водителей: %v", err)
+		return nil, err
+	}
+	for balanceRows.Next() {
+		var driverID int64
+		var balance float64
+		if err := balanceRows.Scan(&driverID, &balance); err != nil {
+			balanceRows.Close()
+			return nil, err
+		}
+		actual[driverID] = toKopecks(balance)
+	}
+	balanceRows.Close()
+
+	driverIDs := make(map[int64]bool)
+	for id := range expected {
+		driverIDs[id] = true
+	}
+	for id := range actual {
+		driverIDs[id] = true
+	}
+	var sortedIDs []int64
+	for id := range driverIDs {
+		sortedIDs = append(sortedIDs, id)
+	}
+	sort.Slice(sortedIDs, func(i, j int) bool { return sortedIDs[i] < sortedIDs[j] })
+
+	var issues []models.LedgerIntegrityIssue
+	for _, id := range sortedIDs {
+		if expected[id] != actual[id] {
+			issues = append(issues, models.LedgerIntegrityIssue{
+				Kind:        "driver_cash_mismatch",
+				Description: fmt.Sprintf("Наличные водителя ID %d расходятся с отчетами и выплатами", id),
+				Expected:    float64(expected[id]) / 100,
+				Actual:      float64(actual[id]) / 100,
+			})
+		}
+	}
+	return issues, nil
+}
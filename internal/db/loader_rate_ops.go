@@ -0,0 +1,152 @@
+package db
+
+import (
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+const loaderPayRateColumns = `lr.id, lr.loader_user_id,
+        TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')) AS loader_name,
+        lr.category, lr.per_order, lr.per_hour, lr.per_floor, lr.per_ton,
+        lr.effective_from, lr.effective_to, lr.comment, lr.is_active, lr.created_by_user_id, lr.created_at`
+
+func scanLoaderPayRate(row rowScanner) (models.LoaderPayRate, error) {
+	var r models.LoaderPayRate
+	var loaderName sql.NullString
+	err := row.Scan(&r.ID, &r.LoaderUserID, &loaderName, &r.Category, &r.PerOrder, &r.PerHour, &r.PerFloor, &r.PerTon,
+		&r.EffectiveFrom, &r.EffectiveTo, &r.Comment, &r.IsActive, &r.CreatedByUserID, &r.CreatedAt)
+	r.LoaderName = loaderName.String
+	return r, err
+}
+
+// CreateLoaderPayRate добавляет тариф оплаты грузчика.
+func CreateLoaderPayRate(rate models.LoaderPayRate) (int64, error) {
+	if rate.PerOrder < 0 || rate.PerHour < 0 || rate.PerFloor < 0 || rate.PerTon < 0 {
+		return 0, fmt.Errorf("ставки тарифа не могут быть отрицательными")
+	}
+	if rate.PerOrder == 0 && rate.PerHour == 0 && rate.PerFloor == 0 && rate.PerTon == 0 {
+		return 0, fmt.Errorf("в тарифе должна быть указана хотя бы одна ставка")
+	}
+	if rate.EffectiveFrom.IsZero() {
+		return 0, fmt.Errorf("не указана дата начала действия тарифа")
+	}
+	if rate.EffectiveTo.Valid && rate.EffectiveTo.Time.Before(rate.EffectiveFrom) {
+		return 0, fmt.Errorf("дата окончания тарифа раньше даты начала")
+	}
+
+	var id int64
+	err := DB.QueryRow(`
+        INSERT INTO loader_pay_rates (loader_user_id, category, per_order, per_hour, per_floor, per_ton,
+                                      effective_from, effective_to, comment, created_by_user_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id`,
+		rate.LoaderUserID, rate.Category, rate.PerOrder, rate.PerHour, rate.PerFloor, rate.PerTon,
+		rate.EffectiveFrom, rate.EffectiveTo, rate.Comment, rate.CreatedByUserID,
+	).Scan(&id)
+	if err != nil {
+		log.Printf("CreateLoaderPayRate: ошибка добавления тарифа: %v", err)
+		return 0, err
+	}
+	log.Printf("CreateLoaderPayRate: добавлен тариф #%d (грузчик %v, категория %v)", id, rate.LoaderUserID, rate.Category)
+	return id, nil
+}
+
+// GetLoaderPayRates возвращает тарифы грузчиков; при activeOnly - только действующие записи.
+func GetLoaderPayRates(activeOnly bool) ([]models.LoaderPayRate, error) {
+	query := `SELECT ` + loaderPayRateColumns + `
+        FROM loader_pay_rates lr
+        LEFT JOIN users u ON u.id = lr.loader_user_id`
+	if activeOnly {
+		query += ` WHERE lr.is_active = TRUE`
+	}
+	query += ` ORDER BY lr.loader_user_id NULLS FIRST, lr.category NULLS FIRST, lr.effective_from DESC, lr.id DESC`
+	return queryLoaderPayRates("GetLoaderPayRates", query)
+}
+
+// DeactivateLoaderPayRate отключает тариф; сохраненные отчеты продолжают ссылаться на него.
+func DeactivateLoaderPayRate(rateID int64) error {
+	result, err := DB.Exec(`UPDATE loader_pay_rates SET is_active = FALSE WHERE id = $1 AND is_active = TRUE`, rateID)
+	if err != nil {
+		log.Printf("DeactivateLoaderPayRate: ошибка отключения тарифа #%d: %v", rateID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("действующий тариф #%d не найден", rateID)
+	}
+	log.Printf("DeactivateLoaderPayRate: тариф #%d отключен", rateID)
+	return nil
+}
+
+func queryLoaderPayRates(funcName, query string, args ...interface{}) ([]models.LoaderPayRate, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		log.Printf("%s: ошибка получения тарифов грузчиков: %v", funcName, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.LoaderPayRate
+	for rows.Next() {
+		r, errScan := scanLoaderPayRate(rows)
+		if errScan != nil {
+			log.Printf("%s: ошибка сканирования тарифа: %v", funcName, errScan)
+			return nil, errScan
+		}
+		rates = append(rates, r)
+	}
+	return rates, rows.Err()
+}
+
+// FindLoaderPayRate подбирает тариф грузчика на дату: грузчик+категория > грузчик > категория > общий,
+// при равной специфичности - с самой поздней датой начала. ok=false, если тарифа нет.
+func FindLoaderPayRate(loaderUserID int64, category string, date time.Time) (models.LoaderPayRate, bool, error) {
+	rates, err := queryLoaderPayRates("FindLoaderPayRate", `SELECT `+loaderPayRateColumns+`
+        FROM loader_pay_rates lr
+        LEFT JOIN users u ON u.id = lr.loader_user_id
+        WHERE lr.is_active = TRUE AND (lr.loader_user_id IS NULL OR lr.loader_user_id = $1)`, loaderUserID)
+	if err != nil {
+		return models.LoaderPayRate{}, false, err
+	}
+
+	var best models.LoaderPayRate
+	bestSpecificity := -1
+	for _, rate := range rates {
+		if rate.Category.Valid && rate.Category.String != category {
+			continue
+		}
+		if !ruleInEffect(rate.EffectiveFrom, rate.EffectiveTo, date) {
+			continue
+		}
+		specificity := ruleSpecificity(rate.LoaderUserID.Valid, rate.Category.Valid)
+		if specificity > bestSpecificity ||
+			(specificity == bestSpecificity && (rate.EffectiveFrom.After(best.EffectiveFrom) ||
+				(rate.EffectiveFrom.Equal(best.EffectiveFrom) && rate.ID > best.ID))) {
+			best = rate
+			bestSpecificity = specificity
+		}
+	}
+	return best, bestSpecificity >= 0, nil
+}
+
+// SuggestLoaderPayment считает сумму по тарифу для строки отчета и записывает в нее тариф и расчетную сумму.
+// Если водитель не указывал причину отклонения, сумма приравнивается к расчетной.
+func SuggestLoaderPayment(detail *models.LoaderPaymentDetail, date time.Time) error {
+	rate, found, err := FindLoaderPayRate(detail.LoaderUserID, detail.Category, date)
+	if err != nil {
+		return err
+	}
+	if !found {
+		detail.RateID = 0
+		detail.SuggestedAmount = 0
+		return nil
+	}
+	detail.RateID = rate.ID
+	detail.SuggestedAmount = rate.Calculate(detail.OrdersCount, detail.Hours, detail.Floors, detail.Tons)
+	if detail.OverrideReason == "" {
+		detail.Amount = detail.SuggestedAmount
+	}
+	return nil
+}
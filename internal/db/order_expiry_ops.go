@@ -0,0 +1,65 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"log"
+	"time"
+)
+
+// staleOrderFilter отбирает заказы в статусе $1, не менявшиеся дольше $2 секунд.
+// Заказы с действующим предложением вариантов стоимости не трогаем: у него свой срок ответа.
+// Заказы регулярных выездов тоже не трогаем: они создаются заранее и ждут оплаты до даты выезда.
+const staleOrderFilter = `o.status = $1 AND o.updated_at <= NOW() - make_interval(secs => $2)
+	AND NOT EXISTS (SELECT 1 FROM order_quotes q WHERE q.order_id = o.id AND q.status = '` + constants.QUOTE_STATUS_PENDING + `')
+	AND NOT EXISTS (SELECT 1 FROM recurring_order_occurrences ro WHERE ro.order_id = o.id)`
+
+func queryStaleOrders(funcName, query string, args ...interface{}) ([]models.StaleOrder, error) {
+	rows, err := DB.Query(`SELECT o.id, o.user_chat_id, o.status, o.cost, o.updated_at, o.expiry_reminder_at
+	                       FROM orders o WHERE `+query+` ORDER BY o.updated_at`, args...)
+	if err != nil {
+		log.Printf("%s: ошибка выборки заказов: %v", funcName, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.StaleOrder
+	for rows.Next() {
+		var o models.StaleOrder
+		if err := rows.Scan(&o.OrderID, &o.UserChatID, &o.Status, &o.Cost, &o.LastActivityAt, &o.ReminderAt); err != nil {
+			log.Printf("%s: ошибка сканирования заказа: %v", funcName, err)
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, rows.Err()
+}
+
+// GetOrdersForExpiryReminder возвращает заказы в статусе status, до автоотмены которых осталось не больше remindBefore
+// и о которых клиенту еще не напоминали после последнего изменения заказа.
+func GetOrdersForExpiryReminder(status string, deadline, remindBefore time.Duration) ([]models.StaleOrder, error) {
+	return queryStaleOrders("GetOrdersForExpiryReminder",
+		staleOrderFilter+` AND (o.expiry_reminder_at IS NULL OR o.expiry_reminder_at < o.updated_at)`,
+		status, (deadline - remindBefore).Seconds())
+}
+
+// MarkOrderExpiryReminderSent запоминает время напоминания. updated_at не меняется,
+// чтобы напоминание не продлевало срок заказа.
+func MarkOrderExpiryReminderSent(orderID int64) error {
+	_, err := DB.Exec(`UPDATE orders SET expiry_reminder_at = NOW() WHERE id = $1`, orderID)
+	if err != nil {
+		log.Printf("MarkOrderExpiryReminderSent: ошибка отметки напоминания для заказа #%d: %v", orderID, err)
+	}
+	return err
+}
+
+// GetExpiredStaleOrders возвращает заказы в статусе status, срок которых истек. Если напоминания включены
+// (remindBefore > 0), заказ считается истекшим не раньше чем через remindBefore после напоминания клиенту.
+func GetExpiredStaleOrders(status string, deadline, remindBefore time.Duration) ([]models.StaleOrder, error) {
+	if remindBefore <= 0 {
+		return queryStaleOrders("GetExpiredStaleOrders", staleOrderFilter, status, deadline.Seconds())
+	}
+	return queryStaleOrders("GetExpiredStaleOrders",
+		staleOrderFilter+` AND o.expiry_reminder_at >= o.updated_at AND o.expiry_reminder_at <= NOW() - make_interval(secs => $3)`,
+		status, deadline.Seconds(), remindBefore.Seconds())
+}
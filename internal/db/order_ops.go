@@ -80,9 +80,10 @@ func CreateInitialOrder(orderData models.Order) (int64, error) {
             user_id, user_chat_id, category, subcategory, name,
             photos, videos, date, time, phone, address,
             description, status, cost, payment,
-            latitude, longitude, created_at, updated_at, is_driver_settled
+            latitude, longitude, created_at, updated_at, is_driver_settled,
+            volume_m3, tonnage_t, floor, has_elevator, needs_loaders
         )
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NOW(), NOW(), FALSE)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NOW(), NOW(), FALSE, $18, $19, $20, $21, $22)
         RETURNING id`
 
 	err = tx.QueryRow(query,
@@ -90,6 +91,7 @@ func CreateInitialOrder(orderData models.Order) (int64, error) {
 		pq.Array(orderData.Photos), pq.Array(orderData.Videos), parsedDate, timeVal,
 		orderData.Phone, orderData.Address, orderData.Description, constants.STATUS_DRAFT,
 		orderData.Cost, orderData.Payment, orderData.Latitude, orderData.Longitude,
+		orderData.VolumeM3, orderData.TonnageT, orderData.Floor, orderData.HasElevator, orderData.NeedsLoaders,
 	).Scan(&id)
 
 	if err != nil {
@@ -120,13 +122,17 @@ func GetOrderByID(orderID int) (models.Order, error) {
         SELECT o.id, o.user_id, o.user_chat_id, o.category, o.subcategory, o.name,
                o.photos, o.videos, o.date, o.time, o.phone, o.address,
                o.description, o.status, o.reason, o.cost, o.payment,
-               o.latitude, o.longitude, o.created_at, o.updated_at, o.is_driver_settled
+               o.latitude, o.longitude, o.created_at, o.updated_at, o.is_driver_settled,
+               o.volume_m3, o.tonnage_t, o.floor, o.has_elevator, o.needs_loaders,
+               o.required_drivers, o.required_loaders
         FROM orders o
         WHERE o.id = $1`, orderID).Scan(
 		&order.ID, &order.UserID, &order.UserChatID, &order.Category, &order.Subcategory, &order.Name,
 		pq.Array(&order.Photos), pq.Array(&order.Videos), &dbDate, &dbTime, &order.Phone, &order.Address,
 		&order.Description, &order.Status, &order.Reason, &order.Cost, &order.Payment,
 		&order.Latitude, &order.Longitude, &dbCreatedAt, &dbUpdatedAt, &order.IsDriverSettled, // Сканируем новое поле
+		&order.VolumeM3, &order.TonnageT, &order.Floor, &order.HasElevator, &order.NeedsLoaders,
+		&order.RequiredDrivers, &order.RequiredLoaders,
 	)
 
 	if err != nil {
@@ -190,6 +196,10 @@ func UpdateOrderStatus(orderID int64, status string) error {
 		return err
 	}
 	log.Printf("Статус заказа #%d обновлен на %s", orderID, status)
+	if status == constants.STATUS_CANCELED {
+		closeCanceledOrderQuotes(DB, orderID)
+	}
+	SyncOrderExpensesLedger(orderID)
 	return nil
 }
 
@@ -205,17 +215,29 @@ func UpdateOrderStatusInTx(tx *sql.Tx, orderID int64, status string) error {
 		return fmt.Errorf("заказ с ID %d не найден для обновления статуса в транзакции", orderID)
 	}
 	log.Printf("Статус заказа #%d обновлен на %s в транзакции.", orderID, status)
-	return nil
+	if status == constants.STATUS_CANCELED {
+		if err = closeCanceledOrderQuotes(tx, orderID); err != nil {
+			return err
+		}
+	}
+	return syncOrderExpensesLedgerInTx(tx, orderID)
 }
 
 // UpdateOrderCostAndStatus обновляет стоимость и статус заказа.
 func UpdateOrderCostAndStatus(orderID int64, cost float64, status string) error {
+	if err := ensureOrderPeriodOpen(DB, orderID); err != nil {
+		return err
+	}
 	_, err := DB.Exec("UPDATE orders SET cost=$1, status=$2, updated_at=NOW() WHERE id=$3", cost, status, orderID)
 	if err != nil {
 		log.Printf("UpdateOrderCostAndStatus: ошибка обновления стоимости/статуса заказа #%d: %v", orderID, err)
 		return err
 	}
 	log.Printf("Стоимость (%.0f) и статус (%s) заказа #%d обновлены.", cost, status, orderID)
+	if status == constants.STATUS_CANCELED {
+		closeCanceledOrderQuotes(DB, orderID)
+	}
+	SyncOrderExpensesLedger(orderID)
 	return nil
 }
 
@@ -227,6 +249,10 @@ func UpdateOrderReasonAndStatus(orderID int64, reason string, status string) err
 		return err
 	}
 	log.Printf("Причина (%s) и статус (%s) заказа #%d обновлены.", reason, status, orderID)
+	if status == constants.STATUS_CANCELED {
+		closeCanceledOrderQuotes(DB, orderID)
+	}
+	SyncOrderExpensesLedger(orderID)
 	return nil
 }
 
@@ -532,6 +558,11 @@ func UpdateOrderField(orderID int64, field string, value interface{}) error {
 	if !allowedFields[field] {
 		return fmt.Errorf("обновление поля '%s' не разрешено через UpdateOrderField", field)
 	}
+	if field == "cost" {
+		if err := ensureOrderPeriodOpen(DB, orderID); err != nil {
+			return err
+		}
+	}
 	if field == "date" {
 		if dateStr, ok := value.(string); ok && dateStr != "" {
 			parsedDate, errDate := time.ParseInLocation("2006-01-02", dateStr, time.Local)
@@ -760,6 +791,18 @@ func UpdateOrderAddress(orderID int64, address string, latitude, longitude float
 	return nil
 }
 
+// UpdateOrderDetails обновляет объем и доступ заказа (объем, вес, этаж, лифт, грузчики) из details.
+func UpdateOrderDetails(orderID int64, details models.Order) error {
+	_, err := DB.Exec(`UPDATE orders SET volume_m3=$1, tonnage_t=$2, floor=$3, has_elevator=$4, needs_loaders=$5, updated_at=NOW()
+	                   WHERE id=$6`,
+		details.VolumeM3, details.TonnageT, details.Floor, details.HasElevator, details.NeedsLoaders, orderID)
+	if err != nil {
+		log.Printf("UpdateOrderDetails: ошибка обновления объема и доступа для заказа #%d: %v", orderID, err)
+		return err
+	}
+	return nil
+}
+
 // GetOrderStatusAndClientChatID получает статус заказа и user_chat_id клиента по ID заказа.
 func GetOrderStatusAndClientChatID(orderID int64) (string, int64, error) {
 	var status string
@@ -783,6 +826,10 @@ func UpdateOrderStatusAndReason(orderID int64, status string, reason sql.NullStr
 		return err
 	}
 	log.Printf("Статус (%s) и причина для заказа #%d обновлены.", status, orderID)
+	if status == constants.STATUS_CANCELED {
+		closeCanceledOrderQuotes(DB, orderID)
+	}
+	SyncOrderExpensesLedger(orderID)
 	return nil
 }
 
@@ -932,6 +979,9 @@ func CancelUserActiveOrdersInTx(tx *sql.Tx, userID int64, reason string) error {
 		log.Printf("CancelUserActiveOrdersInTx: ВНИМАНИЕ! Ожидалась отмена %d заказов, но отменено %d. Возможна гонка состояний или не все ID были корректны.", len(orderIDsToCancel), rowsAffected)
 	}
 
+	if err = closeCanceledOrderQuotes(tx, orderIDsToCancel...); err != nil {
+		return fmt.Errorf("ошибка закрытия предложений отмененных заказов: %w", err)
+	}
 	return nil
 }
 
@@ -976,9 +1026,10 @@ func CreateFullOrder(orderData models.Order) (int64, error) {
             user_id, user_chat_id, category, subcategory, name,
             photos, videos, date, time, phone, address,
             description, status, cost, payment,
-            latitude, longitude, created_at, updated_at, is_driver_settled
+            latitude, longitude, created_at, updated_at, is_driver_settled,
+            volume_m3, tonnage_t, floor, has_elevator, needs_loaders
         )
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NOW(), NOW(), FALSE)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NOW(), NOW(), FALSE, $18, $19, $20, $21, $22)
         RETURNING id`
 
 	err = tx.QueryRow(query,
@@ -986,6 +1037,7 @@ func CreateFullOrder(orderData models.Order) (int64, error) {
 		pq.Array(orderData.Photos), pq.Array(orderData.Videos), parsedDate, orderData.Time,
 		orderData.Phone, orderData.Address, orderData.Description, orderData.Status,
 		orderData.Cost, orderData.Payment, orderData.Latitude, orderData.Longitude,
+		orderData.VolumeM3, orderData.TonnageT, orderData.Floor, orderData.HasElevator, orderData.NeedsLoaders,
 	).Scan(&id)
 
 	if err != nil {
@@ -1138,3 +1190,22 @@ func GetAllOrders() ([]models.Order, error) {
 
 	return orders, nil
 }
+
+// UpdateOrderStatusIfCurrent переводит заказ в статус newStatus, только если он сейчас в статусе expectedStatus.
+// Возвращает true, если статус был изменен. Защищает от гонки между вебхуком и фоновой сверкой платежей.
+func UpdateOrderStatusIfCurrent(orderID int64, expectedStatus, newStatus string) (bool, error) {
+	result, err := DB.Exec("UPDATE orders SET status=$1, updated_at=NOW() WHERE id=$2 AND status=$3", newStatus, orderID, expectedStatus)
+	if err != nil {
+		log.Printf("UpdateOrderStatusIfCurrent: ошибка обновления статуса заказа #%d (%s -> %s): %v", orderID, expectedStatus, newStatus, err)
+		return false, err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected > 0 {
+		log.Printf("Статус заказа #%d обновлен: %s -> %s", orderID, expectedStatus, newStatus)
+		if newStatus == constants.STATUS_CANCELED {
+			closeCanceledOrderQuotes(DB, orderID)
+		}
+		SyncOrderExpensesLedger(orderID)
+	}
+	return rowsAffected > 0, nil
+}
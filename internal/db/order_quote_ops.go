@@ -0,0 +1,292 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrQuoteNotPending - предложение уже принято, отклонено, заменено, истекло или заказ отменен.
+var ErrQuoteNotPending = fmt.Errorf("предложение больше не действует")
+
+const orderQuoteColumns = `q.id, q.order_id, q.status, q.expires_at, q.chosen_option_id, q.answered_at,
+	q.created_by_user_id, q.created_at`
+
+func scanOrderQuote(row rowScanner) (models.OrderQuote, error) {
+	var q models.OrderQuote
+	err := row.Scan(&q.ID, &q.OrderID, &q.Status, &q.ExpiresAt, &q.ChosenOptionID, &q.AnsweredAt,
+		&q.CreatedByUserID, &q.CreatedAt)
+	return q, err
+}
+
+// loadOrderQuoteOptions подгружает варианты предложения в порядке, заданном оператором.
+func loadOrderQuoteOptions(q cashQuerier, quote *models.OrderQuote) error {
+	rows, err := q.Query(`SELECT id, quote_id, position, title, cost, drivers, loaders
+	                      FROM order_quote_options WHERE quote_id = $1 ORDER BY position, id`, quote.ID)
+	if err != nil {
+		log.Printf("loadOrderQuoteOptions: ошибка чтения вариантов предложения #%d: %v", quote.ID, err)
+		return err
+	}
+	defer rows.Close()
+	quote.Options = nil
+	for rows.Next() {
+		var o models.OrderQuoteOption
+		if err := rows.Scan(&o.ID, &o.QuoteID, &o.Position, &o.Title, &o.Cost, &o.Drivers, &o.Loaders); err != nil {
+			return err
+		}
+		quote.Options = append(quote.Options, o)
+	}
+	return rows.Err()
+}
+
+// CreateOrderQuote отправляет заказу новое предложение с вариантами стоимости: прежнее ожидающее
+// предложение заменяется, стоимость заказа сбрасывается до выбора клиента, заказ ждет подтверждения.
+func CreateOrderQuote(orderID int64, options []models.OrderQuoteOption, expiresAt time.Time, createdByUserID int64) (models.OrderQuote, error) {
+	if len(options) < constants.MinQuoteOptions || len(options) > constants.MaxQuoteOptions {
+		return models.OrderQuote{}, fmt.Errorf("нужно от %d до %d вариантов", constants.MinQuoteOptions, constants.MaxQuoteOptions)
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CreateOrderQuote: ошибка начала транзакции для заказа #%d: %v", orderID, err)
+		return models.OrderQuote{}, err
+	}
+	defer tx.Rollback()
+
+	if err = ensureOrderPeriodOpen(tx, orderID); err != nil {
+		return models.OrderQuote{}, err
+	}
+	if _, err = tx.Exec(`UPDATE order_quotes SET status = $1, answered_at = NOW() WHERE order_id = $2 AND status = $3`,
+		constants.QUOTE_STATUS_SUPERSEDED, orderID, constants.QUOTE_STATUS_PENDING); err != nil {
+		log.Printf("CreateOrderQuote: ошибка замены прежнего предложения для заказа #%d: %v", orderID, err)
+		return models.OrderQuote{}, err
+	}
+
+	quote := models.OrderQuote{OrderID: orderID, Status: constants.QUOTE_STATUS_PENDING, ExpiresAt: expiresAt}
+	if createdByUserID > 0 {
+		quote.CreatedByUserID = sql.NullInt64{Int64: createdByUserID, Valid: true}
+	}
+	err = tx.QueryRow(`INSERT INTO order_quotes (order_id, status, expires_at, created_by_user_id)
+	                   VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		orderID, quote.Status, expiresAt, quote.CreatedByUserID).Scan(&quote.ID, &quote.CreatedAt)
+	if err != nil {
+		log.Printf("CreateOrderQuote: ошибка создания предложения для заказа #%d: %v", orderID, err)
+		return models.OrderQuote{}, err
+	}
+	for i, option := range options {
+		option.QuoteID = quote.ID
+		option.Position = i + 1
+		err = tx.QueryRow(`INSERT INTO order_quote_options (quote_id, position, title, cost, drivers, loaders)
+		                   VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			option.QuoteID, option.Position, option.Title, option.Cost, option.Drivers, option.Loaders).Scan(&option.ID)
+		if err != nil {
+			log.Printf("CreateOrderQuote: ошибка сохранения варианта '%s' для заказа #%d: %v", option.Title, orderID, err)
+			return models.OrderQuote{}, err
+		}
+		quote.Options = append(quote.Options, option)
+	}
+
+	if _, err = tx.Exec(`UPDATE orders SET cost = NULL, required_drivers = NULL, required_loaders = NULL, status = $1, updated_at = NOW()
+	                     WHERE id = $2`, constants.STATUS_AWAITING_CONFIRMATION, orderID); err != nil {
+		log.Printf("CreateOrderQuote: ошибка перевода заказа #%d в ожидание подтверждения: %v", orderID, err)
+		return models.OrderQuote{}, err
+	}
+	if _, err = tx.Exec(`DELETE FROM order_cost_items WHERE order_id = $1`, orderID); err != nil {
+		log.Printf("CreateOrderQuote: ошибка сброса расшифровки стоимости заказа #%d: %v", orderID, err)
+		return models.OrderQuote{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CreateOrderQuote: ошибка фиксации транзакции для заказа #%d: %v", orderID, err)
+		return models.OrderQuote{}, err
+	}
+	log.Printf("CreateOrderQuote: заказу #%d отправлено предложение #%d (%d вариантов) до %s", orderID, quote.ID, len(quote.Options), expiresAt.Format(time.RFC3339))
+	return quote, nil
+}
+
+// GetPendingOrderQuote возвращает ожидающее ответа клиента предложение заказа (sql.ErrNoRows - такого нет).
+func GetPendingOrderQuote(orderID int64) (models.OrderQuote, error) {
+	quote, err := scanOrderQuote(DB.QueryRow(`SELECT `+orderQuoteColumns+` FROM order_quotes q
+	                                          WHERE q.order_id = $1 AND q.status = $2 ORDER BY q.id DESC LIMIT 1`,
+		orderID, constants.QUOTE_STATUS_PENDING))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("GetPendingOrderQuote: ошибка чтения предложения заказа #%d: %v", orderID, err)
+		}
+		return quote, err
+	}
+	return quote, loadOrderQuoteOptions(DB, &quote)
+}
+
+// GetOrderIDByQuoteOption возвращает заказ, к предложению которого относится вариант.
+func GetOrderIDByQuoteOption(optionID int64) (int64, error) {
+	var orderID int64
+	err := DB.QueryRow(`SELECT q.order_id FROM order_quote_options o JOIN order_quotes q ON q.id = o.quote_id WHERE o.id = $1`,
+		optionID).Scan(&orderID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("GetOrderIDByQuoteOption: ошибка чтения варианта #%d: %v", optionID, err)
+	}
+	return orderID, err
+}
+
+// AcceptOrderQuoteOption фиксирует выбор клиента: стоимость и состав исполнителей варианта переносятся в заказ,
+// название варианта становится позицией расшифровки стоимости (и чека). Заказ остается в ожидании
+// подтверждения - дальше он идет тем же путем, что и после согласия с единственной стоимостью.
+func AcceptOrderQuoteOption(optionID int64) (models.OrderQuote, models.OrderQuoteOption, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка начала транзакции для варианта #%d: %v", optionID, err)
+		return models.OrderQuote{}, models.OrderQuoteOption{}, err
+	}
+	defer tx.Rollback()
+
+	var option models.OrderQuoteOption
+	err = tx.QueryRow(`SELECT id, quote_id, position, title, cost, drivers, loaders FROM order_quote_options WHERE id = $1`,
+		optionID).Scan(&option.ID, &option.QuoteID, &option.Position, &option.Title, &option.Cost, &option.Drivers, &option.Loaders)
+	if err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка чтения варианта #%d: %v", optionID, err)
+		return models.OrderQuote{}, option, err
+	}
+	quote, err := scanOrderQuote(tx.QueryRow(`SELECT `+orderQuoteColumns+` FROM order_quotes q WHERE q.id = $1 FOR UPDATE`, option.QuoteID))
+	if err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка чтения предложения #%d: %v", option.QuoteID, err)
+		return quote, option, err
+	}
+	if quote.Status != constants.QUOTE_STATUS_PENDING || !quote.ExpiresAt.After(time.Now()) {
+		return quote, option, ErrQuoteNotPending
+	}
+	// Заказ могли отменить или перевести дальше, пока клиент выбирал вариант
+	var orderStatus string
+	if err = tx.QueryRow(`SELECT status FROM orders WHERE id = $1 FOR UPDATE`, quote.OrderID).Scan(&orderStatus); err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка чтения заказа #%d: %v", quote.OrderID, err)
+		return quote, option, err
+	}
+	if orderStatus != constants.STATUS_AWAITING_CONFIRMATION {
+		return quote, option, ErrQuoteNotPending
+	}
+	if err = ensureOrderPeriodOpen(tx, quote.OrderID); err != nil {
+		return quote, option, err
+	}
+
+	if _, err = tx.Exec(`UPDATE order_quotes SET status = $1, chosen_option_id = $2, answered_at = NOW() WHERE id = $3`,
+		constants.QUOTE_STATUS_ACCEPTED, option.ID, quote.ID); err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка принятия предложения #%d: %v", quote.ID, err)
+		return quote, option, err
+	}
+	if _, err = tx.Exec(`UPDATE orders SET cost = $1, required_drivers = $2, required_loaders = $3, updated_at = NOW() WHERE id = $4`,
+		option.Cost, option.Drivers, option.Loaders, quote.OrderID); err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка переноса варианта #%d в заказ #%d: %v", option.ID, quote.OrderID, err)
+		return quote, option, err
+	}
+	if _, err = tx.Exec(`DELETE FROM order_cost_items WHERE order_id = $1`, quote.OrderID); err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка сброса расшифровки стоимости заказа #%d: %v", quote.OrderID, err)
+		return quote, option, err
+	}
+	if _, err = tx.Exec(`INSERT INTO order_cost_items (order_id, kind, description, amount, position) VALUES ($1, $2, $3, $4, 1)`,
+		quote.OrderID, constants.COST_ITEM_REMOVAL, option.Title, option.Cost); err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка записи позиции стоимости заказа #%d: %v", quote.OrderID, err)
+		return quote, option, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("AcceptOrderQuoteOption: ошибка фиксации транзакции для варианта #%d: %v", optionID, err)
+		return quote, option, err
+	}
+	quote.Status = constants.QUOTE_STATUS_ACCEPTED
+	quote.ChosenOptionID = sql.NullInt64{Int64: option.ID, Valid: true}
+	log.Printf("AcceptOrderQuoteOption: по заказу #%d выбран вариант '%s' (%.0f ₽)", quote.OrderID, option.Title, option.Cost)
+	return quote, option, nil
+}
+
+// CloseOrderQuotes закрывает ожидающее предложение заказа с указанным статусом
+// (клиент отклонил варианты или оператор назначил стоимость вручную).
+func CloseOrderQuotes(orderID int64, status string) error {
+	_, err := DB.Exec(`UPDATE order_quotes SET status = $1, answered_at = NOW() WHERE order_id = $2 AND status = $3`,
+		status, orderID, constants.QUOTE_STATUS_PENDING)
+	if err != nil {
+		log.Printf("CloseOrderQuotes: ошибка закрытия предложения заказа #%d: %v", orderID, err)
+	}
+	return err
+}
+
+// quoteExecer - общий интерфейс *sql.DB и *sql.Tx для закрытия предложений.
+type quoteExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// closeCanceledOrderQuotes закрывает ожидающие предложения отмененных заказов,
+// чтобы клиент не выбрал вариант по уже отмененному заказу.
+func closeCanceledOrderQuotes(e quoteExecer, orderIDs ...int64) error {
+	_, err := e.Exec(`UPDATE order_quotes SET status = $1, answered_at = NOW() WHERE order_id = ANY($2::bigint[]) AND status = $3`,
+		constants.QUOTE_STATUS_CANCELED, pq.Array(orderIDs), constants.QUOTE_STATUS_PENDING)
+	if err != nil {
+		log.Printf("closeCanceledOrderQuotes: ошибка закрытия предложений заказов %v: %v", orderIDs, err)
+	}
+	return err
+}
+
+// ExpireOrderQuotes помечает истекшими предложения, на которые клиент не ответил в срок, и возвращает
+// их заказы из ожидания подтверждения в новые. Возвращает предложения, чьи заказы были возвращены.
+func ExpireOrderQuotes() ([]models.OrderQuote, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ExpireOrderQuotes: ошибка начала транзакции: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT `+orderQuoteColumns+`, o.user_chat_id, o.status FROM order_quotes q
+	                       JOIN orders o ON o.id = q.order_id
+	                       WHERE q.status = $1 AND q.expires_at <= NOW()
+	                       ORDER BY q.expires_at FOR UPDATE OF q`, constants.QUOTE_STATUS_PENDING)
+	if err != nil {
+		log.Printf("ExpireOrderQuotes: ошибка поиска истекших предложений: %v", err)
+		return nil, err
+	}
+	var expired []models.OrderQuote
+	var orderStatuses []string
+	for rows.Next() {
+		var q models.OrderQuote
+		var orderStatus string
+		if err := rows.Scan(&q.ID, &q.OrderID, &q.Status, &q.ExpiresAt, &q.ChosenOptionID, &q.AnsweredAt,
+			&q.CreatedByUserID, &q.CreatedAt, &q.ClientChatID, &orderStatus); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		expired = append(expired, q)
+		orderStatuses = append(orderStatuses, orderStatus)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var reverted []models.OrderQuote
+	for i, q := range expired {
+		if _, err = tx.Exec(`UPDATE order_quotes SET status = $1, answered_at = NOW() WHERE id = $2`,
+			constants.QUOTE_STATUS_EXPIRED, q.ID); err != nil {
+			log.Printf("ExpireOrderQuotes: ошибка закрытия предложения #%d: %v", q.ID, err)
+			return nil, err
+		}
+		// Заказ, который уже отменили или перевели дальше вручную, не трогаем
+		if orderStatuses[i] != constants.STATUS_AWAITING_CONFIRMATION {
+			continue
+		}
+		if _, err = tx.Exec(`UPDATE orders SET status = $1, updated_at = NOW() WHERE id = $2`, constants.STATUS_NEW, q.OrderID); err != nil {
+			log.Printf("ExpireOrderQuotes: ошибка возврата заказа #%d в новые: %v", q.OrderID, err)
+			return nil, err
+		}
+		if err = loadOrderQuoteOptions(tx, &q); err != nil {
+			return nil, err
+		}
+		q.Status = constants.QUOTE_STATUS_EXPIRED
+		reverted = append(reverted, q)
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("ExpireOrderQuotes: ошибка фиксации транзакции: %v", err)
+		return nil, err
+	}
+	return reverted, nil
+}
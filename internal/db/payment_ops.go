@@ -0,0 +1,326 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+
+	"Original/internal/constants"
+	"Original/internal/models"
+)
+
+// paymentColumns - список колонок таблицы payments в порядке, ожидаемом scanPayment.
+const paymentColumns = `id, order_id, provider, external_id, amount, currency, status, refunded_amount,
+        idempotence_key, confirmation_url, request_payload, response_payload, last_webhook_payload,
+        paid_at, created_at, updated_at, canceled_locally_at`
+
+// rowScanner позволяет использовать одну функцию сканирования для *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanPayment считывает строку таблицы payments в модель.
+func scanPayment(row rowScanner) (models.Payment, error) {
+	var p models.Payment
+	var requestPayload, responsePayload, webhookPayload []byte
+	var createdAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&p.ID, &p.OrderID, &p.Provider, &p.ExternalID, &p.Amount, &p.Currency, &p.Status, &p.RefundedAmount,
+		&p.IdempotenceKey, &p.ConfirmationURL, &requestPayload, &responsePayload, &webhookPayload,
+		&p.PaidAt, &createdAt, &updatedAt, &p.CanceledLocallyAt,
+	)
+	if err != nil {
+		return p, err
+	}
+	p.RequestPayload = requestPayload
+	p.ResponsePayload = responsePayload
+	p.WebhookPayload = webhookPayload
+	if createdAt.Valid {
+		p.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Valid {
+		p.UpdatedAt = updatedAt.Time
+	}
+	return p, nil
+}
+
+// nullJSON возвращает NULL для пустого или некорректного JSON, чтобы запись в JSONB не падала.
+func nullJSON(payload []byte) interface{} {
+	if len(payload) == 0 || !json.Valid(payload) {
+		return nil
+	}
+	return string(payload)
+}
+
+// CreatePaymentRecord создает запись о платеже до обращения к провайдеру.
+// Запись создается со статусом constants.PAYMENT_STATUS_CREATED и сохраненным ключом идемпотентности,
+// чтобы повторная попытка создания платежа не привела к двойному списанию.
+func CreatePaymentRecord(payment models.Payment) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`
+        INSERT INTO payments (order_id, provider, amount, currency, status, idempotence_key, request_payload, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+        RETURNING id`,
+		payment.OrderID, payment.Provider, payment.Amount, payment.Currency,
+		constants.PAYMENT_STATUS_CREATED, payment.IdempotenceKey, nullJSON(payment.RequestPayload),
+	).Scan(&id)
+	if err != nil {
+		log.Printf("CreatePaymentRecord: ошибка создания записи платежа для заказа #%d: %v", payment.OrderID, err)
+		return 0, err
+	}
+	log.Printf("CreatePaymentRecord: создана запись платежа #%d (%s) для заказа #%d на сумму %.2f.", id, payment.Provider, payment.OrderID, payment.Amount)
+	return id, nil
+}
+
+// GetPaymentByID возвращает запись платежа по ее ID.
+func GetPaymentByID(paymentID int64) (models.Payment, error) {
+	row := DB.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE id = $1`, paymentID)
+	p, err := scanPayment(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return p, fmt.Errorf("платеж #%d не найден", paymentID)
+		}
+		log.Printf("GetPaymentByID: ошибка получения платежа #%d: %v", paymentID, err)
+		return p, err
+	}
+	return p, nil
+}
+
+// GetPaymentByExternalID возвращает запись платежа по ID платежа у провайдера.
+// Если запись не найдена, возвращается sql.ErrNoRows.
+func GetPaymentByExternalID(provider, externalID string) (models.Payment, error) {
+	row := DB.QueryRow(`SELECT `+paymentColumns+` FROM payments WHERE provider = $1 AND external_id = $2`, provider, externalID)
+	p, err := scanPayment(row)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("GetPaymentByExternalID: ошибка получения платежа %s/%s: %v", provider, externalID, err)
+	}
+	return p, err
+}
+
+// GetPaymentsByOrderID возвращает все платежи заказа, начиная с последнего.
+func GetPaymentsByOrderID(orderID int64) ([]models.Payment, error) {
+	rows, err := DB.Query(`SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 ORDER BY created_at DESC`, orderID)
+	if err != nil {
+		log.Printf("GetPaymentsByOrderID: ошибка получения платежей заказа #%d: %v", orderID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.Payment
+	for rows.Next() {
+		p, errScan := scanPayment(rows)
+		if errScan != nil {
+			log.Printf("GetPaymentsByOrderID: ошибка сканирования платежа заказа #%d: %v", orderID, errScan)
+			continue
+		}
+		result = append(result, p)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("GetPaymentsByOrderID: ошибка после итерации по платежам заказа #%d: %v", orderID, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetPaymentsForReconciliation возвращает незавершенные платежи провайдера, известные ему (есть external_id),
+// которые не обновлялись дольше minAge. Такие платежи сверяются с API провайдера. Платежи, аннулированные у нас
+// при отмене заказа, сверяются, пока провайдер не сообщит окончательный статус: клиент мог оплатить старую ссылку.
+func GetPaymentsForReconciliation(provider string, minAge time.Duration) ([]models.Payment, error) {
+	rows, err := DB.Query(`
+        SELECT `+paymentColumns+`
+        FROM payments
+        WHERE provider = $1 AND external_id IS NOT NULL
+          AND (status = ANY($2) OR (status = $4 AND canceled_locally_at IS NOT NULL))
+          AND updated_at < NOW() - make_interval(secs => $3)
+        ORDER BY created_at`,
+		provider,
+		pq.Array([]string{constants.PAYMENT_STATUS_CREATED, constants.PAYMENT_STATUS_PENDING, constants.PAYMENT_STATUS_WAITING_FOR_CAPTURE}),
+		minAge.Seconds(), constants.PAYMENT_STATUS_CANCELED)
+	if err != nil {
+		log.Printf("GetPaymentsForReconciliation: ошибка выборки платежей для сверки: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.Payment
+	for rows.Next() {
+		p, errScan := scanPayment(rows)
+		if errScan != nil {
+			log.Printf("GetPaymentsForReconciliation: ошибка сканирования платежа: %v", errScan)
+			continue
+		}
+		result = append(result, p)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("GetPaymentsForReconciliation: ошибка после итерации по платежам: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// UpdatePaymentFromProvider сохраняет состояние платежа, полученное от провайдера
+// (ответ на создание, запрос статуса или уведомление).
+// paid_at заполняется один раз - при первом переходе в статус succeeded.
+func UpdatePaymentFromProvider(paymentID int64, externalID, status string, refundedAmount float64, confirmationURL string, responsePayload []byte) error {
+	var confirmationArg sql.NullString
+	if confirmationURL != "" {
+		confirmationArg = sql.NullString{String: confirmationURL, Valid: true}
+	}
+	_, err := DB.Exec(`
+        UPDATE payments
+        SET external_id = COALESCE(NULLIF($2, ''), external_id),
+            status = $3,
+            refunded_amount = $4,
+            confirmation_url = COALESCE($5, confirmation_url),
+            response_payload = COALESCE($6::jsonb, response_payload),
+            paid_at = CASE WHEN $3 = $7 AND paid_at IS NULL THEN NOW() ELSE paid_at END,
+            canceled_locally_at = NULL,
+            updated_at = NOW()
+        WHERE id = $1`,
+		paymentID, externalID, status, refundedAmount, confirmationArg, nullJSON(responsePayload), constants.PAYMENT_STATUS_SUCCEEDED)
+	if err != nil {
+		log.Printf("UpdatePaymentFromProvider: ошибка обновления платежа #%d: %v", paymentID, err)
+		return err
+	}
+	log.Printf("UpdatePaymentFromProvider: платеж #%d (%s) обновлен, статус '%s', возвращено %.2f.", paymentID, externalID, status, refundedAmount)
+	return nil
+}
+
+// MarkPaymentFailed отмечает платеж, который провайдер отказался создать.
+func MarkPaymentFailed(paymentID int64, responsePayload []byte) error {
+	_, err := DB.Exec(`
+        UPDATE payments
+        SET status = $2, response_payload = COALESCE($3::jsonb, response_payload), updated_at = NOW()
+        WHERE id = $1`,
+		paymentID, constants.PAYMENT_STATUS_FAILED, nullJSON(responsePayload))
+	if err != nil {
+		log.Printf("MarkPaymentFailed: ошибка обновления платежа #%d: %v", paymentID, err)
+	}
+	return err
+}
+
+// CancelOpenOrderPayments аннулирует незавершенные платежи заказа: ссылка на оплату больше не выдается повторно.
+// Ожидающий платеж YooKassa через API не отменить, и клиент может оплатить уже выданную ссылку, поэтому
+// платежи, известные провайдеру, остаются в сверке (canceled_locally_at) до окончательного статуса у провайдера.
+// Возвращает число аннулированных платежей.
+func CancelOpenOrderPayments(orderID int64) (int64, error) {
+	result, err := DB.Exec(`
+        UPDATE payments
+        SET status = $2, confirmation_url = NULL, updated_at = NOW(),
+            canceled_locally_at = CASE WHEN external_id IS NOT NULL THEN NOW() END
+        WHERE order_id = $1 AND status = ANY($3)`,
+		orderID, constants.PAYMENT_STATUS_CANCELED,
+		pq.Array([]string{constants.PAYMENT_STATUS_CREATED, constants.PAYMENT_STATUS_PENDING, constants.PAYMENT_STATUS_WAITING_FOR_CAPTURE}))
+	if err != nil {
+		log.Printf("CancelOpenOrderPayments: ошибка аннулирования платежей заказа #%d: %v", orderID, err)
+		return 0, err
+	}
+	canceled, _ := result.RowsAffected()
+	if canceled > 0 {
+		log.Printf("CancelOpenOrderPayments: по заказу #%d аннулировано незавершенных платежей: %d", orderID, canceled)
+	}
+	return canceled, nil
+}
+
+// TouchLocallyCanceledPayment откладывает следующую сверку аннулированного у нас платежа, пока провайдер
+// еще не сообщил окончательный статус.
+func TouchLocallyCanceledPayment(paymentID int64) error {
+	_, err := DB.Exec(`UPDATE payments SET updated_at = NOW() WHERE id = $1`, paymentID)
+	if err != nil {
+		log.Printf("TouchLocallyCanceledPayment: ошибка обновления платежа #%d: %v", paymentID, err)
+	}
+	return err
+}
+
+// SavePaymentWebhookPayload сохраняет тело последнего уведомления провайдера по платежу.
+func SavePaymentWebhookPayload(paymentID int64, payload []byte) error {
+	_, err := DB.Exec(`UPDATE payments SET last_webhook_payload = $2::jsonb, updated_at = NOW() WHERE id = $1`,
+		paymentID, nullJSON(payload))
+	if err != nil {
+		log.Printf("SavePaymentWebhookPayload: ошибка сохранения уведомления для платежа #%d: %v", paymentID, err)
+	}
+	return err
+}
+
+// SavePaymentRequestPayload сохраняет тело запроса к провайдеру, если оно еще не записано.
+func SavePaymentRequestPayload(paymentID int64, payload []byte) error {
+	_, err := DB.Exec(`UPDATE payments SET request_payload = COALESCE(request_payload, $2::jsonb), updated_at = NOW() WHERE id = $1`,
+		paymentID, nullJSON(payload))
+	if err != nil {
+		log.Printf("SavePaymentRequestPayload: ошибка сохранения запроса для платежа #%d: %v", paymentID, err)
+	}
+	return err
+}
+
+// GetOnlinePaidAmountForOrders возвращает сумму, оплаченную по заказам через онлайн-провайдеров
+// (за вычетом возвратов). Эти деньги не проходят через водителя.
+func GetOnlinePaidAmountForOrders(orderIDs []int64) (float64, error) {
+	if len(orderIDs) == 0 {
+		return 0, nil
+	}
+	var total float64
+	err := DB.QueryRow(`
+        SELECT COALESCE(SUM(amount - refunded_amount), 0)
+        FROM payments
+        WHERE order_id = ANY($1::bigint[]) AND status = $2 AND provider <> $3`,
+		pq.Array(orderIDs), constants.PAYMENT_STATUS_SUCCEEDED, constants.PAYMENT_PROVIDER_CASH,
+	).Scan(&total)
+	if err != nil {
+		log.Printf("GetOnlinePaidAmountForOrders: ошибка подсчета онлайн-оплат для заказов %v: %v", orderIDs, err)
+		return 0, err
+	}
+	return total, nil
+}
+
+// SettleCashPaymentsInTx отмечает наличные по заказам из отчета водителя как полученные.
+// Ожидаемые наличные платежи переводятся в succeeded; для заказов, где часть стоимости не покрыта
+// ни одним платежом (например, клиент выбрал онлайн-оплату, но рассчитался с водителем), остаток
+// записывается отдельным наличным платежом. Незавершенные онлайн-платежи тоже вычитаются из остатка:
+// если такой платеж пройдет после отчета, заказ не окажется оплаченным дважды.
+// Повторный вызов для того же отчета ничего не добавляет.
+func SettleCashPaymentsInTx(tx *sql.Tx, settlementID int64, orderIDs []int64) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	settlementRef := fmt.Sprintf(`{"settlement_id": %d}`, settlementID)
+
+	result, err := tx.Exec(`
+        UPDATE payments
+        SET status = $3, paid_at = NOW(), response_payload = $4::jsonb, updated_at = NOW()
+        WHERE provider = $2 AND status IN ($5, $6) AND order_id = ANY($1::bigint[])`,
+		pq.Array(orderIDs), constants.PAYMENT_PROVIDER_CASH, constants.PAYMENT_STATUS_SUCCEEDED, settlementRef,
+		constants.PAYMENT_STATUS_CREATED, constants.PAYMENT_STATUS_PENDING)
+	if err != nil {
+		log.Printf("SettleCashPaymentsInTx: ошибка подтверждения наличных по отчету #%d: %v", settlementID, err)
+		return err
+	}
+	confirmed, _ := result.RowsAffected()
+
+	result, err = tx.Exec(`
+        INSERT INTO payments (order_id, provider, external_id, amount, currency, status, idempotence_key,
+                              response_payload, paid_at, created_at, updated_at)
+        SELECT o.id, $3, 'settlement-' || $1::bigint || '-order-' || o.id, o.cost - COALESCE(paid.total, 0), 'RUB', $4,
+               'cash-settlement-' || $1::bigint || '-order-' || o.id, $5::jsonb, NOW(), NOW(), NOW()
+        FROM orders o
+        LEFT JOIN (
+            SELECT order_id, SUM(amount - refunded_amount) AS total
+            FROM payments
+            WHERE status = $4 OR (provider <> $3 AND status IN ($6, $7, $8))
+            GROUP BY order_id
+        ) paid ON paid.order_id = o.id
+        WHERE o.id = ANY($2::bigint[]) AND o.cost IS NOT NULL AND o.cost - COALESCE(paid.total, 0) > 0.005
+        ON CONFLICT DO NOTHING`,
+		settlementID, pq.Array(orderIDs), constants.PAYMENT_PROVIDER_CASH, constants.PAYMENT_STATUS_SUCCEEDED, settlementRef,
+		constants.PAYMENT_STATUS_CREATED, constants.PAYMENT_STATUS_PENDING, constants.PAYMENT_STATUS_WAITING_FOR_CAPTURE)
+	if err != nil {
+		log.Printf("SettleCashPaymentsInTx: ошибка записи наличных по отчету #%d: %v", settlementID, err)
+		return err
+	}
+	added, _ := result.RowsAffected()
+	log.Printf("SettleCashPaymentsInTx: отчет #%d: подтверждено наличных платежей %d, добавлено %d.", settlementID, confirmed, added)
+	return nil
+}
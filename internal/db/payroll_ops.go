@@ -0,0 +1,491 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+)
+
+// ErrPayrollOwedChanged - долг сотрудника уменьшился после расчета черновика (например, была разовая выплата).
+// Ведомость нужно пересчитать, иначе часть суммы будет выплачена дважды.
+var ErrPayrollOwedChanged = fmt.Errorf("долг сотрудника изменился после расчета ведомости")
+
+const payrollRunColumns = `r.id, r.period_from, r.period_to, r.status, r.created_by_user_id, r.created_at,
+	r.confirmed_by_user_id, r.confirmed_at, r.reversed_by_user_id, r.reversed_at, r.reverse_reason,
+	COALESCE((SELECT SUM(l.amount) FROM payroll_run_lines l WHERE l.run_id = r.id), 0)`
+
+func scanPayrollRun(row rowScanner) (models.PayrollRun, error) {
+	var run models.PayrollRun
+	err := row.Scan(&run.ID, &run.PeriodFrom, &run.PeriodTo, &run.Status, &run.CreatedByUserID, &run.CreatedAt,
+		&run.ConfirmedByUserID, &run.ConfirmedAt, &run.ReversedByUserID, &run.ReversedAt, &run.ReverseReason, &run.TotalAmount)
+	return run, err
+}
+
+// staffOwedInTx возвращает долг компании перед водителями и грузчиками по счетам staff_payable:
+// owedAtEnd - по проводкам до asOf, owedNow - текущий. Сторнированные проводки и сторно не учитываются,
+// поэтому отмена, сделанная после asOf, не искажает долг на конец периода.
+func staffOwedInTx(tx *sql.Tx, asOf time.Time) (map[int64][2]float64, error) {
+	rows, err := tx.Query(`
+		SELECT a.user_id,
+		       -COALESCE(SUM(p.amount) FILTER (WHERE j.posted_at < $2), 0),
+		       -COALESCE(SUM(p.amount), 0)
+		FROM ledger_accounts a
+		JOIN users u ON u.id = a.user_id
+		JOIN ledger_postings p ON p.account_id = a.id
+		JOIN ledger_journal j ON j.id = p.journal_id
+		WHERE a.code LIKE $1 AND u.role IN ($3, $4)
+		  AND j.reversal_of_id IS NULL AND j.reversed_by_id IS NULL
+		GROUP BY a.user_id`,
+		constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX+":%", asOf, constants.ROLE_DRIVER, constants.ROLE_LOADER)
+	if err != nil {
+		log.Printf("staffOwedInTx: ошибка расчета долгов перед сотрудниками: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	owed := make(map[int64][2]float64)
+	for rows.Next() {
+		var userID int64
+		var atEnd, now float64
+		if err := rows.Scan(&userID, &atEnd, &now); err != nil {
+			return nil, err
+		}
+		owed[userID] = [2]float64{atEnd, now}
+	}
+	return owed, rows.Err()
+}
+
+// insertPayrollLinesInTx рассчитывает строки ведомости: к выплате долг на конец периода,
+// но не больше текущего долга (если часть уже выплачена отдельно).
+func insertPayrollLinesInTx(tx *sql.Tx, runID int64, periodTo time.Time) error {
+	periodEnd := time.Date(periodTo.Year(), periodTo.Month(), periodTo.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, 1)
+	owed, err := staffOwedInTx(tx, periodEnd)
+	if err != nil {
+		return err
+	}
+	for userID, amounts := range owed {
+		toPay := math.Round(math.Min(amounts[0], amounts[1])*100) / 100
+		if toPay <= 0 {
+			continue
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO payroll_run_lines (run_id, user_id, role, owed_amount, amount)
+			SELECT $1, u.id, u.role, $3, $3 FROM users u WHERE u.id = $2`,
+			runID, userID, toPay); err != nil {
+			log.Printf("insertPayrollLinesInTx: ошибка добавления строки ведомости #%d для userID %d: %v", runID, userID, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// CreatePayrollRun создает черновик ведомости за период. Если за этот период уже есть
+// непогашенная (черновик или проведенная) ведомость, возвращает ее с existing = true.
+func CreatePayrollRun(periodFrom, periodTo time.Time, createdByUserID int64) (models.PayrollRun, bool, error) {
+	if periodTo.Before(periodFrom) {
+		return models.PayrollRun{}, false, fmt.Errorf("дата окончания периода раньше даты начала")
+	}
+	if existingID, found, err := findActivePayrollRun(periodFrom, periodTo); err != nil {
+		return models.PayrollRun{}, false, err
+	} else if found {
+		run, errGet := GetPayrollRun(existingID)
+		return run, true, errGet
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CreatePayrollRun: ошибка начала транзакции: %v", err)
+		return models.PayrollRun{}, false, err
+	}
+	defer tx.Rollback()
+
+	var runID int64
+	err = tx.QueryRow(`
+		INSERT INTO payroll_runs (period_from, period_to, status, created_by_user_id, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id`, periodFrom, periodTo, constants.PAYROLL_STATUS_DRAFT, createdByUserID).Scan(&runID)
+	if err != nil {
+		if strings.Contains(err.Error(), "idx_payroll_runs_active_period") {
+			// Параллельный запрос успел создать ведомость за тот же период
+			tx.Rollback()
+			if existingID, found, errFind := findActivePayrollRun(periodFrom, periodTo); errFind == nil && found {
+				run, errGet := GetPayrollRun(existingID)
+				return run, true, errGet
+			}
+		}
+		log.Printf("CreatePayrollRun: ошибка создания ведомости: %v", err)
+		return models.PayrollRun{}, false, err
+	}
+	if err = insertPayrollLinesInTx(tx, runID, periodTo); err != nil {
+		return models.PayrollRun{}, false, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CreatePayrollRun: ошибка коммита транзакции: %v", err)
+		return models.PayrollRun{}, false, err
+	}
+	log.Printf("CreatePayrollRun: создан черновик ведомости #%d за %s - %s", runID, periodFrom.Format("2006-01-02"), periodTo.Format("2006-01-02"))
+	run, err := GetPayrollRun(runID)
+	return run, false, err
+}
+
+func findActivePayrollRun(periodFrom, periodTo time.Time) (int64, bool, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT id FROM payroll_runs WHERE period_from = $1 AND period_to = $2 AND status <> $3`,
+		periodFrom, periodTo, constants.PAYROLL_STATUS_REVERSED).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		log.Printf("findActivePayrollRun: ошибка поиска ведомости: %v", err)
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// GetPayrollRuns возвращает последние ведомости без строк.
+func GetPayrollRuns(limit int) ([]models.PayrollRun, error) {
+	rows, err := DB.Query(`SELECT `+payrollRunColumns+` FROM payroll_runs r ORDER BY r.period_to DESC, r.id DESC LIMIT $1`, limit)
+	if err != nil {
+		log.Printf("GetPayrollRuns: ошибка получения ведомостей: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.PayrollRun
+	for rows.Next() {
+		run, errScan := scanPayrollRun(rows)
+		if errScan != nil {
+			log.Printf("GetPayrollRuns: ошибка сканирования ведомости: %v", errScan)
+			return nil, errScan
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetPayrollRun возвращает ведомость со строками.
+func GetPayrollRun(runID int64) (models.PayrollRun, error) {
+	run, err := scanPayrollRun(DB.QueryRow(`SELECT `+payrollRunColumns+` FROM payroll_runs r WHERE r.id = $1`, runID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return run, fmt.Errorf("ведомость #%d не найдена", runID)
+		}
+		log.Printf("GetPayrollRun: ошибка получения ведомости #%d: %v", runID, err)
+		return run, err
+	}
+
+	rows, err := DB.Query(`
+		SELECT l.id, l.run_id, l.user_id,
+		       COALESCE(NULLIF(TRIM(CONCAT(u.first_name, ' ', u.last_name)), ''), u.nickname, u.chat_id::text),
+		       l.role, l.owed_amount, l.amount, l.comment, l.payout_id,
+		       COALESCE(u.card_number, '') <> ''
+		FROM payroll_run_lines l
+		JOIN users u ON u.id = l.user_id
+		WHERE l.run_id = $1
+		ORDER BY l.role, u.first_name, u.last_name, l.id`, runID)
+	if err != nil {
+		log.Printf("GetPayrollRun: ошибка получения строк ведомости #%d: %v", runID, err)
+		return run, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line models.PayrollRunLine
+		if err := rows.Scan(&line.ID, &line.RunID, &line.UserID, &line.UserName, &line.Role, &line.OwedAmount,
+			&line.Amount, &line.Comment, &line.PayoutID, &line.HasCard); err != nil {
+			log.Printf("GetPayrollRun: ошибка сканирования строки ведомости #%d: %v", runID, err)
+			return run, err
+		}
+		run.Lines = append(run.Lines, line)
+	}
+	return run, rows.Err()
+}
+
+// lockPayrollRunInTx блокирует ведомость и возвращает ее статус.
+func lockPayrollRunInTx(tx *sql.Tx, runID int64) (string, error) {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM payroll_runs WHERE id = $1 FOR UPDATE`, runID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("ведомость #%d не найдена", runID)
+	}
+	return status, err
+}
+
+// UpdatePayrollRunLine меняет сумму строки черновика. Сумма 0 исключает сотрудника из выплаты.
+func UpdatePayrollRunLine(runID, lineID int64, amount float64, comment string) error {
+	if amount < 0 {
+		return fmt.Errorf("сумма не может быть отрицательной")
+	}
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("UpdatePayrollRunLine: ошибка начала транзакции: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPayrollRunInTx(tx, runID)
+	if err != nil {
+		return err
+	}
+	if status != constants.PAYROLL_STATUS_DRAFT {
+		return fmt.Errorf("ведомость #%d уже не черновик, суммы менять нельзя", runID)
+	}
+	result, err := tx.Exec(`UPDATE payroll_run_lines SET amount = $1, comment = $2 WHERE id = $3 AND run_id = $4`,
+		math.Round(amount*100)/100, sql.NullString{String: comment, Valid: comment != ""}, lineID, runID)
+	if err != nil {
+		log.Printf("UpdatePayrollRunLine: ошибка обновления строки #%d ведомости #%d: %v", lineID, runID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("строка #%d в ведомости #%d не найдена", lineID, runID)
+	}
+	return tx.Commit()
+}
+
+// RecalculatePayrollRun пересчитывает строки черновика по текущим долгам; ручные правки сбрасываются.
+func RecalculatePayrollRun(runID int64) error {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("RecalculatePayrollRun: ошибка начала транзакции: %v", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPayrollRunInTx(tx, runID)
+	if err != nil {
+		return err
+	}
+	if status != constants.PAYROLL_STATUS_DRAFT {
+		return fmt.Errorf("пересчитать можно только черновик")
+	}
+	var periodTo time.Time
+	if err = tx.QueryRow(`SELECT period_to FROM payroll_runs WHERE id = $1`, runID).Scan(&periodTo); err != nil {
+		return err
+	}
+	if _, err = tx.Exec(`DELETE FROM payroll_run_lines WHERE run_id = $1`, runID); err != nil {
+		log.Printf("RecalculatePayrollRun: ошибка удаления строк ведомости #%d: %v", runID, err)
+		return err
+	}
+	if err = insertPayrollLinesInTx(tx, runID, periodTo); err != nil {
+		return err
+	}
+	log.Printf("RecalculatePayrollRun: ведомость #%d пересчитана", runID)
+	return tx.Commit()
+}
+
+// DeletePayrollRunDraft удаляет черновик ведомости.
+func DeletePayrollRunDraft(runID int64) error {
+	result, err := DB.Exec(`DELETE FROM payroll_runs WHERE id = $1 AND status = $2`, runID, constants.PAYROLL_STATUS_DRAFT)
+	if err != nil {
+		log.Printf("DeletePayrollRunDraft: ошибка удаления ведомости #%d: %v", runID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("черновик ведомости #%d не найден", runID)
+	}
+	log.Printf("DeletePayrollRunDraft: черновик ведомости #%d удален", runID)
+	return nil
+}
+
+// ConfirmPayrollRun проводит ведомость: в одной транзакции записывает выплаты по всем строкам с суммой больше нуля.
+// Повторный вызов для проведенной ведомости ничего не делает и возвращает alreadyConfirmed = true.
+// Если долг сотрудника стал меньше, чем при расчете черновика, возвращает ErrPayrollOwedChanged.
+func ConfirmPayrollRun(runID int64, confirmedByUserID int64) (models.PayrollRun, bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ConfirmPayrollRun: ошибка начала транзакции: %v", err)
+		return models.PayrollRun{}, false, err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPayrollRunInTx(tx, runID)
+	if err != nil {
+		return models.PayrollRun{}, false, err
+	}
+	switch status {
+	case constants.PAYROLL_STATUS_CONFIRMED:
+		tx.Rollback()
+		run, errGet := GetPayrollRun(runID)
+		return run, true, errGet
+	case constants.PAYROLL_STATUS_REVERSED:
+		return models.PayrollRun{}, false, fmt.Errorf("ведомость #%d сторнирована", runID)
+	}
+
+	var periodFrom, periodTo time.Time
+	if err = tx.QueryRow(`SELECT period_from, period_to FROM payroll_runs WHERE id = $1`, runID).Scan(&periodFrom, &periodTo); err != nil {
+		return models.PayrollRun{}, false, err
+	}
+	owed, err := staffOwedInTx(tx, time.Now())
+	if err != nil {
+		return models.PayrollRun{}, false, err
+	}
+
+	type pendingLine struct {
+		id, userID         int64
+		owedAmount, amount float64
+	}
+	rows, err := tx.Query(`SELECT id, user_id, owed_amount, amount FROM payroll_run_lines WHERE run_id = $1 AND amount > 0 ORDER BY id`, runID)
+	if err != nil {
+		log.Printf("ConfirmPayrollRun: ошибка чтения строк ведомости #%d: %v", runID, err)
+		return models.PayrollRun{}, false, err
+	}
+	var lines []pendingLine
+	for rows.Next() {
+		var line pendingLine
+		if err = rows.Scan(&line.id, &line.userID, &line.owedAmount, &line.amount); err != nil {
+			rows.Close()
+			return models.PayrollRun{}, false, err
+		}
+		lines = append(lines, line)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return models.PayrollRun{}, false, err
+	}
+	if len(lines) == 0 {
+		return models.PayrollRun{}, false, fmt.Errorf("в ведомости нет сумм к выплате")
+	}
+
+	payoutDate := time.Now()
+	comment := fmt.Sprintf("Ведомость #%d за %s - %s", runID, periodFrom.Format("02.01.2006"), periodTo.Format("02.01.2006"))
+	for _, line := range lines {
+		if toKopecks(owed[line.userID][1]) < toKopecks(line.owedAmount) {
+			log.Printf("ConfirmPayrollRun: долг перед userID %d уменьшился с %.2f до %.2f", line.userID, line.owedAmount, owed[line.userID][1])
+			return models.PayrollRun{}, false, ErrPayrollOwedChanged
+		}
+		payoutID, errPayout := addPayoutWithinTx(tx, models.Payout{
+			UserID:       line.userID,
+			Amount:       line.amount,
+			PayoutDate:   payoutDate,
+			Comment:      comment,
+			MadeByUserID: confirmedByUserID,
+			PayrollRunID: runID,
+		})
+		if errPayout != nil {
+			return models.PayrollRun{}, false, errPayout
+		}
+		if _, err = tx.Exec(`UPDATE payroll_run_lines SET payout_id = $1 WHERE id = $2`, payoutID, line.id); err != nil {
+			log.Printf("ConfirmPayrollRun: ошибка связи строки #%d с выплатой #%d: %v", line.id, payoutID, err)
+			return models.PayrollRun{}, false, err
+		}
+	}
+
+	if _, err = tx.Exec(`UPDATE payroll_runs SET status = $1, confirmed_by_user_id = $2, confirmed_at = NOW() WHERE id = $3`,
+		constants.PAYROLL_STATUS_CONFIRMED, confirmedByUserID, runID); err != nil {
+		log.Printf("ConfirmPayrollRun: ошибка смены статуса ведомости #%d: %v", runID, err)
+		return models.PayrollRun{}, false, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("ConfirmPayrollRun: ошибка коммита транзакции: %v", err)
+		return models.PayrollRun{}, false, err
+	}
+	log.Printf("ConfirmPayrollRun: ведомость #%d проведена, выплат: %d", runID, len(lines))
+	run, err := GetPayrollRun(runID)
+	return run, false, err
+}
+
+// ReversePayrollRun отменяет проведенную ведомость: выплаты помечаются отмененными, их проводки сторнируются.
+// Повторный вызов для сторнированной ведомости возвращает alreadyReversed = true.
+func ReversePayrollRun(runID int64, reversedByUserID int64, reason string) (models.PayrollRun, bool, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ReversePayrollRun: ошибка начала транзакции: %v", err)
+		return models.PayrollRun{}, false, err
+	}
+	defer tx.Rollback()
+
+	status, err := lockPayrollRunInTx(tx, runID)
+	if err != nil {
+		return models.PayrollRun{}, false, err
+	}
+	switch status {
+	case constants.PAYROLL_STATUS_REVERSED:
+		tx.Rollback()
+		run, errGet := GetPayrollRun(runID)
+		return run, true, errGet
+	case constants.PAYROLL_STATUS_DRAFT:
+		return models.PayrollRun{}, false, fmt.Errorf("ведомость #%d еще не проведена", runID)
+	}
+
+	var lastPayoutDate sql.NullTime
+	if err = tx.QueryRow(`SELECT MAX(payout_date) FROM payouts WHERE payroll_run_id = $1 AND reversed_at IS NULL`, runID).Scan(&lastPayoutDate); err != nil {
+		log.Printf("ReversePayrollRun: ошибка чтения дат выплат ведомости #%d: %v", runID, err)
+		return models.PayrollRun{}, false, err
+	}
+	if err = ensurePeriodsOpen(tx, lastPayoutDate.Time); err != nil {
+		return models.PayrollRun{}, false, err
+	}
+
+	payoutIDs, err := func() ([]int64, error) {
+		rows, errQuery := tx.Query(`UPDATE payouts SET reversed_at = NOW() WHERE payroll_run_id = $1 AND reversed_at IS NULL RETURNING id`, runID)
+		if errQuery != nil {
+			return nil, errQuery
+		}
+		defer rows.Close()
+		var ids []int64
+		for rows.Next() {
+			var id int64
+			if errScan := rows.Scan(&id); errScan != nil {
+				return nil, errScan
+			}
+			ids = append(ids, id)
+		}
+		return ids, rows.Err()
+	}()
+	if err != nil {
+		log.Printf("ReversePayrollRun: ошибка отмены выплат ведомости #%d: %v", runID, err)
+		return models.PayrollRun{}, false, err
+	}
+	for _, payoutID := range payoutIDs {
+		if err = syncPayoutLedgerInTx(tx, payoutID); err != nil {
+			log.Printf("ReversePayrollRun: ошибка сторно выплаты #%d: %v", payoutID, err)
+			return models.PayrollRun{}, false, err
+		}
+	}
+
+	if _, err = tx.Exec(`UPDATE payroll_runs SET status = $1, reversed_by_user_id = $2, reversed_at = NOW(), reverse_reason = $3 WHERE id = $4`,
+		constants.PAYROLL_STATUS_REVERSED, reversedByUserID, sql.NullString{String: reason, Valid: reason != ""}, runID); err != nil {
+		log.Printf("ReversePayrollRun: ошибка смены статуса ведомости #%d: %v", runID, err)
+		return models.PayrollRun{}, false, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("ReversePayrollRun: ошибка коммита транзакции: %v", err)
+		return models.PayrollRun{}, false, err
+	}
+	log.Printf("ReversePayrollRun: ведомость #%d сторнирована, отменено выплат: %d", runID, len(payoutIDs))
+	run, err := GetPayrollRun(runID)
+	return run, false, err
+}
+
+// GetPayrollRegistry формирует реестр на перечисление: строки с суммой больше нуля и расшифрованными номерами карт.
+// Если номер карты недоступен, строка остается в реестре с причиной в CardError.
+func GetPayrollRegistry(runID int64) (models.PayrollRun, []models.PayrollRegistryLine, error) {
+	run, err := GetPayrollRun(runID)
+	if err != nil {
+		return run, nil, err
+	}
+	if run.Status == constants.PAYROLL_STATUS_REVERSED {
+		return run, nil, fmt.Errorf("ведомость #%d сторнирована", runID)
+	}
+	purpose := fmt.Sprintf("Заработная плата за %s - %s", run.PeriodFrom.Format("02.01.2006"), run.PeriodTo.Format("02.01.2006"))
+
+	var registry []models.PayrollRegistryLine
+	for _, line := range run.Lines {
+		if line.Amount <= 0 {
+			continue
+		}
+		entry := models.PayrollRegistryLine{UserID: line.UserID, UserName: line.UserName, Amount: line.Amount, Purpose: purpose}
+		card, errCard := GetCardNumberByUserID(line.UserID)
+		if errCard != nil {
+			entry.CardError = errCard.Error()
+		} else {
+			entry.CardNumber = card
+		}
+		registry = append(registry, entry)
+	}
+	return run, registry, nil
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const priceRuleColumns = `id, category, subcategory, base_price, included_volume_m3, per_m3, per_floor,
+        urgent_percent, weekend_percent, free_km, per_km, min_price,
+        effective_from, effective_to, comment, is_active, created_by_user_id, created_at`
+
+func scanPriceRule(row rowScanner) (models.PriceRule, error) {
+	var r models.PriceRule
+	err := row.Scan(&r.ID, &r.Category, &r.Subcategory, &r.BasePrice, &r.IncludedVolumeM3, &r.PerM3, &r.PerFloor,
+		&r.UrgentPercent, &r.WeekendPercent, &r.FreeKm, &r.PerKm, &r.MinPrice,
+		&r.EffectiveFrom, &r.EffectiveTo, &r.Comment, &r.IsActive, &r.CreatedByUserID, &r.CreatedAt)
+	return r, err
+}
+
+// CreatePriceRule добавляет правило расчета цены заказа.
+func CreatePriceRule(rule models.PriceRule) (int64, error) {
+	return savePriceRule(0, rule)
+}
+
+// ReplacePriceRule изменяет правило цены: действующее правило отключается, вместо него добавляется новое.
+// Правила не редактируются на месте, чтобы по журналу было видно, по каким параметрам считались прошлые заказы.
+func ReplacePriceRule(oldRuleID int64, rule models.PriceRule) (int64, error) {
+	return savePriceRule(oldRuleID, rule)
+}
+
+// savePriceRule проверяет и сохраняет правило цены; если replacedRuleID > 0, в той же транзакции отключает прежнее.
+func savePriceRule(replacedRuleID int64, rule models.PriceRule) (int64, error) {
+	for _, v := range []float64{rule.BasePrice, rule.IncludedVolumeM3, rule.PerM3, rule.PerFloor, rule.UrgentPercent,
+		rule.WeekendPercent, rule.FreeKm, rule.PerKm, rule.MinPrice} {
+		if v < 0 {
+			return 0, fmt.Errorf("параметры правила цены не могут быть отрицательными")
+		}
+	}
+	if rule.BasePrice == 0 && rule.MinPrice == 0 {
+		return 0, fmt.Errorf("в правиле должна быть указана базовая или минимальная цена")
+	}
+	if rule.Subcategory.Valid && !rule.Category.Valid {
+		return 0, fmt.Errorf("для подкатегории нужно указать категорию")
+	}
+	if rule.EffectiveFrom.IsZero() {
+		return 0, fmt.Errorf("не указана дата начала действия правила")
+	}
+	if rule.EffectiveTo.Valid && rule.EffectiveTo.Time.Before(rule.EffectiveFrom) {
+		return 0, fmt.Errorf("дата окончания правила раньше даты начала")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("savePriceRule: ошибка начала транзакции: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if replacedRuleID > 0 {
+		result, errOld := tx.Exec(`UPDATE price_rules SET is_active = FALSE WHERE id = $1 AND is_active = TRUE`, replacedRuleID)
+		if errOld != nil {
+			log.Printf("savePriceRule: ошибка отключения правила #%d: %v", replacedRuleID, errOld)
+			return 0, errOld
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return 0, fmt.Errorf("действующее правило цены #%d не найдено", replacedRuleID)
+		}
+	}
+
+	var id int64
+	err = tx.QueryRow(`
+        INSERT INTO price_rules (category, subcategory, base_price, included_volume_m3, per_m3, per_floor,
+                                 urgent_percent, weekend_percent, free_km, per_km, min_price,
+                                 effective_from, effective_to, comment, created_by_user_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+        RETURNING id`,
+		rule.Category, rule.Subcategory, rule.BasePrice, rule.IncludedVolumeM3, rule.PerM3, rule.PerFloor,
+		rule.UrgentPercent, rule.WeekendPercent, rule.FreeKm, rule.PerKm, rule.MinPrice,
+		rule.EffectiveFrom, rule.EffectiveTo, rule.Comment, rule.CreatedByUserID,
+	).Scan(&id)
+	if err != nil {
+		log.Printf("savePriceRule: ошибка добавления правила: %v", err)
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("savePriceRule: ошибка фиксации транзакции: %v", err)
+		return 0, err
+	}
+	if replacedRuleID > 0 {
+		log.Printf("savePriceRule: правило цены #%d заменено правилом #%d (категория %v, подкатегория %v)", replacedRuleID, id, rule.Category, rule.Subcategory)
+	} else {
+		log.Printf("savePriceRule: добавлено правило цены #%d (категория %v, подкатегория %v)", id, rule.Category, rule.Subcategory)
+	}
+	return id, nil
+}
+
+// GetPriceRuleByID возвращает правило цены по ID.
+func GetPriceRuleByID(ruleID int64) (models.PriceRule, error) {
+	rule, err := scanPriceRule(DB.QueryRow(`SELECT `+priceRuleColumns+` FROM price_rules WHERE id = $1`, ruleID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return rule, fmt.Errorf("правило цены #%d не найдено", ruleID)
+		}
+		log.Printf("GetPriceRuleByID: ошибка получения правила #%d: %v", ruleID, err)
+	}
+	return rule, err
+}
+
+// GetPriceRules возвращает правила цены; при activeOnly - только действующие записи.
+func GetPriceRules(activeOnly bool) ([]models.PriceRule, error) {
+	query := `SELECT ` + priceRuleColumns + ` FROM price_rules`
+	if activeOnly {
+		query += ` WHERE is_active = TRUE`
+	}
+	query += ` ORDER BY category NULLS FIRST, subcategory NULLS FIRST, effective_from DESC, id DESC`
+
+	rows, err := DB.Query(query)
+	if err != nil {
+		log.Printf("GetPriceRules: ошибка получения правил цены: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.PriceRule
+	for rows.Next() {
+		r, errScan := scanPriceRule(rows)
+		if errScan != nil {
+			log.Printf("GetPriceRules: ошибка сканирования правила: %v", errScan)
+			return nil, errScan
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// DeactivatePriceRule отключает правило цены.
+func DeactivatePriceRule(ruleID int64) error {
+	result, err := DB.Exec(`UPDATE price_rules SET is_active = FALSE WHERE id = $1 AND is_active = TRUE`, ruleID)
+	if err != nil {
+		log.Printf("DeactivatePriceRule: ошибка отключения правила #%d: %v", ruleID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("действующее правило цены #%d не найдено", ruleID)
+	}
+	log.Printf("DeactivatePriceRule: правило цены #%d отключено", ruleID)
+	return nil
+}
+
+// FindPriceRule подбирает правило цены на дату: подкатегория > категория > общее,
+// при равной специфичности - с самой поздней датой начала. ok=false, если правила нет.
+func FindPriceRule(category, subcategory string, date time.Time) (models.PriceRule, bool, error) {
+	rules, err := GetPriceRules(true)
+	if err != nil {
+		return models.PriceRule{}, false, err
+	}
+
+	var best models.PriceRule
+	bestSpecificity := -1
+	for _, rule := range rules {
+		if rule.Category.Valid && rule.Category.String != category {
+			continue
+		}
+		if rule.Subcategory.Valid && rule.Subcategory.String != subcategory {
+			continue
+		}
+		if !ruleInEffect(rule.EffectiveFrom, rule.EffectiveTo, date) {
+			continue
+		}
+		specificity := ruleSpecificity(rule.Subcategory.Valid, rule.Category.Valid)
+		if specificity > bestSpecificity ||
+			(specificity == bestSpecificity && (rule.EffectiveFrom.After(best.EffectiveFrom) ||
+				(rule.EffectiveFrom.Equal(best.EffectiveFrom) && rule.ID > best.ID))) {
+			best = rule
+			bestSpecificity = specificity
+		}
+	}
+	return best, bestSpecificity >= 0, nil
+}
+
+// OrderPriceInput собирает параметры расчета цены из заказа. Расстояние считается по прямой
+// от базы (depotLat, depotLon); если координаты базы или заказа не заданы, оно неизвестно.
+func OrderPriceInput(order models.Order, depotLat, depotLon float64) models.PriceInput {
+	input := models.PriceInput{
+		Category:    order.Category,
+		Subcategory: order.Subcategory,
+		Urgent:      strings.EqualFold(strings.TrimSpace(order.Time), "СРОЧНО"),
+		VolumeM3:    order.VolumeM3.Float64,
+		Floor:       int(order.Floor.Int64),
+		HasElevator: order.HasElevator.Bool,
+		DistanceKm:  -1,
+	}
+	if order.Date != "" {
+		if parsed, err := time.ParseInLocation("2006-01-02", order.Date, time.Local); err == nil {
+			input.Date = parsed
+		}
+	}
+	if (depotLat != 0 || depotLon != 0) && (order.Latitude != 0 || order.Longitude != 0) {
+		input.DistanceKm = utils.DistanceKm(depotLat, depotLon, order.Latitude, order.Longitude)
+	}
+	return input
+}
+
+// EstimatePrice считает цену по подходящему правилу. ok=false, если правила для заказа нет.
+func EstimatePrice(input models.PriceInput) (models.PriceEstimate, bool, error) {
+	ruleDate := input.Date
+	if ruleDate.IsZero() {
+		ruleDate = time.Now()
+	}
+	rule, found, err := FindPriceRule(input.Category, input.Subcategory, ruleDate)
+	if err != nil || !found {
+		return models.PriceEstimate{}, false, err
+	}
+	return rule.Estimate(input, constants.PriceRoundingStep, constants.PriceEstimateSpreadPercent), true, nil
+}
+
+// EstimateOrderPrice - расчетная цена заказа по правилам цены.
+func EstimateOrderPrice(order models.Order, depotLat, depotLon float64) (models.PriceEstimate, bool, error) {
+	estimate, found, err := EstimatePrice(OrderPriceInput(order, depotLat, depotLon))
+	if err != nil {
+		log.Printf("EstimateOrderPrice: ошибка расчета цены заказа #%d: %v", order.ID, err)
+	}
+	return estimate, found, err
+}
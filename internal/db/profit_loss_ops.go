@@ -0,0 +1,171 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// profitLossLinesSQL считает P&L по месяцам, категориям и подкатегориям выполненных заказов.
+// Заказ относится к месяцу даты выполнения (без даты - к дате создания).
+// Расходы из отчетов водителей распределяются по покрытым заказам пропорционально их стоимости
+// (при нулевой стоимости - поровну), поэтому отчет, покрывающий заказы разных периодов, делится между ними.
+// Старые расходы из таблицы expenses берутся только для заказов, не попавших ни в один отчет.
+// Реферальные бонусы относятся к заказу, за который начислены, утилизация - позиции сметы disposal_fee.
+const profitLossLinesSQL = `
+WITH period_orders AS (
+    SELECT o.id, COALESCE(o.category, '') AS category, COALESCE(o.subcategory, '') AS subcategory,
+           date_trunc('month', COALESCE(o.date, o.created_at::date))::date AS month, COALESCE(o.cost, 0) AS revenue
+    FROM orders o
+    WHERE o.status IN ($3, $4, $5) AND COALESCE(o.date, o.created_at::date) BETWEEN $1 AND $2
+),
+settlement_costs AS (
+    SELECT s.id, s.covered_order_ids, s.fuel_expense AS fuel,
+           (SELECT COALESCE(SUM((e->>'amount')::float), 0) FROM jsonb_array_elements(COALESCE(s.other_expenses_json, '[]'::jsonb)) e) AS other,
+           (SELECT COALESCE(SUM((l->>'amount')::float), 0) FROM jsonb_array_elements(COALESCE(s.loader_payments_json, '[]'::jsonb)) l) AS loaders,
+           s.driver_calculated_salary AS driver_share
+    FROM driver_settlements s
+    WHERE s.status <> $6 AND s.covered_order_ids && ARRAY(SELECT id::bigint FROM period_orders)
+),
+settlement_weights AS (
+    SELECT sc.id AS settlement_id, so.order_id,
+           CASE WHEN SUM(COALESCE(o.cost, 0)) OVER (PARTITION BY sc.id) > 0
+                THEN COALESCE(o.cost, 0) / SUM(COALESCE(o.cost, 0)) OVER (PARTITION BY sc.id)
+                ELSE 1.0 / COUNT(*) OVER (PARTITION BY sc.id) END AS weight
+    FROM settlement_costs sc
+    CROSS JOIN LATERAL unnest(sc.covered_order_ids) AS so(order_id)
+    JOIN orders o ON o.id = so.order_id
+),
+settlement_alloc AS (
+    SELECT w.order_id, SUM(sc.fuel * w.weight) AS fuel, SUM(sc.other * w.weight) AS other,
+           SUM(sc.loaders * w.weight) AS loaders, SUM(sc.driver_share * w.weight) AS driver_share
+    FROM settlement_weights w
+    JOIN settlement_costs sc ON sc.id = w.settlement_id
+    GROUP BY w.order_id
+),
+legacy_expenses AS (
+    SELECT e.order_id, COALESCE(e.fuel, 0) AS fuel, COALESCE(e.other, 0) AS other,
+           (SELECT COALESCE(SUM((ls.value->>'amount')::float), 0) FROM jsonb_each(COALESCE(e.loader_salaries, '{}'::jsonb)) ls) AS loaders,
+           COALESCE(e.driver_share, 0) AS driver_share
+    FROM expenses e
+    JOIN period_orders po ON po.id = e.order_id
+    WHERE NOT EXISTS (SELECT 1 FROM settlement_alloc sa WHERE sa.order_id = e.order_id)
+),
+referral_bonuses AS (
+    SELECT r.order_id, SUM(COALESCE(r.amount, 0)) AS amount
+    FROM referrals r
+    JOIN period_orders po ON po.id = r.order_id
+    GROUP BY r.order_id
+),
+disposal_costs AS (
+    SELECT ci.order_id, SUM(ci.amount) AS amount
+    FROM order_cost_items ci
+    JOIN period_orders po ON po.id = ci.order_id
+    WHERE ci.kind = $7
+    GROUP BY ci.order_id
+)
+SELECT po.month, po.category, po.subcategory, COUNT(*), SUM(po.revenue),
+       SUM(COALESCE(sa.fuel, le.fuel, 0)), SUM(COALESCE(sa.other, le.other, 0)),
+       SUM(COALESCE(sa.loaders, le.loaders, 0)), SUM(COALESCE(sa.driver_share, le.driver_share, 0)),
+       SUM(COALESCE(rb.amount, 0)), SUM(COALESCE(dc.amount, 0))
+FROM period_orders po
+LEFT JOIN settlement_alloc sa ON sa.order_id = po.id
+LEFT JOIN legacy_expenses le ON le.order_id = po.id
+LEFT JOIN referral_bonuses rb ON rb.order_id = po.id
+LEFT JOIN disposal_costs dc ON dc.order_id = po.id
+GROUP BY po.month, po.category, po.subcategory
+ORDER BY po.month, po.category, po.subcategory`
+
+// getProfitLossLines возвращает строки P&L за период [from, to] (календарные дни, включительно).
+func getProfitLossLines(from, to time.Time) ([]models.ProfitLossLine, error) {
+	rows, err := DB.Query(profitLossLinesSQL, from.Format("2006-01-02"), to.Format("2006-01-02"),
+		constants.STATUS_COMPLETED, constants.STATUS_CALCULATED, constants.STATUS_SETTLED,
+		constants.SETTLEMENT_STATUS_REJECTED, constants.COST_ITEM_DISPOSAL_FEE)
+	if err != nil {
+		log.Printf("getProfitLossLines: ошибка расчета P&L за %s - %s: %v", from.Format("02.01.2006"), to.Format("02.01.2006"), err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []models.ProfitLossLine
+	for rows.Next() {
+		var line models.ProfitLossLine
+		if err := rows.Scan(&line.Month, &line.Category, &line.Subcategory, &line.OrdersCount, &line.Revenue,
+			&line.Fuel, &line.OtherExpenses, &line.LoaderSalaries, &line.DriverShare,
+			&line.ReferralBonuses, &line.Disposal); err != nil {
+			log.Printf("getProfitLossLines: ошибка сканирования строки P&L: %v", err)
+			return nil, err
+		}
+		line.Finalize()
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+// GetProfitAndLoss собирает P&L за период from..to (календарные дни, включительно) с разбивкой
+// по месяцам, категориям и подкатегориям и сравнивает его с предыдущим периодом той же длины.
+func GetProfitAndLoss(from, to time.Time) (models.ProfitLossReport, error) {
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.Local)
+	report := models.ProfitLossReport{From: from, To: to, GeneratedAt: time.Now()}
+	if to.Before(from) {
+		return report, fmt.Errorf("дата окончания периода раньше даты начала")
+	}
+	days := int(to.Sub(from).Hours()/24+0.5) + 1
+	report.PreviousTo = from.AddDate(0, 0, -1)
+	report.PreviousFrom = report.PreviousTo.AddDate(0, 0, -(days - 1))
+
+	lines, err := getProfitLossLines(from, to)
+	if err != nil {
+		return report, err
+	}
+	previousLines, err := getProfitLossLines(report.PreviousFrom, report.PreviousTo)
+	if err != nil {
+		return report, err
+	}
+	report.Lines = lines
+
+	type categoryKey struct{ category, subcategory string }
+	categories := make(map[categoryKey]*models.ProfitLossCategory)
+	categoryFor := func(category, subcategory string) *models.ProfitLossCategory {
+		key := categoryKey{category, subcategory}
+		if categories[key] == nil {
+			categories[key] = &models.ProfitLossCategory{Category: category, Subcategory: subcategory}
+		}
+		return categories[key]
+	}
+	for _, line := range lines {
+		categoryFor(line.Category, line.Subcategory).Current.Add(line.ProfitLossAmounts)
+		report.Total.Add(line.ProfitLossAmounts)
+	}
+	for _, line := range previousLines {
+		categoryFor(line.Category, line.Subcategory).Previous.Add(line.ProfitLossAmounts)
+		report.PreviousTotal.Add(line.ProfitLossAmounts)
+	}
+
+	for _, c := range categories {
+		c.RevenueChangePct = models.PercentChange(c.Current.Revenue, c.Previous.Revenue)
+		c.ProfitChangePct = models.PercentChange(c.Current.Profit, c.Previous.Profit)
+		report.Categories = append(report.Categories, *c)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if report.Categories[i].Current.Revenue != report.Categories[j].Current.Revenue {
+			return report.Categories[i].Current.Revenue > report.Categories[j].Current.Revenue
+		}
+		if report.Categories[i].Category != report.Categories[j].Category {
+			return report.Categories[i].Category < report.Categories[j].Category
+		}
+		return report.Categories[i].Subcategory < report.Categories[j].Subcategory
+	})
+
+	report.OrdersChangePct = models.PercentChange(float64(report.Total.OrdersCount), float64(report.PreviousTotal.OrdersCount))
+	report.RevenueChangePct = models.PercentChange(report.Total.Revenue, report.PreviousTotal.Revenue)
+	report.ExpensesChangePct = models.PercentChange(report.Total.Expenses, report.PreviousTotal.Expenses)
+	report.ProfitChangePct = models.PercentChange(report.Total.Profit, report.PreviousTotal.Profit)
+	log.Printf("GetProfitAndLoss: P&L за %s - %s: выручка %.2f, расходы %.2f, прибыль %.2f",
+		from.Format("02.01.2006"), to.Format("02.01.2006"), report.Total.Revenue, report.Total.Expenses, report.Total.Profit)
+	return report, nil
+}
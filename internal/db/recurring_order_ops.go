@@ -0,0 +1,283 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const recurringOrderColumns = `r.id, r.source_order_id, COALESCE(r.user_id, 0), r.user_chat_id, r.category, COALESCE(r.subcategory, ''),
+	COALESCE(r.name, ''), COALESCE(r.phone, ''), COALESCE(r.address, ''), COALESCE(r.latitude, 0), COALESCE(r.longitude, 0),
+	COALESCE(r.description, ''), COALESCE(r.payment, ''), r.time_slot, r.cost, r.interval_days, r.start_date, r.end_date,
+	r.status, r.created_by_user_id, r.created_at, r.updated_at`
+
+func scanRecurringOrder(row rowScanner) (models.RecurringOrder, error) {
+	var r models.RecurringOrder
+	err := row.Scan(&r.ID, &r.SourceOrderID, &r.UserID, &r.UserChatID, &r.Category, &r.Subcategory,
+		&r.Name, &r.Phone, &r.Address, &r.Latitude, &r.Longitude,
+		&r.Description, &r.Payment, &r.TimeSlot, &r.Cost, &r.IntervalDays, &r.StartDate, &r.EndDate,
+		&r.Status, &r.CreatedByUserID, &r.CreatedAt, &r.UpdatedAt)
+	return r, err
+}
+
+// loadRecurringOrderExecutors подгружает исполнителей, назначаемых на заказы графика.
+func loadRecurringOrderExecutors(r *models.RecurringOrder) error {
+	rows, err := DB.Query(`SELECT e.user_id, u.chat_id, e.role, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, ''))
+	                       FROM recurring_order_executors e JOIN users u ON u.id = e.user_id
+	                       WHERE e.recurring_order_id = $1 ORDER BY e.role, u.first_name`, r.ID)
+	if err != nil {
+		log.Printf("loadRecurringOrderExecutors: ошибка чтения исполнителей графика #%d: %v", r.ID, err)
+		return err
+	}
+	defer rows.Close()
+	r.Executors = nil
+	for rows.Next() {
+		var e models.RecurringOrderExecutor
+		if err := rows.Scan(&e.UserID, &e.ChatID, &e.Role, &e.Name); err != nil {
+			return err
+		}
+		r.Executors = append(r.Executors, e)
+	}
+	return rows.Err()
+}
+
+// CreateRecurringOrderFromOrder создает регулярный заказ по образцу существующего: адрес, категория, время,
+// цена и назначенные исполнители копируются из заказа. Первая дата графика - startDate.
+func CreateRecurringOrderFromOrder(orderID int64, intervalDays int, startDate time.Time, createdByUserID int64) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CreateRecurringOrderFromOrder: ошибка начала транзакции для заказа #%d: %v", orderID, err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var createdBy sql.NullInt64
+	if createdByUserID > 0 {
+		createdBy = sql.NullInt64{Int64: createdByUserID, Valid: true}
+	}
+	var id int64
+	err = tx.QueryRow(`
+        INSERT INTO recurring_orders (
+            source_order_id, user_id, user_chat_id, category, subcategory, name, phone, address,
+            latitude, longitude, description, payment, time_slot, cost, interval_days, start_date, status, created_by_user_id
+        )
+        SELECT o.id, o.user_id, o.user_chat_id, o.category, o.subcategory, o.name, o.phone, o.address,
+               o.latitude, o.longitude, o.description, o.payment, NULLIF(o.time, 'СРОЧНО'), NULLIF(o.cost, 0), $2, $3, $4, $5
+        FROM orders o WHERE o.id = $1
+        RETURNING id`,
+		orderID, intervalDays, startDate, constants.RECURRING_STATUS_ACTIVE, createdBy).Scan(&id)
+	if err != nil {
+		log.Printf("CreateRecurringOrderFromOrder: ошибка создания графика по заказу #%d: %v", orderID, err)
+		return 0, err
+	}
+	if _, err = tx.Exec(`INSERT INTO recurring_order_executors (recurring_order_id, user_id, role)
+	                     SELECT DISTINCT $1, user_id, role FROM executors WHERE order_id = $2 AND user_id IS NOT NULL`,
+		id, orderID); err != nil {
+		log.Printf("CreateRecurringOrderFromOrder: ошибка копирования исполнителей заказа #%d: %v", orderID, err)
+		return 0, err
+	}
+	// Заказ-образец считается первой датой графика, чтобы планировщик не создал на нее дубль
+	if _, err = tx.Exec(`INSERT INTO recurring_order_occurrences (recurring_order_id, occurrence_date, order_id, status)
+	                     SELECT $1, o.date, o.id, $3 FROM orders o WHERE o.id = $2 AND o.date IS NOT NULL
+	                     ON CONFLICT (recurring_order_id, occurrence_date) DO NOTHING`,
+		id, orderID, constants.OCCURRENCE_STATUS_CREATED); err != nil {
+		log.Printf("CreateRecurringOrderFromOrder: ошибка привязки заказа #%d к графику: %v", orderID, err)
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CreateRecurringOrderFromOrder: ошибка фиксации транзакции для заказа #%d: %v", orderID, err)
+		return 0, err
+	}
+	log.Printf("CreateRecurringOrderFromOrder: по заказу #%d создан регулярный заказ #%d (каждые %d дн., с %s)", orderID, id, intervalDays, startDate.Format("2006-01-02"))
+	return id, nil
+}
+
+// GetRecurringOrder возвращает регулярный заказ с исполнителями.
+func GetRecurringOrder(id int64) (models.RecurringOrder, error) {
+	r, err := scanRecurringOrder(DB.QueryRow(`SELECT `+recurringOrderColumns+` FROM recurring_orders r WHERE r.id = $1`, id))
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("GetRecurringOrder: ошибка чтения графика #%d: %v", id, err)
+		}
+		return r, err
+	}
+	return r, loadRecurringOrderExecutors(&r)
+}
+
+// GetRecurringOrders возвращает регулярные заказы клиента (clientChatID = 0 - всех клиентов):
+// сначала действующие, затем на паузе и завершенные.
+func GetRecurringOrders(clientChatID int64) ([]models.RecurringOrder, error) {
+	rows, err := DB.Query(`SELECT `+recurringOrderColumns+` FROM recurring_orders r
+	                       WHERE $1 = 0 OR r.user_chat_id = $1
+	                       ORDER BY CASE r.status WHEN $2 THEN 0 WHEN $3 THEN 1 ELSE 2 END, r.id DESC`,
+		clientChatID, constants.RECURRING_STATUS_ACTIVE, constants.RECURRING_STATUS_PAUSED)
+	if err != nil {
+		log.Printf("GetRecurringOrders: ошибка выборки графиков (клиент %d): %v", clientChatID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.RecurringOrder
+	for rows.Next() {
+		r, errScan := scanRecurringOrder(rows)
+		if errScan != nil {
+			log.Printf("GetRecurringOrders: ошибка сканирования графика: %v", errScan)
+			return nil, errScan
+		}
+		result = append(result, r)
+	}
+	return result, rows.Err()
+}
+
+// GetActiveRecurringOrders возвращает действующие графики с исполнителями для планировщика.
+func GetActiveRecurringOrders() ([]models.RecurringOrder, error) {
+	rows, err := DB.Query(`SELECT `+recurringOrderColumns+` FROM recurring_orders r WHERE r.status = $1 ORDER BY r.id`,
+		constants.RECURRING_STATUS_ACTIVE)
+	if err != nil {
+		log.Printf("GetActiveRecurringOrders: ошибка выборки графиков: %v", err)
+		return nil, err
+	}
+	var result []models.RecurringOrder
+	for rows.Next() {
+		r, errScan := scanRecurringOrder(rows)
+		if errScan != nil {
+			rows.Close()
+			log.Printf("GetActiveRecurringOrders: ошибка сканирования графика: %v", errScan)
+			return nil, errScan
+		}
+		result = append(result, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range result {
+		if err = loadRecurringOrderExecutors(&result[i]); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// GetRecurringOrderIDByOrder возвращает график, по которому создан заказ (sql.ErrNoRows - заказ не из графика).
+func GetRecurringOrderIDByOrder(orderID int64) (int64, error) {
+	var id int64
+	err := DB.QueryRow(`SELECT recurring_order_id FROM recurring_order_occurrences WHERE order_id = $1 LIMIT 1`, orderID).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("GetRecurringOrderIDByOrder: ошибка поиска графика заказа #%d: %v", orderID, err)
+	}
+	return id, err
+}
+
+// GetRecurringOccurrences возвращает обработанные даты графика начиная с fromDate.
+func GetRecurringOccurrences(recurringOrderID int64, fromDate time.Time) ([]models.RecurringOccurrence, error) {
+	rows, err := DB.Query(`SELECT id, recurring_order_id, occurrence_date, order_id, status FROM recurring_order_occurrences
+	                       WHERE recurring_order_id = $1 AND occurrence_date >= $2 ORDER BY occurrence_date`,
+		recurringOrderID, fromDate)
+	if err != nil {
+		log.Printf("GetRecurringOccurrences: ошибка чтения дат графика #%d: %v", recurringOrderID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.RecurringOccurrence
+	for rows.Next() {
+		var o models.RecurringOccurrence
+		if err := rows.Scan(&o.ID, &o.RecurringOrderID, &o.Date, &o.OrderID, &o.Status); err != nil {
+			return nil, err
+		}
+		result = append(result, o)
+	}
+	return result, rows.Err()
+}
+
+// CreateRecurringOccurrenceOrder создает заказ графика на дату в указанном статусе. Если на эту дату
+// заказ уже создан или дата пропущена, возвращает 0 без ошибки: планировщик может запускаться повторно.
+func CreateRecurringOccurrenceOrder(r models.RecurringOrder, date time.Time, status string) (int64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CreateRecurringOccurrenceOrder: ошибка начала транзакции для графика #%d: %v", r.ID, err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var occurrenceID int64
+	err = tx.QueryRow(`INSERT INTO recurring_order_occurrences (recurring_order_id, occurrence_date, status)
+	                   VALUES ($1, $2, $3)
+	                   ON CONFLICT (recurring_order_id, occurrence_date) DO NOTHING
+	                   RETURNING id`, r.ID, date, constants.OCCURRENCE_STATUS_CREATED).Scan(&occurrenceID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		log.Printf("CreateRecurringOccurrenceOrder: ошибка записи даты %s графика #%d: %v", date.Format("2006-01-02"), r.ID, err)
+		return 0, err
+	}
+
+	var orderID int64
+	err = tx.QueryRow(`
+        INSERT INTO orders (
+            user_id, user_chat_id, category, subcategory, name,
+            photos, videos, date, time, phone, address,
+            description, status, cost, payment,
+            latitude, longitude, created_at, updated_at, is_driver_settled
+        )
+        VALUES (NULLIF($1, 0), $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, NOW(), NOW(), FALSE)
+        RETURNING id`,
+		r.UserID, r.UserChatID, r.Category, r.Subcategory, r.Name,
+		pq.Array([]string{}), pq.Array([]string{}), date, r.TimeSlot, r.Phone, r.Address,
+		r.Description, status, r.Cost, r.Payment,
+		r.Latitude, r.Longitude).Scan(&orderID)
+	if err != nil {
+		log.Printf("CreateRecurringOccurrenceOrder: ошибка создания заказа по графику #%d на %s: %v", r.ID, date.Format("2006-01-02"), err)
+		return 0, err
+	}
+	if _, err = tx.Exec(`UPDATE recurring_order_occurrences SET order_id = $1 WHERE id = $2`, orderID, occurrenceID); err != nil {
+		log.Printf("CreateRecurringOccurrenceOrder: ошибка привязки заказа #%d к графику #%d: %v", orderID, r.ID, err)
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CreateRecurringOccurrenceOrder: ошибка фиксации транзакции для графика #%d: %v", r.ID, err)
+		return 0, err
+	}
+	log.Printf("CreateRecurringOccurrenceOrder: по графику #%d создан заказ #%d на %s", r.ID, orderID, date.Format("2006-01-02"))
+	return orderID, nil
+}
+
+// SkipRecurringOccurrence отмечает дату графика пропущенной. Возвращает заказ, уже созданный на эту дату, если он есть.
+func SkipRecurringOccurrence(recurringOrderID int64, date time.Time) (sql.NullInt64, error) {
+	var orderID sql.NullInt64
+	err := DB.QueryRow(`INSERT INTO recurring_order_occurrences (recurring_order_id, occurrence_date, status)
+	                    VALUES ($1, $2, $3)
+	                    ON CONFLICT (recurring_order_id, occurrence_date) DO UPDATE SET status = EXCLUDED.status
+	                    RETURNING order_id`, recurringOrderID, date, constants.OCCURRENCE_STATUS_SKIPPED).Scan(&orderID)
+	if err != nil {
+		log.Printf("SkipRecurringOccurrence: ошибка пропуска даты %s графика #%d: %v", date.Format("2006-01-02"), recurringOrderID, err)
+		return orderID, err
+	}
+	log.Printf("SkipRecurringOccurrence: дата %s графика #%d пропущена", date.Format("2006-01-02"), recurringOrderID)
+	return orderID, nil
+}
+
+// SetRecurringOrderStatus ставит график на паузу, возобновляет или завершает его.
+// При завершении датой окончания становится текущий день.
+func SetRecurringOrderStatus(id int64, status string) error {
+	if _, ok := constants.RecurringStatusDisplayMap[status]; !ok {
+		return fmt.Errorf("неизвестный статус регулярного заказа: %s", status)
+	}
+	_, err := DB.Exec(`UPDATE recurring_orders
+	                   SET status = $1, updated_at = NOW(),
+	                       end_date = CASE WHEN $1 = $3 THEN LEAST(COALESCE(end_date, CURRENT_DATE), CURRENT_DATE) ELSE end_date END
+	                   WHERE id = $2`, status, id, constants.RECURRING_STATUS_ENDED)
+	if err != nil {
+		log.Printf("SetRecurringOrderStatus: ошибка смены статуса графика #%d на %s: %v", id, status, err)
+		return err
+	}
+	log.Printf("SetRecurringOrderStatus: график #%d переведен в статус %s", id, status)
+	return nil
+}
@@ -0,0 +1,231 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// referralFraudReasons проверяет пару "пригласивший - приглашенный" на признаки самоприглашения:
+// общий телефон, тот же адрес или точка на карте, что в заказах пригласившего, и всплеск приглашений.
+// orderID = 0 - проверка при переходе по ссылке, когда заказа еще нет.
+func referralFraudReasons(inviterID, inviteeID int64, orderID int) ([]string, error) {
+	var inviterChatID, inviteeChatID int64
+	var inviteePhone sql.NullString
+	err := DB.QueryRow(`
+        SELECT inviter.chat_id, invitee.chat_id, invitee.phone
+        FROM users inviter, users invitee
+        WHERE inviter.id = $1 AND invitee.id = $2`, inviterID, inviteeID).Scan(&inviterChatID, &inviteeChatID, &inviteePhone)
+	if err != nil {
+		log.Printf("referralFraudReasons: ошибка чтения пользователей %d и %d: %v", inviterID, inviteeID, err)
+		return nil, err
+	}
+
+	var reasons []string
+	phones := []string{inviteePhone.String}
+	var orderPhone sql.NullString
+	if orderID > 0 {
+		if err = DB.QueryRow(`SELECT phone FROM orders WHERE id = $1`, orderID).Scan(&orderPhone); err != nil {
+			log.Printf("referralFraudReasons: ошибка чтения заказа #%d: %v", orderID, err)
+			return nil, err
+		}
+		phones = append(phones, orderPhone.String)
+	}
+	checkedPhones := make(map[string]bool)
+	for _, phone := range phones {
+		if normalized, errPhone := utils.ValidatePhoneNumber(phone); errPhone == nil {
+			phone = normalized
+		}
+		if phone == "" || checkedPhones[phone] {
+			continue
+		}
+		checkedPhones[phone] = true
+		ownerChatID, errCheck := CheckPhoneNumberExists(phone, inviteeChatID)
+		if errCheck != nil {
+			return nil, errCheck
+		}
+		if ownerChatID == inviterChatID {
+			reasons = append(reasons, fmt.Sprintf("Телефон %s совпадает с телефоном пригласившего", utils.FormatPhoneNumber(phone)))
+			continue
+		}
+		var inviterOrderID int64
+		errOrder := DB.QueryRow(`SELECT id FROM orders WHERE user_id = $1 AND phone = $2 ORDER BY id DESC LIMIT 1`, inviterID, phone).Scan(&inviterOrderID)
+		if errOrder == nil {
+			reasons = append(reasons, fmt.Sprintf("Телефон %s указан в заказе пригласившего #%d", utils.FormatPhoneNumber(phone), inviterOrderID))
+		} else if errOrder != sql.ErrNoRows {
+			log.Printf("referralFraudReasons: ошибка поиска телефона в заказах пригласившего %d: %v", inviterID, errOrder)
+			return nil, errOrder
+		}
+	}
+
+	if orderID > 0 {
+		var sameAddressOrderID int64
+		err = DB.QueryRow(`
+            SELECT o2.id FROM orders o JOIN orders o2 ON o2.user_id = $2
+            WHERE o.id = $1 AND TRIM(COALESCE(o.address, '')) <> ''
+              AND LOWER(TRIM(o2.address)) = LOWER(TRIM(o.address))
+            ORDER BY o2.id DESC LIMIT 1`, orderID, inviterID).Scan(&sameAddressOrderID)
+		if err == nil {
+			reasons = append(reasons, fmt.Sprintf("Адрес совпадает с адресом заказа пригласившего #%d", sameAddressOrderID))
+		} else if err != sql.ErrNoRows {
+			log.Printf("referralFraudReasons: ошибка сравнения адресов заказа #%d: %v", orderID, err)
+			return nil, err
+		} else {
+			// Адрес записан по-разному, но точка на карте та же. Введенный текстом адрес хранится с координатами 0, 0
+			var nearbyOrderID int64
+			var distance float64
+			err = DB.QueryRow(`
+                SELECT o2.id, 6371000 * SQRT(POWER(RADIANS(o2.latitude - o.latitude), 2) +
+                       POWER(RADIANS(o2.longitude - o.longitude) * COS(RADIANS(o.latitude)), 2)) AS distance
+                FROM orders o JOIN orders o2 ON o2.user_id = $2
+                WHERE o.id = $1 AND o.latitude IS NOT NULL AND o.longitude IS NOT NULL
+                  AND o2.latitude IS NOT NULL AND o2.longitude IS NOT NULL
+                  AND NOT (o.latitude = 0 AND o.longitude = 0)
+                  AND NOT (o2.latitude = 0 AND o2.longitude = 0)
+                ORDER BY distance LIMIT 1`, orderID, inviterID).Scan(&nearbyOrderID, &distance)
+			if err == nil && distance <= constants.REFERRAL_FRAUD_NEARBY_METERS {
+				reasons = append(reasons, fmt.Sprintf("Точка на карте в %.0f м от заказа пригласившего #%d", distance, nearbyOrderID))
+			} else if err != nil && err != sql.ErrNoRows {
+				log.Printf("referralFraudReasons: ошибка сравнения координат заказа #%d: %v", orderID, err)
+				return nil, err
+			}
+		}
+	}
+
+	var recentInvitees int
+	err = DB.QueryRow(`
+        SELECT COUNT(*) FROM users
+        WHERE referred_by_user_id = $1 AND referred_at >= NOW() - make_interval(hours => $2)`,
+		inviterID, constants.REFERRAL_FRAUD_BURST_WINDOW_HOURS).Scan(&recentInvitees)
+	if err != nil {
+		log.Printf("referralFraudReasons: ошибка подсчета приглашенных пользователя %d: %v", inviterID, err)
+		return nil, err
+	}
+	if recentInvitees > constants.REFERRAL_FRAUD_BURST_LIMIT {
+		reasons = append(reasons, fmt.Sprintf("Всплеск приглашений: %d за %d ч", recentInvitees, constants.REFERRAL_FRAUD_BURST_WINDOW_HOURS))
+	}
+	return reasons, nil
+}
+
+// mergeReferralFraudReasons объединяет причины без повторов, сохраняя порядок.
+func mergeReferralFraudReasons(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var merged []string
+	for _, list := range lists {
+		for _, reason := range list {
+			if reason = strings.TrimSpace(reason); reason != "" && !seen[reason] {
+				seen[reason] = true
+				merged = append(merged, reason)
+			}
+		}
+	}
+	return merged
+}
+
+// GetReferralsOnReview возвращает удержанные на проверке бонусы, старые первыми.
+func GetReferralsOnReview(limit, offset int) ([]models.Referral, int, error) {
+	var total int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM referrals WHERE review_status = $1`, constants.REFERRAL_REVIEW_STATUS_PENDING).Scan(&total); err != nil {
+		log.Printf("GetReferralsOnReview: ошибка подсчета бонусов на проверке: %v", err)
+		return nil, 0, err
+	}
+	rows, err := DB.Query(`
+        SELECT r.id, r.inviter_id, r.invitee_id, COALESCE(r.order_id, 0), r.amount, r.created_at, r.rule_id, rr.version,
+               r.review_status, r.review_reasons, r.review_comment,
+               TRIM(COALESCE(invitee.first_name, '') || ' ' || COALESCE(invitee.last_name, '')),
+               TRIM(COALESCE(inviter.first_name, '') || ' ' || COALESCE(inviter.last_name, ''))
+        FROM referrals r
+        JOIN users invitee ON invitee.id = r.invitee_id
+        JOIN users inviter ON inviter.id = r.inviter_id
+        LEFT JOIN referral_rules rr ON rr.id = r.rule_id
+        WHERE r.review_status = $1
+        ORDER BY r.created_at, r.id
+        LIMIT $2 OFFSET $3`, constants.REFERRAL_REVIEW_STATUS_PENDING, limit, offset)
+	if err != nil {
+		log.Printf("GetReferralsOnReview: ошибка получения бонусов на проверке: %v", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var referrals []models.Referral
+	for rows.Next() {
+		var r models.Referral
+		if err = rows.Scan(&r.ID, &r.InviterID, &r.InviteeID, &r.OrderID, &r.Amount, &r.CreatedAt, &r.RuleID, &r.RuleVersion,
+			&r.ReviewStatus, (*pq.StringArray)(&r.ReviewReasons), &r.ReviewComment, &r.Name, &r.InviterName); err != nil {
+			log.Printf("GetReferralsOnReview: ошибка сканирования бонуса: %v", err)
+			return nil, 0, err
+		}
+		referrals = append(referrals, r)
+	}
+	return referrals, total, rows.Err()
+}
+
+// ReviewReferral фиксирует решение владельца по удержанному бонусу. Подтвержденный бонус
+// проводится в главной книге, и с этого момента отсчитывается срок его действия; отклоненный не выплачивается.
+func ReviewReferral(referralID int64, approve bool, reviewerUserID int64, comment string) (models.Referral, error) {
+	var r models.Referral
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ReviewReferral: ошибка начала транзакции: %v", err)
+		return r, err
+	}
+	defer tx.Rollback()
+
+	var status sql.NullString
+	var expiryDays sql.NullInt64
+	err = tx.QueryRow(`
+        SELECT r.inviter_id, r.invitee_id, COALESCE(r.order_id, 0), r.amount, r.created_at, r.review_status, r.review_reasons, rr.expiry_days
+        FROM referrals r LEFT JOIN referral_rules rr ON rr.id = r.rule_id
+        WHERE r.id = $1
+        FOR UPDATE OF r`, referralID).Scan(&r.InviterID, &r.InviteeID, &r.OrderID, &r.Amount, &r.CreatedAt, &status,
+		(*pq.StringArray)(&r.ReviewReasons), &expiryDays)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return r, fmt.Errorf("реферальный бонус #%d не найден", referralID)
+		}
+		log.Printf("ReviewReferral: ошибка чтения бонуса #%d: %v", referralID, err)
+		return r, err
+	}
+	if status.String != constants.REFERRAL_REVIEW_STATUS_PENDING {
+		return r, fmt.Errorf("бонус #%d не ожидает проверки", referralID)
+	}
+
+	newStatus := constants.REFERRAL_REVIEW_STATUS_REJECTED
+	var expiresAt sql.NullTime
+	if approve {
+		newStatus = constants.REFERRAL_REVIEW_STATUS_APPROVED
+		if expiryDays.Int64 > 0 {
+			expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, int(expiryDays.Int64)), Valid: true}
+		}
+	}
+	_, err = tx.Exec(`
+        UPDATE referrals SET review_status = $2, review_comment = $3, reviewed_at = NOW(), reviewed_by_user_id = $4,
+            expires_at = $5, updated_at = NOW()
+        WHERE id = $1`, referralID, newStatus, sql.NullString{String: comment, Valid: comment != ""}, reviewerUserID, expiresAt)
+	if err != nil {
+		log.Printf("ReviewReferral: ошибка сохранения решения по бонусу #%d: %v", referralID, err)
+		return r, err
+	}
+	if err = syncReferralLedgerInTx(tx, referralID); err != nil {
+		log.Printf("ReviewReferral: ошибка проводки бонуса #%d в главной книге: %v", referralID, err)
+		return r, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("ReviewReferral: ошибка коммита транзакции: %v", err)
+		return r, err
+	}
+
+	r.ID = referralID
+	r.ReviewStatus = sql.NullString{String: newStatus, Valid: true}
+	r.ReviewComment = sql.NullString{String: comment, Valid: comment != ""}
+	r.ExpiresAt = expiresAt
+	log.Printf("ReviewReferral: пользователь %d вынес решение '%s' по бонусу #%d", reviewerUserID, newStatus, referralID)
+	return r, nil
+}
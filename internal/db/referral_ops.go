@@ -3,9 +3,11 @@ package db
 import (
 	"Original/internal/constants"
 	"Original/internal/models" // Используем Original как имя модуля / Use Original as module name
+	"Original/internal/utils"
 	"database/sql"
 	"fmt"
 	"log"
+	"math"
 	"strings"
 	"time"
 
@@ -32,6 +34,13 @@ func AddReferral(inviterChatID, inviteeChatID int64, orderID int, amount float64
 		return 0, fmt.Errorf("приглашенный пользователь (chat_id %d) не найден: %w", inviteeChatID, err)
 	}
 
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("AddReferral: ошибка начала транзакции: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	var id int64
 	// paid_out по умолчанию FALSE, payout_request_id по умолчанию NULL при создании
 	// paid_out defaults to FALSE, payout_request_id defaults to NULL on creation
@@ -39,11 +48,20 @@ func AddReferral(inviterChatID, inviteeChatID int64, orderID int, amount float64
         INSERT INTO referrals (inviter_id, invitee_id, order_id, amount, created_at, updated_at, paid_out, payout_request_id)
         VALUES ($1, $2, $3, $4, NOW(), NOW(), FALSE, NULL) 
         RETURNING id`
-	err = DB.QueryRow(query, inviterUserID, inviteeUserID, orderID, amount).Scan(&id)
+	err = tx.QueryRow(query, inviterUserID, inviteeUserID, orderID, amount).Scan(&id)
 	if err != nil {
 		log.Printf("AddReferral: ошибка добавления реферала (inviter_id %d, invitee_id %d, order_id %d): %v", inviterUserID, inviteeUserID, orderID, err)
 		return 0, err
 	}
+	// Бонус начисляется пригласившему сразу: он становится обязательством компании до выплаты.
+	if err = syncReferralLedgerInTx(tx, id); err != nil {
+		log.Printf("AddReferral: ошибка проводки реферала #%d в главной книге: %v", id, err)
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("AddReferral: ошибка коммита транзакции: %v", err)
+		return 0, err
+	}
 	log.Printf("Реферал #%d успешно добавлен.", id)
 	return id, nil
 }
@@ -65,9 +83,11 @@ func GetReferralsByInviterChatID(inviterChatID int64) ([]models.Referral, error)
 
 	rows, err := DB.Query(`
         SELECT r.id, r.inviter_id, r.invitee_id, r.order_id, r.amount, r.created_at, r.paid_out, r.payout_request_id,
+               r.rule_id, rr.version, r.expires_at, r.expired_at, r.review_status, r.review_reasons, r.review_comment,
                u_invitee.first_name, u_invitee.last_name -- Имя приглашенного / Invitee's name
         FROM referrals r
         JOIN users u_invitee ON r.invitee_id = u_invitee.id
+        LEFT JOIN referral_rules rr ON rr.id = r.rule_id
         WHERE r.inviter_id = $1
         ORDER BY r.created_at DESC`, inviterUserID)
 	if err != nil {
@@ -89,6 +109,13 @@ func GetReferralsByInviterChatID(inviterChatID int64) ([]models.Referral, error)
 			&r.CreatedAt,
 			&r.PaidOut,
 			&r.PayoutRequestID, // sql.NullInt64
+			&r.RuleID,
+			&r.RuleVersion,
+			&r.ExpiresAt,
+			&r.ExpiredAt,
+			&r.ReviewStatus,
+			(*pq.StringArray)(&r.ReviewReasons),
+			&r.ReviewComment,
 			&inviteeFirstName,
 			&inviteeLastName,
 		)
@@ -184,9 +211,11 @@ func GetReferralByID(referralID int64, currentUserChatID int64) (models.Referral
 	var inviteeFirstName, inviteeLastName sql.NullString
 	query := `
         SELECT r.id, r.inviter_id, r.invitee_id, r.order_id, r.amount, r.created_at, r.paid_out, r.payout_request_id,
+               r.rule_id, rr.version, r.expires_at, r.expired_at, r.review_status, r.review_reasons, r.review_comment,
                u_invitee.first_name, u_invitee.last_name
         FROM referrals r
         JOIN users u_invitee ON r.invitee_id = u_invitee.id
+        LEFT JOIN referral_rules rr ON rr.id = r.rule_id
         WHERE r.id = $1 AND r.inviter_id = $2` // Проверяем, что текущий пользователь - пригласивший / Check that current user is the inviter
 
 	err = DB.QueryRow(query, referralID, inviterUserID).Scan(
@@ -198,6 +227,13 @@ func GetReferralByID(referralID int64, currentUserChatID int64) (models.Referral
 		&r.CreatedAt,
 		&r.PaidOut,
 		&r.PayoutRequestID,
+		&r.RuleID,
+		&r.RuleVersion,
+		&r.ExpiresAt,
+		&r.ExpiredAt,
+		&r.ReviewStatus,
+		(*pq.StringArray)(&r.ReviewReasons),
+		&r.ReviewComment,
 		&inviteeFirstName,
 		&inviteeLastName,
 	)
@@ -229,6 +265,15 @@ func GetReferralByID(referralID int64, currentUserChatID int64) (models.Referral
 // CreateReferralPayoutRequest creates a new referral bonus payout request.
 func CreateReferralPayoutRequest(request models.ReferralPayoutRequest) (int64, error) {
 	var requestID int64
+	// Номер карты хранится зашифрованным, как и users.card_number
+	if request.PaymentMethod.String == constants.REFERRAL_PAYOUT_METHOD_CARD && request.PaymentDetails.Valid && request.PaymentDetails.String != "" {
+		encryptedCard, errEncrypt := utils.EncryptCardNumber(request.PaymentDetails.String)
+		if errEncrypt != nil {
+			log.Printf("CreateReferralPayoutRequest: ошибка шифрования номера карты: %v", errEncrypt)
+			return 0, errEncrypt
+		}
+		request.PaymentDetails = sql.NullString{String: encryptedCard, Valid: true}
+	}
 	tx, err := DB.Begin()
 	if err != nil {
 		log.Printf("CreateReferralPayoutRequest: ошибка начала транзакции: %v", err)
@@ -260,7 +305,7 @@ func CreateReferralPayoutRequest(request models.ReferralPayoutRequest) (int64, e
 	// 2. Обновляем referral.payout_request_id для всех включенных рефералов
 	// 2. Update referral.payout_request_id for all included referrals
 	if len(request.ReferralIDs) > 0 {
-		stmtUpdateReferral, errPrepare := tx.Prepare(`UPDATE referrals SET payout_request_id = $1, updated_at = NOW() WHERE id = ANY($2) AND payout_request_id IS NULL AND paid_out = FALSE`)
+		stmtUpdateReferral, errPrepare := tx.Prepare(`UPDATE referrals SET payout_request_id = $1, updated_at = NOW() WHERE id = ANY($2) AND payout_request_id IS NULL AND paid_out = FALSE AND expired_at IS NULL AND (expires_at IS NULL OR expires_at > NOW()) AND (review_status IS NULL OR review_status = 'approved')`)
 		if errPrepare != nil {
 			log.Printf("CreateReferralPayoutRequest: ошибка подготовки обновления рефералов: %v", errPrepare)
 			return 0, errPrepare
@@ -275,8 +320,9 @@ func CreateReferralPayoutRequest(request models.ReferralPayoutRequest) (int64, e
 		rowsAffected, _ := res.RowsAffected()
 		log.Printf("CreateReferralPayoutRequest: обновлено %d рефералов для запроса #%d.", rowsAffected, requestID)
 		if rowsAffected != int64(len(request.ReferralIDs)) {
-			log.Printf("CreateReferralPayoutRequest: ВНИМАНИЕ! Ожидалось обновление %d рефералов, но обновлено %d. Возможно, некоторые уже были в запросе или выплачены.", len(request.ReferralIDs), rowsAffected)
-			// Это не обязательно ошибка, но стоит залогировать / Not necessarily an error, but worth logging
+			// Сумма запроса посчитана по всем бонусам: если часть уже в запросе, выплачена или сгорела, запрос неверен
+			log.Printf("CreateReferralPayoutRequest: ожидалось обновление %d рефералов, но обновлено %d. Часть бонусов уже в запросе, выплачена или сгорела.", len(request.ReferralIDs), rowsAffected)
+			return 0, fmt.Errorf("часть бонусов уже недоступна для выплаты")
 		}
 	} else {
 		log.Printf("CreateReferralPayoutRequest: Запрос на выплату #%d создан без ID рефералов.", requestID)
@@ -308,18 +354,37 @@ func MarkReferralsAsPaidOutByRequestID(payoutRequestID int64, paid bool) error {
 	return nil
 }
 
+const referralPayoutRequestColumns = `r.id, r.user_chat_id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')),
+	r.amount, r.status, r.requested_at, r.admin_comment, r.processed_at, r.payment_method, r.payment_details, p.id`
+
+const referralPayoutRequestFrom = `FROM referral_payout_requests r
+	LEFT JOIN users u ON u.chat_id = r.user_chat_id
+	LEFT JOIN payouts p ON p.referral_payout_request_id = r.id AND p.reversed_at IS NULL`
+
+// scanReferralPayoutRequest читает запрос по referralPayoutRequestColumns и расшифровывает номер карты.
+func scanReferralPayoutRequest(row rowScanner) (models.ReferralPayoutRequest, error) {
+	var req models.ReferralPayoutRequest
+	err := row.Scan(&req.ID, &req.UserChatID, &req.UserName, &req.Amount, &req.Status, &req.RequestedAt,
+		&req.AdminComment, &req.ProcessedAt, &req.PaymentMethod, &req.PaymentDetails, &req.PayoutID)
+	if err != nil {
+		return req, err
+	}
+	if req.PaymentMethod.String == constants.REFERRAL_PAYOUT_METHOD_CARD && req.PaymentDetails.Valid && req.PaymentDetails.String != "" {
+		decryptedCard, errDecrypt := utils.DecryptCardNumber(req.PaymentDetails.String)
+		if errDecrypt != nil {
+			log.Printf("scanReferralPayoutRequest: ошибка дешифрования номера карты для запроса #%d: %v", req.ID, errDecrypt)
+			req.PaymentDetails = sql.NullString{}
+		} else {
+			req.PaymentDetails = sql.NullString{String: decryptedCard, Valid: true}
+		}
+	}
+	return req, nil
+}
+
 // GetReferralPayoutRequestByID получает запрос на выплату по ID.
 // GetReferralPayoutRequestByID retrieves a payout request by ID.
 func GetReferralPayoutRequestByID(requestID int64) (models.ReferralPayoutRequest, error) {
-	var req models.ReferralPayoutRequest
-	// var referralIDs pq.Int64Array // Для чтения массива из БД (если бы он хранился в JSON) / For reading array from DB (if stored in JSON)
-
-	query := `SELECT id, user_chat_id, amount, status, requested_at, admin_comment, processed_at, payment_method, payment_details
-              FROM referral_payout_requests WHERE id = $1`
-	err := DB.QueryRow(query, requestID).Scan(
-		&req.ID, &req.UserChatID, &req.Amount, &req.Status, &req.RequestedAt,
-		&req.AdminComment, &req.ProcessedAt, &req.PaymentMethod, &req.PaymentDetails,
-	)
+	req, err := scanReferralPayoutRequest(DB.QueryRow(`SELECT `+referralPayoutRequestColumns+` `+referralPayoutRequestFrom+` WHERE r.id = $1`, requestID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return req, fmt.Errorf("запрос на выплату с ID %d не найден", requestID)
@@ -350,10 +415,156 @@ func GetReferralPayoutRequestByID(requestID int64) (models.ReferralPayoutRequest
 	return req, nil
 }
 
+// GetReferralPayoutRequests возвращает запросы на выплату с указанным статусом (пустой - все), старые ожидающие первыми.
+func GetReferralPayoutRequests(status string, limit, offset int) ([]models.ReferralPayoutRequest, int, error) {
+	where := ""
+	args := []interface{}{}
+	if status != "" {
+		where = "WHERE r.status = $1"
+		args = append(args, status)
+	}
+	var total int
+	if err := DB.QueryRow(`SELECT COUNT(*) FROM referral_payout_requests r `+where, args...).Scan(&total); err != nil {
+		log.Printf("GetReferralPayoutRequests: ошибка подсчета запросов (статус '%s'): %v", status, err)
+		return nil, 0, err
+	}
+	order := "r.requested_at DESC, r.id DESC"
+	if status == constants.PAYOUT_REQUEST_STATUS_PENDING {
+		order = "r.requested_at, r.id"
+	}
+	query := fmt.Sprintf(`SELECT %s %s %s ORDER BY %s LIMIT $%d OFFSET $%d`,
+		referralPayoutRequestColumns, referralPayoutRequestFrom, where, order, len(args)+1, len(args)+2)
+	rows, err := DB.Query(query, append(args, limit, offset)...)
+	if err != nil {
+		log.Printf("GetReferralPayoutRequests: ошибка получения запросов (статус '%s'): %v", status, err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var requests []models.ReferralPayoutRequest
+	for rows.Next() {
+		req, err := scanReferralPayoutRequest(rows)
+		if err != nil {
+			log.Printf("GetReferralPayoutRequests: ошибка сканирования запроса: %v", err)
+			return nil, 0, err
+		}
+		requests = append(requests, req)
+	}
+	return requests, total, rows.Err()
+}
+
+// lockPendingReferralPayoutRequestInTx блокирует запрос на выплату и проверяет, что он еще ожидает решения.
+func lockPendingReferralPayoutRequestInTx(tx *sql.Tx, requestID int64) (int64, float64, error) {
+	var userChatID int64
+	var amount float64
+	var status string
+	err := tx.QueryRow(`SELECT user_chat_id, amount, status FROM referral_payout_requests WHERE id = $1 FOR UPDATE`, requestID).
+		Scan(&userChatID, &amount, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, fmt.Errorf("запрос на выплату #%d не найден", requestID)
+		}
+		return 0, 0, err
+	}
+	if status != constants.PAYOUT_REQUEST_STATUS_PENDING {
+		return 0, 0, fmt.Errorf("запрос на выплату #%d уже обработан (статус: %s)", requestID, constants.PayoutRequestStatusDisplayMap[status])
+	}
+	return userChatID, amount, nil
+}
+
+// CompleteReferralPayoutRequest фиксирует, что деньги по запросу переведены: создает запись в payouts,
+// помечает бонусы выплаченными и проводит выплату из кассы. Все в одной транзакции.
+func CompleteReferralPayoutRequest(requestID int64, paidByUserID int64, comment string) (models.ReferralPayoutRequest, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CompleteReferralPayoutRequest: ошибка начала транзакции: %v", err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	defer tx.Rollback()
+
+	userChatID, amount, err := lockPendingReferralPayoutRequestInTx(tx, requestID)
+	if err != nil {
+		log.Printf("CompleteReferralPayoutRequest: %v", err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	var userID int64
+	if err = tx.QueryRow(`SELECT id FROM users WHERE chat_id = $1`, userChatID).Scan(&userID); err != nil {
+		log.Printf("CompleteReferralPayoutRequest: не найден пользователь chatID %d для запроса #%d: %v", userChatID, requestID, err)
+		return models.ReferralPayoutRequest{}, fmt.Errorf("клиент запроса #%d не найден", requestID)
+	}
+
+	now := time.Now()
+	payoutComment := fmt.Sprintf("Реферальные бонусы по запросу #%d", requestID)
+	if comment != "" {
+		payoutComment += ": " + comment
+	}
+	if _, err = addPayoutWithinTx(tx, models.Payout{
+		UserID:                  userID,
+		Amount:                  amount,
+		PayoutDate:              now,
+		Comment:                 payoutComment,
+		MadeByUserID:            paidByUserID,
+		ReferralPayoutRequestID: requestID,
+	}); err != nil {
+		return models.ReferralPayoutRequest{}, err
+	}
+	if _, err = tx.Exec(`UPDATE referral_payout_requests SET status = $1, processed_at = $2, admin_comment = $3 WHERE id = $4`,
+		constants.PAYOUT_REQUEST_STATUS_COMPLETED, now, sql.NullString{String: comment, Valid: comment != ""}, requestID); err != nil {
+		log.Printf("CompleteReferralPayoutRequest: ошибка обновления запроса #%d: %v", requestID, err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	if _, err = tx.Exec(`UPDATE referrals SET paid_out = TRUE, updated_at = NOW() WHERE payout_request_id = $1`, requestID); err != nil {
+		log.Printf("CompleteReferralPayoutRequest: ошибка пометки бонусов запроса #%d выплаченными: %v", requestID, err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	if err = syncReferralPayoutRequestLedgerInTx(tx, requestID); err != nil {
+		log.Printf("CompleteReferralPayoutRequest: ошибка проводки запроса #%d в главной книге: %v", requestID, err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CompleteReferralPayoutRequest: ошибка коммита транзакции: %v", err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	log.Printf("CompleteReferralPayoutRequest: запрос #%d на %.0f ₽ выплачен пользователем %d.", requestID, amount, paidByUserID)
+	return GetReferralPayoutRequestByID(requestID)
+}
+
+// RejectReferralPayoutRequest отклоняет запрос с причиной. Бонусы отвязываются от запроса,
+// чтобы клиент мог включить их в следующий запрос.
+func RejectReferralPayoutRequest(requestID int64, rejectedByUserID int64, reason string) (models.ReferralPayoutRequest, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("RejectReferralPayoutRequest: ошибка начала транзакции: %v", err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	defer tx.Rollback()
+
+	if _, _, err = lockPendingReferralPayoutRequestInTx(tx, requestID); err != nil {
+		log.Printf("RejectReferralPayoutRequest: %v", err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	if _, err = tx.Exec(`UPDATE referral_payout_requests SET status = $1, processed_at = NOW(), admin_comment = $2 WHERE id = $3`,
+		constants.PAYOUT_REQUEST_STATUS_REJECTED, sql.NullString{String: reason, Valid: reason != ""}, requestID); err != nil {
+		log.Printf("RejectReferralPayoutRequest: ошибка обновления запроса #%d: %v", requestID, err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	res, err := tx.Exec(`UPDATE referrals SET payout_request_id = NULL, paid_out = FALSE, updated_at = NOW() WHERE payout_request_id = $1`, requestID)
+	if err != nil {
+		log.Printf("RejectReferralPayoutRequest: ошибка освобождения бонусов запроса #%d: %v", requestID, err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("RejectReferralPayoutRequest: ошибка коммита транзакции: %v", err)
+		return models.ReferralPayoutRequest{}, err
+	}
+	freed, _ := res.RowsAffected()
+	log.Printf("RejectReferralPayoutRequest: запрос #%d отклонен пользователем %d, освобождено бонусов: %d.", requestID, rejectedByUserID, freed)
+	return GetReferralPayoutRequestByID(requestID)
+}
+
 // UpdateReferralPayoutRequestStatusAndComment обновляет статус и комментарий администратора для запроса на выплату.
 // UpdateReferralPayoutRequestStatusAndComment updates the status and admin comment for a payout request.
 func UpdateReferralPayoutRequestStatusAndComment(requestID int64, newStatus string, adminComment sql.NullString) error {
-	query := `UPDATE referral_payout_requests SET status = $1, admin_comment = $2, processed_at = $3, payment_details = $4 WHERE id = $5`
+	query := `UPDATE referral_payout_requests SET status = $1, admin_comment = $2, processed_at = $3, payment_details = COALESCE($4, payment_details) WHERE id = $5`
 	var processedAt sql.NullTime
 	var paymentDetailsForUpdate sql.NullString // Для обновления деталей платежа, если нужно / For updating payment details if needed
 
@@ -372,11 +583,17 @@ func UpdateReferralPayoutRequestStatusAndComment(requestID int64, newStatus stri
 		// User's card can be retrieved from request's user_chat_id if needed
 		// paymentDetailsForUpdate = sql.NullString{String: "Одобрено, ожидает выплаты", Valid: true}
 	}
-	if newStatus == constants.PAYOUT_REQUEST_STATUS_COMPLETED {
-		paymentDetailsForUpdate = sql.NullString{String: "Выплачено", Valid: true}
+	// Реквизиты клиента сохраняются: payment_details меняется, только если передано новое значение.
+	// Для выплаты с созданием записи в payouts используйте CompleteReferralPayoutRequest.
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("UpdateReferralPayoutRequestStatus: ошибка начала транзакции: %v", err)
+		return err
 	}
+	defer tx.Rollback()
 
-	result, err := DB.Exec(query, newStatus, adminComment, processedAt, paymentDetailsForUpdate, requestID)
+	result, err := tx.Exec(query, newStatus, adminComment, processedAt, paymentDetailsForUpdate, requestID)
 	if err != nil {
 		log.Printf("UpdateReferralPayoutRequestStatus: ошибка обновления статуса для запроса #%d: %v", requestID, err)
 		return err
@@ -386,24 +603,255 @@ func UpdateReferralPayoutRequestStatusAndComment(requestID int64, newStatus stri
 		return fmt.Errorf("запрос на выплату #%d не найден для обновления статуса", requestID)
 	}
 
-	// Если статус "completed", помечаем связанные рефералы как выплаченные
-	// If status is "completed", mark associated referrals as paid out
-	if newStatus == constants.PAYOUT_REQUEST_STATUS_COMPLETED {
-		errPaid := MarkReferralsAsPaidOutByRequestID(requestID, true)
-		if errPaid != nil {
-			// Логируем ошибку, но основной статус запроса уже обновлен
-			// Log error, but main request status is already updated
-			log.Printf("UpdateReferralPayoutRequestStatus: ошибка маркировки рефералов как выплаченных для запроса #%d: %v", requestID, errPaid)
-		}
-	} else if newStatus == constants.PAYOUT_REQUEST_STATUS_REJECTED || newStatus == constants.PAYOUT_REQUEST_STATUS_PENDING {
-		// Если отклонен или вернули в pending, снимаем пометку о выплате с рефералов
-		// If rejected or returned to pending, unmark referrals as paid out
-		errPaid := MarkReferralsAsPaidOutByRequestID(requestID, false)
-		if errPaid != nil {
-			log.Printf("UpdateReferralPayoutRequestStatus: ошибка снятия маркировки выплаты для рефералов запроса #%d: %v", requestID, errPaid)
+	// Если статус "completed", помечаем связанные рефералы как выплаченные,
+	// если отклонен или вернули в pending - снимаем пометку о выплате.
+	// If status is "completed", mark associated referrals as paid out; if rejected or pending, unmark them.
+	if newStatus == constants.PAYOUT_REQUEST_STATUS_COMPLETED ||
+		newStatus == constants.PAYOUT_REQUEST_STATUS_REJECTED ||
+		newStatus == constants.PAYOUT_REQUEST_STATUS_PENDING {
+		paid := newStatus == constants.PAYOUT_REQUEST_STATUS_COMPLETED
+		if _, err = tx.Exec("UPDATE referrals SET paid_out = $1, updated_at = NOW() WHERE payout_request_id = $2", paid, requestID); err != nil {
+			log.Printf("UpdateReferralPayoutRequestStatus: ошибка обновления paid_out рефералов запроса #%d: %v", requestID, err)
+			return err
 		}
 	}
 
+	// Выплата из кассы проводится только для выполненного запроса и сторнируется при возврате из этого статуса.
+	if err = syncReferralPayoutRequestLedgerInTx(tx, requestID); err != nil {
+		log.Printf("UpdateReferralPayoutRequestStatus: ошибка проводки запроса #%d в главной книге: %v", requestID, err)
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("UpdateReferralPayoutRequestStatus: ошибка коммита транзакции: %v", err)
+		return err
+	}
+
 	log.Printf("Статус запроса на выплату #%d обновлен на %s.", requestID, newStatus)
 	return nil
 }
+
+// SetUserReferrer запоминает, кто пригласил пользователя по реферальной ссылке.
+// Пригласившего можно указать только один раз и только до первого заказа; себя пригласить нельзя.
+// Признаки самоприглашения сохраняются в users.referral_flags: бонус за такого пользователя будет удержан на проверке.
+// Возвращает false, если привязка не выполнена по одному из этих условий.
+func SetUserReferrer(inviteeChatID, inviterChatID int64) (bool, error) {
+	var inviteeID, inviterID int64
+	err := DB.QueryRow(`
+        UPDATE users u SET referred_by_user_id = inviter.id, referred_at = NOW()
+        FROM users inviter
+        WHERE u.chat_id = $1 AND inviter.chat_id = $2 AND inviter.id <> u.id
+          AND u.referred_by_user_id IS NULL
+          AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.user_id = u.id)
+        RETURNING u.id, inviter.id`, inviteeChatID, inviterChatID).Scan(&inviteeID, &inviterID)
+	if err == sql.ErrNoRows {
+		log.Printf("SetUserReferrer: пользователь %d не привязан к пригласившему %d (уже привязан, есть заказы или ссылка недействительна)", inviteeChatID, inviterChatID)
+		return false, nil
+	}
+	if err != nil {
+		log.Printf("SetUserReferrer: ошибка привязки пользователя %d к пригласившему %d: %v", inviteeChatID, inviterChatID, err)
+		return false, err
+	}
+	log.Printf("SetUserReferrer: пользователь %d приглашен пользователем %d", inviteeChatID, inviterChatID)
+
+	reasons, err := referralFraudReasons(inviterID, inviteeID, 0)
+	if err != nil {
+		// Привязка уже сохранена; при начислении бонуса проверка повторится
+		log.Printf("SetUserReferrer: ошибка проверки приглашения %d -> %d: %v", inviterChatID, inviteeChatID, err)
+		return true, nil
+	}
+	if len(reasons) > 0 {
+		if _, err = DB.Exec(`UPDATE users SET referral_flags = $2 WHERE id = $1`, inviteeID, pq.Array(reasons)); err != nil {
+			log.Printf("SetUserReferrer: ошибка сохранения признаков мошенничества для пользователя %d: %v", inviteeChatID, err)
+		}
+		log.Printf("SetUserReferrer: приглашение %d -> %d подозрительно: %s", inviterChatID, inviteeChatID, strings.Join(reasons, "; "))
+	}
+	return true, nil
+}
+
+// AccrueReferralBonusForOrder начисляет пригласившему бонус за выполненный заказ приглашенного
+// по действующей версии условий программы. Бонус начисляется один раз за каждого приглашенного.
+// Бонус с признаками самоприглашения сохраняется со статусом проверки pending и не проводится до решения владельца.
+// Возвращает false без ошибки, если заказ не дает бонуса (нет пригласившего, бонус уже был,
+// заказ не подходит по условиям или исчерпан месячный лимит).
+func AccrueReferralBonusForOrder(orderID int) (models.Referral, bool, error) {
+	var referral models.Referral
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("AccrueReferralBonusForOrder: ошибка начала транзакции: %v", err)
+		return referral, false, err
+	}
+	defer tx.Rollback()
+
+	var inviteeID int64
+	var inviterID sql.NullInt64
+	var category, status sql.NullString
+	var cost sql.NullFloat64
+	var attributionFlags pq.StringArray
+	// Блокируем приглашенного, чтобы бонус за него не начислили дважды при одновременном завершении заказов
+	err = tx.QueryRow(`
+        SELECT u.id, u.referred_by_user_id, u.referral_flags, o.category, o.status, o.cost
+        FROM orders o JOIN users u ON u.id = o.user_id
+        WHERE o.id = $1
+        FOR UPDATE OF u`, orderID).Scan(&inviteeID, &inviterID, &attributionFlags, &category, &status, &cost)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return referral, false, fmt.Errorf("заказ #%d не найден", orderID)
+		}
+		log.Printf("AccrueReferralBonusForOrder: ошибка чтения заказа #%d: %v", orderID, err)
+		return referral, false, err
+	}
+	if !inviterID.Valid {
+		return referral, false, nil
+	}
+	switch status.String {
+	case constants.STATUS_COMPLETED, constants.STATUS_CALCULATED, constants.STATUS_SETTLED:
+	default:
+		return referral, false, fmt.Errorf("заказ #%d еще не выполнен (статус: %s)", orderID, status.String)
+	}
+	var alreadyAccrued bool
+	if err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM referrals WHERE invitee_id = $1)`, inviteeID).Scan(&alreadyAccrued); err != nil {
+		log.Printf("AccrueReferralBonusForOrder: ошибка проверки бонуса за приглашенного %d: %v", inviteeID, err)
+		return referral, false, err
+	}
+	if alreadyAccrued {
+		return referral, false, nil
+	}
+
+	rule, err := scanReferralRule(tx.QueryRow(activeReferralRuleQuery))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("AccrueReferralBonusForOrder: условия реферальной программы не заданы, бонус за заказ #%d не начислен", orderID)
+			return referral, false, nil
+		}
+		log.Printf("AccrueReferralBonusForOrder: ошибка получения действующих условий: %v", err)
+		return referral, false, err
+	}
+	if !rule.AppliesToCategory(category.String) || cost.Float64 < rule.MinOrderAmount {
+		log.Printf("AccrueReferralBonusForOrder: заказ #%d (категория %s, стоимость %.0f) не подходит под условия версии %d", orderID, category.String, cost.Float64, rule.Version)
+		return referral, false, nil
+	}
+
+	amount := rule.BonusValue
+	if rule.BonusType == constants.REFERRAL_BONUS_TYPE_PERCENT {
+		amount = math.Round(cost.Float64 * rule.BonusValue / 100)
+	}
+	if rule.MonthlyCap > 0 {
+		// Пригласившего блокируем, чтобы параллельные начисления не превысили лимит месяца
+		var accruedThisMonth float64
+		_, err = tx.Exec(`SELECT 1 FROM users WHERE id = $1 FOR UPDATE`, inviterID.Int64)
+		if err == nil {
+			err = tx.QueryRow(`
+                SELECT COALESCE(SUM(amount), 0) FROM referrals
+                WHERE inviter_id = $1 AND created_at >= date_trunc('month', NOW())
+                  AND review_status IS DISTINCT FROM $2`, inviterID.Int64, constants.REFERRAL_REVIEW_STATUS_REJECTED).Scan(&accruedThisMonth)
+		}
+		if err != nil {
+			log.Printf("AccrueReferralBonusForOrder: ошибка расчета месячного лимита пригласившего %d: %v", inviterID.Int64, err)
+			return referral, false, err
+		}
+		amount = math.Min(amount, rule.MonthlyCap-accruedThisMonth)
+	}
+	if amount <= 0 {
+		log.Printf("AccrueReferralBonusForOrder: месячный лимит пригласившего %d исчерпан, бонус за заказ #%d не начислен", inviterID.Int64, orderID)
+		return referral, false, nil
+	}
+
+	fraudReasons, err := referralFraudReasons(inviterID.Int64, inviteeID, orderID)
+	if err != nil {
+		return referral, false, err
+	}
+	var reviewStatus sql.NullString
+	reviewReasons := mergeReferralFraudReasons(attributionFlags, fraudReasons)
+	var expiresAt sql.NullTime
+	if len(reviewReasons) > 0 {
+		// Срок действия удержанного бонуса начнется с момента подтверждения
+		reviewStatus = sql.NullString{String: constants.REFERRAL_REVIEW_STATUS_PENDING, Valid: true}
+	} else if rule.ExpiryDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().AddDate(0, 0, rule.ExpiryDays), Valid: true}
+	}
+	err = tx.QueryRow(`
+        INSERT INTO referrals (inviter_id, invitee_id, order_id, amount, created_at, updated_at, paid_out, rule_id, expires_at,
+            review_status, review_reasons)
+        VALUES ($1, $2, $3, $4, NOW(), NOW(), FALSE, $5, $6, $7, $8)
+        RETURNING id, created_at`,
+		inviterID.Int64, inviteeID, orderID, amount, rule.ID, expiresAt, reviewStatus, pq.Array(reviewReasons)).Scan(&referral.ID, &referral.CreatedAt)
+	if err != nil {
+		log.Printf("AccrueReferralBonusForOrder: ошибка добавления бонуса за заказ #%d: %v", orderID, err)
+		return referral, false, err
+	}
+	if err = syncReferralLedgerInTx(tx, referral.ID); err != nil {
+		log.Printf("AccrueReferralBonusForOrder: ошибка проводки реферала #%d в главной книге: %v", referral.ID, err)
+		return referral, false, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("AccrueReferralBonusForOrder: ошибка коммита транзакции: %v", err)
+		return referral, false, err
+	}
+
+	referral.InviterID = inviterID.Int64
+	referral.InviteeID = inviteeID
+	referral.OrderID = orderID
+	referral.Amount = amount
+	referral.RuleID = sql.NullInt64{Int64: rule.ID, Valid: true}
+	referral.RuleVersion = sql.NullInt64{Int64: int64(rule.Version), Valid: true}
+	referral.ExpiresAt = expiresAt
+	referral.ReviewStatus = reviewStatus
+	referral.ReviewReasons = reviewReasons
+	if reviewStatus.Valid {
+		log.Printf("AccrueReferralBonusForOrder: бонус #%d за заказ #%d удержан на проверке: %s", referral.ID, orderID, strings.Join(reviewReasons, "; "))
+	}
+	log.Printf("AccrueReferralBonusForOrder: начислен бонус #%d на %.0f ₽ пригласившему %d за заказ #%d (условия версии %d)", referral.ID, amount, inviterID.Int64, orderID, rule.Version)
+	return referral, true, nil
+}
+
+// ExpireReferralBonuses списывает невыплаченные бонусы с истекшим сроком и сторнирует их начисление.
+// Бонусы, уже включенные в запрос на выплату, не сгорают. Возвращает сгоревшие бонусы.
+func ExpireReferralBonuses() ([]models.Referral, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ExpireReferralBonuses: ошибка начала транзакции: %v", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+        UPDATE referrals SET expired_at = NOW(), updated_at = NOW()
+        WHERE expires_at <= NOW() AND expired_at IS NULL AND paid_out = FALSE AND payout_request_id IS NULL
+        RETURNING id, inviter_id, invitee_id, order_id, amount, created_at, expires_at, expired_at`)
+	if err != nil {
+		log.Printf("ExpireReferralBonuses: ошибка списания бонусов: %v", err)
+		return nil, err
+	}
+	var expired []models.Referral
+	for rows.Next() {
+		var r models.Referral
+		var orderID sql.NullInt64
+		if err = rows.Scan(&r.ID, &r.InviterID, &r.InviteeID, &orderID, &r.Amount, &r.CreatedAt, &r.ExpiresAt, &r.ExpiredAt); err != nil {
+			rows.Close()
+			log.Printf("ExpireReferralBonuses: ошибка сканирования бонуса: %v", err)
+			return nil, err
+		}
+		r.OrderID = int(orderID.Int64)
+		expired = append(expired, r)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		log.Printf("ExpireReferralBonuses: ошибка после итерации по бонусам: %v", err)
+		return nil, err
+	}
+
+	for _, r := range expired {
+		if err = syncReferralLedgerInTx(tx, r.ID); err != nil {
+			log.Printf("ExpireReferralBonuses: ошибка сторно реферала #%d в главной книге: %v", r.ID, err)
+			return nil, err
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("ExpireReferralBonuses: ошибка коммита транзакции: %v", err)
+		return nil, err
+	}
+	if len(expired) > 0 {
+		log.Printf("ExpireReferralBonuses: сгорело бонусов: %d", len(expired))
+	}
+	return expired, nil
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+const referralRuleColumns = `rr.id, rr.version, rr.bonus_type, rr.bonus_value, rr.min_order_amount, rr.categories,
+        rr.monthly_cap, rr.expiry_days, rr.min_payout_amount, rr.effective_from, rr.comment,
+        rr.created_by_user_id, rr.created_at`
+
+// activeReferralRuleQuery - действующая версия условий: последняя из вступивших в силу.
+const activeReferralRuleQuery = `SELECT ` + referralRuleColumns + `
+        FROM referral_rules rr
+        WHERE rr.effective_from <= NOW()
+        ORDER BY rr.effective_from DESC, rr.version DESC
+        LIMIT 1`
+
+func scanReferralRule(row rowScanner) (models.ReferralRule, error) {
+	var r models.ReferralRule
+	var categories pq.StringArray
+	err := row.Scan(&r.ID, &r.Version, &r.BonusType, &r.BonusValue, &r.MinOrderAmount, &categories,
+		&r.MonthlyCap, &r.ExpiryDays, &r.MinPayoutAmount, &r.EffectiveFrom, &r.Comment,
+		&r.CreatedByUserID, &r.CreatedAt)
+	r.Categories = []string(categories)
+	return r, err
+}
+
+// validateReferralRule проверяет параметры новой версии условий.
+func validateReferralRule(rule models.ReferralRule) error {
+	switch rule.BonusType {
+	case constants.REFERRAL_BONUS_TYPE_FIXED:
+		if rule.BonusValue <= 0 {
+			return fmt.Errorf("сумма бонуса должна быть больше нуля")
+		}
+	case constants.REFERRAL_BONUS_TYPE_PERCENT:
+		if rule.BonusValue <= 0 || rule.BonusValue > 100 {
+			return fmt.Errorf("процент бонуса должен быть больше 0 и не больше 100")
+		}
+	default:
+		return fmt.Errorf("неизвестный тип бонуса «%s»", rule.BonusType)
+	}
+	if rule.MinOrderAmount < 0 || rule.MonthlyCap < 0 || rule.MinPayoutAmount < 0 {
+		return fmt.Errorf("минимальный заказ, месячный лимит и порог выплаты не могут быть отрицательными")
+	}
+	if rule.ExpiryDays < 0 {
+		return fmt.Errorf("срок действия бонуса не может быть отрицательным")
+	}
+	for _, category := range rule.Categories {
+		if _, ok := constants.CategoryDisplayMap[category]; !ok {
+			return fmt.Errorf("неизвестная категория «%s»", category)
+		}
+	}
+	return nil
+}
+
+// CreateReferralRule сохраняет новую версию условий реферальной программы.
+// Номер версии назначается автоматически; пустой EffectiveFrom - условия действуют сразу.
+func CreateReferralRule(rule models.ReferralRule) (models.ReferralRule, error) {
+	if err := validateReferralRule(rule); err != nil {
+		return rule, err
+	}
+	var effectiveFrom interface{}
+	if !rule.EffectiveFrom.IsZero() {
+		effectiveFrom = rule.EffectiveFrom
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CreateReferralRule: ошибка начала транзакции: %v", err)
+		return rule, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка таблицы исключает две версии с одним номером при одновременном сохранении
+	if _, err = tx.Exec(`LOCK TABLE referral_rules IN SHARE ROW EXCLUSIVE MODE`); err != nil {
+		log.Printf("CreateReferralRule: ошибка блокировки таблицы: %v", err)
+		return rule, err
+	}
+	var id int64
+	err = tx.QueryRow(`
+        INSERT INTO referral_rules (version, bonus_type, bonus_value, min_order_amount, categories, monthly_cap,
+            expiry_days, min_payout_amount, effective_from, comment, created_by_user_id)
+        SELECT COALESCE(MAX(version), 0) + 1, $1, $2, $3, $4, $5, $6, $7, COALESCE($8::timestamptz, NOW()), $9, $10
+        FROM referral_rules
+        RETURNING id`,
+		rule.BonusType, rule.BonusValue, rule.MinOrderAmount, pq.Array(rule.Categories), rule.MonthlyCap,
+		rule.ExpiryDays, rule.MinPayoutAmount, effectiveFrom, rule.Comment, rule.CreatedByUserID,
+	).Scan(&id)
+	if err != nil {
+		log.Printf("CreateReferralRule: ошибка добавления версии условий: %v", err)
+		return rule, err
+	}
+	created, err := scanReferralRule(tx.QueryRow(`SELECT `+referralRuleColumns+` FROM referral_rules rr WHERE rr.id = $1`, id))
+	if err != nil {
+		log.Printf("CreateReferralRule: ошибка чтения версии #%d: %v", id, err)
+		return rule, err
+	}
+	if err = tx.Commit(); err != nil {
+		log.Printf("CreateReferralRule: ошибка коммита транзакции: %v", err)
+		return rule, err
+	}
+	log.Printf("CreateReferralRule: добавлена версия %d условий реферальной программы (#%d)", created.Version, created.ID)
+	return created, nil
+}
+
+// GetActiveReferralRule возвращает действующую версию условий реферальной программы.
+func GetActiveReferralRule() (models.ReferralRule, error) {
+	rule, err := scanReferralRule(DB.QueryRow(activeReferralRuleQuery))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return rule, fmt.Errorf("условия реферальной программы не заданы")
+		}
+		log.Printf("GetActiveReferralRule: ошибка получения действующих условий: %v", err)
+		return rule, err
+	}
+	return rule, nil
+}
+
+// GetReferralRules возвращает версии условий, начиная с последней.
+func GetReferralRules(limit int) ([]models.ReferralRule, error) {
+	rows, err := DB.Query(`SELECT `+referralRuleColumns+`
+        FROM referral_rules rr
+        ORDER BY rr.version DESC
+        LIMIT $1`, limit)
+	if err != nil {
+		log.Printf("GetReferralRules: ошибка получения версий условий: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.ReferralRule
+	for rows.Next() {
+		r, errScan := scanReferralRule(rows)
+		if errScan != nil {
+			log.Printf("GetReferralRules: ошибка сканирования версии: %v", errScan)
+			return nil, errScan
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
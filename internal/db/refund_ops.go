@@ -0,0 +1,241 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/lib/pq"
+
+	"Original/internal/constants"
+	"Original/internal/models"
+)
+
+// ErrRefundExceedsCaptured возвращается, если запрошенный возврат больше суммы, доступной к возврату по платежу.
+var ErrRefundExceedsCaptured = errors.New("сумма возврата превышает сумму, доступную к возврату")
+
+// refundColumns - список колонок таблицы refunds в порядке, ожидаемом scanRefund.
+const refundColumns = `id, payment_id, order_id, external_id, amount, status, reason, idempotence_key,
+        requested_by_user_id, request_payload, response_payload, created_at, updated_at`
+
+// activeRefundStatuses - статусы возвратов, сумма которых уже считается занятой.
+var activeRefundStatuses = []string{constants.REFUND_STATUS_CREATED, constants.REFUND_STATUS_PENDING, constants.REFUND_STATUS_SUCCEEDED}
+
+// scanRefund считывает строку таблицы refunds в модель.
+func scanRefund(row rowScanner) (models.Refund, error) {
+	var r models.Refund
+	var requestPayload, responsePayload []byte
+	var createdAt, updatedAt sql.NullTime
+	err := row.Scan(
+		&r.ID, &r.PaymentID, &r.OrderID, &r.ExternalID, &r.Amount, &r.Status, &r.Reason, &r.IdempotenceKey,
+		&r.RequestedByUserID, &requestPayload, &responsePayload, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return r, err
+	}
+	r.RequestPayload = requestPayload
+	r.ResponsePayload = responsePayload
+	if createdAt.Valid {
+		r.CreatedAt = createdAt.Time
+	}
+	if updatedAt.Valid {
+		r.UpdatedAt = updatedAt.Time
+	}
+	return r, nil
+}
+
+// refundableAmountInTx возвращает сумму, которую еще можно вернуть по платежу.
+// Строка платежа блокируется до конца транзакции, чтобы параллельные возвраты не превысили оплату.
+func refundableAmountInTx(tx *sql.Tx, paymentID int64) (float64, error) {
+	var amount float64
+	var status string
+	err := tx.QueryRow(`SELECT amount, status FROM payments WHERE id = $1 FOR UPDATE`, paymentID).Scan(&amount, &status)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("платеж #%d не найден", paymentID)
+		}
+		return 0, err
+	}
+	if status != constants.PAYMENT_STATUS_SUCCEEDED {
+		return 0, fmt.Errorf("платеж #%d не оплачен (статус '%s'), возврат невозможен", paymentID, status)
+	}
+
+	var reserved sql.NullFloat64
+	err = tx.QueryRow(`SELECT SUM(amount) FROM refunds WHERE payment_id = $1 AND status = ANY($2)`,
+		paymentID, pq.Array(activeRefundStatuses)).Scan(&reserved)
+	if err != nil {
+		return 0, err
+	}
+	available := amount - reserved.Float64
+	return math.Max(0, math.Round(available*100)/100), nil
+}
+
+// GetRefundableAmount возвращает сумму, которую еще можно вернуть по платежу.
+func GetRefundableAmount(paymentID int64) (float64, error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	available, err := refundableAmountInTx(tx, paymentID)
+	if err != nil {
+		log.Printf("GetRefundableAmount: ошибка расчета доступной к возврату суммы по платежу #%d: %v", paymentID, err)
+		return 0, err
+	}
+	return available, nil
+}
+
+// ReserveRefund создает запись о возврате до обращения к провайдеру.
+// Проверяет, что сумма возврата вместе с уже оформленными возвратами не превышает оплаченную сумму;
+// иначе возвращает ErrRefundExceedsCaptured.
+func ReserveRefund(refund models.Refund) (int64, error) {
+	if refund.Amount <= 0 {
+		return 0, fmt.Errorf("сумма возврата должна быть положительной")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("ReserveRefund: ошибка начала транзакции: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	available, err := refundableAmountInTx(tx, refund.PaymentID)
+	if err != nil {
+		log.Printf("ReserveRefund: %v", err)
+		return 0, err
+	}
+	if refund.Amount > available+0.005 {
+		log.Printf("ReserveRefund: запрошен возврат %.2f по платежу #%d, доступно %.2f.", refund.Amount, refund.PaymentID, available)
+		return 0, fmt.Errorf("%w: запрошено %.2f, доступно %.2f", ErrRefundExceedsCaptured, refund.Amount, available)
+	}
+
+	var id int64
+	err = tx.QueryRow(`
+        INSERT INTO refunds (payment_id, order_id, amount, status, reason, idempotence_key, requested_by_user_id, request_payload, created_at, updated_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
+        RETURNING id`,
+		refund.PaymentID, refund.OrderID, refund.Amount, constants.REFUND_STATUS_CREATED, refund.Reason,
+		refund.IdempotenceKey, refund.RequestedByUserID, nullJSON(refund.RequestPayload),
+	).Scan(&id)
+	if err != nil {
+		log.Printf("ReserveRefund: ошибка создания записи возврата по платежу #%d: %v", refund.PaymentID, err)
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		log.Printf("ReserveRefund: ошибка коммита транзакции: %v", err)
+		return 0, err
+	}
+	log.Printf("ReserveRefund: создан возврат #%d на сумму %.2f по платежу #%d (заказ #%d).", id, refund.Amount, refund.PaymentID, refund.OrderID)
+	return id, nil
+}
+
+// UpdateRefundFromProvider сохраняет состояние возврата, полученное от провайдера.
+func UpdateRefundFromProvider(refundID int64, externalID, status string, responsePayload []byte) error {
+	_, err := DB.Exec(`
+        UPDATE refunds
+        SET external_id = COALESCE(NULLIF($2, ''), external_id),
+            status = $3,
+            response_payload = COALESCE($4::jsonb, response_payload),
+            updated_at = NOW()
+        WHERE id = $1`,
+		refundID, externalID, status, nullJSON(responsePayload))
+	if err != nil {
+		log.Printf("UpdateRefundFromProvider: ошибка обновления возврата #%d: %v", refundID, err)
+		return err
+	}
+	log.Printf("UpdateRefundFromProvider: возврат #%d (%s) обновлен, статус '%s'.", refundID, externalID, status)
+	return nil
+}
+
+// MarkRefundFailed отмечает возврат, который провайдер отказался создать. Зарезервированная сумма освобождается.
+func MarkRefundFailed(refundID int64, responsePayload []byte) error {
+	_, err := DB.Exec(`
+        UPDATE refunds
+        SET status = $2, response_payload = COALESCE($3::jsonb, response_payload), updated_at = NOW()
+        WHERE id = $1`,
+		refundID, constants.REFUND_STATUS_FAILED, nullJSON(responsePayload))
+	if err != nil {
+		log.Printf("MarkRefundFailed: ошибка обновления возврата #%d: %v", refundID, err)
+	}
+	return err
+}
+
+// GetRefundByExternalID возвращает возврат по его ID у провайдера.
+// Если запись не найдена, возвращается sql.ErrNoRows.
+func GetRefundByExternalID(externalID string) (models.Refund, error) {
+	row := DB.QueryRow(`SELECT `+refundColumns+` FROM refunds WHERE external_id = $1`, externalID)
+	r, err := scanRefund(row)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("GetRefundByExternalID: ошибка получения возврата %s: %v", externalID, err)
+	}
+	return r, err
+}
+
+// GetRefundsByOrderID возвращает все возвраты по заказу, начиная с последнего.
+func GetRefundsByOrderID(orderID int64) ([]models.Refund, error) {
+	return queryRefunds(`SELECT `+refundColumns+` FROM refunds WHERE order_id = $1 ORDER BY created_at DESC`, orderID)
+}
+
+// GetRefundsForReconciliation возвращает возвраты в статусе pending, не обновлявшиеся дольше minAge.
+func GetRefundsForReconciliation(minAge time.Duration) ([]models.Refund, error) {
+	return queryRefunds(`
+        SELECT `+refundColumns+`
+        FROM refunds
+        WHERE status = $1 AND external_id IS NOT NULL
+          AND updated_at < NOW() - make_interval(secs => $2)
+        ORDER BY created_at`,
+		constants.REFUND_STATUS_PENDING, minAge.Seconds())
+}
+
+// GetStaleCreatedRefunds возвращает возвраты, оставшиеся в статусе created без ответа провайдера дольше minAge:
+// запрос мог не дойти до провайдера или ответ потерялся из-за сетевой ошибки.
+func GetStaleCreatedRefunds(minAge time.Duration) ([]models.Refund, error) {
+	return queryRefunds(`
+        SELECT `+refundColumns+`
+        FROM refunds
+        WHERE status = $1 AND external_id IS NULL
+          AND updated_at < NOW() - make_interval(secs => $2)
+        ORDER BY created_at`,
+		constants.REFUND_STATUS_CREATED, minAge.Seconds())
+}
+
+// queryRefunds выполняет запрос и считывает список возвратов.
+func queryRefunds(query string, args ...interface{}) ([]models.Refund, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		log.Printf("queryRefunds: ошибка выборки возвратов: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []models.Refund
+	for rows.Next() {
+		r, errScan := scanRefund(rows)
+		if errScan != nil {
+			log.Printf("queryRefunds: ошибка сканирования возврата: %v", errScan)
+			continue
+		}
+		result = append(result, r)
+	}
+	if err = rows.Err(); err != nil {
+		log.Printf("queryRefunds: ошибка после итерации по возвратам: %v", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// SaveRefundRequestPayload сохраняет тело запроса к провайдеру, если оно еще не записано.
+func SaveRefundRequestPayload(refundID int64, payload []byte) error {
+	_, err := DB.Exec(`UPDATE refunds SET request_payload = COALESCE(request_payload, $2::jsonb), updated_at = NOW() WHERE id = $1`,
+		refundID, nullJSON(payload))
+	if err != nil {
+		log.Printf("SaveRefundRequestPayload: ошибка сохранения запроса для возврата #%d: %v", refundID, err)
+	}
+	return err
+}
@@ -221,6 +221,33 @@ func UpdateUserPhone(chatID int64, phone string) error {
 	return nil
 }
 
+// UpdateUserEmail сохраняет email пользователя для отправки фискальных чеков. Пустая строка удаляет email.
+// UpdateUserEmail stores the user's email used as a receipt contact. An empty string clears it.
+func UpdateUserEmail(chatID int64, email string) error {
+	_, err := DB.Exec("UPDATE users SET email=NULLIF($1, ''), updated_at=NOW() WHERE chat_id=$2", email, chatID)
+	if err != nil {
+		log.Printf("UpdateUserEmail: ошибка сохранения email для chatID %d: %v", chatID, err)
+		return err
+	}
+	log.Printf("Email для чеков chatID %d обновлен", chatID)
+	return nil
+}
+
+// GetUserEmail возвращает email пользователя для чеков или пустую строку, если он не указан.
+// GetUserEmail returns the user's receipt email or an empty string if none is set.
+func GetUserEmail(userID int64) (string, error) {
+	var email sql.NullString
+	err := DB.QueryRow("SELECT email FROM users WHERE id = $1", userID).Scan(&email)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		log.Printf("GetUserEmail: ошибка получения email для userID %d: %v", userID, err)
+		return "", err
+	}
+	return email.String, nil
+}
+
 // CheckPhoneNumberExists проверяет, используется ли номер телефона другим пользователем.
 // Возвращает chat_id существующего пользователя или 0, если номер не найден или ошибка.
 // CheckPhoneNumberExists checks if a phone number is used by another user.
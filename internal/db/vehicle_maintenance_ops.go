@@ -0,0 +1,252 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// GetVehicleCompliance проверяет допуск машины на дату asOf. Текущий пробег берется
+// как наибольшее из показаний в отчетах водителей и при последнем ТО.
+func GetVehicleCompliance(v models.Vehicle, asOf time.Time) (models.VehicleCompliance, error) {
+	odometer, err := GetLastOdometerReading(v.ID)
+	if err != nil {
+		return models.VehicleCompliance{}, err
+	}
+	if v.LastServiceOdometer.Valid && (!odometer.Valid || v.LastServiceOdometer.Int64 > odometer.Int64) {
+		odometer = v.LastServiceOdometer
+	}
+	return v.CheckCompliance(asOf, odometer, constants.VEHICLE_EXPIRY_WARNING_DAYS, constants.VEHICLE_SERVICE_WARNING_KM), nil
+}
+
+// SetVehicleDocuments задает сроки ОСАГО и техосмотра и интервалы ТО машины.
+func SetVehicleDocuments(vehicleID int64, osagoExpiresOn, inspectionExpiresOn sql.NullTime, serviceIntervalKm int64, serviceIntervalDays int) error {
+	if serviceIntervalKm < 0 || serviceIntervalDays < 0 {
+		return fmt.Errorf("интервалы ТО не могут быть отрицательными")
+	}
+	result, err := DB.Exec(`
+        UPDATE vehicles SET osago_expires_on = $1, inspection_expires_on = $2, service_interval_km = $3,
+               service_interval_days = $4, compliance_notified_on = NULL, updated_at = NOW()
+        WHERE id = $5`, osagoExpiresOn, inspectionExpiresOn, serviceIntervalKm, serviceIntervalDays, vehicleID)
+	if err != nil {
+		log.Printf("SetVehicleDocuments: ошибка обновления документов машины #%d: %v", vehicleID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("машина #%d не найдена", vehicleID)
+	}
+	log.Printf("SetVehicleDocuments: обновлены документы и интервалы ТО машины #%d", vehicleID)
+	return nil
+}
+
+// AddVehicleMaintenance сохраняет запись об обслуживании и проводит ее стоимость расходом из кассы.
+// ТО обновляет дату и пробег последнего обслуживания, ОСАГО и техосмотр с valid_until - срок документа.
+func AddVehicleMaintenance(m models.VehicleMaintenance) (int64, error) {
+	if _, known := constants.VehicleMaintenanceKindDisplayMap[m.Kind]; !known {
+		return 0, fmt.Errorf("неизвестный вид обслуживания '%s'", m.Kind)
+	}
+	if m.Cost < 0 {
+		return 0, fmt.Errorf("стоимость обслуживания не может быть отрицательной")
+	}
+
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("AddVehicleMaintenance: ошибка начала транзакции: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err = ensurePeriodsOpen(tx, m.PerformedOn); err != nil {
+		return 0, err
+	}
+	var id int64
+	err = tx.QueryRow(`
+        INSERT INTO vehicle_maintenance (vehicle_id, kind, performed_on, odometer, cost, description, valid_until, created_by_user_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id`,
+		m.VehicleID, m.Kind, m.PerformedOn, m.Odometer, m.Cost, m.Description, m.ValidUntil, m.CreatedByUserID,
+	).Scan(&id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23503" {
+			return 0, fmt.Errorf("машина #%d не найдена", m.VehicleID)
+		}
+		log.Printf("AddVehicleMaintenance: ошибка добавления записи для машины #%d: %v", m.VehicleID, err)
+		return 0, err
+	}
+
+	switch m.Kind {
+	case constants.VEHICLE_MAINTENANCE_SERVICE:
+		// Более ранняя запись, внесенная задним числом, не сдвигает последнее ТО назад
+		_, err = tx.Exec(`
+            UPDATE vehicles SET
+                last_service_odometer = CASE WHEN last_service_on IS NULL OR $2 >= last_service_on
+                                             THEN COALESCE($3, last_service_odometer) ELSE last_service_odometer END,
+                last_service_on = GREATEST(COALESCE(last_service_on, $2), $2),
+                compliance_notified_on = NULL, updated_at = NOW()
+            WHERE id = $1`, m.VehicleID, m.PerformedOn, m.Odometer)
+	case constants.VEHICLE_MAINTENANCE_OSAGO:
+		if m.ValidUntil.Valid {
+			_, err = tx.Exec(`UPDATE vehicles SET osago_expires_on = $2, compliance_notified_on = NULL, updated_at = NOW() WHERE id = $1`, m.VehicleID, m.ValidUntil)
+		}
+	case constants.VEHICLE_MAINTENANCE_INSPECTION:
+		if m.ValidUntil.Valid {
+			_, err = tx.Exec(`UPDATE vehicles SET inspection_expires_on = $2, compliance_notified_on = NULL, updated_at = NOW() WHERE id = $1`, m.VehicleID, m.ValidUntil)
+		}
+	}
+	if err != nil {
+		log.Printf("AddVehicleMaintenance: ошибка обновления машины #%d по записи #%d: %v", m.VehicleID, id, err)
+		return 0, err
+	}
+
+	if err := syncVehicleMaintenanceLedgerInTx(tx, id); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("AddVehicleMaintenance: ошибка коммита записи #%d: %v", id, err)
+		return 0, err
+	}
+	log.Printf("AddVehicleMaintenance: машина #%d, %s, %.2f ₽ (запись #%d)", m.VehicleID, m.Kind, m.Cost, id)
+	return id, nil
+}
+
+// CancelVehicleMaintenance отменяет запись об обслуживании и сторнирует ее проводку.
+// Даты ТО и сроки документов машины не откатываются: их владелец исправляет вручную.
+func CancelVehicleMaintenance(maintenanceID int64) (vehicleID int64, err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		log.Printf("CancelVehicleMaintenance: ошибка начала транзакции: %v", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var performedOn time.Time
+	err = tx.QueryRow(`UPDATE vehicle_maintenance SET canceled_at = NOW() WHERE id = $1 AND canceled_at IS NULL RETURNING vehicle_id, performed_on`,
+		maintenanceID).Scan(&vehicleID, &performedOn)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("запись об обслуживании #%d не найдена или уже отменена", maintenanceID)
+	}
+	if err != nil {
+		log.Printf("CancelVehicleMaintenance: ошибка отмены записи #%d: %v", maintenanceID, err)
+		return 0, err
+	}
+	if err := ensurePeriodsOpen(tx, performedOn); err != nil {
+		return 0, err
+	}
+	if err := syncVehicleMaintenanceLedgerInTx(tx, maintenanceID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("CancelVehicleMaintenance: ошибка коммита отмены записи #%d: %v", maintenanceID, err)
+		return 0, err
+	}
+	log.Printf("CancelVehicleMaintenance: запись об обслуживании #%d отменена", maintenanceID)
+	return vehicleID, nil
+}
+
+// GetVehicleMaintenance возвращает последние записи об обслуживании машины, включая отмененные.
+func GetVehicleMaintenance(vehicleID int64, limit int) ([]models.VehicleMaintenance, error) {
+	rows, err := DB.Query(`
+        SELECT id, vehicle_id, kind, performed_on, odometer, cost, description, valid_until, created_by_user_id, created_at, canceled_at
+        FROM vehicle_maintenance
+        WHERE vehicle_id = $1
+        ORDER BY performed_on DESC, id DESC
+        LIMIT $2`, vehicleID, limit)
+	if err != nil {
+		log.Printf("GetVehicleMaintenance: ошибка получения записей машины #%d: %v", vehicleID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.VehicleMaintenance
+	for rows.Next() {
+		var m models.VehicleMaintenance
+		if err := rows.Scan(&m.ID, &m.VehicleID, &m.Kind, &m.PerformedOn, &m.Odometer, &m.Cost, &m.Description,
+			&m.ValidUntil, &m.CreatedByUserID, &m.CreatedAt, &m.CanceledAt); err != nil {
+			log.Printf("GetVehicleMaintenance: ошибка сканирования записи: %v", err)
+			return nil, err
+		}
+		records = append(records, m)
+	}
+	return records, rows.Err()
+}
+
+// GetVehicleMaintenanceCosts возвращает стоимость обслуживания по машинам за период [from, to] без отмененных записей.
+func GetVehicleMaintenanceCosts(from, to time.Time) (map[int64]float64, error) {
+	rows, err := DB.Query(`
+        SELECT vehicle_id, SUM(cost) FROM vehicle_maintenance
+        WHERE canceled_at IS NULL AND performed_on BETWEEN $1 AND $2
+        GROUP BY vehicle_id`, from, to)
+	if err != nil {
+		log.Printf("GetVehicleMaintenanceCosts: ошибка расчета расходов на обслуживание: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	costs := make(map[int64]float64)
+	for rows.Next() {
+		var vehicleID int64
+		var cost float64
+		if err := rows.Scan(&vehicleID, &cost); err != nil {
+			return nil, err
+		}
+		costs[vehicleID] = cost
+	}
+	return costs, rows.Err()
+}
+
+// GetVehiclesDueComplianceNotice возвращает работающие машины с проблемами допуска или приближающимися сроками,
+// о которых владельца сегодня еще не предупреждали.
+func GetVehiclesDueComplianceNotice(asOf time.Time) ([]models.Vehicle, []models.VehicleCompliance, error) {
+	rows, err := DB.Query(`SELECT `+vehicleColumns+`
+        FROM vehicles v
+        LEFT JOIN users u ON u.id = v.default_driver_user_id
+        WHERE v.is_active = TRUE AND (v.compliance_notified_on IS NULL OR v.compliance_notified_on < $1::date)
+        ORDER BY v.plate`, asOf)
+	if err != nil {
+		log.Printf("GetVehiclesDueComplianceNotice: ошибка получения машин: %v", err)
+		return nil, nil, err
+	}
+	var candidates []models.Vehicle
+	for rows.Next() {
+		v, errScan := scanVehicle(rows)
+		if errScan != nil {
+			rows.Close()
+			log.Printf("GetVehiclesDueComplianceNotice: ошибка сканирования машины: %v", errScan)
+			return nil, nil, errScan
+		}
+		candidates = append(candidates, v)
+	}
+	rows.Close()
+
+	var vehicles []models.Vehicle
+	var compliances []models.VehicleCompliance
+	for _, v := range candidates {
+		compliance, errCompliance := GetVehicleCompliance(v, asOf)
+		if errCompliance != nil {
+			return nil, nil, errCompliance
+		}
+		if len(compliance.Issues) == 0 && len(compliance.Warnings) == 0 {
+			continue
+		}
+		vehicles = append(vehicles, v)
+		compliances = append(compliances, compliance)
+	}
+	return vehicles, compliances, nil
+}
+
+// MarkVehiclesComplianceNotified отмечает, что владельца предупредили о машинах в дату asOf.
+func MarkVehiclesComplianceNotified(vehicleIDs []int64, asOf time.Time) error {
+	if len(vehicleIDs) == 0 {
+		return nil
+	}
+	_, err := DB.Exec(`UPDATE vehicles SET compliance_notified_on = $2::date WHERE id = ANY($1)`, pq.Array(vehicleIDs), asOf)
+	if err != nil {
+		log.Printf("MarkVehiclesComplianceNotified: ошибка отметки уведомления: %v", err)
+	}
+	return err
+}
@@ -0,0 +1,446 @@
+package db
+
+import (
+	"Original/internal/constants"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const vehicleColumns = `v.id, v.plate, v.model, v.capacity_m3, v.capacity_tonnes, v.fuel_norm_l_per_100km, v.fuel_price_per_l,
+        v.default_driver_user_id, TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')) AS driver_name,
+        v.osago_expires_on, v.inspection_expires_on, v.service_interval_km, v.service_interval_days,
+        v.last_service_on, v.last_service_odometer, v.is_active, v.created_at, v.updated_at`
+
+func scanVehicle(row rowScanner) (models.Vehicle, error) {
+	var v models.Vehicle
+	var driverName sql.NullString
+	err := row.Scan(&v.ID, &v.Plate, &v.Model, &v.CapacityM3, &v.CapacityTonnes, &v.FuelNormLPer100Km, &v.FuelPricePerL,
+		&v.DefaultDriverUserID, &driverName, &v.OsagoExpiresOn, &v.InspectionExpiresOn, &v.ServiceIntervalKm, &v.ServiceIntervalDays,
+		&v.LastServiceOn, &v.LastServiceOdometer, &v.IsActive, &v.CreatedAt, &v.UpdatedAt)
+	v.DefaultDriverName = driverName.String
+	return v, err
+}
+
+func validateVehicle(v models.Vehicle) error {
+	if strings.TrimSpace(v.Plate) == "" {
+		return fmt.Errorf("не указан госномер")
+	}
+	if v.CapacityM3 < 0 || v.CapacityTonnes < 0 || v.FuelNormLPer100Km < 0 || v.FuelPricePerL < 0 {
+		return fmt.Errorf("вместимость, норма расхода и цена топлива не могут быть отрицательными")
+	}
+	return nil
+}
+
+// CreateVehicle добавляет машину в автопарк.
+func CreateVehicle(v models.Vehicle) (int64, error) {
+	if err := validateVehicle(v); err != nil {
+		return 0, err
+	}
+	var id int64
+	err := DB.QueryRow(`
+        INSERT INTO vehicles (plate, model, capacity_m3, capacity_tonnes, fuel_norm_l_per_100km, fuel_price_per_l, default_driver_user_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+        RETURNING id`,
+		strings.TrimSpace(v.Plate), v.Model, v.CapacityM3, v.CapacityTonnes, v.FuelNormLPer100Km, v.FuelPricePerL, v.DefaultDriverUserID,
+	).Scan(&id)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return 0, fmt.Errorf("машина с госномером %s уже есть в автопарке", v.Plate)
+		}
+		log.Printf("CreateVehicle: ошибка добавления машины %s: %v", v.Plate, err)
+		return 0, err
+	}
+	log.Printf("CreateVehicle: добавлена машина #%d (%s)", id, v.Plate)
+	return id, nil
+}
+
+// UpdateVehicle обновляет параметры машины.
+func UpdateVehicle(v models.Vehicle) error {
+	if err := validateVehicle(v); err != nil {
+		return err
+	}
+	result, err := DB.Exec(`
+        UPDATE vehicles SET plate = $1, model = $2, capacity_m3 = $3, capacity_tonnes = $4,
+               fuel_norm_l_per_100km = $5, fuel_price_per_l = $6, default_driver_user_id = $7, updated_at = NOW()
+        WHERE id = $8`,
+		strings.TrimSpace(v.Plate), v.Model, v.CapacityM3, v.CapacityTonnes, v.FuelNormLPer100Km, v.FuelPricePerL, v.DefaultDriverUserID, v.ID)
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return fmt.Errorf("машина с госномером %s уже есть в автопарке", v.Plate)
+		}
+		log.Printf("UpdateVehicle: ошибка обновления машины #%d: %v", v.ID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("машина #%d не найдена", v.ID)
+	}
+	log.Printf("UpdateVehicle: машина #%d обновлена", v.ID)
+	return nil
+}
+
+// SetVehicleActive выводит машину из работы или возвращает в нее. История отчетов сохраняется.
+func SetVehicleActive(vehicleID int64, active bool) error {
+	result, err := DB.Exec(`UPDATE vehicles SET is_active = $1, updated_at = NOW() WHERE id = $2`, active, vehicleID)
+	if err != nil {
+		log.Printf("SetVehicleActive: ошибка изменения статуса машины #%d: %v", vehicleID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("машина #%d не найдена", vehicleID)
+	}
+	log.Printf("SetVehicleActive: машина #%d, активна: %v", vehicleID, active)
+	return nil
+}
+
+// GetVehicles возвращает машины автопарка; при activeOnly - только работающие.
+func GetVehicles(activeOnly bool) ([]models.Vehicle, error) {
+	query := `SELECT ` + vehicleColumns + `
+        FROM vehicles v
+        LEFT JOIN users u ON u.id = v.default_driver_user_id`
+	if activeOnly {
+		query += ` WHERE v.is_active = TRUE`
+	}
+	query += ` ORDER BY v.is_active DESC, v.plate`
+	rows, err := DB.Query(query)
+	if err != nil {
+		log.Printf("GetVehicles: ошибка получения машин: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vehicles []models.Vehicle
+	for rows.Next() {
+		v, errScan := scanVehicle(rows)
+		if errScan != nil {
+			log.Printf("GetVehicles: ошибка сканирования машины: %v", errScan)
+			return nil, errScan
+		}
+		vehicles = append(vehicles, v)
+	}
+	return vehicles, rows.Err()
+}
+
+// GetVehicleByID возвращает машину по ID.
+func GetVehicleByID(vehicleID int64) (models.Vehicle, error) {
+	v, err := scanVehicle(DB.QueryRow(`SELECT `+vehicleColumns+`
+        FROM vehicles v
+        LEFT JOIN users u ON u.id = v.default_driver_user_id
+        WHERE v.id = $1`, vehicleID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return v, fmt.Errorf("машина #%d не найдена", vehicleID)
+		}
+		log.Printf("GetVehicleByID: ошибка получения машины #%d: %v", vehicleID, err)
+	}
+	return v, err
+}
+
+// SetOrderVehicle назначает машину на заказ; невалидный vehicleID снимает назначение.
+func SetOrderVehicle(orderID int64, vehicleID sql.NullInt64) error {
+	if vehicleID.Valid {
+		vehicle, err := GetVehicleByID(vehicleID.Int64)
+		if err != nil {
+			return err
+		}
+		if !vehicle.IsActive {
+			return fmt.Errorf("машина %s выведена из работы", vehicle.Plate)
+		}
+		compliance, errCompliance := GetVehicleCompliance(vehicle, complianceDateForOrder(orderID))
+		if errCompliance != nil {
+			return errCompliance
+		}
+		if !compliance.Allowed() {
+			return fmt.Errorf("машина %s не допущена к работе: %s", vehicle.Plate, strings.Join(compliance.Issues, "; "))
+		}
+	}
+	result, err := DB.Exec(`UPDATE orders SET vehicle_id = $1, updated_at = NOW() WHERE id = $2`, vehicleID, orderID)
+	if err != nil {
+		log.Printf("SetOrderVehicle: ошибка назначения машины на заказ #%d: %v", orderID, err)
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("заказ #%d не найден", orderID)
+	}
+	log.Printf("SetOrderVehicle: заказ #%d, машина %v", orderID, vehicleID)
+	return nil
+}
+
+// complianceDateForOrder - дата, на которую проверяется допуск машины к заказу:
+// дата заказа, а для прошедших и незаданных дат - сегодня.
+func complianceDateForOrder(orderID int64) time.Time {
+	asOf := time.Now()
+	var orderDate sql.NullTime
+	if err := DB.QueryRow(`SELECT date FROM orders WHERE id = $1`, orderID).Scan(&orderDate); err == nil && orderDate.Valid && orderDate.Time.After(asOf) {
+		asOf = orderDate.Time
+	}
+	return asOf
+}
+
+// GetOrderVehicleID возвращает машину, назначенную на заказ.
+func GetOrderVehicleID(orderID int64) (sql.NullInt64, error) {
+	var vehicleID sql.NullInt64
+	err := DB.QueryRow(`SELECT vehicle_id FROM orders WHERE id = $1`, orderID).Scan(&vehicleID)
+	if err != nil {
+		log.Printf("GetOrderVehicleID: ошибка получения машины заказа #%d: %v", orderID, err)
+	}
+	return vehicleID, err
+}
+
+// GetVehicleSuggestionsForOrder подбирает машины для заказа: сначала подходящие по вместимости,
+// свободные в дату заказа и закрепленные за назначенным водителем, затем меньшие по вместимости.
+// Нулевые requiredM3 и requiredTonnes означают, что объем груза неизвестен.
+func GetVehicleSuggestionsForOrder(orderID int64, requiredM3, requiredTonnes float64) ([]models.VehicleSuggestion, error) {
+	vehicles, err := GetVehicles(true)
+	if err != nil {
+		return nil, err
+	}
+	assignedVehicleID, err := GetOrderVehicleID(orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := make(map[int64][]int64)
+	rows, err := DB.Query(`
+        SELECT o.vehicle_id, o.id
+        FROM orders o
+        JOIN orders target ON target.id = $1
+        WHERE o.id <> target.id AND o.vehicle_id IS NOT NULL AND o.date = target.date
+          AND o.status NOT IN ($2, $3, $4)`,
+		orderID, constants.STATUS_CANCELED, constants.STATUS_DRAFT, constants.STATUS_COMPLETED)
+	if err != nil {
+		log.Printf("GetVehicleSuggestionsForOrder: ошибка поиска занятых машин для заказа #%d: %v", orderID, err)
+		return nil, err
+	}
+	for rows.Next() {
+		var vehicleID, otherOrderID int64
+		if errScan := rows.Scan(&vehicleID, &otherOrderID); errScan != nil {
+			rows.Close()
+			return nil, errScan
+		}
+		busy[vehicleID] = append(busy[vehicleID], otherOrderID)
+	}
+	rows.Close()
+
+	assignedDrivers := make(map[int64]bool)
+	if executors, errExec := GetExecutorsByOrderID(int(orderID)); errExec == nil {
+		for _, exec := range executors {
+			if exec.Role == constants.ROLE_DRIVER {
+				assignedDrivers[exec.UserID] = true
+			}
+		}
+	}
+
+	asOf := complianceDateForOrder(orderID)
+	suggestions := make([]models.VehicleSuggestion, 0, len(vehicles))
+	for _, v := range vehicles {
+		compliance, errCompliance := GetVehicleCompliance(v, asOf)
+		if errCompliance != nil {
+			return nil, errCompliance
+		}
+		suggestions = append(suggestions, models.VehicleSuggestion{
+			ComplianceIssues: compliance.Issues,
+			Vehicle:          v,
+			FitsCapacity:     v.FitsCapacity(requiredM3, requiredTonnes),
+			BusyOrderIDs:     busy[v.ID],
+			DriverMatches:    v.DefaultDriverUserID.Valid && assignedDrivers[v.DefaultDriverUserID.Int64],
+			IsAssigned:       assignedVehicleID.Valid && assignedVehicleID.Int64 == v.ID,
+		})
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if (len(a.ComplianceIssues) == 0) != (len(b.ComplianceIssues) == 0) {
+			return len(a.ComplianceIssues) == 0
+		}
+		if a.FitsCapacity != b.FitsCapacity {
+			return a.FitsCapacity
+		}
+		if (len(a.BusyOrderIDs) == 0) != (len(b.BusyOrderIDs) == 0) {
+			return len(a.BusyOrderIDs) == 0
+		}
+		if a.DriverMatches != b.DriverMatches {
+			return a.DriverMatches
+		}
+		// Из подходящих предлагаем меньшую машину, чтобы не гонять большую под мелкий заказ
+		return a.Vehicle.CapacityM3 < b.Vehicle.CapacityM3
+	})
+	return suggestions, nil
+}
+
+// ResolveSettlementVehicle подбирает машину для отчета водителя: чаще всего встречающуюся
+// в покрытых заказах, иначе закрепленную за водителем.
+func ResolveSettlementVehicle(driverUserID int64, orderIDs []int64) (sql.NullInt64, error) {
+	var vehicleID sql.NullInt64
+	if len(orderIDs) > 0 {
+		err := DB.QueryRow(`
+            SELECT o.vehicle_id FROM orders o
+            JOIN vehicles v ON v.id = o.vehicle_id AND v.is_active = TRUE
+            WHERE o.id = ANY($1)
+            GROUP BY o.vehicle_id ORDER BY COUNT(*) DESC, o.vehicle_id LIMIT 1`, pq.Array(orderIDs)).Scan(&vehicleID)
+		if err != nil && err != sql.ErrNoRows {
+			log.Printf("ResolveSettlementVehicle: ошибка подбора машины по заказам водителя %d: %v", driverUserID, err)
+			return vehicleID, err
+		}
+		if vehicleID.Valid {
+			return vehicleID, nil
+		}
+	}
+	err := DB.QueryRow(`SELECT id FROM vehicles WHERE default_driver_user_id = $1 AND is_active = TRUE ORDER BY id LIMIT 1`,
+		driverUserID).Scan(&vehicleID)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("ResolveSettlementVehicle: ошибка получения машины водителя %d: %v", driverUserID, err)
+		return vehicleID, err
+	}
+	return vehicleID, nil
+}
+
+// GetLastOdometerReading возвращает последние показания одометра машины из отчетов водителей.
+func GetLastOdometerReading(vehicleID int64) (sql.NullInt64, error) {
+	var reading sql.NullInt64
+	err := DB.QueryRow(`
+        SELECT odometer_end FROM driver_settlements
+        WHERE vehicle_id = $1 AND odometer_end IS NOT NULL AND status <> $2
+        ORDER BY settlement_timestamp DESC, id DESC LIMIT 1`,
+		vehicleID, constants.SETTLEMENT_STATUS_REJECTED).Scan(&reading)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("GetLastOdometerReading: ошибка получения показаний одометра машины #%d: %v", vehicleID, err)
+		return reading, err
+	}
+	return reading, nil
+}
+
+// fuelPer100Km считает расход на 100 км и отклонение от нормы. Литры оцениваются по цене литра;
+// если цена или норма не заданы, отклонение не считается.
+func fuelPer100Km(fuelExpense float64, mileageKm int64, vehicle models.Vehicle) (rubPer100, litersPer100, deviationPct float64, comparable bool) {
+	if mileageKm <= 0 {
+		return 0, 0, 0, false
+	}
+	rubPer100 = fuelExpense * 100 / float64(mileageKm)
+	if vehicle.FuelPricePerL <= 0 {
+		return rubPer100, 0, 0, false
+	}
+	litersPer100 = rubPer100 / vehicle.FuelPricePerL
+	if vehicle.FuelNormLPer100Km <= 0 {
+		return rubPer100, litersPer100, 0, false
+	}
+	deviationPct = (litersPer100 - vehicle.FuelNormLPer100Km) / vehicle.FuelNormLPer100Km * 100
+	return rubPer100, litersPer100, deviationPct, true
+}
+
+// GetVehicleFuelReport собирает расход топлива по машинам за период [from, to] по неотклоненным отчетам.
+// Аномалией считается отклонение от нормы больше constants.FUEL_NORM_TOLERANCE в любую сторону
+// и расход топлива при нулевом пробеге.
+func GetVehicleFuelReport(from, to time.Time) (models.VehicleFuelReport, error) {
+	report := models.VehicleFuelReport{From: from, To: to, Tolerance: constants.FUEL_NORM_TOLERANCE}
+
+	vehicles, err := GetVehicles(false)
+	if err != nil {
+		return report, err
+	}
+	vehiclesByID := make(map[int64]models.Vehicle, len(vehicles))
+	for _, v := range vehicles {
+		vehiclesByID[v.ID] = v
+	}
+
+	rows, err := DB.Query(`
+        SELECT ds.id, ds.vehicle_id, ds.report_date, ds.fuel_expense, ds.odometer_start, ds.odometer_end,
+               TRIM(COALESCE(u.first_name, '') || ' ' || COALESCE(u.last_name, '')) AS driver_name
+        FROM driver_settlements ds
+        LEFT JOIN users u ON u.id = ds.driver_user_id
+        WHERE ds.vehicle_id IS NOT NULL AND ds.status <> $1
+          AND ds.report_date BETWEEN $2 AND $3
+        ORDER BY ds.report_date, ds.id`,
+		constants.SETTLEMENT_STATUS_REJECTED, from, to)
+	if err != nil {
+		log.Printf("GetVehicleFuelReport: ошибка получения отчетов водителей: %v", err)
+		return report, err
+	}
+	defer rows.Close()
+
+	stats := make(map[int64]*models.VehicleFuelStat)
+	var order []int64
+	for rows.Next() {
+		var s models.DriverSettlement
+		var vehicleID int64
+		var driverName sql.NullString
+		if errScan := rows.Scan(&s.ID, &vehicleID, &s.ReportDate, &s.FuelExpense, &s.OdometerStart, &s.OdometerEnd, &driverName); errScan != nil {
+			log.Printf("GetVehicleFuelReport: ошибка сканирования отчета: %v", errScan)
+			return report, errScan
+		}
+		vehicle := vehiclesByID[vehicleID]
+		stat, ok := stats[vehicleID]
+		if !ok {
+			stat = &models.VehicleFuelStat{VehicleID: vehicleID, Vehicle: vehicle.DisplayName(), FuelNormLPer100Km: vehicle.FuelNormLPer100Km}
+			stats[vehicleID] = stat
+			order = append(order, vehicleID)
+		}
+		stat.SettlementsCount++
+
+		mileage, hasMileage := s.MileageKm()
+		if !hasMileage {
+			stat.WithoutOdometerCount++
+			continue
+		}
+		stat.MileageKm += mileage
+		stat.FuelExpense += s.FuelExpense
+
+		anomaly := models.VehicleFuelAnomaly{
+			SettlementID: s.ID, VehicleID: vehicleID, Vehicle: vehicle.DisplayName(), DriverName: driverName.String,
+			ReportDate: s.ReportDate, MileageKm: mileage, FuelExpense: s.FuelExpense,
+		}
+		if mileage == 0 {
+			if s.FuelExpense > 0 {
+				anomaly.Reason = "расход топлива при нулевом пробеге"
+				report.Anomalies = append(report.Anomalies, anomaly)
+			}
+			continue
+		}
+		_, litersPer100, deviation, comparable := fuelPer100Km(s.FuelExpense, mileage, vehicle)
+		if comparable && math.Abs(deviation) > constants.FUEL_NORM_TOLERANCE*100 {
+			anomaly.LitersPer100Km = litersPer100
+			anomaly.DeviationPct = deviation
+			if deviation > 0 {
+				anomaly.Reason = "перерасход относительно нормы"
+			} else {
+				anomaly.Reason = "расход ниже нормы, проверьте пробег"
+			}
+			report.Anomalies = append(report.Anomalies, anomaly)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	maintenanceCosts, err := GetVehicleMaintenanceCosts(from, to)
+	if err != nil {
+		return report, err
+	}
+	for vehicleID := range maintenanceCosts {
+		if _, ok := stats[vehicleID]; !ok {
+			vehicle := vehiclesByID[vehicleID]
+			stats[vehicleID] = &models.VehicleFuelStat{VehicleID: vehicleID, Vehicle: vehicle.DisplayName(), FuelNormLPer100Km: vehicle.FuelNormLPer100Km}
+			order = append(order, vehicleID)
+		}
+	}
+
+	for _, vehicleID := range order {
+		stat := stats[vehicleID]
+		vehicle := vehiclesByID[vehicleID]
+		var comparable bool
+		stat.RubPer100Km, stat.LitersPer100Km, stat.DeviationPct, comparable = fuelPer100Km(stat.FuelExpense, stat.MileageKm, vehicle)
+		if vehicle.FuelPricePerL > 0 {
+			stat.FuelLiters = stat.FuelExpense / vehicle.FuelPricePerL
+		}
+		stat.IsAnomalous = comparable && math.Abs(stat.DeviationPct) > constants.FUEL_NORM_TOLERANCE*100
+		stat.MaintenanceCost = maintenanceCosts[vehicleID]
+		report.Vehicles = append(report.Vehicles, *stat)
+	}
+	return report, nil
+}
@@ -3,6 +3,7 @@ package formatters
 import (
 	"Original/internal/constants"
 	"Original/internal/models"
+	"Original/internal/payments"
 	"Original/internal/utils"
 	"fmt"
 	"strings"
@@ -12,6 +13,13 @@ const (
 	separator = "─ ─ ─ ─ ─ ─ ─ ─ ─ ─ ─ ─ ─"
 )
 
+// writeOrderDetailLines добавляет в блок деталей заказа объем и доступ (этаж, лифт, грузчики).
+func writeOrderDetailLines(sb *strings.Builder, order models.Order) {
+	for _, line := range utils.FormatOrderDetailLines(order) {
+		sb.WriteString(fmt.Sprintf(" •  %s\n", utils.EscapeTelegramMarkdown(line)))
+	}
+}
+
 // FormatOrderConfirmationForUser форматирует сообщение для клиента на этапе подтверждения создания заказа.
 // На этом этапе еще нет ID заказа, стоимости от оператора и исполнителей.
 func FormatOrderConfirmationForUser(orderData models.Order) string {
@@ -30,9 +38,9 @@ func FormatOrderConfirmationForUser(orderData models.Order) string {
 	if timeStr == "" {
 		timeStr = "В ближайшее время"
 	}
-	paymentStr := "По выполнению"
-	if orderData.Payment == "now" {
-		paymentStr = "Сразу (скидка 5%)"
+	paymentStr := payments.MethodDisplayName(orderData.Payment)
+	if payments.IsPrepaidMethod(orderData.Payment) {
+		paymentStr += " (скидка 5%)"
 	}
 
 	summaryBuilder.WriteString("📋 *ДЕТАЛИ ЗАКАЗА:*\n")
@@ -44,6 +52,7 @@ func FormatOrderConfirmationForUser(orderData models.Order) string {
 		utils.EscapeTelegramMarkdown(formattedDate),
 		utils.EscapeTelegramMarkdown(timeStr)))
 
+	writeOrderDetailLines(&summaryBuilder, orderData)
 	if orderData.Description != "" {
 		summaryBuilder.WriteString(fmt.Sprintf(" •  Описание: %s\n", utils.EscapeTelegramMarkdown(orderData.Description)))
 	}
@@ -100,9 +109,9 @@ func FormatOrderDetailsForUser(order models.Order, assignedExecutors []models.Ex
 
 	// --- Блок "Финансы" ---
 	if order.Cost.Valid && order.Cost.Float64 > 0 {
-		paymentStr := "По выполнению"
-		if order.Payment == "now" {
-			paymentStr = "Сразу (скидка 5%)"
+		paymentStr := payments.MethodDisplayName(order.Payment)
+		if payments.IsPrepaidMethod(order.Payment) {
+			paymentStr += " (скидка 5%)"
 		}
 		summaryBuilder.WriteString("💰 *ФИНАНСЫ:*\n")
 		summaryBuilder.WriteString(fmt.Sprintf(" •  Стоимость: *%.0f ₽*\n", order.Cost.Float64))
@@ -162,6 +171,7 @@ func FormatOrderDetailsForOperator(order models.Order, client models.User, assig
 	summaryBuilder.WriteString(fmt.Sprintf(" •  Дата и время: %s, %s\n",
 		utils.EscapeTelegramMarkdown(formattedDate),
 		utils.EscapeTelegramMarkdown(timeStr)))
+	writeOrderDetailLines(&summaryBuilder, order)
 	if order.Description != "" {
 		summaryBuilder.WriteString(fmt.Sprintf(" •  Описание: %s\n", utils.EscapeTelegramMarkdown(order.Description)))
 	}
@@ -171,9 +181,9 @@ func FormatOrderDetailsForOperator(order models.Order, client models.User, assig
 	summaryBuilder.WriteString("\n")
 
 	// --- Блок "Финансы" ---
-	paymentStr := "По выполнению"
-	if order.Payment == "now" {
-		paymentStr = "Сразу (скидка 5%)"
+	paymentStr := payments.MethodDisplayName(order.Payment)
+	if payments.IsPrepaidMethod(order.Payment) {
+		paymentStr += " (скидка 5%)"
 	}
 	costDisplay := "не установлена"
 	if order.Cost.Valid && order.Cost.Float64 > 0 {
@@ -186,6 +196,10 @@ func FormatOrderDetailsForOperator(order models.Order, client models.User, assig
 
 	// --- Блок "Исполнители" ---
 	summaryBuilder.WriteString("👷 *НАЗНАЧЕННЫЕ ИСПОЛНИТЕЛИ:*\n")
+	if order.RequiredDrivers.Valid || order.RequiredLoaders.Valid {
+		summaryBuilder.WriteString(fmt.Sprintf(" •  Нужно по выбранному варианту: %s\n",
+			utils.FormatQuoteComposition(order.RequiredDrivers.Int64, order.RequiredLoaders.Int64)))
+	}
 	if len(assignedExecutors) == 0 {
 		summaryBuilder.WriteString(" •  _Не назначены_\n")
 	} else {
@@ -207,6 +221,19 @@ func FormatOrderDetailsForOperator(order models.Order, client models.User, assig
 		title, separator, summaryBuilder.String(), separator, footer)
 }
 
+// FormatCostBreakdown форматирует расшифровку стоимости заказа списком статей.
+// Для заказа без расшифровки возвращает пустую строку.
+func FormatCostBreakdown(items []models.OrderCostItem) string {
+	if len(items) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	for _, item := range items {
+		sb.WriteString(fmt.Sprintf(" •  %s: %.0f ₽\n", utils.EscapeTelegramMarkdown(item.Description), item.Amount))
+	}
+	return sb.String()
+}
+
 // FormatTaskForExecutor форматирует сообщение с заданием для водителя или грузчика.
 // Скрывает финансовую информацию.
 func FormatTaskForExecutor(order models.Order, client models.User, brigade []models.Executor) string {
@@ -233,6 +260,7 @@ func FormatTaskForExecutor(order models.Order, client models.User, brigade []mod
 	summaryBuilder.WriteString(fmt.Sprintf(" •  Дата и время: %s, %s\n",
 		utils.EscapeTelegramMarkdown(formattedDate),
 		utils.EscapeTelegramMarkdown(timeStr)))
+	writeOrderDetailLines(&summaryBuilder, order)
 	if order.Description != "" {
 		summaryBuilder.WriteString(fmt.Sprintf(" •  Описание: %s\n", utils.EscapeTelegramMarkdown(order.Description)))
 	}
@@ -46,7 +46,7 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		"stats_get_current_week", "stats_get_current_month", "stats_get_last_week", "stats_get_last_month",
 		"stats_select_custom_date", "stats_select_custom_period", "stats_select_month", "stats_select_day",
 		"stats_year_nav", "send_excel_menu", "excel_generate_orders", "excel_generate_referrals",
-		"excel_generate_salaries",
+		"excel_generate_salaries", constants.CALLBACK_PREFIX_STATS_PROFIT_LOSS, constants.CALLBACK_PREFIX_STATS_ACCOUNTING_EXPORT,
 	}
 	userBlockingCommands := []string{
 		"block_user_menu", "block_user_list_prompt", "block_user_info", "block_user_reason_prompt",
@@ -68,6 +68,12 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_ADD_LOADER_PROMPT,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_DELETE_LOADER_CONFIRM,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_LOADER,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_ACCEPT_LOADER_RATE,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADER_QUANTITIES,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_VEHICLE,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_VEHICLE,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_ODOMETER,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_SAVE_FINAL,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_CANCEL_ALL,
 	}
@@ -91,13 +97,74 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		constants.CALLBACK_PREFIX_OWNER_CASH_MARK_SALARY_UNPAID,
 		constants.CALLBACK_PREFIX_OWNER_MARK_ALL_SALARY_PAID,
 		constants.CALLBACK_PREFIX_OWNER_MARK_ALL_MONEY_DEPOSITED,
+		constants.CALLBACK_PREFIX_OWNER_LEDGER_CHECK,
+		constants.CALLBACK_PREFIX_OWNER_COMP_RULES,
+		constants.CALLBACK_PREFIX_OWNER_COMP_RULE_ADD,
+		constants.CALLBACK_PREFIX_OWNER_COMP_RULE_DELETE,
+		constants.CALLBACK_PREFIX_OWNER_LOADER_RATES,
+		constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_ADD,
+		constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_DELETE,
+		constants.CALLBACK_PREFIX_OWNER_STATEMENTS,
+		constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_NEW,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_LINE,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_RECALC,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_POST,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_DELETE,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLES,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_ADD,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_EDIT,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_TOGGLE,
+		constants.CALLBACK_PREFIX_OWNER_FUEL_REPORT,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_DOCS,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_ADD,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_CANCEL,
+		constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW,
+		constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS,
+		constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_CANCEL,
+		constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES,
+		constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCY_RESOLVE,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_PAID,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_REJECT,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULES,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULE_ADD,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_REJECT,
+		constants.CALLBACK_PREFIX_OWNER_PERIODS,
+		constants.CALLBACK_PREFIX_OWNER_PERIOD_CLOSE,
+		constants.CALLBACK_PREFIX_OWNER_PERIOD_REOPEN,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULES,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_ADD,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_DELETE,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_EDIT,
 		constants.CALLBACK_PREFIX_OPERATOR_VIEW_DRIVER_SETTLEMENT,
 	}
+	// Подтверждение сдачи наличных - только водитель; принадлежность сдачи проверяется в БД
+	cashHandoverAckCommands := []string{
+		constants.CALLBACK_PREFIX_CASH_HANDOVER_ACK,
+		constants.CALLBACK_PREFIX_CASH_HANDOVER_DISPUTE,
+	}
+	// Выписку по водителю запрашивает владелец или сам водитель; принадлежность проверяется в обработчиках
+	driverStatementCommands := []string{
+		constants.CALLBACK_PREFIX_DRIVER_STATEMENT,
+		constants.CALLBACK_PREFIX_STATEMENT_GENERATE,
+		constants.CALLBACK_PREFIX_STATEMENT_PERIOD,
+	}
 
 	// --- НАЧАЛО ИЗМЕНЕНИЯ: Добавляем новые коллбэки в проверку прав ---
 	settlementReviewCommands := []string{
 		constants.CALLBACK_PREFIX_OPERATOR_APPROVE_SETTLEMENT,
 		constants.CALLBACK_PREFIX_OPERATOR_REJECT_SETTLEMENT,
+		constants.CALLBACK_PREFIX_SETTLEMENT_RECEIPTS,
 	}
 	// --- КОНЕЦ ИЗМЕНЕНИЯ ---
 
@@ -124,6 +191,10 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		}
 	} else if utils.IsCommandInCategory(currentCommand, settlementReviewCommands) && !isOperatorOrHigher { // --- НОВОЕ ПРАВИЛО ---
 		accessGranted = false
+	} else if utils.IsCommandInCategory(currentCommand, driverStatementCommands) && !isOwner && !isDriver {
+		accessGranted = false
+	} else if utils.IsCommandInCategory(currentCommand, cashHandoverAckCommands) && !isDriver {
+		accessGranted = false
 	}
 
 	if !accessGranted {
@@ -163,6 +234,18 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		}
 		break // Добавляем break
 
+	case constants.CALLBACK_PREFIX_SETTLEMENT_RECEIPTS: // parts: [SETTLEMENT_ID]
+		if len(parts) == 1 {
+			settlementID, err := strconv.ParseInt(parts[0], 10, 64)
+			if err == nil {
+				bh.sendSettlementReceipts(chatID, user, settlementID, originalMessageID)
+			} else {
+				bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный ID отчета.")
+			}
+		} else {
+			bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный формат команды просмотра чеков.")
+		}
+
 	case constants.CALLBACK_PREFIX_OPERATOR_VIEW_DRIVER_SETTLEMENT:
 		if !utils.IsOperatorOrHigher(user.Role) {
 			sentMsg, _ = bh.sendAccessDenied(chatID, originalMessageID)
@@ -298,6 +381,14 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		if len(parts) == 2 {
 			bh.handleStatsYearNavigation(parts[0], parts[1], data, chatID, originalMessageID)
 		}
+	case constants.CALLBACK_PREFIX_STATS_PROFIT_LOSS: // parts: [FROM, TO, FORMAT]
+		if len(parts) == 3 {
+			bh.handleProfitLossReport(chatID, parts[0], parts[1], parts[2], originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_STATS_ACCOUNTING_EXPORT: // parts: [FROM, TO, FORMAT]
+		if len(parts) == 3 {
+			bh.handleAccountingExport(chatID, parts[0], parts[1], parts[2], originalMessageID)
+		}
 	case "send_excel_menu":
 		bh.SendExcelMenu(chatID, originalMessageID)
 	case "excel_generate_orders", "excel_generate_referrals", "excel_generate_salaries":
@@ -345,6 +436,8 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		bh.HandleShowAmountOwed(chatID, user, originalMessageID)
 	case fmt.Sprintf("%s_earned_stats", constants.CALLBACK_PREFIX_MY_SALARY):
 		bh.HandleShowEarnedStats(chatID, user, originalMessageID)
+	case fmt.Sprintf("%s_statement", constants.CALLBACK_PREFIX_MY_SALARY):
+		bh.HandleShowSalaryStatement(chatID, user, originalMessageID)
 
 	// --- ИНЛАЙН-ОТЧЕТ ВОДИТЕЛЯ ---
 	case constants.CALLBACK_PREFIX_DRIVER_REPORT_OVERALL_MENU:
@@ -410,7 +503,34 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 	case constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADERS_MENU:
 		bh.SendDriverReportLoadersSubMenu(chatID, user, originalMessageID)
 	case constants.CALLBACK_PREFIX_DRIVER_REPORT_ADD_LOADER_PROMPT:
+		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+		tempData.TempLoaderNameInput = ""
+		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 		bh.SendDriverReportLoaderNameInputPrompt(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_LOADER: // parts: [LOADER_USER_ID]
+		if len(parts) == 1 {
+			loaderUserID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleDriverReportPickLoader(chatID, user, originalMessageID, loaderUserID)
+		}
+	case constants.CALLBACK_PREFIX_DRIVER_REPORT_ACCEPT_LOADER_RATE: // parts: [LOADER_INDEX]
+		if len(parts) == 1 {
+			loaderIndex, _ := strconv.Atoi(parts[0])
+			bh.handleDriverReportAcceptLoaderRate(chatID, user, originalMessageID, loaderIndex)
+		}
+	case constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADER_QUANTITIES: // parts: [LOADER_INDEX]
+		if len(parts) == 1 {
+			loaderIndex, _ := strconv.Atoi(parts[0])
+			bh.SendDriverReportLoaderQuantitiesPrompt(chatID, user, originalMessageID, loaderIndex)
+		}
+	case constants.CALLBACK_PREFIX_DRIVER_REPORT_VEHICLE:
+		bh.SendDriverReportVehicleMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_VEHICLE: // parts: [VEHICLE_ID]
+		if len(parts) == 1 {
+			vehicleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleDriverReportPickVehicle(chatID, user, vehicleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_DRIVER_REPORT_ODOMETER:
+		bh.SendDriverReportOdometerPrompt(chatID, user, originalMessageID)
 	case constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT:
 		if len(parts) == 1 {
 			loaderIndex, err := strconv.Atoi(parts[0])
@@ -434,7 +554,8 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 			loaderIndex, err := strconv.Atoi(parts[0])
 			if err == nil {
 				tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-				if tempData.EditingLoaderIndex == loaderIndex && tempData.EditingLoaderIndex != -1 {
+				confirmed := bh.Deps.SessionManager.GetState(chatID) == constants.STATE_DRIVER_REPORT_CONFIRM_DELETE_LOADER
+				if confirmed && tempData.EditingLoaderIndex == loaderIndex && tempData.EditingLoaderIndex != -1 {
 					if loaderIndex >= 0 && loaderIndex < len(tempData.LoaderPayments) {
 						tempData.LoaderPayments = append(tempData.LoaderPayments[:loaderIndex], tempData.LoaderPayments[loaderIndex+1:]...)
 						tempData.EditingLoaderIndex = -1
@@ -455,26 +576,38 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		}
 	case constants.CALLBACK_PREFIX_DRIVER_REPORT_SAVE_FINAL:
 		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-		tempData.RecalculateTotals(bh.Deps.Config.DriverSharePercentage)
+		tempData.RecalculateTotals(tempData.DriverShareRate)
+		if tempData.VehicleID.Valid && !(tempData.OdometerStart.Valid && tempData.OdometerEnd.Valid) {
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "🔢 Для выбранной машины нужно ввести показания одометра.")
+			bh.SendDriverReportVehicleMenu(chatID, user, originalMessageID)
+			break
+		}
 
 		settlement := models.DriverSettlement{
 			DriverUserID:           user.ID,
 			SettlementTimestamp:    tempData.SettlementCreateTime,
 			CoveredOrdersRevenue:   tempData.CoveredOrdersRevenue,
+			OnlinePaidRevenue:      tempData.OnlinePaidRevenue,
 			FuelExpense:            tempData.FuelExpense,
+			FuelReceiptFileIDs:     tempData.FuelReceiptFileIDs,
 			OtherExpenses:          tempData.OtherExpenses, // Используем новый список
 			LoaderPayments:         tempData.LoaderPayments,
 			DriverCalculatedSalary: tempData.DriverCalculatedSalary,
 			AmountToCashier:        tempData.AmountToCashier,
+			DriverShareRate:        sql.NullFloat64{Float64: tempData.DriverShareRate, Valid: true},
+			ShareRuleIDs:           tempData.ShareRuleIDs,
 			CoveredOrdersCount:     tempData.CoveredOrdersCount,
 			CoveredOrderIDs:        tempData.CoveredOrderIDs,
+			VehicleID:              tempData.VehicleID,
+			OdometerStart:          tempData.OdometerStart,
+			OdometerEnd:            tempData.OdometerEnd,
 			CreatedAt:              time.Now(),
 			UpdatedAt:              time.Now(),
 		}
 		savedSettlementID, err := db.AddDriverSettlement(settlement)
 		if err != nil {
 			log.Printf("CALLBACK_PREFIX_DRIVER_REPORT_SAVE_FINAL: ошибка сохранения отчета для водителя %d: %v", user.ID, err)
-			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Произошла ошибка при сохранении отчета. Попробуйте снова.")
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, periodErrorText(err, "Произошла ошибка при сохранении отчета. Попробуйте снова."))
 			bh.SendDriverReportOverallMenu(chatID, user, originalMessageID)
 		} else {
 			go bh.NotifyOperatorsAboutDriverSettlement(user, savedSettlementID)
@@ -519,6 +652,226 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 	// --- НОВОЕ: Управление кассой Владельца ---
 	case constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN:
 		bh.SendOwnerCashManagementMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_LEDGER_CHECK:
+		bh.SendOwnerLedgerCheck(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_COMP_RULES:
+		bh.SendOwnerCompensationRulesMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_COMP_RULE_ADD:
+		bh.SendOwnerCompensationRuleAddPrompt(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_COMP_RULE_DELETE: // parts: [RULE_ID]
+		if len(parts) == 1 {
+			ruleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerDeleteCompensationRule(chatID, user, ruleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_STATEMENTS:
+		bh.SendOwnerStatementDriversMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER: // parts: [DRIVER_ID]
+		if len(parts) == 1 {
+			driverID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendStatementPeriodMenu(chatID, user, driverID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_DRIVER_STATEMENT:
+		bh.SendStatementPeriodMenu(chatID, user, user.ID, originalMessageID)
+	case constants.CALLBACK_PREFIX_STATEMENT_PERIOD: // parts: [DRIVER_ID]
+		if len(parts) == 1 {
+			driverID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendStatementPeriodInputPrompt(chatID, user, driverID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_STATEMENT_GENERATE: // parts: [DRIVER_ID, FROM, TO, FORMAT]
+		if len(parts) == 4 {
+			driverID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleStatementGenerate(chatID, user, driverID, parts[1], parts[2], parts[3], originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_PAYROLL:
+		bh.SendOwnerPayrollRunsMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_PAYROLL_NEW: // parts: [FROM, TO]
+		if len(parts) == 2 {
+			bh.handleOwnerPayrollNew(chatID, user, parts[0], parts[1], originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW, constants.CALLBACK_PREFIX_OWNER_PAYROLL_RECALC,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM, constants.CALLBACK_PREFIX_OWNER_PAYROLL_POST,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_DELETE, constants.CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE: // parts: [RUN_ID]
+		if len(parts) != 1 {
+			break
+		}
+		runID, _ := strconv.ParseInt(parts[0], 10, 64)
+		switch currentCommand {
+		case constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW:
+			bh.SendOwnerPayrollRunView(chatID, user, runID, originalMessageID)
+		case constants.CALLBACK_PREFIX_OWNER_PAYROLL_RECALC:
+			bh.handleOwnerPayrollRecalc(chatID, user, runID, originalMessageID)
+		case constants.CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM:
+			bh.SendOwnerPayrollConfirmPrompt(chatID, user, runID, originalMessageID)
+		case constants.CALLBACK_PREFIX_OWNER_PAYROLL_POST:
+			bh.handleOwnerPayrollPost(chatID, user, runID, originalMessageID)
+		case constants.CALLBACK_PREFIX_OWNER_PAYROLL_DELETE:
+			bh.handleOwnerPayrollDelete(chatID, user, runID, originalMessageID)
+		case constants.CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE:
+			bh.SendOwnerPayrollReversePrompt(chatID, user, runID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_PAYROLL_LINE: // parts: [RUN_ID, LINE_ID]
+		if len(parts) == 2 {
+			runID, _ := strconv.ParseInt(parts[0], 10, 64)
+			lineID, _ := strconv.ParseInt(parts[1], 10, 64)
+			bh.SendOwnerPayrollLinePrompt(chatID, user, runID, lineID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY: // parts: [RUN_ID, FORMAT]
+		if len(parts) == 2 {
+			runID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerPayrollRegistry(chatID, user, runID, parts[1], originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLES:
+		bh.SendOwnerVehiclesMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLE_ADD:
+		bh.SendOwnerVehicleInputPrompt(chatID, user, 0, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLE_EDIT: // parts: [VEHICLE_ID]
+		if len(parts) == 1 {
+			vehicleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerVehicleInputPrompt(chatID, user, vehicleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLE_TOGGLE: // parts: [VEHICLE_ID]
+		if len(parts) == 1 {
+			vehicleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerToggleVehicle(chatID, user, vehicleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_FUEL_REPORT: // parts: [DAYS]
+		days := constants.FUEL_REPORT_PERIOD_DAYS
+		if len(parts) == 1 {
+			if parsed, err := strconv.Atoi(parts[0]); err == nil && parsed > 0 {
+				days = parsed
+			}
+		}
+		bh.SendOwnerFuelReport(chatID, user, days, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD: // parts: [VEHICLE_ID]
+		if len(parts) == 1 {
+			vehicleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerVehicleCard(chatID, user, vehicleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLE_DOCS: // parts: [VEHICLE_ID]
+		if len(parts) == 1 {
+			vehicleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerVehicleDocsPrompt(chatID, user, vehicleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_ADD: // parts: [VEHICLE_ID]
+		if len(parts) == 1 {
+			vehicleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerVehicleMaintenancePrompt(chatID, user, vehicleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_CANCEL: // parts: [RECORD_ID]
+		if len(parts) == 1 {
+			recordID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerCancelVehicleMaintenance(chatID, user, recordID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW: // parts: [DRIVER_USER_ID]
+		if len(parts) == 1 {
+			driverUserID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerCashHandoverPrompt(chatID, user, driverUserID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS: // parts: [DRIVER_USER_ID, PAGE]
+		if len(parts) == 2 {
+			driverUserID, _ := strconv.ParseInt(parts[0], 10, 64)
+			page, _ := strconv.Atoi(parts[1])
+			bh.SendOwnerCashHandoversList(chatID, user, driverUserID, page, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_CANCEL: // parts: [HANDOVER_ID]
+		if len(parts) == 1 {
+			handoverID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerCancelCashHandover(chatID, user, handoverID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES: // parts: [PAGE]
+		page := 0
+		if len(parts) == 1 {
+			page, _ = strconv.Atoi(parts[0])
+		}
+		bh.SendOwnerCashDiscrepancies(chatID, user, page, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCY_RESOLVE: // parts: [DISCREPANCY_ID]
+		if len(parts) == 1 {
+			discrepancyID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerResolveDiscrepancyPrompt(chatID, user, discrepancyID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULES:
+		bh.SendOwnerReferralRulesMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULE_ADD:
+		bh.SendOwnerReferralRuleAddPrompt(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS: // parts: [PAGE]
+		page := 0
+		if len(parts) == 1 {
+			page, _ = strconv.Atoi(parts[0])
+		}
+		bh.SendOwnerReferralReviewsList(chatID, user, page, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE, constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_REJECT: // parts: [REFERRAL_ID]
+		if len(parts) == 1 {
+			referralID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerReferralReview(chatID, user, referralID, currentCommand == constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_PERIODS:
+		bh.SendOwnerFinancialPeriodsMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_PERIOD_CLOSE, constants.CALLBACK_PREFIX_OWNER_PERIOD_REOPEN: // parts: [YYYYMM]
+		if len(parts) == 1 {
+			month, errMonth := parsePeriodMonth(parts[0])
+			if errMonth != nil {
+				bh.sendErrorMessageHelper(chatID, originalMessageID, "❌ "+errMonth.Error())
+			} else if currentCommand == constants.CALLBACK_PREFIX_OWNER_PERIOD_CLOSE {
+				bh.handleOwnerPeriodClose(chatID, user, month, originalMessageID)
+			} else {
+				bh.SendOwnerPeriodReopenPrompt(chatID, user, month, originalMessageID)
+			}
+		}
+	case constants.CALLBACK_PREFIX_OWNER_PRICE_RULES:
+		bh.SendOwnerPriceRulesMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_ADD:
+		bh.SendOwnerPriceRuleAddPrompt(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_DELETE: // parts: [RULE_ID]
+		if len(parts) == 1 {
+			ruleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerDeletePriceRule(chatID, user, ruleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_EDIT: // parts: [RULE_ID]
+		if len(parts) == 1 {
+			ruleID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerPriceRuleEditPrompt(chatID, user, ruleID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS: // parts: [STATUS, PAGE]
+		status, page := constants.PAYOUT_REQUEST_STATUS_PENDING, 0
+		if len(parts) == 2 {
+			status = parts[0]
+			page, _ = strconv.Atoi(parts[1])
+		}
+		bh.SendOwnerReferralPayoutsList(chatID, user, status, page, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW: // parts: [REQUEST_ID]
+		if len(parts) == 1 {
+			requestID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerReferralPayoutView(chatID, user, requestID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_PAID: // parts: [REQUEST_ID]
+		if len(parts) == 1 {
+			requestID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerReferralPayoutPaid(chatID, user, requestID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_REJECT: // parts: [REQUEST_ID]
+		if len(parts) == 1 {
+			requestID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendOwnerReferralPayoutRejectPrompt(chatID, user, requestID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_CASH_HANDOVER_ACK: // parts: [HANDOVER_ID]
+		if len(parts) == 1 {
+			handoverID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleDriverCashHandoverAck(chatID, user, handoverID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_CASH_HANDOVER_DISPUTE: // parts: [HANDOVER_ID]
+		if len(parts) == 1 {
+			handoverID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.SendDriverCashHandoverDisputePrompt(chatID, user, handoverID, originalMessageID)
+		}
+	case constants.CALLBACK_PREFIX_OWNER_LOADER_RATES:
+		bh.SendOwnerLoaderRatesMenu(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_ADD:
+		bh.SendOwnerLoaderRateAddPrompt(chatID, user, originalMessageID)
+	case constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_DELETE: // parts: [RATE_ID]
+		if len(parts) == 1 {
+			rateID, _ := strconv.ParseInt(parts[0], 10, 64)
+			bh.handleOwnerDeleteLoaderRate(chatID, user, rateID, originalMessageID)
+		}
 	case constants.CALLBACK_PREFIX_OWNER_CASH_ACTUAL_LIST: // parts: [PAGE]
 		page := 0
 		if len(parts) == 1 {
@@ -535,11 +888,16 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		if len(parts) == 1 {
 			settlementID, err := strconv.ParseInt(parts[0], 10, 64)
 			if err == nil {
-				errDb := db.MarkSettlementAsPaidToOwner(settlementID)
+				handoverID, errDb := db.MarkSettlementAsPaidToOwner(settlementID, user.ID)
 				if errDb != nil {
 					log.Printf("CALLBACK_ADMIN: Ошибка пометки отчета #%d как оплаченного: %v", settlementID, errDb)
-					bh.sendErrorMessageHelper(chatID, originalMessageID, "❌ Ошибка при отметке оплаты.")
+					bh.sendErrorMessageHelper(chatID, originalMessageID, periodErrorText(errDb, "❌ Ошибка при отметке оплаты."))
 				} else {
+					if handoverID != 0 {
+						if handover, errHo := db.GetCashHandoverByID(handoverID); errHo == nil {
+							bh.NotifyDriverCashHandover(handover)
+						}
+					}
 					tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 					bh.SendOwnerDriverIndividualSettlementsList(chatID, user, originalMessageID, tempData.DriverUserIDForBackNav, tempData.ViewTypeForBackNav, tempData.PageForBackNav)
 				}
@@ -552,10 +910,10 @@ func (bh *BotHandler) dispatchAdminCallbacks(currentCommand string, parts []stri
 		if len(parts) == 1 {
 			settlementID, err := strconv.ParseInt(parts[0], 10, 64)
 			if err == nil {
-				errDb := db.MarkSettlementAsUnpaidToOwner(settlementID)
+				errDb := db.MarkSettlementAsUnpaidToOwner(settlementID, user.ID)
 				if errDb != nil {
 					log.Printf("CALLBACK_ADMIN: Ошибка пометки отчета #%d как НЕ оплаченного: %v", settlementID, errDb)
-					bh.sendErrorMessageHelper(chatID, originalMessageID, "❌ Ошибка при отмене отметки оплаты.")
+					bh.sendErrorMessageHelper(chatID, originalMessageID, fmt.Sprintf("❌ Ошибка при отмене отметки оплаты: %v", errDb))
 				} else {
 					tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 					bh.SendOwnerDriverIndividualSettlementsList(chatID, user, originalMessageID, tempData.DriverUserIDForBackNav, tempData.ViewTypeForBackNav, tempData.PageForBackNav)
@@ -960,7 +1318,7 @@ func (bh *BotHandler) handleStatsGetPeriod(periodKey string, data string, chatID
 		_, _ = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка получения статистики из базы данных.")
 		return
 	}
-	bh.DisplayStats(chatID, originalMessageID, stats, periodDescription)
+	bh.DisplayStats(chatID, originalMessageID, stats, periodDescription, startDate, endDate)
 }
 func (bh *BotHandler) handleStatsSelectDay(chatID int64, user models.User, context string, year int, month time.Month, day int, originalMessageID int) {
 	selectedDate := time.Date(year, month, day, 0, 0, 0, 0, time.Local)
@@ -975,7 +1333,7 @@ func (bh *BotHandler) handleStatsSelectDay(chatID int64, user models.User, conte
 			_, _ = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка получения статистики.")
 			return
 		}
-		bh.DisplayStats(chatID, originalMessageID, stats, selectedDate.Format("02.01.2006"))
+		bh.DisplayStats(chatID, originalMessageID, stats, selectedDate.Format("02.01.2006"), startDate, endDate)
 		bh.Deps.SessionManager.ClearTempOrder(chatID)
 	} else if context == "period_start" {
 		tempData.Date = selectedDate.Format("2006-01-02") // Сохраняем дату начала в сессию
@@ -1014,7 +1372,7 @@ func (bh *BotHandler) handleStatsSelectDay(chatID int64, user models.User, conte
 			_, _ = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка получения статистики.")
 			return
 		}
-		bh.DisplayStats(chatID, originalMessageID, stats, fmt.Sprintf("%s - %s", startDate.Format("02.01.2006"), selectedDate.Format("02.01.2006")))
+		bh.DisplayStats(chatID, originalMessageID, stats, fmt.Sprintf("%s - %s", startDate.Format("02.01.2006"), selectedDate.Format("02.01.2006")), startDate, endDate)
 		bh.Deps.SessionManager.ClearTempOrder(chatID)
 	}
 }
@@ -1197,7 +1555,7 @@ func (bh *BotHandler) handleOwnerMarkSalaryPaid(chatID int64, user models.User,
 	errDb := db.MarkDriverSalaryAsPaid(settlementID)
 	if errDb != nil {
 		log.Printf("handleOwnerMarkSalaryPaid: Ошибка пометки ЗП по отчету #%d как выплаченной: %v", settlementID, errDb)
-		bh.sendErrorMessageHelper(chatID, originalMessageID, "❌ Ошибка при отметке выплаты ЗП.")
+		bh.sendErrorMessageHelper(chatID, originalMessageID, periodErrorText(errDb, "❌ Ошибка при отметке выплаты ЗП."))
 		return
 	}
 	log.Printf("ЗП по отчету #%d помечена как выплаченная. Проверка и обновление связанных заказов завершены (если были).", settlementID)
@@ -1209,7 +1567,7 @@ func (bh *BotHandler) handleOwnerMarkSalaryUnpaid(chatID int64, user models.User
 	errDb := db.MarkDriverSalaryAsUnpaid(settlementID)
 	if errDb != nil {
 		log.Printf("CALLBACK_ADMIN: Ошибка снятия пометки ЗП по отчету #%d: %v", settlementID, errDb)
-		bh.sendErrorMessageHelper(chatID, originalMessageID, "❌ Ошибка при отмене отметки выплаты ЗП.")
+		bh.sendErrorMessageHelper(chatID, originalMessageID, periodErrorText(errDb, "❌ Ошибка при отмене отметки выплаты ЗП."))
 		return
 	}
 	settlement, errGet := db.GetDriverSettlementByID(settlementID)
@@ -1287,56 +1645,30 @@ func (bh *BotHandler) handleOwnerMarkAllMoneyDeposited(chatID int64, owner model
 		bh.sendErrorMessageHelper(chatID, originalMessageID, "Эта операция доступна только для списка актуальных (не внесенных) отчетов.")
 		return
 	}
-	var reportsToUpdate []models.DriverSettlementWithDriverName
-	page := 0
-	processedSettlementIDs := make(map[int64]bool)
-
-	for {
-		settlements, total, err := db.GetDriverSettlementsForOwnerView(driverUserID, viewType, page, 100)
-		if err != nil {
-			log.Printf("Ошибка получения отчетов для 'Деньги внесены за все' (водитель %d, тип %s, стр %d): %v", driverUserID, viewType, page, err)
-			bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка получения списка отчетов для массовой отметки.")
-			return
-		}
-		if len(settlements) == 0 {
-			break
-		}
-		for _, s := range settlements {
-			if !s.PaidToOwnerAt.Valid && !processedSettlementIDs[s.ID] {
-				reportsToUpdate = append(reportsToUpdate, s)
-				processedSettlementIDs[s.ID] = true
-			}
-		}
-		if page*100+len(settlements) >= total {
-			break
-		}
-		page++
-		if page > 20 {
-			log.Printf("Превышен лимит страниц при выборке отчетов для 'Деньги внесены за все'. Водитель %d", driverUserID)
-			break
-		}
-	}
+	backCallback := fmt.Sprintf("%s_%d_%s_0", constants.CALLBACK_PREFIX_OWNER_VIEW_DRIVER_SETTLEMENTS, driverUserID, viewType)
 
-	if len(reportsToUpdate) == 0 {
-		bh.sendInfoMessage(chatID, originalMessageID, "Нет отчетов в текущем списке, по которым нужно отметить внесение денег.", fmt.Sprintf("%s_%d_%s_0", constants.CALLBACK_PREFIX_OWNER_VIEW_DRIVER_SETTLEMENTS, driverUserID, viewType))
+	// Весь несданный остаток записывается одной сдачей, распределенной по отчетам от старых к новым
+	balance, err := db.GetDriverCashBalance(driverUserID)
+	if err != nil {
+		log.Printf("Ошибка получения остатка для 'Деньги внесены за все' (водитель %d): %v", driverUserID, err)
+		bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка получения несданного остатка водителя.")
 		return
 	}
-	successCount := 0
-	errorCount := 0
-	for _, report := range reportsToUpdate {
-		err := db.MarkSettlementAsPaidToOwner(report.ID)
-		if err != nil {
-			log.Printf("Ошибка отметки 'Деньги внесены' по отчету #%d: %v", report.ID, err)
-			errorCount++
-		} else {
-			successCount++
-		}
+	if balance.Outstanding < 0.01 {
+		bh.sendInfoMessage(chatID, originalMessageID, "Нет отчетов в текущем списке, по которым нужно отметить внесение денег.", backCallback)
+		return
 	}
-	resultMessage := fmt.Sprintf("Обработка завершена:\nУспешно отмечено 'Деньги внесены' по %d отчетам.", successCount)
-	if errorCount > 0 {
-		resultMessage += fmt.Sprintf("\nОшибок при отметке: %d.", errorCount)
+	handover, err := db.RecordCashHandover(driverUserID, balance.Outstanding, sql.NullFloat64{Float64: balance.Outstanding, Valid: true}, owner.ID, "Все несданные отчеты")
+	if err != nil {
+		log.Printf("Ошибка записи сдачи 'Деньги внесены за все' (водитель %d): %v", driverUserID, err)
+		bh.sendErrorMessageHelper(chatID, originalMessageID, fmt.Sprintf("❌ Ошибка при отметке внесения денег: %v", err))
+		return
 	}
-	bh.sendInfoMessage(chatID, originalMessageID, resultMessage, fmt.Sprintf("%s_%d_%s_0", constants.CALLBACK_PREFIX_OWNER_VIEW_DRIVER_SETTLEMENTS, driverUserID, viewType))
+	bh.NotifyDriverCashHandover(handover)
+
+	resultMessage := fmt.Sprintf("Обработка завершена:\nЗаписана сдача #%d на %.0f ₽, зачтена в %d отчет(ов). Водителю отправлен запрос подтверждения.",
+		handover.ID, handover.Amount, len(handover.Allocations))
+	bh.sendInfoMessage(chatID, originalMessageID, resultMessage, backCallback)
 	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 	bh.SendOwnerDriverIndividualSettlementsList(chatID, owner, originalMessageID, driverUserID, tempData.ViewTypeForBackNav, tempData.PageForBackNav)
 }
@@ -1373,12 +1705,13 @@ func (bh *BotHandler) SendOperatorReviewSettlementMenu(operatorChatID int64, ope
 	reportDetails.WriteString(fmt.Sprintf("\n💰 Общая выручка по заказам: *%.0f ₽*\n", settlement.CoveredOrdersRevenue))
 
 	reportDetails.WriteString("\n*Расходы:*\n")
-	reportDetails.WriteString(fmt.Sprintf("  ⛽️ Топливо: *%.0f ₽*\n", settlement.FuelExpense))
+	reportDetails.WriteString(fmt.Sprintf("  ⛽️ Топливо: *%.0f ₽*%s\n", settlement.FuelExpense, receiptsMark(len(settlement.FuelReceiptFileIDs))))
+	reportDetails.WriteString(settlementVehicleLine(settlement))
 
 	if len(settlement.OtherExpenses) > 0 {
 		reportDetails.WriteString("  🛠️ *Прочие расходы:*\n")
 		for _, oe := range settlement.OtherExpenses {
-			reportDetails.WriteString(fmt.Sprintf("    - %s: *%.0f ₽*\n", utils.EscapeTelegramMarkdown(oe.Description), oe.Amount))
+			reportDetails.WriteString(fmt.Sprintf("    - %s: *%.0f ₽*%s\n", utils.EscapeTelegramMarkdown(oe.Description), oe.Amount, receiptsMark(len(oe.ReceiptFileIDs))))
 		}
 	} else {
 		reportDetails.WriteString("  🛠️ Прочие расходы: *0 ₽*\n")
@@ -1388,14 +1721,18 @@ func (bh *BotHandler) SendOperatorReviewSettlementMenu(operatorChatID int64, ope
 		reportDetails.WriteString("\n👷 *Зарплаты грузчикам:*\n")
 		totalLoaderSalary := 0.0
 		for _, lp := range settlement.LoaderPayments {
-			reportDetails.WriteString(fmt.Sprintf("  - %s: *%.0f ₽*\n", utils.EscapeTelegramMarkdown(lp.LoaderIdentifier), lp.Amount))
+			reportDetails.WriteString("  - " + formatLoaderPaymentLine(lp) + "\n")
 			totalLoaderSalary += lp.Amount
 		}
 		reportDetails.WriteString(fmt.Sprintf("  Итого грузчикам: *%.0f ₽*\n", totalLoaderSalary))
 	}
 
 	reportDetails.WriteString("\n*Итоги:*\n")
-	reportDetails.WriteString(fmt.Sprintf("  💸 Расчетная ЗП водителя: *%.0f ₽*\n", settlement.DriverCalculatedSalary))
+	if settlement.DriverShareRate.Valid {
+		reportDetails.WriteString(fmt.Sprintf("  💸 Расчетная ЗП водителя (%.1f%%): *%.0f ₽*\n", settlement.DriverShareRate.Float64*100, settlement.DriverCalculatedSalary))
+	} else {
+		reportDetails.WriteString(fmt.Sprintf("  💸 Расчетная ЗП водителя: *%.0f ₽*\n", settlement.DriverCalculatedSalary))
+	}
 	reportDetails.WriteString(fmt.Sprintf("  ➡️ Сумма к сдаче в кассу: *%.0f ₽*\n\n", settlement.AmountToCashier))
 	reportDetails.WriteString("Пожалуйста, проверьте данные и примите решение.")
 
@@ -1415,6 +1752,11 @@ func (bh *BotHandler) SendOperatorReviewSettlementMenu(operatorChatID int64, ope
 		}
 	}
 
+	if receiptsCount := settlement.ReceiptsCount(); receiptsCount > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📎 Чеки (%d)", receiptsCount), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_SETTLEMENT_RECEIPTS, settlement.ID)),
+		))
+	}
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main"),
 	))
@@ -1423,11 +1765,85 @@ func (bh *BotHandler) SendOperatorReviewSettlementMenu(operatorChatID int64, ope
 	bh.sendOrEditMessageHelper(operatorChatID, messageIDToEdit, reportDetails.String(), &keyboard, tgbotapi.ModeMarkdown)
 }
 
+// settlementVehicleLine - строка о машине и пробеге для карточки отчета; пустая, если машина не указана.
+func settlementVehicleLine(settlement models.DriverSettlement) string {
+	if !settlement.VehicleID.Valid {
+		return ""
+	}
+	vehicleName := fmt.Sprintf("#%d", settlement.VehicleID.Int64)
+	vehicle, err := db.GetVehicleByID(settlement.VehicleID.Int64)
+	if err == nil {
+		vehicleName = vehicle.DisplayName()
+	}
+	mileage, ok := settlement.MileageKm()
+	if !ok {
+		return fmt.Sprintf("  🚚 Машина: *%s*, одометр не указан\n", utils.EscapeTelegramMarkdown(vehicleName))
+	}
+	line := fmt.Sprintf("  🚚 Машина: *%s*, пробег *%d км* (%d → %d)", utils.EscapeTelegramMarkdown(vehicleName), mileage, settlement.OdometerStart.Int64, settlement.OdometerEnd.Int64)
+	if err == nil && mileage > 0 && vehicle.FuelPricePerL > 0 {
+		litersPer100 := settlement.FuelExpense / vehicle.FuelPricePerL * 100 / float64(mileage)
+		line += fmt.Sprintf(", ≈%.1f л/100 км", litersPer100)
+		if vehicle.FuelNormLPer100Km > 0 {
+			line += fmt.Sprintf(" при норме %.1f", vehicle.FuelNormLPer100Km)
+			if litersPer100 > vehicle.FuelNormLPer100Km*(1+constants.FUEL_NORM_TOLERANCE) {
+				line += " ⚠️"
+			}
+		}
+	}
+	return line + "\n"
+}
+
+// receiptsMark - пометка о количестве приложенных чеков для строки расхода.
+func receiptsMark(count int) string {
+	if count == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" 📎%d", count)
+}
+
+// sendSettlementReceipts отправляет фото чеков отчета отдельными сообщениями с подписью расхода.
+// Меню отчета пересылается ниже, чтобы оставаться последним сообщением.
+func (bh *BotHandler) sendSettlementReceipts(chatID int64, user models.User, settlementID int64, messageIDToEdit int) {
+	settlement, err := db.GetDriverSettlementByID(settlementID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки отчета.")
+		return
+	}
+	type receipt struct {
+		ref     string
+		caption string
+	}
+	var receipts []receipt
+	for i, ref := range settlement.FuelReceiptFileIDs {
+		receipts = append(receipts, receipt{ref, fmt.Sprintf("Отчет #%d. ⛽️ Топливо: %.0f ₽ (чек %d/%d)", settlement.ID, settlement.FuelExpense, i+1, len(settlement.FuelReceiptFileIDs))})
+	}
+	for _, oe := range settlement.OtherExpenses {
+		for i, ref := range oe.ReceiptFileIDs {
+			receipts = append(receipts, receipt{ref, fmt.Sprintf("Отчет #%d. 🛠️ %s: %.0f ₽ (чек %d/%d)", settlement.ID, oe.Description, oe.Amount, i+1, len(oe.ReceiptFileIDs))})
+		}
+	}
+	if len(receipts) == 0 {
+		bh.sendInfoMessage(chatID, messageIDToEdit, fmt.Sprintf("К отчету #%d чеки не приложены.", settlementID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OPERATOR_VIEW_DRIVER_SETTLEMENT, settlementID))
+		return
+	}
+
+	bh.deleteMessageHelper(chatID, messageIDToEdit)
+	for _, r := range receipts {
+		photo := tgbotapi.NewPhoto(chatID, utils.TelegramFileFromRef(r.ref))
+		photo.Caption = r.caption
+		if _, err := bh.Deps.BotClient.Send(photo); err != nil {
+			log.Printf("sendSettlementReceipts: ошибка отправки чека %s отчета #%d: %v", r.ref, settlementID, err)
+		}
+	}
+	log.Printf("sendSettlementReceipts: отправлено %d чеков отчета #%d в чат %d", len(receipts), settlementID, chatID)
+	bh.SendOperatorViewDriverSettlementDetails(chatID, user, settlementID, 0)
+}
+
 // handleOperatorApproveSettlement обрабатывает утверждение отчета.
 func (bh *BotHandler) handleOperatorApproveSettlement(operatorChatID int64, operatorUser models.User, settlementID int64, messageIDToEdit int) {
 	err := db.UpdateDriverSettlementStatus(settlementID, constants.SETTLEMENT_STATUS_APPROVED, sql.NullString{})
 	if err != nil {
-		bh.sendErrorMessageHelper(operatorChatID, messageIDToEdit, "❌ Ошибка утверждения отчета.")
+		bh.sendErrorMessageHelper(operatorChatID, messageIDToEdit, periodErrorText(err, "❌ Ошибка утверждения отчета."))
 		return
 	}
 
@@ -1461,7 +1877,7 @@ func (bh *BotHandler) handleOperatorRejectSettlement(operatorChatID int64, opera
 func (bh *BotHandler) handleOperatorFinalizeRejection(operatorChatID int64, operatorUser models.User, settlementID int64, reason string, messageIDToEdit int) {
 	err := db.UpdateDriverSettlementStatus(settlementID, constants.SETTLEMENT_STATUS_REJECTED, sql.NullString{String: reason, Valid: true})
 	if err != nil {
-		bh.sendErrorMessageHelper(operatorChatID, messageIDToEdit, "❌ Ошибка отклонения отчета.")
+		bh.sendErrorMessageHelper(operatorChatID, messageIDToEdit, periodErrorText(err, "❌ Ошибка отклонения отчета."))
 		return
 	}
 
@@ -1522,13 +1938,14 @@ func (bh *BotHandler) SendOperatorViewDriverSettlementDetails(operatorChatID int
 	reportDetails.WriteString(fmt.Sprintf("\n💰 Общая выручка по заказам: *%.0f ₽*\n", settlement.CoveredOrdersRevenue))
 
 	reportDetails.WriteString("\n*Расходы:*\n")
-	reportDetails.WriteString(fmt.Sprintf("  ⛽️ Топливо: *%.0f ₽*\n", settlement.FuelExpense))
+	reportDetails.WriteString(fmt.Sprintf("  ⛽️ Топливо: *%.0f ₽*%s\n", settlement.FuelExpense, receiptsMark(len(settlement.FuelReceiptFileIDs))))
+	reportDetails.WriteString(settlementVehicleLine(settlement))
 
 	// Отображение прочих расходов
 	if len(settlement.OtherExpenses) > 0 {
 		reportDetails.WriteString("  🛠️ *Прочие расходы:*\n")
 		for _, oe := range settlement.OtherExpenses {
-			reportDetails.WriteString(fmt.Sprintf("    - %s: *%.0f ₽*\n", utils.EscapeTelegramMarkdown(oe.Description), oe.Amount))
+			reportDetails.WriteString(fmt.Sprintf("    - %s: *%.0f ₽*%s\n", utils.EscapeTelegramMarkdown(oe.Description), oe.Amount, receiptsMark(len(oe.ReceiptFileIDs))))
 		}
 	} else {
 		reportDetails.WriteString("  🛠️ Прочие расходы: *0 ₽*\n")
@@ -1538,14 +1955,18 @@ func (bh *BotHandler) SendOperatorViewDriverSettlementDetails(operatorChatID int
 		reportDetails.WriteString("\n👷 *Зарплаты грузчикам:*\n")
 		totalLoaderSalary := 0.0
 		for _, lp := range settlement.LoaderPayments {
-			reportDetails.WriteString(fmt.Sprintf("  - %s: *%.0f ₽*\n", utils.EscapeTelegramMarkdown(lp.LoaderIdentifier), lp.Amount))
+			reportDetails.WriteString("  - " + formatLoaderPaymentLine(lp) + "\n")
 			totalLoaderSalary += lp.Amount
 		}
 		reportDetails.WriteString(fmt.Sprintf("  Итого грузчикам: *%.0f ₽*\n", totalLoaderSalary))
 	}
 
 	reportDetails.WriteString("\n*Итоги:*\n")
-	reportDetails.WriteString(fmt.Sprintf("  💸 Расчетная ЗП водителя: *%.0f ₽*\n", settlement.DriverCalculatedSalary))
+	if settlement.DriverShareRate.Valid {
+		reportDetails.WriteString(fmt.Sprintf("  💸 Расчетная ЗП водителя (%.1f%%): *%.0f ₽*\n", settlement.DriverShareRate.Float64*100, settlement.DriverCalculatedSalary))
+	} else {
+		reportDetails.WriteString(fmt.Sprintf("  💸 Расчетная ЗП водителя: *%.0f ₽*\n", settlement.DriverCalculatedSalary))
+	}
 	reportDetails.WriteString(fmt.Sprintf("  ➡️ Сумма к сдаче в кассу: *%.0f ₽*\n", settlement.AmountToCashier))
 
 	var statusMoney, statusSalary string
@@ -1568,6 +1989,11 @@ func (bh *BotHandler) SendOperatorViewDriverSettlementDetails(operatorChatID int
 	}
 
 	var rows [][]tgbotapi.InlineKeyboardButton // <--- ИЗМЕНЕНИЕ ЗДЕСЬ
+	if receiptsCount := settlement.ReceiptsCount(); receiptsCount > 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📎 Чеки (%d)", receiptsCount), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_SETTLEMENT_RECEIPTS, settlement.ID)),
+		))
+	}
 	if utils.IsRoleOrHigher(operatorUser.Role, constants.ROLE_OWNER) {
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("✏️ Редактировать этот отчет", fmt.Sprintf("%s_%d_%d_%s_%d", constants.CALLBACK_PREFIX_OWNER_EDIT_SETTLEMENT, settlement.ID, settlement.DriverUserID, constants.VIEW_TYPE_ACTUAL_SETTLEMENTS, 0)),
@@ -131,6 +131,7 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 		"use_profile_name_for_order": true, "enter_another_name_for_order": true,
 		"skip_photo_initial": true, "finish_photo_upload": true, "reset_photo_upload": true,
 		"payment_now": true, "payment_later": true, "send_location_prompt": true,
+		"payment_yookassa": true, "payment_telegram": true, "payment_sbp": true, "payment_cash": true,
 		"confirm_order_name": true, "confirm_order_phone": true,
 		"confirm_order_description_placeholder": true,
 		"skip_order_description_placeholder":    true,
@@ -156,6 +157,23 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 		"operator_create_order_for_client":                                           true, // Устарело, заменено на CALLBACK_PREFIX_OP_CREATE_NEW_ORDER
 		constants.CALLBACK_PREFIX_OWNER_FINANCIALS:                                   true,
 		constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN:                         true,
+		constants.CALLBACK_PREFIX_OWNER_LEDGER_CHECK:                                 true,
+		constants.CALLBACK_PREFIX_OWNER_COMP_RULES:                                   true,
+		constants.CALLBACK_PREFIX_OWNER_COMP_RULE_ADD:                                true,
+		constants.CALLBACK_PREFIX_OWNER_LOADER_RATES:                                 true,
+		constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_ADD:                              true,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULES:                               true,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULE_ADD:                            true,
+		constants.CALLBACK_PREFIX_OWNER_PERIODS:                                      true,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULES:                                  true,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_ADD:                               true,
+		constants.CALLBACK_PREFIX_RECURRING_LIST:                                     true,
+		constants.CALLBACK_PREFIX_OWNER_STATEMENTS:                                   true,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL:                                      true,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLES:                                     true,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_ADD:                                  true,
+		constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_PROFILE_CARD:                       true,
+		constants.CALLBACK_PREFIX_DRIVER_STATEMENT:                                   true,
 		"back_to_main_confirm_cancel_order":                                          true,
 		"back_to_main_confirm_cancel_driver_settlement":                              true,
 		"back_to_main_confirmed_cancel_final":                                        true,
@@ -170,6 +188,8 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADERS_MENU:                         true,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_ADD_LOADER_PROMPT:                    true,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_SAVE_FINAL:                           true,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_VEHICLE:                              true,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_ODOMETER:                             true,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_CANCEL_ALL:                           true,
 		// Новые операторские команды
 		constants.CALLBACK_PREFIX_OP_CREATE_NEW_ORDER: true,
@@ -179,7 +199,7 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 	prefixedCommandsWithParams := map[string]int{
 		"confirm_order_final": 3, "select_date": 2, "edit_order": 2, "edit_field_description": 3,
 		"edit_field_name": 3, "edit_field_subcategory": 3, "edit_field_date": 3, "edit_field_time": 3, "edit_field_phone": 3,
-		"edit_field_address": 3, "edit_field_media": 3, "edit_field_payment": 3, "accept_cost": 2, "reject_cost": 2,
+		"edit_field_address": 3, "edit_field_media": 3, "edit_field_payment": 3, "edit_field_details": 3, "accept_cost": 2, "reject_cost": 2,
 		"cancel_order_operator": 3, "cancel_order_confirm": 3, "operator_orders_new": 3, "operator_orders_awaiting_confirmation": 4,
 		"operator_orders_in_progress": 4, "operator_orders_completed": 3, "operator_orders_canceled": 3, "operator_orders_calculated": 3,
 		"my_orders_page": 3, "select_client": 2, "view_order": 2, "view_order_ops": 3, "set_cost": 2,
@@ -193,6 +213,7 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 		"block_user_final": 3, "unblock_user_info": 3, "unblock_user_final": 3,
 		fmt.Sprintf("%s_owed", constants.CALLBACK_PREFIX_MY_SALARY):                          3,
 		fmt.Sprintf("%s_earned_stats", constants.CALLBACK_PREFIX_MY_SALARY):                  4,
+		fmt.Sprintf("%s_statement", constants.CALLBACK_PREFIX_MY_SALARY):                     3,
 		fmt.Sprintf("%s_page", constants.CALLBACK_PREFIX_OWNER_STAFF_PAYOUT):                 3,
 		fmt.Sprintf("%s_select", constants.CALLBACK_PREFIX_OWNER_STAFF_PAYOUT):               3,
 		fmt.Sprintf("%s_confirm", constants.CALLBACK_PREFIX_OWNER_STAFF_PAYOUT):              3,
@@ -211,10 +232,58 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 		constants.CALLBACK_PREFIX_OWNER_CASH_MARK_SALARY_UNPAID:                              5,
 		constants.CALLBACK_PREFIX_OWNER_MARK_ALL_SALARY_PAID:                                 5,
 		constants.CALLBACK_PREFIX_OWNER_MARK_ALL_MONEY_DEPOSITED:                             5,
+		constants.CALLBACK_PREFIX_OWNER_COMP_RULE_DELETE:                                     3,
+		constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_DELETE:                                   4,
+		constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER:                                     3,
+		constants.CALLBACK_PREFIX_STATEMENT_GENERATE:                                         2,
+		constants.CALLBACK_PREFIX_STATEMENT_PERIOD:                                           2,
+		constants.CALLBACK_PREFIX_STATS_PROFIT_LOSS:                                          2,
+		constants.CALLBACK_PREFIX_STATS_ACCOUNTING_EXPORT:                                    2,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_NEW:                                          3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW:                                         3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_LINE:                                         3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_RECALC:                                       3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM:                                      3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_POST:                                         3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_DELETE:                                       3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY:                                     3,
+		constants.CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE:                                      3,
 		constants.CALLBACK_PREFIX_OPERATOR_VIEW_DRIVER_SETTLEMENT:                            4,
-		"date_page": 2, "resume_order_creation": 3,
+		constants.CALLBACK_PREFIX_SETTLEMENT_RECEIPTS:                                        2,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_EDIT:                                         3,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_TOGGLE:                                       3,
+		constants.CALLBACK_PREFIX_OWNER_FUEL_REPORT:                                          3,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD:                                         3,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_DOCS:                                         3,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_ADD:                                    3,
+		constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_CANCEL:                                 3,
+		constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW:                                    4,
+		constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS:                                       4,
+		constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_CANCEL:                                 4,
+		constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES:                                   4,
+		constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCY_RESOLVE:                             4,
+		constants.CALLBACK_PREFIX_CASH_HANDOVER_ACK:                                          3,
+		constants.CALLBACK_PREFIX_CASH_HANDOVER_DISPUTE:                                      3,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS:                                     3,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW:                                 3,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_PAID:                                 3,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_REJECT:                               3,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS:                                     3,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE:                              3,
+		constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_REJECT:                               3,
+		constants.CALLBACK_PREFIX_OWNER_PERIOD_CLOSE:                                         3,
+		constants.CALLBACK_PREFIX_OWNER_PERIOD_REOPEN:                                        3,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_DELETE:                                    3,
+		constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_EDIT:                                      3,
+		constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_METHOD:                                     3,
+		constants.CALLBACK_PREFIX_ASSIGN_VEHICLE:                                             2,
+		"date_page":                                                                          2, "resume_order_creation": 3,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT:                5,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_DELETE_LOADER_CONFIRM:             5,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_LOADER:                       4,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_VEHICLE:                      4,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_ACCEPT_LOADER_RATE:                4,
+		constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADER_QUANTITIES:                 4,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_OTHER_EXPENSE_PROMPT:         5,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_DELETE_OTHER_EXPENSE_SHOW_CONFIRM: 5,
 		constants.CALLBACK_PREFIX_DRIVER_REPORT_DELETE_OTHER_EXPENSE_CONFIRM:      5,
@@ -225,12 +294,26 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 		constants.CALLBACK_PREFIX_OP_SKIP_COST:                   3, // op_skip_cost (3 части) + _ORDERID
 		constants.CALLBACK_PREFIX_OP_SKIP_ASSIGN_EXEC:            4, // op_skip_assign_exec (4 части) + _ORDERID
 		constants.CALLBACK_PREFIX_OP_FINALIZE_ORDER_CREATION:     3, // op_finalize_creation (3 части) + _ORDERID
+		constants.CALLBACK_PREFIX_PRICE_ESTIMATE_ACCEPT:          2, // price_accept_ORDERID
 		constants.CALLBACK_PREFIX_OP_EDIT_ORDER_COST:             4, // op_edit_ord_cost (4 части) + _ORDERID
 		constants.CALLBACK_PREFIX_OP_EDIT_ORDER_EXECS:            4, // op_edit_ord_execs (4 части) + _ORDERID
 		constants.CALLBACK_PREFIX_EXECUTOR_NOTIFIED:              2, // exec_notified_ORDERID_EXECUTORUSERID (старый префикс, новая логика)
 		constants.CALLBACK_PREFIX_SELECT_HOUR:                    2, // select_hour_HOUR
 		"select_time":                                            2, // select_time_HH:MM
 		constants.CALLBACK_PREFIX_PAY_ORDER:                      2, // pay_order_ORDERID
+		constants.CALLBACK_PREFIX_RECEIPT_EMAIL:                  2, // receipt_email_ORDERID
+		constants.CALLBACK_PREFIX_ORDER_DETAIL:                   2, // ord_det_STEP_VALUE
+		constants.CALLBACK_PREFIX_QUOTE_NEW:                      2, // quote_new_ORDERID
+		constants.CALLBACK_PREFIX_QUOTE_TTL:                      2, // quote_ttl_ORDERID_HOURS
+		constants.CALLBACK_PREFIX_QUOTE_PICK:                     2, // quote_pick_OPTIONID
+		constants.CALLBACK_PREFIX_REPEAT_ORDER:                   2, // repeat_order_ORDERID
+		constants.CALLBACK_PREFIX_RECURRING_NEW:                  2, // rec_new_ORDERID
+		constants.CALLBACK_PREFIX_RECURRING_CREATE:               2, // rec_create_ORDERID_DAYS
+		constants.CALLBACK_PREFIX_RECURRING_VIEW:                 2, // rec_view_ID
+		constants.CALLBACK_PREFIX_RECURRING_PAUSE:                2, // rec_pause_ID
+		constants.CALLBACK_PREFIX_RECURRING_SKIP:                 2, // rec_skip_ID
+		constants.CALLBACK_PREFIX_RECURRING_END:                  2, // rec_end_ID
+		constants.CALLBACK_PREFIX_RECURRING_END_CONFIRM:          3, // rec_end_ok_ID
 	}
 
 	if explicitCompleteCommands[data] {
@@ -376,7 +459,11 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 		constants.CALLBACK_PREFIX_OP_CONFIRM_ORDER_ASSIGN_EXEC,
 		constants.CALLBACK_PREFIX_OP_SKIP_COST,
 		constants.CALLBACK_PREFIX_OP_SKIP_ASSIGN_EXEC,
-		constants.CALLBACK_PREFIX_OP_FINALIZE_ORDER_CREATION:
+		constants.CALLBACK_PREFIX_OP_FINALIZE_ORDER_CREATION,
+		constants.CALLBACK_PREFIX_PRICE_ESTIMATE_ACCEPT,
+		constants.CALLBACK_PREFIX_QUOTE_NEW,
+		constants.CALLBACK_PREFIX_QUOTE_TTL,
+		constants.CALLBACK_PREFIX_QUOTE_PICK:
 		finalActiveMessageID = bh.dispatchOrderCallbacks(currentCommand, remainingParts, data, chatID, user, originalMessageID)
 		isDispatched = true
 
@@ -399,9 +486,66 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 			constants.CALLBACK_PREFIX_OWNER_CASH_MARK_SALARY_UNPAID,
 			constants.CALLBACK_PREFIX_OWNER_MARK_ALL_SALARY_PAID,
 			constants.CALLBACK_PREFIX_OWNER_MARK_ALL_MONEY_DEPOSITED,
+			constants.CALLBACK_PREFIX_OWNER_LEDGER_CHECK,
+			constants.CALLBACK_PREFIX_OWNER_COMP_RULES,
+			constants.CALLBACK_PREFIX_OWNER_COMP_RULE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_COMP_RULE_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_LOADER_RATES,
+			constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_STATEMENTS,
+			constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER,
+			constants.CALLBACK_PREFIX_DRIVER_STATEMENT,
+			constants.CALLBACK_PREFIX_STATEMENT_GENERATE,
+			constants.CALLBACK_PREFIX_STATEMENT_PERIOD,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_NEW,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_LINE,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_RECALC,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_POST,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE,
 			constants.CALLBACK_PREFIX_OPERATOR_VIEW_DRIVER_SETTLEMENT,
+			constants.CALLBACK_PREFIX_SETTLEMENT_RECEIPTS,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLES,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_EDIT,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_TOGGLE,
+			constants.CALLBACK_PREFIX_OWNER_FUEL_REPORT,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_DOCS,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_ADD,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_CANCEL,
+			constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW,
+			constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS,
+			constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_CANCEL,
+			constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES,
+			constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCY_RESOLVE,
+			constants.CALLBACK_PREFIX_CASH_HANDOVER_ACK,
+			constants.CALLBACK_PREFIX_CASH_HANDOVER_DISPUTE,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_PAID,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_REJECT,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULES,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_REJECT,
+			constants.CALLBACK_PREFIX_OWNER_PERIODS,
+			constants.CALLBACK_PREFIX_OWNER_PERIOD_CLOSE,
+			constants.CALLBACK_PREFIX_OWNER_PERIOD_REOPEN,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULES,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_EDIT,
 			"staff_menu", "staff_list_menu", "staff_list_by_role", "staff_add_prompt_name", "staff_add_role_final", "staff_info", "staff_edit_menu", "staff_edit_role_final", "staff_block_reason_prompt", "staff_unblock_confirm", "staff_delete_confirm", "staff_edit_field_name", "staff_edit_field_surname", "staff_edit_field_nickname", "staff_edit_field_phone", "staff_edit_field_card_number", "staff_edit_field_role", "staff_add_prompt_card_number",
 			"stats_menu", "stats_basic_periods", "stats_get_today", "stats_get_yesterday", "stats_get_current_week", "stats_get_current_month", "stats_get_last_week", "stats_get_last_month", "stats_select_custom_date", "stats_select_custom_period", "stats_select_month", "stats_select_day", "stats_year_nav",
+			constants.CALLBACK_PREFIX_STATS_PROFIT_LOSS,
+			constants.CALLBACK_PREFIX_STATS_ACCOUNTING_EXPORT,
 			"send_excel_menu", "excel_generate_orders", "excel_generate_referrals", "excel_generate_salaries",
 			"block_user_menu", "block_user_list_prompt", "block_user_info", "block_user_reason_prompt", "block_user_final", "unblock_user_list_prompt", "unblock_user_info", "unblock_user_final",
 			constants.CALLBACK_PREFIX_DRIVER_SETTLEMENT,
@@ -415,6 +559,12 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 			constants.CALLBACK_PREFIX_DRIVER_REPORT_ADD_LOADER_PROMPT,
 			constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT,
 			constants.CALLBACK_PREFIX_DRIVER_REPORT_DELETE_LOADER_CONFIRM,
+			constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_LOADER,
+			constants.CALLBACK_PREFIX_DRIVER_REPORT_ACCEPT_LOADER_RATE,
+			constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADER_QUANTITIES,
+			constants.CALLBACK_PREFIX_DRIVER_REPORT_VEHICLE,
+			constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_VEHICLE,
+			constants.CALLBACK_PREFIX_DRIVER_REPORT_ODOMETER,
 			constants.CALLBACK_PREFIX_DRIVER_REPORT_SAVE_FINAL,
 			constants.CALLBACK_PREFIX_DRIVER_REPORT_CANCEL_ALL,
 		}
@@ -431,6 +581,55 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 			constants.CALLBACK_PREFIX_OWNER_CASH_MARK_SALARY_UNPAID,
 			constants.CALLBACK_PREFIX_OWNER_MARK_ALL_SALARY_PAID,
 			constants.CALLBACK_PREFIX_OWNER_MARK_ALL_MONEY_DEPOSITED,
+			constants.CALLBACK_PREFIX_OWNER_LEDGER_CHECK,
+			constants.CALLBACK_PREFIX_OWNER_COMP_RULES,
+			constants.CALLBACK_PREFIX_OWNER_COMP_RULE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_COMP_RULE_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_LOADER_RATES,
+			constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_STATEMENTS,
+			constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_NEW,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_LINE,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_RECALC,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_POST,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY,
+			constants.CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLES,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_EDIT,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_TOGGLE,
+			constants.CALLBACK_PREFIX_OWNER_FUEL_REPORT,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_DOCS,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_ADD,
+			constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_CANCEL,
+			constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW,
+			constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS,
+			constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_CANCEL,
+			constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES,
+			constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCY_RESOLVE,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_PAID,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_REJECT,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULES,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE,
+			constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_REJECT,
+			constants.CALLBACK_PREFIX_OWNER_PERIODS,
+			constants.CALLBACK_PREFIX_OWNER_PERIOD_CLOSE,
+			constants.CALLBACK_PREFIX_OWNER_PERIOD_REOPEN,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULES,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_ADD,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_DELETE,
+			constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_EDIT,
 		}
 
 		orderCreationDispatchableItems := []string{
@@ -440,18 +639,21 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 			"use_profile_name_for_order", "enter_another_name_for_order",
 			"skip_photo_initial", "finish_photo_upload", "reset_photo_upload",
 			"payment_now", "payment_later", "send_location_prompt",
+			"payment_yookassa", "payment_telegram", "payment_sbp", "payment_cash",
 			"confirm_order_name", "confirm_order_phone",
 			"confirm_order_description_placeholder", "skip_order_description_placeholder",
 			"change_order_phone", "view_uploaded_media",
 			"select_date_asap", "select_date", "select_time",
 			"edit_order", "edit_field_description", "edit_field_name", "edit_field_subcategory", "edit_field_date", "edit_field_time",
-			"edit_field_phone", "edit_field_address", "edit_field_media", "edit_field_payment",
+			"edit_field_phone", "edit_field_address", "edit_field_media", "edit_field_payment", "edit_field_details",
+			constants.CALLBACK_PREFIX_ORDER_DETAIL,
 			"confirm_order_final", "accept_cost", "reject_cost", "cancel_order_operator", "cancel_order_confirm",
-			constants.CALLBACK_PREFIX_PAY_ORDER,
+			constants.CALLBACK_PREFIX_PAY_ORDER, constants.CALLBACK_PREFIX_RECEIPT_EMAIL,
+			constants.CALLBACK_PREFIX_REPEAT_ORDER,
 		}
 		orderViewManageDispatchableItems := []string{
 			"manage_orders", "operator_create_order_for_client", "select_client", "view_order", "view_order_ops",
-			"set_cost", "assign_executors", "assign_driver", "assign_loader", "unassign_executor",
+			"set_cost", "assign_executors", "assign_driver", "assign_loader", "unassign_executor", constants.CALLBACK_PREFIX_ASSIGN_VEHICLE,
 			"operator_orders_new", "operator_orders_awaiting_confirmation", "operator_orders_in_progress",
 			"operator_orders_completed", "operator_orders_canceled", "operator_orders_calculated",
 			constants.CALLBACK_PREFIX_MARK_ORDER_DONE, constants.CALLBACK_PREFIX_ORDER_SET_FINAL_COST,
@@ -460,10 +662,17 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 			constants.CALLBACK_PREFIX_OP_EDIT_ORDER_COST,
 			constants.CALLBACK_PREFIX_OP_EDIT_ORDER_EXECS,
 		}
+		recurringOrderDispatchableItems := []string{
+			constants.CALLBACK_PREFIX_RECURRING_LIST, constants.CALLBACK_PREFIX_RECURRING_NEW,
+			constants.CALLBACK_PREFIX_RECURRING_CREATE, constants.CALLBACK_PREFIX_RECURRING_VIEW,
+			constants.CALLBACK_PREFIX_RECURRING_PAUSE, constants.CALLBACK_PREFIX_RECURRING_SKIP,
+			constants.CALLBACK_PREFIX_RECURRING_END, constants.CALLBACK_PREFIX_RECURRING_END_CONFIRM,
+		}
 		infoCommsDispatchableItems := []string{
 			"invite_friend", "contact_operator", "contact_chat", "contact_phone_options",
 			"client_chats", "materials_soon", "subscribe_materials_updates", "referral_link",
 			"referral_qr", "referral_my", "referral_details", "request_referral_payout",
+			constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_METHOD, constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_PROFILE_CARD,
 			"phone_action_request_call", "phone_action_call_self", "view_chat_history",
 		}
 
@@ -491,6 +700,9 @@ func (bh *BotHandler) HandleCallback(update tgbotapi.Update) {
 			// ИЗМЕНЕНИЕ: передаем 'query' в dispatchOrderViewManageCallbacks
 			finalActiveMessageID = bh.dispatchOrderViewManageCallbacks(query, currentCommand, remainingParts, data, chatID, user, originalMessageID)
 			isDispatched = true
+		} else if utils.IsCommandInCategory(currentCommand, recurringOrderDispatchableItems) {
+			finalActiveMessageID = bh.dispatchRecurringOrderCallbacks(currentCommand, remainingParts, data, chatID, user, originalMessageID)
+			isDispatched = true
 		} else if utils.IsCommandInCategory(currentCommand, infoCommsDispatchableItems) {
 			finalActiveMessageID = bh.dispatchInfoCommsCallbacks(currentCommand, remainingParts, data, chatID, user, originalMessageID)
 			isDispatched = true
@@ -647,6 +859,9 @@ func (bh *BotHandler) resumeOrderCreation(chatID int64, user models.User, origin
 		bh.SendCategoryMenu(chatID, user.FirstName, originalStepMessageID)
 	case constants.STATE_ORDER_SUBCATEGORY:
 		bh.SendSubcategoryMenu(chatID, bh.Deps.SessionManager.GetTempOrder(chatID).Category, originalStepMessageID)
+	case constants.STATE_ORDER_VOLUME, constants.STATE_ORDER_TONNAGE, constants.STATE_ORDER_FLOOR,
+		constants.STATE_ORDER_ELEVATOR, constants.STATE_ORDER_LOADERS:
+		bh.SendOrderDetailStep(chatID, orderDetailStepForState(previousMeaningfulState), originalStepMessageID)
 	case constants.STATE_ORDER_DESCRIPTION:
 		bh.SendDescriptionInputMenu(chatID, originalStepMessageID)
 	case constants.STATE_ORDER_NAME:
@@ -693,6 +908,8 @@ func (bh *BotHandler) resumeOrderCreation(chatID int64, user models.User, origin
 		bh.SendDriverReportOverallMenu(chatID, user, originalStepMessageID)
 	case constants.STATE_DRIVER_REPORT_INPUT_FUEL:
 		bh.SendDriverReportFuelInputPrompt(chatID, user, originalStepMessageID)
+	case constants.STATE_DRIVER_REPORT_VEHICLE_MENU, constants.STATE_DRIVER_REPORT_INPUT_ODOMETER:
+		bh.SendDriverReportVehicleMenu(chatID, user, originalStepMessageID)
 	case constants.STATE_DRIVER_REPORT_OTHER_EXPENSES_MENU: // Добавлено
 		bh.SendDriverReportOtherExpensesMenu(chatID, user, originalStepMessageID)
 	case constants.STATE_DRIVER_REPORT_INPUT_OTHER_EXPENSE_DESCRIPTION: // Добавлено
@@ -705,10 +922,7 @@ func (bh *BotHandler) resumeOrderCreation(chatID int64, user models.User, origin
 		bh.SendDriverReportLoadersSubMenu(chatID, user, originalStepMessageID)
 	case constants.STATE_DRIVER_REPORT_INPUT_LOADER_NAME:
 		bh.SendDriverReportLoaderNameInputPrompt(chatID, user, originalStepMessageID)
-	case constants.STATE_DRIVER_REPORT_INPUT_LOADER_SALARY:
-		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-		bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, originalStepMessageID, tempData.TempLoaderNameInput, false, -1)
-	case constants.STATE_DRIVER_REPORT_EDIT_LOADER_SALARY:
+	case constants.STATE_DRIVER_REPORT_EDIT_LOADER_SALARY, constants.STATE_DRIVER_REPORT_INPUT_LOADER_SALARY, constants.STATE_DRIVER_REPORT_INPUT_LOADER_QUANTITIES:
 		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 		if tempData.EditingLoaderIndex >= 0 && tempData.EditingLoaderIndex < len(tempData.LoaderPayments) {
 			loaderToEdit := tempData.LoaderPayments[tempData.EditingLoaderIndex]
@@ -777,6 +991,7 @@ func (bh *BotHandler) handleBackCallback(chatID int64, user models.User, data st
 	knownBackTargets := map[string]int{
 		"main": 1, "category": 1, "subcategory": 1, "description": 1, "name": 1,
 		"date": 1, "time": 1, "phone": 1, "address": 1, "photo": 1, "payment": 1,
+		"orddet":           1, // back_to_orddet_STEP
 		"edit_menu_direct": 1, // ORDERID будет из сессии
 		"staff_menu":       2, "staff_list_menu": 3, "staff_info": 2, "staff_edit_menu": 3,
 		"stats_menu": 2, "stats_basic_periods": 3, "stats_select_custom_date": 4,
@@ -931,6 +1146,12 @@ func (bh *BotHandler) handleBackCallback(chatID int64, user models.User, data st
 		bh.SendCategoryMenu(chatID, user.FirstName, originalMessageID)
 	case "subcategory":
 		bh.SendSubcategoryMenu(chatID, categoryForSubmenu, originalMessageID)
+	case "orddet":
+		if len(destinationParams) == 1 {
+			bh.SendOrderDetailStep(chatID, destinationParams[0], originalMessageID)
+		} else {
+			bh.StartOrderDetails(chatID, originalMessageID)
+		}
 	case "description":
 		bh.SendDescriptionInputMenu(chatID, originalMessageID)
 	case "name":
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"strconv"
@@ -148,6 +149,21 @@ func (bh *BotHandler) dispatchInfoCommsCallbacks(currentCommand string, parts []
 		log.Printf("[CALLBACK_INFO_COMMS] Запрос на выплату реферальных бонусов. ChatID=%d", chatID)
 		bh.handleRequestReferralPayout(chatID, user, originalMessageID)
 		newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+	case constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_METHOD: // parts: [METHOD]
+		if len(parts) == 1 {
+			bh.handleReferralPayoutMethod(chatID, user, parts[0], originalMessageID)
+		} else {
+			log.Printf("[CALLBACK_INFO_COMMS] Некорректный формат для '%s': %v. ChatID=%d", currentCommand, parts, chatID)
+			bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный формат команды.")
+		}
+		newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+	case constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_PROFILE_CARD:
+		if user.CardNumber.Valid && user.CardNumber.String != "" {
+			bh.submitReferralPayoutRequest(chatID, user, constants.REFERRAL_PAYOUT_METHOD_CARD, user.CardNumber.String, originalMessageID)
+		} else {
+			bh.handleReferralPayoutMethod(chatID, user, constants.REFERRAL_PAYOUT_METHOD_CARD, originalMessageID)
+		}
+		newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
 	case "phone_action_request_call", "phone_action_call_self":
 		actionKey := strings.TrimPrefix(currentCommand, "phone_action_")
 
@@ -177,14 +193,44 @@ func (bh *BotHandler) dispatchInfoCommsCallbacks(currentCommand string, parts []
 	return newMenuMessageID
 }
 
-// handleRequestReferralPayout обрабатывает запрос на выплату реферальных бонусов.
+// availableReferralBonus возвращает сумму и ID бонусов, которые не выплачены и не включены в другой запрос.
+func availableReferralBonus(chatID int64) (float64, []int64, error) {
+	referrals, err := db.GetReferralsByInviterChatID(chatID)
+	if err != nil {
+		return 0, nil, err
+	}
+	total := 0.0
+	var ids []int64
+	now := time.Now()
+	for _, r := range referrals {
+		// Бонус доступен к выплате, если он не выплачен, не сгорел И не находится уже в другом запросе на выплату
+		// Bonus is available for payout if it's not paid, not expired AND not already in another payout request
+		if r.AvailableForPayout(now) {
+			total += r.Amount
+			ids = append(ids, r.ID)
+		}
+	}
+	return total, ids, nil
+}
+
+// referralMinPayoutAmount - порог выплаты по действующим условиям программы (0 - без порога).
+func referralMinPayoutAmount() float64 {
+	rule, err := db.GetActiveReferralRule()
+	if err != nil {
+		return 0
+	}
+	return rule.MinPayoutAmount
+}
+
+// handleRequestReferralPayout обрабатывает запрос на выплату реферальных бонусов:
+// показывает доступную сумму и предлагает выбрать способ получения.
 // handleRequestReferralPayout processes a referral bonus payout request.
 func (bh *BotHandler) handleRequestReferralPayout(chatID int64, user models.User, originalMessageID int) {
 	log.Printf("[REFERRAL_HANDLER] Обработка запроса на выплату реферальных бонусов. ChatID=%d", chatID)
 	var sentMsg tgbotapi.Message
 	var errHelper error
 
-	referrals, err := db.GetReferralsByInviterChatID(chatID)
+	totalUnpaidBonus, _, err := availableReferralBonus(chatID)
 	if err != nil {
 		log.Printf("[REFERRAL_HANDLER] Ошибка БД при получении рефералов для выплаты ChatID=%d: %v", chatID, err)
 		_, _ = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка получения данных о ваших бонусах.")
@@ -192,17 +238,6 @@ func (bh *BotHandler) handleRequestReferralPayout(chatID int64, user models.User
 		return
 	}
 
-	totalUnpaidBonus := 0.0
-	var unpaidReferralIDs []int64
-	for _, r := range referrals {
-		// Бонус доступен к выплате, если он не выплачен И не находится уже в другом запросе на выплату
-		// Bonus is available for payout if it's not paid AND not already in another payout request
-		if !r.PaidOut && !r.PayoutRequestID.Valid {
-			totalUnpaidBonus += r.Amount
-			unpaidReferralIDs = append(unpaidReferralIDs, r.ID)
-		}
-	}
-
 	if totalUnpaidBonus <= 0 {
 		log.Printf("[REFERRAL_HANDLER] Сумма невыплаченных и не запрошенных бонусов для ChatID=%d равна нулю или меньше.", chatID)
 		// Отправляем сообщение и затем меню рефералов / Send message and then referrals menu
@@ -214,28 +249,112 @@ func (bh *BotHandler) handleRequestReferralPayout(chatID int64, user models.User
 		bh.SendMyReferralsMenu(chatID, currentMenuID)
 		return
 	}
+	if minPayout := referralMinPayoutAmount(); totalUnpaidBonus < minPayout {
+		bh.sendInfoMessage(chatID, originalMessageID, fmt.Sprintf("ℹ️ Выплата доступна от %.0f ₽, сейчас накоплено %.0f ₽.", minPayout, totalUnpaidBonus), "referral_my")
+		return
+	}
+
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_REFERRAL_PAYOUT_CONFIRM)
+	msgText := fmt.Sprintf("💸 К выплате доступно: *%.0f ₽*\n\nКак вам удобнее получить деньги?", totalUnpaidBonus)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if user.CardNumber.Valid && user.CardNumber.String != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			"💳 На карту из профиля "+maskCardNumber(user.CardNumber.String), constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_PROFILE_CARD)))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💳 На карту", fmt.Sprintf("%s_%s", constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_METHOD, constants.REFERRAL_PAYOUT_METHOD_CARD))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📱 По СБП (номер телефона)", fmt.Sprintf("%s_%s", constants.CALLBACK_PREFIX_REFERRAL_PAYOUT_METHOD, constants.REFERRAL_PAYOUT_METHOD_SBP))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Мои рефералы", "referral_my")),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, originalMessageID, msgText, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("[REFERRAL_HANDLER] Ошибка отправки выбора способа выплаты ChatID=%d: %v", chatID, err)
+	}
+}
+
+// handleReferralPayoutMethod запрашивает реквизиты для выбранного способа выплаты.
+func (bh *BotHandler) handleReferralPayoutMethod(chatID int64, user models.User, method string, originalMessageID int) {
+	var msgText string
+	switch method {
+	case constants.REFERRAL_PAYOUT_METHOD_CARD:
+		bh.Deps.SessionManager.SetState(chatID, constants.STATE_REFERRAL_PAYOUT_CARD)
+		msgText = "💳 Введите номер карты, на которую перевести бонусы (16–19 цифр):"
+	case constants.REFERRAL_PAYOUT_METHOD_SBP:
+		bh.Deps.SessionManager.SetState(chatID, constants.STATE_REFERRAL_PAYOUT_SBP)
+		msgText = "📱 Введите номер телефона, привязанный к СБП (например, +79001234567):"
+		if user.Phone.Valid && user.Phone.String != "" {
+			msgText += fmt.Sprintf("\n\nВаш номер в профиле: %s", utils.FormatPhoneNumber(user.Phone.String))
+		}
+	default:
+		log.Printf("[REFERRAL_HANDLER] Неизвестный способ выплаты '%s'. ChatID=%d", method, chatID)
+		bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неизвестный способ выплаты.")
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Другой способ", "request_referral_payout")),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, originalMessageID, msgText, &keyboard, ""); err != nil {
+		log.Printf("[REFERRAL_HANDLER] Ошибка запроса реквизитов ChatID=%d: %v", chatID, err)
+	}
+}
+
+// handleReferralPayoutDetailsInput проверяет номер карты или телефон СБП и создает запрос на выплату.
+func (bh *BotHandler) handleReferralPayoutDetailsInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	method := constants.REFERRAL_PAYOUT_METHOD_CARD
+	details := strings.ReplaceAll(strings.TrimSpace(text), " ", "")
+	if bh.Deps.SessionManager.GetState(chatID) == constants.STATE_REFERRAL_PAYOUT_SBP {
+		method = constants.REFERRAL_PAYOUT_METHOD_SBP
+		phone, err := utils.ValidatePhoneNumber(details)
+		if err != nil {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Введите номер телефона еще раз.", err))
+			return
+		}
+		details = phone
+	} else if err := utils.ValidateCardNumber(details); err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Введите номер карты еще раз.", err))
+		return
+	}
+	bh.submitReferralPayoutRequest(chatID, user, method, details, botMenuMsgID)
+}
+
+// submitReferralPayoutRequest создает запрос на выплату всех доступных бонусов с указанными реквизитами
+// и уведомляет администраторов.
+func (bh *BotHandler) submitReferralPayoutRequest(chatID int64, user models.User, method, details string, messageIDToEdit int) {
+	totalUnpaidBonus, unpaidReferralIDs, err := availableReferralBonus(chatID)
+	if err != nil {
+		log.Printf("[REFERRAL_HANDLER] Ошибка БД при получении рефералов для выплаты ChatID=%d: %v", chatID, err)
+		_, _ = bh.sendErrorMessageHelper(chatID, messageIDToEdit, "Ошибка получения данных о ваших бонусах.")
+		return
+	}
+	if totalUnpaidBonus <= 0 || totalUnpaidBonus < referralMinPayoutAmount() {
+		bh.SendMyReferralsMenu(chatID, messageIDToEdit)
+		return
+	}
 
 	payoutRequest := models.ReferralPayoutRequest{
-		UserChatID:  chatID,
-		Amount:      totalUnpaidBonus,
-		Status:      constants.PAYOUT_REQUEST_STATUS_PENDING, // Начальный статус / Initial status
-		RequestedAt: time.Now(),
-		ReferralIDs: unpaidReferralIDs, // ID рефералов, включенных в этот запрос / IDs of referrals included in this request
+		UserChatID:     chatID,
+		Amount:         totalUnpaidBonus,
+		Status:         constants.PAYOUT_REQUEST_STATUS_PENDING, // Начальный статус / Initial status
+		RequestedAt:    time.Now(),
+		ReferralIDs:    unpaidReferralIDs, // ID рефералов, включенных в этот запрос / IDs of referrals included in this request
+		PaymentMethod:  sql.NullString{String: method, Valid: true},
+		PaymentDetails: sql.NullString{String: details, Valid: true},
 	}
 	requestID, err := db.CreateReferralPayoutRequest(payoutRequest)
 	if err != nil {
 		log.Printf("[REFERRAL_HANDLER] Ошибка БД при создании запроса на выплату для ChatID=%d: %v", chatID, err)
-		_, _ = bh.sendErrorMessageHelper(chatID, originalMessageID, "Не удалось создать запрос на выплату. Попробуйте позже.")
+		_, _ = bh.sendErrorMessageHelper(chatID, messageIDToEdit, "Не удалось создать запрос на выплату. Попробуйте позже.")
 		return
 	}
 
 	// Уведомляем администраторов/бухгалтерию о новом запросе
 	// Notify administrators/accounting about the new request
-	adminMessage := fmt.Sprintf("💸 Новый запрос на выплату реферальных бонусов!\nID Запроса: *%d*\nПользователь: %s (ChatID: `%d`)\nСумма: *%.0f ₽*",
-		requestID, utils.GetUserDisplayName(user), chatID, totalUnpaidBonus)
+	adminMessage := fmt.Sprintf("💸 Новый запрос на выплату реферальных бонусов!\nID Запроса: *%d*\nПользователь: %s (ChatID: `%d`)\nСумма: *%.0f ₽*\nСпособ: %s",
+		requestID, utils.EscapeTelegramMarkdown(utils.GetUserDisplayName(user)), chatID, totalUnpaidBonus, constants.ReferralPayoutMethodDisplayMap[method])
 
 	bh.NotifyAdminsPayoutRequest(adminMessage, requestID) // Реализация этой функции ниже / Implementation of this function below
-	bh.SendReferralPayoutConfirmation(chatID, originalMessageID, totalUnpaidBonus, requestID)
+	bh.SendReferralPayoutConfirmation(chatID, messageIDToEdit, totalUnpaidBonus, requestID)
 }
 
 // NotifyAdminsPayoutRequest уведомляет администраторов и/или бухгалтерию о новом запросе на выплату.
@@ -249,18 +368,19 @@ func (bh *BotHandler) NotifyAdminsPayoutRequest(message string, requestID int64)
 	if err != nil {
 		log.Printf("NotifyAdminsPayoutRequest: ошибка получения списка администраторов: %v", err)
 	} else {
+		// Владельцу - кнопка перехода к запросу в очереди выплат
+		// Owners get a button to open the request in the payout queue
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("💸 Открыть запрос", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW, requestID)),
+			),
+		)
 		for _, admin := range admins {
-			// Можно добавить кнопку для быстрого перехода к управлению этим запросом
-			// Can add a button for quick navigation to manage this request
-			// keyboard := tgbotapi.NewInlineKeyboardMarkup(
-			// 	tgbotapi.NewInlineKeyboardRow(
-			// 		tgbotapi.NewInlineKeyboardButtonData("处理请求", fmt.Sprintf("admin_view_payout_request_%d", requestID)),
-			// 	),
-			// )
-			// msg := tgbotapi.NewMessage(admin.ChatID, message)
-			// msg.ReplyMarkup = keyboard
-			// bh.Deps.BotClient.Send(msg)
-			bh.sendMessage(admin.ChatID, message) // Отправляем простое сообщение / Send a simple message
+			if admin.Role == constants.ROLE_OWNER {
+				bh.sendMessageWithKeyboard(admin.ChatID, message, &keyboard)
+			} else {
+				bh.sendMessageWithKeyboard(admin.ChatID, message, nil)
+			}
 		}
 	}
 
@@ -268,12 +388,12 @@ func (bh *BotHandler) NotifyAdminsPayoutRequest(message string, requestID int64)
 	// Send to a special accounting chat if configured
 	if bh.Deps.Config.AccountingChatID != 0 {
 		// Аналогично, можно добавить кнопки / Similarly, buttons can be added
-		bh.sendMessage(bh.Deps.Config.AccountingChatID, message)
+		bh.sendMessageWithKeyboard(bh.Deps.Config.AccountingChatID, message, nil)
 	}
 
 	// Также можно отправить в общую группу, если это релевантно
 	// Can also send to the common group if relevant
 	if bh.Deps.Config.GroupChatID != 0 && bh.Deps.Config.GroupChatID != bh.Deps.Config.AccountingChatID {
-		bh.sendMessage(bh.Deps.Config.GroupChatID, message)
+		bh.sendMessageWithKeyboard(bh.Deps.Config.GroupChatID, message, nil)
 	}
 }
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"Original/internal/payments"
 	"Original/internal/session"
 	"fmt"
 	"log"
@@ -14,7 +13,8 @@ import (
 	"Original/internal/constants" //
 	"Original/internal/db"
 	"Original/internal/models" // Нужен для user / Needed for user
-	"Original/internal/utils"  //
+	"Original/internal/payments"
+	"Original/internal/utils" //
 )
 
 // dispatchOrderCallbacks маршрутизирует коллбэки, связанные с созданием и редактированием заказа.
@@ -40,6 +40,16 @@ func (bh *BotHandler) dispatchOrderCallbacks(currentCommand string, parts []stri
 				newMenuMessageID = sentMsg.MessageID
 			}
 		}
+	case constants.CALLBACK_PREFIX_RECEIPT_EMAIL:
+		if len(parts) == 1 {
+			newMenuMessageID = bh.handleReceiptEmailPrompt(chatID, user, parts[0], originalMessageID)
+		} else {
+			log.Printf("[CALLBACK_ORDER] Некорректный формат для '%s': %v. Ожидался ID заказа. ChatID=%d", currentCommand, parts, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка формата: email для чека.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+		}
 	case constants.CALLBACK_PREFIX_SELECT_HOUR: // например, select_hour_09
 		if len(parts) == 1 {
 			hourStr := parts[0]
@@ -158,8 +168,9 @@ func (bh *BotHandler) dispatchOrderCallbacks(currentCommand string, parts []stri
 	case "view_uploaded_media":
 		newMenuMessageID = bh.handleViewUploadedMedia(chatID, user, originalMessageID)
 
-	case "payment_now", "payment_later":
-		paymentType := strings.TrimPrefix(currentCommand, "payment_")
+	case "payment_now", "payment_later", "payment_yookassa", "payment_telegram", "payment_sbp", "payment_cash":
+		// "payment_now"/"payment_later" остаются для кнопок, отправленных до появления провайдеров
+		paymentType := payments.NormalizeMethod(strings.TrimPrefix(currentCommand, "payment_"))
 		newMenuMessageID = bh.handlePaymentSelection(chatID, user, []string{paymentType}, originalMessageID)
 
 	case "change_order_phone":
@@ -298,6 +309,28 @@ func (bh *BotHandler) dispatchOrderCallbacks(currentCommand string, parts []stri
 				newMenuMessageID = sentMsg.MessageID
 			}
 		}
+	case "edit_field_details":
+		if len(parts) == 1 {
+			orderIDStr := parts[0]
+			newMenuMessageID = bh.handleEditFieldSelection(chatID, user, "details", orderIDStr, originalMessageID)
+		} else {
+			log.Printf("[CALLBACK_ORDER] Некорректный формат для 'edit_field_details': %v. ChatID=%d", parts, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка формата: ред. объема и доступа.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+		}
+	case constants.CALLBACK_PREFIX_ORDER_DETAIL: // ord_det_STEP_VALUE
+		if len(parts) == 2 {
+			bh.handleOrderDetailAnswer(chatID, parts[0], parts[1], originalMessageID)
+			newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+		} else {
+			log.Printf("[CALLBACK_ORDER] Некорректный формат для '%s': %v. Ожидалось ШАГ_ЗНАЧЕНИЕ. ChatID=%d", currentCommand, parts, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка формата: объем и доступ.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+		}
 	case "edit_field": // Общий обработчик, если предыдущие не сработали (должны)
 		if len(parts) == 2 {
 			newMenuMessageID = bh.handleEditFieldSelection(chatID, user, parts[0], parts[1], originalMessageID)
@@ -328,6 +361,67 @@ func (bh *BotHandler) dispatchOrderCallbacks(currentCommand string, parts []stri
 				newMenuMessageID = sentMsg.MessageID
 			}
 		}
+	case constants.CALLBACK_PREFIX_REPEAT_ORDER: // repeat_order_ORDERID
+		orderID, errID := int64(0), fmt.Errorf("нет ID заказа")
+		if len(parts) == 1 {
+			orderID, errID = strconv.ParseInt(parts[0], 10, 64)
+		}
+		if errID != nil {
+			log.Printf("[CALLBACK_ORDER] Некорректный формат для '%s': %s. Ожидался ID заказа. ChatID=%d", currentCommand, data, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный формат команды повтора заказа.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+			break
+		}
+		bh.StartRepeatOrder(chatID, user, orderID, originalMessageID)
+		newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+	case constants.CALLBACK_PREFIX_QUOTE_PICK: // quote_pick_OPTIONID
+		if len(parts) == 1 {
+			newMenuMessageID = bh.handleQuotePick(chatID, user, parts[0], originalMessageID)
+		} else {
+			log.Printf("[CALLBACK_ORDER] Некорректный формат для '%s': %s. Ожидался ID варианта. ChatID=%d", currentCommand, data, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный формат выбора варианта.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+		}
+	case constants.CALLBACK_PREFIX_QUOTE_NEW, constants.CALLBACK_PREFIX_QUOTE_TTL: // quote_new_ORDERID, quote_ttl_ORDERID_HOURS
+		if !utils.IsOperatorOrHigher(user.Role) {
+			sentMsg, _ = bh.sendAccessDenied(chatID, originalMessageID)
+			if sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+			return newMenuMessageID
+		}
+		var orderID int64
+		errID := fmt.Errorf("нет ID заказа")
+		if len(parts) >= 1 {
+			orderID, errID = strconv.ParseInt(parts[0], 10, 64)
+		}
+		if errID != nil {
+			log.Printf("[CALLBACK_ORDER] Некорректный формат для '%s': %v. ChatID=%d", currentCommand, parts, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный ID заказа.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+			break
+		}
+		if currentCommand == constants.CALLBACK_PREFIX_QUOTE_NEW {
+			bh.SendQuoteOptionsPrompt(chatID, orderID, originalMessageID)
+		} else if hours, errHours := strconv.Atoi(parts[len(parts)-1]); len(parts) == 2 && errHours == nil && hours > 0 {
+			bh.handleQuoteSend(chatID, user, orderID, hours, originalMessageID)
+		} else {
+			log.Printf("[CALLBACK_ORDER] Некорректный срок в '%s': %v. ChatID=%d", currentCommand, parts, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный срок ответа.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+			break
+		}
+		if currentMsgID := bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID; currentMsgID != 0 {
+			newMenuMessageID = currentMsgID
+		}
 	case "cancel_order_operator", "cancel_order_confirm":
 		actionType := strings.TrimPrefix(currentCommand, "cancel_order_")
 		if len(parts) == 1 {
@@ -374,6 +468,29 @@ func (bh *BotHandler) dispatchOrderCallbacks(currentCommand string, parts []stri
 			}
 		}
 
+	case constants.CALLBACK_PREFIX_PRICE_ESTIMATE_ACCEPT: // parts: [ORDERID]
+		if !utils.IsOperatorOrHigher(user.Role) && user.Role != constants.ROLE_DRIVER {
+			sentMsg, _ = bh.sendAccessDenied(chatID, originalMessageID)
+			if sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+			return newMenuMessageID
+		}
+		if len(parts) == 1 {
+			if orderID, err := strconv.ParseInt(parts[0], 10, 64); err == nil {
+				bh.handlePriceEstimateAccept(chatID, user, orderID, originalMessageID)
+				if currentMsgID := bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID; currentMsgID != 0 {
+					newMenuMessageID = currentMsgID
+				}
+			} else {
+				log.Printf("[CALLBACK_ORDER] Ошибка конвертации OrderID для '%s': %v. ChatID=%d", currentCommand, parts, chatID)
+				sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный ID заказа.")
+				if errHelper == nil && sentMsg.MessageID != 0 {
+					newMenuMessageID = sentMsg.MessageID
+				}
+			}
+		}
+
 	case constants.CALLBACK_PREFIX_OP_SKIP_COST: // parts: [ORDERID]
 		if !utils.IsOperatorOrHigher(user.Role) {
 			sentMsg, _ = bh.sendAccessDenied(chatID, originalMessageID)
@@ -557,8 +674,16 @@ func (bh *BotHandler) handleAcceptCost(chatID int64, user models.User, orderIDSt
 		return newMenuMessageID
 	}
 
-	if orderData.Payment == "now" {
-		log.Printf("[ORDER_HANDLER] Клиент ChatID=%d подтвердил стоимость для заказа #%d. Метод оплаты: 'now'. Переход к оплате.", chatID, orderID)
+	if _, errQuote := db.GetPendingOrderQuote(orderID); errQuote == nil {
+		sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "По заказу отправлено несколько вариантов стоимости - выберите один из них.")
+		if errHelper == nil && sentMsg.MessageID != 0 {
+			newMenuMessageID = sentMsg.MessageID
+		}
+		return newMenuMessageID
+	}
+
+	if bh.RequiresPrepayment(orderData) {
+		log.Printf("[ORDER_HANDLER] Клиент ChatID=%d подтвердил стоимость для заказа #%d. Метод оплаты: '%s'. Переход к оплате.", chatID, orderID, orderData.Payment)
 		errDb = db.UpdateOrderStatus(orderID, constants.STATUS_AWAITING_PAYMENT)
 		if errDb != nil {
 			log.Printf("[ORDER_HANDLER] Ошибка обновления статуса заказа #%d на AWAITING_PAYMENT: %v. ChatID=%d", orderID, errDb, chatID)
@@ -581,6 +706,8 @@ func (bh *BotHandler) handleAcceptCost(chatID int64, user models.User, orderIDSt
 			return newMenuMessageID
 		}
 
+		bh.RegisterCashPayment(orderData)
+
 		log.Printf("[ORDER_HANDLER] Клиент ChatID=%d подтвердил стоимость для заказа #%d. Уведомление операторам...", chatID, orderID)
 		operatorMsgText := fmt.Sprintf("✅ Клиент %s (ChatID: `%d`) подтвердил стоимость для заказа №%d.\nЗаказ переведен в статус '%s'.",
 			utils.GetUserDisplayName(user), chatID, orderID, constants.StatusDisplayMap[constants.STATUS_INPROGRESS])
@@ -620,19 +747,31 @@ func (bh *BotHandler) sendPaymentMenu(chatID int64, orderID int, messageIDToEdit
 		return
 	}
 
+	receiptContact := "чек придет SMS на номер из заказа"
+	emailButtonText := "📧 Получить чек на email"
+	if email := receiptEmailForOrder(orderData); email != "" {
+		receiptContact = fmt.Sprintf("чек придет на %s", utils.EscapeTelegramMarkdown(email))
+		emailButtonText = "📧 Изменить email для чека"
+	}
+
 	text := fmt.Sprintf(
 		"💳 *Переход к оплате*\n\n"+
 			"Заказ: №%d\n"+
-			"Сумма к оплате: *%.2f ₽*\n\n"+
+			"Сумма к оплате: *%.2f ₽*\n"+
+			"Чек: %s\n\n"+
 			"Нажмите на кнопку ниже, чтобы перейти на страницу безопасной оплаты.",
 		orderID,
 		orderData.Cost.Float64,
+		receiptContact,
 	)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить заказ", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_PAY_ORDER, orderID)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(emailButtonText, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECEIPT_EMAIL, orderID)),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Позже", "my_orders_page_0"),
 		),
@@ -644,6 +783,61 @@ func (bh *BotHandler) sendPaymentMenu(chatID int64, orderID int, messageIDToEdit
 	}
 }
 
+// handleReceiptEmailPrompt запрашивает у клиента email, на который YooKassa отправит чек.
+// Email сохраняется в профиле и используется для всех следующих чеков.
+func (bh *BotHandler) handleReceiptEmailPrompt(chatID int64, user models.User, orderIDStr string, originalMessageID int) int {
+	orderID, err := strconv.ParseInt(orderIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[ORDER_HANDLER] Ошибка: неверный OrderID в '%s': '%s'. ChatID=%d", constants.CALLBACK_PREFIX_RECEIPT_EMAIL, orderIDStr, chatID)
+		return originalMessageID
+	}
+
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_RECEIPT_EMAIL_INPUT)
+	tempData := bh.Deps.SessionManager.GetTempOrder(chatID)
+	tempData.ID = orderID
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempData)
+
+	text := "📧 Введите email, на который отправить кассовый чек.\nЧтобы получать чек только по SMS, отправьте «-»."
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к моим заказам", "my_orders_page_0"),
+		),
+	)
+	sentMsg, errSend := bh.sendOrEditMessageHelper(chatID, originalMessageID, text, &keyboard, "")
+	if errSend != nil {
+		log.Printf("handleReceiptEmailPrompt: ошибка отправки запроса email: %v", errSend)
+		return originalMessageID
+	}
+	return sentMsg.MessageID
+}
+
+// handleReceiptEmailInput сохраняет email для чеков и возвращает клиента к меню оплаты.
+func (bh *BotHandler) handleReceiptEmailInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	tempData := bh.Deps.SessionManager.GetTempOrder(chatID)
+
+	email := ""
+	if strings.TrimSpace(text) != "-" {
+		validEmail, errValidate := utils.ValidateEmail(text)
+		if errValidate != nil {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Неверный формат email. Пример: name@example.ru. Или отправьте «-», чтобы не указывать email.")
+			return
+		}
+		email = validEmail
+	}
+
+	if err := db.UpdateUserEmail(chatID, email); err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Не удалось сохранить email. Попробуйте позже.")
+		return
+	}
+	bh.Deps.SessionManager.ClearState(chatID)
+	if tempData.ID != 0 {
+		bh.sendPaymentMenu(chatID, int(tempData.ID), botMenuMsgID)
+		return
+	}
+	bh.sendInfoMessage(chatID, botMenuMsgID, "✅ Email для чеков сохранен.", "my_orders_page_0")
+}
+
 // handlePayOrder обрабатывает нажатие кнопки "Оплатить".
 func (bh *BotHandler) handlePayOrder(chatID int64, user models.User, orderIDStr string, originalMessageID int) int {
 	log.Printf("[ORDER_HANDLER] Начало процесса оплаты: OrderIDStr=%s, ChatID=%d", orderIDStr, chatID)
@@ -676,18 +870,15 @@ func (bh *BotHandler) handlePayOrder(chatID int64, user models.User, orderIDStr
 		return newMenuMessageID
 	}
 
-	// --- Логика создания платежа (YooKassa) ---
-	shopID := bh.Deps.Config.YooKassaShopID
-	secretKey := bh.Deps.Config.YooKassaSecretKey
-
-	if shopID == "" || secretKey == "" {
-		log.Printf("handlePayOrder: YooKassa Shop ID или Secret Key не установлены в конфигурации.")
-		bh.sendErrorMessageHelper(chatID, originalMessageID, "❌ Ошибка конфигурации платежной системы.")
+	// --- Логика создания платежа через провайдера, выбранного в заказе ---
+	provider, providerOk := bh.paymentProvider(orderData.Payment)
+	if !providerOk || !provider.Prepaid() {
+		log.Printf("handlePayOrder: способ оплаты '%s' заказа #%d не включен или не предполагает онлайн-оплату.", orderData.Payment, orderID)
+		bh.sendErrorMessageHelper(chatID, originalMessageID, "❌ Выбранный способ оплаты сейчас недоступен. Свяжитесь с оператором.")
 		return newMenuMessageID
 	}
 
 	description := fmt.Sprintf("Оплата заказа №%d", orderID)
-	amount := orderData.Cost.Float64
 	// A simple return URL, could be improved to lead back to the bot
 	returnURL := fmt.Sprintf("https://t.me/%s", bh.Deps.Config.BotUsername)
 
@@ -699,7 +890,7 @@ func (bh *BotHandler) handlePayOrder(chatID int64, user models.User, orderIDStr
 		log.Printf("handlePayOrder: ВНИМАНИЕ! Телефон для заказа #%d не найден. Используется номер-заглушка.", orderID)
 	}
 
-	paymentURL, errPay := payments.CreatePaymentLink(shopID, secretKey, orderID, amount, "RUB", description, returnURL, clientPhone)
+	paymentURL, errPay := bh.createOrReusePayment(provider, orderData, description, returnURL, clientPhone)
 	// --- КОНЕЦ ИЗМЕНЕНИЯ ---
 
 	if errPay != nil {
@@ -709,7 +900,7 @@ func (bh *BotHandler) handlePayOrder(chatID int64, user models.User, orderIDStr
 	}
 
 	// --- Отправка ссылки на оплату ---
-	text := "✅ Ваша ссылка на оплату готова!\n\nНажмите кнопку ниже, чтобы перейти к странице безопасной оплаты."
+	text := fmt.Sprintf("✅ Ваша ссылка на оплату готова!\n\nСпособ оплаты: %s.\nНажмите кнопку ниже, чтобы перейти к странице безопасной оплаты.", provider.Title())
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonURL("Перейти к оплате", paymentURL),
@@ -799,6 +990,10 @@ func (bh *BotHandler) handleSubcategorySelection(chatID int64, user models.User,
 	}
 
 	tempOrder.Subcategory = subcategoryKey
+	// Ответы об объеме и доступе, которые для новой подкатегории не задаются, сбрасываются
+	if err := utils.NormalizeOrderDetails(&tempOrder.Order); err != nil {
+		log.Printf("[ORDER_HANDLER] Некорректные объем и доступ в сессии: %v. ChatID=%d", err, chatID)
+	}
 	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
 
 	history := bh.Deps.SessionManager.GetHistory(chatID)
@@ -817,10 +1012,13 @@ func (bh *BotHandler) handleSubcategorySelection(chatID int64, user models.User,
 			newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
 			return newMenuMessageID
 		}
+		if err := db.UpdateOrderDetails(tempOrder.ID, tempOrder.Order); err != nil {
+			log.Printf("[ORDER_HANDLER] Ошибка сброса объема и доступа для заказа #%d: %v. ChatID=%d", tempOrder.ID, err, chatID)
+		}
 		bh.SendEditOrderMenu(chatID, originalMessageID)
 	} else {
-		log.Printf("[ORDER_HANDLER] Переход к вводу описания после выбора подкатегории '%s'. ChatID=%d", subcategoryKey, chatID)
-		bh.SendDescriptionInputMenu(chatID, originalMessageID)
+		log.Printf("[ORDER_HANDLER] Переход к вопросам об объеме и доступе после выбора подкатегории '%s'. ChatID=%d", subcategoryKey, chatID)
+		bh.StartOrderDetails(chatID, originalMessageID)
 	}
 	newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
 	return newMenuMessageID
@@ -1013,7 +1211,7 @@ func (bh *BotHandler) handleDateTimeSelection(chatID int64, user models.User, co
 			_ = db.UpdateOrderField(tempOrder.ID, "time", "СРОЧНО")
 			bh.SendEditOrderMenu(chatID, originalMessageID)
 		} else {
-			bh.SendPhoneInputMenu(chatID, user, originalMessageID)
+			bh.continueOrderAfterDateTime(chatID, user, originalMessageID)
 		}
 	} else if command == "select_date" && len(parts) == 3 {
 		dayStr, monthStr, yearStr := parts[0], parts[1], parts[2]
@@ -1058,7 +1256,7 @@ func (bh *BotHandler) handleDateTimeSelection(chatID int64, user models.User, co
 			_ = db.UpdateOrderField(tempOrder.ID, "time", timeStr)
 			bh.SendEditOrderMenu(chatID, originalMessageID)
 		} else {
-			bh.SendPhoneInputMenu(chatID, user, originalMessageID)
+			bh.continueOrderAfterDateTime(chatID, user, originalMessageID)
 		}
 	} else {
 		log.Printf("[ORDER_HANDLER] Ошибка: неизвестный тип выбора для 'select...': Command='%s', Parts=%v, ChatID=%d", command, parts, chatID)
@@ -1202,6 +1400,16 @@ func (bh *BotHandler) handlePaymentSelection(chatID int64, user models.User, par
 		return newMenuMessageID
 	}
 	paymentType := parts[0]
+	if _, ok := bh.paymentProvider(paymentType); !ok {
+		log.Printf("[ORDER_HANDLER] Способ оплаты '%s' не включен. ChatID=%d", paymentType, chatID)
+		sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Этот способ оплаты сейчас недоступен. Выберите другой.")
+		menuMsgID := originalMessageID
+		if errHelper == nil && sentMsg.MessageID != 0 {
+			menuMsgID = sentMsg.MessageID
+		}
+		bh.SendPaymentSelectionMenu(chatID, menuMsgID)
+		return bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+	}
 
 	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
 	tempOrder.Payment = paymentType
@@ -1445,6 +1653,8 @@ func (bh *BotHandler) handleEditFieldSelection(chatID int64, user models.User, f
 		bh.SendPhotoInputMenu(chatID, originalMessageID)
 	case "payment":
 		bh.SendPaymentSelectionMenu(chatID, originalMessageID)
+	case "details":
+		bh.startEditOrderDetails(chatID, originalMessageID)
 	default:
 		log.Printf("[ORDER_HANDLER] Ошибка: неизвестное поле для редактирования '%s'. ChatID=%d", fieldKey, chatID)
 		sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Неизвестное поле для редактирования.")
@@ -1534,6 +1744,7 @@ func (bh *BotHandler) handleConfirmOrderFinal(chatID int64, user models.User, or
 		}
 		db.UpdateOrderPhotosAndVideos(orderID, tempOrderSession.Photos, tempOrderSession.Videos)
 		db.UpdateOrderField(orderID, "payment", tempOrderSession.Payment)
+		db.UpdateOrderDetails(orderID, tempOrderSession.Order)
 
 		// Стоимость заказа (если оператор ее установил)
 		if tempOrderSession.Cost.Valid && tempOrderSession.Cost.Float64 > 0 {
@@ -1886,3 +2097,75 @@ func (bh *BotHandler) handleDriverStartOrderCreation(chatID int64, user models.U
 	bh.SendCategoryMenu(chatID, user.FirstName, messageIDToEdit)
 	log.Printf("BotHandler.handleDriverStartOrderCreation: Водителю %d отправлено меню выбора категории.", chatID)
 }
+
+// StartRepeatOrder начинает новый заказ по образцу заказа клиента из истории: категория, описание, адрес,
+// телефон и способ оплаты копируются, а клиент сразу переходит к выбору даты.
+func (bh *BotHandler) StartRepeatOrder(chatID int64, user models.User, orderID int64, messageIDToEdit int) error {
+	log.Printf("BotHandler.StartRepeatOrder: клиент ChatID=%d повторяет заказ #%d. MessageIDToEdit: %d", chatID, orderID, messageIDToEdit)
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Заказ №%d не найден.", orderID))
+		return err
+	}
+	if order.UserChatID != chatID {
+		bh.sendAccessDenied(chatID, messageIDToEdit)
+		return fmt.Errorf("заказ #%d принадлежит другому клиенту", orderID)
+	}
+	if order.Status == constants.STATUS_DRAFT {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Черновик нельзя повторить - завершите его оформление.")
+		return fmt.Errorf("заказ #%d - черновик", orderID)
+	}
+
+	bh.Deps.SessionManager.ClearState(chatID)
+	bh.Deps.SessionManager.ClearTempOrder(chatID)
+
+	tempOrder := session.NewTempOrder(chatID)
+	tempOrder.Category = order.Category
+	tempOrder.Subcategory = order.Subcategory
+	tempOrder.Description = order.Description
+	tempOrder.Name = order.Name
+	tempOrder.Phone = order.Phone
+	tempOrder.Address = order.Address
+	tempOrder.Latitude = order.Latitude
+	tempOrder.Longitude = order.Longitude
+	// Объем и доступ повторяются вместе с адресом: по ним подбираются машина и стоимость
+	tempOrder.VolumeM3 = order.VolumeM3
+	tempOrder.TonnageT = order.TonnageT
+	tempOrder.Floor = order.Floor
+	tempOrder.HasElevator = order.HasElevator
+	tempOrder.NeedsLoaders = order.NeedsLoaders
+	if order.Payment != "" {
+		// Способ оплаты мог быть отключен с момента исходного заказа - тогда клиент выберет его заново
+		if method := payments.NormalizeMethod(order.Payment); method != "" {
+			if _, ok := bh.paymentProvider(method); ok {
+				tempOrder.Payment = method
+			}
+		}
+	}
+	tempOrder.RepeatedFromOrderID = order.ID
+	tempOrder.CurrentMessageID = messageIDToEdit
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+
+	bh.SendDateSelectionMenu(chatID, messageIDToEdit, 0)
+	return nil
+}
+
+// continueOrderAfterDateTime ведет клиента дальше после выбора даты и времени: при повторе заказа сразу
+// к подтверждению (или к недостающим шагам), иначе - к вводу телефона.
+func (bh *BotHandler) continueOrderAfterDateTime(chatID int64, user models.User, messageIDToEdit int) {
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	if tempOrder.RepeatedFromOrderID == 0 || tempOrder.Phone == "" {
+		bh.SendPhoneInputMenu(chatID, user, messageIDToEdit)
+		return
+	}
+	if tempOrder.Address == "" {
+		bh.SendAddressInputMenu(chatID, messageIDToEdit)
+		return
+	}
+	if tempOrder.Payment == "" {
+		bh.SendPaymentSelectionMenu(chatID, messageIDToEdit)
+		return
+	}
+	log.Printf("[ORDER_HANDLER] Повтор заказа #%d: переход к подтверждению после выбора даты. ChatID=%d", tempOrder.RepeatedFromOrderID, chatID)
+	bh.SendOrderConfirmationMenu(chatID, messageIDToEdit)
+}
@@ -392,6 +392,44 @@ func (bh *BotHandler) dispatchOrderViewManageCallbacks(query *tgbotapi.CallbackQ
 				newMenuMessageID = sentMsg.MessageID
 			}
 		}
+	case constants.CALLBACK_PREFIX_ASSIGN_VEHICLE:
+		if !utils.IsOperatorOrHigher(user.Role) {
+			sentMsg, _ = bh.sendAccessDenied(chatID, originalMessageID)
+			if sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+			return newMenuMessageID
+		}
+		if len(parts) == 2 {
+			orderID, errOrder := strconv.ParseInt(parts[0], 10, 64)
+			vehicleID, errVeh := strconv.ParseInt(parts[1], 10, 64)
+			if errOrder == nil && errVeh == nil {
+				// VEHICLEID = 0 снимает машину с заказа
+				errSet := db.SetOrderVehicle(orderID, sql.NullInt64{Int64: vehicleID, Valid: vehicleID > 0})
+				if errSet != nil {
+					log.Printf("[CALLBACK_ORDER_VM] Ошибка назначения машины #%d на заказ #%d: %v. ChatID=%d", vehicleID, orderID, errSet, chatID)
+					sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, fmt.Sprintf("❌ Машину не удалось назначить: %v", errSet))
+					if errHelper == nil && sentMsg.MessageID != 0 {
+						newMenuMessageID = sentMsg.MessageID
+					}
+					return newMenuMessageID
+				}
+				bh.SendAssignExecutorsMenu(chatID, orderID, originalMessageID)
+				newMenuMessageID = bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+			} else {
+				log.Printf("[CALLBACK_ORDER_VM] Ошибка конвертации ID для '%s': %v. ChatID=%d", currentCommand, parts, chatID)
+				sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка назначения машины (неверные ID).")
+				if errHelper == nil && sentMsg.MessageID != 0 {
+					newMenuMessageID = sentMsg.MessageID
+				}
+			}
+		} else {
+			log.Printf("[CALLBACK_ORDER_VM] Некорректный формат для '%s': %v. ChatID=%d", currentCommand, parts, chatID)
+			sentMsg, errHelper = bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка назначения машины.")
+			if errHelper == nil && sentMsg.MessageID != 0 {
+				newMenuMessageID = sentMsg.MessageID
+			}
+		}
 	case "unassign_executor":
 		if !utils.IsOperatorOrHigher(user.Role) {
 			sentMsg, _ = bh.sendAccessDenied(chatID, originalMessageID)
@@ -743,6 +781,7 @@ func (bh *BotHandler) handleMarkOrderCompleted(chatID int64, user models.User, o
 		clientMsg := fmt.Sprintf("✅ Ваш заказ №%d выполнен! Спасибо за использование нашего сервиса!", orderID)
 		bh.sendMessage(order.UserChatID, clientMsg)
 	}
+	bh.AccrueReferralBonus(orderID)
 
 	if user.Role != constants.ROLE_OWNER { // Уведомляем владельца и гл.операторов, если не они сами закрыли
 		ownerAndMainOps, _ := db.GetUsersByRole(constants.ROLE_OWNER, constants.ROLE_MAINOPERATOR)
@@ -779,13 +818,13 @@ func (bh *BotHandler) handleSetFinalCostPrompt(chatID int64, user models.User, o
 	tempData.CurrentMessageID = originalMessageID // Важно для редактирования этого сообщения
 	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempData)
 
-	promptText := fmt.Sprintf("💰 Введите новую итоговую стоимость для заказа №%d (текущая: %.0f ₽).\nЭто изменение только для внутреннего учета, клиенту уведомление не придет.", orderID, order.Cost.Float64)
+	promptText := fmt.Sprintf("💰 Введите новую итоговую стоимость для заказа №%d (текущая: %.0f ₽).\n%s\nЭто изменение только для внутреннего учета, клиенту уведомление не придет.", orderID, order.Cost.Float64, constants.CostBreakdownHint)
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад к заказу", fmt.Sprintf("view_order_ops_%d", orderID)),
 		),
 	)
-	bh.sendOrEditMessageHelper(chatID, originalMessageID, promptText, &keyboard, "")
+	bh.sendOrEditMessageHelper(chatID, originalMessageID, promptText, &keyboard, tgbotapi.ModeMarkdown)
 }
 
 // handleResumeOrder возобновляет отмененный заказ.
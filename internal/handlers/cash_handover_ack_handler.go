@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// NotifyDriverCashHandover отправляет водителю запрос подтвердить сумму, которую у него приняли.
+func (bh *BotHandler) NotifyDriverCashHandover(h models.CashHandover) {
+	driver, err := db.GetUserByID(int(h.DriverUserID))
+	if err != nil || driver.ChatID == 0 {
+		log.Printf("NotifyDriverCashHandover: не удалось уведомить водителя %d о сдаче #%d: %v", h.DriverUserID, h.ID, err)
+		return
+	}
+	if _, err := bh.sendMessageWithKeyboard(driver.ChatID, formatCashHandoverForDriver(h, false), cashHandoverAckKeyboard(h.ID)); err != nil {
+		log.Printf("NotifyDriverCashHandover: ошибка отправки запроса по сдаче #%d: %v", h.ID, err)
+	}
+}
+
+// handleDriverCashHandoverAck - водитель подтверждает, что сдал указанную сумму.
+func (bh *BotHandler) handleDriverCashHandoverAck(chatID int64, user models.User, handoverID int64, messageIDToEdit int) {
+	handover, err := db.AcknowledgeCashHandover(handoverID, user.ID, true, "")
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось подтвердить сдачу: %v", err))
+		return
+	}
+	log.Printf("handleDriverCashHandoverAck: водитель %d подтвердил сдачу #%d", user.ID, handoverID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main")),
+	)
+	text := fmt.Sprintf("✅ Вы подтвердили сдачу #%d на *%.0f ₽*. Спасибо!", handover.ID, handover.Amount)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("handleDriverCashHandoverAck: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendDriverCashHandoverDisputePrompt - водитель не согласен с суммой и объясняет почему.
+func (bh *BotHandler) SendDriverCashHandoverDisputePrompt(chatID int64, user models.User, handoverID int64, messageIDToEdit int) {
+	handover, err := db.GetCashHandoverByID(handoverID)
+	if err != nil || handover.DriverUserID != user.ID {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Сдача не найдена.")
+		return
+	}
+	if handover.AckStatus != constants.CASH_ACK_PENDING || handover.CanceledAt.Valid {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("Ответ по сдаче #%d уже не требуется.", handoverID))
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_CASH_HANDOVER_DISPUTE)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.CashHandoverID = handoverID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := formatCashHandoverForDriver(handover, false) +
+		"\n\n⚠️ Напишите, сколько вы на самом деле сдали и что не так. Сообщение получит владелец."
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Все-таки подтверждаю", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_CASH_HANDOVER_ACK, handoverID)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendDriverCashHandoverDisputePrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleDriverCashHandoverDisputeInput записывает несогласие водителя в журнал расхождений и сообщает владельцам.
+func (bh *BotHandler) handleDriverCashHandoverDisputeInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	comment := strings.TrimSpace(text)
+	if comment == "" {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Напишите, с чем вы не согласны.")
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	handover, err := db.AcknowledgeCashHandover(tempData.CashHandoverID, user.ID, false, comment)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить ответ: %v", err))
+		return
+	}
+	log.Printf("handleDriverCashHandoverDisputeInput: водитель %d оспорил сдачу #%d", user.ID, handover.ID)
+	tempData.CashHandoverID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_IDLE)
+
+	bh.NotifyOwnersCashHandoverDisputed(user, handover, comment)
+
+	bh.sendInfoMessage(chatID, botMenuMsgID, fmt.Sprintf("Ваш ответ по сдаче #%d передан владельцу. Он свяжется с вами для сверки.", handover.ID), "back_to_main")
+}
+
+// NotifyOwnersCashHandoverDisputed сообщает владельцам, что водитель не согласен с записанной суммой сдачи.
+func (bh *BotHandler) NotifyOwnersCashHandoverDisputed(driver models.User, handover models.CashHandover, comment string) {
+	owners, err := db.GetUsersByRole(constants.ROLE_OWNER)
+	if err != nil {
+		log.Printf("NotifyOwnersCashHandoverDisputed: ошибка получения владельцев: %v", err)
+	}
+	ownerText := fmt.Sprintf("⚠️ *%s* не согласен со сдачей #%d на *%.0f ₽*:\n%s",
+		utils.EscapeTelegramMarkdown(utils.GetUserDisplayName(driver)), handover.ID, handover.Amount, utils.EscapeTelegramMarkdown(comment))
+	ownerKeyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⚠️ Расхождения", fmt.Sprintf("%s_0", constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES))),
+	)
+	for _, owner := range owners {
+		bh.sendMessageWithKeyboard(owner.ChatID, ownerText, &ownerKeyboard)
+	}
+}
+
+// CheckCashHandoverAcks напоминает водителям о сдачах, которые они не подтвердили
+// за constants.CASH_ACK_REMIND_AFTER_HOURS. По каждой сдаче напоминание отправляется один раз.
+func (bh *BotHandler) CheckCashHandoverAcks() {
+	handovers, err := db.GetCashHandoversAwaitingAck(time.Duration(constants.CASH_ACK_REMIND_AFTER_HOURS) * time.Hour)
+	if err != nil {
+		log.Printf("[CASH_ACK] Ошибка получения неподтвержденных сдач: %v", err)
+		return
+	}
+	for _, h := range handovers {
+		driver, err := db.GetUserByID(int(h.DriverUserID))
+		if err != nil || driver.ChatID == 0 {
+			log.Printf("[CASH_ACK] Не удалось найти водителя %d для напоминания по сдаче #%d: %v", h.DriverUserID, h.ID, err)
+			continue
+		}
+		if _, err := bh.sendMessageWithKeyboard(driver.ChatID, formatCashHandoverForDriver(h, true), cashHandoverAckKeyboard(h.ID)); err != nil {
+			continue
+		}
+		db.MarkCashHandoverAckReminded(h.ID)
+	}
+	if len(handovers) > 0 {
+		log.Printf("[CASH_ACK] Напоминания о подтверждении отправлены по %d сдачам.", len(handovers))
+	}
+}
+
+// RunCashHandoverAckReminders периодически запускает CheckCashHandoverAcks. Блокирует вызывающую горутину.
+func (bh *BotHandler) RunCashHandoverAckReminders(interval time.Duration) {
+	log.Printf("[CASH_ACK] Напоминания о подтверждении сдачи наличных запущены с периодом %s.", interval)
+	bh.CheckCashHandoverAcks()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bh.CheckCashHandoverAcks()
+	}
+}
+
+// formatCashHandoverForDriver - текст запроса подтверждения сдачи для водителя.
+func formatCashHandoverForDriver(h models.CashHandover, reminder bool) string {
+	var sb strings.Builder
+	if reminder {
+		sb.WriteString("⏰ *Напоминание: подтвердите сдачу денег*\n\n")
+	} else {
+		sb.WriteString("💵 *Подтвердите сдачу денег*\n\n")
+	}
+	sb.WriteString(fmt.Sprintf("Сдача #%d от %s: *%.0f ₽*\n", h.ID, h.HandedAt.Format("02.01.2006 15:04"), h.Amount))
+	if diff := h.Difference(); math.Abs(diff) >= 0.01 {
+		sb.WriteString(fmt.Sprintf("Ожидалось: %.0f ₽ (разница %+.0f ₽)\n", h.ExpectedAmount.Float64, diff))
+	}
+	if len(h.Allocations) > 0 {
+		parts := make([]string, 0, len(h.Allocations))
+		for _, a := range h.Allocations {
+			parts = append(parts, fmt.Sprintf("#%d - %.0f ₽", a.SettlementID, a.Amount))
+		}
+		sb.WriteString("Зачтено в отчеты: " + strings.Join(parts, ", ") + "\n")
+	}
+	if h.Comment.Valid && h.Comment.String != "" {
+		sb.WriteString("💬 " + utils.EscapeTelegramMarkdown(h.Comment.String) + "\n")
+	}
+	sb.WriteString("\nЕсли сумма верна, нажмите «Подтверждаю».")
+	return sb.String()
+}
+
+func cashHandoverAckKeyboard(handoverID int64) *tgbotapi.InlineKeyboardMarkup {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Подтверждаю", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_CASH_HANDOVER_ACK, handoverID)),
+			tgbotapi.NewInlineKeyboardButtonData("⚠️ Не согласен", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_CASH_HANDOVER_DISPUTE, handoverID)),
+		),
+	)
+	return &keyboard
+}
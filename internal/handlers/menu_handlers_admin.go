@@ -16,6 +16,7 @@ import (
 	"Original/internal/db"
 	"Original/internal/models"
 	// "Original/internal/session" // Access via bh.Deps
+	"Original/internal/statements"
 	"Original/internal/utils"
 )
 
@@ -30,7 +31,10 @@ func (bh *BotHandler) SendExcelMenu(chatID int64, messageIDToEdit int) {
 	// Права доступа проверяются в callback_handler перед вызовом этой функции
 	// Access rights are checked in callback_handler before calling this function
 
-	msgText := "📑 Выберите тип Excel-отчета для генерации:"
+	now := time.Now()
+	lastMonthStart := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.Local)
+	lastMonthEnd := lastMonthStart.AddDate(0, 1, -1)
+	msgText := "📑 Выберите тип Excel-отчета для генерации.\n\nВыгрузка в 1С за произвольный период - в статистике за выбранный период."
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📋 Заказы (за сегодня)", "excel_generate_orders"),
@@ -39,6 +43,10 @@ func (bh *BotHandler) SendExcelMenu(chatID int64, messageIDToEdit int) {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("💰 Зарплаты (за сегодня)", "excel_generate_salaries"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧾 1С за прошлый месяц (XML)", accountingExportCallback(lastMonthStart, lastMonthEnd, statements.FormatXML)),
+			tgbotapi.NewInlineKeyboardButtonData("📄 CSV", accountingExportCallback(lastMonthStart, lastMonthEnd, statements.FormatCSV)),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню статистики", "stats_menu"), // Или back_to_main, если вызывается не из статистики
 			// Or back_to_main if not called from statistics
@@ -6,9 +6,12 @@ import (
 	"Original/internal/models"
 	"Original/internal/session"
 	"Original/internal/utils"
+	"database/sql"
 	"fmt"
 	tgbotapi "github.com/OvyFlash/telegram-bot-api"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -47,8 +50,37 @@ func (bh *BotHandler) StartDriverInlineReport(chatID int64, user models.User, me
 	}
 	tempData.CoveredOrdersRevenue = totalRevenue
 	tempData.CoveredOrderIDs = orderIDs
+	onlinePaid, errOnline := db.GetOnlinePaidAmountForOrders(orderIDs)
+	if errOnline != nil {
+		log.Printf("StartDriverInlineReport: ошибка подсчета онлайн-оплат для водителя UserID %d: %v", user.ID, errOnline)
+	}
+	tempData.OnlinePaidRevenue = onlinePaid
+
+	shareResolution, errShare := db.ResolveDriverShare(user.ID, unsettledOrders, tempData.SettlementCreateTime, bh.Deps.Config.DriverSharePercentage)
+	if errShare != nil {
+		log.Printf("StartDriverInlineReport: ошибка подбора ставки водителя UserID %d, используется ставка по умолчанию: %v", user.ID, errShare)
+	}
+	tempData.DriverShareRate = shareResolution.Rate
+	tempData.ShareRuleIDs = shareResolution.RuleIDs
+
+	// Машина берется из заказов или закрепленная за водителем; начальный пробег - из прошлого отчета по ней
+	vehicleID, errVehicle := db.ResolveSettlementVehicle(user.ID, orderIDs)
+	if errVehicle != nil {
+		log.Printf("StartDriverInlineReport: ошибка подбора машины для водителя UserID %d: %v", user.ID, errVehicle)
+	}
+	tempData.VehicleID = vehicleID
+	if vehicleID.Valid {
+		tempData.OdometerStart, _ = db.GetLastOdometerReading(vehicleID.Int64)
+	}
 
-	assignedLoadersMap := make(map[int64]string)
+	// Грузчики берутся из назначений executors: по каждому считаем заказы и основную категорию,
+	// а сумму предлагаем по тарифу из loader_pay_rates.
+	ordersByID := make(map[int64]models.Order, len(unsettledOrders))
+	for _, order := range unsettledOrders {
+		ordersByID[order.ID] = order
+	}
+	loaderIndexByUserID := make(map[int64]int)
+	loaderCategoryCounts := make(map[int64]map[string]int)
 	for _, orderID := range tempData.CoveredOrderIDs {
 		executors, errExec := db.GetExecutorsByOrderID(int(orderID))
 		if errExec != nil {
@@ -56,29 +88,38 @@ func (bh *BotHandler) StartDriverInlineReport(chatID int64, user models.User, me
 			continue
 		}
 		for _, executor := range executors {
-			if executor.Role == constants.ROLE_LOADER {
-				if _, exists := assignedLoadersMap[executor.UserID]; !exists {
-					loaderUser, errLoaderUser := db.GetUserByID(int(executor.UserID))
-					if errLoaderUser == nil {
-						assignedLoadersMap[executor.UserID] = utils.GetUserDisplayName(loaderUser)
-					} else {
-						assignedLoadersMap[executor.UserID] = fmt.Sprintf("Грузчик ID %d", executor.UserID)
-						log.Printf("StartDriverInlineReport: Не удалось получить детали для грузчика UserID %d: %v", executor.UserID, errLoaderUser)
-					}
+			if executor.Role != constants.ROLE_LOADER {
+				continue
+			}
+			idx, exists := loaderIndexByUserID[executor.UserID]
+			if !exists {
+				loaderName := fmt.Sprintf("Грузчик ID %d", executor.UserID)
+				if loaderUser, errLoaderUser := db.GetUserByID(int(executor.UserID)); errLoaderUser == nil {
+					loaderName = utils.GetUserDisplayName(loaderUser)
+				} else {
+					log.Printf("StartDriverInlineReport: Не удалось получить детали для грузчика UserID %d: %v", executor.UserID, errLoaderUser)
 				}
+				tempData.LoaderPayments = append(tempData.LoaderPayments, models.LoaderPaymentDetail{
+					LoaderUserID:     executor.UserID,
+					LoaderIdentifier: loaderName,
+				})
+				idx = len(tempData.LoaderPayments) - 1
+				loaderIndexByUserID[executor.UserID] = idx
+				loaderCategoryCounts[executor.UserID] = make(map[string]int)
 			}
+			tempData.LoaderPayments[idx].OrdersCount++
+			loaderCategoryCounts[executor.UserID][ordersByID[orderID].Category]++
 		}
 	}
 
-	if len(assignedLoadersMap) > 0 {
-		tempData.LoaderPayments = make([]models.LoaderPaymentDetail, 0, len(assignedLoadersMap))
-		for loaderUserID, loaderName := range assignedLoadersMap {
-			tempData.LoaderPayments = append(tempData.LoaderPayments, models.LoaderPaymentDetail{
-				LoaderUserID:     loaderUserID,
-				LoaderIdentifier: loaderName,
-				Amount:           0,
-			})
+	for i := range tempData.LoaderPayments {
+		lp := &tempData.LoaderPayments[i]
+		lp.Category = dominantCategory(loaderCategoryCounts[lp.LoaderUserID])
+		if errRate := db.SuggestLoaderPayment(lp, tempData.SettlementCreateTime); errRate != nil {
+			log.Printf("StartDriverInlineReport: ошибка расчета ЗП грузчика UserID %d по тарифу: %v", lp.LoaderUserID, errRate)
 		}
+	}
+	if len(tempData.LoaderPayments) > 0 {
 		log.Printf("StartDriverInlineReport: Предварительно загружено %d грузчиков для отчета.", len(tempData.LoaderPayments))
 	}
 
@@ -102,7 +143,7 @@ func (bh *BotHandler) SendDriverReportOverallMenu(chatID int64, user models.User
 		currentMessageIDForThisMenu = tempData.CurrentMessageID
 	}
 
-	tempData.RecalculateTotals(bh.Deps.Config.DriverSharePercentage)
+	tempData.RecalculateTotals(tempData.DriverShareRate)
 	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 
 	orderIDsStr := "не указаны"
@@ -111,9 +152,16 @@ func (bh *BotHandler) SendDriverReportOverallMenu(chatID int64, user models.User
 	}
 
 	text := fmt.Sprintf("📝 *Отчет по заказам (ID: %s)*\n", orderIDsStr)
-	text += fmt.Sprintf("💰 Общая выручка: *%.0f ₽*\n\n", tempData.CoveredOrdersRevenue)
+	text += fmt.Sprintf("💰 Общая выручка: *%.0f ₽*\n", tempData.CoveredOrdersRevenue)
+	if tempData.OnlinePaidRevenue > 0 {
+		text += fmt.Sprintf("💳 Из них оплачено онлайн: *%.0f ₽* (в кассу не сдается)\n", tempData.OnlinePaidRevenue)
+	}
+	text += "\n"
 	text += "✏️ *Ваши расходы:*\n"
 	fuelTextButton := fmt.Sprintf("⛽️ Топливо: %.0f ₽", tempData.FuelExpense)
+	if len(tempData.FuelReceiptFileIDs) > 0 {
+		fuelTextButton += fmt.Sprintf(" 📎%d", len(tempData.FuelReceiptFileIDs))
+	}
 
 	// --- ИЗМЕНЕНИЕ ОТОБРАЖЕНИЯ ПРОЧИХ РАСХОДОВ ---
 	var totalOtherExpenses float64
@@ -136,12 +184,19 @@ func (bh *BotHandler) SendDriverReportOverallMenu(chatID int64, user models.User
 		loadersSummary = fmt.Sprintf("%d чел, %.0f ₽", len(tempData.LoaderPayments), totalLoaderSalary)
 	}
 	loadersTextButton := fmt.Sprintf("👷‍♂️ ЗП Грузчикам: %s", loadersSummary)
+	vehicleTextButton := "🚚 Машина и пробег: не указаны"
+	if tempData.VehicleID.Valid {
+		vehicleTextButton = "🚚 " + bh.driverReportVehicleSummary(tempData.VehicleID.Int64, tempData.OdometerStart, tempData.OdometerEnd)
+	}
 
 	text += "\n-------------------------------------\n"
-	text += fmt.Sprintf("💸 Ваша зарплата (%.0f%%): *%.0f ₽*\n", bh.Deps.Config.DriverSharePercentage*100, tempData.DriverCalculatedSalary)
+	text += fmt.Sprintf("💸 Ваша зарплата (%.0f%%): *%.0f ₽*\n", tempData.DriverShareRate*100, tempData.DriverCalculatedSalary)
 	text += fmt.Sprintf("➡️ Сумма к сдаче в кассу: *%.0f ₽*\n", tempData.AmountToCashier)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(vehicleTextButton, constants.CALLBACK_PREFIX_DRIVER_REPORT_VEHICLE),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(fuelTextButton, constants.CALLBACK_PREFIX_DRIVER_REPORT_SET_FUEL),
 		),
@@ -172,14 +227,14 @@ func (bh *BotHandler) SendDriverReportOverallMenu(chatID int64, user models.User
 	}
 }
 
-// SendDriverReportFuelInputPrompt - запрос суммы топлива (без изменений)
+// SendDriverReportFuelInputPrompt - запрос суммы топлива и фото чеков
 func (bh *BotHandler) SendDriverReportFuelInputPrompt(chatID int64, user models.User, messageIDToEdit int) {
 	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_INPUT_FUEL)
 	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 	tempData.CurrentMessageID = messageIDToEdit
 	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 
-	text := "⛽ Введите сумму расходов на *топливо* (₽) по этим заказам:"
+	text := "⛽ Введите сумму расходов на *топливо* (₽) по этим заказам:\n\n" + receiptsHint(len(tempData.FuelReceiptFileIDs))
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к отчету", constants.CALLBACK_PREFIX_DRIVER_REPORT_OVERALL_MENU),
@@ -206,7 +261,11 @@ func (bh *BotHandler) SendDriverReportOtherExpensesMenu(chatID int64, user model
 		text.WriteString("_Прочих расходов пока не добавлено._\n")
 	} else {
 		for i, expense := range tempData.OtherExpenses {
-			text.WriteString(fmt.Sprintf("%d. %s: *%.0f ₽*\n", i+1, utils.EscapeTelegramMarkdown(expense.Description), expense.Amount))
+			text.WriteString(fmt.Sprintf("%d. %s: *%.0f ₽*", i+1, utils.EscapeTelegramMarkdown(expense.Description), expense.Amount))
+			if len(expense.ReceiptFileIDs) > 0 {
+				text.WriteString(fmt.Sprintf(" 📎%d", len(expense.ReceiptFileIDs)))
+			}
+			text.WriteString("\n")
 			// TODO: Можно добавить кнопки для редактирования/удаления каждого расхода, если потребуется
 			// CALLBACK_PREFIX_DRIVER_REPORT_EDIT_OTHER_EXPENSE_PROMPT_i
 			// CALLBACK_PREFIX_DRIVER_REPORT_DELETE_OTHER_EXPENSE_CONFIRM_i
@@ -255,6 +314,7 @@ func (bh *BotHandler) SendDriverReportOtherExpenseDescriptionPrompt(chatID int64
 		tempData.TempOtherExpenseDescription = currentDescription // Предзаполняем для редактирования
 	} else {
 		tempData.TempOtherExpenseDescription = "" // Очищаем для нового
+		tempData.TempOtherExpenseReceipts = nil
 	}
 	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 
@@ -300,7 +360,7 @@ func (bh *BotHandler) SendDriverReportOtherExpenseAmountPrompt(chatID int64, use
 	// EditingOtherExpenseIndex тоже уже должен быть установлен, если isEditing = true
 	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 
-	text := fmt.Sprintf("💰 Введите *сумму* (₽) для расхода '%s'", utils.EscapeTelegramMarkdown(description))
+	text := fmt.Sprintf("💰 Введите *сумму* (₽) для расхода '%s'\n\n%s", utils.EscapeTelegramMarkdown(description), receiptsHint(len(tempData.TempOtherExpenseReceipts)))
 	if isEditing {
 		text = fmt.Sprintf("💰 Введите новую *сумму* (₽) для '%s' (текущая: %.0f):", utils.EscapeTelegramMarkdown(description), currentAmount)
 	}
@@ -326,8 +386,12 @@ func (bh *BotHandler) SendDriverReportConfirmAddOtherExpense(chatID int64, user
 	tempData.CurrentMessageID = messageIDToEdit
 	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 
-	text := fmt.Sprintf("✅ Расход '%s: %.0f ₽' добавлен.\n\nДобавить еще один прочий расход?",
-		utils.EscapeTelegramMarkdown(addedDescription), addedAmount)
+	receiptsCount := 0
+	if len(tempData.OtherExpenses) > 0 {
+		receiptsCount = len(tempData.OtherExpenses[len(tempData.OtherExpenses)-1].ReceiptFileIDs)
+	}
+	text := fmt.Sprintf("✅ Расход '%s: %.0f ₽' добавлен.\n📎 Чеков: %d. Фото чека к этому расходу можно прислать сейчас.\n\nДобавить еще один прочий расход?",
+		utils.EscapeTelegramMarkdown(addedDescription), addedAmount, receiptsCount)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -341,93 +405,585 @@ func (bh *BotHandler) SendDriverReportConfirmAddOtherExpense(chatID int64, user
 	bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown)
 }
 
+// addDriverReportOtherExpense добавляет прочий расход с введенной суммой и присланными до нее чеками.
+func (bh *BotHandler) addDriverReportOtherExpense(chatID int64, user models.User, amount float64, botMenuMsgID int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if tempData.TempOtherExpenseDescription == "" {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Ошибка: описание для прочего расхода не найдено. Начните добавление заново.")
+		bh.SendDriverReportOtherExpensesMenu(chatID, user, botMenuMsgID)
+		return
+	}
+	tempData.OtherExpenses = append(tempData.OtherExpenses, models.OtherExpenseDetail{
+		Description:    tempData.TempOtherExpenseDescription,
+		Amount:         amount,
+		ReceiptFileIDs: tempData.TempOtherExpenseReceipts,
+	})
+	addedDesc := tempData.TempOtherExpenseDescription
+	tempData.TempOtherExpenseDescription = ""
+	tempData.TempOtherExpenseReceipts = nil
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendDriverReportConfirmAddOtherExpense(chatID, user, botMenuMsgID, addedDesc, amount)
+}
+
+// isDriverReportReceiptState - шаги инлайн-отчета, на которых принимаются фото чеков.
+func isDriverReportReceiptState(state string) bool {
+	switch state {
+	case constants.STATE_DRIVER_REPORT_INPUT_FUEL,
+		constants.STATE_DRIVER_REPORT_INPUT_OTHER_EXPENSE_AMOUNT,
+		constants.STATE_DRIVER_REPORT_CONFIRM_ADD_OTHER_EXPENSE:
+		return true
+	}
+	return false
+}
+
+// handleDriverReportReceiptPhoto прикрепляет фото чека к расходу текущего шага.
+// Сумма в подписи к фото засчитывается так же, как введенная текстом.
+func (bh *BotHandler) handleDriverReportReceiptPhoto(chatID int64, user models.User, state string, fileID string, caption string, botMenuMsgID int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	captionAmount, errAmount := strconv.ParseFloat(strings.Replace(strings.TrimSpace(caption), ",", ".", -1), 64)
+	hasAmount := caption != "" && errAmount == nil
+
+	addReceipt := func(receipts []string) ([]string, bool) {
+		if len(receipts) >= constants.MAX_RECEIPTS_PER_EXPENSE {
+			return receipts, false
+		}
+		for _, existing := range receipts {
+			if existing == fileID {
+				return receipts, true
+			}
+		}
+		return append(receipts, fileID), true
+	}
+	var added bool
+
+	switch state {
+	case constants.STATE_DRIVER_REPORT_INPUT_FUEL:
+		tempData.FuelReceiptFileIDs, added = addReceipt(tempData.FuelReceiptFileIDs)
+		if hasAmount && captionAmount >= 0 {
+			tempData.FuelExpense = captionAmount
+		}
+		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+		if !added {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("Можно приложить не больше %d чеков к одному расходу.", constants.MAX_RECEIPTS_PER_EXPENSE))
+		}
+		if hasAmount && captionAmount >= 0 {
+			bh.SendDriverReportOverallMenu(chatID, user, botMenuMsgID)
+		} else {
+			bh.SendDriverReportFuelInputPrompt(chatID, user, botMenuMsgID)
+		}
+
+	case constants.STATE_DRIVER_REPORT_INPUT_OTHER_EXPENSE_AMOUNT:
+		tempData.TempOtherExpenseReceipts, added = addReceipt(tempData.TempOtherExpenseReceipts)
+		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+		if !added {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("Можно приложить не больше %d чеков к одному расходу.", constants.MAX_RECEIPTS_PER_EXPENSE))
+		}
+		if hasAmount && captionAmount > 0 {
+			bh.addDriverReportOtherExpense(chatID, user, captionAmount, botMenuMsgID)
+		} else {
+			bh.SendDriverReportOtherExpenseAmountPrompt(chatID, user, botMenuMsgID, tempData.TempOtherExpenseDescription, false, -1)
+		}
+
+	case constants.STATE_DRIVER_REPORT_CONFIRM_ADD_OTHER_EXPENSE:
+		if len(tempData.OtherExpenses) == 0 {
+			bh.SendDriverReportOtherExpensesMenu(chatID, user, botMenuMsgID)
+			return
+		}
+		last := &tempData.OtherExpenses[len(tempData.OtherExpenses)-1]
+		last.ReceiptFileIDs, added = addReceipt(last.ReceiptFileIDs)
+		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+		if !added {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("Можно приложить не больше %d чеков к одному расходу.", constants.MAX_RECEIPTS_PER_EXPENSE))
+		}
+		bh.SendDriverReportConfirmAddOtherExpense(chatID, user, botMenuMsgID, last.Description, last.Amount)
+	}
+	log.Printf("handleDriverReportReceiptPhoto: водитель ChatID=%d приложил чек на шаге %s (добавлен: %v)", chatID, state, added)
+}
+
+// receiptsHint - подсказка о фото чеков с количеством уже приложенных.
+func receiptsHint(count int) string {
+	hint := "📎 Можно прислать фото чека (сумму можно указать в подписи к фото)."
+	if count > 0 {
+		hint += fmt.Sprintf(" Чеков: %d", count)
+	}
+	return hint
+}
+
 // --- КОНЕЦ НОВЫХ ФУНКЦИЙ ДЛЯ ПРОЧИХ РАСХОДОВ ---
 
-// SendDriverReportLoadersSubMenu - меню управления грузчиками (без изменений)
+// driverReportVehicleSummary - машина и пробег для кнопки меню отчета.
+func (bh *BotHandler) driverReportVehicleSummary(vehicleID int64, odometerStart, odometerEnd sql.NullInt64) string {
+	summary := fmt.Sprintf("Машина #%d", vehicleID)
+	if vehicle, err := db.GetVehicleByID(vehicleID); err == nil {
+		summary = vehicle.Plate
+	}
+	if odometerStart.Valid && odometerEnd.Valid {
+		return fmt.Sprintf("%s, пробег %d км", summary, odometerEnd.Int64-odometerStart.Int64)
+	}
+	return summary + ", одометр не введен"
+}
+
+// SendDriverReportVehicleMenu - выбор машины и переход к вводу одометра.
+func (bh *BotHandler) SendDriverReportVehicleMenu(chatID int64, user models.User, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_VEHICLE_MENU)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+
+	vehicles, err := db.GetVehicles(true)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки списка машин.")
+		return
+	}
+
+	text := "🚚 *Машина и пробег*\n\n"
+	if len(vehicles) == 0 {
+		text += "В автопарке пока нет машин. Отчет можно сохранить без машины."
+	} else {
+		text += "Выберите машину, на которой выполняли заказы, и введите показания одометра."
+	}
+	if tempData.VehicleID.Valid {
+		text += "\n\nСейчас: *" + utils.EscapeTelegramMarkdown(bh.driverReportVehicleSummary(tempData.VehicleID.Int64, tempData.OdometerStart, tempData.OdometerEnd)) + "*"
+		if tempData.OdometerStart.Valid && tempData.OdometerEnd.Valid {
+			text += fmt.Sprintf("\nОдометр: %d → %d км", tempData.OdometerStart.Int64, tempData.OdometerEnd.Int64)
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, v := range vehicles {
+		label := "🚚 " + v.DisplayName()
+		if tempData.VehicleID.Valid && tempData.VehicleID.Int64 == v.ID {
+			label = "✅ " + v.DisplayName()
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_VEHICLE, v.ID)),
+		))
+	}
+	if tempData.VehicleID.Valid {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔢 Ввести одометр", constants.CALLBACK_PREFIX_DRIVER_REPORT_ODOMETER),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад к отчету", constants.CALLBACK_PREFIX_DRIVER_REPORT_OVERALL_MENU),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sentMsg, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown)
+	if err == nil && sentMsg.MessageID != 0 {
+		tempData.CurrentMessageID = sentMsg.MessageID
+		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	}
+}
+
+// handleDriverReportPickVehicle выбирает машину; при смене машины начальный пробег берется из ее прошлого отчета.
+func (bh *BotHandler) handleDriverReportPickVehicle(chatID int64, user models.User, vehicleID int64, messageIDToEdit int) {
+	vehicle, err := db.GetVehicleByID(vehicleID)
+	if err != nil || !vehicle.IsActive {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Машина не найдена или выведена из работы.")
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if !tempData.VehicleID.Valid || tempData.VehicleID.Int64 != vehicleID {
+		tempData.VehicleID = sql.NullInt64{Int64: vehicleID, Valid: true}
+		tempData.OdometerStart, _ = db.GetLastOdometerReading(vehicleID)
+		tempData.OdometerEnd = sql.NullInt64{}
+		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	}
+	bh.SendDriverReportOdometerPrompt(chatID, user, messageIDToEdit)
+}
+
+// SendDriverReportOdometerPrompt - запрос показаний одометра.
+func (bh *BotHandler) SendDriverReportOdometerPrompt(chatID int64, user models.User, messageIDToEdit int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if !tempData.VehicleID.Valid {
+		bh.SendDriverReportVehicleMenu(chatID, user, messageIDToEdit)
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_INPUT_ODOMETER)
+	tempData.CurrentMessageID = messageIDToEdit
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := "🔢 Введите показания одометра (км) в начале и в конце смены через пробел.\nПример: `125300 125480`"
+	if tempData.OdometerStart.Valid {
+		text += fmt.Sprintf("\n\nНачальные показания по прошлому отчету: *%d*. Если они верны, достаточно ввести конечные.", tempData.OdometerStart.Int64)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", constants.CALLBACK_PREFIX_DRIVER_REPORT_VEHICLE)),
+	)
+	bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown)
+}
+
+// handleDriverReportOdometerInput разбирает показания одометра: "начало конец" или только "конец".
+func (bh *BotHandler) handleDriverReportOdometerInput(chatID int64, user models.User, text string, botMenuMsgID int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	fields := strings.Fields(strings.NewReplacer(";", " ", ",", " ", "-", " ").Replace(text))
+	var readings []int64
+	for _, field := range fields {
+		value, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || value < 0 {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Показания одометра - целые неотрицательные числа в километрах.")
+			return
+		}
+		readings = append(readings, value)
+	}
+
+	var start, end int64
+	switch {
+	case len(readings) == 2:
+		start, end = readings[0], readings[1]
+	case len(readings) == 1 && tempData.OdometerStart.Valid:
+		start, end = tempData.OdometerStart.Int64, readings[0]
+	default:
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Введите начальные и конечные показания через пробел.")
+		return
+	}
+	if end < start {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Конечные показания не могут быть меньше начальных.")
+		return
+	}
+	if end-start > constants.MAX_ODOMETER_SHIFT_KM {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Пробег %d км за отчет выглядит ошибкой ввода. Проверьте показания.", end-start))
+		return
+	}
+	if lastReading, _ := db.GetLastOdometerReading(tempData.VehicleID.Int64); lastReading.Valid && start < lastReading.Int64 {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Начальные показания меньше последних по этой машине (%d км).", lastReading.Int64))
+		return
+	}
+
+	tempData.OdometerStart = sql.NullInt64{Int64: start, Valid: true}
+	tempData.OdometerEnd = sql.NullInt64{Int64: end, Valid: true}
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	log.Printf("handleDriverReportOdometerInput: водитель ChatID=%d, машина #%d, одометр %d → %d", chatID, tempData.VehicleID.Int64, start, end)
+	bh.SendDriverReportOverallMenu(chatID, user, botMenuMsgID)
+}
+
+// SendDriverReportLoadersSubMenu - меню зарплат грузчиков: суммы по тарифам и отметки о ручной правке.
 func (bh *BotHandler) SendDriverReportLoadersSubMenu(chatID int64, user models.User, messageIDToEdit int) {
 	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_LOADERS_MENU)
 	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 	tempData.CurrentMessageID = messageIDToEdit
 	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 
-	text := "👷‍♂️ *Зарплаты грузчикам по данным заказам:*\n(Укажите ЗП для назначенных грузчиков)\n\n"
+	text := "👷‍♂️ *Зарплаты грузчикам по данным заказам:*\n(Суммы рассчитаны по тарифам. Чтобы изменить сумму, укажите причину)\n\n"
 	var rows [][]tgbotapi.InlineKeyboardButton
 
 	if len(tempData.LoaderPayments) == 0 {
 		text += "_Нет назначенных грузчиков по данным заказам, или они еще не загружены в отчет._\n"
 	} else {
 		for i, loaderPayment := range tempData.LoaderPayments {
-			loaderRowText := fmt.Sprintf("%s: %.0f ₽", utils.EscapeTelegramMarkdown(loaderPayment.LoaderIdentifier), loaderPayment.Amount)
+			text += "• " + formatLoaderPaymentLine(loaderPayment) + "\n"
+			buttonText := fmt.Sprintf("✏️ %s: %.0f ₽", loaderPayment.LoaderIdentifier, loaderPayment.Amount)
+			if loaderPayment.IsOverridden() {
+				buttonText += " ✍️"
+			}
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✏️ %s", loaderRowText), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT, i)),
+				tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT, i)),
 			))
 		}
 	}
-	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к отчету", constants.CALLBACK_PREFIX_DRIVER_REPORT_OVERALL_MENU),
-	))
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить грузчика", constants.CALLBACK_PREFIX_DRIVER_REPORT_ADD_LOADER_PROMPT),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к отчету", constants.CALLBACK_PREFIX_DRIVER_REPORT_OVERALL_MENU),
+		),
+	)
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 	bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown)
 }
 
-// SendDriverReportLoaderNameInputPrompt - запрос имени нового грузчика (без изменений)
+// SendDriverReportLoaderNameInputPrompt - выбор грузчика, не назначенного на заказы.
+// Грузчик выбирается из пользователей с ролью "грузчик"; ввод текста фильтрует список по имени.
 func (bh *BotHandler) SendDriverReportLoaderNameInputPrompt(chatID int64, user models.User, messageIDToEdit int) {
 	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_INPUT_LOADER_NAME)
 	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 	tempData.CurrentMessageID = messageIDToEdit
-	tempData.TempLoaderNameInput = ""
 	tempData.EditingLoaderIndex = -1
+	filter := tempData.TempLoaderNameInput
 	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
 
-	text := "🧑‍🔧 Введите имя или идентификатор нового грузчика (если он не был назначен на заказ):"
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к списку грузчиков", constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADERS_MENU),
-		),
-	)
+	loaders, err := db.GetUsersByRole(constants.ROLE_LOADER)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки списка грузчиков.")
+		return
+	}
+
+	alreadyAdded := make(map[int64]bool)
+	for _, lp := range tempData.LoaderPayments {
+		alreadyAdded[lp.LoaderUserID] = true
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, loader := range loaders {
+		if alreadyAdded[loader.ID] {
+			continue
+		}
+		name := utils.GetUserDisplayName(loader)
+		if filter != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(filter)) {
+			continue
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("👷 "+name, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_PICK_LOADER, loader.ID)),
+		))
+		if len(rows) == 20 {
+			break
+		}
+	}
+
+	text := "🧑‍🔧 Выберите грузчика, который работал на заказах, но не был назначен. Можно отправить часть имени для поиска."
+	if filter != "" {
+		text = fmt.Sprintf("🔎 Грузчики по запросу «%s»:", utils.EscapeTelegramMarkdown(filter))
+	}
+	if len(rows) == 0 {
+		text += "\n\n_Подходящих грузчиков не найдено._"
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к списку грузчиков", constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADERS_MENU),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 	bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown)
 }
 
-// SendDriverReportLoaderSalaryInputPrompt - запрос ЗП для грузчика (без изменений)
-func (bh *BotHandler) SendDriverReportLoaderSalaryInputPrompt(chatID int64, user models.User, messageIDToEdit int, loaderIdentifier string, isEditing bool, loaderIndex int) {
-	var stateToSet string
-	var backCallback string
+// handleDriverReportPickLoader добавляет выбранного грузчика в отчет и открывает его карточку.
+func (bh *BotHandler) handleDriverReportPickLoader(chatID int64, user models.User, messageIDToEdit int, loaderUserID int64) {
+	loader, err := db.GetUserByID(int(loaderUserID))
+	if err != nil || loader.Role != constants.ROLE_LOADER {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "Ошибка: грузчик не найден.")
+		bh.SendDriverReportLoadersSubMenu(chatID, user, messageIDToEdit)
+		return
+	}
 
-	if isEditing {
-		stateToSet = constants.STATE_DRIVER_REPORT_EDIT_LOADER_SALARY
-		backCallback = constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADERS_MENU
-	} else {
-		stateToSet = constants.STATE_DRIVER_REPORT_INPUT_LOADER_SALARY
-		backCallback = constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADERS_MENU
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	for i, lp := range tempData.LoaderPayments {
+		if lp.LoaderUserID == loaderUserID {
+			bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, messageIDToEdit, lp.LoaderIdentifier, true, i)
+			return
+		}
 	}
 
-	bh.Deps.SessionManager.SetState(chatID, stateToSet)
+	categoryCounts := make(map[string]int)
+	for _, order := range tempData.UnsettledOrders {
+		categoryCounts[order.Category]++
+	}
+	// Назначения в executors нет, поэтому считаем, что грузчик работал на одном заказе; объем можно уточнить.
+	detail := models.LoaderPaymentDetail{
+		LoaderUserID:     loaderUserID,
+		LoaderIdentifier: utils.GetUserDisplayName(loader),
+		Category:         dominantCategory(categoryCounts),
+		OrdersCount:      1,
+	}
+	if errRate := db.SuggestLoaderPayment(&detail, tempData.SettlementCreateTime); errRate != nil {
+		log.Printf("handleDriverReportPickLoader: ошибка расчета ЗП грузчика UserID %d по тарифу: %v", loaderUserID, errRate)
+	}
+	tempData.LoaderPayments = append(tempData.LoaderPayments, detail)
+	tempData.TempLoaderNameInput = ""
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, messageIDToEdit, detail.LoaderIdentifier, true, len(tempData.LoaderPayments)-1)
+}
+
+// SendDriverReportLoaderSalaryInputPrompt - карточка грузчика: объем работ, сумма по тарифу и ввод своей суммы с причиной.
+func (bh *BotHandler) SendDriverReportLoaderSalaryInputPrompt(chatID int64, user models.User, messageIDToEdit int, loaderIdentifier string, isEditing bool, loaderIndex int) {
 	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if !isEditing || loaderIndex < 0 || loaderIndex >= len(tempData.LoaderPayments) {
+		// Грузчики добавляются только выбором из списка, поэтому ввод суммы без выбранной строки невозможен.
+		bh.SendDriverReportLoadersSubMenu(chatID, user, messageIDToEdit)
+		return
+	}
+
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_EDIT_LOADER_SALARY)
 	tempData.CurrentMessageID = messageIDToEdit
-	if isEditing {
-		tempData.EditingLoaderIndex = loaderIndex
+	tempData.EditingLoaderIndex = loaderIndex
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	lp := tempData.LoaderPayments[loaderIndex]
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("👷 *%s*\n\n", utils.EscapeTelegramMarkdown(loaderIdentifier)))
+	text.WriteString(fmt.Sprintf("Объем: заказов %d, часов %s, этажей %d, тонн %s\n",
+		lp.OrdersCount, strconv.FormatFloat(lp.Hours, 'f', -1, 64), lp.Floors, strconv.FormatFloat(lp.Tons, 'f', -1, 64)))
+	if lp.RateID != 0 {
+		text.WriteString(fmt.Sprintf("По тарифу #%d: *%.0f ₽*\n", lp.RateID, lp.SuggestedAmount))
 	} else {
-		tempData.EditingLoaderIndex = -1
+		text.WriteString("_Тариф для грузчика не задан, укажите сумму и причину._\n")
 	}
-	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	text.WriteString(fmt.Sprintf("Текущая сумма: *%.0f ₽*\n", lp.Amount))
+	if lp.OverrideReason != "" {
+		text.WriteString(fmt.Sprintf("Причина: _%s_\n", utils.EscapeTelegramMarkdown(lp.OverrideReason)))
+	}
+	text.WriteString("\nЧтобы указать другую сумму, отправьте `сумма; причина`, например `2500; задержались на 2 часа`.")
 
-	currentSalary := 0.0
-	if isEditing && loaderIndex >= 0 && loaderIndex < len(tempData.LoaderPayments) {
-		currentSalary = tempData.LoaderPayments[loaderIndex].Amount
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if lp.RateID != 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ По тарифу: %.0f ₽", lp.SuggestedAmount), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_ACCEPT_LOADER_RATE, loaderIndex)),
+		))
 	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📏 Часы, этажи, тонны", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADER_QUANTITIES, loaderIndex)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🗑️ Убрать из отчета", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_DELETE_LOADER_CONFIRM, loaderIndex)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", constants.CALLBACK_PREFIX_DRIVER_REPORT_LOADERS_MENU),
+		),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text.String(), &keyboard, tgbotapi.ModeMarkdown)
+}
 
-	text := fmt.Sprintf("💸 Введите сумму зарплаты (₽) для грузчика *%s* (текущая: %.0f ₽):",
-		utils.EscapeTelegramMarkdown(loaderIdentifier), currentSalary)
+// SendDriverReportLoaderQuantitiesPrompt - ввод объема работ грузчика для расчета по почасовому, поэтажному и потонному тарифу.
+func (bh *BotHandler) SendDriverReportLoaderQuantitiesPrompt(chatID int64, user models.User, messageIDToEdit int, loaderIndex int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if loaderIndex < 0 || loaderIndex >= len(tempData.LoaderPayments) {
+		bh.SendDriverReportLoadersSubMenu(chatID, user, messageIDToEdit)
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_INPUT_LOADER_QUANTITIES)
+	tempData.CurrentMessageID = messageIDToEdit
+	tempData.EditingLoaderIndex = loaderIndex
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	lp := tempData.LoaderPayments[loaderIndex]
+	text := fmt.Sprintf("📏 Объем работ грузчика *%s*.\n\nОтправьте `часы; этажи; тонны`, например `4; 3; 1,5`. Неизвестное значение укажите как 0.",
+		utils.EscapeTelegramMarkdown(lp.LoaderIdentifier))
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", backCallback),
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_DRIVER_REPORT_EDIT_LOADER_PROMPT, loaderIndex)),
 		),
 	)
 	bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown)
 }
 
+// handleDriverReportAcceptLoaderRate возвращает грузчику сумму по тарифу и снимает причину ручной правки.
+func (bh *BotHandler) handleDriverReportAcceptLoaderRate(chatID int64, user models.User, messageIDToEdit int, loaderIndex int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if loaderIndex < 0 || loaderIndex >= len(tempData.LoaderPayments) {
+		bh.SendDriverReportLoadersSubMenu(chatID, user, messageIDToEdit)
+		return
+	}
+	lp := &tempData.LoaderPayments[loaderIndex]
+	lp.OverrideReason = ""
+	lp.Amount = lp.SuggestedAmount
+	tempData.EditingLoaderIndex = -1
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendDriverReportLoadersSubMenu(chatID, user, messageIDToEdit)
+}
+
+// handleDriverReportLoaderSalaryInput обрабатывает ввод "сумма; причина" для грузчика.
+// Сумма, отличающаяся от тарифной, без причины не принимается.
+func (bh *BotHandler) handleDriverReportLoaderSalaryInput(chatID int64, user models.User, text string, botMenuMsgID int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	loaderIndex := tempData.EditingLoaderIndex
+	if loaderIndex < 0 || loaderIndex >= len(tempData.LoaderPayments) {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Ошибка: не выбран грузчик для редактирования зарплаты.")
+		bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
+		return
+	}
+	lp := &tempData.LoaderPayments[loaderIndex]
+
+	amountPart, reason, _ := strings.Cut(text, ";")
+	reason = strings.TrimSpace(reason)
+	salary, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(amountPart), ",", ".", -1), 64)
+	if err != nil || salary <= 0 {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Сумма зарплаты грузчика должна быть положительным числом. Попробуйте снова.")
+		bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, botMenuMsgID, lp.LoaderIdentifier, true, loaderIndex)
+		return
+	}
+
+	differsFromRate := lp.RateID == 0 || math.Abs(salary-lp.SuggestedAmount) >= 0.01
+	if differsFromRate && len([]rune(reason)) < 3 {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Сумма отличается от тарифа — укажите причину через точку с запятой, например: 2500; задержались на 2 часа.")
+		bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, botMenuMsgID, lp.LoaderIdentifier, true, loaderIndex)
+		return
+	}
+
+	lp.Amount = salary
+	lp.OverrideReason = ""
+	if differsFromRate {
+		lp.OverrideReason = reason
+	}
+	tempData.EditingLoaderIndex = -1
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
+}
+
+// handleDriverReportLoaderQuantitiesInput обрабатывает ввод "часы; этажи; тонны" и пересчитывает сумму по тарифу.
+func (bh *BotHandler) handleDriverReportLoaderQuantitiesInput(chatID int64, user models.User, text string, botMenuMsgID int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	loaderIndex := tempData.EditingLoaderIndex
+	if loaderIndex < 0 || loaderIndex >= len(tempData.LoaderPayments) {
+		bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
+		return
+	}
+
+	fields := strings.Split(text, ";")
+	var values [3]float64
+	for i := 0; i < len(values) && i < len(fields); i++ {
+		value, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(fields[i]), ",", ".", -1), 64)
+		if err != nil || value < 0 {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Неверный формат. Отправьте часы, этажи и тонны через точку с запятой, например: 4; 3; 1,5.")
+			return
+		}
+		values[i] = value
+	}
+
+	lp := &tempData.LoaderPayments[loaderIndex]
+	lp.Hours = values[0]
+	lp.Floors = int(math.Round(values[1]))
+	lp.Tons = values[2]
+	if err := db.SuggestLoaderPayment(lp, tempData.SettlementCreateTime); err != nil {
+		log.Printf("handleDriverReportLoaderQuantitiesInput: ошибка расчета ЗП грузчика UserID %d по тарифу: %v", lp.LoaderUserID, err)
+	}
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, botMenuMsgID, lp.LoaderIdentifier, true, loaderIndex)
+}
+
+// formatLoaderPaymentLine - строка зарплаты грузчика с объемом работ и отметкой о ручной правке.
+func formatLoaderPaymentLine(lp models.LoaderPaymentDetail) string {
+	line := fmt.Sprintf("%s: *%.0f ₽*", utils.EscapeTelegramMarkdown(lp.LoaderIdentifier), lp.Amount)
+	var volume []string
+	if lp.OrdersCount > 0 {
+		volume = append(volume, fmt.Sprintf("заказов %d", lp.OrdersCount))
+	}
+	if lp.Hours > 0 {
+		volume = append(volume, "ч "+strconv.FormatFloat(lp.Hours, 'f', -1, 64))
+	}
+	if lp.Floors > 0 {
+		volume = append(volume, fmt.Sprintf("эт %d", lp.Floors))
+	}
+	if lp.Tons > 0 {
+		volume = append(volume, "т "+strconv.FormatFloat(lp.Tons, 'f', -1, 64))
+	}
+	if len(volume) > 0 {
+		line += " (" + strings.Join(volume, ", ") + ")"
+	}
+	if lp.IsOverridden() || (lp.RateID == 0 && lp.OverrideReason != "") {
+		if lp.RateID != 0 {
+			line += fmt.Sprintf("\n    ✍️ по тарифу %.0f ₽", lp.SuggestedAmount)
+		} else {
+			line += "\n    ✍️ без тарифа"
+		}
+		if lp.OverrideReason != "" {
+			line += ": _" + utils.EscapeTelegramMarkdown(lp.OverrideReason) + "_"
+		}
+	}
+	return line
+}
+
+// dominantCategory возвращает категорию с наибольшим числом заказов (при равенстве - первую по алфавиту).
+func dominantCategory(counts map[string]int) string {
+	best, bestCount := "", 0
+	for category, count := range counts {
+		if count > bestCount || (count == bestCount && category < best) {
+			best, bestCount = category, count
+		}
+	}
+	return best
+}
+
 // SendDriverReportConfirmDeleteLoaderPrompt - запрос подтверждения удаления грузчика (без изменений)
 func (bh *BotHandler) SendDriverReportConfirmDeleteLoaderPrompt(chatID int64, user models.User, messageIDToEdit int, loaderIndex int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_DRIVER_REPORT_CONFIRM_DELETE_LOADER)
 	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
 	tempData.CurrentMessageID = messageIDToEdit
 	tempData.EditingLoaderIndex = loaderIndex
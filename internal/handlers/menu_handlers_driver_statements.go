@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/statements"
+	"Original/internal/utils"
+)
+
+// statementSendToDriver - формат коллбэка stmt_gen: владелец отправляет PDF-выписку водителю в чат.
+const statementSendToDriver = "send"
+
+// statementMaxPeriodDays ограничивает период выписки, чтобы документ оставался читаемым.
+const statementMaxPeriodDays = 366
+
+var statementDateRegexp = regexp.MustCompile(`\d{2}\.\d{2}\.\d{4}|\d{4}-\d{2}-\d{2}`)
+
+// SendOwnerStatementDriversMenu - выбор водителя для выписки.
+func (bh *BotHandler) SendOwnerStatementDriversMenu(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerStatementDriversMenu: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_MANAGEMENT_MENU)
+
+	drivers, err := db.GetUsersByRole(constants.ROLE_DRIVER)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки списка водителей.")
+		return
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, driver := range drivers {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(utils.GetUserDisplayName(driver), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER, driver.ID)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN),
+	))
+
+	text := "📄 Выписка по водителю\n\nВыберите водителя:"
+	if len(drivers) == 0 {
+		text = "📄 Выписка по водителю\n\nВодителей нет."
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, ""); err != nil {
+		log.Printf("SendOwnerStatementDriversMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendStatementPeriodMenu - выбор периода и формата выписки. Водитель видит только свою выписку,
+// владелец - любого водителя и может отправить ее водителю.
+func (bh *BotHandler) SendStatementPeriodMenu(chatID int64, user models.User, driverUserID int64, messageIDToEdit int) {
+	log.Printf("SendStatementPeriodMenu: ChatID=%d, водитель %d", chatID, driverUserID)
+	if !canViewDriverStatement(user, driverUserID) {
+		bh.sendAccessDenied(chatID, messageIDToEdit)
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_MY_SALARY_MENU)
+
+	driver, err := db.GetUserByID(int(driverUserID))
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Водитель не найден.")
+		return
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	presets := []struct {
+		label    string
+		from, to time.Time
+	}{
+		{"Текущий месяц", monthStart, now},
+		{"Прошлый месяц", monthStart.AddDate(0, -1, 0), monthStart.AddDate(0, 0, -1)},
+		{"30 дней", now.AddDate(0, 0, -29), now},
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, preset := range presets {
+		rows = append(rows, bh.statementFormatRow(user, driverUserID, preset.label, preset.from, preset.to))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🗓 Другой период", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_STATEMENT_PERIOD, driverUserID)),
+	))
+	rows = append(rows, statementBackRow(user))
+
+	text := fmt.Sprintf("📄 Выписка: %s\n\nОтчеты, расходы, оплата грузчикам, ЗП, сдача денег в кассу и остаток за период.\nВыберите период и формат:", utils.GetUserDisplayName(driver))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, ""); err != nil {
+		log.Printf("SendStatementPeriodMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendStatementPeriodInputPrompt - запрос произвольного периода выписки.
+func (bh *BotHandler) SendStatementPeriodInputPrompt(chatID int64, user models.User, driverUserID int64, messageIDToEdit int) {
+	if !canViewDriverStatement(user, driverUserID) {
+		bh.sendAccessDenied(chatID, messageIDToEdit)
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.DriverUserIDForBackNav = driverUserID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_STATEMENT_PERIOD_INPUT)
+
+	backCallback := constants.CALLBACK_PREFIX_DRIVER_STATEMENT
+	if user.Role == constants.ROLE_OWNER {
+		backCallback = fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER, driverUserID)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", backCallback)),
+	)
+	text := fmt.Sprintf("🗓 Введите период выписки: две даты через пробел или дефис.\n\nПример: 01.09.2026 - 30.09.2026\n\nПериод - не больше %d дней.", statementMaxPeriodDays)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, ""); err != nil {
+		log.Printf("SendStatementPeriodInputPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleStatementPeriodInput разбирает введенный период и предлагает выбрать формат.
+func (bh *BotHandler) handleStatementPeriodInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	driverUserID := bh.Deps.SessionManager.GetTempDriverSettlement(chatID).DriverUserIDForBackNav
+	if !canViewDriverStatement(user, driverUserID) {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+
+	from, to, err := parseStatementPeriod(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Введите период еще раз, например: 01.09.2026 - 30.09.2026", err))
+		return
+	}
+
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_MY_SALARY_MENU)
+	label := fmt.Sprintf("%s - %s", from.Format("02.01.2006"), to.Format("02.01.2006"))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		bh.statementFormatRow(user, driverUserID, label, from, to),
+		statementBackRow(user),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("📄 Выписка за %s\n\nВыберите формат:", label), &keyboard, ""); err != nil {
+		log.Printf("handleStatementPeriodInput: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleStatementGenerate формирует выписку и отправляет файл запросившему или, для формата send, водителю.
+func (bh *BotHandler) handleStatementGenerate(chatID int64, user models.User, driverUserID int64, fromStr, toStr, format string, messageIDToEdit int) {
+	if !canViewDriverStatement(user, driverUserID) || (format == statementSendToDriver && user.Role != constants.ROLE_OWNER) {
+		bh.sendAccessDenied(chatID, messageIDToEdit)
+		return
+	}
+	from, errFrom := time.ParseInLocation("20060102", fromStr, time.Local)
+	to, errTo := time.ParseInLocation("20060102", toStr, time.Local)
+	if errFrom != nil || errTo != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Некорректный период выписки.")
+		return
+	}
+
+	statement, err := db.GetDriverStatement(driverUserID, from, to)
+	if err != nil {
+		log.Printf("handleStatementGenerate: ошибка сбора выписки водителя %d: %v", driverUserID, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось сформировать выписку.")
+		return
+	}
+
+	fileFormat := format
+	targetChatID := chatID
+	if format == statementSendToDriver {
+		fileFormat = statements.FormatPDF
+		driver, errDriver := db.GetUserByID(int(driverUserID))
+		if errDriver != nil || driver.ChatID == 0 {
+			bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ У водителя нет чата с ботом, отправить выписку нельзя.")
+			return
+		}
+		targetChatID = driver.ChatID
+	}
+
+	content, err := statements.Render(statement, fileFormat)
+	if err != nil {
+		log.Printf("handleStatementGenerate: ошибка формирования файла %s для водителя %d: %v", fileFormat, driverUserID, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось сформировать файл выписки.")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(targetChatID, tgbotapi.FileBytes{Name: statements.FileName(statement, fileFormat), Bytes: content})
+	doc.Caption = fmt.Sprintf("%s\nОстаток на конец периода: %.0f ₽", statements.Title(statement), statement.ClosingBalance)
+	if _, err := bh.Deps.BotClient.Send(doc); err != nil {
+		log.Printf("handleStatementGenerate: ошибка отправки выписки в чат %d: %v", targetChatID, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка при отправке файла выписки.")
+		return
+	}
+	log.Printf("handleStatementGenerate: выписка водителя %d (%s - %s, %s) отправлена в чат %d по запросу %d",
+		driverUserID, fromStr, toStr, fileFormat, targetChatID, user.ID)
+
+	if format == statementSendToDriver {
+		bh.sendInfoMessage(chatID, messageIDToEdit, "✅ Выписка отправлена водителю.", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_STATEMENT_DRIVER, driverUserID))
+	}
+}
+
+// statementFormatRow - строка кнопок форматов выписки за период.
+func (bh *BotHandler) statementFormatRow(user models.User, driverUserID int64, label string, from, to time.Time) []tgbotapi.InlineKeyboardButton {
+	callback := func(format string) string {
+		return fmt.Sprintf("%s_%d_%s_%s_%s", constants.CALLBACK_PREFIX_STATEMENT_GENERATE, driverUserID, from.Format("20060102"), to.Format("20060102"), format)
+	}
+	row := tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📄 "+label, callback(statements.FormatPDF)),
+		tgbotapi.NewInlineKeyboardButtonData("📊 XLSX", callback(statements.FormatXLSX)),
+	)
+	if user.Role == constants.ROLE_OWNER {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("📨 Водителю", callback(statementSendToDriver)))
+	}
+	return row
+}
+
+// statementBackRow - возврат к списку водителей для владельца или в "Мою зарплату" для водителя.
+func statementBackRow(user models.User) []tgbotapi.InlineKeyboardButton {
+	if user.Role == constants.ROLE_OWNER {
+		return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 К водителям", constants.CALLBACK_PREFIX_OWNER_STATEMENTS))
+	}
+	return tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Моя зарплата", constants.CALLBACK_PREFIX_MY_SALARY))
+}
+
+// canViewDriverStatement - выписку видит владелец и сам водитель.
+func canViewDriverStatement(user models.User, driverUserID int64) bool {
+	if driverUserID <= 0 {
+		return false
+	}
+	return user.Role == constants.ROLE_OWNER || (user.Role == constants.ROLE_DRIVER && user.ID == driverUserID)
+}
+
+// parseStatementPeriod извлекает из строки две даты (ДД.ММ.ГГГГ или ГГГГ-ММ-ДД).
+func parseStatementPeriod(text string) (time.Time, time.Time, error) {
+	matches := statementDateRegexp.FindAllString(text, -1)
+	if len(matches) != 2 {
+		return time.Time{}, time.Time{}, fmt.Errorf("нужно указать две даты")
+	}
+	from, err := utils.ValidateDate(matches[0])
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("некорректная дата «%s»", matches[0])
+	}
+	to, err := utils.ValidateDate(matches[1])
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("некорректная дата «%s»", matches[1])
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("дата окончания раньше даты начала")
+	}
+	if to.Sub(from) > statementMaxPeriodDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("период больше %d дней", statementMaxPeriodDays)
+	}
+	return from, to, nil
+}
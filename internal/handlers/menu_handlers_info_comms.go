@@ -244,9 +244,13 @@ func (bh *BotHandler) SendPhoneCallRequestConfirmation(chatID int64, formattedPh
 		log.Printf("SendPhoneCallRequestConfirmation: Ошибка отправки сообщения для удаления ReplyKeyboard: %v", errKb)
 	}
 
+	bonusText := "бонус"
+	if rule, errRule := db.GetActiveReferralRule(); errRule == nil {
+		bonusText = "бонус " + formatReferralBonusAmount(rule)
+	}
 	msgText := fmt.Sprintf(
-		"📞 Спасибо! Мы перезвоним вам на номер %s в ближайшие 5 минут! 😊\n🔥 Пока ждёте, пригласите друга и получите бонус 500 ₽! 🎁",
-		utils.EscapeTelegramMarkdown(formattedPhone))
+		"📞 Спасибо! Мы перезвоним вам на номер %s в ближайшие 5 минут! 😊\n🔥 Пока ждёте, пригласите друга и получите %s! 🎁",
+		utils.EscapeTelegramMarkdown(formattedPhone), bonusText)
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("👥 Пригласить друга", "invite_friend"),
@@ -313,8 +317,11 @@ func (bh *BotHandler) SendInviteFriendMenu(chatID int64, messageIDToEdit int) {
 	log.Printf("BotHandler.SendInviteFriendMenu для chatID %d, messageIDToEdit: %d", chatID, messageIDToEdit)
 	bh.Deps.SessionManager.SetState(chatID, constants.STATE_INVITE_FRIEND)
 
-	msgText := "👥 Приглашайте друзей и получайте 500 ₽ за каждый их заказ от 10 000 ₽!\n\n" +
-		"🔥 Выберите способ поделиться реферальной ссылкой:"
+	msgText := "👥 Приглашайте друзей и получайте бонусы за их заказы!\n\n"
+	if rule, errRule := db.GetActiveReferralRule(); errRule == nil {
+		msgText += formatReferralRuleTerms(rule) + "\n"
+	}
+	msgText += "🔥 Выберите способ поделиться реферальной ссылкой:"
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("📱 Реферальная ссылка", "referral_link"),
@@ -427,6 +434,12 @@ func (bh *BotHandler) SendMyReferralsMenu(chatID int64, messageIDToEdit int) {
 		return
 	}
 
+	// Без условий программы меню все равно показываем: список бонусов от них не зависит
+	rule, errRule := db.GetActiveReferralRule()
+	if errRule != nil {
+		log.Printf("SendMyReferralsMenu: Ошибка получения условий программы: %v", errRule)
+	}
+
 	var msgText string
 	var keyboard tgbotapi.InlineKeyboardMarkup
 	var rows [][]tgbotapi.InlineKeyboardButton
@@ -439,17 +452,27 @@ func (bh *BotHandler) SendMyReferralsMenu(chatID int64, messageIDToEdit int) {
 		totalBonus := 0.0
 		unpaidBonus := 0.0
 		hasUnpaidAndNotRequested := false // Флаг для доступных к запросу бонусов / Flag for bonuses available for request
+		now := time.Now()
 		for _, r := range referrals {
 			dateStr := r.CreatedAt.Format("02.01.2006")
 			statusStr := ""
 			if r.PaidOut {
 				statusStr = " (выплачено)"
+			} else if r.ReviewStatus.String == constants.REFERRAL_REVIEW_STATUS_REJECTED {
+				statusStr = " (отклонен)"
+			} else if r.IsHeld() {
+				statusStr = " (на проверке)"
+			} else if r.IsExpired(now) {
+				statusStr = " (сгорел)"
 			} else {
 				if r.PayoutRequestID.Valid {
 					statusStr = " (в запросе на выплату)"
 				} else {
 					unpaidBonus += r.Amount // Суммируем только те, что не выплачены и не в запросе / Sum only those not paid and not in request
 					hasUnpaidAndNotRequested = true
+					if r.ExpiresAt.Valid {
+						statusStr = " (до " + r.ExpiresAt.Time.Format("02.01") + ")"
+					}
 				}
 			}
 			// Отображаем имя приглашенного (r.Name уже содержит ФИО) / Display invitee's name (r.Name already contains full name)
@@ -457,17 +480,26 @@ func (bh *BotHandler) SendMyReferralsMenu(chatID int64, messageIDToEdit int) {
 			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s (%s) - Бонус: %.0f ₽%s", r.Name, dateStr, r.Amount, statusStr), fmt.Sprintf("referral_details_%d", r.ID)),
 			))
-			totalBonus += r.Amount // Общий заработанный бонус / Total earned bonus
+			if !r.IsExpired(now) && r.ReviewStatus.String != constants.REFERRAL_REVIEW_STATUS_REJECTED {
+				totalBonus += r.Amount // Общий заработанный бонус без сгоревших / Total earned bonus without expired ones
+			}
 		}
 		// POINT 10: Format total and unpaid bonus amounts
 		msgText += fmt.Sprintf("\nОбщий заработанный бонус: *%.0f ₽*", totalBonus)
 		if hasUnpaidAndNotRequested && unpaidBonus > 0 {
 			msgText += fmt.Sprintf("\nК выплате доступно: *%.0f ₽*", unpaidBonus)
-			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💸 Запросить выплату доступных бонусов", "request_referral_payout")))
+			if errRule == nil && unpaidBonus < rule.MinPayoutAmount {
+				msgText += fmt.Sprintf("\nВыплату можно запросить, когда накопится %.0f ₽.", rule.MinPayoutAmount)
+			} else {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💸 Запросить выплату доступных бонусов", "request_referral_payout")))
+			}
 		} else if totalBonus > 0 {
 			msgText += "\nВсе доступные бонусы выплачены или находятся в обработке."
 		}
 	}
+	if errRule == nil {
+		msgText += "\n\n🎁 *Условия программы:*\n" + formatReferralRuleTerms(rule)
+	}
 
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к 'Пригласить друга'", "invite_friend")))
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "back_to_main")))
@@ -488,7 +520,7 @@ func (bh *BotHandler) SendReferralPayoutConfirmation(chatID int64, messageIDToEd
 	bh.Deps.SessionManager.SetState(chatID, constants.STATE_IDLE) // Сбрасываем состояние / Reset state
 
 	// POINT 10: Format amount
-	msgText := fmt.Sprintf("💸 Ваш запрос №%d на выплату реферальных бонусов на сумму %.0f ₽ отправлен администратору!\n\nМы свяжемся с вами для уточнения деталей выплаты. Спасибо!", requestID, amount)
+	msgText := fmt.Sprintf("💸 Ваш запрос №%d на выплату реферальных бонусов на сумму %.0f ₽ отправлен администратору!\n\nДеньги будут переведены по указанным реквизитам - мы сообщим, когда выплата будет проведена. Спасибо!", requestID, amount)
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👥 Мои рефералы", "referral_my")), // Кнопка для возврата к списку рефералов / Button to return to referral list
 		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "back_to_main")),
@@ -562,8 +594,16 @@ func (bh *BotHandler) SendReferralDetails(chatID int64, referralID int64, messag
 	statusText := "Ожидает выплаты"
 	if referral.PaidOut {
 		statusText = "Выплачено"
+	} else if referral.ReviewStatus.String == constants.REFERRAL_REVIEW_STATUS_REJECTED {
+		statusText = "Отклонен при проверке"
+	} else if referral.IsHeld() {
+		statusText = "На проверке"
+	} else if referral.IsExpired(time.Now()) {
+		statusText = "Сгорел"
 	} else if referral.PayoutRequestID.Valid { // Проверяем, есть ли ID запроса / Check if request ID exists
 		statusText = "В запросе на выплату"
+	} else if referral.ExpiresAt.Valid {
+		statusText = "Ожидает выплаты, запросить до " + referral.ExpiresAt.Time.Format("02.01.2006")
 	}
 
 	// POINT 10: Format bonus amount
@@ -580,6 +620,9 @@ func (bh *BotHandler) SendReferralDetails(chatID int64, referralID int64, messag
 		referral.OrderID,
 		utils.EscapeTelegramMarkdown(statusText),
 	)
+	if referral.RuleVersion.Valid {
+		msgText += fmt.Sprintf("\nУсловия программы: версия %d", referral.RuleVersion.Int64)
+	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
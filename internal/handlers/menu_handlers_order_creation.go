@@ -13,6 +13,7 @@ import (
 	"Original/internal/constants" //
 	"Original/internal/db"
 	"Original/internal/models"
+	"Original/internal/payments"
 	"Original/internal/session" //
 	"Original/internal/utils"   //
 )
@@ -172,9 +173,12 @@ func (bh *BotHandler) SendDescriptionInputMenu(chatID int64, messageIDToEdit int
 	} else if tempOrder.Category == constants.CAT_MATERIALS || tempOrder.Category == constants.CAT_OTHER {
 		// Если категория не требует подкатегории, "Назад" ведет к категориям
 		backButtonCallbackData = "back_to_category"
+	} else if lastDetailStep := utils.PrevOrderDetailStep(tempOrder.Order, ""); lastDetailStep != "" {
+		// "Назад" ведет к последнему вопросу об объеме и доступе
+		backButtonCallbackData = "back_to_orddet_" + lastDetailStep
 	}
 
-	msgText := "📝 Опишите детали заказа (например, объем, этаж, наличие лифта, особые пожелания).\nЭто поможет нам точнее рассчитать стоимость и время.\n\nВы можете пропустить этот шаг."
+	msgText := "📝 Опишите детали заказа (например, что именно нужно вывезти, особые пожелания).\nЭто поможет нам точнее рассчитать стоимость и время.\n\nВы можете пропустить этот шаг."
 
 	var rows [][]tgbotapi.InlineKeyboardButton
 	if tempOrder.Description != "" { // Если описание уже есть (например, при редактировании или возврате)
@@ -363,6 +367,8 @@ func (bh *BotHandler) SendDateSelectionMenu(chatID int64, messageIDToEdit int, p
 	backCallback := "back_to_name"
 	if isEditingOrder {
 		backCallback = "back_to_edit_menu_direct"
+	} else if tempOrder.RepeatedFromOrderID != 0 {
+		backCallback = "my_orders_page_0" // При повторе заказа предыдущие шаги пропущены
 	}
 
 	// Последний ряд с кнопками "Назад" и "Главное меню"
@@ -375,6 +381,9 @@ func (bh *BotHandler) SendDateSelectionMenu(chatID int64, messageIDToEdit int, p
 
 	msgText := "📅 Выберите удобную дату для заказа:\n\n" +
 		"🚛 Мы готовы приступить к работе в кратчайшие сроки! 😎"
+	if tempOrder.RepeatedFromOrderID != 0 && !isEditingOrder {
+		msgText = fmt.Sprintf("🔄 Повторяем заказ №%d: адрес, описание, телефон и способ оплаты уже заполнены.\n\n", tempOrder.RepeatedFromOrderID) + msgText
+	}
 
 	_, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, tgbotapi.ModeMarkdown)
 	if err != nil {
@@ -747,7 +756,7 @@ func (bh *BotHandler) SendPhotoInputMenu(chatID int64, messageIDToEdit int) {
 
 	msgTextFormat := "📸 Отправьте нам несколько фото или видео.\n" +
 		"Это поможет нам точнее оценить объем работ."
-	msgText := fmt.Sprintf(msgTextFormat)
+	msgText := msgTextFormat
 
 	history := bh.Deps.SessionManager.GetHistory(chatID)
 	isEditingOrder := false
@@ -813,16 +822,27 @@ func (bh *BotHandler) SendPaymentSelectionMenu(chatID int64, messageIDToEdit int
 		backButtonCallback = "back_to_edit_menu_direct"
 	}
 
-	msgText := "💳 Выберите способ оплаты. При оплате сразу — скидка 5%"
+	msgText := "💳 Выберите способ оплаты."
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить сразу (скидка 5%)", "payment_now")),
-		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💵 Оплатить по выполнению", "payment_later")),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", backButtonCallback),
-			tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main_confirm_cancel_order"),
-		),
-	)
+	// Показываем только способы оплаты, включенные в конфигурации
+	var rows [][]tgbotapi.InlineKeyboardButton
+	hasPrepaid := false
+	for _, provider := range bh.EnabledPaymentProviders() {
+		buttonText := fmt.Sprintf("%s %s", constants.PaymentMethodEmojiMap[provider.Name()], provider.Title())
+		if provider.Prepaid() {
+			buttonText += " (скидка 5%)"
+			hasPrepaid = true
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(buttonText, "payment_"+provider.Name())))
+	}
+	if hasPrepaid {
+		msgText = "💳 Выберите способ оплаты. При онлайн-оплате — скидка 5%"
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", backButtonCallback),
+		tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main_confirm_cancel_order"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 	_, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, tgbotapi.ModeMarkdown)
 	if err != nil {
 		log.Printf("SendPaymentSelectionMenu: Ошибка для chatID %d: %v", chatID, err)
@@ -922,6 +942,9 @@ func (bh *BotHandler) SendOrderConfirmationMenu(chatID int64, messageIDToEdit in
 	} else { // Клиент подтверждает свой заказ
 		bh.Deps.SessionManager.SetState(chatID, constants.STATE_ORDER_CONFIRM)
 		msgText = formatters.FormatOrderConfirmationForUser(tempOrder.Order)
+		if estimate, found := bh.estimateOrderPrice(tempOrder.Order); found && orderStatus == constants.STATUS_DRAFT {
+			msgText += "\n\n" + formatPriceEstimateForClient(estimate)
+		}
 
 		var confirmButtonText, confirmCallbackData string
 		if orderStatus == constants.STATUS_DRAFT {
@@ -1020,6 +1043,7 @@ func (bh *BotHandler) SendEditOrderMenu(chatID int64, messageIDToEdit int) {
 
 	// Обновляем данные в сессии из БД, сохраняя CurrentMessageID
 	tempOrder.Order = orderFromDB
+	tempOrder.EditingDetails = false
 	tempOrder.CurrentMessageID = currentMsgIDFromSession // Восстанавливаем/устанавливаем актуальный ID для редактирования
 
 	// Если CurrentMessageID обновился или еще не был в MediaMessageIDs, добавляем его.
@@ -1046,9 +1070,9 @@ func (bh *BotHandler) SendEditOrderMenu(chatID int64, messageIDToEdit int) {
 	if timeStr == "" {
 		timeStr = "В ближайшее время"
 	}
-	paymentStr := "По выполнению"
-	if tempOrder.Payment == "now" {
-		paymentStr = "Сразу (скидка 5%)"
+	paymentStr := payments.MethodDisplayName(tempOrder.Payment)
+	if payments.IsPrepaidMethod(tempOrder.Payment) {
+		paymentStr += " (скидка 5%)"
 	}
 	formattedDate, _ := utils.FormatDateForDisplay(tempOrder.Date)
 	formattedPhone := utils.FormatPhoneNumber(tempOrder.Phone)
@@ -1056,11 +1080,16 @@ func (bh *BotHandler) SendEditOrderMenu(chatID int64, messageIDToEdit int) {
 
 	lines := []string{
 		fmt.Sprintf("📋 Подкатегория: %s", displaySubcategory),
+	}
+	for _, detailLine := range utils.FormatOrderDetailLines(tempOrder.Order) {
+		lines = append(lines, "📦 "+detailLine)
+	}
+	lines = append(lines,
 		fmt.Sprintf("📝 Описание: %s", utils.EscapeTelegramMarkdown(tempOrder.Description)),
 		fmt.Sprintf("👤 Имя: %s", tempOrder.Name),
 		fmt.Sprintf("📅 Дата: %s", formattedDate), fmt.Sprintf("⏰ Время: %s", timeStr),
 		fmt.Sprintf("📱 Телефон: %s", formattedPhone), fmt.Sprintf("📍 Адрес: %s", tempOrder.Address),
-	}
+	)
 	if len(tempOrder.Photos) > 0 {
 		lines = append(lines, fmt.Sprintf("📸 Фото: %d", len(tempOrder.Photos)))
 	}
@@ -1086,6 +1115,11 @@ func (bh *BotHandler) SendEditOrderMenu(chatID int64, messageIDToEdit int) {
 		tgbotapi.NewInlineKeyboardButtonData("📋 Подкатегория", fmt.Sprintf("edit_field_subcategory_%d", tempOrder.ID)),
 		tgbotapi.NewInlineKeyboardButtonData("📝 Описание", fmt.Sprintf("edit_field_description_%d", tempOrder.ID)),
 	))
+	if utils.NextOrderDetailStep(tempOrder.Order, "") != "" {
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📦 Объем и доступ", fmt.Sprintf("edit_field_details_%d", tempOrder.ID)),
+		))
+	}
 	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("👤 Имя", fmt.Sprintf("edit_field_name_%d", tempOrder.ID)),
 		tgbotapi.NewInlineKeyboardButtonData("📅 Дата", fmt.Sprintf("edit_field_date_%d", tempOrder.ID)),
@@ -1187,12 +1221,21 @@ func (bh *BotHandler) SendCostInputPrompt(chatID int64, orderID int, messageIDTo
 
 	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
 
-	msgText := fmt.Sprintf("💰 Введите стоимость для заказа №%d (в рублях, например, 1500):", orderID)
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", backCallbackKey),
-		),
-	)
+	msgText := fmt.Sprintf("💰 Введите стоимость для заказа №%d (в рублях, например, 1500).\n%s", orderID, constants.CostBreakdownHint)
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	if estimateText, acceptRow := bh.priceEstimateCostPrompt(int64(orderID)); acceptRow != nil {
+		msgText += "\n\n" + estimateText
+		keyboardRows = append(keyboardRows, acceptRow)
+	}
+	if bh.Deps.SessionManager.GetState(chatID) == constants.STATE_COST_INPUT {
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📑 Несколько вариантов", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_QUOTE_NEW, orderID)),
+		))
+	}
+	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", backCallbackKey),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
 	_, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, tgbotapi.ModeMarkdown)
 	if err != nil {
 		log.Printf("SendCostInputPrompt: Ошибка для chatID %d: %v", chatID, err)
@@ -1243,9 +1286,14 @@ func (bh *BotHandler) SendOpOrderCostInputMenu(chatID int64, orderID int64, mess
 	tempOrder.ID = orderID // Убедимся, что ID заказа есть в сессии
 	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
 
-	msgText := fmt.Sprintf("💰 *Установка стоимости*\nВведите стоимость для заказа №%d (например, 1500).\nЭто значение будет показано клиенту (если применимо).", orderID)
+	msgText := fmt.Sprintf("💰 *Установка стоимости*\nВведите стоимость для заказа №%d (например, 1500).\n%s\nЭто значение будет показано клиенту (если применимо).", orderID, constants.CostBreakdownHint)
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+	var keyboardRows [][]tgbotapi.InlineKeyboardButton
+	if estimateText, acceptRow := bh.priceEstimateCostPrompt(orderID); acceptRow != nil {
+		msgText += "\n\n" + estimateText
+		keyboardRows = append(keyboardRows, acceptRow)
+	}
+	keyboardRows = append(keyboardRows,
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("➡️ Пропустить этот шаг", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OP_SKIP_COST, orderID)),
 		),
@@ -1253,6 +1301,7 @@ func (bh *BotHandler) SendOpOrderCostInputMenu(chatID int64, orderID int64, mess
 			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад к опциям создания", fmt.Sprintf("back_to_op_confirm_options_%d", orderID)),
 		),
 	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(keyboardRows...)
 	_, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, tgbotapi.ModeMarkdown)
 	if err != nil {
 		log.Printf("SendOpOrderCostInputMenu: Ошибка для chatID %d: %v", chatID, err)
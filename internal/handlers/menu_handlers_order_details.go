@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+)
+
+// --- Объем и доступ: вопросы после выбора подкатегории ---
+
+// orderDetailStepStates - состояние сессии для каждого вопроса об объеме и доступе.
+var orderDetailStepStates = map[string]string{
+	constants.ORDER_DETAIL_VOLUME:   constants.STATE_ORDER_VOLUME,
+	constants.ORDER_DETAIL_TONNAGE:  constants.STATE_ORDER_TONNAGE,
+	constants.ORDER_DETAIL_FLOOR:    constants.STATE_ORDER_FLOOR,
+	constants.ORDER_DETAIL_ELEVATOR: constants.STATE_ORDER_ELEVATOR,
+	constants.ORDER_DETAIL_LOADERS:  constants.STATE_ORDER_LOADERS,
+}
+
+// orderDetailStepForState - вопрос, который задается в состоянии state ("" - состояние не из этого шага).
+func orderDetailStepForState(state string) string {
+	for step, stepState := range orderDetailStepStates {
+		if stepState == state {
+			return step
+		}
+	}
+	return ""
+}
+
+// StartOrderDetails задает первый вопрос об объеме и доступе для подкатегории заказа.
+// Если для подкатегории вопросов нет, продолжает оформление: к описанию или, при редактировании, в меню редактирования.
+func (bh *BotHandler) StartOrderDetails(chatID int64, messageIDToEdit int) {
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	firstStep := utils.NextOrderDetailStep(tempOrder.Order, "")
+	if firstStep == "" {
+		bh.finishOrderDetails(chatID, messageIDToEdit)
+		return
+	}
+	bh.SendOrderDetailStep(chatID, firstStep, messageIDToEdit)
+}
+
+// finishOrderDetails завершает вопросы об объеме и доступе.
+func (bh *BotHandler) finishOrderDetails(chatID int64, messageIDToEdit int) {
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	if tempOrder.EditingDetails {
+		tempOrder.EditingDetails = false
+		bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+		bh.SendEditOrderMenu(chatID, messageIDToEdit)
+		return
+	}
+	bh.SendDescriptionInputMenu(chatID, messageIDToEdit)
+}
+
+// SendOrderDetailStep показывает вопрос step об объеме и доступе с вариантами быстрого ответа.
+func (bh *BotHandler) SendOrderDetailStep(chatID int64, step string, messageIDToEdit int) {
+	log.Printf("BotHandler.SendOrderDetailStep для chatID %d, шаг: %s, messageIDToEdit: %d", chatID, step, messageIDToEdit)
+	state, ok := orderDetailStepStates[step]
+	if !ok {
+		log.Printf("SendOrderDetailStep: неизвестный шаг '%s' для chatID %d", step, chatID)
+		bh.finishOrderDetails(chatID, messageIDToEdit)
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, state)
+
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	tempOrder.CurrentMessageID = messageIDToEdit
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+
+	callback := func(value string) string {
+		return fmt.Sprintf("%s_%s_%s", constants.CALLBACK_PREFIX_ORDER_DETAIL, step, value)
+	}
+	yesNoRow := func(yesText, noText string) []tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(yesText, callback("yes")),
+			tgbotapi.NewInlineKeyboardButtonData(noText, callback("no")),
+		)
+	}
+
+	var msgText string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	switch step {
+	case constants.ORDER_DETAIL_VOLUME:
+		msgText = "📦 Примерно какой объем мусора?\n\n" +
+			"💡 Выберите вариант или введите число в м³. Для ориентира: кузов «Газели» - около 10 м³, строительный мешок - около 0,05 м³."
+		var optionRow []tgbotapi.InlineKeyboardButton
+		for _, volume := range constants.OrderVolumeOptionsM3 {
+			value := utils.FormatDetailNumber(volume)
+			optionRow = append(optionRow, tgbotapi.NewInlineKeyboardButtonData(value+" м³", callback(value)))
+		}
+		rows = append(rows, optionRow)
+	case constants.ORDER_DETAIL_TONNAGE:
+		msgText = "⚖️ Примерно сколько весит металл?\n\n💡 Выберите вариант или введите число в тоннах."
+		var optionRow []tgbotapi.InlineKeyboardButton
+		for _, tonnage := range constants.OrderTonnageOptionsT {
+			value := utils.FormatDetailNumber(tonnage)
+			optionRow = append(optionRow, tgbotapi.NewInlineKeyboardButtonData(value+" т", callback(value)))
+		}
+		rows = append(rows, optionRow)
+	case constants.ORDER_DETAIL_FLOOR:
+		msgText = "🏢 С какого этажа нужно выносить?\n\n💡 Выберите вариант или введите номер этажа."
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏡 Частный дом / 1 этаж", callback("1")),
+		))
+		var optionRow []tgbotapi.InlineKeyboardButton
+		for _, floor := range constants.OrderFloorOptions[1:] {
+			value := strconv.FormatInt(floor, 10)
+			optionRow = append(optionRow, tgbotapi.NewInlineKeyboardButtonData(value, callback(value)))
+		}
+		rows = append(rows, optionRow)
+	case constants.ORDER_DETAIL_ELEVATOR:
+		msgText = fmt.Sprintf("🛗 Этаж %d. Есть ли грузовой или пассажирский лифт, которым можно пользоваться?", tempOrder.Floor.Int64)
+		rows = append(rows, yesNoRow("✅ Есть лифт", "🚶 Нет лифта"))
+	case constants.ORDER_DETAIL_LOADERS:
+		msgText = "💪 Нужны ли грузчики, чтобы вынести и погрузить мусор?"
+		rows = append(rows, yesNoRow("✅ Да, нужны", "🙅 Нет, погрузим сами"))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🤷 Не знаю / пропустить", callback("skip")),
+	))
+
+	backCallback := "back_to_edit_menu_direct"
+	if !tempOrder.EditingDetails {
+		backCallback = "back_to_subcategory"
+		if prevStep := utils.PrevOrderDetailStep(tempOrder.Order, step); prevStep != "" {
+			backCallback = "back_to_orddet_" + prevStep
+		}
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", backCallback),
+		tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main_confirm_cancel_order"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	_, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, tgbotapi.ModeMarkdown)
+	if err != nil {
+		log.Printf("SendOrderDetailStep: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// applyOrderDetailAnswer записывает ответ value на вопрос step в заказ.
+// value "skip" сбрасывает ответ. Возвращает ошибку с текстом для клиента, если ответ некорректен.
+func applyOrderDetailAnswer(order *models.Order, step, value string) error {
+	skip := value == "skip"
+	switch step {
+	case constants.ORDER_DETAIL_VOLUME:
+		order.VolumeM3.Valid = false
+		if !skip {
+			volume, err := utils.ParseOrderVolume(value)
+			if err != nil {
+				return err
+			}
+			order.VolumeM3.Float64, order.VolumeM3.Valid = volume, true
+		}
+	case constants.ORDER_DETAIL_TONNAGE:
+		order.TonnageT.Valid = false
+		if !skip {
+			tonnage, err := utils.ParseOrderTonnage(value)
+			if err != nil {
+				return err
+			}
+			order.TonnageT.Float64, order.TonnageT.Valid = tonnage, true
+		}
+	case constants.ORDER_DETAIL_FLOOR:
+		order.Floor.Valid = false
+		if !skip {
+			floor, err := utils.ParseOrderFloor(value)
+			if err != nil {
+				return err
+			}
+			order.Floor.Int64, order.Floor.Valid = floor, true
+		}
+		// Лифт имеет смысл только выше первого этажа; при смене этажа вопрос задается заново
+		order.HasElevator.Valid = false
+	case constants.ORDER_DETAIL_ELEVATOR:
+		order.HasElevator.Bool, order.HasElevator.Valid = value == "yes", !skip
+	case constants.ORDER_DETAIL_LOADERS:
+		order.NeedsLoaders.Bool, order.NeedsLoaders.Valid = value == "yes", !skip
+	default:
+		return fmt.Errorf("неизвестный вопрос '%s'", step)
+	}
+	return nil
+}
+
+// handleOrderDetailAnswer принимает ответ на вопрос об объеме и доступе (кнопкой или текстом) и переходит к следующему.
+// При редактировании созданного заказа ответ сразу сохраняется в БД.
+func (bh *BotHandler) handleOrderDetailAnswer(chatID int64, step, value string, messageIDToEdit int) {
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	if err := applyOrderDetailAnswer(&tempOrder.Order, step, value); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ %v. Попробуйте снова.", err))
+		return
+	}
+	if tempOrder.EditingDetails && tempOrder.ID != 0 {
+		if err := db.UpdateOrderDetails(tempOrder.ID, tempOrder.Order); err != nil {
+			bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка сохранения данных заказа.")
+			return
+		}
+	}
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+	log.Printf("handleOrderDetailAnswer: ChatID=%d, шаг '%s', ответ '%s'", chatID, step, value)
+
+	if nextStep := utils.NextOrderDetailStep(tempOrder.Order, step); nextStep != "" {
+		bh.SendOrderDetailStep(chatID, nextStep, messageIDToEdit)
+		return
+	}
+	bh.finishOrderDetails(chatID, messageIDToEdit)
+}
+
+// handleOrderDetailTextInput обрабатывает ответ текстом на вопрос об объеме, весе или этаже.
+func (bh *BotHandler) handleOrderDetailTextInput(chatID int64, state string, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	step := orderDetailStepForState(state)
+	if step == constants.ORDER_DETAIL_ELEVATOR || step == constants.ORDER_DETAIL_LOADERS {
+		bh.sendInfoMessage(chatID, botMenuMsgID, "Пожалуйста, ответьте кнопкой под сообщением.", "")
+		bh.SendOrderDetailStep(chatID, step, botMenuMsgID)
+		return
+	}
+	bh.handleOrderDetailAnswer(chatID, step, text, botMenuMsgID)
+}
+
+// startEditOrderDetails запускает вопросы об объеме и доступе для уже созданного заказа.
+func (bh *BotHandler) startEditOrderDetails(chatID int64, messageIDToEdit int) {
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	if utils.NextOrderDetailStep(tempOrder.Order, "") == "" {
+		bh.sendInfoMessage(chatID, messageIDToEdit, "Для этой подкатегории объем и доступ не уточняются.", "")
+		bh.SendEditOrderMenu(chatID, messageIDToEdit)
+		return
+	}
+	tempOrder.EditingDetails = true
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+	bh.StartOrderDetails(chatID, messageIDToEdit)
+}
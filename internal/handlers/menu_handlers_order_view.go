@@ -207,9 +207,14 @@ func (bh *BotHandler) SendMyOrdersMenu(chatID int64, user models.User, messageID
 				buttonText = buttonText[:57] + "..."
 			}
 
-			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			orderRow := tgbotapi.NewInlineKeyboardRow(
 				tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("%s%d", callbackViewPrefix, orderItem.ID)),
-			))
+			)
+			// Клиент может повторить любой оформленный заказ из своей истории.
+			if callbackViewPrefix == "view_order_" && orderItem.Status != constants.STATUS_DRAFT {
+				orderRow = append(orderRow, tgbotapi.NewInlineKeyboardButtonData("🔄 Повторить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_REPEAT_ORDER, orderItem.ID)))
+			}
+			rows = append(rows, orderRow)
 		}
 
 		navRow := []tgbotapi.InlineKeyboardButton{}
@@ -222,6 +227,13 @@ func (bh *BotHandler) SendMyOrdersMenu(chatID int64, user models.User, messageID
 		if len(navRow) > 0 {
 			rows = append(rows, navRow)
 		}
+		if page == 0 && user.Role == constants.ROLE_USER {
+			if recurring, errRec := db.GetRecurringOrders(chatID); errRec == nil && len(recurring) > 0 {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🔁 Регулярные заказы", constants.CALLBACK_PREFIX_RECURRING_LIST),
+				))
+			}
+		}
 
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "back_to_main"),
@@ -271,6 +283,9 @@ func (bh *BotHandler) SendOrdersMenu(chatID int64, user models.User, messageIDTo
 		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("➕ Создать заказ для клиента", constants.CALLBACK_PREFIX_OP_CREATE_NEW_ORDER), // Обновленный коллбэк
 		))
+		keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 Регулярные заказы", constants.CALLBACK_PREFIX_RECURRING_LIST),
+		))
 	}
 
 	keyboardRows = append(keyboardRows, tgbotapi.NewInlineKeyboardRow(
@@ -543,6 +558,9 @@ func (bh *BotHandler) SendViewOrderDetails(chatID int64, orderID int, messageIDT
 		clientUser, _ := db.GetUserByChatID(order.UserChatID)
 		title := fmt.Sprintf("ℹ️ *Детали Заказа №%d*", order.ID)
 		footer := "Операторский режим просмотра."
+		if quoteSummary := pendingQuoteSummary(order.ID); quoteSummary != "" {
+			footer = quoteSummary + "\n" + footer
+		}
 		msgText = formatters.FormatOrderDetailsForOperator(order, clientUser, assignedExecutors, title, footer)
 	} else {
 		msgText = formatters.FormatOrderDetailsForUser(order, assignedExecutors)
@@ -673,6 +691,16 @@ func (bh *BotHandler) SendViewOrderDetails(chatID int64, orderID int, messageIDT
 			listCallbackKey = "manage_orders"
 		}
 
+		if utils.IsOperatorOrHigher(viewingUser.Role) && order.Status != constants.STATUS_DRAFT && order.Status != constants.STATUS_CANCELED {
+			if recurringID, errRec := db.GetRecurringOrderIDByOrder(order.ID); errRec == nil {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+					fmt.Sprintf("🔁 Регулярный заказ №%d", recurringID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_VIEW, recurringID))))
+			} else {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+					"🔁 Сделать регулярным", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_NEW, order.ID))))
+			}
+		}
+
 		if listDisplayText, textOk := constants.OrderListDisplayMap[listCallbackKey]; textOk {
 			if callbackValue, cbOk := constants.OrderListCallbackMap[listCallbackKey]; cbOk {
 				btnToList = tgbotapi.NewInlineKeyboardButtonData(listDisplayText, callbackValue)
@@ -708,16 +736,30 @@ func (bh *BotHandler) SendViewOrderDetails(chatID int64, orderID int, messageIDT
 				rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✏️ Редактировать мой заказ", fmt.Sprintf("edit_order_%d", order.ID))))
 			}
 			if order.Status == constants.STATUS_AWAITING_CONFIRMATION {
-				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Да, согласен (%.0f ₽)", clientOrderCostForButton), fmt.Sprintf("accept_cost_%d", order.ID)),
-					tgbotapi.NewInlineKeyboardButtonData("❌ Отказаться от стоимости", fmt.Sprintf("reject_cost_%d", order.ID)),
-				))
+				// Если клиенту отправлены варианты, он выбирает один из них вместо согласия с единой стоимостью
+				if quote, errQuote := db.GetPendingOrderQuote(order.ID); errQuote == nil {
+					for _, option := range quote.Options {
+						rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+							fmt.Sprintf("✅ %s (%.0f ₽)", option.Title, option.Cost), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_QUOTE_PICK, option.ID))))
+					}
+					rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData("❌ Не подходит ни один", fmt.Sprintf("reject_cost_%d", order.ID)),
+					))
+				} else {
+					rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+						tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Да, согласен (%.0f ₽)", clientOrderCostForButton), fmt.Sprintf("accept_cost_%d", order.ID)),
+						tgbotapi.NewInlineKeyboardButtonData("❌ Отказаться от стоимости", fmt.Sprintf("reject_cost_%d", order.ID)),
+					))
+				}
 			}
 			if order.Status == constants.STATUS_DRAFT ||
 				(order.Status == constants.STATUS_AWAITING_COST && (!order.Cost.Valid || (order.Cost.Valid && order.Cost.Float64 == 0.0))) ||
 				order.Status == constants.STATUS_AWAITING_CONFIRMATION {
 				rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Отменить мой заказ", fmt.Sprintf("cancel_order_confirm_%d", order.ID))))
 			}
+			if order.Status != constants.STATUS_DRAFT {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔄 Повторить заказ", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_REPEAT_ORDER, order.ID))))
+			}
 		}
 
 		// Кнопка подтверждения уведомления для любого исполнителя
@@ -914,6 +956,54 @@ func (bh *BotHandler) SendAssignExecutorsMenu(chatID int64, orderID int64, messa
 		msgText += "\n"
 	}
 
+	// Подбор машины (только для операторов) с учетом объема и веса груза из заказа.
+	if !isDriverCreatingFlow {
+		suggestions, errVeh := db.GetVehicleSuggestionsForOrder(orderID, order.VolumeM3.Float64, order.TonnageT.Float64)
+		if errVeh != nil {
+			log.Printf("SendAssignExecutorsMenu: ошибка подбора машин для заказа #%d: %v", orderID, errVeh)
+		} else if len(suggestions) > 0 {
+			msgText += "*🚛 Машина:*\n"
+			vehicleAssigned := false
+			for _, sug := range suggestions {
+				label := sug.Vehicle.DisplayName()
+				switch {
+				case sug.IsAssigned:
+					vehicleAssigned = true
+					label = "☑️ " + label
+					msgText += fmt.Sprintf("Назначена: *%s*\n", utils.EscapeTelegramMarkdown(sug.Vehicle.DisplayName()))
+					if len(sug.ComplianceIssues) > 0 {
+						msgText += fmt.Sprintf("⛔ _Не допущена: %s_\n", utils.EscapeTelegramMarkdown(strings.Join(sug.ComplianceIssues, "; ")))
+					}
+				case len(sug.ComplianceIssues) > 0:
+					// Недопущенную машину показываем, чтобы было видно причину; назначение отклонит db.SetOrderVehicle
+					label = "⛔ " + label + " — " + sug.ComplianceIssues[0]
+				default:
+					label = "🚛 " + label
+				}
+				if sug.DriverMatches {
+					label += " 👤"
+				}
+				if !sug.FitsCapacity {
+					label += " 📦 мала"
+				}
+				if len(sug.BusyOrderIDs) > 0 {
+					label += fmt.Sprintf(" ⚠️ занята #%d", sug.BusyOrderIDs[0])
+				}
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s_%d_%d", constants.CALLBACK_PREFIX_ASSIGN_VEHICLE, orderID, sug.Vehicle.ID)),
+				))
+			}
+			if vehicleAssigned {
+				rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("✖️ Снять машину", fmt.Sprintf("%s_%d_0", constants.CALLBACK_PREFIX_ASSIGN_VEHICLE, orderID)),
+				))
+			} else {
+				msgText += "_Машина не назначена. 👤 - закреплена за назначенным водителем._\n"
+			}
+			msgText += "\n"
+		}
+	}
+
 	// Логика для отображения доступных грузчиков (для всех)
 	msgText += "*💪 Доступные грузчики для назначения:*\n"
 	loadersAddedToMenu := 0
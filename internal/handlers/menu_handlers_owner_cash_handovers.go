@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// SendOwnerCashHandoverPrompt - запрос фактически сданной водителем суммы.
+// Показывает несданный остаток водителя с разбивкой по возрасту долга.
+func (bh *BotHandler) SendOwnerCashHandoverPrompt(chatID int64, user models.User, driverUserID int64, messageIDToEdit int) {
+	balance, err := db.GetDriverCashBalance(driverUserID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка получения несданного остатка водителя.")
+		return
+	}
+	driverDisplayName := fmt.Sprintf("Водитель ID %d", driverUserID)
+	if driver, errDriver := db.GetUserByID(int(driverUserID)); errDriver == nil {
+		driverDisplayName = utils.GetUserDisplayName(driver)
+	}
+
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_HANDOVER_INPUT)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.DriverUserIDForBackNav = driverUserID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💵 *Прием денег: %s*\n\n", utils.EscapeTelegramMarkdown(driverDisplayName)))
+	sb.WriteString(formatDriverCashBalance(balance))
+	sb.WriteString("\nОтправьте одной строкой через `;`:\n`сданная сумма; ожидалось; комментарий`\n\n")
+	sb.WriteString("Сумма зачитывается в отчеты от старых к новым. «Ожидалось» можно пропустить или указать `-`; ")
+	sb.WriteString("если оно не совпадает со сданной суммой, расхождение попадет в журнал.\n\n")
+	sb.WriteString(fmt.Sprintf("Пример: `%.0f; %.0f; сдал в офисе`", balance.Outstanding, balance.Outstanding))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("%s_%d_%s_0", constants.CALLBACK_PREFIX_OWNER_VIEW_DRIVER_SETTLEMENTS, driverUserID, constants.VIEW_TYPE_ACTUAL_SETTLEMENTS)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerCashHandoverPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerCashHandoverInput записывает сдачу наличных и отправляет водителю запрос подтверждения.
+func (bh *BotHandler) handleOwnerCashHandoverInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+	amount, expected, comment, err := parseCashHandoverInput(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	driverUserID := bh.Deps.SessionManager.GetTempDriverSettlement(chatID).DriverUserIDForBackNav
+	handover, err := db.RecordCashHandover(driverUserID, amount, expected, user.ID, comment)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось записать сдачу: %v", err))
+		return
+	}
+	log.Printf("handleOwnerCashHandoverInput: владелец %d принял от водителя %d %.2f ₽ (сдача #%d)", user.ID, driverUserID, handover.Amount, handover.ID)
+	bh.NotifyDriverCashHandover(handover)
+	bh.SendOwnerCashHandoversList(chatID, user, driverUserID, 0, botMenuMsgID)
+}
+
+// SendOwnerCashHandoversList - история сдачи наличных водителем с отметками подтверждения.
+func (bh *BotHandler) SendOwnerCashHandoversList(chatID int64, user models.User, driverUserID int64, page int, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_VIEW_DRIVER_SETTLEMENTS)
+	handovers, total, err := db.GetCashHandovers(driverUserID, constants.CashRecordsPerPage, page*constants.CashRecordsPerPage)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки истории сдачи наличных.")
+		return
+	}
+	balance, err := db.GetDriverCashBalance(driverUserID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка получения несданного остатка водителя.")
+		return
+	}
+	driverDisplayName := fmt.Sprintf("Водитель ID %d", driverUserID)
+	if driver, errDriver := db.GetUserByID(int(driverUserID)); errDriver == nil {
+		driverDisplayName = utils.GetUserDisplayName(driver)
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString(fmt.Sprintf("🧾 *История сдачи наличных: %s*\n\n", utils.EscapeTelegramMarkdown(driverDisplayName)))
+	sb.WriteString(formatDriverCashBalance(balance))
+	sb.WriteString("\n")
+	if len(handovers) == 0 {
+		sb.WriteString("Сдач пока не было.")
+	}
+	for _, h := range handovers {
+		sb.WriteString(fmt.Sprintf("*#%d* от %s: *%.0f ₽*", h.ID, h.HandedAt.Format("02.01.06 15:04"), h.Amount))
+		if h.CanceledAt.Valid {
+			sb.WriteString(fmt.Sprintf(" - ↩️ отменена %s\n", h.CanceledAt.Time.Format("02.01.06")))
+			continue
+		}
+		sb.WriteString(" - " + constants.CashAckStatusDisplayMap[h.AckStatus] + "\n")
+		if diff := h.Difference(); math.Abs(diff) >= 0.01 {
+			sb.WriteString(fmt.Sprintf("   Ожидалось %.0f ₽, разница %+.0f ₽\n", h.ExpectedAmount.Float64, diff))
+		}
+		if h.Comment.Valid && h.Comment.String != "" {
+			sb.WriteString("   💬 " + utils.EscapeTelegramMarkdown(h.Comment.String) + "\n")
+		}
+		if h.AckComment.Valid && h.AckComment.String != "" {
+			sb.WriteString("   🗣 Водитель: " + utils.EscapeTelegramMarkdown(h.AckComment.String) + "\n")
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("↩️ Отменить сдачу #%d", h.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_CANCEL, h.ID)),
+		))
+	}
+
+	totalPages := 0
+	if total > 0 {
+		totalPages = int(math.Ceil(float64(total) / float64(constants.CashRecordsPerPage)))
+	}
+	navRow := []tgbotapi.InlineKeyboardButton{}
+	if page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ Пред.", fmt.Sprintf("%s_%d_%d", constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS, driverUserID, page-1)))
+	}
+	if page < totalPages-1 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("След. ➡️", fmt.Sprintf("%s_%d_%d", constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS, driverUserID, page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("💵 Принять деньги", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW, driverUserID))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 К отчетам водителя", fmt.Sprintf("%s_%d_%s_0", constants.CALLBACK_PREFIX_OWNER_VIEW_DRIVER_SETTLEMENTS, driverUserID, constants.VIEW_TYPE_ACTUAL_SETTLEMENTS))),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerCashHandoversList: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerCancelCashHandover отменяет ошибочную запись о сдаче: отчеты снова числятся несданными.
+func (bh *BotHandler) handleOwnerCancelCashHandover(chatID int64, user models.User, handoverID int64, messageIDToEdit int) {
+	handover, err := db.GetCashHandoverByID(handoverID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Сдача #%d не найдена.", handoverID))
+		return
+	}
+	if err := db.CancelCashHandover(handoverID, user.ID); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, periodErrorText(err, fmt.Sprintf("❌ Не удалось отменить сдачу #%d: %v", handoverID, err)))
+		return
+	}
+	log.Printf("handleOwnerCancelCashHandover: владелец %d отменил сдачу #%d", user.ID, handoverID)
+	if driver, errDriver := db.GetUserByID(int(handover.DriverUserID)); errDriver == nil && driver.ChatID != 0 {
+		bh.sendMessage(driver.ChatID, fmt.Sprintf("↩️ Запись о сдаче #%d на %.0f ₽ отменена владельцем. Сумма снова числится к сдаче.", handover.ID, handover.Amount))
+	}
+	bh.SendOwnerCashHandoversList(chatID, user, handover.DriverUserID, 0, messageIDToEdit)
+}
+
+// SendOwnerCashDiscrepancies - журнал незакрытых расхождений по сдаче наличных.
+func (bh *BotHandler) SendOwnerCashDiscrepancies(chatID int64, user models.User, page int, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_MANAGEMENT_MENU)
+	discrepancies, total, err := db.GetCashDiscrepancies(true, constants.CashRecordsPerPage, page*constants.CashRecordsPerPage)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки журнала расхождений.")
+		return
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString("⚠️ *Незакрытые расхождения по сдаче наличных*\n\n")
+	if len(discrepancies) == 0 {
+		sb.WriteString("✅ Расхождений нет.")
+	}
+	for _, d := range discrepancies {
+		sb.WriteString(fmt.Sprintf("*#%d* %s - %s: *%.0f ₽*", d.ID, d.CreatedAt.Format("02.01.06"),
+			constants.CashDiscrepancyKindDisplayMap[d.Kind], d.Amount))
+		sb.WriteString(" - " + utils.EscapeTelegramMarkdown(d.DriverName))
+		if d.HandoverID.Valid {
+			sb.WriteString(fmt.Sprintf(" (сдача #%d)", d.HandoverID.Int64))
+		}
+		sb.WriteString("\n")
+		if d.Note.Valid && d.Note.String != "" {
+			sb.WriteString("   💬 " + utils.EscapeTelegramMarkdown(d.Note.String) + "\n")
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✔️ Закрыть #%d", d.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCY_RESOLVE, d.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("🧾 Сдачи водителя", fmt.Sprintf("%s_%d_0", constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS, d.DriverUserID)),
+		))
+	}
+
+	totalPages := 0
+	if total > 0 {
+		totalPages = int(math.Ceil(float64(total) / float64(constants.CashRecordsPerPage)))
+	}
+	navRow := []tgbotapi.InlineKeyboardButton{}
+	if page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ Пред.", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES, page-1)))
+	}
+	if page < totalPages-1 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("След. ➡️", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES, page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerCashDiscrepancies: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerResolveDiscrepancyPrompt - запрос комментария, с которым закрывается расхождение.
+func (bh *BotHandler) SendOwnerResolveDiscrepancyPrompt(chatID int64, user models.User, discrepancyID int64, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_DISCREPANCY_RESOLVE)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.CashDiscrepancyID = discrepancyID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := fmt.Sprintf("✔️ *Закрытие расхождения #%d*\n\nНапишите, как оно закрыто: например, «водитель доплатил», «ошибка пересчета», «списано».", discrepancyID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("%s_0", constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerResolveDiscrepancyPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerResolveDiscrepancyInput закрывает расхождение с комментарием владельца.
+func (bh *BotHandler) handleOwnerResolveDiscrepancyInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	discrepancy, err := db.ResolveCashDiscrepancy(tempData.CashDiscrepancyID, user.ID, text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось закрыть расхождение: %v", err))
+		return
+	}
+	log.Printf("handleOwnerResolveDiscrepancyInput: владелец %d закрыл расхождение #%d", user.ID, discrepancy.ID)
+	tempData.CashDiscrepancyID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendOwnerCashDiscrepancies(chatID, user, 0, botMenuMsgID)
+}
+
+// formatDriverCashBalance - несданный остаток водителя с разбивкой по возрасту долга.
+func formatDriverCashBalance(balance models.DriverCashBalance) string {
+	if balance.Outstanding < 0.01 {
+		return "✅ Несданных денег нет.\n"
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Не сдано: *%.0f ₽* по %d отч.\n", balance.Outstanding, balance.OpenReports))
+	sb.WriteString(fmt.Sprintf("  до %d дней: %.0f ₽\n", constants.CASH_DEBT_AGE_FRESH_DAYS, balance.Debt0To7Days))
+	sb.WriteString(fmt.Sprintf("  %d–%d дней: %.0f ₽\n", constants.CASH_DEBT_AGE_FRESH_DAYS+1, constants.CASH_DEBT_AGE_LATE_DAYS, balance.Debt8To30Days))
+	sb.WriteString(fmt.Sprintf("  более %d дней: %.0f ₽\n", constants.CASH_DEBT_AGE_LATE_DAYS, balance.DebtOver30Days))
+	if balance.OldestDebtDate.Valid {
+		sb.WriteString(fmt.Sprintf("Самый старый долг: %s\n", balance.OldestDebtDate.Time.Format("02.01.2006")))
+	}
+	if balance.PendingAcks > 0 {
+		sb.WriteString(fmt.Sprintf("⏳ Ждут подтверждения водителя: %d\n", balance.PendingAcks))
+	}
+	if balance.OpenDiscrepancies > 0 {
+		sb.WriteString(fmt.Sprintf("⚠️ Открытых расхождений: %d (%+.0f ₽)\n", balance.OpenDiscrepancies, balance.OpenDiscrepancyDiff))
+	}
+	return sb.String()
+}
+
+// parseCashHandoverInput разбирает строку "сданная сумма; ожидалось; комментарий".
+func parseCashHandoverInput(text string) (amount float64, expected sql.NullFloat64, comment string, err error) {
+	fields := strings.Split(text, ";")
+	amount, err = strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(fields[0]), ",", "."), 64)
+	if err != nil || amount <= 0 {
+		return 0, expected, "", fmt.Errorf("сданная сумма должна быть положительным числом")
+	}
+	if len(fields) > 1 {
+		expectedStr := strings.TrimSpace(fields[1])
+		if expectedStr != "" && expectedStr != "-" {
+			value, errExpected := strconv.ParseFloat(strings.ReplaceAll(expectedStr, ",", "."), 64)
+			if errExpected != nil || value < 0 {
+				return 0, expected, "", fmt.Errorf("ожидаемая сумма должна быть неотрицательным числом")
+			}
+			expected = sql.NullFloat64{Float64: value, Valid: true}
+		}
+	}
+	if len(fields) > 2 {
+		comment = strings.TrimSpace(strings.Join(fields[2:], ";"))
+	}
+	return amount, expected, comment, nil
+}
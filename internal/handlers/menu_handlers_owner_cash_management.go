@@ -29,6 +29,34 @@ func (bh *BotHandler) SendOwnerCashManagementMenu(chatID int64, user models.User
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("✅ Рассчитанные (кто внес/кому выплачено)", fmt.Sprintf("%s_0", constants.CALLBACK_PREFIX_OWNER_CASH_SETTLED_LIST)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚠️ Расхождения по сдаче", fmt.Sprintf("%s_0", constants.CALLBACK_PREFIX_OWNER_CASH_DISCREPANCIES)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💸 Выплаты реферальных бонусов", fmt.Sprintf("%s_%s_0", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS, constants.PAYOUT_REQUEST_STATUS_PENDING)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎁 Условия реферальной программы", constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULES),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📒 Остатки и сверка книги", constants.CALLBACK_PREFIX_OWNER_LEDGER_CHECK),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧮 Правила цены", constants.CALLBACK_PREFIX_OWNER_PRICE_RULES),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔒 Закрытие месяцев", constants.CALLBACK_PREFIX_OWNER_PERIODS),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Выписка по водителю (PDF/XLSX)", constants.CALLBACK_PREFIX_OWNER_STATEMENTS),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⚙️ Ставки водителей", constants.CALLBACK_PREFIX_OWNER_COMP_RULES),
+			tgbotapi.NewInlineKeyboardButtonData("👷 Тарифы грузчиков", constants.CALLBACK_PREFIX_OWNER_LOADER_RATES),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🚚 Автопарк и расход топлива", constants.CALLBACK_PREFIX_OWNER_VEHICLES),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "back_to_main"),
 		),
@@ -43,6 +71,78 @@ func (bh *BotHandler) SendOwnerCashManagementMenu(chatID int64, user models.User
 	}
 }
 
+// SendOwnerLedgerCheck - остатки по счетам главной книги и результат проверки ее целостности.
+func (bh *BotHandler) SendOwnerLedgerCheck(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerLedgerCheck: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_LEDGER_CHECK)
+
+	accounts, err := db.GetLedgerAccountBalances()
+	if err != nil {
+		log.Printf("SendOwnerLedgerCheck: ошибка получения остатков: %v", err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки остатков по счетам.")
+		return
+	}
+	issues, err := db.CheckLedgerIntegrity()
+	if err != nil {
+		log.Printf("SendOwnerLedgerCheck: ошибка проверки целостности: %v", err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка проверки главной книги.")
+		return
+	}
+
+	var companyCash, onlineReceipts, driversCash, staffPayable, referralLiability float64
+	for _, account := range accounts {
+		switch {
+		case account.Code == constants.LEDGER_ACCOUNT_COMPANY_CASH:
+			companyCash = account.Balance
+		case account.Code == constants.LEDGER_ACCOUNT_ONLINE_RECEIPTS:
+			onlineReceipts = account.Balance
+		case strings.HasPrefix(account.Code, constants.LEDGER_ACCOUNT_DRIVER_CASH_PREFIX+":"):
+			driversCash += account.Balance
+		case strings.HasPrefix(account.Code, constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX+":"):
+			staffPayable -= account.Balance
+		case strings.HasPrefix(account.Code, constants.LEDGER_ACCOUNT_REFERRAL_LIABILITY_PREFIX+":"):
+			referralLiability -= account.Balance
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📒 *Главная книга*\n\n")
+	sb.WriteString(fmt.Sprintf("🏦 Касса компании: *%.0f ₽*\n", companyCash))
+	sb.WriteString(fmt.Sprintf("💳 Онлайн-поступления: *%.0f ₽*\n", onlineReceipts))
+	sb.WriteString(fmt.Sprintf("🚚 Наличные у водителей: *%.0f ₽*\n", driversCash))
+	sb.WriteString(fmt.Sprintf("👷 Долг по зарплате: *%.0f ₽*\n", staffPayable))
+	sb.WriteString(fmt.Sprintf("🤝 Долг по реферальным бонусам: *%.0f ₽*\n\n", referralLiability))
+
+	if len(issues) == 0 {
+		sb.WriteString("✅ Проверка целостности пройдена: все проводки сбалансированы и совпадают с отчетами и выплатами.")
+	} else {
+		sb.WriteString(fmt.Sprintf("⚠️ Найдено расхождений: %d\n", len(issues)))
+		for i, issue := range issues {
+			if i == 10 {
+				sb.WriteString(fmt.Sprintf("...и еще %d\n", len(issues)-i))
+				break
+			}
+			line := "• " + utils.EscapeTelegramMarkdown(issue.Description)
+			if issue.Kind == "driver_cash_mismatch" {
+				line += fmt.Sprintf(" (ожидается %.2f, в книге %.2f)", issue.Expected, issue.Actual)
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", constants.CALLBACK_PREFIX_OWNER_LEDGER_CHECK),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerLedgerCheck: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
 // SendOwnerActualDebtsList - отображает АГРЕГИРОВАННЫЙ список актуальных долгов водителей.
 func (bh *BotHandler) SendOwnerActualDebtsList(chatID int64, user models.User, messageIDToEdit int, page int) {
 	log.Printf("SendOwnerActualDebtsList: для владельца ChatID=%d, страница %d, messageIDToEdit %d", chatID, page, messageIDToEdit)
@@ -75,7 +175,8 @@ func (bh *BotHandler) SendOwnerActualDebtsList(chatID int64, user models.User, m
 	} else if len(paginatedAggregatedData) == 0 && page > 0 {
 		msgText = "✅ Больше водителей с актуальными долгами нет."
 	} else {
-		msgText = "❗️ *Актуальные (ожидается внесение в кассу И выплата ЗП водителю):*\n\n"
+		msgText = "❗️ *Актуальные (ожидается внесение в кассу И выплата ЗП водителю):*\n" +
+			"_Сверху - водители с самым старым несданным долгом._\n\n"
 		for _, aggData := range paginatedAggregatedData {
 			driverModelsUser := models.User{
 				FirstName: aggData.DriverFirstName.String,
@@ -84,6 +185,17 @@ func (bh *BotHandler) SendOwnerActualDebtsList(chatID int64, user models.User, m
 				ChatID:    aggData.DriverUserID,
 			}
 			driverName := utils.GetUserDisplayName(driverModelsUser)
+			if aggData.OutstandingAmount >= 0.01 {
+				msgText += fmt.Sprintf("👤 *%s*: не сдано *%.0f ₽* (до %dд: %.0f, %d–%dд: %.0f, >%dд: %.0f)",
+					utils.EscapeTelegramMarkdown(driverName), aggData.OutstandingAmount,
+					constants.CASH_DEBT_AGE_FRESH_DAYS, aggData.Debt0To7Days,
+					constants.CASH_DEBT_AGE_FRESH_DAYS+1, constants.CASH_DEBT_AGE_LATE_DAYS, aggData.Debt8To30Days,
+					constants.CASH_DEBT_AGE_LATE_DAYS, aggData.DebtOver30Days)
+				if aggData.OldestDebtDate.Valid {
+					msgText += fmt.Sprintf(", с %s", aggData.OldestDebtDate.Time.Format("02.01.06"))
+				}
+				msgText += "\n"
+			}
 
 			buttonText := fmt.Sprintf("👤 %s | *%.0f ₽* (%d отч.)",
 				utils.EscapeTelegramMarkdown(driverName),
@@ -267,6 +379,8 @@ func (bh *BotHandler) SendOwnerDriverIndividualSettlementsList(chatID int64, use
 
 			if moneyIn {
 				statusParts = append(statusParts, fmt.Sprintf("Деньги ✅: %s", s.PaidToOwnerAt.Time.Format("02.01.06")))
+			} else if s.HandedOverAmount > 0 {
+				statusParts = append(statusParts, fmt.Sprintf("Деньги ⏳: сдано %.0f из %.0f ₽", s.HandedOverAmount, s.AmountToCashier))
 			} else {
 				statusParts = append(statusParts, "Деньги ❌")
 			}
@@ -358,7 +472,7 @@ func (bh *BotHandler) SendOwnerDriverIndividualSettlementsList(chatID int64, use
 				}
 			}
 			if canDepositAll {
-				allActionsRow = append(allActionsRow, tgbotapi.NewInlineKeyboardButtonData("💰 Деньги внес за все (на стр.)",
+				allActionsRow = append(allActionsRow, tgbotapi.NewInlineKeyboardButtonData("💰 Деньги внес за все",
 					fmt.Sprintf("%s_%d_%s", constants.CALLBACK_PREFIX_OWNER_MARK_ALL_MONEY_DEPOSITED, driverUserID, viewType)))
 			}
 		}
@@ -397,6 +511,15 @@ func (bh *BotHandler) SendOwnerDriverIndividualSettlementsList(chatID int64, use
 		rows = append(rows, navRow)
 	}
 
+	cashRow := []tgbotapi.InlineKeyboardButton{}
+	if viewType == constants.VIEW_TYPE_ACTUAL_SETTLEMENTS {
+		cashRow = append(cashRow, tgbotapi.NewInlineKeyboardButtonData("💵 Принять деньги",
+			fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVER_NEW, driverUserID)))
+	}
+	cashRow = append(cashRow, tgbotapi.NewInlineKeyboardButtonData("🧾 История сдач",
+		fmt.Sprintf("%s_%d_0", constants.CALLBACK_PREFIX_OWNER_CASH_HANDOVERS, driverUserID)))
+	rows = append(rows, cashRow)
+
 	backToListCallback := constants.CALLBACK_PREFIX_OWNER_CASH_ACTUAL_LIST + "_0"
 	if viewType == constants.VIEW_TYPE_SETTLED_SETTLEMENTS {
 		backToListCallback = constants.CALLBACK_PREFIX_OWNER_CASH_SETTLED_LIST + "_0"
@@ -0,0 +1,422 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// SendOwnerCompensationRulesMenu - список действующих правил долей водителей.
+func (bh *BotHandler) SendOwnerCompensationRulesMenu(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerCompensationRulesMenu: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_COMP_RULES)
+
+	rules, err := db.GetCompensationRules(true)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки правил долей водителей.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("⚙️ *Ставки водителей*\n\n")
+	sb.WriteString(fmt.Sprintf("По умолчанию: *%.1f%%* от чистой выручки.\n", bh.Deps.Config.DriverSharePercentage*100))
+	sb.WriteString("Приоритет: водитель и категория > водитель > категория > общее правило. Ставка фиксируется в отчете при его создании.\n\n")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(rules) == 0 {
+		sb.WriteString("Правил нет, для всех отчетов используется ставка по умолчанию.")
+	}
+	for _, rule := range rules {
+		sb.WriteString(fmt.Sprintf("#%d — *%.1f%%*: %s", rule.ID, rule.Share*100, utils.EscapeTelegramMarkdown(formatCompensationRuleScope(rule))))
+		if rule.Comment.Valid && rule.Comment.String != "" {
+			sb.WriteString(fmt.Sprintf("\n    _%s_", utils.EscapeTelegramMarkdown(rule.Comment.String)))
+		}
+		sb.WriteString("\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 Отключить #%d", rule.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_COMP_RULE_DELETE, rule.ID)),
+		))
+	}
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить правило", constants.CALLBACK_PREFIX_OWNER_COMP_RULE_ADD)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN)),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerCompensationRulesMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerCompensationRuleAddPrompt - запрос параметров нового правила одной строкой.
+func (bh *BotHandler) SendOwnerCompensationRuleAddPrompt(chatID int64, user models.User, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_COMP_RULE_INPUT)
+
+	var categories []string
+	for code, name := range constants.CategoryDisplayMap {
+		categories = append(categories, fmt.Sprintf("`%s` (%s)", code, name))
+	}
+	sort.Strings(categories)
+	text := "➕ *Новое правило доли водителя*\n\n" +
+		"Отправьте одной строкой через `;`:\n" +
+		"`процент; ID водителя; категория; с даты; по дату; комментарий`\n\n" +
+		"Вместо ID водителя, категории и даты окончания можно указать `-` — правило будет действовать для всех водителей, всех категорий или бессрочно. Комментарий необязателен.\n\n" +
+		"Категории: " + strings.Join(categories, ", ") + "\n\n" +
+		"Пример: `40; 12; waste_removal; 01.11.2026; -; опытный водитель`"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", constants.CALLBACK_PREFIX_OWNER_COMP_RULES),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerCompensationRuleAddPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerCompensationRuleInput разбирает строку с параметрами правила и сохраняет его.
+func (bh *BotHandler) handleOwnerCompensationRuleInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+
+	rule, err := parseCompensationRuleInput(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	if rule.DriverUserID.Valid {
+		driver, errUser := db.GetUserByID(int(rule.DriverUserID.Int64))
+		if errUser != nil || driver.Role != constants.ROLE_DRIVER {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Водитель с ID %d не найден.", rule.DriverUserID.Int64))
+			return
+		}
+	}
+	rule.CreatedByUserID = sql.NullInt64{Int64: user.ID, Valid: true}
+
+	ruleID, err := db.CreateCompensationRule(rule)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить правило: %v", err))
+		return
+	}
+	log.Printf("handleOwnerCompensationRuleInput: владелец %d добавил правило #%d", user.ID, ruleID)
+	bh.Deps.SessionManager.ClearState(chatID)
+	bh.SendOwnerCompensationRulesMenu(chatID, user, botMenuMsgID)
+}
+
+// handleOwnerDeleteCompensationRule отключает правило и возвращает к списку.
+func (bh *BotHandler) handleOwnerDeleteCompensationRule(chatID int64, user models.User, ruleID int64, messageIDToEdit int) {
+	if err := db.DeactivateCompensationRule(ruleID); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось отключить правило #%d: %v", ruleID, err))
+		return
+	}
+	log.Printf("handleOwnerDeleteCompensationRule: владелец %d отключил правило #%d", user.ID, ruleID)
+	bh.SendOwnerCompensationRulesMenu(chatID, user, messageIDToEdit)
+}
+
+// parseCompensationRuleInput разбирает строку "процент; ID водителя; категория; с даты; по дату; комментарий".
+func parseCompensationRuleInput(text string) (models.CompensationRule, error) {
+	var rule models.CompensationRule
+	fields := strings.Split(text, ";")
+	if len(fields) < 4 {
+		return rule, fmt.Errorf("нужно минимум 4 поля: процент, водитель, категория, дата начала")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	isEmpty := func(s string) bool { return s == "" || s == "-" }
+
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.Replace(fields[0], ",", ".", 1), "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return rule, fmt.Errorf("процент должен быть числом от 0 до 100")
+	}
+	rule.Share = percent / 100
+
+	if !isEmpty(fields[1]) {
+		driverID, errID := strconv.ParseInt(fields[1], 10, 64)
+		if errID != nil || driverID <= 0 {
+			return rule, fmt.Errorf("некорректный ID водителя «%s»", fields[1])
+		}
+		rule.DriverUserID = sql.NullInt64{Int64: driverID, Valid: true}
+	}
+
+	if !isEmpty(fields[2]) {
+		category, ok := resolveCategoryInput(fields[2])
+		if !ok {
+			return rule, fmt.Errorf("неизвестная категория «%s»", fields[2])
+		}
+		rule.Category = sql.NullString{String: category, Valid: true}
+	}
+
+	rule.EffectiveFrom, err = utils.ValidateDate(fields[3])
+	if err != nil {
+		return rule, fmt.Errorf("некорректная дата начала «%s»", fields[3])
+	}
+
+	if len(fields) > 4 && !isEmpty(fields[4]) {
+		effectiveTo, errTo := utils.ValidateDate(fields[4])
+		if errTo != nil {
+			return rule, fmt.Errorf("некорректная дата окончания «%s»", fields[4])
+		}
+		rule.EffectiveTo = sql.NullTime{Time: effectiveTo, Valid: true}
+	}
+
+	if len(fields) > 5 {
+		comment := strings.TrimSpace(strings.Join(fields[5:], ";"))
+		rule.Comment = sql.NullString{String: comment, Valid: comment != ""}
+	}
+	return rule, nil
+}
+
+// resolveCategoryInput принимает код категории или ее название (без учета регистра).
+func resolveCategoryInput(input string) (string, bool) {
+	for code, name := range constants.CategoryDisplayMap {
+		if strings.EqualFold(input, code) || strings.EqualFold(input, name) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// formatCompensationRuleScope - область действия правила: водитель, категория и период.
+func formatCompensationRuleScope(rule models.CompensationRule) string {
+	var parts []string
+	if rule.DriverUserID.Valid {
+		name := rule.DriverName
+		if name == "" {
+			name = fmt.Sprintf("ID %d", rule.DriverUserID.Int64)
+		}
+		parts = append(parts, "водитель "+name)
+	} else {
+		parts = append(parts, "все водители")
+	}
+	if rule.Category.Valid {
+		categoryName, ok := constants.CategoryDisplayMap[rule.Category.String]
+		if !ok {
+			categoryName = rule.Category.String
+		}
+		parts = append(parts, categoryName)
+	} else {
+		parts = append(parts, "все категории")
+	}
+	period := "с " + rule.EffectiveFrom.Format("02.01.2006")
+	if rule.EffectiveTo.Valid {
+		period += " по " + rule.EffectiveTo.Time.Format("02.01.2006")
+	}
+	return strings.Join(append(parts, period), ", ")
+}
+
+// SendOwnerLoaderRatesMenu - список действующих тарифов грузчиков.
+func (bh *BotHandler) SendOwnerLoaderRatesMenu(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerLoaderRatesMenu: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_LOADER_RATES)
+
+	rates, err := db.GetLoaderPayRates(true)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки тарифов грузчиков.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("👷 *Тарифы грузчиков*\n\n")
+	sb.WriteString("Сумма в отчете водителя считается как: за заказ × заказы + за час × часы + за этаж × этажи + за тонну × тонны. Другую сумму водитель может указать только с причиной.\n\n")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(rates) == 0 {
+		sb.WriteString("Тарифов нет, суммы грузчикам водители указывают вручную с причиной.")
+	}
+	for _, rate := range rates {
+		sb.WriteString(fmt.Sprintf("#%d — %s\n    %s", rate.ID, utils.EscapeTelegramMarkdown(formatLoaderRateScope(rate)), formatLoaderRateAmounts(rate)))
+		if rate.Comment.Valid && rate.Comment.String != "" {
+			sb.WriteString(fmt.Sprintf("\n    _%s_", utils.EscapeTelegramMarkdown(rate.Comment.String)))
+		}
+		sb.WriteString("\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 Отключить #%d", rate.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_DELETE, rate.ID)),
+		))
+	}
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить тариф", constants.CALLBACK_PREFIX_OWNER_LOADER_RATE_ADD)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN)),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerLoaderRatesMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerLoaderRateAddPrompt - запрос параметров нового тарифа грузчика одной строкой.
+func (bh *BotHandler) SendOwnerLoaderRateAddPrompt(chatID int64, user models.User, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_LOADER_RATE_INPUT)
+
+	text := "➕ *Новый тариф грузчика*\n\n" +
+		"Отправьте одной строкой через `;`:\n" +
+		"`ID грузчика; категория; за заказ; за час; за этаж; за тонну; с даты; по дату; комментарий`\n\n" +
+		"Вместо ID грузчика, категории и даты окончания можно указать `-`. Неиспользуемые ставки - 0.\n\n" +
+		"Пример: `-; -; 1500; 0; 100; 0; 01.11.2026; -; базовый тариф`"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", constants.CALLBACK_PREFIX_OWNER_LOADER_RATES),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerLoaderRateAddPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerLoaderRateInput разбирает строку с параметрами тарифа и сохраняет его.
+func (bh *BotHandler) handleOwnerLoaderRateInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+
+	rate, err := parseLoaderRateInput(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	if rate.LoaderUserID.Valid {
+		loader, errUser := db.GetUserByID(int(rate.LoaderUserID.Int64))
+		if errUser != nil || loader.Role != constants.ROLE_LOADER {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Грузчик с ID %d не найден.", rate.LoaderUserID.Int64))
+			return
+		}
+	}
+	rate.CreatedByUserID = sql.NullInt64{Int64: user.ID, Valid: true}
+
+	rateID, err := db.CreateLoaderPayRate(rate)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить тариф: %v", err))
+		return
+	}
+	log.Printf("handleOwnerLoaderRateInput: владелец %d добавил тариф грузчика #%d", user.ID, rateID)
+	bh.Deps.SessionManager.ClearState(chatID)
+	bh.SendOwnerLoaderRatesMenu(chatID, user, botMenuMsgID)
+}
+
+// handleOwnerDeleteLoaderRate отключает тариф и возвращает к списку.
+func (bh *BotHandler) handleOwnerDeleteLoaderRate(chatID int64, user models.User, rateID int64, messageIDToEdit int) {
+	if err := db.DeactivateLoaderPayRate(rateID); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось отключить тариф #%d: %v", rateID, err))
+		return
+	}
+	log.Printf("handleOwnerDeleteLoaderRate: владелец %d отключил тариф грузчика #%d", user.ID, rateID)
+	bh.SendOwnerLoaderRatesMenu(chatID, user, messageIDToEdit)
+}
+
+// parseLoaderRateInput разбирает строку "ID грузчика; категория; за заказ; за час; за этаж; за тонну; с даты; по дату; комментарий".
+func parseLoaderRateInput(text string) (models.LoaderPayRate, error) {
+	var rate models.LoaderPayRate
+	fields := strings.Split(text, ";")
+	if len(fields) < 7 {
+		return rate, fmt.Errorf("нужно минимум 7 полей: грузчик, категория, четыре ставки и дата начала")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	isEmpty := func(s string) bool { return s == "" || s == "-" }
+
+	if !isEmpty(fields[0]) {
+		loaderID, errID := strconv.ParseInt(fields[0], 10, 64)
+		if errID != nil || loaderID <= 0 {
+			return rate, fmt.Errorf("некорректный ID грузчика «%s»", fields[0])
+		}
+		rate.LoaderUserID = sql.NullInt64{Int64: loaderID, Valid: true}
+	}
+	if !isEmpty(fields[1]) {
+		category, ok := resolveCategoryInput(fields[1])
+		if !ok {
+			return rate, fmt.Errorf("неизвестная категория «%s»", fields[1])
+		}
+		rate.Category = sql.NullString{String: category, Valid: true}
+	}
+
+	amounts := make([]float64, 4)
+	for i := range amounts {
+		if isEmpty(fields[2+i]) {
+			continue
+		}
+		value, errValue := strconv.ParseFloat(strings.Replace(fields[2+i], ",", ".", 1), 64)
+		if errValue != nil || value < 0 {
+			return rate, fmt.Errorf("ставка «%s» должна быть неотрицательным числом", fields[2+i])
+		}
+		amounts[i] = value
+	}
+	rate.PerOrder, rate.PerHour, rate.PerFloor, rate.PerTon = amounts[0], amounts[1], amounts[2], amounts[3]
+
+	var err error
+	rate.EffectiveFrom, err = utils.ValidateDate(fields[6])
+	if err != nil {
+		return rate, fmt.Errorf("некорректная дата начала «%s»", fields[6])
+	}
+	if len(fields) > 7 && !isEmpty(fields[7]) {
+		effectiveTo, errTo := utils.ValidateDate(fields[7])
+		if errTo != nil {
+			return rate, fmt.Errorf("некорректная дата окончания «%s»", fields[7])
+		}
+		rate.EffectiveTo = sql.NullTime{Time: effectiveTo, Valid: true}
+	}
+	if len(fields) > 8 {
+		comment := strings.TrimSpace(strings.Join(fields[8:], ";"))
+		rate.Comment = sql.NullString{String: comment, Valid: comment != ""}
+	}
+	return rate, nil
+}
+
+// formatLoaderRateScope - область действия тарифа: грузчик, категория и период.
+func formatLoaderRateScope(rate models.LoaderPayRate) string {
+	var parts []string
+	if rate.LoaderUserID.Valid {
+		name := rate.LoaderName
+		if name == "" {
+			name = fmt.Sprintf("ID %d", rate.LoaderUserID.Int64)
+		}
+		parts = append(parts, "грузчик "+name)
+	} else {
+		parts = append(parts, "все грузчики")
+	}
+	if rate.Category.Valid {
+		categoryName, ok := constants.CategoryDisplayMap[rate.Category.String]
+		if !ok {
+			categoryName = rate.Category.String
+		}
+		parts = append(parts, categoryName)
+	} else {
+		parts = append(parts, "все категории")
+	}
+	period := "с " + rate.EffectiveFrom.Format("02.01.2006")
+	if rate.EffectiveTo.Valid {
+		period += " по " + rate.EffectiveTo.Time.Format("02.01.2006")
+	}
+	return strings.Join(append(parts, period), ", ")
+}
+
+// formatLoaderRateAmounts - ненулевые ставки тарифа.
+func formatLoaderRateAmounts(rate models.LoaderPayRate) string {
+	var parts []string
+	if rate.PerOrder > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ₽/заказ", rate.PerOrder))
+	}
+	if rate.PerHour > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ₽/час", rate.PerHour))
+	}
+	if rate.PerFloor > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ₽/этаж", rate.PerFloor))
+	}
+	if rate.PerTon > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ₽/т", rate.PerTon))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -59,6 +59,7 @@ func (bh *BotHandler) handleOwnerEditSettlementStart(chatID int64, user models.U
 	tempSettleData.OriginalPaidToOwnerAt = settlement.PaidToOwnerAt
 
 	tempSettleData.CoveredOrdersRevenue = settlement.CoveredOrdersRevenue
+	tempSettleData.OnlinePaidRevenue = settlement.OnlinePaidRevenue
 	tempSettleData.FuelExpense = settlement.FuelExpense
 	// tempSettleData.OtherExpense = settlement.OtherExpense // УДАЛЕНО, ТАК КАК OtherExpense БОЛЬШЕ НЕТ
 	tempSettleData.OtherExpenses = make([]models.OtherExpenseDetail, len(settlement.OtherExpenses)) // ИЗМЕНЕНО
@@ -286,7 +287,12 @@ func (bh *BotHandler) handleOwnerSaveEditedSettlementFieldInput(chatID int64, us
 				// Если уже были детальные расходы, а владелец вводит общую сумму, это конфликт.
 				// Пока просто перезаписываем первым элементом. Более сложная логика потребует UX решения.
 				log.Printf("ВНИМАНИЕ: Владелец перезаписывает детальные прочие расходы общей суммой для отчета #%d", settlementID)
-				tempData.OtherExpenses = []models.OtherExpenseDetail{{Description: "Прочие расходы (общая сумма, перезаписано)", Amount: val}}
+				// Чеки водителя переносятся в общую строку, чтобы их можно было проверить и после правки.
+				var receipts []string
+				for _, oe := range tempData.OtherExpenses {
+					receipts = append(receipts, oe.ReceiptFileIDs...)
+				}
+				tempData.OtherExpenses = []models.OtherExpenseDetail{{Description: "Прочие расходы (общая сумма, перезаписано)", Amount: val, ReceiptFileIDs: receipts}}
 			}
 		}
 	default:
@@ -320,12 +326,18 @@ func (bh *BotHandler) handleOwnerSaveAllSettlementChanges(chatID int64, user mod
 		DriverUserID:           originalSettlement.DriverUserID,
 		SettlementTimestamp:    tempData.SettlementCreateTime,
 		CoveredOrdersRevenue:   tempData.CoveredOrdersRevenue,
+		OnlinePaidRevenue:      originalSettlement.OnlinePaidRevenue,
 		FuelExpense:            tempData.FuelExpense,
-		OtherExpenses:          tempData.OtherExpenses, // ИСПОЛЬЗУЕМ ОБНОВЛЕННОЕ ПОЛЕ
+		FuelReceiptFileIDs:     originalSettlement.FuelReceiptFileIDs, // Чеки прикладывает водитель, владелец их не меняет
+		OtherExpenses:          tempData.OtherExpenses,                // ИСПОЛЬЗУЕМ ОБНОВЛЕННОЕ ПОЛЕ
 		LoaderPayments:         tempData.LoaderPayments,
 		CoveredOrdersCount:     originalSettlement.CoveredOrdersCount,
 		CoveredOrderIDs:        originalSettlement.CoveredOrderIDs,
 		PaidToOwnerAt:          tempData.OriginalPaidToOwnerAt,
+		DriverShareRate:        originalSettlement.DriverShareRate, // Ставка фиксируется при создании отчета
+		VehicleID:              originalSettlement.VehicleID,
+		OdometerStart:          originalSettlement.OdometerStart,
+		OdometerEnd:            originalSettlement.OdometerEnd,
 		DriverCalculatedSalary: 0,
 		AmountToCashier:        0,
 	}
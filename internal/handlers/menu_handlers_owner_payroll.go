@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/statements"
+	"Original/internal/utils"
+)
+
+// payrollRunsListLimit - сколько последних ведомостей показывать в списке.
+const payrollRunsListLimit = 10
+
+// SendOwnerPayrollRunsMenu - список ведомостей и создание новой за текущий или прошлый месяц.
+func (bh *BotHandler) SendOwnerPayrollRunsMenu(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerPayrollRunsMenu: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_STAFF_PAYOUTS_MENU)
+
+	runs, err := db.GetPayrollRuns(payrollRunsListLimit)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки ведомостей.")
+		return
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	prevMonthStart := monthStart.AddDate(0, -1, 0)
+	newRunCallback := func(from, to time.Time) string {
+		return fmt.Sprintf("%s_%s_%s", constants.CALLBACK_PREFIX_OWNER_PAYROLL_NEW, from.Format("20060102"), to.Format("20060102"))
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, run := range runs {
+		label := fmt.Sprintf("#%d %s - %s · %.0f ₽ · %s", run.ID, run.PeriodFrom.Format("02.01"), run.PeriodTo.Format("02.01.06"),
+			run.TotalAmount, constants.PayrollStatusDisplayMap[run.Status])
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW, run.ID)),
+		))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ За прошлый месяц", newRunCallback(prevMonthStart, monthStart.AddDate(0, 0, -1))),
+			tgbotapi.NewInlineKeyboardButtonData("➕ За текущий месяц", newRunCallback(monthStart, now)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 К выплатам", constants.CALLBACK_PREFIX_OWNER_STAFF_PAYOUT),
+		),
+	)
+
+	text := "🧾 Ведомости на выплату\n\nВедомость собирает долги по ЗП перед водителями и грузчиками на конец периода. " +
+		"Черновик можно поправить, после проведения все выплаты записываются разом и сотрудники получают уведомления."
+	if len(runs) == 0 {
+		text += "\n\nВедомостей пока нет."
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, ""); err != nil {
+		log.Printf("SendOwnerPayrollRunsMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerPayrollNew создает черновик ведомости или открывает уже существующую за тот же период.
+func (bh *BotHandler) handleOwnerPayrollNew(chatID int64, user models.User, fromStr, toStr string, messageIDToEdit int) {
+	from, errFrom := time.ParseInLocation("20060102", fromStr, time.Local)
+	to, errTo := time.ParseInLocation("20060102", toStr, time.Local)
+	if errFrom != nil || errTo != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Некорректный период ведомости.")
+		return
+	}
+	run, existing, err := db.CreatePayrollRun(from, to, user.ID)
+	if err != nil {
+		log.Printf("handleOwnerPayrollNew: ошибка создания ведомости: %v", err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось создать ведомость.")
+		return
+	}
+	if existing {
+		log.Printf("handleOwnerPayrollNew: за период %s - %s уже есть ведомость #%d", fromStr, toStr, run.ID)
+	}
+	bh.SendOwnerPayrollRunView(chatID, user, run.ID, messageIDToEdit)
+}
+
+// SendOwnerPayrollRunView показывает строки ведомости и действия по ее статусу.
+func (bh *BotHandler) SendOwnerPayrollRunView(chatID int64, user models.User, runID int64, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_STAFF_PAYOUTS_MENU)
+	run, err := db.GetPayrollRun(runID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ведомость не найдена.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧾 Ведомость #%d за %s - %s\nСтатус: %s\n\n", run.ID, run.PeriodFrom.Format("02.01.2006"),
+		run.PeriodTo.Format("02.01.2006"), constants.PayrollStatusDisplayMap[run.Status]))
+	if len(run.Lines) == 0 {
+		sb.WriteString("Долгов по ЗП на конец периода нет.\n")
+	}
+	for _, line := range run.Lines {
+		sb.WriteString(fmt.Sprintf("• %s (%s): %.2f ₽", line.UserName, utils.GetRoleDisplayName(line.Role), line.Amount))
+		if line.Amount != line.OwedAmount {
+			sb.WriteString(fmt.Sprintf(" из %.2f", line.OwedAmount))
+		}
+		if !line.HasCard {
+			sb.WriteString(" ⚠️ нет карты")
+		}
+		if line.Comment.Valid {
+			sb.WriteString(fmt.Sprintf("\n   %s", line.Comment.String))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("\nИтого к выплате: %.2f ₽", run.TotalAmount))
+	if run.ConfirmedAt.Valid {
+		sb.WriteString(fmt.Sprintf("\nПроведена: %s", run.ConfirmedAt.Time.Format("02.01.2006 15:04")))
+	}
+	if run.ReversedAt.Valid {
+		sb.WriteString(fmt.Sprintf("\nСторнирована: %s", run.ReversedAt.Time.Format("02.01.2006 15:04")))
+		if run.ReverseReason.Valid {
+			sb.WriteString(fmt.Sprintf(" (%s)", run.ReverseReason.String))
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	switch run.Status {
+	case constants.PAYROLL_STATUS_DRAFT:
+		sb.WriteString("\n\nНажмите на сотрудника, чтобы изменить сумму.")
+		for _, line := range run.Lines {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+				fmt.Sprintf("✏️ %s - %.0f ₽", line.UserName, line.Amount),
+				fmt.Sprintf("%s_%d_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_LINE, run.ID, line.ID),
+			)))
+		}
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("🔄 Пересчитать", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_RECALC, run.ID)),
+				tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_DELETE, run.ID)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ Провести выплаты", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_CONFIRM, run.ID)),
+			),
+		)
+	case constants.PAYROLL_STATUS_CONFIRMED:
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("📄 Реестр CSV", fmt.Sprintf("%s_%d_%s", constants.CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY, run.ID, statements.FormatCSV)),
+				tgbotapi.NewInlineKeyboardButtonData("📊 Реестр XLSX", fmt.Sprintf("%s_%d_%s", constants.CALLBACK_PREFIX_OWNER_PAYROLL_REGISTRY, run.ID, statements.FormatXLSX)),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("↩️ Сторнировать", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_REVERSE, run.ID)),
+			),
+		)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 К ведомостям", constants.CALLBACK_PREFIX_OWNER_PAYROLL),
+	))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, ""); err != nil {
+		log.Printf("SendOwnerPayrollRunView: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerPayrollLinePrompt - запрос новой суммы строки черновика.
+func (bh *BotHandler) SendOwnerPayrollLinePrompt(chatID int64, user models.User, runID, lineID int64, messageIDToEdit int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.PayrollRunID = runID
+	tempData.PayrollLineID = lineID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_PAYROLL_LINE_INPUT)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW, runID))),
+	)
+	text := "✏️ Введите сумму к выплате и, при необходимости, комментарий через «;».\n\nПример: 15000; остаток в следующей ведомости\n\n0 - исключить сотрудника из выплаты."
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, ""); err != nil {
+		log.Printf("SendOwnerPayrollLinePrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerPayrollLineInput сохраняет сумму строки черновика.
+func (bh *BotHandler) handleOwnerPayrollLineInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+
+	amountStr, comment, _ := strings.Cut(text, ";")
+	amount, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(amountStr), ",", "."), 64)
+	if err != nil || amount < 0 {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Некорректная сумма. Введите число, например: 15000")
+		return
+	}
+	if err := db.UpdatePayrollRunLine(tempData.PayrollRunID, tempData.PayrollLineID, amount, strings.TrimSpace(comment)); err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	bh.SendOwnerPayrollRunView(chatID, user, tempData.PayrollRunID, botMenuMsgID)
+}
+
+// handleOwnerPayrollRecalc пересчитывает черновик по текущим долгам.
+func (bh *BotHandler) handleOwnerPayrollRecalc(chatID int64, user models.User, runID int64, messageIDToEdit int) {
+	if err := db.RecalculatePayrollRun(runID); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось пересчитать ведомость: %v", err))
+		return
+	}
+	bh.SendOwnerPayrollRunView(chatID, user, runID, messageIDToEdit)
+}
+
+// handleOwnerPayrollDelete удаляет черновик ведомости.
+func (bh *BotHandler) handleOwnerPayrollDelete(chatID int64, user models.User, runID int64, messageIDToEdit int) {
+	if err := db.DeletePayrollRunDraft(runID); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось удалить ведомость: %v", err))
+		return
+	}
+	bh.SendOwnerPayrollRunsMenu(chatID, user, messageIDToEdit)
+}
+
+// SendOwnerPayrollConfirmPrompt - подтверждение проведения ведомости.
+func (bh *BotHandler) SendOwnerPayrollConfirmPrompt(chatID int64, user models.User, runID int64, messageIDToEdit int) {
+	run, err := db.GetPayrollRun(runID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ведомость не найдена.")
+		return
+	}
+	paidCount := 0
+	for _, line := range run.Lines {
+		if line.Amount > 0 {
+			paidCount++
+		}
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да, провести", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_POST, run.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW, run.ID)),
+		),
+	)
+	text := fmt.Sprintf("Провести ведомость #%d?\n\nБудут записаны выплаты %d сотрудникам на %.2f ₽, каждый получит уведомление.",
+		run.ID, paidCount, run.TotalAmount)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, ""); err != nil {
+		log.Printf("SendOwnerPayrollConfirmPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerPayrollPost проводит ведомость. Повторное нажатие не создает вторых выплат и не дублирует уведомления.
+func (bh *BotHandler) handleOwnerPayrollPost(chatID int64, user models.User, runID int64, messageIDToEdit int) {
+	run, alreadyConfirmed, err := db.ConfirmPayrollRun(runID, user.ID)
+	if err != nil {
+		text := fmt.Sprintf("❌ Не удалось провести ведомость: %v", err)
+		if errors.Is(err, db.ErrPayrollOwedChanged) {
+			text = "❌ После расчета ведомости часть долга уже выплачена отдельно. Пересчитайте ведомость и проверьте суммы."
+		}
+		bh.sendInfoMessage(chatID, messageIDToEdit, text, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW, runID))
+		return
+	}
+	if !alreadyConfirmed {
+		bh.NotifyPayrollRunConfirmed(run)
+	}
+	bh.SendOwnerPayrollRunView(chatID, user, run.ID, messageIDToEdit)
+}
+
+// SendOwnerPayrollReversePrompt - запрос причины сторно ведомости.
+func (bh *BotHandler) SendOwnerPayrollReversePrompt(chatID int64, user models.User, runID int64, messageIDToEdit int) {
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.PayrollRunID = runID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_PAYROLL_REVERSE_INPUT)
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW, runID))),
+	)
+	text := fmt.Sprintf("↩️ Сторно ведомости #%d\n\nВсе ее выплаты будут отменены, долги по ЗП вернутся. Введите причину:", runID)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, ""); err != nil {
+		log.Printf("SendOwnerPayrollReversePrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerPayrollReverseInput сторнирует ведомость с введенной причиной.
+func (bh *BotHandler) handleOwnerPayrollReverseInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	runID := bh.Deps.SessionManager.GetTempDriverSettlement(chatID).PayrollRunID
+	reason := strings.TrimSpace(text)
+	if reason == "" {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Причина не может быть пустой.")
+		return
+	}
+	run, alreadyReversed, err := db.ReversePayrollRun(runID, user.ID, reason)
+	if err != nil {
+		bh.sendInfoMessage(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сторнировать ведомость: %v", err), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PAYROLL_VIEW, runID))
+		return
+	}
+	if !alreadyReversed {
+		bh.NotifyPayrollRunReversed(run)
+	}
+	bh.SendOwnerPayrollRunView(chatID, user, run.ID, botMenuMsgID)
+}
+
+// handleOwnerPayrollRegistry отправляет владельцу реестр на перечисление с номерами карт.
+func (bh *BotHandler) handleOwnerPayrollRegistry(chatID int64, user models.User, runID int64, format string, messageIDToEdit int) {
+	run, registry, err := db.GetPayrollRegistry(runID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось сформировать реестр: %v", err))
+		return
+	}
+	content, err := statements.RenderRegistry(run, registry, format)
+	if err != nil {
+		log.Printf("handleOwnerPayrollRegistry: ошибка формирования реестра #%d (%s): %v", runID, format, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось сформировать файл реестра.")
+		return
+	}
+
+	caption := statements.RegistryTitle(run)
+	missingCards := 0
+	for _, line := range registry {
+		if line.CardError != "" {
+			missingCards++
+		}
+	}
+	if missingCards > 0 {
+		caption += fmt.Sprintf("\n⚠️ Без номера карты: %d", missingCards)
+	}
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: statements.RegistryFileName(run, format), Bytes: content})
+	doc.Caption = caption
+	if _, err := bh.Deps.BotClient.Send(doc); err != nil {
+		log.Printf("handleOwnerPayrollRegistry: ошибка отправки реестра в чат %d: %v", chatID, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка при отправке файла реестра.")
+		return
+	}
+	log.Printf("handleOwnerPayrollRegistry: реестр ведомости #%d (%s) отправлен владельцу %d", runID, format, user.ID)
+}
+
+// NotifyPayrollRunConfirmed уведомляет сотрудников о выплате по проведенной ведомости.
+func (bh *BotHandler) NotifyPayrollRunConfirmed(run models.PayrollRun) {
+	for _, line := range run.Lines {
+		if line.Amount <= 0 {
+			continue
+		}
+		bh.notifyPayrollLineUser(line, fmt.Sprintf("✅ Вам произведена выплата по ведомости за %s - %s: %.2f ₽.",
+			run.PeriodFrom.Format("02.01.2006"), run.PeriodTo.Format("02.01.2006"), line.Amount))
+	}
+}
+
+// NotifyPayrollRunReversed уведомляет сотрудников об отмене выплат по ведомости.
+func (bh *BotHandler) NotifyPayrollRunReversed(run models.PayrollRun) {
+	for _, line := range run.Lines {
+		if !line.PayoutID.Valid {
+			continue
+		}
+		bh.notifyPayrollLineUser(line, fmt.Sprintf("↩️ Выплата по ведомости за %s - %s (%.2f ₽) отменена. Сумма снова числится к выплате.",
+			run.PeriodFrom.Format("02.01.2006"), run.PeriodTo.Format("02.01.2006"), line.Amount))
+	}
+}
+
+func (bh *BotHandler) notifyPayrollLineUser(line models.PayrollRunLine, text string) {
+	staff, err := db.GetUserByID(int(line.UserID))
+	if err != nil || staff.ChatID == 0 {
+		log.Printf("notifyPayrollLineUser: не удалось уведомить userID %d: %v", line.UserID, err)
+		return
+	}
+	bh.sendMessage(staff.ChatID, text)
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// periodErrorText - текст ошибки для пользователя: причина отказа из-за закрытого месяца или общий текст.
+func periodErrorText(err error, fallback string) string {
+	if errors.Is(err, db.ErrFinancialPeriodClosed) {
+		return "🔒 " + err.Error()
+	}
+	return fallback
+}
+
+// parsePeriodMonth разбирает месяц из callback (YYYYMM).
+func parsePeriodMonth(value string) (time.Time, error) {
+	month, err := time.ParseInLocation("200601", value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("неверный месяц %q", value)
+	}
+	return month, nil
+}
+
+// SendOwnerFinancialPeriodsMenu - последние завершенные месяцы с кнопками закрытия/открытия и журнал открытий.
+func (bh *BotHandler) SendOwnerFinancialPeriodsMenu(chatID int64, user models.User, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_FINANCIAL_PERIODS)
+	periods, err := db.GetFinancialPeriods(constants.FinancialPeriodsShownMonths)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки периодов.")
+		return
+	}
+	events, err := db.GetFinancialPeriodEvents(constants.FinancialPeriodEventsShown)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки журнала периодов.")
+		return
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString("🔒 *Закрытие месяцев*\n\n")
+	sb.WriteString("В закрытом месяце нельзя менять отчеты водителей, выплаты, расходы и стоимость заказов. ")
+	sb.WriteString("Чтобы внести исправление, месяц нужно открыть с указанием причины.\n\n")
+	for _, p := range periods {
+		monthKey := p.Month.Format("200601")
+		if p.IsClosed {
+			sb.WriteString(fmt.Sprintf("🔒 *%s* - закрыт", p.Month.Format("01.2006")))
+			if p.ClosedAt.Valid {
+				sb.WriteString(" " + p.ClosedAt.Time.Format("02.01.2006"))
+			}
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔓 Открыть %s", p.Month.Format("01.2006")), fmt.Sprintf("%s_%s", constants.CALLBACK_PREFIX_OWNER_PERIOD_REOPEN, monthKey)),
+			))
+		} else {
+			sb.WriteString(fmt.Sprintf("🔓 *%s* - открыт", p.Month.Format("01.2006")))
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🔒 Закрыть %s", p.Month.Format("01.2006")), fmt.Sprintf("%s_%s", constants.CALLBACK_PREFIX_OWNER_PERIOD_CLOSE, monthKey)),
+			))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(events) > 0 {
+		sb.WriteString("\n📜 *Журнал:*\n")
+		for _, e := range events {
+			action := "закрыт"
+			if e.Action == constants.FINANCIAL_PERIOD_ACTION_REOPEN {
+				action = "открыт"
+			}
+			sb.WriteString(fmt.Sprintf("%s - %s %s", e.CreatedAt.Format("02.01.06 15:04"), e.Month.Format("01.2006"), action))
+			if e.UserName != "" {
+				sb.WriteString(" (" + utils.EscapeTelegramMarkdown(e.UserName) + ")")
+			}
+			if e.Reason.Valid && e.Reason.String != "" {
+				sb.WriteString(": " + utils.EscapeTelegramMarkdown(e.Reason.String))
+			}
+			sb.WriteString("\n")
+		}
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerFinancialPeriodsMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerPeriodClose закрывает завершившийся месяц.
+func (bh *BotHandler) handleOwnerPeriodClose(chatID int64, user models.User, month time.Time, messageIDToEdit int) {
+	if _, err := db.CloseFinancialPeriod(month, user.ID); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось закрыть месяц: %v", err))
+		return
+	}
+	log.Printf("handleOwnerPeriodClose: владелец %d закрыл месяц %s", user.ID, month.Format("01.2006"))
+	bh.SendOwnerFinancialPeriodsMenu(chatID, user, messageIDToEdit)
+}
+
+// SendOwnerPeriodReopenPrompt - запрос причины открытия закрытого месяца.
+func (bh *BotHandler) SendOwnerPeriodReopenPrompt(chatID int64, user models.User, month time.Time, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_PERIOD_REOPEN_REASON)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.PeriodMonth = month
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := fmt.Sprintf("🔓 *Открытие месяца %s*\n\nНапишите, что и зачем нужно исправить. Причина сохранится в журнале. После исправлений не забудьте закрыть месяц снова.", month.Format("01.2006"))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Отмена", constants.CALLBACK_PREFIX_OWNER_PERIODS),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerPeriodReopenPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerPeriodReopenInput открывает месяц с причиной, введенной владельцем.
+func (bh *BotHandler) handleOwnerPeriodReopenInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+	reason := strings.TrimSpace(text)
+	if reason == "" {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Напишите причину открытия месяца.")
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if tempData.PeriodMonth.IsZero() {
+		bh.SendOwnerFinancialPeriodsMenu(chatID, user, botMenuMsgID)
+		return
+	}
+	if _, err := db.ReopenFinancialPeriod(tempData.PeriodMonth, user.ID, reason); err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось открыть месяц: %v", err))
+		return
+	}
+	log.Printf("handleOwnerPeriodReopenInput: владелец %d открыл месяц %s", user.ID, tempData.PeriodMonth.Format("01.2006"))
+	tempData.PeriodMonth = time.Time{}
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendOwnerFinancialPeriodsMenu(chatID, user, botMenuMsgID)
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// SendOwnerPriceRulesMenu - список действующих правил расчета цены заказа.
+func (bh *BotHandler) SendOwnerPriceRulesMenu(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerPriceRulesMenu: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_PRICE_RULES)
+
+	rules, err := db.GetPriceRules(true)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки правил цены.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🧮 *Правила цены заказа*\n\n")
+	sb.WriteString("Цена = база + за м³ сверх включенного объема + за этаж без лифта + за км сверх бесплатных, затем надбавки за срочность и выходной, но не ниже минимальной. ")
+	sb.WriteString("Оператор видит расчет и может принять его или ввести свою цену, клиент - предварительный диапазон. ")
+	sb.WriteString("Исправленное правило заменяет прежнее: старое отключается, новое добавляется с новым номером.\n\n")
+	if bh.Deps.Config.DepotLatitude == 0 && bh.Deps.Config.DepotLongitude == 0 {
+		sb.WriteString("⚠️ Координаты базы не заданы (DEPOT\\_LATITUDE, DEPOT\\_LONGITUDE), надбавка за расстояние не начисляется.\n\n")
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(rules) == 0 {
+		sb.WriteString("Правил нет, стоимость заказов операторы вводят вручную.")
+	}
+	for _, rule := range rules {
+		sb.WriteString(fmt.Sprintf("#%d — %s\n    %s", rule.ID, utils.EscapeTelegramMarkdown(formatPriceRuleScope(rule)), formatPriceRuleAmounts(rule)))
+		if rule.Comment.Valid && rule.Comment.String != "" {
+			sb.WriteString(fmt.Sprintf("\n    _%s_", utils.EscapeTelegramMarkdown(rule.Comment.String)))
+		}
+		sb.WriteString("\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✏️ Изменить #%d", rule.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_EDIT, rule.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 Отключить #%d", rule.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_DELETE, rule.ID)),
+		))
+	}
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить правило", constants.CALLBACK_PREFIX_OWNER_PRICE_RULE_ADD)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN)),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerPriceRulesMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerPriceRuleAddPrompt - запрос параметров нового правила цены одной строкой.
+func (bh *BotHandler) SendOwnerPriceRuleAddPrompt(chatID int64, user models.User, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_PRICE_RULE_INPUT)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.EditingPriceRuleID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := "➕ *Новое правило цены*\n\n" +
+		"Отправьте одной строкой через `;`:\n" +
+		"`категория; подкатегория; база; включено м³; за м³; за этаж; срочно %; выходные %; бесплатно км; за км; минимум; с даты; по дату; комментарий`\n\n" +
+		"Вместо категории, подкатегории и даты окончания можно указать `-`. Неиспользуемые надбавки - 0.\n\n" +
+		"Пример: `waste_removal; construct; 6000; 5; 900; 300; 20; 10; 15; 60; 5000; 01.11.2026; -; строймусор`"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", constants.CALLBACK_PREFIX_OWNER_PRICE_RULES),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerPriceRuleAddPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerPriceRuleEditPrompt - запрос исправленных параметров правила цены. Текущие параметры показываются
+// строкой в том же формате, что и при добавлении; сохранение отключает прежнее правило и добавляет новое.
+func (bh *BotHandler) SendOwnerPriceRuleEditPrompt(chatID int64, user models.User, ruleID int64, messageIDToEdit int) {
+	rule, err := db.GetPriceRuleByID(ruleID)
+	if err != nil || !rule.IsActive {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Действующее правило цены #%d не найдено.", ruleID))
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_PRICE_RULE_INPUT)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.EditingPriceRuleID = ruleID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := fmt.Sprintf("✏️ *Изменение правила цены #%d*\n\n", ruleID) +
+		"Текущие параметры:\n" +
+		"`" + formatPriceRuleInput(rule) + "`\n\n" +
+		"Скопируйте строку, исправьте нужные значения и отправьте ее. Формат:\n" +
+		"`категория; подкатегория; база; включено м³; за м³; за этаж; срочно %; выходные %; бесплатно км; за км; минимум; с даты; по дату; комментарий`\n\n" +
+		fmt.Sprintf("Правило #%d будет отключено, вместо него добавится новое. Уже назначенные цены заказов не меняются.", ruleID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", constants.CALLBACK_PREFIX_OWNER_PRICE_RULES),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerPriceRuleEditPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerPriceRuleInput разбирает строку с параметрами правила цены и сохраняет его
+// (при изменении - заменяет им прежнее правило).
+func (bh *BotHandler) handleOwnerPriceRuleInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+
+	rule, err := parsePriceRuleInput(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	rule.CreatedByUserID = sql.NullInt64{Int64: user.ID, Valid: true}
+
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	var ruleID int64
+	if tempData.EditingPriceRuleID != 0 {
+		ruleID, err = db.ReplacePriceRule(tempData.EditingPriceRuleID, rule)
+	} else {
+		ruleID, err = db.CreatePriceRule(rule)
+	}
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить правило: %v", err))
+		return
+	}
+	if tempData.EditingPriceRuleID != 0 {
+		log.Printf("handleOwnerPriceRuleInput: владелец %d заменил правило цены #%d правилом #%d", user.ID, tempData.EditingPriceRuleID, ruleID)
+	} else {
+		log.Printf("handleOwnerPriceRuleInput: владелец %d добавил правило цены #%d", user.ID, ruleID)
+	}
+	tempData.EditingPriceRuleID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.Deps.SessionManager.ClearState(chatID)
+	bh.SendOwnerPriceRulesMenu(chatID, user, botMenuMsgID)
+}
+
+// handleOwnerDeletePriceRule отключает правило цены и возвращает к списку.
+func (bh *BotHandler) handleOwnerDeletePriceRule(chatID int64, user models.User, ruleID int64, messageIDToEdit int) {
+	if err := db.DeactivatePriceRule(ruleID); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось отключить правило #%d: %v", ruleID, err))
+		return
+	}
+	log.Printf("handleOwnerDeletePriceRule: владелец %d отключил правило цены #%d", user.ID, ruleID)
+	bh.SendOwnerPriceRulesMenu(chatID, user, messageIDToEdit)
+}
+
+// subcategoryNamesForCategory - подкатегории категории заказа (nil, если у категории их нет).
+func subcategoryNamesForCategory(category string) map[string]string {
+	switch category {
+	case constants.CAT_WASTE:
+		return constants.WasteSubcategoryMap
+	case constants.CAT_DEMOLITION:
+		return constants.DemolitionSubcategoryMap
+	}
+	return nil
+}
+
+// resolveSubcategoryInput ищет подкатегорию категории по коду или названию.
+func resolveSubcategoryInput(category, input string) (string, bool) {
+	for code, name := range subcategoryNamesForCategory(category) {
+		if strings.EqualFold(input, code) || strings.EqualFold(input, name) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// parsePriceRuleInput разбирает строку "категория; подкатегория; база; включено м³; за м³; за этаж; срочно %;
+// выходные %; бесплатно км; за км; минимум; с даты; по дату; комментарий".
+func parsePriceRuleInput(text string) (models.PriceRule, error) {
+	var rule models.PriceRule
+	fields := strings.Split(text, ";")
+	if len(fields) < 12 {
+		return rule, fmt.Errorf("нужно минимум 12 полей: категория, подкатегория, девять параметров цены и дата начала")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	isEmpty := func(s string) bool { return s == "" || s == "-" }
+
+	if !isEmpty(fields[0]) {
+		category, ok := resolveCategoryInput(fields[0])
+		if !ok {
+			return rule, fmt.Errorf("неизвестная категория «%s»", fields[0])
+		}
+		rule.Category = sql.NullString{String: category, Valid: true}
+	}
+	if !isEmpty(fields[1]) {
+		if !rule.Category.Valid {
+			return rule, fmt.Errorf("для подкатегории нужно указать категорию")
+		}
+		subcategory, ok := resolveSubcategoryInput(rule.Category.String, fields[1])
+		if !ok {
+			return rule, fmt.Errorf("неизвестная подкатегория «%s»", fields[1])
+		}
+		rule.Subcategory = sql.NullString{String: subcategory, Valid: true}
+	}
+
+	amounts := make([]float64, 9)
+	for i := range amounts {
+		if isEmpty(fields[2+i]) {
+			continue
+		}
+		value, errValue := strconv.ParseFloat(strings.Replace(fields[2+i], ",", ".", 1), 64)
+		if errValue != nil || value < 0 {
+			return rule, fmt.Errorf("значение «%s» должно быть неотрицательным числом", fields[2+i])
+		}
+		amounts[i] = value
+	}
+	rule.BasePrice, rule.IncludedVolumeM3, rule.PerM3, rule.PerFloor = amounts[0], amounts[1], amounts[2], amounts[3]
+	rule.UrgentPercent, rule.WeekendPercent = amounts[4], amounts[5]
+	rule.FreeKm, rule.PerKm, rule.MinPrice = amounts[6], amounts[7], amounts[8]
+
+	var err error
+	rule.EffectiveFrom, err = utils.ValidateDate(fields[11])
+	if err != nil {
+		return rule, fmt.Errorf("некорректная дата начала «%s»", fields[11])
+	}
+	if len(fields) > 12 && !isEmpty(fields[12]) {
+		effectiveTo, errTo := utils.ValidateDate(fields[12])
+		if errTo != nil {
+			return rule, fmt.Errorf("некорректная дата окончания «%s»", fields[12])
+		}
+		rule.EffectiveTo = sql.NullTime{Time: effectiveTo, Valid: true}
+	}
+	if len(fields) > 13 {
+		comment := strings.TrimSpace(strings.Join(fields[13:], ";"))
+		rule.Comment = sql.NullString{String: comment, Valid: comment != ""}
+	}
+	return rule, nil
+}
+
+// formatPriceRuleInput - параметры правила цены строкой в формате ввода (для исправления).
+func formatPriceRuleInput(rule models.PriceRule) string {
+	category, subcategory, effectiveTo := "-", "-", "-"
+	if rule.Category.Valid {
+		category = rule.Category.String
+	}
+	if rule.Subcategory.Valid {
+		subcategory = rule.Subcategory.String
+	}
+	if rule.EffectiveTo.Valid {
+		effectiveTo = rule.EffectiveTo.Time.Format("02.01.2006")
+	}
+	fields := []string{category, subcategory}
+	for _, v := range []float64{rule.BasePrice, rule.IncludedVolumeM3, rule.PerM3, rule.PerFloor, rule.UrgentPercent,
+		rule.WeekendPercent, rule.FreeKm, rule.PerKm, rule.MinPrice} {
+		fields = append(fields, strconv.FormatFloat(v, 'f', -1, 64))
+	}
+	fields = append(fields, rule.EffectiveFrom.Format("02.01.2006"), effectiveTo)
+	if rule.Comment.Valid && rule.Comment.String != "" {
+		fields = append(fields, rule.Comment.String)
+	}
+	return strings.Join(fields, "; ")
+}
+
+// formatPriceRuleScope - область действия правила цены: категория, подкатегория и период.
+func formatPriceRuleScope(rule models.PriceRule) string {
+	var parts []string
+	if rule.Category.Valid {
+		categoryName, ok := constants.CategoryDisplayMap[rule.Category.String]
+		if !ok {
+			categoryName = rule.Category.String
+		}
+		parts = append(parts, categoryName)
+		if rule.Subcategory.Valid {
+			subcategoryName, okSub := subcategoryNamesForCategory(rule.Category.String)[rule.Subcategory.String]
+			if !okSub {
+				subcategoryName = rule.Subcategory.String
+			}
+			parts = append(parts, subcategoryName)
+		}
+	} else {
+		parts = append(parts, "все категории")
+	}
+	period := "с " + rule.EffectiveFrom.Format("02.01.2006")
+	if rule.EffectiveTo.Valid {
+		period += " по " + rule.EffectiveTo.Time.Format("02.01.2006")
+	}
+	return strings.Join(append(parts, period), ", ")
+}
+
+// formatPriceRuleAmounts - ненулевые параметры правила цены.
+func formatPriceRuleAmounts(rule models.PriceRule) string {
+	var parts []string
+	if rule.BasePrice > 0 {
+		parts = append(parts, fmt.Sprintf("база %.0f ₽", rule.BasePrice))
+	}
+	if rule.PerM3 > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ₽/м³ сверх %.1f м³", rule.PerM3, rule.IncludedVolumeM3))
+	}
+	if rule.PerFloor > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ₽/этаж без лифта", rule.PerFloor))
+	}
+	if rule.PerKm > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f ₽/км сверх %.0f км", rule.PerKm, rule.FreeKm))
+	}
+	if rule.UrgentPercent > 0 {
+		parts = append(parts, fmt.Sprintf("срочно +%.0f%%", rule.UrgentPercent))
+	}
+	if rule.WeekendPercent > 0 {
+		parts = append(parts, fmt.Sprintf("выходные +%.0f%%", rule.WeekendPercent))
+	}
+	if rule.MinPrice > 0 {
+		parts = append(parts, fmt.Sprintf("минимум %.0f ₽", rule.MinPrice))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// estimateOrderPrice - расчетная цена заказа с координатами базы из конфигурации.
+func (bh *BotHandler) estimateOrderPrice(order models.Order) (models.PriceEstimate, bool) {
+	estimate, found, err := db.EstimateOrderPrice(order, bh.Deps.Config.DepotLatitude, bh.Deps.Config.DepotLongitude)
+	if err != nil {
+		return estimate, false
+	}
+	return estimate, found
+}
+
+// formatPriceEstimateForOperator - расчетная цена с расшифровкой для оператора.
+func formatPriceEstimateForOperator(estimate models.PriceEstimate) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🧮 *Расчет по тарифу: %.0f ₽*\n", estimate.Total))
+	for _, line := range estimate.Lines {
+		sb.WriteString(fmt.Sprintf("  • %s: %.0f ₽\n", line.Label, line.Amount))
+	}
+	return sb.String()
+}
+
+// formatPriceEstimateForClient - предварительный диапазон цены для клиента.
+func formatPriceEstimateForClient(estimate models.PriceEstimate) string {
+	return fmt.Sprintf("🧮 *Предварительная стоимость:* от %.0f до %.0f ₽. Точную стоимость назовет оператор после уточнения деталей.",
+		estimate.RangeMin, estimate.RangeMax)
+}
+
+// handlePriceEstimateAccept устанавливает заказу расчетную цену так же, как если бы оператор ввел ее вручную.
+// Цена применяется к заказу из кнопки, и только если ввод стоимости открыт именно для него: иначе кнопка
+// со старого экрана установила бы цену другому заказу.
+func (bh *BotHandler) handlePriceEstimateAccept(chatID int64, user models.User, orderID int64, messageIDToEdit int) {
+	state := bh.Deps.SessionManager.GetState(chatID)
+	if (state != constants.STATE_COST_INPUT && state != constants.STATE_OP_ORDER_COST_INPUT) ||
+		bh.Deps.SessionManager.GetTempOrder(chatID).ID != orderID {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Этот расчет устарел. Откройте ввод стоимости заново.")
+		return
+	}
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Заказ №%d не найден.", orderID))
+		return
+	}
+	estimate, found := bh.estimateOrderPrice(order)
+	if !found || estimate.Total <= 0 {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Для заказа нет правила цены. Введите стоимость вручную.")
+		return
+	}
+	costText := strconv.FormatFloat(estimate.Total, 'f', 0, 64)
+	log.Printf("handlePriceEstimateAccept: пользователь %d принял расчетную цену %s ₽ (правило #%d) для заказа #%d", user.ID, costText, estimate.RuleID, orderID)
+
+	if state == constants.STATE_COST_INPUT {
+		bh.handleCostInput(chatID, user, costText, 0, messageIDToEdit)
+	} else {
+		bh.handleOpOrderCostInput(chatID, user, costText, messageIDToEdit)
+	}
+}
+
+// priceEstimateCostPrompt - расчет по тарифу и кнопка "Принять расчет" для экрана ввода стоимости.
+// Возвращает пустой текст и nil, если для заказа нет правила цены.
+func (bh *BotHandler) priceEstimateCostPrompt(orderID int64) (string, []tgbotapi.InlineKeyboardButton) {
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		return "", nil
+	}
+	estimate, found := bh.estimateOrderPrice(order)
+	if !found || estimate.Total <= 0 {
+		return "", nil
+	}
+	return formatPriceEstimateForOperator(estimate), tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Принять расчет: %.0f ₽", estimate.Total), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_PRICE_ESTIMATE_ACCEPT, orderID)),
+	)
+}
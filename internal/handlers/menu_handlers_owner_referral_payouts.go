@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// SendOwnerReferralPayoutsList - очередь запросов на выплату реферальных бонусов с фильтром по статусу.
+func (bh *BotHandler) SendOwnerReferralPayoutsList(chatID int64, user models.User, status string, page int, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_MANAGEMENT_MENU)
+	if _, ok := constants.PayoutRequestStatusDisplayMap[status]; !ok {
+		status = constants.PAYOUT_REQUEST_STATUS_PENDING
+	}
+	requests, total, err := db.GetReferralPayoutRequests(status, constants.CashRecordsPerPage, page*constants.CashRecordsPerPage)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки запросов на выплату.")
+		return
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString(fmt.Sprintf("💸 *Выплаты реферальных бонусов: %s*\n\n", strings.ToLower(constants.PayoutRequestStatusDisplayMap[status])))
+	if len(requests) == 0 {
+		sb.WriteString("Запросов нет.")
+	}
+	for _, r := range requests {
+		sb.WriteString(fmt.Sprintf("*#%d* %s - %s: *%.0f ₽*", r.ID, r.RequestedAt.Format("02.01.06"),
+			utils.EscapeTelegramMarkdown(referralPayoutClientName(r)), r.Amount))
+		if r.PaymentMethod.Valid {
+			sb.WriteString(" (" + constants.ReferralPayoutMethodDisplayMap[r.PaymentMethod.String] + ")")
+		}
+		sb.WriteString("\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("#%d - %.0f ₽", r.ID, r.Amount), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW, r.ID)),
+		))
+	}
+
+	totalPages := 0
+	if total > 0 {
+		totalPages = int(math.Ceil(float64(total) / float64(constants.CashRecordsPerPage)))
+	}
+	navRow := []tgbotapi.InlineKeyboardButton{}
+	if page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ Пред.", fmt.Sprintf("%s_%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS, status, page-1)))
+	}
+	if page < totalPages-1 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("След. ➡️", fmt.Sprintf("%s_%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS, status, page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	filterRow := []tgbotapi.InlineKeyboardButton{}
+	for _, s := range []string{constants.PAYOUT_REQUEST_STATUS_PENDING, constants.PAYOUT_REQUEST_STATUS_COMPLETED, constants.PAYOUT_REQUEST_STATUS_REJECTED} {
+		if s != status {
+			filterRow = append(filterRow, tgbotapi.NewInlineKeyboardButtonData(constants.PayoutRequestStatusDisplayMap[s], fmt.Sprintf("%s_%s_0", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS, s)))
+		}
+	}
+	rows = append(rows, filterRow)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🕵️ Бонусы на проверке", fmt.Sprintf("%s_0", constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS)),
+	))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerReferralPayoutsList: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerReferralPayoutView - карточка запроса с реквизитами клиента и кнопками решения.
+func (bh *BotHandler) SendOwnerReferralPayoutView(chatID int64, user models.User, requestID int64, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_MANAGEMENT_MENU)
+	req, err := db.GetReferralPayoutRequestByID(requestID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Запрос на выплату #%d не найден.", requestID))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("💸 *Запрос на выплату #%d*\n\n", req.ID))
+	sb.WriteString(fmt.Sprintf("Клиент: %s (ChatID: `%d`)\n", utils.EscapeTelegramMarkdown(referralPayoutClientName(req)), req.UserChatID))
+	sb.WriteString(fmt.Sprintf("Сумма: *%.0f ₽*\n", req.Amount))
+	sb.WriteString(fmt.Sprintf("Создан: %s\n", req.RequestedAt.Format("02.01.2006 15:04")))
+	sb.WriteString(fmt.Sprintf("Статус: %s\n", constants.PayoutRequestStatusDisplayMap[req.Status]))
+	if len(req.ReferralIDs) > 0 {
+		sb.WriteString(fmt.Sprintf("Бонусов в запросе: %d\n", len(req.ReferralIDs)))
+	}
+	sb.WriteString("\n" + formatReferralPayoutDetails(req, false) + "\n")
+	if req.ProcessedAt.Valid {
+		sb.WriteString(fmt.Sprintf("\nОбработан: %s\n", req.ProcessedAt.Time.Format("02.01.2006 15:04")))
+	}
+	if req.PayoutID.Valid {
+		sb.WriteString(fmt.Sprintf("Выплата: #%d\n", req.PayoutID.Int64))
+	}
+	if req.AdminComment.Valid && req.AdminComment.String != "" {
+		sb.WriteString("💬 " + utils.EscapeTelegramMarkdown(req.AdminComment.String) + "\n")
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if req.Status == constants.PAYOUT_REQUEST_STATUS_PENDING {
+		sb.WriteString("\nПереведите деньги по реквизитам и нажмите «Выплачено».")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Выплачено", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_PAID, req.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_REJECT, req.ID)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 К очереди", fmt.Sprintf("%s_%s_0", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS, req.Status)),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerReferralPayoutView: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerReferralPayoutPaid фиксирует перевод денег по запросу и сообщает клиенту.
+func (bh *BotHandler) handleOwnerReferralPayoutPaid(chatID int64, user models.User, requestID int64, messageIDToEdit int) {
+	req, err := db.CompleteReferralPayoutRequest(requestID, user.ID, "")
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось провести выплату: %v", err))
+		return
+	}
+	log.Printf("handleOwnerReferralPayoutPaid: владелец %d выплатил запрос #%d", user.ID, requestID)
+	bh.NotifyClientReferralPayoutProcessed(req)
+	bh.SendOwnerReferralPayoutView(chatID, user, requestID, messageIDToEdit)
+}
+
+// SendOwnerReferralPayoutRejectPrompt - запрос причины отказа, которую увидит клиент.
+func (bh *BotHandler) SendOwnerReferralPayoutRejectPrompt(chatID int64, user models.User, requestID int64, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_REFERRAL_REJECT)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.ReferralPayoutRequestID = requestID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := fmt.Sprintf("❌ *Отказ в выплате по запросу #%d*\n\nНапишите причину - ее получит клиент. Бонусы вернутся к нему и их можно будет запросить снова.", requestID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Отмена", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUT_VIEW, requestID)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerReferralPayoutRejectPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerReferralPayoutRejectInput отклоняет запрос с причиной владельца и сообщает клиенту.
+func (bh *BotHandler) handleOwnerReferralPayoutRejectInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+	reason := strings.TrimSpace(text)
+	if reason == "" {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Напишите причину отказа.")
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	req, err := db.RejectReferralPayoutRequest(tempData.ReferralPayoutRequestID, user.ID, reason)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось отклонить запрос: %v", err))
+		return
+	}
+	log.Printf("handleOwnerReferralPayoutRejectInput: владелец %d отклонил запрос #%d", user.ID, req.ID)
+	tempData.ReferralPayoutRequestID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.NotifyClientReferralPayoutProcessed(req)
+	bh.SendOwnerReferralPayoutView(chatID, user, req.ID, botMenuMsgID)
+}
+
+// SendOwnerReferralReviewsList - удержанные бонусы с причинами подозрения и кнопками решения.
+func (bh *BotHandler) SendOwnerReferralReviewsList(chatID int64, user models.User, page int, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_CASH_MANAGEMENT_MENU)
+	referrals, total, err := db.GetReferralsOnReview(constants.CashRecordsPerPage, page*constants.CashRecordsPerPage)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки бонусов на проверке.")
+		return
+	}
+
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString("🕵️ *Реферальные бонусы на проверке*\n\n")
+	if len(referrals) == 0 {
+		sb.WriteString("Подозрительных бонусов нет.")
+	} else {
+		sb.WriteString("Бонусы с признаками самоприглашения не выплачиваются, пока вы их не подтвердите.\n\n")
+	}
+	for _, r := range referrals {
+		sb.WriteString(fmt.Sprintf("*#%d* %s - *%.0f ₽*, заказ №%d\n", r.ID, r.CreatedAt.Format("02.01.06"), r.Amount, r.OrderID))
+		sb.WriteString(fmt.Sprintf("%s → %s\n", utils.EscapeTelegramMarkdown(r.InviterName), utils.EscapeTelegramMarkdown(r.Name)))
+		sb.WriteString(formatReferralReviewReasons(r.ReviewReasons) + "\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ #%d", r.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_APPROVE, r.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🚫 #%d", r.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEW_REJECT, r.ID)),
+		))
+	}
+
+	totalPages := 0
+	if total > 0 {
+		totalPages = int(math.Ceil(float64(total) / float64(constants.CashRecordsPerPage)))
+	}
+	navRow := []tgbotapi.InlineKeyboardButton{}
+	if page > 0 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("⬅️ Пред.", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS, page-1)))
+	}
+	if page < totalPages-1 {
+		navRow = append(navRow, tgbotapi.NewInlineKeyboardButtonData("След. ➡️", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS, page+1)))
+	}
+	if len(navRow) > 0 {
+		rows = append(rows, navRow)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔙 К выплатам", fmt.Sprintf("%s_%s_0", constants.CALLBACK_PREFIX_OWNER_REFERRAL_PAYOUTS, constants.PAYOUT_REQUEST_STATUS_PENDING)),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerReferralReviewsList: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerReferralReview подтверждает или отклоняет удержанный бонус и сообщает пригласившему.
+func (bh *BotHandler) handleOwnerReferralReview(chatID int64, user models.User, referralID int64, approve bool, messageIDToEdit int) {
+	referral, err := db.ReviewReferral(referralID, approve, user.ID, "")
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось сохранить решение: %v", err))
+		return
+	}
+	log.Printf("handleOwnerReferralReview: владелец %d вынес решение по бонусу #%d (подтвержден: %v)", user.ID, referralID, approve)
+	bh.NotifyClientReferralReviewed(referral)
+	bh.SendOwnerReferralReviewsList(chatID, user, 0, messageIDToEdit)
+}
+
+// NotifyClientReferralReviewed сообщает пригласившему решение по удержанному бонусу.
+func (bh *BotHandler) NotifyClientReferralReviewed(referral models.Referral) {
+	inviter, err := db.GetUserByID(int(referral.InviterID))
+	if err != nil || inviter.ChatID == 0 {
+		log.Printf("NotifyClientReferralReviewed: не удалось найти пригласившего %d по бонусу #%d: %v", referral.InviterID, referral.ID, err)
+		return
+	}
+	var text string
+	if referral.ReviewStatus.String == constants.REFERRAL_REVIEW_STATUS_APPROVED {
+		text = fmt.Sprintf("✅ Реферальный бонус *%.0f ₽* за заказ друга прошел проверку и доступен к выплате.", referral.Amount)
+		if referral.ExpiresAt.Valid {
+			text += fmt.Sprintf("\nЗапросите выплату до %s, иначе бонус сгорит.", referral.ExpiresAt.Time.Format("02.01.2006"))
+		}
+	} else {
+		text = fmt.Sprintf("❌ Реферальный бонус %.0f ₽ за заказ друга не подтвержден: приглашение не соответствует условиям программы.", referral.Amount)
+		if referral.ReviewComment.Valid && referral.ReviewComment.String != "" {
+			text += "\nКомментарий: " + utils.EscapeTelegramMarkdown(referral.ReviewComment.String)
+		}
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👥 Мои рефералы", "referral_my")),
+	)
+	if _, err := bh.sendMessageWithKeyboard(inviter.ChatID, text, &keyboard); err != nil {
+		log.Printf("NotifyClientReferralReviewed: ошибка уведомления клиента %d по бонусу #%d: %v", inviter.ChatID, referral.ID, err)
+	}
+}
+
+// NotifyClientReferralPayoutProcessed сообщает клиенту о выплате или отказе по его запросу.
+func (bh *BotHandler) NotifyClientReferralPayoutProcessed(req models.ReferralPayoutRequest) {
+	var text string
+	switch req.Status {
+	case constants.PAYOUT_REQUEST_STATUS_COMPLETED:
+		text = fmt.Sprintf("✅ Реферальные бонусы по запросу №%d выплачены: *%.0f ₽*.\n%s\n\nСпасибо, что рекомендуете нас!",
+			req.ID, req.Amount, formatReferralPayoutDetails(req, true))
+	case constants.PAYOUT_REQUEST_STATUS_REJECTED:
+		text = fmt.Sprintf("❌ Запрос №%d на выплату %.0f ₽ отклонен.", req.ID, req.Amount)
+		if req.AdminComment.Valid && req.AdminComment.String != "" {
+			text += "\nПричина: " + utils.EscapeTelegramMarkdown(req.AdminComment.String)
+		}
+		text += "\n\nБонусы снова доступны - вы можете запросить выплату повторно."
+	default:
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👥 Мои рефералы", "referral_my")),
+	)
+	if _, err := bh.sendMessageWithKeyboard(req.UserChatID, text, &keyboard); err != nil {
+		log.Printf("NotifyClientReferralPayoutProcessed: ошибка уведомления клиента %d по запросу #%d: %v", req.UserChatID, req.ID, err)
+	}
+}
+
+// formatReferralPayoutDetails - способ и реквизиты выплаты. Для клиента номер карты маскируется.
+func formatReferralPayoutDetails(req models.ReferralPayoutRequest, masked bool) string {
+	if !req.PaymentMethod.Valid || !req.PaymentDetails.Valid || req.PaymentDetails.String == "" {
+		return "Реквизиты не указаны - уточните у клиента."
+	}
+	details := req.PaymentDetails.String
+	switch req.PaymentMethod.String {
+	case constants.REFERRAL_PAYOUT_METHOD_CARD:
+		if masked {
+			details = maskCardNumber(details)
+		}
+	case constants.REFERRAL_PAYOUT_METHOD_SBP:
+		details = utils.FormatPhoneNumber(details)
+	}
+	return fmt.Sprintf("%s: `%s`", constants.ReferralPayoutMethodDisplayMap[req.PaymentMethod.String], details)
+}
+
+// referralPayoutClientName - имя клиента запроса или его ChatID, если пользователь не найден.
+func referralPayoutClientName(req models.ReferralPayoutRequest) string {
+	if req.UserName != "" {
+		return req.UserName
+	}
+	return fmt.Sprintf("ChatID %d", req.UserChatID)
+}
+
+// maskCardNumber оставляет видимыми только последние 4 цифры номера карты.
+func maskCardNumber(cardNumber string) string {
+	if len(cardNumber) <= 4 {
+		return cardNumber
+	}
+	return "•••• " + cardNumber[len(cardNumber)-4:]
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// referralRulesHistoryLimit - сколько последних версий условий показывать владельцу.
+const referralRulesHistoryLimit = 10
+
+// SendOwnerReferralRulesMenu - действующие условия реферальной программы и история версий.
+func (bh *BotHandler) SendOwnerReferralRulesMenu(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerReferralRulesMenu: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_REFERRAL_RULES)
+
+	rules, err := db.GetReferralRules(referralRulesHistoryLimit)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки условий реферальной программы.")
+		return
+	}
+	active, errActive := db.GetActiveReferralRule()
+
+	var sb strings.Builder
+	sb.WriteString("🎁 *Условия реферальной программы*\n\n")
+	if errActive != nil {
+		sb.WriteString("Действующих условий нет, бонусы не начисляются.\n\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("Действует версия *%d* с %s:\n", active.Version, active.EffectiveFrom.Format("02.01.2006 15:04")))
+		sb.WriteString(formatReferralRuleTerms(active))
+		sb.WriteString("\n")
+	}
+	sb.WriteString("Условия не редактируются: изменения оформляются новой версией. Уже начисленные бонусы остаются по своей версии.\n")
+
+	if len(rules) > 0 {
+		sb.WriteString("\n*История версий:*\n")
+	}
+	for _, rule := range rules {
+		sb.WriteString(fmt.Sprintf("v%d с %s — %s", rule.Version, rule.EffectiveFrom.Format("02.01.2006"), utils.EscapeTelegramMarkdown(formatReferralRuleSummary(rule))))
+		if rule.Comment.Valid && rule.Comment.String != "" {
+			sb.WriteString(fmt.Sprintf("\n    _%s_", utils.EscapeTelegramMarkdown(rule.Comment.String)))
+		}
+		sb.WriteString("\n")
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Новая версия условий", constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULE_ADD)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN)),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerReferralRulesMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerReferralRuleAddPrompt - запрос параметров новой версии условий одной строкой.
+func (bh *BotHandler) SendOwnerReferralRuleAddPrompt(chatID int64, user models.User, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_REFERRAL_RULE_INPUT)
+
+	var categories []string
+	for code, name := range constants.CategoryDisplayMap {
+		categories = append(categories, fmt.Sprintf("`%s` (%s)", code, name))
+	}
+	sort.Strings(categories)
+	text := "➕ *Новая версия условий реферальной программы*\n\n" +
+		"Отправьте одной строкой через `;`:\n" +
+		"`бонус; мин. заказ; категории; лимит в месяц; срок бонуса в днях; мин. выплата; комментарий`\n\n" +
+		"Бонус - сумма в рублях или процент от заказа со знаком `%`. Категории перечисляются через запятую. " +
+		"Вместо любого ограничения можно указать `-` или `0` — ограничения не будет. Комментарий необязателен.\n\n" +
+		"Категории: " + strings.Join(categories, ", ") + "\n\n" +
+		"Пример: `5%; 10000; waste_removal, demolition; 3000; 90; 1000; осенняя акция`\n\n" +
+		"Новые условия вступают в силу сразу и применяются к заказам, выполненным после этого."
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", constants.CALLBACK_PREFIX_OWNER_REFERRAL_RULES),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerReferralRuleAddPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerReferralRuleInput разбирает строку с условиями и сохраняет новую версию.
+func (bh *BotHandler) handleOwnerReferralRuleInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+
+	rule, err := parseReferralRuleInput(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	rule.CreatedByUserID = sql.NullInt64{Int64: user.ID, Valid: true}
+
+	created, err := db.CreateReferralRule(rule)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить условия: %v", err))
+		return
+	}
+	log.Printf("handleOwnerReferralRuleInput: владелец %d добавил версию %d условий реферальной программы", user.ID, created.Version)
+	bh.Deps.SessionManager.ClearState(chatID)
+	bh.SendOwnerReferralRulesMenu(chatID, user, botMenuMsgID)
+}
+
+// parseReferralRuleInput разбирает строку "бонус; мин. заказ; категории; лимит в месяц; срок в днях; мин. выплата; комментарий".
+func parseReferralRuleInput(text string) (models.ReferralRule, error) {
+	var rule models.ReferralRule
+	fields := strings.Split(text, ";")
+	if len(fields) < 6 {
+		return rule, fmt.Errorf("нужно минимум 6 полей: бонус, мин. заказ, категории, лимит, срок и мин. выплата")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	isEmpty := func(s string) bool { return s == "" || s == "-" }
+	parseAmount := func(s, name string) (float64, error) {
+		if isEmpty(s) {
+			return 0, nil
+		}
+		value, errValue := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+		if errValue != nil || value < 0 {
+			return 0, fmt.Errorf("%s «%s» должен быть неотрицательным числом", name, s)
+		}
+		return value, nil
+	}
+
+	rule.BonusType = constants.REFERRAL_BONUS_TYPE_FIXED
+	bonus := fields[0]
+	if strings.HasSuffix(bonus, "%") {
+		rule.BonusType = constants.REFERRAL_BONUS_TYPE_PERCENT
+		bonus = strings.TrimSpace(strings.TrimSuffix(bonus, "%"))
+	}
+	bonusValue, err := strconv.ParseFloat(strings.Replace(bonus, ",", ".", 1), 64)
+	if err != nil || bonusValue <= 0 {
+		return rule, fmt.Errorf("размер бонуса «%s» должен быть положительным числом", fields[0])
+	}
+	rule.BonusValue = bonusValue
+
+	if rule.MinOrderAmount, err = parseAmount(fields[1], "минимальный заказ"); err != nil {
+		return rule, err
+	}
+	if !isEmpty(fields[2]) {
+		for _, part := range strings.Split(fields[2], ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			category, ok := resolveCategoryInput(part)
+			if !ok {
+				return rule, fmt.Errorf("неизвестная категория «%s»", part)
+			}
+			rule.Categories = append(rule.Categories, category)
+		}
+	}
+	if rule.MonthlyCap, err = parseAmount(fields[3], "лимит в месяц"); err != nil {
+		return rule, err
+	}
+	if !isEmpty(fields[4]) {
+		days, errDays := strconv.Atoi(fields[4])
+		if errDays != nil || days < 0 {
+			return rule, fmt.Errorf("срок бонуса «%s» должен быть целым числом дней", fields[4])
+		}
+		rule.ExpiryDays = days
+	}
+	if rule.MinPayoutAmount, err = parseAmount(fields[5], "порог выплаты"); err != nil {
+		return rule, err
+	}
+	if len(fields) > 6 {
+		comment := strings.TrimSpace(strings.Join(fields[6:], ";"))
+		rule.Comment = sql.NullString{String: comment, Valid: comment != ""}
+	}
+	return rule, nil
+}
+
+// formatReferralRuleSummary - краткое описание версии условий в одну строку.
+func formatReferralRuleSummary(rule models.ReferralRule) string {
+	parts := []string{formatReferralBonusAmount(rule)}
+	if rule.MinOrderAmount > 0 {
+		parts = append(parts, fmt.Sprintf("заказ от %.0f ₽", rule.MinOrderAmount))
+	}
+	if len(rule.Categories) > 0 {
+		parts = append(parts, strings.Join(rule.Categories, ", "))
+	}
+	if rule.MonthlyCap > 0 {
+		parts = append(parts, fmt.Sprintf("лимит %.0f ₽/мес", rule.MonthlyCap))
+	}
+	if rule.ExpiryDays > 0 {
+		parts = append(parts, fmt.Sprintf("срок %d дн.", rule.ExpiryDays))
+	}
+	if rule.MinPayoutAmount > 0 {
+		parts = append(parts, fmt.Sprintf("выплата от %.0f ₽", rule.MinPayoutAmount))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,645 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// SendOwnerVehiclesMenu - список машин автопарка с нормами расхода.
+func (bh *BotHandler) SendOwnerVehiclesMenu(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("SendOwnerVehiclesMenu: для владельца ChatID=%d", chatID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_VEHICLES)
+
+	vehicles, err := db.GetVehicles(false)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки автопарка.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🚚 *Автопарк*\n\n")
+	sb.WriteString("Машина выбирается при назначении исполнителей на заказ и в отчете водителя вместе с показаниями одометра. Норма расхода нужна для отчета о расходе топлива на 100 км.\n")
+	sb.WriteString("🛠 - документы, ТО и расходы на обслуживание. Машину с истекшим ОСАГО, техосмотром или просроченным ТО нельзя назначить на заказ.\n\n")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(vehicles) == 0 {
+		sb.WriteString("Машин пока нет.")
+	}
+	for _, v := range vehicles {
+		status := ""
+		if !v.IsActive {
+			status = " _(выведена из работы)_"
+		}
+		sb.WriteString(fmt.Sprintf("#%d — *%s*%s\n    %s", v.ID, utils.EscapeTelegramMarkdown(v.DisplayName()), status, formatVehicleSpecs(v)))
+		if v.DefaultDriverUserID.Valid {
+			sb.WriteString(fmt.Sprintf("\n    👤 %s", utils.EscapeTelegramMarkdown(v.DefaultDriverName)))
+		}
+		if v.IsActive {
+			if compliance, errCompliance := db.GetVehicleCompliance(v, time.Now()); errCompliance == nil {
+				for _, issue := range compliance.Issues {
+					sb.WriteString("\n    ⛔ " + utils.EscapeTelegramMarkdown(issue))
+				}
+				for _, warning := range compliance.Warnings {
+					sb.WriteString("\n    ⚠️ " + utils.EscapeTelegramMarkdown(warning))
+				}
+			}
+		}
+		sb.WriteString("\n")
+
+		toggleText := fmt.Sprintf("⏸ Вывести #%d", v.ID)
+		if !v.IsActive {
+			toggleText = fmt.Sprintf("▶️ Вернуть #%d", v.ID)
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🛠 %s", v.Plate), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD, v.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✏️ #%d", v.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_EDIT, v.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(toggleText, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_TOGGLE, v.ID)),
+		))
+	}
+
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Добавить машину", constants.CALLBACK_PREFIX_OWNER_VEHICLE_ADD)),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("⛽️ Расход топлива за %d дней", constants.FUEL_REPORT_PERIOD_DAYS), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_FUEL_REPORT, constants.FUEL_REPORT_PERIOD_DAYS))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 В меню ДС", constants.CALLBACK_PREFIX_OWNER_CASH_MANAGEMENT_MAIN)),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerVehiclesMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerVehicleInputPrompt - запрос параметров машины одной строкой; vehicleID=0 - новая машина.
+func (bh *BotHandler) SendOwnerVehicleInputPrompt(chatID int64, user models.User, vehicleID int64, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_VEHICLE_INPUT)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.EditingVehicleID = vehicleID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	title := "➕ *Новая машина*"
+	if vehicleID != 0 {
+		vehicle, err := db.GetVehicleByID(vehicleID)
+		if err != nil {
+			bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Машина не найдена.")
+			return
+		}
+		driverID := "-"
+		if vehicle.DefaultDriverUserID.Valid {
+			driverID = strconv.FormatInt(vehicle.DefaultDriverUserID.Int64, 10)
+		}
+		title = fmt.Sprintf("✏️ *Машина #%d*\n\nСейчас: `%s; %s; %s; %s; %s; %s; %s`", vehicle.ID,
+			vehicle.Plate, vehicle.Model.String, formatVehicleNumber(vehicle.CapacityM3), formatVehicleNumber(vehicle.CapacityTonnes),
+			formatVehicleNumber(vehicle.FuelNormLPer100Km), formatVehicleNumber(vehicle.FuelPricePerL), driverID)
+	}
+	text := title + "\n\n" +
+		"Отправьте одной строкой через `;`:\n" +
+		"`госномер; модель; объем м³; грузоподъемность т; норма л/100 км; цена литра ₽; ID водителя`\n\n" +
+		"Модель и ID закрепленного водителя можно заменить на `-`. Без цены литра расход считается только в рублях на 100 км.\n\n" +
+		"Пример: `А123ВС 77; ГАЗель Next; 16; 1.5; 14; 62; -`"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", constants.CALLBACK_PREFIX_OWNER_VEHICLES),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerVehicleInputPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerVehicleInput разбирает строку с параметрами машины и сохраняет ее.
+func (bh *BotHandler) handleOwnerVehicleInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+
+	vehicle, err := parseVehicleInput(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	if vehicle.DefaultDriverUserID.Valid {
+		driver, errUser := db.GetUserByID(int(vehicle.DefaultDriverUserID.Int64))
+		if errUser != nil || driver.Role != constants.ROLE_DRIVER {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Водитель с ID %d не найден.", vehicle.DefaultDriverUserID.Int64))
+			return
+		}
+	}
+
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	if tempData.EditingVehicleID != 0 {
+		vehicle.ID = tempData.EditingVehicleID
+		err = db.UpdateVehicle(vehicle)
+	} else {
+		vehicle.ID, err = db.CreateVehicle(vehicle)
+	}
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить машину: %v", err))
+		return
+	}
+	log.Printf("handleOwnerVehicleInput: владелец %d сохранил машину #%d (%s)", user.ID, vehicle.ID, vehicle.Plate)
+	tempData.EditingVehicleID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.Deps.SessionManager.ClearState(chatID)
+	bh.SendOwnerVehiclesMenu(chatID, user, botMenuMsgID)
+}
+
+// handleOwnerToggleVehicle выводит машину из работы или возвращает в нее.
+func (bh *BotHandler) handleOwnerToggleVehicle(chatID int64, user models.User, vehicleID int64, messageIDToEdit int) {
+	vehicle, err := db.GetVehicleByID(vehicleID)
+	if err == nil {
+		err = db.SetVehicleActive(vehicleID, !vehicle.IsActive)
+	}
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось изменить статус машины #%d: %v", vehicleID, err))
+		return
+	}
+	log.Printf("handleOwnerToggleVehicle: владелец %d изменил статус машины #%d", user.ID, vehicleID)
+	bh.SendOwnerVehiclesMenu(chatID, user, messageIDToEdit)
+}
+
+// SendOwnerFuelReport - расход топлива на 100 км по машинам за последние days дней с аномальными отчетами.
+func (bh *BotHandler) SendOwnerFuelReport(chatID int64, user models.User, days int, messageIDToEdit int) {
+	to := time.Now()
+	from := to.AddDate(0, 0, -days+1)
+	report, err := db.GetVehicleFuelReport(from, to)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка формирования отчета о расходе топлива.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⛽️ *Расход топлива* за %s – %s\n", from.Format("02.01.2006"), to.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("Допустимое отклонение от нормы: ±%.0f%%\n\n", report.Tolerance*100))
+	if len(report.Vehicles) == 0 {
+		sb.WriteString("За период нет отчетов водителей с выбранной машиной и расходов на обслуживание.")
+	}
+	for _, stat := range report.Vehicles {
+		mark := "✅"
+		if stat.IsAnomalous {
+			mark = "⚠️"
+		} else if stat.MileageKm == 0 {
+			mark = "❔"
+		}
+		sb.WriteString(fmt.Sprintf("%s *%s*: %d км, %.0f ₽", mark, utils.EscapeTelegramMarkdown(stat.Vehicle), stat.MileageKm, stat.FuelExpense))
+		if stat.MileageKm > 0 {
+			sb.WriteString(fmt.Sprintf(", %.0f ₽/100 км", stat.RubPer100Km))
+		}
+		if stat.LitersPer100Km > 0 {
+			sb.WriteString(fmt.Sprintf(", ≈%.1f л/100 км", stat.LitersPer100Km))
+			if stat.FuelNormLPer100Km > 0 {
+				sb.WriteString(fmt.Sprintf(" (норма %.1f, %+.0f%%)", stat.FuelNormLPer100Km, stat.DeviationPct))
+			}
+		}
+		if stat.WithoutOdometerCount > 0 {
+			sb.WriteString(fmt.Sprintf("\n    _без одометра: %d из %d отчетов_", stat.WithoutOdometerCount, stat.SettlementsCount))
+		}
+		if stat.MaintenanceCost > 0 {
+			sb.WriteString(fmt.Sprintf("\n    🛠 обслуживание: %.0f ₽", stat.MaintenanceCost))
+		}
+		sb.WriteString("\n")
+	}
+
+	if len(report.Anomalies) > 0 {
+		sb.WriteString("\n*Отчеты с отклонением:*\n")
+		const maxAnomaliesShown = 15
+		for i, a := range report.Anomalies {
+			if i == maxAnomaliesShown {
+				sb.WriteString(fmt.Sprintf("_...и еще %d_\n", len(report.Anomalies)-maxAnomaliesShown))
+				break
+			}
+			line := fmt.Sprintf("#%d %s, %s, %s: %d км, %.0f ₽", a.SettlementID, a.ReportDate.Format("02.01"),
+				utils.EscapeTelegramMarkdown(a.Vehicle), utils.EscapeTelegramMarkdown(a.DriverName), a.MileageKm, a.FuelExpense)
+			if a.LitersPer100Km > 0 {
+				line += fmt.Sprintf(", ≈%.1f л/100 км (%+.0f%%)", a.LitersPer100Km, a.DeviationPct)
+			}
+			sb.WriteString(fmt.Sprintf("⚠️ %s — %s\n", line, a.Reason))
+		}
+	}
+
+	var periodButtons []tgbotapi.InlineKeyboardButton
+	for _, period := range []int{7, 30, 90} {
+		label := fmt.Sprintf("%d дн.", period)
+		if period == days {
+			label = "• " + label
+		}
+		periodButtons = append(periodButtons, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_FUEL_REPORT, period)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		periodButtons,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 К автопарку", constants.CALLBACK_PREFIX_OWNER_VEHICLES)),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerFuelReport: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// parseVehicleInput разбирает строку "госномер; модель; объем; грузоподъемность; норма; цена литра; ID водителя".
+func parseVehicleInput(text string) (models.Vehicle, error) {
+	var vehicle models.Vehicle
+	fields := strings.Split(text, ";")
+	if len(fields) < 5 {
+		return vehicle, fmt.Errorf("нужно минимум 5 полей: госномер, модель, объем, грузоподъемность и норма расхода")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	isEmpty := func(s string) bool { return s == "" || s == "-" }
+
+	if isEmpty(fields[0]) {
+		return vehicle, fmt.Errorf("не указан госномер")
+	}
+	vehicle.Plate = strings.ToUpper(fields[0])
+	if !isEmpty(fields[1]) {
+		vehicle.Model = sql.NullString{String: fields[1], Valid: true}
+	}
+
+	numbers := make([]float64, 4)
+	names := []string{"объем", "грузоподъемность", "норма расхода", "цена литра"}
+	for i := range numbers {
+		if 2+i >= len(fields) || isEmpty(fields[2+i]) {
+			continue
+		}
+		value, errValue := strconv.ParseFloat(strings.Replace(fields[2+i], ",", ".", 1), 64)
+		if errValue != nil || value < 0 {
+			return vehicle, fmt.Errorf("%s «%s» должна быть неотрицательным числом", names[i], fields[2+i])
+		}
+		numbers[i] = value
+	}
+	vehicle.CapacityM3, vehicle.CapacityTonnes, vehicle.FuelNormLPer100Km, vehicle.FuelPricePerL = numbers[0], numbers[1], numbers[2], numbers[3]
+
+	if len(fields) > 6 && !isEmpty(fields[6]) {
+		driverID, errID := strconv.ParseInt(fields[6], 10, 64)
+		if errID != nil || driverID <= 0 {
+			return vehicle, fmt.Errorf("некорректный ID водителя «%s»", fields[6])
+		}
+		vehicle.DefaultDriverUserID = sql.NullInt64{Int64: driverID, Valid: true}
+	}
+	return vehicle, nil
+}
+
+// formatVehicleSpecs - вместимость и норма расхода машины одной строкой.
+func formatVehicleSpecs(v models.Vehicle) string {
+	var parts []string
+	if v.CapacityM3 > 0 {
+		parts = append(parts, formatVehicleNumber(v.CapacityM3)+" м³")
+	}
+	if v.CapacityTonnes > 0 {
+		parts = append(parts, formatVehicleNumber(v.CapacityTonnes)+" т")
+	}
+	if v.FuelNormLPer100Km > 0 {
+		parts = append(parts, formatVehicleNumber(v.FuelNormLPer100Km)+" л/100 км")
+	}
+	if v.FuelPricePerL > 0 {
+		parts = append(parts, formatVehicleNumber(v.FuelPricePerL)+" ₽/л")
+	}
+	if len(parts) == 0 {
+		return "параметры не указаны"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatVehicleNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// SendOwnerVehicleCard - документы, состояние ТО и история обслуживания машины.
+func (bh *BotHandler) SendOwnerVehicleCard(chatID int64, user models.User, vehicleID int64, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_VEHICLES)
+	vehicle, err := db.GetVehicleByID(vehicleID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Машина не найдена.")
+		return
+	}
+	const recordsShown = 10
+	records, err := db.GetVehicleMaintenance(vehicleID, recordsShown)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки истории обслуживания.")
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🛠 *%s*\n\n", utils.EscapeTelegramMarkdown(vehicle.DisplayName())))
+	sb.WriteString(fmt.Sprintf("📄 ОСАГО до: %s\n", formatVehicleDate(vehicle.OsagoExpiresOn)))
+	sb.WriteString(fmt.Sprintf("📄 Техосмотр до: %s\n", formatVehicleDate(vehicle.InspectionExpiresOn)))
+	sb.WriteString(fmt.Sprintf("🔧 ТО: %s\n", formatServiceInterval(vehicle)))
+	if vehicle.LastServiceOn.Valid {
+		sb.WriteString(fmt.Sprintf("🔧 Последнее ТО: %s", vehicle.LastServiceOn.Time.Format("02.01.2006")))
+		if vehicle.LastServiceOdometer.Valid {
+			sb.WriteString(fmt.Sprintf(", %d км", vehicle.LastServiceOdometer.Int64))
+		}
+		sb.WriteString("\n")
+	}
+
+	compliance, err := db.GetVehicleCompliance(vehicle, time.Now())
+	if err == nil {
+		if compliance.Allowed() && len(compliance.Warnings) == 0 {
+			sb.WriteString("\n✅ Машина допущена к работе.\n")
+		}
+		for _, issue := range compliance.Issues {
+			sb.WriteString("\n⛔ " + utils.EscapeTelegramMarkdown(issue))
+		}
+		for _, warning := range compliance.Warnings {
+			sb.WriteString("\n⚠️ " + utils.EscapeTelegramMarkdown(warning))
+		}
+		if len(compliance.Issues) > 0 {
+			sb.WriteString("\n_Машину нельзя назначить на заказ, пока проблемы не устранены._")
+		}
+		sb.WriteString("\n")
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	sb.WriteString("\n*Обслуживание:*\n")
+	if len(records) == 0 {
+		sb.WriteString("Записей пока нет.\n")
+	}
+	for _, m := range records {
+		line := fmt.Sprintf("#%d %s — %s, %.0f ₽", m.ID, m.PerformedOn.Format("02.01.2006"), constants.VehicleMaintenanceKindDisplayMap[m.Kind], m.Cost)
+		if m.Odometer.Valid {
+			line += fmt.Sprintf(", %d км", m.Odometer.Int64)
+		}
+		if m.ValidUntil.Valid {
+			line += ", до " + m.ValidUntil.Time.Format("02.01.2006")
+		}
+		if m.Description.Valid && m.Description.String != "" {
+			line += " — " + m.Description.String
+		}
+		if m.CanceledAt.Valid {
+			sb.WriteString(utils.EscapeTelegramMarkdown(line) + " _(отменена)_\n")
+			continue
+		}
+		sb.WriteString(utils.EscapeTelegramMarkdown(line) + "\n")
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("↩️ Отменить запись #%d", m.ID), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_CANCEL, m.ID)),
+		))
+	}
+	sb.WriteString("\nСтоимость обслуживания списывается из кассы и попадает в финансовые отчеты как «Обслуживание автопарка».")
+
+	rows = append([][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("➕ Запись об обслуживании", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_MAINT_ADD, vehicleID))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📄 Документы и интервалы ТО", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_DOCS, vehicleID))),
+	}, rows...)
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔙 К автопарку", constants.CALLBACK_PREFIX_OWNER_VEHICLES)))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerVehicleCard: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendOwnerVehicleDocsPrompt - запрос сроков документов и интервалов ТО одной строкой.
+func (bh *BotHandler) SendOwnerVehicleDocsPrompt(chatID int64, user models.User, vehicleID int64, messageIDToEdit int) {
+	vehicle, err := db.GetVehicleByID(vehicleID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Машина не найдена.")
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_VEHICLE_DOCS_INPUT)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.EditingVehicleID = vehicleID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := fmt.Sprintf("📄 *Документы и ТО: %s*\n\nСейчас: `%s; %s; %d; %d`\n\n", utils.EscapeTelegramMarkdown(vehicle.Plate),
+		formatVehicleDate(vehicle.OsagoExpiresOn), formatVehicleDate(vehicle.InspectionExpiresOn), vehicle.ServiceIntervalKm, vehicle.ServiceIntervalDays) +
+		"Отправьте одной строкой через `;`:\n" +
+		"`ОСАГО до; техосмотр до; ТО каждые N км; ТО каждые N дней`\n\n" +
+		"Даты - ДД.ММ.ГГГГ, `-` - не отслеживать; 0 отключает интервал ТО.\n\n" +
+		"Пример: `15.03.2027; 01.11.2026; 15000; 365`"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD, vehicleID)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerVehicleDocsPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerVehicleDocsInput сохраняет сроки документов и интервалы ТО.
+func (bh *BotHandler) handleOwnerVehicleDocsInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+	osago, inspection, intervalKm, intervalDays, err := parseVehicleDocsInput(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	vehicleID := tempData.EditingVehicleID
+	if err := db.SetVehicleDocuments(vehicleID, osago, inspection, intervalKm, intervalDays); err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить документы: %v", err))
+		return
+	}
+	log.Printf("handleOwnerVehicleDocsInput: владелец %d обновил документы машины #%d", user.ID, vehicleID)
+	tempData.EditingVehicleID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendOwnerVehicleCard(chatID, user, vehicleID, botMenuMsgID)
+}
+
+// SendOwnerVehicleMaintenancePrompt - запрос записи об обслуживании одной строкой.
+func (bh *BotHandler) SendOwnerVehicleMaintenancePrompt(chatID int64, user models.User, vehicleID int64, messageIDToEdit int) {
+	vehicle, err := db.GetVehicleByID(vehicleID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Машина не найдена.")
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_OWNER_VEHICLE_MAINT_INPUT)
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	tempData.EditingVehicleID = vehicleID
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+
+	text := fmt.Sprintf("➕ *Обслуживание: %s*\n\n", utils.EscapeTelegramMarkdown(vehicle.Plate)) +
+		"Отправьте одной строкой через `;`:\n" +
+		"`вид; сумма ₽; дата; пробег км; описание; действует до`\n\n" +
+		"Вид: `ТО`, `ремонт`, `ОСАГО`, `техосмотр` или `прочее`. Дата `-` - сегодня. Пробег и описание можно пропустить.\n" +
+		"«Действует до» - для ОСАГО и техосмотра: новый срок документа.\n\n" +
+		"Примеры:\n`ТО; 18500; -; 125300; масло, фильтры`\n`ОСАГО; 9800; 10.03.2026; -; -; 09.03.2027`"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_OWNER_VEHICLE_CARD, vehicleID)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, text, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendOwnerVehicleMaintenancePrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleOwnerVehicleMaintenanceInput сохраняет запись об обслуживании и проводит ее стоимость.
+func (bh *BotHandler) handleOwnerVehicleMaintenanceInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if user.Role != constants.ROLE_OWNER {
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+	record, err := parseVehicleMaintenanceInput(text, time.Now())
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Проверьте формат и отправьте строку снова.", err))
+		return
+	}
+	tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
+	record.VehicleID = tempData.EditingVehicleID
+	record.CreatedByUserID = sql.NullInt64{Int64: user.ID, Valid: true}
+	recordID, err := db.AddVehicleMaintenance(record)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Не удалось сохранить запись: %v", err))
+		return
+	}
+	log.Printf("handleOwnerVehicleMaintenanceInput: владелец %d добавил запись #%d для машины #%d", user.ID, recordID, record.VehicleID)
+	tempData.EditingVehicleID = 0
+	bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
+	bh.SendOwnerVehicleCard(chatID, user, record.VehicleID, botMenuMsgID)
+}
+
+// handleOwnerCancelVehicleMaintenance отменяет запись об обслуживании со сторно проводки.
+func (bh *BotHandler) handleOwnerCancelVehicleMaintenance(chatID int64, user models.User, recordID int64, messageIDToEdit int) {
+	vehicleID, err := db.CancelVehicleMaintenance(recordID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Не удалось отменить запись #%d: %v", recordID, err))
+		return
+	}
+	log.Printf("handleOwnerCancelVehicleMaintenance: владелец %d отменил запись об обслуживании #%d", user.ID, recordID)
+	bh.SendOwnerVehicleCard(chatID, user, vehicleID, messageIDToEdit)
+}
+
+// parseVehicleDocsInput разбирает строку "ОСАГО до; техосмотр до; ТО каждые N км; ТО каждые N дней".
+func parseVehicleDocsInput(text string) (osago, inspection sql.NullTime, intervalKm int64, intervalDays int, err error) {
+	fields := strings.Split(text, ";")
+	if len(fields) < 2 {
+		return osago, inspection, 0, 0, fmt.Errorf("нужно минимум 2 поля: срок ОСАГО и срок техосмотра")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	isEmpty := func(s string) bool { return s == "" || s == "-" }
+
+	parseOptionalDate := func(value, name string) (sql.NullTime, error) {
+		if isEmpty(value) {
+			return sql.NullTime{}, nil
+		}
+		date, errDate := utils.ValidateDate(value)
+		if errDate != nil {
+			return sql.NullTime{}, fmt.Errorf("некорректная дата %s «%s»", name, value)
+		}
+		return sql.NullTime{Time: date, Valid: true}, nil
+	}
+	if osago, err = parseOptionalDate(fields[0], "ОСАГО"); err != nil {
+		return
+	}
+	if inspection, err = parseOptionalDate(fields[1], "техосмотра"); err != nil {
+		return
+	}
+	if len(fields) > 2 && !isEmpty(fields[2]) {
+		intervalKm, err = strconv.ParseInt(fields[2], 10, 64)
+		if err != nil || intervalKm < 0 {
+			return osago, inspection, 0, 0, fmt.Errorf("интервал ТО в км «%s» должен быть неотрицательным целым числом", fields[2])
+		}
+	}
+	if len(fields) > 3 && !isEmpty(fields[3]) {
+		intervalDays, err = strconv.Atoi(fields[3])
+		if err != nil || intervalDays < 0 {
+			return osago, inspection, 0, 0, fmt.Errorf("интервал ТО в днях «%s» должен быть неотрицательным целым числом", fields[3])
+		}
+	}
+	return osago, inspection, intervalKm, intervalDays, nil
+}
+
+// parseVehicleMaintenanceInput разбирает строку "вид; сумма; дата; пробег; описание; действует до".
+func parseVehicleMaintenanceInput(text string, today time.Time) (models.VehicleMaintenance, error) {
+	var record models.VehicleMaintenance
+	fields := strings.Split(text, ";")
+	if len(fields) < 2 {
+		return record, fmt.Errorf("нужно минимум 2 поля: вид и сумма")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	isEmpty := func(s string) bool { return s == "" || s == "-" }
+
+	kind, ok := resolveMaintenanceKindInput(fields[0])
+	if !ok {
+		return record, fmt.Errorf("неизвестный вид обслуживания «%s»", fields[0])
+	}
+	record.Kind = kind
+
+	cost, err := strconv.ParseFloat(strings.Replace(fields[1], ",", ".", 1), 64)
+	if err != nil || cost < 0 {
+		return record, fmt.Errorf("сумма «%s» должна быть неотрицательным числом", fields[1])
+	}
+	record.Cost = cost
+
+	record.PerformedOn = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.Local)
+	if len(fields) > 2 && !isEmpty(fields[2]) {
+		record.PerformedOn, err = utils.ValidateDate(fields[2])
+		if err != nil {
+			return record, fmt.Errorf("некорректная дата «%s»", fields[2])
+		}
+		if record.PerformedOn.After(today) {
+			return record, fmt.Errorf("дата обслуживания не может быть в будущем")
+		}
+	}
+	if len(fields) > 3 && !isEmpty(fields[3]) {
+		odometer, errOdo := strconv.ParseInt(fields[3], 10, 64)
+		if errOdo != nil || odometer < 0 {
+			return record, fmt.Errorf("пробег «%s» должен быть неотрицательным целым числом", fields[3])
+		}
+		record.Odometer = sql.NullInt64{Int64: odometer, Valid: true}
+	}
+	if len(fields) > 4 && !isEmpty(fields[4]) {
+		record.Description = sql.NullString{String: fields[4], Valid: true}
+	}
+	if len(fields) > 5 && !isEmpty(fields[5]) {
+		validUntil, errDate := utils.ValidateDate(fields[5])
+		if errDate != nil {
+			return record, fmt.Errorf("некорректная дата окончания «%s»", fields[5])
+		}
+		if kind != constants.VEHICLE_MAINTENANCE_OSAGO && kind != constants.VEHICLE_MAINTENANCE_INSPECTION {
+			return record, fmt.Errorf("срок действия указывается только для ОСАГО и техосмотра")
+		}
+		record.ValidUntil = sql.NullTime{Time: validUntil, Valid: true}
+	}
+	return record, nil
+}
+
+// resolveMaintenanceKindInput принимает код вида обслуживания или его название (без учета регистра).
+func resolveMaintenanceKindInput(input string) (string, bool) {
+	for code, name := range constants.VehicleMaintenanceKindDisplayMap {
+		if strings.EqualFold(input, code) || strings.EqualFold(input, name) {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+func formatVehicleDate(date sql.NullTime) string {
+	if !date.Valid {
+		return "-"
+	}
+	return date.Time.Format("02.01.2006")
+}
+
+// formatServiceInterval - интервал планового ТО по пробегу и времени.
+func formatServiceInterval(v models.Vehicle) string {
+	var parts []string
+	if v.ServiceIntervalKm > 0 {
+		parts = append(parts, fmt.Sprintf("каждые %d км", v.ServiceIntervalKm))
+	}
+	if v.ServiceIntervalDays > 0 {
+		parts = append(parts, fmt.Sprintf("каждые %d дн.", v.ServiceIntervalDays))
+	}
+	if len(parts) == 0 {
+		return "интервал не задан"
+	}
+	return strings.Join(parts, " или ")
+}
@@ -9,6 +9,9 @@ import (
 	"fmt"
 	tgbotapi "github.com/OvyFlash/telegram-bot-api"
 	"log"
+	"math"
+	"strings"
+	"time"
 	// "strconv" // Убрано, если не используется
 )
 
@@ -31,6 +34,14 @@ func (bh *BotHandler) SendMySalaryMenu(chatID int64, user models.User, messageID
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("📊 Сколько я заработал (всего)?", fmt.Sprintf("%s_earned_stats", constants.CALLBACK_PREFIX_MY_SALARY)),
 	))
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📒 Выписка за 30 дней", fmt.Sprintf("%s_statement", constants.CALLBACK_PREFIX_MY_SALARY)),
+	))
+	if user.Role == constants.ROLE_DRIVER {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📄 Выписка по отчетам (PDF/XLSX)", constants.CALLBACK_PREFIX_DRIVER_STATEMENT),
+		))
+	}
 
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "back_to_main"),
@@ -63,7 +74,9 @@ func (bh *BotHandler) HandleShowAmountOwed(chatID int64, user models.User, messa
 	}
 
 	text := fmt.Sprintf("💸 Вам должны выплатить: *%.0f ₽*%s", amountOwed, cardNumberDisplay)
-	if amountOwed <= 0 {
+	if amountOwed < 0 {
+		text = fmt.Sprintf("ℹ️ Вам выплачено больше начисленного (аванс): *%.0f ₽*. Сумма будет учтена при следующих начислениях.%s", -amountOwed, cardNumberDisplay)
+	} else if amountOwed == 0 {
 		text = "✅ На данный момент все выплаты произведены." + cardNumberDisplay
 	}
 
@@ -110,6 +123,59 @@ func (bh *BotHandler) HandleShowEarnedStats(chatID int64, user models.User, mess
 	}
 }
 
+// HandleShowSalaryStatement показывает выписку по счету зарплаты сотрудника за последние 30 дней.
+func (bh *BotHandler) HandleShowSalaryStatement(chatID int64, user models.User, messageIDToEdit int) {
+	log.Printf("HandleShowSalaryStatement: для ChatID=%d, Роль=%s", chatID, user.Role)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_VIEW_SALARY_STATEMENT)
+
+	to := time.Now()
+	from := to.AddDate(0, 0, -30)
+	statement, err := db.GetLedgerAccountStatement(db.LedgerUserAccountCode(constants.LEDGER_ACCOUNT_STAFF_PAYABLE_PREFIX, user.ID), from, to)
+	if err != nil {
+		log.Printf("HandleShowSalaryStatement: Ошибка получения выписки для UserID %d (ChatID %d): %v", user.ID, chatID, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось получить выписку.")
+		return
+	}
+
+	// Остаток счета обязательства отрицательный, когда компания должна сотруднику, поэтому знаки меняются.
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📒 Выписка с %s по %s\n\n", from.Format("02.01.2006"), to.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("Долг на начало: *%.0f ₽*\n\n", -statement.OpeningBalance))
+	if len(statement.Lines) == 0 {
+		sb.WriteString("За период начислений и выплат не было.\n")
+	}
+	for _, line := range statement.Lines {
+		kindText := constants.LedgerKindDisplayMap[line.Kind]
+		if kindText == "" {
+			kindText = line.Kind
+		}
+		if line.IsReversal {
+			kindText = "Отмена: " + kindText
+		}
+		sign := "➕"
+		if line.Amount > 0 {
+			sign = "➖"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s %s %.0f ₽ → %.0f ₽\n",
+			line.PostedAt.Format("02.01"), sign, utils.EscapeTelegramMarkdown(kindText), math.Abs(line.Amount), -line.Balance))
+	}
+	sb.WriteString(fmt.Sprintf("\nДолг на конец: *%.0f ₽*", -statement.ClosingBalance))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в 'Моя зарплата'", constants.CALLBACK_PREFIX_MY_SALARY),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main"),
+		),
+	)
+
+	_, errSend := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown)
+	if errSend != nil {
+		log.Printf("HandleShowSalaryStatement: Ошибка отправки сообщения для ChatID %d: %v", chatID, errSend)
+	}
+}
+
 // --- УДАЛЕНЫ УСТАРЕВШИЕ ФУНКЦИИ ---
 // SendDriverExpensesMainMenu
 // SendDriverSelectOrderForExpenses
@@ -207,6 +273,9 @@ func (bh *BotHandler) SendOwnerStaffListForPayout(chatID int64, user models.User
 		rows = append(rows, navRow)
 	}
 
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🧾 Ведомость за период", constants.CALLBACK_PREFIX_OWNER_PAYROLL),
+	))
 	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
 		tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "back_to_main"),
 	))
@@ -58,7 +58,7 @@ func (bh *BotHandler) SendStaffListMenu(chatID int64, messageIDToEdit int) {
 			tgbotapi.NewInlineKeyboardButtonData(utils.GetRoleDisplayName(constants.ROLE_LOADER), fmt.Sprintf("staff_list_by_role_%s", constants.ROLE_LOADER)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf(utils.GetRoleDisplayName(constants.ROLE_USER)), fmt.Sprintf("staff_list_by_role_%s", constants.ROLE_USER)),
+			tgbotapi.NewInlineKeyboardButtonData(utils.GetRoleDisplayName(constants.ROLE_USER), fmt.Sprintf("staff_list_by_role_%s", constants.ROLE_USER)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню штата", "staff_menu"),
@@ -6,15 +6,17 @@ import (
 	"log"
 	// "os"   // Not used here
 	"strconv"
-	// "strings" // Used in utils
+	"strings"
 	"time"
 
 	tgbotapi "github.com/OvyFlash/telegram-bot-api"
 	// "github.com/xuri/excelize/v2" // Not used here
 
 	"Original/internal/constants"
+	"Original/internal/db"
 	"Original/internal/models"
 	// "Original/internal/session" // Access via bh.Deps
+	"Original/internal/statements"
 	"Original/internal/utils"
 )
 
@@ -27,6 +29,8 @@ func (bh *BotHandler) SendStatsMenu(chatID int64, messageIDToEdit int) {
 	// Права доступа проверяются в callback_handler перед вызовом этой функции
 	// Access rights are checked in callback_handler before calling this function
 
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
 	msgText := "📊 Статистика:\n\nВыберите период или действие:"
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -36,6 +40,9 @@ func (bh *BotHandler) SendStatsMenu(chatID int64, messageIDToEdit int) {
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("Выбрать период", "stats_select_custom_period"),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📒 Прибыли и убытки за месяц", profitLossCallback(monthStart, now, profitLossFormatView)),
+		),
 		// Кнопка для генерации Excel отчетов может быть здесь или в отдельном админском меню
 		// Button for generating Excel reports can be here or in a separate admin menu
 		tgbotapi.NewInlineKeyboardRow(
@@ -227,7 +234,7 @@ func (bh *BotHandler) SendDaySelectionMenu(chatID int64, messageIDToEdit int, ye
 
 // DisplayStats отображает полученную статистику.
 // DisplayStats displays the retrieved statistics.
-func (bh *BotHandler) DisplayStats(chatID int64, messageIDToEdit int, stats models.Stats, periodDescription string) {
+func (bh *BotHandler) DisplayStats(chatID int64, messageIDToEdit int, stats models.Stats, periodDescription string, startDate, endDate time.Time) {
 	log.Printf("BotHandler.DisplayStats для chatID %d, период: %s, messageIDToEdit: %d", chatID, periodDescription, messageIDToEdit)
 	bh.Deps.SessionManager.SetState(chatID, constants.STATE_STATS_MENU) // Возвращаем в меню статистики после просмотра / Return to statistics menu after viewing
 
@@ -275,6 +282,13 @@ func (bh *BotHandler) DisplayStats(chatID int64, messageIDToEdit int, stats mode
 	msgText += fmt.Sprintf("\n👥 Новых клиентов за период: *%d*", stats.NewClients)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📒 Прибыли и убытки за период", profitLossCallback(startDate, endDate, profitLossFormatView)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🧾 Выгрузка в 1С (XML)", accountingExportCallback(startDate, endDate, statements.FormatXML)),
+			tgbotapi.NewInlineKeyboardButtonData("📄 CSV", accountingExportCallback(startDate, endDate, statements.FormatCSV)),
+		),
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню статистики", "stats_menu"),
 			tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main"),
@@ -286,3 +300,169 @@ func (bh *BotHandler) DisplayStats(chatID int64, messageIDToEdit int, stats mode
 		log.Printf("DisplayStats: Ошибка для chatID %d: %v", chatID, err)
 	}
 }
+
+// profitLossFormatView - показ P&L сообщением; второй формат - statements.FormatXLSX.
+const profitLossFormatView = "view"
+
+// profitLossMaxCategories - сколько категорий P&L показывать в сообщении, остальные есть в XLSX.
+const profitLossMaxCategories = 15
+
+// profitLossCallback - коллбэк P&L за период from..to в указанном формате.
+func profitLossCallback(from, to time.Time, format string) string {
+	return fmt.Sprintf("%s_%s_%s_%s", constants.CALLBACK_PREFIX_STATS_PROFIT_LOSS, from.Format("20060102"), to.Format("20060102"), format)
+}
+
+// accountingExportCallback - коллбэк выгрузки в 1С за период from..to в указанном формате.
+func accountingExportCallback(from, to time.Time, format string) string {
+	return fmt.Sprintf("%s_%s_%s_%s", constants.CALLBACK_PREFIX_STATS_ACCOUNTING_EXPORT, from.Format("20060102"), to.Format("20060102"), format)
+}
+
+// handleAccountingExport отправляет файл выгрузки в 1С за период: EnterpriseData XML или CSV.
+func (bh *BotHandler) handleAccountingExport(chatID int64, fromStr, toStr, format string, messageIDToEdit int) {
+	from, errFrom := time.ParseInLocation("20060102", fromStr, time.Local)
+	to, errTo := time.ParseInLocation("20060102", toStr, time.Local)
+	if errFrom != nil || errTo != nil || to.Before(from) {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Некорректный период выгрузки.")
+		return
+	}
+
+	export, err := db.GetAccountingExport(from, to)
+	if err != nil {
+		log.Printf("handleAccountingExport: ошибка сбора документов за %s - %s: %v", fromStr, toStr, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось собрать документы для выгрузки.")
+		return
+	}
+	content, err := statements.RenderAccountingExport(export, format)
+	if err != nil {
+		log.Printf("handleAccountingExport: ошибка формирования выгрузки %s за %s - %s: %v", format, fromStr, toStr, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось сформировать файл выгрузки.")
+		return
+	}
+
+	doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: statements.AccountingExportFileName(export, format), Bytes: content})
+	doc.Caption = fmt.Sprintf("%s\nДокументов: %d\nРеализация: %.0f ₽, поступления: %.0f ₽\nЗарплата: %.0f ₽, прочие расходы: %.0f ₽\n"+
+		"Повторная загрузка периода обновит документы в 1С без дублей.",
+		statements.AccountingExportTitle(export), len(export.Documents),
+		export.Total(constants.ACCOUNTING_DOC_SALE), export.Total(constants.ACCOUNTING_DOC_CASH_RECEIPT),
+		export.Total(constants.ACCOUNTING_DOC_PAYROLL_PAYOUT), export.Total(constants.ACCOUNTING_DOC_EXPENSE))
+	if _, errSend := bh.Deps.BotClient.Send(doc); errSend != nil {
+		log.Printf("handleAccountingExport: ошибка отправки выгрузки в чат %d: %v", chatID, errSend)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка при отправке файла выгрузки.")
+	}
+}
+
+// formatPercentChange - изменение показателя к предыдущему периоду для сообщения.
+func formatPercentChange(change *float64) string {
+	if change == nil {
+		return ""
+	}
+	arrow := "▲"
+	if *change < 0 {
+		arrow = "▼"
+	}
+	return fmt.Sprintf(" (%s %.1f%%)", arrow, *change)
+}
+
+// handleProfitLossReport показывает P&L за период или отправляет его файлом XLSX.
+func (bh *BotHandler) handleProfitLossReport(chatID int64, fromStr, toStr, format string, messageIDToEdit int) {
+	from, errFrom := time.ParseInLocation("20060102", fromStr, time.Local)
+	to, errTo := time.ParseInLocation("20060102", toStr, time.Local)
+	if errFrom != nil || errTo != nil || to.Before(from) {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Некорректный период отчета.")
+		return
+	}
+
+	report, err := db.GetProfitAndLoss(from, to)
+	if err != nil {
+		log.Printf("handleProfitLossReport: ошибка расчета P&L за %s - %s: %v", fromStr, toStr, err)
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось рассчитать прибыли и убытки.")
+		return
+	}
+
+	if format == statements.FormatXLSX {
+		content, errRender := statements.RenderProfitLossXLSX(report)
+		if errRender != nil {
+			log.Printf("handleProfitLossReport: ошибка формирования XLSX за %s - %s: %v", fromStr, toStr, errRender)
+			bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось сформировать файл отчета.")
+			return
+		}
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: statements.ProfitLossFileName(report), Bytes: content})
+		doc.Caption = fmt.Sprintf("%s\nПрибыль: %.0f ₽", statements.ProfitLossTitle(report), report.Total.Profit)
+		if _, errSend := bh.Deps.BotClient.Send(doc); errSend != nil {
+			log.Printf("handleProfitLossReport: ошибка отправки XLSX в чат %d: %v", chatID, errSend)
+			bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка при отправке файла отчета.")
+		}
+		return
+	}
+	bh.SendProfitLossReport(chatID, report, messageIDToEdit)
+}
+
+// SendProfitLossReport отображает P&L: итоги по статьям, категории и месяцы с изменением к предыдущему периоду.
+func (bh *BotHandler) SendProfitLossReport(chatID int64, report models.ProfitLossReport, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_STATS_MENU)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📒 *Прибыли и убытки за %s - %s*\n", report.From.Format("02.01.2006"), report.To.Format("02.01.2006")))
+	sb.WriteString(fmt.Sprintf("_Сравнение с %s - %s_\n\n", report.PreviousFrom.Format("02.01.2006"), report.PreviousTo.Format("02.01.2006")))
+
+	total := report.Total
+	sb.WriteString(fmt.Sprintf("📦 Заказов: *%d*%s\n", total.OrdersCount, formatPercentChange(report.OrdersChangePct)))
+	sb.WriteString(fmt.Sprintf("💰 Выручка: *%.0f ₽*%s\n", total.Revenue, formatPercentChange(report.RevenueChangePct)))
+	sb.WriteString(fmt.Sprintf("📉 Расходы: *%.0f ₽*%s\n", total.Expenses, formatPercentChange(report.ExpensesChangePct)))
+	sb.WriteString(fmt.Sprintf("  ⛽ Топливо: %.0f ₽\n", total.Fuel))
+	sb.WriteString(fmt.Sprintf("  🧾 Прочие расходы: %.0f ₽\n", total.OtherExpenses))
+	sb.WriteString(fmt.Sprintf("  💪 ЗП грузчиков: %.0f ₽\n", total.LoaderSalaries))
+	sb.WriteString(fmt.Sprintf("  🚚 Доля водителей: %.0f ₽\n", total.DriverShare))
+	sb.WriteString(fmt.Sprintf("  🎁 Реферальные бонусы: %.0f ₽\n", total.ReferralBonuses))
+	sb.WriteString(fmt.Sprintf("  ♻️ Утилизация: %.0f ₽\n", total.Disposal))
+	sb.WriteString(fmt.Sprintf("📈 Прибыль: *%.0f ₽*%s\n", total.Profit, formatPercentChange(report.ProfitChangePct)))
+
+	if len(report.Categories) > 0 {
+		sb.WriteString("\n🗂️ *По категориям (выручка / прибыль):*\n")
+		for i, c := range report.Categories {
+			if i == profitLossMaxCategories {
+				sb.WriteString(fmt.Sprintf("  _...и еще %d, полный список в XLSX_\n", len(report.Categories)-profitLossMaxCategories))
+				break
+			}
+			name := statements.ProfitLossCategoryName(c.Category)
+			if sub := statements.ProfitLossSubcategoryName(c.Category, c.Subcategory); sub != "" {
+				name += " / " + sub
+			}
+			sb.WriteString(fmt.Sprintf("  %s: %.0f / %.0f ₽%s\n", utils.EscapeTelegramMarkdown(name),
+				c.Current.Revenue, c.Current.Profit, formatPercentChange(c.ProfitChangePct)))
+		}
+	}
+
+	months := make(map[time.Time]*models.ProfitLossAmounts)
+	var monthOrder []time.Time
+	for _, line := range report.Lines {
+		if months[line.Month] == nil {
+			months[line.Month] = &models.ProfitLossAmounts{}
+			monthOrder = append(monthOrder, line.Month)
+		}
+		months[line.Month].Add(line.ProfitLossAmounts)
+	}
+	if len(monthOrder) > 1 {
+		sb.WriteString("\n📅 *По месяцам (выручка / расходы / прибыль):*\n")
+		for _, month := range monthOrder {
+			m := months[month]
+			sb.WriteString(fmt.Sprintf("  %s: %.0f / %.0f / %.0f ₽\n", month.Format("01.2006"), m.Revenue, m.Expenses, m.Profit))
+		}
+	}
+	if total.OrdersCount == 0 {
+		sb.WriteString("\nЗа период нет выполненных заказов.\n")
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Предыдущий период", profitLossCallback(report.PreviousFrom, report.PreviousTo, profitLossFormatView)),
+			tgbotapi.NewInlineKeyboardButtonData("📊 XLSX", profitLossCallback(report.From, report.To, statements.FormatXLSX)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад в меню статистики", "stats_menu"),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendProfitLossReport: ошибка для chatID %d: %v", chatID, err)
+	}
+}
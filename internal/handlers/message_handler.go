@@ -5,6 +5,7 @@ package handlers
 import (
 	"Original/internal/constants" //
 	"Original/internal/db"
+	"Original/internal/formatters"
 	"Original/internal/models"
 	"Original/internal/utils" //
 	"database/sql"
@@ -32,6 +33,11 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 	log.Printf("HandleMessage: ChatID=%d, UserMessageID=%d, Text='%s', MediaGroupID='%s', Photo: %v, Video: %v, Document: %v, Location: %v, Contact: %v",
 		chatID, userMessageID, text, message.MediaGroupID, message.Photo != nil, message.Video != nil, message.Document != nil, message.Location != nil, message.Contact != nil)
 
+	if message.SuccessfulPayment != nil {
+		bh.handleSuccessfulPayment(message)
+		return
+	}
+
 	user, userExists := bh.getUserFromDB(chatID)
 	if !userExists {
 		if message.IsCommand() && message.Command() == "start" {
@@ -65,6 +71,12 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 				return
 			}
 			user = registeredUser
+			// Переход по реферальной ссылке: запоминаем пригласившего до первого заказа
+			if inviterChatID, ok := utils.ParseReferralStartPayload(message.CommandArguments()); ok {
+				if _, errRef := db.SetUserReferrer(chatID, inviterChatID); errRef != nil {
+					log.Printf("HandleMessage: /start: Ошибка привязки пригласившего %d для chatID %d: %v", inviterChatID, chatID, errRef)
+				}
+			}
 
 			tempOrderDataForStart := bh.Deps.SessionManager.GetTempOrder(chatID)
 			currentMenuMsgIDBeforeStart := tempOrderDataForStart.CurrentMessageID
@@ -117,6 +129,13 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 	tempOrderForMenuID := bh.Deps.SessionManager.GetTempOrder(chatID)
 	botMenuMsgID = tempOrderForMenuID.CurrentMessageID
 
+	// Фото чеков в инлайн-отчете водителя, в том числе альбомом
+	if len(message.Photo) > 0 && isDriverReportReceiptState(currentState) {
+		bh.handleDriverReportReceiptPhoto(chatID, user, currentState, message.Photo[len(message.Photo)-1].FileID, message.Caption, botMenuMsgID)
+		bh.deleteMessageHelper(chatID, userMessageID)
+		return
+	}
+
 	isAlbumItem := message.MediaGroupID != "" && (message.Photo != nil || message.Video != nil)
 
 	shouldProcessThisAlbumItem := false
@@ -176,9 +195,9 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 		}
 		bh.deleteMessageHelper(chatID, userMessageID)
 
-		finalCost, err := strconv.ParseFloat(strings.Replace(text, ",", ".", -1), 64)
-		if err != nil || finalCost < 0 {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Неверный формат стоимости. Введите целое число (например, 123) или число с точкой (123.45).")
+		finalCost, finalCostItems, err := utils.ParseCostBreakdown(text)
+		if err != nil {
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ Неверный формат стоимости: %v. Введите целое число (например, 123), число с точкой (123.45) или расшифровку по статьям.", err))
 			return
 		}
 
@@ -188,14 +207,24 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 		errUpdate := db.UpdateOrderField(int64(orderID), "cost", finalCost)
 		if errUpdate != nil {
 			log.Printf("handleFinalCostInput: Ошибка обновления итоговой стоимости для заказа #%d: %v", orderID, errUpdate)
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Ошибка сохранения новой стоимости.")
+			bh.sendErrorMessageHelper(chatID, botMenuMsgID, periodErrorText(errUpdate, "❌ Ошибка сохранения новой стоимости."))
 			return
 		}
+		if errItems := db.ReplaceOrderCostItems(int64(orderID), finalCostItems); errItems != nil {
+			log.Printf("handleFinalCostInput: Ошибка сохранения расшифровки стоимости для заказа #%d: %v", orderID, errItems)
+		}
 		log.Printf("Итоговая стоимость для заказа #%d обновлена на %.0f оператором %d", orderID, finalCost, chatID)
 		bh.sendInfoMessage(chatID, botMenuMsgID, fmt.Sprintf("✅ Итоговая стоимость для заказа №%d обновлена на %.0f ₽.", orderID, finalCost), fmt.Sprintf("view_order_ops_%d", orderID))
 		bh.Deps.SessionManager.ClearState(chatID)
 		bh.SendViewOrderDetails(chatID, orderID, botMenuMsgID, true, user)
 
+	case constants.STATE_RECEIPT_EMAIL_INPUT:
+		bh.handleReceiptEmailInput(chatID, user, text, userMessageID, botMenuMsgID)
+
+	case constants.STATE_ORDER_VOLUME, constants.STATE_ORDER_TONNAGE, constants.STATE_ORDER_FLOOR,
+		constants.STATE_ORDER_ELEVATOR, constants.STATE_ORDER_LOADERS:
+		bh.handleOrderDetailTextInput(chatID, currentState, text, userMessageID, botMenuMsgID)
+
 	case constants.STATE_ORDER_DESCRIPTION:
 		bh.handleOrderDescriptionInput(chatID, user, text, userMessageID, botMenuMsgID)
 
@@ -292,8 +321,8 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 				}
 				bh.SendEditOrderMenu(chatID, botMenuMsgID)
 			} else {
-				log.Printf("Переход к вводу телефона после ввода времени %s. ChatID=%d", tempOrder.Time, chatID)
-				bh.SendPhoneInputMenu(chatID, user, botMenuMsgID)
+				log.Printf("Переход к следующему шагу после ввода времени %s. ChatID=%d", tempOrder.Time, chatID)
+				bh.continueOrderAfterDateTime(chatID, user, botMenuMsgID)
 			}
 		} else {
 			log.Printf("HandleMessage: STATE_ORDER_TIME, неверный формат времени: '%s'. Повторный запрос.", text)
@@ -331,8 +360,8 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 				}
 				bh.SendEditOrderMenu(chatID, botMenuMsgID)
 			} else {
-				log.Printf("Переход к вводу телефона после ввода времени %s. ChatID=%d", tempOrder.Time, chatID)
-				bh.SendPhoneInputMenu(chatID, user, botMenuMsgID)
+				log.Printf("Переход к следующему шагу после ввода времени %s. ChatID=%d", tempOrder.Time, chatID)
+				bh.continueOrderAfterDateTime(chatID, user, botMenuMsgID)
 			}
 		} else {
 			log.Printf("HandleMessage: STATE_ORDER_MINUTE_SELECTION, неверный формат времени: '%s'. Повторный запрос.", text)
@@ -354,6 +383,8 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 		bh.handlePhoneAwaitInput(chatID, user, phoneInput, userMessageID, botMenuMsgID)
 	case constants.STATE_COST_INPUT:
 		bh.handleCostInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_QUOTE_OPTIONS_INPUT:
+		bh.handleQuoteOptionsInput(chatID, user, text, userMessageID, botMenuMsgID)
 	case constants.STATE_CANCEL_REASON:
 		bh.handleCancelReasonInput(chatID, user, text, userMessageID, botMenuMsgID)
 
@@ -411,84 +442,61 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 			bh.SendDriverReportOtherExpenseAmountPrompt(chatID, user, botMenuMsgID, tempData.TempOtherExpenseDescription, false, -1)
 			return
 		}
-		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-		if tempData.TempOtherExpenseDescription == "" {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Ошибка: описание для прочего расхода не найдено. Начните добавление заново.")
-			bh.SendDriverReportOtherExpensesMenu(chatID, user, botMenuMsgID)
-			return
-		}
-		tempData.OtherExpenses = append(tempData.OtherExpenses, models.OtherExpenseDetail{
-			Description: tempData.TempOtherExpenseDescription,
-			Amount:      amount,
-		})
-		addedDesc := tempData.TempOtherExpenseDescription
-		tempData.TempOtherExpenseDescription = ""
-		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
-		bh.SendDriverReportConfirmAddOtherExpense(chatID, user, botMenuMsgID, addedDesc, amount)
+		bh.addDriverReportOtherExpense(chatID, user, amount, botMenuMsgID)
 
 	case constants.STATE_DRIVER_REPORT_INPUT_LOADER_NAME:
 		bh.deleteMessageHelper(chatID, userMessageID)
-		loaderName := strings.TrimSpace(text)
-		if loaderName == "" {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Имя/идентификатор грузчика не может быть пустым. Попробуйте снова.")
-			return
-		}
 		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-		tempData.TempLoaderNameInput = loaderName
+		tempData.TempLoaderNameInput = strings.TrimSpace(text)
 		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
-		bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, botMenuMsgID, loaderName, false, -1)
+		bh.SendDriverReportLoaderNameInputPrompt(chatID, user, botMenuMsgID)
 
-	case constants.STATE_DRIVER_REPORT_INPUT_LOADER_SALARY:
+	case constants.STATE_DRIVER_REPORT_INPUT_LOADER_SALARY, constants.STATE_DRIVER_REPORT_EDIT_LOADER_SALARY:
 		bh.deleteMessageHelper(chatID, userMessageID)
-		salary, err := strconv.ParseFloat(strings.Replace(text, ",", ".", -1), 64)
-		if err != nil || salary <= 0 {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Сумма зарплаты грузчика должна быть положительным числом. Попробуйте снова.")
-			tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-			bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, botMenuMsgID, tempData.TempLoaderNameInput, false, -1)
-			return
-		}
-		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-		if tempData.TempLoaderNameInput == "" {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Ошибка: не найдено имя для нового грузчика. Начните добавление заново.")
-			bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
-			return
-		}
-		tempData.LoaderPayments = append(tempData.LoaderPayments, models.LoaderPaymentDetail{
-			LoaderIdentifier: tempData.TempLoaderNameInput,
-			Amount:           salary,
-		})
-		tempData.TempLoaderNameInput = ""
-		tempData.EditingLoaderIndex = -1
-		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
-		bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
+		bh.handleDriverReportLoaderSalaryInput(chatID, user, text, botMenuMsgID)
 
-	case constants.STATE_DRIVER_REPORT_EDIT_LOADER_SALARY:
+	case constants.STATE_DRIVER_REPORT_INPUT_LOADER_QUANTITIES:
 		bh.deleteMessageHelper(chatID, userMessageID)
-		salary, err := strconv.ParseFloat(strings.Replace(text, ",", ".", -1), 64)
-		if err != nil || salary <= 0 {
-			tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Сумма зарплаты грузчика должна быть положительным числом. Попробуйте снова.")
-			if tempData.EditingLoaderIndex >= 0 && tempData.EditingLoaderIndex < len(tempData.LoaderPayments) {
-				loaderToEdit := tempData.LoaderPayments[tempData.EditingLoaderIndex]
-				bh.SendDriverReportLoaderSalaryInputPrompt(chatID, user, botMenuMsgID, loaderToEdit.LoaderIdentifier, true, tempData.EditingLoaderIndex)
-			} else {
-				bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
-			}
-			return
-		}
-		tempData := bh.Deps.SessionManager.GetTempDriverSettlement(chatID)
-		if tempData.EditingLoaderIndex < 0 || tempData.EditingLoaderIndex >= len(tempData.LoaderPayments) {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "Ошибка: не выбран грузчик для редактирования зарплаты.")
-			bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
-			return
-		}
-		tempData.LoaderPayments[tempData.EditingLoaderIndex].Amount = salary
-		tempData.EditingLoaderIndex = -1
-		bh.Deps.SessionManager.UpdateTempDriverSettlement(chatID, tempData)
-		bh.SendDriverReportLoadersSubMenu(chatID, user, botMenuMsgID)
+		bh.handleDriverReportLoaderQuantitiesInput(chatID, user, text, botMenuMsgID)
+
+	case constants.STATE_DRIVER_REPORT_INPUT_ODOMETER:
+		bh.deleteMessageHelper(chatID, userMessageID)
+		bh.handleDriverReportOdometerInput(chatID, user, text, botMenuMsgID)
 
 	case constants.STATE_OWNER_CASH_EDIT_SETTLEMENT_FIELD:
 		bh.handleOwnerSaveEditedSettlementFieldInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_COMP_RULE_INPUT:
+		bh.handleOwnerCompensationRuleInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_LOADER_RATE_INPUT:
+		bh.handleOwnerLoaderRateInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_STATEMENT_PERIOD_INPUT:
+		bh.handleStatementPeriodInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_PAYROLL_LINE_INPUT:
+		bh.handleOwnerPayrollLineInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_PAYROLL_REVERSE_INPUT:
+		bh.handleOwnerPayrollReverseInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_VEHICLE_INPUT:
+		bh.handleOwnerVehicleInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_VEHICLE_DOCS_INPUT:
+		bh.handleOwnerVehicleDocsInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_VEHICLE_MAINT_INPUT:
+		bh.handleOwnerVehicleMaintenanceInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_CASH_HANDOVER_INPUT:
+		bh.handleOwnerCashHandoverInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_CASH_DISCREPANCY_RESOLVE:
+		bh.handleOwnerResolveDiscrepancyInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_DRIVER_CASH_HANDOVER_DISPUTE:
+		bh.handleDriverCashHandoverDisputeInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_REFERRAL_PAYOUT_CARD, constants.STATE_REFERRAL_PAYOUT_SBP:
+		bh.handleReferralPayoutDetailsInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_REFERRAL_REJECT:
+		bh.handleOwnerReferralPayoutRejectInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_PERIOD_REOPEN_REASON:
+		bh.handleOwnerPeriodReopenInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_PRICE_RULE_INPUT:
+		bh.handleOwnerPriceRuleInput(chatID, user, text, userMessageID, botMenuMsgID)
+	case constants.STATE_OWNER_REFERRAL_RULE_INPUT:
+		bh.handleOwnerReferralRuleInput(chatID, user, text, userMessageID, botMenuMsgID)
 	case constants.STATE_OPERATOR_REJECT_REASON_INPUT:
 		bh.deleteMessageHelper(chatID, userMessageID)
 		if !utils.IsOperatorOrHigher(user.Role) {
@@ -512,35 +520,7 @@ func (bh *BotHandler) HandleMessage(update tgbotapi.Update) {
 		bh.handleOperatorFinalizeRejection(chatID, user, settlementID, rejectionReason, botMenuMsgID)
 	case constants.STATE_OP_ORDER_COST_INPUT:
 		bh.deleteMessageHelper(chatID, userMessageID)
-		tempData := bh.Deps.SessionManager.GetTempOrder(chatID)
-		isOperatorFlow := tempData.OrderAction == "operator_creating_order" && utils.IsOperatorOrHigher(user.Role)
-		isDriverFlow := tempData.OrderAction == "driver_creating_order" && user.Role == constants.ROLE_DRIVER
-
-		if !isOperatorFlow && !isDriverFlow {
-			log.Printf("HandleMessage: Попытка доступа к STATE_OP_ORDER_COST_INPUT без прав. ChatID: %d, Role: %s, OrderAction: %s", chatID, user.Role, tempData.OrderAction)
-			bh.sendAccessDenied(chatID, botMenuMsgID)
-			return
-		}
-
-		cost, err := strconv.ParseFloat(strings.Replace(text, ",", ".", -1), 64)
-		if err != nil || cost <= 0 {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Стоимость должна быть положительным числом (например, 1500).")
-			bh.SendOpOrderCostInputMenu(chatID, tempData.ID, botMenuMsgID)
-			return
-		}
-
-		orderID := tempData.ID
-		if orderID == 0 {
-			bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Ошибка: ID заказа не определен для установки стоимости.")
-			bh.SendMainMenu(chatID, user, botMenuMsgID)
-			return
-		}
-		tempData.Cost.Float64 = cost
-		tempData.Cost.Valid = true
-		bh.Deps.SessionManager.UpdateTempOrder(chatID, tempData)
-		log.Printf("Пользователь %d (Роль: %s) установил стоимость %.2f для заказа #%d (в процессе создания).", chatID, user.Role, cost, orderID)
-
-		bh.SendAssignExecutorsMenu(chatID, orderID, botMenuMsgID)
+		bh.handleOpOrderCostInput(chatID, user, text, botMenuMsgID)
 
 	default:
 		if text != "" {
@@ -973,9 +953,9 @@ func (bh *BotHandler) handleCostInput(chatID int64, user models.User, costStr st
 		bh.deleteMessageHelper(chatID, userMsgID)
 		return
 	}
-	cost, errConv := strconv.ParseFloat(strings.Replace(costStr, ",", ".", -1), 64)
-	if errConv != nil || cost < 0 {
-		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Стоимость должна быть числом >= 0 (например, 1500 или 1250.5).")
+	cost, costItems, errConv := utils.ParseCostBreakdown(costStr)
+	if errConv != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v. Стоимость должна быть числом >= 0 (например, 1500 или 1250.5) или расшифровкой по статьям.", errConv))
 		bh.deleteMessageHelper(chatID, userMsgID)
 		return
 	}
@@ -989,10 +969,15 @@ func (bh *BotHandler) handleCostInput(chatID int64, user models.User, costStr st
 
 	errDb := db.UpdateOrderCostAndStatus(orderID, cost, constants.STATUS_AWAITING_CONFIRMATION)
 	if errDb != nil {
-		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Ошибка обновления стоимости заказа.")
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, periodErrorText(errDb, "❌ Ошибка обновления стоимости заказа."))
 		bh.deleteMessageHelper(chatID, userMsgID)
 		return
 	}
+	if errItems := db.ReplaceOrderCostItems(orderID, costItems); errItems != nil {
+		log.Printf("handleCostInput: стоимость заказа #%d сохранена, но расшифровка нет: %v", orderID, errItems)
+	}
+	// Стоимость, назначенная вручную, заменяет отправленные клиенту варианты
+	db.CloseOrderQuotes(orderID, constants.QUOTE_STATUS_SUPERSEDED)
 
 	orderForClient, errGetOrder := db.GetOrderByID(int(orderID))
 	if errGetOrder == nil && orderForClient.UserChatID != 0 {
@@ -1033,6 +1018,8 @@ func (bh *BotHandler) handleCancelReasonInput(chatID int64, user models.User, re
 		return
 	}
 
+	db.CloseOrderQuotes(orderID, constants.QUOTE_STATUS_REJECTED)
+
 	bh.deleteMessageHelper(chatID, userMsgID)
 	orderData, _ := db.GetOrderByID(int(orderID))
 
@@ -1041,7 +1028,12 @@ func (bh *BotHandler) handleCancelReasonInput(chatID int64, user models.User, re
 	} else if orderData.UserChatID == chatID {
 		bh.NotifyOperatorsOrderCancelledByClient(orderID, user, reason)
 	}
-	bh.sendInfoMessage(chatID, botMenuMsgID, fmt.Sprintf("❌ Заказ №%d отменён. Причина: %s", orderID, reason), "back_to_main")
+	resultText := fmt.Sprintf("❌ Заказ №%d отменён. Причина: %s", orderID, reason)
+	// Если заказ был оплачен картой, деньги возвращаются клиенту автоматически.
+	if refundNote := bh.RefundAfterCancel(orderID, reason, user); refundNote != "" {
+		resultText += "\n\n" + refundNote
+	}
+	bh.sendInfoMessage(chatID, botMenuMsgID, resultText, "back_to_main")
 	bh.Deps.SessionManager.ClearTempOrder(chatID)
 	bh.Deps.SessionManager.ClearState(chatID)
 }
@@ -1422,7 +1414,11 @@ func (bh *BotHandler) NotifyOperatorsOrderCancelledByClient(orderID int64, clien
 func (bh *BotHandler) SendClientCostConfirmation(clientChatID int64, orderID int, cost float64) {
 	log.Printf("SendClientCostConfirmation: Уведомление клиента %d о стоимости заказа #%d", clientChatID, orderID)
 
-	msgText := fmt.Sprintf("💰 Оператор рассчитал стоимость вашего заказа №%d: *%.0f ₽*.\n\nПожалуйста, подтвердите или отклоните предложенную стоимость.", orderID, cost)
+	breakdown := ""
+	if costItems, errItems := db.GetOrderCostItems(int64(orderID)); errItems == nil {
+		breakdown = formatters.FormatCostBreakdown(costItems)
+	}
+	msgText := fmt.Sprintf("💰 Оператор рассчитал стоимость вашего заказа №%d: *%.0f ₽*.\n%s\nПожалуйста, подтвердите или отклоните предложенную стоимость.", orderID, cost, breakdown)
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Да, согласен (%.0f ₽)", cost), fmt.Sprintf("accept_cost_%d", orderID)),
@@ -1443,3 +1439,40 @@ func (bh *BotHandler) SendClientCostConfirmation(clientChatID int64, orderID int
 		log.Printf("SendClientCostConfirmation: Ошибка отправки уведомления клиенту %d: %v", clientChatID, err)
 	}
 }
+
+// handleOpOrderCostInput сохраняет стоимость, введенную оператором или водителем при создании заказа,
+// и переходит к назначению исполнителей.
+func (bh *BotHandler) handleOpOrderCostInput(chatID int64, user models.User, text string, botMenuMsgID int) {
+	tempData := bh.Deps.SessionManager.GetTempOrder(chatID)
+	isOperatorFlow := tempData.OrderAction == "operator_creating_order" && utils.IsOperatorOrHigher(user.Role)
+	isDriverFlow := tempData.OrderAction == "driver_creating_order" && user.Role == constants.ROLE_DRIVER
+
+	if !isOperatorFlow && !isDriverFlow {
+		log.Printf("handleOpOrderCostInput: Попытка доступа без прав. ChatID: %d, Role: %s, OrderAction: %s", chatID, user.Role, tempData.OrderAction)
+		bh.sendAccessDenied(chatID, botMenuMsgID)
+		return
+	}
+
+	cost, costItems, err := utils.ParseCostBreakdown(text)
+	if err != nil || cost <= 0 {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Стоимость должна быть положительным числом (например, 1500) или расшифровкой по статьям.")
+		bh.SendOpOrderCostInputMenu(chatID, tempData.ID, botMenuMsgID)
+		return
+	}
+
+	orderID := tempData.ID
+	if orderID == 0 {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ Ошибка: ID заказа не определен для установки стоимости.")
+		bh.SendMainMenu(chatID, user, botMenuMsgID)
+		return
+	}
+	tempData.Cost.Float64 = cost
+	tempData.Cost.Valid = true
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempData)
+	if errItems := db.ReplaceOrderCostItems(orderID, costItems); errItems != nil {
+		log.Printf("handleOpOrderCostInput: Ошибка сохранения расшифровки стоимости для заказа #%d: %v", orderID, errItems)
+	}
+	log.Printf("Пользователь %d (Роль: %s) установил стоимость %.2f для заказа #%d (в процессе создания).", chatID, user.Role, cost, orderID)
+
+	bh.SendAssignExecutorsMenu(chatID, orderID, botMenuMsgID)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// staleOrderRule - срок и причина автоотмены для статуса, в котором заказ ждет действия клиента.
+type staleOrderRule struct {
+	status   string
+	deadline time.Duration
+	reason   string
+}
+
+func (bh *BotHandler) staleOrderRules() []staleOrderRule {
+	return []staleOrderRule{
+		{status: constants.STATUS_AWAITING_CONFIRMATION, deadline: bh.Deps.Config.ConfirmationExpiry, reason: constants.OrderExpiredConfirmationReason},
+		{status: constants.STATUS_AWAITING_PAYMENT, deadline: bh.Deps.Config.PaymentExpiry, reason: constants.OrderExpiredPaymentReason},
+	}
+}
+
+// CheckStaleOrders напоминает клиентам о заказах, которые скоро будут отменены без их ответа,
+// и отменяет заказы с истекшим сроком подтверждения стоимости или оплаты.
+func (bh *BotHandler) CheckStaleOrders() {
+	remindBefore := bh.Deps.Config.ExpiryReminderBefore
+	for _, rule := range bh.staleOrderRules() {
+		if rule.deadline <= 0 {
+			continue
+		}
+		if remindBefore > 0 {
+			toRemind, err := db.GetOrdersForExpiryReminder(rule.status, rule.deadline, remindBefore)
+			if err != nil {
+				log.Printf("[STALE_ORDERS] Ошибка выборки заказов '%s' для напоминания: %v", rule.status, err)
+			}
+			for _, order := range toRemind {
+				bh.sendOrderExpiryReminder(order, rule.deadline, remindBefore)
+			}
+		}
+
+		expired, err := db.GetExpiredStaleOrders(rule.status, rule.deadline, remindBefore)
+		if err != nil {
+			log.Printf("[STALE_ORDERS] Ошибка выборки просроченных заказов '%s': %v", rule.status, err)
+			continue
+		}
+		for _, order := range expired {
+			bh.expireStaleOrder(order, rule.reason)
+		}
+	}
+}
+
+// sendOrderExpiryReminder предупреждает клиента, что заказ будет отменен, если он не подтвердит стоимость или не оплатит.
+func (bh *BotHandler) sendOrderExpiryReminder(order models.StaleOrder, deadline, remindBefore time.Duration) {
+	if errMark := db.MarkOrderExpiryReminderSent(order.OrderID); errMark != nil {
+		return
+	}
+	if order.UserChatID == 0 {
+		return
+	}
+	// Отмена возможна не раньше срока заказа и не раньше чем через remindBefore после напоминания
+	cancelAt := order.LastActivityAt.Add(deadline)
+	if minCancelAt := time.Now().Add(remindBefore); cancelAt.Before(minCancelAt) {
+		cancelAt = minCancelAt
+	}
+
+	var text string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if order.Status == constants.STATUS_AWAITING_PAYMENT {
+		text = fmt.Sprintf("⏳ Заказ №%d ждет оплаты. Если оплата не поступит до %s, заказ будет автоматически отменен.",
+			order.OrderID, cancelAt.Format("02.01.2006 15:04"))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить заказ", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_PAY_ORDER, order.OrderID)),
+		))
+	} else {
+		text = fmt.Sprintf("⏳ Заказ №%d ждет вашего подтверждения стоимости. Если не ответить до %s, заказ будет автоматически отменен.",
+			order.OrderID, cancelAt.Format("02.01.2006 15:04"))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("📋 Просмотреть детали заказа", fmt.Sprintf("view_order_%d", order.OrderID)),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendMessageWithKeyboard(order.UserChatID, text, &keyboard); err != nil {
+		log.Printf("[STALE_ORDERS] Ошибка отправки напоминания по заказу #%d клиенту %d: %v", order.OrderID, order.UserChatID, err)
+	}
+}
+
+// expireStaleOrder отменяет заказ с системной причиной, аннулирует его незавершенные платежи
+// и сообщает об отмене клиенту и операторам.
+func (bh *BotHandler) expireStaleOrder(order models.StaleOrder, reason string) {
+	// Заказ мог измениться между выборкой и отменой
+	current, err := db.GetOrderByID(int(order.OrderID))
+	if err != nil || current.Status != order.Status || current.UpdatedAt.After(order.LastActivityAt) {
+		log.Printf("[STALE_ORDERS] Заказ #%d изменился после выборки, автоотмена пропущена.", order.OrderID)
+		return
+	}
+	if err = db.UpdateOrderStatusAndReason(order.OrderID, constants.STATUS_CANCELED, sql.NullString{String: reason, Valid: true}); err != nil {
+		log.Printf("[STALE_ORDERS] Ошибка автоотмены заказа #%d: %v", order.OrderID, err)
+		return
+	}
+	canceledPayments, _ := db.CancelOpenOrderPayments(order.OrderID)
+	log.Printf("[STALE_ORDERS] Заказ #%d (%s) отменен автоматически, аннулировано платежей: %d.", order.OrderID, order.Status, canceledPayments)
+
+	if order.UserChatID != 0 {
+		bh.sendMessage(order.UserChatID, fmt.Sprintf("⚠️ Заказ №%d отменен. Причина: %s.\nЕсли заказ еще актуален, оформите его заново или свяжитесь с оператором.",
+			order.OrderID, reason))
+	}
+	operatorText := fmt.Sprintf("🗑 Заказ №%d отменен автоматически (статус был '%s'). Причина: %s.",
+		order.OrderID, constants.StatusDisplayMap[order.Status], reason)
+	if canceledPayments > 0 {
+		operatorText += "\nСсылка на оплату аннулирована."
+	}
+	bh.NotifyOperatorsAndGroup(operatorText)
+}
+
+// RunStaleOrderExpiry периодически запускает CheckStaleOrders. Блокирует вызывающую горутину.
+func (bh *BotHandler) RunStaleOrderExpiry(interval time.Duration) {
+	log.Printf("[STALE_ORDERS] Проверка зависших заказов запущена с периодом %s (подтверждение: %s, оплата: %s, напоминание за %s).",
+		interval, bh.Deps.Config.ConfirmationExpiry, bh.Deps.Config.PaymentExpiry, bh.Deps.Config.ExpiryReminderBefore)
+	bh.CheckStaleOrders()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bh.CheckStaleOrders()
+	}
+}
@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// SendQuoteOptionsPrompt запрашивает у оператора 2-3 варианта стоимости заказа, по одному в строке.
+func (bh *BotHandler) SendQuoteOptionsPrompt(chatID int64, orderID int64, messageIDToEdit int) {
+	log.Printf("SendQuoteOptionsPrompt: оператор %d готовит варианты стоимости для заказа #%d", chatID, orderID)
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_QUOTE_OPTIONS_INPUT)
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	tempOrder.ID = orderID
+	tempOrder.QuoteOptions = nil
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+
+	msgText := fmt.Sprintf("📑 Введите от %d до %d вариантов стоимости для заказа №%d, каждый с новой строки:\n"+
+		"`<название> - <сумма> - <водителей>/<грузчиков>`\n\n"+
+		"Например:\n`Газель, 2 грузчика - 6000 - 1/2`\n`КамАЗ без грузчиков - 9000 - 1/0`\n\n"+
+		"Состав можно не указывать - тогда нужен один водитель без грузчиков.",
+		constants.MinQuoteOptions, constants.MaxQuoteOptions, orderID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", fmt.Sprintf("set_cost_%d", orderID)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendQuoteOptionsPrompt: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleQuoteOptionsInput разбирает варианты, введенные оператором, и предлагает выбрать срок ответа клиента.
+func (bh *BotHandler) handleQuoteOptionsInput(chatID int64, user models.User, text string, userMsgID int, botMenuMsgID int) {
+	bh.deleteMessageHelper(chatID, userMsgID)
+	if !utils.IsOperatorOrHigher(user.Role) {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, constants.AccessDeniedMessage)
+		return
+	}
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	if tempOrder.ID == 0 {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, "❌ ID заказа не найден для отправки вариантов.")
+		return
+	}
+	options, err := utils.ParseQuoteOptions(text)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, botMenuMsgID, fmt.Sprintf("❌ %v.", err))
+		bh.SendQuoteOptionsPrompt(chatID, tempOrder.ID, bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID)
+		return
+	}
+	tempOrder.QuoteOptions = options
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+	bh.SendQuoteValidityMenu(chatID, tempOrder.ID, botMenuMsgID)
+}
+
+// SendQuoteValidityMenu показывает оператору разобранные варианты и кнопки срока, до которого клиент может выбрать.
+func (bh *BotHandler) SendQuoteValidityMenu(chatID int64, orderID int64, messageIDToEdit int) {
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	if len(tempOrder.QuoteOptions) == 0 {
+		bh.SendQuoteOptionsPrompt(chatID, orderID, messageIDToEdit)
+		return
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📑 Варианты для заказа №%d:\n\n", orderID))
+	sb.WriteString(formatQuoteOptions(tempOrder.QuoteOptions))
+	sb.WriteString("\n⏳ Сколько клиент может думать? Если он не выберет вариант в срок, заказ вернется в новые.")
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, hours := range constants.QuoteValidityHoursOptions {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(formatQuoteValidity(hours),
+			fmt.Sprintf("%s_%d_%d", constants.CALLBACK_PREFIX_QUOTE_TTL, orderID, hours)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить варианты", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_QUOTE_NEW, orderID))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", fmt.Sprintf("set_cost_%d", orderID))),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendQuoteValidityMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleQuoteSend сохраняет предложение с выбранным сроком ответа и отправляет варианты клиенту.
+func (bh *BotHandler) handleQuoteSend(chatID int64, user models.User, orderID int64, hours int, messageIDToEdit int) {
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	if tempOrder.ID != orderID || len(tempOrder.QuoteOptions) == 0 {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Варианты не найдены. Введите их заново.")
+		bh.SendQuoteOptionsPrompt(chatID, orderID, bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID)
+		return
+	}
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Заказ №%d не найден.", orderID))
+		return
+	}
+	if order.UserChatID == 0 {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ У заказа нет клиента в Telegram - варианты некому отправить. Установите стоимость вручную.")
+		return
+	}
+
+	quote, err := db.CreateOrderQuote(orderID, tempOrder.QuoteOptions, time.Now().Add(time.Duration(hours)*time.Hour), user.ID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, periodErrorText(err, "❌ Ошибка сохранения вариантов стоимости."))
+		return
+	}
+	bh.SendClientQuoteOptions(order.UserChatID, quote)
+
+	bh.sendInfoMessage(chatID, messageIDToEdit, fmt.Sprintf("✅ Клиенту отправлено %d варианта стоимости для заказа №%d. Ждем выбор до %s.",
+		len(quote.Options), orderID, quote.ExpiresAt.Format("02.01.2006 15:04")), "manage_orders")
+	bh.Deps.SessionManager.ClearState(chatID)
+	bh.Deps.SessionManager.ClearTempOrder(chatID)
+}
+
+// SendClientQuoteOptions присылает клиенту варианты стоимости: каждый вариант - отдельная кнопка выбора.
+func (bh *BotHandler) SendClientQuoteOptions(clientChatID int64, quote models.OrderQuote) {
+	log.Printf("SendClientQuoteOptions: Уведомление клиента %d о вариантах стоимости заказа #%d", clientChatID, quote.OrderID)
+	msgText := fmt.Sprintf("💰 Оператор подготовил варианты для вашего заказа №%d:\n\n%s\nВыберите подходящий до *%s*. Если ответа не будет, заказ вернется оператору на пересчет.",
+		quote.OrderID, formatQuoteOptions(quote.Options), quote.ExpiresAt.Format("02.01.2006 15:04"))
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, option := range quote.Options {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("✅ %s (%.0f ₽)", option.Title, option.Cost), fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_QUOTE_PICK, option.ID))))
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("❌ Не подходит ни один", fmt.Sprintf("reject_cost_%d", quote.OrderID))),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📋 Просмотреть детали заказа", fmt.Sprintf("view_order_%d", quote.OrderID))),
+	)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendMessageWithKeyboard(clientChatID, msgText, &keyboard); err != nil {
+		log.Printf("SendClientQuoteOptions: Ошибка отправки вариантов клиенту %d: %v", clientChatID, err)
+	}
+}
+
+// handleQuotePick фиксирует выбранный клиентом вариант и продолжает подтверждение заказа
+// так же, как после согласия с единственной стоимостью.
+func (bh *BotHandler) handleQuotePick(chatID int64, user models.User, optionIDStr string, originalMessageID int) int {
+	optionID, err := strconv.ParseInt(optionIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[ORDER_HANDLER] Ошибка: неверный ID варианта в '%s': '%s'. ChatID=%d", constants.CALLBACK_PREFIX_QUOTE_PICK, optionIDStr, chatID)
+		sentMsg, _ := bh.sendErrorMessageHelper(chatID, originalMessageID, "Неверный вариант стоимости.")
+		return sentMsg.MessageID
+	}
+	orderID, err := db.GetOrderIDByQuoteOption(optionID)
+	if err != nil {
+		sentMsg, _ := bh.sendErrorMessageHelper(chatID, originalMessageID, "Вариант стоимости не найден.")
+		return sentMsg.MessageID
+	}
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil || order.UserChatID != chatID {
+		log.Printf("[ORDER_HANDLER] Попытка выбрать вариант #%d не клиентом заказа. ChatID=%d", optionID, chatID)
+		sentMsg, _ := bh.sendErrorMessageHelper(chatID, originalMessageID, "Это действие доступно только клиенту заказа.")
+		return sentMsg.MessageID
+	}
+
+	quote, option, err := db.AcceptOrderQuoteOption(optionID)
+	if err != nil {
+		text := periodErrorText(err, "Ошибка выбора варианта стоимости.")
+		if errors.Is(err, db.ErrQuoteNotPending) {
+			text = "⌛ Эти варианты больше не действуют."
+		}
+		sentMsg, _ := bh.sendErrorMessageHelper(chatID, originalMessageID, text)
+		return sentMsg.MessageID
+	}
+
+	bh.NotifyOperatorsAndGroup(fmt.Sprintf("📑 Клиент %s (ChatID: `%d`) выбрал вариант по заказу №%d: *%s* - %.0f ₽ (%s).",
+		utils.GetUserDisplayName(user), chatID, quote.OrderID, utils.EscapeTelegramMarkdown(option.Title), option.Cost,
+		utils.FormatQuoteComposition(int64(option.Drivers), int64(option.Loaders))))
+	return bh.handleAcceptCost(chatID, user, strconv.FormatInt(quote.OrderID, 10), originalMessageID)
+}
+
+// CheckOrderQuoteExpiry закрывает предложения без ответа клиента, возвращает их заказы в новые
+// и сообщает об этом операторам и клиенту.
+func (bh *BotHandler) CheckOrderQuoteExpiry() {
+	reverted, err := db.ExpireOrderQuotes()
+	if err != nil {
+		log.Printf("[QUOTE] Ошибка обработки истекших предложений: %v", err)
+		return
+	}
+	for _, quote := range reverted {
+		bh.NotifyOperatorsAndGroup(fmt.Sprintf("⌛ Клиент не выбрал вариант стоимости по заказу №%d до %s. Заказ вернулся в '%s' - нужна новая стоимость.",
+			quote.OrderID, quote.ExpiresAt.Format("02.01.2006 15:04"), constants.StatusDisplayMap[constants.STATUS_NEW]))
+		if quote.ClientChatID != 0 {
+			bh.sendMessage(quote.ClientChatID, fmt.Sprintf("⌛ Срок выбора варианта стоимости для заказа №%d истек. Оператор свяжется с вами и пришлет новое предложение.", quote.OrderID))
+		}
+	}
+}
+
+// RunOrderQuoteExpiry периодически запускает CheckOrderQuoteExpiry. Блокирует вызывающую горутину.
+func (bh *BotHandler) RunOrderQuoteExpiry(interval time.Duration) {
+	log.Printf("[QUOTE] Проверка сроков предложений стоимости запущена с периодом %s.", interval)
+	bh.CheckOrderQuoteExpiry()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bh.CheckOrderQuoteExpiry()
+	}
+}
+
+// pendingQuoteSummary - ожидающее ответа предложение заказа для карточки оператора (пустая строка, если его нет).
+func pendingQuoteSummary(orderID int64) string {
+	quote, err := db.GetPendingOrderQuote(orderID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("pendingQuoteSummary: ошибка чтения предложения заказа #%d: %v", orderID, err)
+		}
+		return ""
+	}
+	return fmt.Sprintf("📑 *Варианты отправлены клиенту, ответ до %s:*\n%s", quote.ExpiresAt.Format("02.01.2006 15:04"), formatQuoteOptions(quote.Options))
+}
+
+// formatQuoteOptions - нумерованный список вариантов с суммой и составом исполнителей (Markdown).
+func formatQuoteOptions(options []models.OrderQuoteOption) string {
+	var sb strings.Builder
+	for i, option := range options {
+		sb.WriteString(fmt.Sprintf("%d. %s - *%.0f ₽* (%s)\n", i+1, utils.EscapeTelegramMarkdown(option.Title), option.Cost,
+			utils.FormatQuoteComposition(int64(option.Drivers), int64(option.Loaders))))
+	}
+	return sb.String()
+}
+
+// formatQuoteValidity - подпись кнопки срока ответа: "6 ч", "1 сут.", "2 сут.".
+func formatQuoteValidity(hours int) string {
+	if hours%24 == 0 {
+		return fmt.Sprintf("%d сут.", hours/24)
+	}
+	return fmt.Sprintf("%d ч", hours)
+}
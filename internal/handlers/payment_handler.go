@@ -2,20 +2,76 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"strconv"
+	"time"
 
 	"Original/internal/constants"
 	"Original/internal/db"
+	"Original/internal/models"
 	"Original/internal/payments"
 	"Original/internal/utils"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+	"github.com/google/uuid"
 )
 
-// HandleYooKassaNotification обрабатывает входящие вебхуки от ЮKassa.
+// paymentReconcileMinAge - платежи моложе этого возраста не сверяются: по ним еще ожидается вебхук.
+const paymentReconcileMinAge = 2 * time.Minute
+
+// yooKassaCredentials возвращает параметры доступа к YooKassa из конфигурации.
+func (bh *BotHandler) yooKassaCredentials() payments.Credentials {
+	return payments.Credentials{
+		ShopID:    bh.Deps.Config.YooKassaShopID,
+		SecretKey: bh.Deps.Config.YooKassaSecretKey,
+		APIURL:    bh.Deps.Config.YooKassaAPIURL,
+	}
+}
+
+// paymentProvider возвращает включенного провайдера для способа оплаты заказа.
+func (bh *BotHandler) paymentProvider(method string) (payments.PaymentProvider, bool) {
+	return bh.Deps.PaymentProviders.Get(method)
+}
+
+// EnabledPaymentProviders возвращает способы оплаты, которые можно предложить клиенту.
+func (bh *BotHandler) EnabledPaymentProviders() []payments.PaymentProvider {
+	return bh.Deps.PaymentProviders.Enabled()
+}
+
+// RequiresPrepayment сообщает, должен ли клиент оплатить заказ онлайн до начала работ.
+// Если выбранный в заказе онлайн-способ отключен, заказ оплачивается по выполнению.
+func (bh *BotHandler) RequiresPrepayment(order models.Order) bool {
+	provider, ok := bh.paymentProvider(order.Payment)
+	return ok && provider.Prepaid()
+}
+
+// yooKassaProvider возвращает провайдера, работающего через API YooKassa (карта или СБП).
+func (bh *BotHandler) yooKassaProvider() (payments.PaymentProvider, bool) {
+	if provider, ok := bh.paymentProvider(constants.PAYMENT_PROVIDER_YOOKASSA); ok {
+		return provider, true
+	}
+	return bh.paymentProvider(constants.PAYMENT_PROVIDER_SBP)
+}
+
+// findYooKassaPaymentRecord ищет платеж YooKassa в журнале: он мог быть создан как оплата картой или через СБП.
+func findYooKassaPaymentRecord(externalID string) (models.Payment, error) {
+	record, err := db.GetPaymentByExternalID(constants.PAYMENT_PROVIDER_YOOKASSA, externalID)
+	if err == sql.ErrNoRows {
+		return db.GetPaymentByExternalID(constants.PAYMENT_PROVIDER_SBP, externalID)
+	}
+	return record, err
+}
+
+// HandleYooKassaNotification обрабатывает входящие вебхуки от ЮKassa (оплата картой и через СБП).
+// Тело уведомления не считается достоверным: актуальное состояние платежа запрашивается у API,
+// после чего обновляется журнал платежей и, при успешной оплате, статус заказа.
 func (bh *BotHandler) HandleYooKassaNotification(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		log.Printf("[YOOKASSA_HANDLER] Получен не-POST запрос: %s", r.Method)
@@ -33,81 +89,420 @@ func (bh *BotHandler) HandleYooKassaNotification(w http.ResponseWriter, r *http.
 
 	log.Printf("[YOOKASSA_HANDLER] Получено уведомление: %s", string(body))
 
-	var notification payments.YooKassaNotification
-	if err := json.Unmarshal(body, &notification); err != nil {
-		log.Printf("[YOOKASSA_HANDLER] Ошибка демаршалинга JSON: %v", err)
-		http.Error(w, "Invalid JSON format", http.StatusBadRequest)
+	provider, ok := bh.yooKassaProvider()
+	if !ok {
+		log.Printf("[YOOKASSA_HANDLER] Оплата через YooKassa не включена. Уведомление проигнорировано.")
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Проверяем, что это уведомление об успешном платеже
-	if notification.Event == "payment.succeeded" {
-		log.Printf("[YOOKASSA_HANDLER] Обработка успешного платежа. PaymentID: %s", notification.Object.ID)
+	event, err := provider.ParseWebhook(body)
+	if err != nil {
+		log.Printf("[YOOKASSA_HANDLER] Не удалось разобрать уведомление: %v", err)
+		// Отвечаем 200 OK, чтобы ЮKassa не повторяла запрос
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-		// Извлекаем ID заказа из метаданных
-		var metadata map[string]string
-		if err := json.Unmarshal(notification.Object.Metadata, &metadata); err != nil {
-			log.Printf("[YOOKASSA_HANDLER] Ошибка парсинга метаданных для PaymentID %s: %v", notification.Object.ID, err)
-			// Отвечаем 200 OK, чтобы ЮKassa не повторяла запрос
+	// Запрашиваем актуальное состояние платежа у API, а не доверяем телу уведомления.
+	providerPayment, err := provider.GetStatus(event.PaymentExternalID)
+	if err != nil {
+		log.Printf("[YOOKASSA_HANDLER] Ошибка получения платежа %s из API: %v", event.PaymentExternalID, err)
+		// Просим ЮKassa повторить уведомление позже
+		http.Error(w, "Failed to verify payment", http.StatusInternalServerError)
+		return
+	}
+
+	record, err := findYooKassaPaymentRecord(event.PaymentExternalID)
+	if err == sql.ErrNoRows {
+		// Платеж создан до появления журнала платежей: определяем заказ по метаданным.
+		orderID, ok := orderIDFromPaymentMetadata(providerPayment)
+		if !ok {
+			log.Printf("[YOOKASSA_HANDLER] Платеж %s не найден в журнале и не содержит order_id. Игнорируется.", event.PaymentExternalID)
 			w.WriteHeader(http.StatusOK)
 			return
 		}
+		// Платеж без записи в журнале нельзя отследить между уведомлениями, поэтому автовозврат по нему не делаем
+		if providerPayment.Status == payments.StatusSucceeded {
+			bh.applySucceededPayment(orderID, false)
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to load payment", http.StatusInternalServerError)
+		return
+	}
 
-		orderIDStr, ok := metadata["order_id"]
-		if !ok {
-			log.Printf("[YOOKASSA_HANDLER] В метаданных для PaymentID %s отсутствует order_id.", notification.Object.ID)
-			w.WriteHeader(http.StatusOK)
+	db.SavePaymentWebhookPayload(record.ID, body)
+	if errSync := bh.syncProviderPayment(record, providerPayment); errSync != nil {
+		// Здесь можно было бы вернуть 500, чтобы ЮKassa попробовала снова
+		http.Error(w, "Failed to update payment", http.StatusInternalServerError)
+		return
+	}
+
+	if event.RefundExternalID != "" {
+		if errRefund := bh.syncYooKassaRefund(event.RefundExternalID); errRefund != nil {
+			log.Printf("[YOOKASSA_HANDLER] Ошибка обновления возврата %s: %v", event.RefundExternalID, errRefund)
+			http.Error(w, "Failed to update refund", http.StatusInternalServerError)
 			return
 		}
+	}
+
+	// Отвечаем ЮKassa, что все получили и обработали
+	w.WriteHeader(http.StatusOK)
+}
+
+// createOrReusePayment создает платеж по заказу у провайдера, записывая его в журнал, и возвращает ссылку на оплату.
+// Если у заказа уже есть незавершенный платеж этого провайдера на ту же сумму, повторно используется его ссылка;
+// если прошлая попытка создания оборвалась без ответа, запрос повторяется с тем же ключом идемпотентности.
+// Для наличных ссылка пустая: платеж лишь фиксируется как ожидаемый.
+func (bh *BotHandler) createOrReusePayment(provider payments.PaymentProvider, order models.Order, description, returnURL, clientPhone string) (string, error) {
+	amount := order.Cost.Float64
+	existing, err := db.GetPaymentsByOrderID(order.ID)
+	if err != nil {
+		return "", err
+	}
+
+	var record models.Payment
+	for _, p := range existing {
+		if p.Provider != provider.Name() || p.Amount != amount {
+			continue
+		}
+		if p.Status == constants.PAYMENT_STATUS_PENDING || p.Status == constants.PAYMENT_STATUS_WAITING_FOR_CAPTURE {
+			if p.ConfirmationURL.Valid || !provider.Prepaid() {
+				log.Printf("[PAYMENTS] Для заказа #%d используется существующий платеж #%d (%s).", order.ID, p.ID, p.ExternalID.String)
+				return p.ConfirmationURL.String, nil
+			}
+		}
+		if p.Status == constants.PAYMENT_STATUS_CREATED && !p.ExternalID.Valid && record.ID == 0 {
+			record = p
+		}
+	}
 
-		orderID, err := strconv.ParseInt(orderIDStr, 10, 64)
+	if record.ID == 0 {
+		record = models.Payment{
+			OrderID:        order.ID,
+			Provider:       provider.Name(),
+			Amount:         amount,
+			Currency:       "RUB",
+			IdempotenceKey: uuid.New().String(),
+		}
+		record.ID, err = db.CreatePaymentRecord(record)
 		if err != nil {
-			log.Printf("[YOOKASSA_HANDLER] Неверный формат order_id '%s' в метаданных для PaymentID %s.", orderIDStr, notification.Object.ID)
-			w.WriteHeader(http.StatusOK)
-			return
+			return "", err
+		}
+	} else {
+		log.Printf("[PAYMENTS] Повтор создания платежа #%d для заказа #%d с прежним ключом идемпотентности.", record.ID, order.ID)
+	}
+
+	providerPayment, err := provider.CreatePayment(payments.PaymentIntent{
+		OrderID:        order.ID,
+		Amount:         amount,
+		Currency:       record.Currency,
+		Description:    description,
+		ReturnURL:      returnURL,
+		ClientPhone:    clientPhone,
+		ClientEmail:    receiptEmailForOrder(order),
+		IdempotenceKey: record.IdempotenceKey,
+		ReceiptLines:   receiptLinesForOrder(order),
+		PaymentMode:    receiptPaymentModeForOrder(order),
+	})
+	db.SavePaymentRequestPayload(record.ID, providerPayment.RequestPayload)
+	if err != nil {
+		if providerPayment.RawResponse != nil {
+			// Провайдер ответил отказом: эта попытка окончательно неуспешна.
+			db.MarkPaymentFailed(record.ID, providerPayment.RawResponse)
+		}
+		return "", err
+	}
+
+	if errSync := bh.syncProviderPayment(record, providerPayment); errSync != nil {
+		log.Printf("[PAYMENTS] Платеж %s создан, но не сохранен в журнале (запись #%d): %v", providerPayment.ExternalID, record.ID, errSync)
+	}
+	return providerPayment.ConfirmationURL, nil
+}
+
+// receiptLinesForOrder возвращает позиции чека из расшифровки стоимости заказа.
+// Без расшифровки чек состоит из одной позиции - основной услуги категории заказа.
+func receiptLinesForOrder(order models.Order) []payments.ReceiptLine {
+	items, err := db.GetOrderCostItems(order.ID)
+	if err != nil {
+		log.Printf("[PAYMENTS] Не удалось получить расшифровку стоимости заказа #%d, чек будет одной позицией: %v", order.ID, err)
+	}
+	if len(items) == 0 {
+		kind, ok := constants.CategoryDefaultCostItemMap[order.Category]
+		if !ok {
+			kind = constants.COST_ITEM_OTHER
+		}
+		items = []models.OrderCostItem{{Kind: kind, Description: constants.CostItemDisplayMap[kind], Amount: order.Cost.Float64}}
+	}
+
+	lines := make([]payments.ReceiptLine, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, payments.ReceiptLine{
+			Description:    fmt.Sprintf("%s (заказ №%d)", item.Description, order.ID),
+			Amount:         item.Amount,
+			PaymentSubject: constants.CostItemPaymentSubjectMap[item.Kind],
+		})
+	}
+	return lines
+}
+
+// receiptEmailForOrder возвращает email клиента для чека, если клиент его указал.
+func receiptEmailForOrder(order models.Order) string {
+	if order.UserID == 0 {
+		return ""
+	}
+	email, err := db.GetUserEmail(int64(order.UserID))
+	if err != nil {
+		return ""
+	}
+	return email
+}
+
+// receiptPaymentModeForOrder возвращает признак способа расчета: оплата выполненного заказа - полный расчет,
+// оплата до выполнения - полная предоплата.
+func receiptPaymentModeForOrder(order models.Order) string {
+	switch order.Status {
+	case constants.STATUS_COMPLETED, constants.STATUS_CALCULATED, constants.STATUS_SETTLED:
+		return constants.RECEIPT_MODE_FULL_PAYMENT
+	}
+	return constants.RECEIPT_MODE_PREPAYMENT
+}
+
+// RegisterCashPayment фиксирует в журнале ожидаемую оплату наличными по заказу, стоимость которого принял клиент.
+// Платеж станет полученным, когда водитель сдаст отчет по этому заказу.
+func (bh *BotHandler) RegisterCashPayment(order models.Order) {
+	if !order.Cost.Valid || order.Cost.Float64 <= 0 {
+		return
+	}
+	provider, ok := bh.paymentProvider(constants.PAYMENT_PROVIDER_CASH)
+	if !ok {
+		provider = &payments.CashProvider{}
+	}
+	description := fmt.Sprintf("Оплата заказа №%d наличными", order.ID)
+	if _, err := bh.createOrReusePayment(provider, order, description, "", order.Phone); err != nil {
+		log.Printf("[PAYMENTS] Ошибка регистрации оплаты наличными для заказа #%d: %v", order.ID, err)
+	}
+}
+
+// orderIDFromPaymentMetadata извлекает ID заказа из метаданных платежа YooKassa.
+func orderIDFromPaymentMetadata(providerPayment payments.ProviderPayment) (int64, bool) {
+	orderIDStr, ok := payments.MetadataOrderID(providerPayment.RawResponse)
+	if !ok {
+		log.Printf("[YOOKASSA_HANDLER] В метаданных для PaymentID %s отсутствует order_id.", providerPayment.ExternalID)
+		return 0, false
+	}
+	orderID, err := strconv.ParseInt(orderIDStr, 10, 64)
+	if err != nil {
+		log.Printf("[YOOKASSA_HANDLER] Неверный формат order_id '%s' в метаданных для PaymentID %s.", orderIDStr, providerPayment.ExternalID)
+		return 0, false
+	}
+	return orderID, true
+}
+
+// syncProviderPayment записывает состояние платежа от провайдера в журнал и применяет его к заказу.
+// Функция идемпотентна: повторные вызовы с тем же состоянием ничего не меняют.
+func (bh *BotHandler) syncProviderPayment(record models.Payment, providerPayment payments.ProviderPayment) error {
+	// Аннулированный у нас платеж не возвращается в ожидание: ждем, пока провайдер оплатит или отменит его
+	if record.CanceledLocallyAt.Valid && providerPayment.Status != payments.StatusSucceeded && providerPayment.Status != payments.StatusCanceled {
+		return db.TouchLocallyCanceledPayment(record.ID)
+	}
+	errUpdate := db.UpdatePaymentFromProvider(record.ID, providerPayment.ExternalID, providerPayment.Status, providerPayment.RefundedAmount,
+		providerPayment.ConfirmationURL, providerPayment.RawResponse)
+	if errUpdate != nil {
+		return errUpdate
+	}
+
+	if providerPayment.Status == payments.StatusSucceeded {
+		// Поздняя оплата отмененного заказа обрабатывается только при первом переходе платежа в succeeded,
+		// а не при каждой повторной синхронизации (возвраты, повторные уведомления)
+		bh.applySucceededPayment(record.OrderID, record.Status != constants.PAYMENT_STATUS_SUCCEEDED)
+	}
+	return nil
+}
+
+// applySucceededPayment переводит заказ из "ожидание оплаты" в работу и уведомляет клиента и операторов.
+// Если заказ уже не ожидает оплаты, ничего не делает; newlyPaid - платеж только что перешел в succeeded.
+func (bh *BotHandler) applySucceededPayment(orderID int64, newlyPaid bool) {
+	changed, err := db.UpdateOrderStatusIfCurrent(orderID, constants.STATUS_AWAITING_PAYMENT, constants.STATUS_INPROGRESS)
+	if err != nil {
+		log.Printf("[PAYMENTS] Ошибка обновления статуса заказа #%d на IN_PROGRESS: %v", orderID, err)
+		return
+	}
+	if !changed {
+		log.Printf("[PAYMENTS] Получена оплата для заказа #%d, который уже не в статусе 'ожидание оплаты'. Статус не изменен.", orderID)
+		if newlyPaid {
+			bh.refundPaymentForCanceledOrder(orderID)
+		}
+		return
+	}
+
+	log.Printf("[PAYMENTS] Статус заказа #%d успешно обновлен на 'in_progress'.", orderID)
+
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		log.Printf("[PAYMENTS] Ошибка получения заказа #%d для уведомлений: %v", orderID, err)
+		return
+	}
+
+	// Уведомляем клиента
+	clientMsg := fmt.Sprintf("✅ Оплата по заказу №%d прошла успешно! Ваш заказ принят в работу. Скоро с вами свяжутся исполнители.", orderID)
+	bh.sendMessage(order.UserChatID, clientMsg)
+
+	// Уведомляем операторов
+	client, _ := db.GetUserByChatID(order.UserChatID)
+	operatorMsg := fmt.Sprintf(
+		"💸 Получена оплата по заказу №%d от клиента %s.\n"+
+			"Статус заказа изменен на '%s'. Можно назначать исполнителей, если они еще не назначены.",
+		orderID,
+		utils.GetUserDisplayName(client),
+		constants.StatusDisplayMap[constants.STATUS_INPROGRESS],
+	)
+	bh.NotifyOperatorsAndGroup(operatorMsg)
+}
+
+// refundPaymentForCanceledOrder возвращает деньги, если клиент оплатил по старой ссылке заказ, отмененный
+// автоматически за неоплату или неподтверждение. Если заказ отменили вручную, решение о возврате
+// принимает оператор.
+func (bh *BotHandler) refundPaymentForCanceledOrder(orderID int64) {
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil || order.Status != constants.STATUS_CANCELED {
+		return
+	}
+	if order.Reason.String != constants.OrderExpiredPaymentReason && order.Reason.String != constants.OrderExpiredConfirmationReason {
+		bh.NotifyOperatorsAndGroup(fmt.Sprintf("⚠️ Поступила оплата по отмененному заказу №%d. Автоматический возврат не оформлен: заказ отменен вручную. Проверьте и при необходимости оформите возврат.", orderID))
+		return
+	}
+	refundNote := bh.RefundAfterCancel(orderID, "Оплата поступила после отмены заказа", models.User{})
+	if refundNote == "" {
+		return
+	}
+	if order.UserChatID != 0 {
+		bh.sendMessage(order.UserChatID, fmt.Sprintf("ℹ️ Оплата по заказу №%d поступила после его отмены. %s", orderID, refundNote))
+	}
+	bh.NotifyOperatorsAndGroup(fmt.Sprintf("⚠️ Поступила оплата по отмененному заказу №%d.\n%s", orderID, refundNote))
+}
+
+// ReconcilePayments сверяет незавершенные платежи и возвраты с API провайдеров.
+// Исправляет заказы, зависшие в "ожидании оплаты" из-за потерянного вебхука.
+// Провайдеры, не умеющие сообщать статус (Telegram, наличные), пропускаются.
+func (bh *BotHandler) ReconcilePayments() {
+	if staleRefunds, errRefunds := db.GetStaleCreatedRefunds(paymentReconcileMinAge); errRefunds == nil {
+		for _, refund := range staleRefunds {
+			if errResend := bh.resendStaleRefund(refund); errResend != nil {
+				log.Printf("[PAYMENTS_RECONCILE] Ошибка повтора возврата #%d: %v", refund.ID, errResend)
+			}
+		}
+	}
+	if pendingRefunds, errRefunds := db.GetRefundsForReconciliation(paymentReconcileMinAge); errRefunds == nil {
+		for _, refund := range pendingRefunds {
+			if errSync := bh.syncYooKassaRefund(refund.ExternalID.String); errSync != nil {
+				log.Printf("[PAYMENTS_RECONCILE] Ошибка сверки возврата #%d: %v", refund.ID, errSync)
+			}
 		}
+	}
 
-		// Получаем заказ из БД
-		order, err := db.GetOrderByID(int(orderID))
+	for _, provider := range bh.EnabledPaymentProviders() {
+		openPayments, err := db.GetPaymentsForReconciliation(provider.Name(), paymentReconcileMinAge)
 		if err != nil {
-			log.Printf("[YOOKASSA_HANDLER] Ошибка получения заказа #%d из БД: %v", orderID, err)
-			w.WriteHeader(http.StatusOK)
-			return
+			log.Printf("[PAYMENTS_RECONCILE] Ошибка получения платежей '%s' для сверки: %v", provider.Name(), err)
+			continue
+		}
+		if len(openPayments) == 0 {
+			continue
 		}
 
-		// Проверяем, что заказ ожидает оплаты, чтобы избежать повторной обработки
-		if order.Status == constants.STATUS_AWAITING_PAYMENT {
-			// Обновляем статус заказа
-			errUpdate := db.UpdateOrderStatus(orderID, constants.STATUS_INPROGRESS)
-			if errUpdate != nil {
-				log.Printf("[YOOKASSA_HANDLER] Ошибка обновления статуса заказа #%d на IN_PROGRESS: %v", orderID, errUpdate)
-				// Здесь можно было бы вернуть 500, чтобы ЮKassa попробовала снова
-				http.Error(w, "Failed to update order status", http.StatusInternalServerError)
-				return
+		log.Printf("[PAYMENTS_RECONCILE] Сверка %d незавершенных платежей '%s'.", len(openPayments), provider.Name())
+		for _, record := range openPayments {
+			providerPayment, errGet := provider.GetStatus(record.ExternalID.String)
+			if errors.Is(errGet, payments.ErrNotSupported) {
+				break
 			}
+			if errGet != nil {
+				log.Printf("[PAYMENTS_RECONCILE] Ошибка получения платежа %s (запись #%d): %v", record.ExternalID.String, record.ID, errGet)
+				continue
+			}
+			if providerPayment.Status == record.Status {
+				continue
+			}
+			log.Printf("[PAYMENTS_RECONCILE] Платеж #%d (%s): статус '%s' -> '%s'.", record.ID, record.ExternalID.String, record.Status, providerPayment.Status)
+			if errSync := bh.syncProviderPayment(record, providerPayment); errSync != nil {
+				log.Printf("[PAYMENTS_RECONCILE] Ошибка обновления платежа #%d: %v", record.ID, errSync)
+			}
+		}
+	}
+}
 
-			log.Printf("[YOOKASSA_HANDLER] Статус заказа #%d успешно обновлен на 'in_progress'.", orderID)
+// RunPaymentReconciliation периодически запускает ReconcilePayments. Блокирует вызывающую горутину.
+func (bh *BotHandler) RunPaymentReconciliation(interval time.Duration) {
+	if bh.Deps.Config.YooKassaShopID == "" || bh.Deps.Config.YooKassaSecretKey == "" {
+		log.Println("[PAYMENTS_RECONCILE] YooKassa не настроена, сверка платежей не запускается.")
+		return
+	}
+	log.Printf("[PAYMENTS_RECONCILE] Сверка платежей запущена с периодом %s.", interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bh.ReconcilePayments()
+	}
+}
 
-			// Уведомляем клиента
-			clientMsg := fmt.Sprintf("✅ Оплата по заказу №%d прошла успешно! Ваш заказ принят в работу. Скоро с вами свяжутся исполнители.", orderID)
-			bh.sendMessage(order.UserChatID, clientMsg)
+// HandlePreCheckoutQuery подтверждает Telegram списание по счету, если заказ все еще ждет оплаты этой суммы.
+// Telegram ждет ответа не дольше 10 секунд, поэтому проверки ограничены чтением из базы.
+func (bh *BotHandler) HandlePreCheckoutQuery(update tgbotapi.Update) {
+	query := update.PreCheckoutQuery
+	if query == nil {
+		return
+	}
 
-			// Уведомляем операторов
-			client, _ := db.GetUserByChatID(order.UserChatID)
-			operatorMsg := fmt.Sprintf(
-				"💸 Получена оплата по заказу №%d от клиента %s.\n"+
-					"Статус заказа изменен на '%s'. Можно назначать исполнителей, если они еще не назначены.",
-				orderID,
-				utils.GetUserDisplayName(client),
-				constants.StatusDisplayMap[constants.STATUS_INPROGRESS],
-			)
-			bh.NotifyOperatorsAndGroup(operatorMsg)
+	answer := tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, OK: true}
+	record, err := db.GetPaymentByExternalID(constants.PAYMENT_PROVIDER_TELEGRAM, query.InvoicePayload)
+	if err != nil {
+		log.Printf("[TELEGRAM_PAYMENTS] Счет '%s' не найден в журнале: %v", query.InvoicePayload, err)
+		answer = tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, ErrorMessage: "Счет не найден. Запросите новую ссылку на оплату в меню 'Мои заказы'."}
+	} else if order, errOrder := db.GetOrderByID(int(record.OrderID)); errOrder != nil || order.Status != constants.STATUS_AWAITING_PAYMENT {
+		log.Printf("[TELEGRAM_PAYMENTS] Заказ #%d по счету '%s' не ожидает оплаты.", record.OrderID, query.InvoicePayload)
+		answer = tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, ErrorMessage: "Этот заказ уже не ожидает оплаты."}
+	} else if int64(query.TotalAmount) != int64(math.Round(record.Amount*100)) {
+		log.Printf("[TELEGRAM_PAYMENTS] Сумма счета '%s' (%d) не совпадает с журналом (%.2f).", query.InvoicePayload, query.TotalAmount, record.Amount)
+		answer = tgbotapi.PreCheckoutConfig{PreCheckoutQueryID: query.ID, ErrorMessage: "Стоимость заказа изменилась. Запросите новую ссылку на оплату."}
+	}
 
-		} else {
-			log.Printf("[YOOKASSA_HANDLER] Получено уведомление об оплате для заказа #%d, который уже не в статусе 'ожидание оплаты' (текущий статус: %s). Игнорируется.", orderID, order.Status)
-		}
+	if _, errAnswer := bh.Deps.BotClient.Request(answer); errAnswer != nil {
+		log.Printf("[TELEGRAM_PAYMENTS] Ошибка ответа на pre_checkout_query %s: %v", query.ID, errAnswer)
 	}
+}
 
-	// Отвечаем ЮKassa, что все получили и обработали
-	w.WriteHeader(http.StatusOK)
+// handleSuccessfulPayment обрабатывает сервисное сообщение об оплате счета Telegram.
+func (bh *BotHandler) handleSuccessfulPayment(message *tgbotapi.Message) {
+	provider, ok := bh.paymentProvider(constants.PAYMENT_PROVIDER_TELEGRAM)
+	if !ok {
+		provider = &payments.TelegramProvider{}
+	}
+	body, _ := json.Marshal(message.SuccessfulPayment)
+	event, err := provider.ParseWebhook(body)
+	if err != nil {
+		log.Printf("[TELEGRAM_PAYMENTS] Не удалось разобрать successful_payment от chatID %d: %v", message.Chat.ID, err)
+		return
+	}
+
+	record, err := db.GetPaymentByExternalID(constants.PAYMENT_PROVIDER_TELEGRAM, event.PaymentExternalID)
+	if err != nil {
+		log.Printf("[TELEGRAM_PAYMENTS] Оплаченный счет '%s' не найден в журнале: %v", event.PaymentExternalID, err)
+		bh.NotifyOperatorsAndGroup(fmt.Sprintf("⚠️ Получена оплата %.2f ₽ через Telegram по неизвестному счету '%s'. Требуется ручная проверка.", event.Amount, event.PaymentExternalID))
+		return
+	}
+
+	db.SavePaymentWebhookPayload(record.ID, body)
+	// Telegram не позволяет перепроверить счет, но successful_payment приходит только после списания.
+	providerPayment := payments.ProviderPayment{
+		ExternalID:     event.PaymentExternalID,
+		Status:         event.Status,
+		RefundedAmount: record.RefundedAmount,
+		RawResponse:    body,
+	}
+	if errSync := bh.syncProviderPayment(record, providerPayment); errSync != nil {
+		log.Printf("[TELEGRAM_PAYMENTS] Ошибка обновления платежа #%d: %v", record.ID, errSync)
+	}
 }
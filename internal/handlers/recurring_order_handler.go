@@ -0,0 +1,597 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// recurringUpcomingDatesShown - сколько ближайших дат графика показывать в карточке.
+const recurringUpcomingDatesShown = 4
+
+// dispatchRecurringOrderCallbacks обрабатывает коллбэки регулярных заказов (rec_*).
+func (bh *BotHandler) dispatchRecurringOrderCallbacks(currentCommand string, parts []string, data string, chatID int64, user models.User, originalMessageID int) int {
+	log.Printf("[CALLBACK_RECURRING] Диспетчер: Команда='%s', Части=%v, Data='%s', ChatID=%d", currentCommand, parts, data, chatID)
+
+	if currentCommand == constants.CALLBACK_PREFIX_RECURRING_LIST {
+		bh.SendRecurringOrdersList(chatID, user, originalMessageID)
+		return bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+	}
+
+	if len(parts) == 0 {
+		log.Printf("[CALLBACK_RECURRING] Нет ID в '%s'. ChatID=%d", data, chatID)
+		bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный формат команды.")
+		return originalMessageID
+	}
+	id, errID := strconv.ParseInt(parts[0], 10, 64)
+	if errID != nil || id <= 0 {
+		log.Printf("[CALLBACK_RECURRING] Некорректный ID в '%s'. ChatID=%d", data, chatID)
+		bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверный ID.")
+		return originalMessageID
+	}
+
+	switch currentCommand {
+	case constants.CALLBACK_PREFIX_RECURRING_NEW: // rec_new_ORDERID
+		bh.SendRecurringIntervalMenu(chatID, user, id, originalMessageID)
+	case constants.CALLBACK_PREFIX_RECURRING_CREATE: // rec_create_ORDERID_DAYS
+		days := 0
+		if len(parts) == 2 {
+			days, _ = strconv.Atoi(parts[1])
+		}
+		if days <= 0 {
+			bh.sendErrorMessageHelper(chatID, originalMessageID, "Ошибка: неверная периодичность.")
+			return originalMessageID
+		}
+		bh.handleRecurringCreate(chatID, user, id, days, originalMessageID)
+	case constants.CALLBACK_PREFIX_RECURRING_VIEW:
+		bh.SendRecurringOrderCard(chatID, user, id, originalMessageID)
+	case constants.CALLBACK_PREFIX_RECURRING_PAUSE:
+		bh.handleRecurringPause(chatID, user, id, originalMessageID)
+	case constants.CALLBACK_PREFIX_RECURRING_SKIP:
+		bh.handleRecurringSkip(chatID, user, id, originalMessageID)
+	case constants.CALLBACK_PREFIX_RECURRING_END:
+		bh.SendRecurringEndConfirm(chatID, user, id, originalMessageID)
+	case constants.CALLBACK_PREFIX_RECURRING_END_CONFIRM:
+		bh.handleRecurringEnd(chatID, user, id, originalMessageID)
+	default:
+		log.Printf("[CALLBACK_RECURRING] Неизвестная команда '%s'. ChatID=%d", currentCommand, chatID)
+		bh.sendErrorMessageHelper(chatID, originalMessageID, "Неизвестная команда регулярных заказов.")
+		return originalMessageID
+	}
+	return bh.Deps.SessionManager.GetTempOrder(chatID).CurrentMessageID
+}
+
+// loadManagedRecurringOrder загружает график и проверяет доступ: оператор управляет всеми графиками,
+// клиент - только своими. При ошибке сообщение пользователю уже отправлено.
+func (bh *BotHandler) loadManagedRecurringOrder(chatID int64, user models.User, id int64, messageIDToEdit int) (models.RecurringOrder, bool) {
+	r, err := db.GetRecurringOrder(id)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Регулярный заказ №%d не найден.", id))
+		return r, false
+	}
+	if !utils.IsOperatorOrHigher(user.Role) && r.UserChatID != chatID {
+		bh.sendAccessDenied(chatID, messageIDToEdit)
+		return r, false
+	}
+	return r, true
+}
+
+// SendRecurringOrdersList показывает регулярные заказы: оператору - все, клиенту - его собственные.
+func (bh *BotHandler) SendRecurringOrdersList(chatID int64, user models.User, messageIDToEdit int) {
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_IDLE)
+	isOperator := utils.IsOperatorOrHigher(user.Role)
+	var clientChatID int64
+	backCallback := "manage_orders"
+	if !isOperator {
+		clientChatID = chatID
+		backCallback = "my_orders_page_0"
+	}
+
+	list, err := db.GetRecurringOrders(clientChatID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Ошибка загрузки регулярных заказов.")
+		return
+	}
+
+	msgText := "🔁 *Регулярные заказы*\n\n"
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(list) == 0 {
+		if isOperator {
+			msgText += "Регулярных заказов пока нет. Сделать заказ регулярным можно из карточки заказа."
+		} else {
+			msgText += "У вас нет регулярных заказов. Чтобы вывоз проходил по графику, обратитесь к оператору."
+		}
+	} else {
+		msgText += "Выберите график:"
+		for _, r := range list {
+			buttonText := fmt.Sprintf("%s №%d | %s, %s", strings.Fields(constants.RecurringStatusDisplayMap[r.Status])[0],
+				r.ID, formatRecurringInterval(r.IntervalDays), r.Address)
+			if len([]rune(buttonText)) > 60 {
+				buttonText = string([]rune(buttonText)[:57]) + "..."
+			}
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(buttonText, fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_VIEW, r.ID)),
+			))
+		}
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ Назад", backCallback),
+		tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, tgbotapi.ModeMarkdown); err != nil {
+		log.Printf("SendRecurringOrdersList: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// SendRecurringIntervalMenu предлагает оператору выбрать периодичность графика для заказа.
+func (bh *BotHandler) SendRecurringIntervalMenu(chatID int64, user models.User, orderID int64, messageIDToEdit int) {
+	if !utils.IsOperatorOrHigher(user.Role) {
+		bh.sendAccessDenied(chatID, messageIDToEdit)
+		return
+	}
+	if existingID, err := db.GetRecurringOrderIDByOrder(orderID); err == nil {
+		bh.SendRecurringOrderCard(chatID, user, existingID, messageIDToEdit)
+		return
+	}
+
+	msgText := fmt.Sprintf("🔁 Как часто повторять заказ №%d?\n\n"+
+		"Новые заказы будут создаваться автоматически за %d дн. до даты - с тем же адресом, временем, ценой и исполнителями.",
+		orderID, bh.Deps.Config.RecurringOrdersAheadDays)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, days := range constants.RecurringIntervalOptions {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			formatRecurringInterval(days), fmt.Sprintf("%s_%d_%d", constants.CALLBACK_PREFIX_RECURRING_CREATE, orderID, days))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⬅️ К заказу", fmt.Sprintf("view_order_%d", orderID)),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, ""); err != nil {
+		log.Printf("SendRecurringIntervalMenu: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleRecurringCreate создает график по заказу. Первая дата - через интервал после даты заказа,
+// но не раньше сегодняшнего дня.
+func (bh *BotHandler) handleRecurringCreate(chatID int64, user models.User, orderID int64, days int, messageIDToEdit int) {
+	if !utils.IsOperatorOrHigher(user.Role) {
+		bh.sendAccessDenied(chatID, messageIDToEdit)
+		return
+	}
+	if existingID, err := db.GetRecurringOrderIDByOrder(orderID); err == nil {
+		bh.SendRecurringOrderCard(chatID, user, existingID, messageIDToEdit)
+		return
+	}
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, fmt.Sprintf("❌ Заказ №%d не найден.", orderID))
+		return
+	}
+	if order.Status == constants.STATUS_DRAFT || order.Status == constants.STATUS_CANCELED {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Черновик или отмененный заказ нельзя сделать регулярным.")
+		return
+	}
+
+	today := utils.DateOnly(time.Now())
+	startDate := today
+	if orderDate, errDate := utils.ValidateDate(order.Date); errDate == nil {
+		startDate = utils.DateOnly(orderDate).AddDate(0, 0, days)
+		for startDate.Before(today) {
+			startDate = startDate.AddDate(0, 0, days)
+		}
+	}
+
+	id, err := db.CreateRecurringOrderFromOrder(orderID, days, startDate, user.ID)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось создать регулярный заказ.")
+		return
+	}
+
+	if order.UserChatID != 0 && order.UserChatID != chatID {
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔁 Открыть график", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_VIEW, id)),
+		))
+		text := fmt.Sprintf("🔁 Заказ №%d теперь регулярный: %s, следующая дата - %s.\nГрафик можно приостановить, пропустить дату или завершить в разделе «Мои заказы».",
+			orderID, formatRecurringInterval(days), startDate.Format("02.01.2006"))
+		if _, errSend := bh.sendMessageWithKeyboard(order.UserChatID, text, &keyboard); errSend != nil {
+			log.Printf("handleRecurringCreate: ошибка уведомления клиента %d о графике #%d: %v", order.UserChatID, id, errSend)
+		}
+	}
+	bh.SendRecurringOrderCard(chatID, user, id, messageIDToEdit)
+}
+
+// SendRecurringOrderCard показывает карточку графика с ближайшими датами и кнопками управления.
+func (bh *BotHandler) SendRecurringOrderCard(chatID int64, user models.User, id int64, messageIDToEdit int) {
+	r, ok := bh.loadManagedRecurringOrder(chatID, user, id, messageIDToEdit)
+	if !ok {
+		return
+	}
+	bh.Deps.SessionManager.SetState(chatID, constants.STATE_IDLE)
+	today := utils.DateOnly(time.Now())
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔁 *Регулярный заказ №%d*\n", r.ID))
+	sb.WriteString(fmt.Sprintf("Статус: %s\n", constants.RecurringStatusDisplayMap[r.Status]))
+	sb.WriteString(fmt.Sprintf("Периодичность: %s\n", formatRecurringInterval(r.IntervalDays)))
+	category := constants.CategoryDisplayMap[r.Category]
+	if category == "" {
+		category = r.Category
+	}
+	sb.WriteString(fmt.Sprintf("Услуга: %s\n", utils.EscapeTelegramMarkdown(category)))
+	sb.WriteString(fmt.Sprintf("Адрес: %s\n", utils.EscapeTelegramMarkdown(r.Address)))
+	if r.TimeSlot.Valid && r.TimeSlot.String != "" {
+		sb.WriteString(fmt.Sprintf("Время: %s\n", utils.EscapeTelegramMarkdown(r.TimeSlot.String)))
+	}
+	if r.Cost.Valid {
+		sb.WriteString(fmt.Sprintf("Цена: %.0f ₽\n", r.Cost.Float64))
+	} else {
+		sb.WriteString("Цена: рассчитывается оператором для каждого заказа\n")
+	}
+	if utils.IsOperatorOrHigher(user.Role) {
+		if len(r.Executors) == 0 {
+			sb.WriteString("Исполнители: назначаются вручную\n")
+		} else {
+			var names []string
+			for _, e := range r.Executors {
+				names = append(names, fmt.Sprintf("%s (%s)", e.Name, utils.GetRoleDisplayName(e.Role)))
+			}
+			sb.WriteString(fmt.Sprintf("Исполнители: %s\n", utils.EscapeTelegramMarkdown(strings.Join(names, ", "))))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("Начало: %s", r.StartDate.Format("02.01.2006")))
+	if r.EndDate.Valid {
+		sb.WriteString(fmt.Sprintf(", окончание: %s", r.EndDate.Time.Format("02.01.2006")))
+	}
+	sb.WriteString("\n")
+
+	if r.Status != constants.RECURRING_STATUS_ENDED {
+		upcoming := bh.upcomingRecurringDates(r, today, recurringUpcomingDatesShown)
+		if len(upcoming) > 0 {
+			sb.WriteString("\n*Ближайшие даты:*\n")
+			for _, item := range upcoming {
+				sb.WriteString(fmt.Sprintf("• %s%s\n", item.date.Format("02.01.2006"), item.note))
+			}
+		}
+		if r.Status == constants.RECURRING_STATUS_PAUSED {
+			sb.WriteString("\nПока график на паузе, новые заказы не создаются.\n")
+		}
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	switch r.Status {
+	case constants.RECURRING_STATUS_ACTIVE:
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏭ Пропустить ближайшую дату", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_SKIP, r.ID)),
+		))
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("⏸ Приостановить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_PAUSE, r.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("⏹ Завершить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_END, r.ID)),
+		))
+	case constants.RECURRING_STATUS_PAUSED:
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("▶️ Возобновить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_PAUSE, r.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("⏹ Завершить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_END, r.ID)),
+		))
+	}
+	if r.SourceOrderID.Valid {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("📋 Исходный заказ №%d", r.SourceOrderID.Int64), fmt.Sprintf("view_order_%d", r.SourceOrderID.Int64)),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("🔁 Все регулярные заказы", constants.CALLBACK_PREFIX_RECURRING_LIST),
+		tgbotapi.NewInlineKeyboardButtonData("🏢 Главное меню", "back_to_main"),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sentMsg, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, sb.String(), &keyboard, tgbotapi.ModeMarkdown)
+	if err != nil {
+		log.Printf("SendRecurringOrderCard: Ошибка для chatID %d: %v", chatID, err)
+		return
+	}
+	tempOrder := bh.Deps.SessionManager.GetTempOrder(chatID)
+	tempOrder.CurrentMessageID = sentMsg.MessageID
+	bh.Deps.SessionManager.UpdateTempOrder(chatID, tempOrder)
+}
+
+// recurringDateItem - дата графика с пометкой для карточки (создан заказ, пропущена).
+type recurringDateItem struct {
+	date    time.Time
+	note    string
+	skipped bool
+	orderID sql.NullInt64
+}
+
+// upcomingRecurringDates возвращает ближайшие даты графика начиная с from, отмечая уже созданные заказы и пропуски.
+func (bh *BotHandler) upcomingRecurringDates(r models.RecurringOrder, from time.Time, limit int) []recurringDateItem {
+	var end time.Time
+	if r.EndDate.Valid {
+		end = r.EndDate.Time
+	}
+	to := from.AddDate(0, 0, r.IntervalDays*(limit+1))
+	dates := utils.RecurringDates(r.StartDate, r.IntervalDays, end, from, to)
+
+	occurrences, _ := db.GetRecurringOccurrences(r.ID, from)
+	byDate := make(map[string]models.RecurringOccurrence, len(occurrences))
+	for _, o := range occurrences {
+		byDate[o.Date.Format("2006-01-02")] = o
+	}
+
+	var items []recurringDateItem
+	for _, d := range dates {
+		item := recurringDateItem{date: d}
+		if o, ok := byDate[d.Format("2006-01-02")]; ok {
+			item.orderID = o.OrderID
+			if o.Status == constants.OCCURRENCE_STATUS_SKIPPED {
+				item.skipped = true
+				item.note = " - пропущена"
+			} else if o.OrderID.Valid {
+				item.note = fmt.Sprintf(" - заказ №%d", o.OrderID.Int64)
+			}
+		}
+		items = append(items, item)
+		if len(items) == limit {
+			break
+		}
+	}
+	return items
+}
+
+// handleRecurringPause ставит действующий график на паузу или возобновляет приостановленный.
+func (bh *BotHandler) handleRecurringPause(chatID int64, user models.User, id int64, messageIDToEdit int) {
+	r, ok := bh.loadManagedRecurringOrder(chatID, user, id, messageIDToEdit)
+	if !ok {
+		return
+	}
+	var newStatus, notice string
+	switch r.Status {
+	case constants.RECURRING_STATUS_ACTIVE:
+		newStatus = constants.RECURRING_STATUS_PAUSED
+		notice = fmt.Sprintf("⏸ Регулярный заказ №%d приостановлен. Новые заказы по нему не создаются.", r.ID)
+	case constants.RECURRING_STATUS_PAUSED:
+		newStatus = constants.RECURRING_STATUS_ACTIVE
+		notice = fmt.Sprintf("▶️ Регулярный заказ №%d возобновлен.", r.ID)
+	default:
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Завершенный график нельзя приостановить или возобновить.")
+		return
+	}
+	if err := db.SetRecurringOrderStatus(r.ID, newStatus); err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось изменить статус графика.")
+		return
+	}
+	bh.notifyRecurringCounterparty(chatID, r, notice)
+	bh.SendRecurringOrderCard(chatID, user, r.ID, messageIDToEdit)
+}
+
+// handleRecurringSkip пропускает ближайшую дату графика. Если заказ на эту дату уже создан и еще не выполнен,
+// он отменяется вместе с незавершенными платежами.
+func (bh *BotHandler) handleRecurringSkip(chatID int64, user models.User, id int64, messageIDToEdit int) {
+	r, ok := bh.loadManagedRecurringOrder(chatID, user, id, messageIDToEdit)
+	if !ok {
+		return
+	}
+	if r.Status != constants.RECURRING_STATUS_ACTIVE {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Пропустить дату можно только в действующем графике.")
+		return
+	}
+	var next *recurringDateItem
+	for _, item := range bh.upcomingRecurringDates(r, utils.DateOnly(time.Now()), recurringUpcomingDatesShown) {
+		if !item.skipped {
+			item := item
+			next = &item
+			break
+		}
+	}
+	if next == nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ В графике нет ближайших дат.")
+		return
+	}
+
+	orderID, err := db.SkipRecurringOccurrence(r.ID, next.date)
+	if err != nil {
+		bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось пропустить дату.")
+		return
+	}
+	notice := fmt.Sprintf("⏭ В регулярном заказе №%d пропущена дата %s.", r.ID, next.date.Format("02.01.2006"))
+	if orderID.Valid {
+		if order, errOrder := db.GetOrderByID(int(orderID.Int64)); errOrder == nil && isRecurringOrderCancelable(order.Status) {
+			if errCancel := db.UpdateOrderStatusAndReason(order.ID, constants.STATUS_CANCELED, sql.NullString{String: constants.RecurringSkipReason, Valid: true}); errCancel != nil {
+				log.Printf("handleRecurringSkip: ошибка отмены заказа #%d графика #%d: %v", order.ID, r.ID, errCancel)
+				bh.sendErrorMessageHelper(chatID, messageIDToEdit, periodErrorText(errCancel, fmt.Sprintf("⚠️ Дата пропущена, но заказ №%d отменить не удалось - отмените его вручную.", order.ID)))
+				return
+			}
+			db.CancelOpenOrderPayments(order.ID)
+			notice += fmt.Sprintf(" Заказ №%d на эту дату отменен.", order.ID)
+			// Если заказ уже был оплачен онлайн, деньги возвращаются клиенту автоматически.
+			if refundNote := bh.RefundAfterCancel(order.ID, constants.RecurringSkipReason, user); refundNote != "" {
+				notice += "\n\n" + refundNote
+			}
+		}
+	}
+	bh.notifyRecurringCounterparty(chatID, r, notice)
+	bh.SendRecurringOrderCard(chatID, user, r.ID, messageIDToEdit)
+}
+
+// isRecurringOrderCancelable сообщает, можно ли отменить заказ графика при пропуске даты: работы по нему еще не выполнены.
+func isRecurringOrderCancelable(status string) bool {
+	switch status {
+	case constants.STATUS_NEW, constants.STATUS_AWAITING_COST, constants.STATUS_AWAITING_CONFIRMATION,
+		constants.STATUS_AWAITING_PAYMENT, constants.STATUS_INPROGRESS:
+		return true
+	}
+	return false
+}
+
+// SendRecurringEndConfirm запрашивает подтверждение завершения графика.
+func (bh *BotHandler) SendRecurringEndConfirm(chatID int64, user models.User, id int64, messageIDToEdit int) {
+	r, ok := bh.loadManagedRecurringOrder(chatID, user, id, messageIDToEdit)
+	if !ok {
+		return
+	}
+	if r.Status == constants.RECURRING_STATUS_ENDED {
+		bh.SendRecurringOrderCard(chatID, user, r.ID, messageIDToEdit)
+		return
+	}
+	msgText := fmt.Sprintf("⏹ Завершить регулярный заказ №%d?\n\nНовые заказы по графику создаваться не будут. Уже созданные заказы останутся в силе.", r.ID)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Да, завершить", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_END_CONFIRM, r.ID)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Нет", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_VIEW, r.ID)),
+		),
+	)
+	if _, err := bh.sendOrEditMessageHelper(chatID, messageIDToEdit, msgText, &keyboard, ""); err != nil {
+		log.Printf("SendRecurringEndConfirm: Ошибка для chatID %d: %v", chatID, err)
+	}
+}
+
+// handleRecurringEnd завершает график.
+func (bh *BotHandler) handleRecurringEnd(chatID int64, user models.User, id int64, messageIDToEdit int) {
+	r, ok := bh.loadManagedRecurringOrder(chatID, user, id, messageIDToEdit)
+	if !ok {
+		return
+	}
+	if r.Status != constants.RECURRING_STATUS_ENDED {
+		if err := db.SetRecurringOrderStatus(r.ID, constants.RECURRING_STATUS_ENDED); err != nil {
+			bh.sendErrorMessageHelper(chatID, messageIDToEdit, "❌ Не удалось завершить график.")
+			return
+		}
+		bh.notifyRecurringCounterparty(chatID, r, fmt.Sprintf("⏹ Регулярный заказ №%d завершен.", r.ID))
+	}
+	bh.SendRecurringOrderCard(chatID, user, r.ID, messageIDToEdit)
+}
+
+// notifyRecurringCounterparty сообщает об изменении графика другой стороне: операторам, если действовал клиент,
+// и клиенту, если действовал оператор.
+func (bh *BotHandler) notifyRecurringCounterparty(actorChatID int64, r models.RecurringOrder, text string) {
+	if actorChatID == r.UserChatID {
+		bh.NotifyOperatorsAndGroup(text + " (действие клиента)")
+		return
+	}
+	if r.UserChatID != 0 {
+		bh.sendMessage(r.UserChatID, text)
+	}
+}
+
+// GenerateRecurringOrders создает заказы по действующим графикам на даты в пределах RecurringOrdersAheadDays
+// и завершает графики, у которых прошла дата окончания.
+func (bh *BotHandler) GenerateRecurringOrders() {
+	list, err := db.GetActiveRecurringOrders()
+	if err != nil {
+		log.Printf("[RECURRING] Ошибка выборки графиков: %v", err)
+		return
+	}
+	today := utils.DateOnly(time.Now())
+	horizon := today.AddDate(0, 0, bh.Deps.Config.RecurringOrdersAheadDays)
+	for _, r := range list {
+		if r.EndDate.Valid && utils.DateOnly(r.EndDate.Time).Before(today) {
+			if errEnd := db.SetRecurringOrderStatus(r.ID, constants.RECURRING_STATUS_ENDED); errEnd == nil {
+				log.Printf("[RECURRING] График #%d завершен: прошла дата окончания.", r.ID)
+			}
+			continue
+		}
+		var end time.Time
+		if r.EndDate.Valid {
+			end = r.EndDate.Time
+		}
+		for _, date := range utils.RecurringDates(r.StartDate, r.IntervalDays, end, today, horizon) {
+			bh.createRecurringOccurrence(r, date)
+		}
+	}
+}
+
+// createRecurringOccurrence создает заказ графика на дату, назначает исполнителей и уведомляет клиента и операторов.
+func (bh *BotHandler) createRecurringOccurrence(r models.RecurringOrder, date time.Time) {
+	status := constants.STATUS_NEW
+	prepaid := false
+	if r.Cost.Valid && r.Cost.Float64 > 0 {
+		prepaid = bh.RequiresPrepayment(models.Order{Payment: r.Payment})
+		if prepaid {
+			status = constants.STATUS_AWAITING_PAYMENT
+		} else {
+			status = constants.STATUS_INPROGRESS
+		}
+	}
+	orderID, err := db.CreateRecurringOccurrenceOrder(r, date, status)
+	if err != nil || orderID == 0 {
+		return
+	}
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		log.Printf("[RECURRING] Ошибка загрузки созданного заказа #%d: %v", orderID, err)
+		return
+	}
+	if status == constants.STATUS_INPROGRESS {
+		bh.RegisterCashPayment(order)
+	}
+
+	var failedExecutors []string
+	for _, e := range r.Executors {
+		if errAssign := db.AssignExecutor(int(orderID), e.ChatID, e.Role); errAssign != nil {
+			log.Printf("[RECURRING] Не удалось назначить %s (chat %d) на заказ #%d: %v", e.Role, e.ChatID, orderID, errAssign)
+			failedExecutors = append(failedExecutors, fmt.Sprintf("%s (%s)", e.Name, utils.GetRoleDisplayName(e.Role)))
+			continue
+		}
+		if executor, errUser := db.GetUserByChatID(e.ChatID); errUser == nil {
+			bh.sendTaskNotificationToExecutor(executor, int(orderID))
+		}
+	}
+
+	dateText := date.Format("02.01.2006")
+	if r.UserChatID != 0 {
+		var rows [][]tgbotapi.InlineKeyboardButton
+		text := fmt.Sprintf("🔁 По регулярному заказу №%d создан заказ №%d на %s.", r.ID, orderID, dateText)
+		switch status {
+		case constants.STATUS_AWAITING_PAYMENT:
+			text += fmt.Sprintf("\nК оплате: %.0f ₽.", r.Cost.Float64)
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("💳 Оплатить заказ", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_PAY_ORDER, orderID)),
+			))
+		case constants.STATUS_NEW:
+			text += "\nОператор рассчитает стоимость и пришлет ее на подтверждение."
+		}
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📋 Детали заказа", fmt.Sprintf("view_order_%d", orderID))),
+			tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⏭ Управлять графиком", fmt.Sprintf("%s_%d", constants.CALLBACK_PREFIX_RECURRING_VIEW, r.ID))),
+		)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		if _, errSend := bh.sendMessageWithKeyboard(r.UserChatID, text, &keyboard); errSend != nil {
+			log.Printf("[RECURRING] Ошибка уведомления клиента %d о заказе #%d: %v", r.UserChatID, orderID, errSend)
+		}
+	}
+
+	operatorText := fmt.Sprintf("🔁 По графику №%d создан заказ №%d на %s (статус: %s).",
+		r.ID, orderID, dateText, constants.StatusDisplayMap[status])
+	if len(failedExecutors) > 0 {
+		operatorText += fmt.Sprintf("\n⚠️ Не удалось назначить: %s. Назначьте исполнителей вручную.", strings.Join(failedExecutors, ", "))
+	}
+	bh.NotifyOperatorsAndGroup(operatorText)
+}
+
+// RunRecurringOrders периодически запускает GenerateRecurringOrders. Блокирует вызывающую горутину.
+func (bh *BotHandler) RunRecurringOrders(interval time.Duration) {
+	log.Printf("[RECURRING] Планировщик регулярных заказов запущен с периодом %s (заказы создаются за %d дн.).",
+		interval, bh.Deps.Config.RecurringOrdersAheadDays)
+	bh.GenerateRecurringOrders()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bh.GenerateRecurringOrders()
+	}
+}
+
+// formatRecurringInterval - подпись периодичности графика.
+func formatRecurringInterval(days int) string {
+	if label, ok := constants.RecurringIntervalDisplayMap[days]; ok {
+		return label
+	}
+	return fmt.Sprintf("каждые %d дн.", days)
+}
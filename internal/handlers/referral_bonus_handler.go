@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/utils"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// AccrueReferralBonus начисляет реферальный бонус за выполненный заказ и уведомляет пригласившего.
+// Вызывается после перевода заказа в статус "Выполнен"; ошибки только логируются.
+func (bh *BotHandler) AccrueReferralBonus(orderID int) {
+	referral, accrued, err := db.AccrueReferralBonusForOrder(orderID)
+	if err != nil {
+		log.Printf("[REFERRAL] Ошибка начисления бонуса за заказ #%d: %v", orderID, err)
+		return
+	}
+	if !accrued {
+		return
+	}
+	if referral.IsHeld() {
+		bh.notifyOwnersReferralHeld(referral)
+	}
+	inviter, err := db.GetUserByID(int(referral.InviterID))
+	if err != nil || inviter.ChatID == 0 {
+		log.Printf("[REFERRAL] Не удалось найти пригласившего %d для уведомления о бонусе #%d: %v", referral.InviterID, referral.ID, err)
+		return
+	}
+	text := fmt.Sprintf("🎉 Ваш друг выполнил заказ — вам начислен реферальный бонус *%.0f ₽*!", referral.Amount)
+	if referral.IsHeld() {
+		text = fmt.Sprintf("🎉 Ваш друг выполнил заказ! Реферальный бонус *%.0f ₽* проходит проверку — мы сообщим, когда он станет доступен.", referral.Amount)
+	} else if referral.ExpiresAt.Valid {
+		text += fmt.Sprintf("\nЗапросите выплату до %s, иначе бонус сгорит.", referral.ExpiresAt.Time.Format("02.01.2006"))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("👥 Мои рефералы", "referral_my")),
+	)
+	bh.sendMessageWithKeyboard(inviter.ChatID, text, &keyboard)
+}
+
+// notifyOwnersReferralHeld сообщает владельцам об удержанном на проверке бонусе с причинами.
+func (bh *BotHandler) notifyOwnersReferralHeld(referral models.Referral) {
+	owners, err := db.GetUsersByRole(constants.ROLE_OWNER)
+	if err != nil {
+		log.Printf("[REFERRAL] Ошибка получения владельцев для уведомления о бонусе #%d: %v", referral.ID, err)
+		return
+	}
+	text := fmt.Sprintf("🕵️ *Реферальный бонус #%d на %.0f ₽ удержан на проверке*\nЗаказ №%d\n%s",
+		referral.ID, referral.Amount, referral.OrderID, formatReferralReviewReasons(referral.ReviewReasons))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🕵️ Бонусы на проверке", fmt.Sprintf("%s_0", constants.CALLBACK_PREFIX_OWNER_REFERRAL_REVIEWS)),
+		),
+	)
+	for _, owner := range owners {
+		bh.sendMessageWithKeyboard(owner.ChatID, text, &keyboard)
+	}
+}
+
+// formatReferralReviewReasons - причины удержания бонуса списком (Markdown).
+func formatReferralReviewReasons(reasons []string) string {
+	var sb strings.Builder
+	for _, reason := range reasons {
+		sb.WriteString("⚠️ " + utils.EscapeTelegramMarkdown(reason) + "\n")
+	}
+	return sb.String()
+}
+
+// CheckReferralBonusExpiry списывает бонусы с истекшим сроком и сообщает об этом пригласившим.
+func (bh *BotHandler) CheckReferralBonusExpiry() {
+	expired, err := db.ExpireReferralBonuses()
+	if err != nil {
+		log.Printf("[REFERRAL] Ошибка списания бонусов с истекшим сроком: %v", err)
+		return
+	}
+	totals := make(map[int64]float64)
+	var inviterIDs []int64
+	for _, r := range expired {
+		if _, ok := totals[r.InviterID]; !ok {
+			inviterIDs = append(inviterIDs, r.InviterID)
+		}
+		totals[r.InviterID] += r.Amount
+	}
+	for _, inviterID := range inviterIDs {
+		inviter, errUser := db.GetUserByID(int(inviterID))
+		if errUser != nil || inviter.ChatID == 0 {
+			log.Printf("[REFERRAL] Не удалось найти пригласившего %d для уведомления о сгоревших бонусах: %v", inviterID, errUser)
+			continue
+		}
+		bh.sendMessage(inviter.ChatID, fmt.Sprintf("⌛ Срок действия реферальных бонусов на %.0f ₽ истек, они больше недоступны для выплаты.", totals[inviterID]))
+	}
+}
+
+// RunReferralBonusExpiry периодически запускает CheckReferralBonusExpiry. Блокирует вызывающую горутину.
+func (bh *BotHandler) RunReferralBonusExpiry(interval time.Duration) {
+	log.Printf("[REFERRAL] Проверка сроков реферальных бонусов запущена с периодом %s.", interval)
+	bh.CheckReferralBonusExpiry()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bh.CheckReferralBonusExpiry()
+	}
+}
+
+// formatReferralBonusAmount - размер бонуса по условиям: сумма или процент от заказа.
+func formatReferralBonusAmount(rule models.ReferralRule) string {
+	if rule.BonusType == constants.REFERRAL_BONUS_TYPE_PERCENT {
+		return fmt.Sprintf("%s%% от стоимости заказа", strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", rule.BonusValue), "0"), "."))
+	}
+	return fmt.Sprintf("%.0f ₽", rule.BonusValue)
+}
+
+// formatReferralRuleTerms - условия реферальной программы для клиента (Markdown).
+func formatReferralRuleTerms(rule models.ReferralRule) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("• Бонус: *%s* за первый выполненный заказ приглашенного друга", formatReferralBonusAmount(rule)))
+	if rule.MinOrderAmount > 0 {
+		sb.WriteString(fmt.Sprintf(" от %.0f ₽", rule.MinOrderAmount))
+	}
+	sb.WriteString("\n")
+	if len(rule.Categories) > 0 {
+		names := make([]string, 0, len(rule.Categories))
+		for _, category := range rule.Categories {
+			name, ok := constants.CategoryDisplayMap[category]
+			if !ok {
+				name = category
+			}
+			names = append(names, name)
+		}
+		sb.WriteString(fmt.Sprintf("• Учитываются заказы: %s\n", utils.EscapeTelegramMarkdown(strings.Join(names, ", "))))
+	}
+	if rule.MonthlyCap > 0 {
+		sb.WriteString(fmt.Sprintf("• Не более %.0f ₽ бонусов в календарный месяц\n", rule.MonthlyCap))
+	}
+	if rule.ExpiryDays > 0 {
+		sb.WriteString(fmt.Sprintf("• Бонус нужно запросить к выплате в течение %d дн., иначе он сгорит\n", rule.ExpiryDays))
+	}
+	if rule.MinPayoutAmount > 0 {
+		sb.WriteString(fmt.Sprintf("• Выплата от %.0f ₽\n", rule.MinPayoutAmount))
+	}
+	return sb.String()
+}
@@ -0,0 +1,248 @@
+// Файл: internal/handlers/refund_handler.go
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/models"
+	"Original/internal/payments"
+
+	"github.com/google/uuid"
+)
+
+// ErrNoRefundablePayments возвращается, если у заказа нет онлайн-платежей с остатком к возврату.
+var ErrNoRefundablePayments = errors.New("по заказу нет оплат, доступных для возврата")
+
+// RefundOrderPayment возвращает клиенту деньги за заказ, оплаченный онлайн.
+// Наличные возвращаются вручную и здесь не учитываются.
+// amount <= 0 означает полный возврат всей еще не возвращенной суммы; иначе выполняется частичный возврат.
+// Сумма распределяется по успешным платежам заказа, начиная с последнего, и никогда не превышает оплаченную.
+// Возвращает фактически оформленную сумму возврата.
+func (bh *BotHandler) RefundOrderPayment(orderID int64, amount float64, reason string, initiator models.User) (float64, error) {
+	orderPayments, err := db.GetPaymentsByOrderID(orderID)
+	if err != nil {
+		return 0, err
+	}
+
+	type refundable struct {
+		payment   models.Payment
+		provider  payments.PaymentProvider
+		available float64
+	}
+	var candidates []refundable
+	totalAvailable := 0.0
+	for _, p := range orderPayments {
+		if p.Status != constants.PAYMENT_STATUS_SUCCEEDED || !p.ExternalID.Valid {
+			continue
+		}
+		provider, ok := bh.paymentProvider(p.Provider)
+		if !ok || !provider.Prepaid() {
+			continue
+		}
+		available, errAvail := db.GetRefundableAmount(p.ID)
+		if errAvail != nil || available <= 0 {
+			continue
+		}
+		candidates = append(candidates, refundable{payment: p, provider: provider, available: available})
+		totalAvailable += available
+	}
+
+	if len(candidates) == 0 {
+		return 0, ErrNoRefundablePayments
+	}
+	if amount <= 0 {
+		amount = totalAvailable
+	}
+	amount = math.Round(amount*100) / 100
+	if amount > totalAvailable+0.005 {
+		return 0, fmt.Errorf("%w: запрошено %.2f ₽, доступно %.2f ₽", db.ErrRefundExceedsCaptured, amount, totalAvailable)
+	}
+
+	order, err := db.GetOrderByID(int(orderID))
+	if err != nil {
+		return 0, err
+	}
+	description := fmt.Sprintf("Возврат по заказу №%d", orderID)
+
+	refunded := 0.0
+	remaining := amount
+	var lastErr error
+	for _, c := range candidates {
+		if remaining <= 0.005 {
+			break
+		}
+		part := math.Min(remaining, c.available)
+		if errRefund := bh.createProviderRefund(c.provider, c.payment, part, description, reason, order.Phone, initiator); errRefund != nil {
+			log.Printf("[REFUNDS] Ошибка возврата %.2f по платежу #%d заказа #%d: %v", part, c.payment.ID, orderID, errRefund)
+			lastErr = errRefund
+			continue
+		}
+		refunded += part
+		remaining -= part
+	}
+
+	if refunded == 0 {
+		return 0, lastErr
+	}
+
+	clientMsg := fmt.Sprintf("💸 По заказу №%d оформлен возврат %.2f ₽ тем же способом, которым производилась оплата. Обычно деньги поступают в течение нескольких рабочих дней.", orderID, refunded)
+	bh.sendMessage(order.UserChatID, clientMsg)
+
+	if lastErr != nil {
+		return refunded, fmt.Errorf("возвращено %.2f ₽ из %.2f ₽: %w", refunded, amount, lastErr)
+	}
+	return refunded, nil
+}
+
+// createProviderRefund резервирует сумму возврата в журнале и отправляет запрос провайдеру.
+// Если провайдер не поддерживает возвраты через API, резерв снимается и возвращается payments.ErrNotSupported.
+func (bh *BotHandler) createProviderRefund(provider payments.PaymentProvider, payment models.Payment, amount float64, description, reason, clientPhone string, initiator models.User) error {
+	record := models.Refund{
+		PaymentID:      payment.ID,
+		OrderID:        payment.OrderID,
+		Amount:         amount,
+		Reason:         sql.NullString{String: reason, Valid: reason != ""},
+		IdempotenceKey: uuid.New().String(),
+	}
+	if initiator.ID != 0 {
+		record.RequestedByUserID = sql.NullInt64{Int64: int64(initiator.ID), Valid: true}
+	}
+
+	refundID, err := db.ReserveRefund(record)
+	if err != nil {
+		return err
+	}
+
+	intent := payments.RefundIntent{
+		PaymentExternalID: payment.ExternalID.String,
+		Amount:            amount,
+		Currency:          payment.Currency,
+		Description:       description,
+		ClientPhone:       clientPhone,
+		IdempotenceKey:    record.IdempotenceKey,
+	}
+	if order, errOrder := db.GetOrderByID(int(payment.OrderID)); errOrder == nil {
+		intent.ClientEmail = receiptEmailForOrder(order)
+		intent.ReceiptLines = receiptLinesForOrder(order)
+		intent.PaymentMode = receiptPaymentModeForOrder(order)
+	}
+	refund, err := provider.Refund(intent)
+	db.SaveRefundRequestPayload(refundID, refund.RequestPayload)
+	if err != nil {
+		if refund.RawResponse != nil || errors.Is(err, payments.ErrNotSupported) {
+			db.MarkRefundFailed(refundID, refund.RawResponse)
+		}
+		return err
+	}
+	if errUpdate := db.UpdateRefundFromProvider(refundID, refund.ExternalID, refund.Status, refund.RawResponse); errUpdate != nil {
+		log.Printf("[REFUNDS] Возврат %s создан, но не сохранен в журнале (запись #%d): %v", refund.ExternalID, refundID, errUpdate)
+	}
+	bh.refreshPaymentFromProvider(provider, payment)
+	return nil
+}
+
+// syncYooKassaRefund запрашивает состояние возврата у YooKassa и обновляет журнал.
+// Клиент уведомляется, когда возврат впервые переходит в статус succeeded.
+func (bh *BotHandler) syncYooKassaRefund(refundExternalID string) error {
+	record, err := db.GetRefundByExternalID(refundExternalID)
+	if err == sql.ErrNoRows {
+		log.Printf("[REFUNDS] Возврат %s не найден в журнале. Игнорируется.", refundExternalID)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	refund, rawResponse, err := payments.GetRefund(bh.yooKassaCredentials(), refundExternalID)
+	if err != nil {
+		return err
+	}
+	if refund.Status == record.Status {
+		return nil
+	}
+	if errUpdate := db.UpdateRefundFromProvider(record.ID, refund.ID, refund.Status, rawResponse); errUpdate != nil {
+		return errUpdate
+	}
+
+	if refund.Status == payments.RefundStatusSucceeded {
+		if order, errOrder := db.GetOrderByID(int(record.OrderID)); errOrder == nil {
+			bh.sendMessage(order.UserChatID, fmt.Sprintf("✅ Возврат %.2f ₽ по заказу №%d выполнен.", record.Amount, record.OrderID))
+		}
+	} else if refund.Status == payments.RefundStatusCanceled {
+		bh.NotifyOperatorsAndGroup(fmt.Sprintf("⚠️ YooKassa отклонила возврат %.2f ₽ по заказу №%d. Требуется ручная проверка.", record.Amount, record.OrderID))
+	}
+	return nil
+}
+
+// resendStaleRefund повторно отправляет в YooKassa возврат, по которому не был получен ответ, с тем же
+// ключом идемпотентности. Если провайдер отклонил запрос или он не был отправлен, резерв суммы снимается.
+func (bh *BotHandler) resendStaleRefund(record models.Refund) error {
+	if len(record.RequestPayload) == 0 {
+		log.Printf("[REFUNDS] Запрос по возврату #%d не был отправлен провайдеру. Резерв снимается.", record.ID)
+		return db.MarkRefundFailed(record.ID, nil)
+	}
+
+	refund, rawResponse, err := payments.ResendRefund(bh.yooKassaCredentials(), record.IdempotenceKey, record.RequestPayload)
+	if err != nil {
+		if rawResponse != nil {
+			log.Printf("[REFUNDS] YooKassa отклонила повтор возврата #%d: %v", record.ID, err)
+			if errMark := db.MarkRefundFailed(record.ID, rawResponse); errMark != nil {
+				return errMark
+			}
+			bh.NotifyOperatorsAndGroup(fmt.Sprintf("⚠️ Возврат %.2f ₽ по заказу №%d не выполнен: YooKassa отклонила запрос. Требуется ручная проверка.", record.Amount, record.OrderID))
+			return nil
+		}
+		return err
+	}
+	if errUpdate := db.UpdateRefundFromProvider(record.ID, refund.ID, refund.Status, rawResponse); errUpdate != nil {
+		return errUpdate
+	}
+	log.Printf("[REFUNDS] Возврат #%d по заказу #%d подтвержден провайдером при сверке: %s, статус %s", record.ID, record.OrderID, refund.ID, refund.Status)
+
+	if payment, errPayment := db.GetPaymentByID(record.PaymentID); errPayment == nil {
+		if provider, ok := bh.paymentProvider(payment.Provider); ok {
+			bh.refreshPaymentFromProvider(provider, payment)
+		}
+	}
+	if refund.Status == payments.RefundStatusSucceeded {
+		if order, errOrder := db.GetOrderByID(int(record.OrderID)); errOrder == nil {
+			bh.sendMessage(order.UserChatID, fmt.Sprintf("✅ Возврат %.2f ₽ по заказу №%d выполнен.", record.Amount, record.OrderID))
+		}
+	}
+	return nil
+}
+
+// refreshPaymentFromProvider обновляет запись платежа (в т.ч. refunded_amount) по данным провайдера.
+func (bh *BotHandler) refreshPaymentFromProvider(provider payments.PaymentProvider, payment models.Payment) {
+	providerPayment, err := provider.GetStatus(payment.ExternalID.String)
+	if errors.Is(err, payments.ErrNotSupported) {
+		return
+	}
+	if err != nil {
+		log.Printf("[REFUNDS] Не удалось обновить платеж #%d после возврата: %v", payment.ID, err)
+		return
+	}
+	bh.syncProviderPayment(payment, providerPayment)
+}
+
+// RefundAfterCancel оформляет полный возврат по отмененному заказу и возвращает строку для сообщения инициатору.
+// Пустая строка означает, что возвращать нечего.
+func (bh *BotHandler) RefundAfterCancel(orderID int64, reason string, initiator models.User) string {
+	refunded, err := bh.RefundOrderPayment(orderID, 0, reason, initiator)
+	if errors.Is(err, ErrNoRefundablePayments) {
+		return ""
+	}
+	if err != nil && refunded == 0 {
+		log.Printf("[REFUNDS] Ошибка возврата по отмененному заказу #%d: %v", orderID, err)
+		return "⚠️ Не удалось оформить возврат оплаты автоматически. Оформите его в личном кабинете платежного провайдера."
+	}
+	if err != nil {
+		return fmt.Sprintf("⚠️ Возврат оформлен частично: %.2f ₽. %v", refunded, err)
+	}
+	return fmt.Sprintf("💸 Оформлен возврат оплаты клиенту: %.2f ₽.", refunded)
+}
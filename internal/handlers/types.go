@@ -5,6 +5,7 @@ import (
 	"Original/internal/config" // Используем Original как имя модуля / Use Original as module name
 	"Original/internal/db"     // Для прямого доступа к db.DB, если потребуется / For direct access to db.DB, if needed
 	"Original/internal/models"
+	"Original/internal/payments"     // Реестр способов оплаты / Payment providers registry
 	"Original/internal/session"      // Менеджер сессий / Session manager
 	"Original/internal/telegram_api" // Клиент Telegram API / Telegram API client
 	"log"
@@ -16,6 +17,9 @@ type HandlerDependencies struct {
 	Config         *config.Config
 	BotClient      *telegram_api.BotClient
 	SessionManager *session.SessionManager
+	// PaymentProviders - включенные способы оплаты. Если не задан, собирается из Config.
+	// PaymentProviders - enabled payment methods. Built from Config when nil.
+	PaymentProviders *payments.Registry
 	// DB *sql.DB // Можно передавать db.DB напрямую, если обработчики часто к нему обращаются,
 	// но лучше, если они будут использовать функции из пакета db.
 	// Глобальный db.DB все еще доступен из пакета db.
@@ -40,6 +44,9 @@ func NewBotHandler(deps HandlerDependencies) *BotHandler {
 		// In a real application, there should be stricter handling here.
 		panic("Не все зависимости для BotHandler были предоставлены.")
 	}
+	if deps.PaymentProviders == nil {
+		deps.PaymentProviders = payments.NewRegistry(deps.Config)
+	}
 	return &BotHandler{Deps: deps}
 }
 
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"Original/internal/constants"
+	"Original/internal/db"
+	"Original/internal/utils"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/OvyFlash/telegram-bot-api"
+)
+
+// CheckVehicleCompliance предупреждает владельцев о машинах с истекшими или истекающими
+// ОСАГО и техосмотром и о подошедшем ТО. О каждой машине сообщается не чаще раза в день.
+func (bh *BotHandler) CheckVehicleCompliance() {
+	now := time.Now()
+	vehicles, compliances, err := db.GetVehiclesDueComplianceNotice(now)
+	if err != nil {
+		log.Printf("[VEHICLE_COMPLIANCE] Ошибка проверки допуска машин: %v", err)
+		return
+	}
+	if len(vehicles) == 0 {
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🚚 *Документы и ТО автопарка*\n")
+	vehicleIDs := make([]int64, 0, len(vehicles))
+	for i, v := range vehicles {
+		vehicleIDs = append(vehicleIDs, v.ID)
+		sb.WriteString(fmt.Sprintf("\n*%s*\n", utils.EscapeTelegramMarkdown(v.DisplayName())))
+		for _, issue := range compliances[i].Issues {
+			sb.WriteString("⛔ " + utils.EscapeTelegramMarkdown(issue) + "\n")
+		}
+		for _, warning := range compliances[i].Warnings {
+			sb.WriteString("⚠️ " + utils.EscapeTelegramMarkdown(warning) + "\n")
+		}
+	}
+	sb.WriteString("\nМашины с ⛔ нельзя назначить на заказ, пока документы или ТО не обновлены.")
+
+	owners, err := db.GetUsersByRole(constants.ROLE_OWNER)
+	if err != nil {
+		log.Printf("[VEHICLE_COMPLIANCE] Ошибка получения владельцев: %v", err)
+		return
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🚚 Открыть автопарк", constants.CALLBACK_PREFIX_OWNER_VEHICLES)),
+	)
+	sent := false
+	for _, owner := range owners {
+		if _, errSend := bh.sendMessageWithKeyboard(owner.ChatID, sb.String(), &keyboard); errSend == nil {
+			sent = true
+		}
+	}
+	// Если не удалось отправить ни одному владельцу, повторим при следующей проверке
+	if sent {
+		db.MarkVehiclesComplianceNotified(vehicleIDs, now)
+	}
+	log.Printf("[VEHICLE_COMPLIANCE] Предупреждение о %d машинах отправлено владельцам: %v", len(vehicles), sent)
+}
+
+// RunVehicleComplianceChecks периодически запускает CheckVehicleCompliance. Блокирует вызывающую горутину.
+func (bh *BotHandler) RunVehicleComplianceChecks(interval time.Duration) {
+	log.Printf("[VEHICLE_COMPLIANCE] Проверка документов и ТО машин запущена с периодом %s.", interval)
+	bh.CheckVehicleCompliance()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		bh.CheckVehicleCompliance()
+	}
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// AccountingDocument - один документ выгрузки в бухгалтерию (1С).
+// Key однозначно определяется исходной записью (например, "sale:123"), поэтому при повторной
+// выгрузке того же периода документ получает тот же Key и тот же GUID и не дублируется в 1С.
+type AccountingDocument struct {
+	Key            string    `json:"key"`                       // Тип и ID исходной записи: sale:ORDERID, receipt_online:PAYMENTID...
+	GUID           string    `json:"guid"`                      // Стабильный UUID документа для 1С, производный от Key
+	Type           string    `json:"type"`                      // constants.ACCOUNTING_DOC_*
+	Number         string    `json:"number"`                    // Номер документа для 1С
+	Date           time.Time `json:"date"`                      // Дата документа
+	Amount         float64   `json:"amount"`                    // Сумма документа, всегда положительная
+	Counterparty   string    `json:"counterparty"`              // Клиент, сотрудник или пусто для внутренних расходов
+	CounterpartyID int64     `json:"counterparty_id,omitempty"` // users.id контрагента
+	Item           string    `json:"item"`                      // Статья: категория заказа, способ оплаты, вид расхода
+	OrderID        int64     `json:"order_id,omitempty"`
+	Comment        string    `json:"comment"`
+}
+
+// AccountingExport - документы за период для загрузки в 1С, упорядоченные по дате и типу.
+type AccountingExport struct {
+	From       time.Time            `json:"from"`
+	To         time.Time            `json:"to"`
+	ExportedAt time.Time            `json:"exported_at"`
+	Documents  []AccountingDocument `json:"documents"`
+}
+
+// Total возвращает сумму документов указанного типа.
+func (e AccountingExport) Total(docType string) float64 {
+	total := 0.0
+	for _, d := range e.Documents {
+		if d.Type == docType {
+			total += d.Amount
+		}
+	}
+	return total
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CashHandover - фактическая сдача наличных водителем в кассу.
+// Сумма распределяется по несданным отчетам водителя от старых к новым (Allocations).
+type CashHandover struct {
+	ID               int64                    `json:"id"`
+	DriverUserID     int64                    `json:"driver_user_id"`
+	DriverName       string                   `json:"driver_name,omitempty"`
+	Amount           float64                  `json:"amount"`
+	ExpectedAmount   sql.NullFloat64          `json:"expected_amount"` // Сколько водитель должен был сдать, если указано
+	HandedAt         time.Time                `json:"handed_at"`
+	ReceivedByUserID sql.NullInt64            `json:"received_by_user_id"`
+	Comment          sql.NullString           `json:"comment"`
+	AckStatus        string                   `json:"ack_status"` // constants.CASH_ACK_*
+	AckAt            sql.NullTime             `json:"ack_at"`
+	AckComment       sql.NullString           `json:"ack_comment"`
+	AckRemindedAt    sql.NullTime             `json:"ack_reminded_at"`
+	CanceledAt       sql.NullTime             `json:"canceled_at"`
+	CreatedAt        time.Time                `json:"created_at"`
+	Allocations      []CashHandoverAllocation `json:"allocations"`
+}
+
+// Difference - разница между сданной и ожидаемой суммой; 0, если ожидаемая сумма не указана.
+func (h CashHandover) Difference() float64 {
+	if !h.ExpectedAmount.Valid {
+		return 0
+	}
+	return h.Amount - h.ExpectedAmount.Float64
+}
+
+// CashHandoverAllocation - часть сдачи, зачтенная в счет отчета водителя.
+type CashHandoverAllocation struct {
+	SettlementID int64   `json:"settlement_id"`
+	Amount       float64 `json:"amount"`
+}
+
+// CashDiscrepancy - запись журнала расхождений по сдаче наличных.
+type CashDiscrepancy struct {
+	ID                int64          `json:"id"`
+	DriverUserID      int64          `json:"driver_user_id"`
+	DriverName        string         `json:"driver_name,omitempty"`
+	HandoverID        sql.NullInt64  `json:"handover_id"`
+	Kind              string         `json:"kind"`   // constants.CASH_DISCREPANCY_*
+	Amount            float64        `json:"amount"` // Недостача и излишек - модуль разницы; для спора - спорная сумма сдачи
+	Note              sql.NullString `json:"note"`
+	CreatedAt         time.Time      `json:"created_at"`
+	ResolvedAt        sql.NullTime   `json:"resolved_at"`
+	ResolvedByUserID  sql.NullInt64  `json:"resolved_by_user_id"`
+	ResolutionComment sql.NullString `json:"resolution_comment"`
+}
+
+// DriverCashBalance - остаток наличных, которые водитель должен сдать, с разбивкой по возрасту долга.
+type DriverCashBalance struct {
+	DriverUserID        int64        `json:"driver_user_id"`
+	Outstanding         float64      `json:"outstanding"`           // Сколько еще не сдано по всем отчетам
+	Debt0To7Days        float64      `json:"debt_0_7_days"`         // Из них по отчетам не старше 7 дней
+	Debt8To30Days       float64      `json:"debt_8_30_days"`        // От 8 до 30 дней
+	DebtOver30Days      float64      `json:"debt_over_30_days"`     // Старше 30 дней
+	OldestDebtDate      sql.NullTime `json:"oldest_debt_date"`      // Дата самого старого несданного отчета
+	OpenReports         int          `json:"open_reports"`          // Отчетов с несданным остатком
+	PendingAcks         int          `json:"pending_acks"`          // Сдач, которые водитель еще не подтвердил
+	OpenDiscrepancies   int          `json:"open_discrepancies"`    // Незакрытых расхождений
+	OpenDiscrepancyDiff float64      `json:"open_discrepancy_diff"` // Сумма незакрытых недостач минус излишков
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CompensationRule задает долю водителя в чистой выручке отчета.
+// Пустые DriverUserID и Category означают "любой водитель" и "любая категория".
+// Правило действует с EffectiveFrom по EffectiveTo включительно; пустой EffectiveTo - бессрочно.
+type CompensationRule struct {
+	ID              int64          `json:"id"`
+	DriverUserID    sql.NullInt64  `json:"driver_user_id"`
+	DriverName      string         `json:"driver_name,omitempty"` // Для отображения, из users
+	Category        sql.NullString `json:"category"`              // constants.CAT_*
+	Share           float64        `json:"share"`                 // Доля от 0 до 1, например 0.35
+	EffectiveFrom   time.Time      `json:"effective_from"`
+	EffectiveTo     sql.NullTime   `json:"effective_to"`
+	Comment         sql.NullString `json:"comment"`
+	IsActive        bool           `json:"is_active"`
+	CreatedByUserID sql.NullInt64  `json:"created_by_user_id"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// DriverShareResolution - результат подбора доли водителя для набора заказов.
+// Rate - средневзвешенная по выручке категорий доля; RuleIDs - примененные правила.
+type DriverShareResolution struct {
+	Rate    float64 `json:"rate"`
+	RuleIDs []int64 `json:"rule_ids"`
+}
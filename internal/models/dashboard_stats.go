@@ -0,0 +1,19 @@
+package models
+
+// DashboardStats - сводные показатели для панели статистики WebApp, посчитанные в базе данных.
+type DashboardStats struct {
+	TotalOrders     int
+	CompletedOrders int                // Выполненные заказы с указанной стоимостью
+	TotalRevenue    float64            // Выручка по выполненным заказам
+	TotalClients    int                // Пользователи с ролью клиента
+	OrdersByType    map[string]int     // Количество заказов по категориям
+	MonthlyRevenue  map[string]float64 // Выручка по месяцам создания заказа, ключ YYYY-MM
+	TopClients      []TopClientStats
+}
+
+// TopClientStats - клиент с наибольшим количеством заказов.
+type TopClientStats struct {
+	Name    string
+	Orders  int
+	Revenue float64
+}
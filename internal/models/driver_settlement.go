@@ -2,20 +2,38 @@ package models
 
 import (
 	"database/sql"
+	"math"
 	"time"
 )
 
-// LoaderPaymentDetail остается без изменений
+// LoaderPaymentDetail - зарплата грузчика в отчете водителя.
+// Объем работ (заказы, часы, этажи, тонны) и тариф сохраняются вместе с суммой,
+// чтобы было видно, из чего сложилась выплата и чем ручная сумма отличается от расчетной.
 type LoaderPaymentDetail struct {
 	LoaderUserID     int64   `json:"loader_user_id"`    // User.ID грузчика
 	LoaderIdentifier string  `json:"loader_identifier"` // Имя или другой идентификатор для отображения
 	Amount           float64 `json:"amount"`
+
+	Category        string  `json:"category,omitempty"` // Основная категория заказов грузчика, по ней выбирается тариф
+	OrdersCount     int     `json:"orders_count,omitempty"`
+	Hours           float64 `json:"hours,omitempty"`
+	Floors          int     `json:"floors,omitempty"`
+	Tons            float64 `json:"tons,omitempty"`
+	RateID          int64   `json:"rate_id,omitempty"`          // loader_pay_rates.id, по которому посчитана сумма
+	SuggestedAmount float64 `json:"suggested_amount,omitempty"` // Сумма по тарифу
+	OverrideReason  string  `json:"override_reason,omitempty"`  // Причина, если водитель указал сумму не по тарифу
+}
+
+// IsOverridden сообщает, отличается ли сумма от расчетной по тарифу.
+func (lp LoaderPaymentDetail) IsOverridden() bool {
+	return lp.RateID != 0 && math.Abs(lp.Amount-lp.SuggestedAmount) >= 0.01
 }
 
 // НОВАЯ СТРУКТУРА для детализации прочих расходов
 type OtherExpenseDetail struct {
-	Description string  `json:"description"`
-	Amount      float64 `json:"amount"`
+	Description    string   `json:"description"`
+	Amount         float64  `json:"amount"`
+	ReceiptFileIDs []string `json:"receipt_file_ids,omitempty"` // Фото чеков: file_id Telegram или имя файла, загруженного из WebApp
 }
 
 // DriverSettlement представляет собой отчет водителя о расходах за определенный период/набор заказов.
@@ -24,13 +42,21 @@ type DriverSettlement struct {
 	DriverUserID           int64                 `json:"driver_user_id"`       // User.ID водителя
 	SettlementTimestamp    time.Time             `json:"settlement_timestamp"` // Время фактического создания отчета
 	CoveredOrdersRevenue   float64               `json:"covered_orders_revenue"`
+	OnlinePaidRevenue      float64               `json:"online_paid_revenue"` // Часть выручки, оплаченная клиентами онлайн (не проходила через водителя)
 	FuelExpense            float64               `json:"fuel_expense"`
+	FuelReceiptFileIDs     []string              `json:"fuel_receipt_file_ids"`  // Фото чеков на топливо
+	VehicleID              sql.NullInt64         `json:"vehicle_id"`             // Машина, на которой выполнялись заказы
+	OdometerStart          sql.NullInt64         `json:"odometer_start"`         // Показания одометра в начале смены, км
+	OdometerEnd            sql.NullInt64         `json:"odometer_end"`           // Показания одометра в конце смены, км
 	OtherExpensesJSON      sql.NullString        `json:"-"`                      // ИЗМЕНЕНО: JSON строка для хранения в БД [{description: "Парковка", amount: 200}, ...]
 	OtherExpenses          []OtherExpenseDetail  `json:"other_expenses" db:"-"`  // ИЗМЕНЕНО: Для использования в коде
 	LoaderPaymentsJSON     sql.NullString        `json:"-"`                      // JSON строка для хранения в БД [{loader_identifier: "Иван", amount: 1000}, ...]
 	LoaderPayments         []LoaderPaymentDetail `json:"loader_payments" db:"-"` // Для использования в коде
 	DriverCalculatedSalary float64               `json:"driver_calculated_salary"`
+	DriverShareRate        sql.NullFloat64       `json:"driver_share_rate"` // Доля водителя, примененная при расчете; хранится, чтобы новые правила не меняли историю
+	ShareRuleIDs           []int64               `json:"share_rule_ids"`    // Правила compensation_rules, по которым определена доля
 	AmountToCashier        float64               `json:"amount_to_cashier"`
+	HandedOverAmount       float64               `json:"handed_over_amount"`   // Сколько из AmountToCashier уже зачтено фактическими сдачами наличных
 	CoveredOrdersCount     int                   `json:"covered_orders_count"` // Информационно: количество заказов, которое водитель указал
 	CreatedAt              time.Time             `json:"created_at"`
 	UpdatedAt              time.Time             `json:"updated_at"`
@@ -47,6 +73,23 @@ type DriverSettlement struct {
 	// --- КОНЕЦ ИЗМЕНЕНИЯ ---
 }
 
+// ReceiptsCount - общее количество фото чеков по топливу и прочим расходам.
+func (s DriverSettlement) ReceiptsCount() int {
+	count := len(s.FuelReceiptFileIDs)
+	for _, oe := range s.OtherExpenses {
+		count += len(oe.ReceiptFileIDs)
+	}
+	return count
+}
+
+// OutstandingCash - сколько по отчету еще не сдано в кассу.
+func (s DriverSettlement) OutstandingCash() float64 {
+	if s.PaidToOwnerAt.Valid || s.AmountToCashier <= s.HandedOverAmount {
+		return 0
+	}
+	return s.AmountToCashier - s.HandedOverAmount
+}
+
 // OwnerCashierRecord остается без изменений. Его ReportDate будет соответствовать ReportDate из DriverSettlement.
 type OwnerCashierRecord struct {
 	ID                  int64     `json:"id"`
@@ -74,4 +117,19 @@ type AggregatedDriverSettlementInfo struct {
 	DriverNickname       sql.NullString `json:"driver_nickname" db:"driver_nickname"`
 	TotalAmountToCashier float64        `json:"total_amount_to_cashier" db:"total_amount_to_cashier"`
 	TotalReportsCount    int            `json:"total_reports_count" db:"total_reports_count"`
+
+	// Несданный остаток и его возраст по дате отчета (для актуальных долгов)
+	OutstandingAmount float64      `json:"outstanding_amount" db:"outstanding_amount"`
+	Debt0To7Days      float64      `json:"debt_0_7_days" db:"debt_0_7_days"`
+	Debt8To30Days     float64      `json:"debt_8_30_days" db:"debt_8_30_days"`
+	DebtOver30Days    float64      `json:"debt_over_30_days" db:"debt_over_30_days"`
+	OldestDebtDate    sql.NullTime `json:"oldest_debt_date" db:"oldest_debt_date"`
+}
+
+// MileageKm - пробег по показаниям одометра; ok=false, если показания не введены.
+func (s DriverSettlement) MileageKm() (int64, bool) {
+	if !s.OdometerStart.Valid || !s.OdometerEnd.Valid || s.OdometerEnd.Int64 < s.OdometerStart.Int64 {
+		return 0, false
+	}
+	return s.OdometerEnd.Int64 - s.OdometerStart.Int64, true
 }
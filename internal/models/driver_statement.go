@@ -0,0 +1,64 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DriverStatementSettlement - отчет водителя в выписке за период.
+type DriverStatementSettlement struct {
+	SettlementID       int64        `json:"settlement_id"`
+	ReportDate         time.Time    `json:"report_date"`
+	Status             string       `json:"status"`
+	OrderIDs           []int64      `json:"order_ids"`
+	OrdersCount        int          `json:"orders_count"`
+	Revenue            float64      `json:"revenue"`
+	OnlinePaidRevenue  float64      `json:"online_paid_revenue"`
+	FuelExpense        float64      `json:"fuel_expense"`
+	OtherExpenses      float64      `json:"other_expenses"`
+	LoaderPayments     float64      `json:"loader_payments"`
+	DriverSalary       float64      `json:"driver_salary"`
+	AmountToCashier    float64      `json:"amount_to_cashier"`
+	PaidToOwnerAt      sql.NullTime `json:"paid_to_owner_at"`
+	DriverSalaryPaidAt sql.NullTime `json:"driver_salary_paid_at"`
+}
+
+// DriverStatementMovement - движение денег "на руках у водителя": начисление по отчету,
+// сдача денег в кассу или выплата ЗП. Balance - остаток после движения.
+type DriverStatementMovement struct {
+	Date         time.Time `json:"date"`
+	Kind         string    `json:"kind"` // constants.LEDGER_KIND_SETTLEMENT_ACCRUAL, _HANDOVER или LEDGER_KIND_DRIVER_SALARY_PAID
+	SettlementID int64     `json:"settlement_id"`
+	Description  string    `json:"description"`
+	Amount       float64   `json:"amount"`
+	Balance      float64   `json:"balance"`
+}
+
+// DriverStatementTotals - итоги выписки за период.
+type DriverStatementTotals struct {
+	OrdersCount       int     `json:"orders_count"`
+	Revenue           float64 `json:"revenue"`
+	OnlinePaidRevenue float64 `json:"online_paid_revenue"`
+	FuelExpense       float64 `json:"fuel_expense"`
+	OtherExpenses     float64 `json:"other_expenses"`
+	LoaderPayments    float64 `json:"loader_payments"`
+	DriverSalary      float64 `json:"driver_salary"`
+	HandedOver        float64 `json:"handed_over"` // Сдано в кассу за период
+	SalaryPaid        float64 `json:"salary_paid"` // Выплачено ЗП за период
+}
+
+// DriverStatement - выписка по водителю за период (границы включительно).
+// Остаток - деньги, которые числятся за водителем: наличная выручка за вычетом расходов,
+// оплаты грузчикам, сданных в кассу сумм и выплаченной ЗП.
+type DriverStatement struct {
+	DriverUserID   int64                       `json:"driver_user_id"`
+	DriverName     string                      `json:"driver_name"`
+	From           time.Time                   `json:"from"`
+	To             time.Time                   `json:"to"`
+	GeneratedAt    time.Time                   `json:"generated_at"`
+	OpeningBalance float64                     `json:"opening_balance"`
+	ClosingBalance float64                     `json:"closing_balance"`
+	Settlements    []DriverStatementSettlement `json:"settlements"`
+	Movements      []DriverStatementMovement   `json:"movements"`
+	Totals         DriverStatementTotals       `json:"totals"`
+}
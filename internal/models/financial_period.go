@@ -0,0 +1,29 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// FinancialPeriod - учетный месяц. В закрытом месяце отчеты водителей, выплаты, расходы и стоимость
+// заказов только читаются; изменить их можно, лишь открыв месяц с указанием причины.
+type FinancialPeriod struct {
+	Month            time.Time      `json:"month"` // Первое число месяца
+	IsClosed         bool           `json:"is_closed"`
+	ClosedAt         sql.NullTime   `json:"closed_at"`
+	ClosedByUserID   sql.NullInt64  `json:"closed_by_user_id"`
+	ReopenedAt       sql.NullTime   `json:"reopened_at"`
+	ReopenedByUserID sql.NullInt64  `json:"reopened_by_user_id"`
+	ReopenReason     sql.NullString `json:"reopen_reason"`
+}
+
+// FinancialPeriodEvent - запись журнала закрытия и открытия месяцев.
+type FinancialPeriodEvent struct {
+	ID        int64          `json:"id"`
+	Month     time.Time      `json:"month"`
+	Action    string         `json:"action"` // constants.FINANCIAL_PERIOD_ACTION_*
+	Reason    sql.NullString `json:"reason"`
+	UserID    sql.NullInt64  `json:"user_id"`
+	UserName  string         `json:"user_name"`
+	CreatedAt time.Time      `json:"created_at"`
+}
@@ -61,3 +61,87 @@ func (nt *NullTime) UnmarshalJSON(b []byte) error {
 	}
 	return nil
 }
+
+// NullFloat64 - обертка для sql.NullFloat64 для правильной обработки JSON.
+type NullFloat64 struct {
+	sql.NullFloat64
+}
+
+// MarshalJSON реализует интерфейс json.Marshaler для NullFloat64.
+func (nf NullFloat64) MarshalJSON() ([]byte, error) {
+	if !nf.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nf.Float64)
+}
+
+// UnmarshalJSON реализует интерфейс json.Unmarshaler для NullFloat64.
+func (nf *NullFloat64) UnmarshalJSON(b []byte) error {
+	var f *float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	if f != nil {
+		nf.Float64 = *f
+		nf.Valid = true
+	} else {
+		nf.Valid = false
+	}
+	return nil
+}
+
+// NullInt64 - обертка для sql.NullInt64 для правильной обработки JSON.
+type NullInt64 struct {
+	sql.NullInt64
+}
+
+// MarshalJSON реализует интерфейс json.Marshaler для NullInt64.
+func (ni NullInt64) MarshalJSON() ([]byte, error) {
+	if !ni.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(ni.Int64)
+}
+
+// UnmarshalJSON реализует интерфейс json.Unmarshaler для NullInt64.
+func (ni *NullInt64) UnmarshalJSON(b []byte) error {
+	var i *int64
+	if err := json.Unmarshal(b, &i); err != nil {
+		return err
+	}
+	if i != nil {
+		ni.Int64 = *i
+		ni.Valid = true
+	} else {
+		ni.Valid = false
+	}
+	return nil
+}
+
+// NullBool - обертка для sql.NullBool для правильной обработки JSON.
+type NullBool struct {
+	sql.NullBool
+}
+
+// MarshalJSON реализует интерфейс json.Marshaler для NullBool.
+func (nb NullBool) MarshalJSON() ([]byte, error) {
+	if !nb.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(nb.Bool)
+}
+
+// UnmarshalJSON реализует интерфейс json.Unmarshaler для NullBool.
+func (nb *NullBool) UnmarshalJSON(b []byte) error {
+	var v *bool
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v != nil {
+		nb.Bool = *v
+		nb.Valid = true
+	} else {
+		nb.Valid = false
+	}
+	return nil
+}
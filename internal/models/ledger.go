@@ -0,0 +1,72 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LedgerAccount is an account of the general ledger.
+// Balance is the sum of all postings: positive for debit balances (cash, expenses),
+// negative for credit balances (payables, revenue).
+type LedgerAccount struct {
+	ID        int64         `json:"id"`
+	Code      string        `json:"code"` // company_cash, driver_cash:12, staff_payable:7...
+	Type      string        `json:"type"` // constants.LEDGER_ACCOUNT_TYPE_*
+	UserID    sql.NullInt64 `json:"user_id"`
+	Name      string        `json:"name"`
+	Balance   float64       `json:"balance"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+// LedgerJournal is one balanced journal entry. A reversal entry points to the entry it cancels.
+type LedgerJournal struct {
+	ID           int64           `json:"id"`
+	Kind         string          `json:"kind"`        // constants.LEDGER_KIND_*
+	SourceType   string          `json:"source_type"` // constants.LEDGER_SOURCE_*
+	SourceID     int64           `json:"source_id"`
+	Description  string          `json:"description"`
+	PostedAt     time.Time       `json:"posted_at"`
+	ReversalOfID sql.NullInt64   `json:"reversal_of_id"`
+	ReversedByID sql.NullInt64   `json:"reversed_by_id"`
+	Postings     []LedgerPosting `json:"postings,omitempty"`
+}
+
+// LedgerPosting is one line of a journal entry: debit is positive, credit is negative.
+type LedgerPosting struct {
+	ID          int64   `json:"id"`
+	JournalID   int64   `json:"journal_id"`
+	AccountID   int64   `json:"account_id"`
+	AccountCode string  `json:"account_code"`
+	Amount      float64 `json:"amount"`
+}
+
+// LedgerStatementLine is a posting of an account statement with the running balance after it.
+type LedgerStatementLine struct {
+	JournalID   int64     `json:"journal_id"`
+	PostedAt    time.Time `json:"posted_at"`
+	Kind        string    `json:"kind"`
+	SourceType  string    `json:"source_type"`
+	SourceID    int64     `json:"source_id"`
+	Description string    `json:"description"`
+	IsReversal  bool      `json:"is_reversal"`
+	Amount      float64   `json:"amount"`
+	Balance     float64   `json:"balance"`
+}
+
+// LedgerStatement is an account statement for a period.
+type LedgerStatement struct {
+	Account        LedgerAccount         `json:"account"`
+	From           time.Time             `json:"from"`
+	To             time.Time             `json:"to"`
+	OpeningBalance float64               `json:"opening_balance"`
+	ClosingBalance float64               `json:"closing_balance"`
+	Lines          []LedgerStatementLine `json:"lines"`
+}
+
+// LedgerIntegrityIssue describes a discrepancy found by the ledger integrity check.
+type LedgerIntegrityIssue struct {
+	Kind        string  `json:"kind"` // unbalanced_journal, trial_balance, driver_cash_mismatch, missing_entry
+	Description string  `json:"description"`
+	Expected    float64 `json:"expected,omitempty"`
+	Actual      float64 `json:"actual,omitempty"`
+}
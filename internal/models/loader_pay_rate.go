@@ -0,0 +1,30 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LoaderPayRate - тариф оплаты грузчика. Пустые LoaderUserID и Category означают
+// "любой грузчик" и "любая категория". Сумма = за заказ * заказы + за час * часы + за этаж * этажи + за тонну * тонны.
+type LoaderPayRate struct {
+	ID              int64          `json:"id"`
+	LoaderUserID    sql.NullInt64  `json:"loader_user_id"`
+	LoaderName      string         `json:"loader_name,omitempty"` // Для отображения, из users
+	Category        sql.NullString `json:"category"`
+	PerOrder        float64        `json:"per_order"`
+	PerHour         float64        `json:"per_hour"`
+	PerFloor        float64        `json:"per_floor"`
+	PerTon          float64        `json:"per_ton"`
+	EffectiveFrom   time.Time      `json:"effective_from"`
+	EffectiveTo     sql.NullTime   `json:"effective_to"`
+	Comment         sql.NullString `json:"comment"`
+	IsActive        bool           `json:"is_active"`
+	CreatedByUserID sql.NullInt64  `json:"created_by_user_id"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// Calculate считает сумму по тарифу для указанного объема работ.
+func (r LoaderPayRate) Calculate(ordersCount int, hours float64, floors int, tons float64) float64 {
+	return r.PerOrder*float64(ordersCount) + r.PerHour*hours + r.PerFloor*float64(floors) + r.PerTon*tons
+}
@@ -34,4 +34,13 @@ type Order struct {
 	CreatedAt               time.Time
 	UpdatedAt               time.Time
 	IsDriverSettled         bool `json:"is_driver_settled" db:"is_driver_settled"`
+	// Объем и доступ: какие из полей спрашиваются, зависит от подкатегории (constants.OrderDetailStepsMap).
+	VolumeM3     NullFloat64 `json:"volume_m3"`
+	TonnageT     NullFloat64 `json:"tonnage_t"`
+	Floor        NullInt64   `json:"floor"`
+	HasElevator  NullBool    `json:"has_elevator"`
+	NeedsLoaders NullBool    `json:"needs_loaders"`
+	// Состав исполнителей из варианта стоимости, выбранного клиентом (models.OrderQuoteOption).
+	RequiredDrivers NullInt64 `json:"required_drivers"`
+	RequiredLoaders NullInt64 `json:"required_loaders"`
 }
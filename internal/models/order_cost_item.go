@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// OrderCostItem is one line of an order's cost breakdown (removal, loading, demolition, disposal fee...).
+// Each line becomes a separate position of the fiscal receipt; the sum of lines equals Order.Cost.
+type OrderCostItem struct {
+	ID          int64     `json:"id"`
+	OrderID     int64     `json:"order_id"`
+	Kind        string    `json:"kind"`        // constants.COST_ITEM_*
+	Description string    `json:"description"` // Text printed on the receipt
+	Amount      float64   `json:"amount"`
+	Position    int       `json:"position"` // Order of lines as entered by the operator
+	CreatedAt   time.Time `json:"created_at"`
+}
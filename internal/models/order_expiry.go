@@ -0,0 +1,16 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// StaleOrder - заказ, который ждет подтверждения стоимости или оплаты и давно не менялся.
+type StaleOrder struct {
+	OrderID        int64
+	UserChatID     int64
+	Status         string
+	Cost           sql.NullFloat64
+	LastActivityAt time.Time    // Последнее изменение заказа (orders.updated_at), от него считается срок
+	ReminderAt     sql.NullTime // Когда клиенту напомнили об автоотмене
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// OrderQuote - предложение клиенту нескольких вариантов стоимости заказа со сроком ответа.
+// На заказ действует не больше одного предложения в статусе pending.
+type OrderQuote struct {
+	ID              int64              `json:"id"`
+	OrderID         int64              `json:"order_id"`
+	Status          string             `json:"status"` // constants.QUOTE_STATUS_*
+	ExpiresAt       time.Time          `json:"expires_at"`
+	ChosenOptionID  sql.NullInt64      `json:"chosen_option_id"`
+	AnsweredAt      sql.NullTime       `json:"answered_at"`
+	CreatedByUserID sql.NullInt64      `json:"created_by_user_id"`
+	CreatedAt       time.Time          `json:"created_at"`
+	Options         []OrderQuoteOption `json:"options"`
+	ClientChatID    int64              `json:"client_chat_id,omitempty"` // Заполняется при выборке истекших предложений
+}
+
+// OrderQuoteOption - вариант стоимости: цена и состав исполнителей, который понадобится на заказе.
+type OrderQuoteOption struct {
+	ID       int64   `json:"id"`
+	QuoteID  int64   `json:"quote_id"`
+	Position int     `json:"position"`
+	Title    string  `json:"title"`
+	Cost     float64 `json:"cost"`
+	Drivers  int     `json:"drivers"`
+	Loaders  int     `json:"loaders"`
+}
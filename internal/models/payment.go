@@ -0,0 +1,29 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Payment represents a row of the payments ledger: one attempt to collect money for an order through a provider.
+type Payment struct {
+	ID              int64           `json:"id"`
+	OrderID         int64           `json:"order_id"`
+	Provider        string          `json:"provider"`             // e.g. "yookassa"
+	ExternalID      sql.NullString  `json:"external_id"`          // Payment ID on the provider side
+	Amount          float64         `json:"amount"`               // Requested amount
+	Currency        string          `json:"currency"`             // e.g. "RUB"
+	Status          string          `json:"status"`               // constants.PAYMENT_STATUS_*
+	RefundedAmount  float64         `json:"refunded_amount"`      // Total refunded so far, as reported by the provider
+	IdempotenceKey  string          `json:"idempotence_key"`      // Key sent with the create request; reused on retries
+	ConfirmationURL sql.NullString  `json:"confirmation_url"`     // Link the client follows to pay
+	RequestPayload  json.RawMessage `json:"request_payload"`      // Body sent to the provider on creation
+	ResponsePayload json.RawMessage `json:"response_payload"`     // Last payment object received from the provider API
+	WebhookPayload  json.RawMessage `json:"last_webhook_payload"` // Last webhook notification body
+	PaidAt          sql.NullTime    `json:"paid_at"`              // Set when the payment first reaches "succeeded"
+	// Set when the order was canceled while the payment was open; cleared once the provider reports a final status
+	CanceledLocallyAt sql.NullTime `json:"canceled_locally_at"`
+	CreatedAt         time.Time    `json:"created_at"`
+	UpdatedAt         time.Time    `json:"updated_at"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// PayrollRun - платежная ведомость за период: черновик с суммами к выплате водителям и грузчикам,
+// который владелец проверяет и проводит одной операцией.
+type PayrollRun struct {
+	ID                int64            `json:"id"`
+	PeriodFrom        time.Time        `json:"period_from"`
+	PeriodTo          time.Time        `json:"period_to"`
+	Status            string           `json:"status"` // constants.PAYROLL_STATUS_*
+	CreatedByUserID   sql.NullInt64    `json:"created_by_user_id"`
+	CreatedAt         time.Time        `json:"created_at"`
+	ConfirmedByUserID sql.NullInt64    `json:"confirmed_by_user_id"`
+	ConfirmedAt       sql.NullTime     `json:"confirmed_at"`
+	ReversedByUserID  sql.NullInt64    `json:"reversed_by_user_id"`
+	ReversedAt        sql.NullTime     `json:"reversed_at"`
+	ReverseReason     sql.NullString   `json:"reverse_reason"`
+	Lines             []PayrollRunLine `json:"lines,omitempty"`
+	TotalAmount       float64          `json:"total_amount"`
+}
+
+// PayrollRunLine - строка ведомости. OwedAmount - долг на момент расчета, Amount - сумма к выплате
+// (владелец может ее изменить; 0 - не платить).
+type PayrollRunLine struct {
+	ID         int64          `json:"id"`
+	RunID      int64          `json:"run_id"`
+	UserID     int64          `json:"user_id"`
+	UserName   string         `json:"user_name"`
+	Role       string         `json:"role"`
+	OwedAmount float64        `json:"owed_amount"`
+	Amount     float64        `json:"amount"`
+	Comment    sql.NullString `json:"comment"`
+	PayoutID   sql.NullInt64  `json:"payout_id"`
+	HasCard    bool           `json:"has_card"`
+}
+
+// PayrollRegistryLine - строка реестра на перечисление в банк.
+type PayrollRegistryLine struct {
+	UserID     int64   `json:"user_id"`
+	UserName   string  `json:"user_name"`
+	CardNumber string  `json:"card_number"`
+	Amount     float64 `json:"amount"`
+	Purpose    string  `json:"purpose"`
+	CardError  string  `json:"card_error,omitempty"` // Почему номер карты недоступен
+}
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"database/sql"
+	"math"
+	"time"
+)
+
+// PriceRule - правило расчета цены заказа. Пустые Category и Subcategory означают "любая".
+// Цена = база + за м³ сверх включенного объема + за этаж без лифта + за км сверх бесплатных,
+// затем надбавки за срочность и выходной в процентах, но не меньше минимальной.
+type PriceRule struct {
+	ID               int64          `json:"id"`
+	Category         sql.NullString `json:"category"`
+	Subcategory      sql.NullString `json:"subcategory"`
+	BasePrice        float64        `json:"base_price"`
+	IncludedVolumeM3 float64        `json:"included_volume_m3"` // Объем, входящий в базовую цену
+	PerM3            float64        `json:"per_m3"`
+	PerFloor         float64        `json:"per_floor"` // За каждый этаж выше первого, если нет лифта
+	UrgentPercent    float64        `json:"urgent_percent"`
+	WeekendPercent   float64        `json:"weekend_percent"`
+	FreeKm           float64        `json:"free_km"` // Расстояние от базы, входящее в базовую цену
+	PerKm            float64        `json:"per_km"`
+	MinPrice         float64        `json:"min_price"`
+	EffectiveFrom    time.Time      `json:"effective_from"`
+	EffectiveTo      sql.NullTime   `json:"effective_to"`
+	Comment          sql.NullString `json:"comment"`
+	IsActive         bool           `json:"is_active"`
+	CreatedByUserID  sql.NullInt64  `json:"created_by_user_id"`
+	CreatedAt        time.Time      `json:"created_at"`
+}
+
+// PriceInput - параметры заказа, от которых зависит цена.
+type PriceInput struct {
+	Category    string    `json:"category"`
+	Subcategory string    `json:"subcategory"`
+	Date        time.Time `json:"date"` // Нулевая дата - день неизвестен, надбавка за выходной не начисляется
+	Urgent      bool      `json:"urgent"`
+	VolumeM3    float64   `json:"volume_m3"`
+	Floor       int       `json:"floor"`
+	HasElevator bool      `json:"has_elevator"`
+	DistanceKm  float64   `json:"distance_km"` // Отрицательное - расстояние неизвестно
+}
+
+// PriceEstimateLine - строка расшифровки расчетной цены.
+type PriceEstimateLine struct {
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+// PriceEstimate - расчетная цена заказа по правилу и диапазон, который показывается клиенту.
+type PriceEstimate struct {
+	RuleID   int64               `json:"rule_id"`
+	Lines    []PriceEstimateLine `json:"lines"`
+	Total    float64             `json:"total"`
+	RangeMin float64             `json:"range_min"`
+	RangeMax float64             `json:"range_max"`
+}
+
+// roundPrice округляет сумму до шага step (step <= 0 - без округления).
+func roundPrice(amount, step float64) float64 {
+	if step <= 0 {
+		return amount
+	}
+	return math.Round(amount/step) * step
+}
+
+// Estimate считает цену по правилу. Итог и границы диапазона ±spreadPercent округляются до roundingStep.
+func (r PriceRule) Estimate(in PriceInput, roundingStep, spreadPercent float64) PriceEstimate {
+	estimate := PriceEstimate{RuleID: r.ID}
+	add := func(label string, amount float64) {
+		if amount > 0 {
+			estimate.Lines = append(estimate.Lines, PriceEstimateLine{Label: label, Amount: amount})
+		}
+	}
+
+	add("Базовая цена", r.BasePrice)
+	if extra := in.VolumeM3 - r.IncludedVolumeM3; extra > 0 {
+		add("Объем сверх включенного", extra*r.PerM3)
+	}
+	if !in.HasElevator && in.Floor > 1 {
+		add("Подъем/спуск без лифта", float64(in.Floor-1)*r.PerFloor)
+	}
+	if extra := in.DistanceKm - r.FreeKm; in.DistanceKm >= 0 && extra > 0 {
+		add("Расстояние от базы", extra*r.PerKm)
+	}
+	subtotal := 0.0
+	for _, line := range estimate.Lines {
+		subtotal += line.Amount
+	}
+	if in.Urgent {
+		add("Срочный заказ", subtotal*r.UrgentPercent/100)
+	}
+	if weekday := in.Date.Weekday(); !in.Date.IsZero() && (weekday == time.Saturday || weekday == time.Sunday) {
+		add("Выходной день", subtotal*r.WeekendPercent/100)
+	}
+
+	for _, line := range estimate.Lines {
+		estimate.Total += line.Amount
+	}
+	if estimate.Total < r.MinPrice {
+		add("Доплата до минимальной цены", r.MinPrice-estimate.Total)
+		estimate.Total = r.MinPrice
+	}
+	estimate.Total = roundPrice(estimate.Total, roundingStep)
+	estimate.RangeMin = roundPrice(estimate.Total*(1-spreadPercent/100), roundingStep)
+	estimate.RangeMax = roundPrice(estimate.Total*(1+spreadPercent/100), roundingStep)
+	return estimate
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// ProfitLossAmounts - выручка и расходы P&L по статьям.
+type ProfitLossAmounts struct {
+	OrdersCount     int     `json:"orders_count"`
+	Revenue         float64 `json:"revenue"`
+	Fuel            float64 `json:"fuel"`
+	OtherExpenses   float64 `json:"other_expenses"`
+	LoaderSalaries  float64 `json:"loader_salaries"`
+	DriverShare     float64 `json:"driver_share"`
+	ReferralBonuses float64 `json:"referral_bonuses"`
+	Disposal        float64 `json:"disposal"`
+	Expenses        float64 `json:"expenses"`
+	Profit          float64 `json:"profit"`
+}
+
+// Add прибавляет суммы другой строки и пересчитывает итоги.
+func (a *ProfitLossAmounts) Add(other ProfitLossAmounts) {
+	a.OrdersCount += other.OrdersCount
+	a.Revenue += other.Revenue
+	a.Fuel += other.Fuel
+	a.OtherExpenses += other.OtherExpenses
+	a.LoaderSalaries += other.LoaderSalaries
+	a.DriverShare += other.DriverShare
+	a.ReferralBonuses += other.ReferralBonuses
+	a.Disposal += other.Disposal
+	a.Finalize()
+}
+
+// Finalize пересчитывает Expenses и Profit по статьям расходов.
+func (a *ProfitLossAmounts) Finalize() {
+	a.Expenses = a.Fuel + a.OtherExpenses + a.LoaderSalaries + a.DriverShare + a.ReferralBonuses + a.Disposal
+	a.Profit = a.Revenue - a.Expenses
+}
+
+// ProfitLossLine - строка P&L за месяц по категории и подкатегории заказов.
+type ProfitLossLine struct {
+	Month       time.Time `json:"month"` // Первое число месяца
+	Category    string    `json:"category"`
+	Subcategory string    `json:"subcategory"`
+	ProfitLossAmounts
+}
+
+// ProfitLossCategory - итог категории/подкатегории за период в сравнении с предыдущим периодом.
+type ProfitLossCategory struct {
+	Category         string            `json:"category"`
+	Subcategory      string            `json:"subcategory"`
+	Current          ProfitLossAmounts `json:"current"`
+	Previous         ProfitLossAmounts `json:"previous"`
+	RevenueChangePct *float64          `json:"revenue_change_pct"`
+	ProfitChangePct  *float64          `json:"profit_change_pct"`
+}
+
+// ProfitLossReport - P&L за период [From, To] и сравнение с предыдущим периодом той же длины.
+type ProfitLossReport struct {
+	From              time.Time            `json:"from"`
+	To                time.Time            `json:"to"`
+	PreviousFrom      time.Time            `json:"previous_from"`
+	PreviousTo        time.Time            `json:"previous_to"`
+	Lines             []ProfitLossLine     `json:"lines"`
+	Categories        []ProfitLossCategory `json:"categories"`
+	Total             ProfitLossAmounts    `json:"total"`
+	PreviousTotal     ProfitLossAmounts    `json:"previous_total"`
+	OrdersChangePct   *float64             `json:"orders_change_pct"`
+	RevenueChangePct  *float64             `json:"revenue_change_pct"`
+	ExpensesChangePct *float64             `json:"expenses_change_pct"`
+	ProfitChangePct   *float64             `json:"profit_change_pct"`
+	GeneratedAt       time.Time            `json:"generated_at"`
+}
+
+// PercentChange - изменение current относительно previous в процентах; nil, если базы для сравнения нет.
+func PercentChange(current, previous float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := (current - previous) / math.Abs(previous) * 100
+	return &change
+}
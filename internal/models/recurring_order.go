@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// RecurringOrder - шаблон регулярного заказа с графиком: планировщик создает по нему
+// обычные заказы на даты StartDate + k*IntervalDays.
+type RecurringOrder struct {
+	ID              int64                    `json:"id"`
+	SourceOrderID   sql.NullInt64            `json:"source_order_id"`
+	UserID          int64                    `json:"user_id"`
+	UserChatID      int64                    `json:"user_chat_id"`
+	Category        string                   `json:"category"`
+	Subcategory     string                   `json:"subcategory"`
+	Name            string                   `json:"name"`
+	Phone           string                   `json:"phone"`
+	Address         string                   `json:"address"`
+	Latitude        float64                  `json:"latitude"`
+	Longitude       float64                  `json:"longitude"`
+	Description     string                   `json:"description"`
+	Payment         string                   `json:"payment"`
+	TimeSlot        sql.NullString           `json:"time_slot"` // Время или интервал, как в orders.time
+	Cost            sql.NullFloat64          `json:"cost"`      // Согласованная цена; без нее заказы создаются на расчет
+	IntervalDays    int                      `json:"interval_days"`
+	StartDate       time.Time                `json:"start_date"`
+	EndDate         sql.NullTime             `json:"end_date"`
+	Status          string                   `json:"status"` // constants.RECURRING_STATUS_*
+	CreatedByUserID sql.NullInt64            `json:"created_by_user_id"`
+	CreatedAt       time.Time                `json:"created_at"`
+	UpdatedAt       time.Time                `json:"updated_at"`
+	Executors       []RecurringOrderExecutor `json:"executors"`
+}
+
+// RecurringOrderExecutor - исполнитель, которого назначают на каждый заказ графика.
+type RecurringOrderExecutor struct {
+	UserID int64  `json:"user_id"`
+	ChatID int64  `json:"chat_id"`
+	Role   string `json:"role"`
+	Name   string `json:"name"`
+}
+
+// RecurringOccurrence - дата графика, на которую уже создан заказ или которую пропустили.
+type RecurringOccurrence struct {
+	ID               int64         `json:"id"`
+	RecurringOrderID int64         `json:"recurring_order_id"`
+	Date             time.Time     `json:"date"`
+	OrderID          sql.NullInt64 `json:"order_id"`
+	Status           string        `json:"status"` // constants.OCCURRENCE_STATUS_*
+}
@@ -7,28 +7,57 @@ import (
 
 // Referral represents a referral in the system.
 type Referral struct {
-	ID              int64 // Primary key
-	InviterID       int64 // User.ID of the inviter
-	InviteeID       int64 // User.ID of the invitee
-	OrderID         int   // Order.ID for which the referral bonus is applied
-	Amount          float64
-	CreatedAt       time.Time
-	Name            string        // Name of the referred user (invitee) for display
-	PaidOut         bool          // True if this specific referral bonus has been paid out
-	PayoutRequestID sql.NullInt64 `db:"payout_request_id"` // ID запроса на выплату, если этот бонус в него включен
+	ID              int64         `json:"id"`         // Primary key
+	InviterID       int64         `json:"inviter_id"` // User.ID of the inviter
+	InviteeID       int64         `json:"invitee_id"` // User.ID of the invitee
+	OrderID         int           `json:"order_id"`   // Order.ID for which the referral bonus is applied
+	Amount          float64       `json:"amount"`
+	CreatedAt       time.Time     `json:"created_at"`
+	Name            string        `json:"name"`                                     // Name of the referred user (invitee) for display
+	InviterName     string        `json:"inviter_name,omitempty"`                   // Имя пригласившего, для очереди проверки
+	PaidOut         bool          `json:"paid_out"`                                 // True if this specific referral bonus has been paid out
+	PayoutRequestID sql.NullInt64 `db:"payout_request_id" json:"payout_request_id"` // ID запроса на выплату, если этот бонус в него включен
+	RuleID          sql.NullInt64 `db:"rule_id" json:"rule_id"`                     // Версия условий программы (referral_rules.id), по которой начислен бонус
+	RuleVersion     sql.NullInt64 `json:"rule_version"`                             // Номер версии условий, для отображения
+	ExpiresAt       sql.NullTime  `db:"expires_at" json:"expires_at"`               // Срок, до которого бонус нужно запросить к выплате
+	ExpiredAt       sql.NullTime  `db:"expired_at" json:"expired_at"`               // Когда бонус сгорел
+	// Проверка на мошенничество: подозрительный бонус удерживается до решения владельца
+	ReviewStatus  sql.NullString `db:"review_status" json:"review_status"`   // constants.REFERRAL_REVIEW_STATUS_*
+	ReviewReasons []string       `db:"review_reasons" json:"review_reasons"` // Причины, по которым бонус удержан
+	ReviewComment sql.NullString `db:"review_comment" json:"review_comment"`
 	// UpdatedAt time.Time // from DB schema
 }
 
 // ReferralPayoutRequest представляет запрос на выплату реферальных бонусов.
 type ReferralPayoutRequest struct {
-	ID             int64          `db:"id"`
-	UserChatID     int64          `db:"user_chat_id"` // ChatID пользователя, запрашивающего выплату
-	Amount         float64        `db:"amount"`       // Общая сумма к выплате
-	Status         string         `db:"status"`       // e.g., "pending", "approved", "rejected", "completed"
-	RequestedAt    time.Time      `db:"requested_at"`
-	ReferralIDs    []int64        // Массив ID рефералов (из таблицы referrals), включенных в эту выплату
-	AdminComment   sql.NullString `db:"admin_comment"`   // Комментарий администратора (например, причина отклонения)
-	ProcessedAt    sql.NullTime   `db:"processed_at"`    // Дата обработки запроса
-	PaymentMethod  sql.NullString `db:"payment_method"`  // Способ выплаты, если нужно
-	PaymentDetails sql.NullString `db:"payment_details"` // Реквизиты для выплаты
+	ID             int64          `db:"id" json:"id"`
+	UserChatID     int64          `db:"user_chat_id" json:"user_chat_id"` // ChatID пользователя, запрашивающего выплату
+	UserName       string         `json:"user_name,omitempty"`            // Имя клиента для очереди выплат
+	Amount         float64        `db:"amount" json:"amount"`             // Общая сумма к выплате
+	Status         string         `db:"status" json:"status"`             // e.g., "pending", "approved", "rejected", "completed"
+	RequestedAt    time.Time      `db:"requested_at" json:"requested_at"`
+	ReferralIDs    []int64        `json:"referral_ids"`                         // Массив ID рефералов (из таблицы referrals), включенных в эту выплату
+	AdminComment   sql.NullString `db:"admin_comment" json:"admin_comment"`     // Комментарий администратора (например, причина отклонения)
+	ProcessedAt    sql.NullTime   `db:"processed_at" json:"processed_at"`       // Дата обработки запроса
+	PaymentMethod  sql.NullString `db:"payment_method" json:"payment_method"`   // constants.REFERRAL_PAYOUT_METHOD_*
+	PaymentDetails sql.NullString `db:"payment_details" json:"payment_details"` // Реквизиты для выплаты: номер карты (в БД зашифрован) или телефон СБП
+	PayoutID       sql.NullInt64  `json:"payout_id"`                            // payouts.id, созданный при выплате
+}
+
+// IsExpired сообщает, что бонус сгорел или срок его действия истек (до ближайшей проверки сроков).
+func (r Referral) IsExpired(now time.Time) bool {
+	if r.ExpiredAt.Valid {
+		return true
+	}
+	return r.ExpiresAt.Valid && !r.PaidOut && !r.PayoutRequestID.Valid && !r.ExpiresAt.Time.After(now)
+}
+
+// IsHeld сообщает, что бонус удержан на проверке или отклонен при проверке.
+func (r Referral) IsHeld() bool {
+	return r.ReviewStatus.Valid && r.ReviewStatus.String != "approved" // constants.REFERRAL_REVIEW_STATUS_APPROVED
+}
+
+// AvailableForPayout сообщает, что бонус можно включить в новый запрос на выплату.
+func (r Referral) AvailableForPayout(now time.Time) bool {
+	return !r.PaidOut && !r.PayoutRequestID.Valid && !r.IsHeld() && !r.IsExpired(now)
 }
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ReferralRule - версия условий реферальной программы.
+// Версии не изменяются: новые условия оформляются новой версией, а каждый начисленный
+// бонус хранит ссылку на версию, по которой он рассчитан (referrals.rule_id).
+// Нулевые MinOrderAmount, MonthlyCap, ExpiryDays и MinPayoutAmount означают "без ограничения".
+type ReferralRule struct {
+	ID              int64          `json:"id"`
+	Version         int            `json:"version"`
+	BonusType       string         `json:"bonus_type"`       // constants.REFERRAL_BONUS_TYPE_*
+	BonusValue      float64        `json:"bonus_value"`      // Сумма в рублях или процент от стоимости заказа
+	MinOrderAmount  float64        `json:"min_order_amount"` // Минимальная стоимость первого заказа друга
+	Categories      []string       `json:"categories"`       // constants.CAT_*; пусто - все категории
+	MonthlyCap      float64        `json:"monthly_cap"`      // Лимит бонусов одного пригласившего за календарный месяц
+	ExpiryDays      int            `json:"expiry_days"`      // Срок жизни невыплаченного бонуса в днях
+	MinPayoutAmount float64        `json:"min_payout_amount"`
+	EffectiveFrom   time.Time      `json:"effective_from"`
+	Comment         sql.NullString `json:"comment"`
+	CreatedByUserID sql.NullInt64  `json:"created_by_user_id"`
+	CreatedAt       time.Time      `json:"created_at"`
+}
+
+// AppliesToCategory проверяет, начисляется ли бонус за заказ этой категории.
+func (r ReferralRule) AppliesToCategory(category string) bool {
+	if len(r.Categories) == 0 {
+		return true
+	}
+	for _, c := range r.Categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
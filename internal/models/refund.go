@@ -0,0 +1,24 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// Refund represents a refund of (part of) a payment from the payments ledger.
+type Refund struct {
+	ID                int64           `json:"id"`
+	PaymentID         int64           `json:"payment_id"`           // Foreign key to Payment.ID
+	OrderID           int64           `json:"order_id"`             // Denormalized Payment.OrderID for quick lookups
+	ExternalID        sql.NullString  `json:"external_id"`          // Refund ID on the provider side
+	Amount            float64         `json:"amount"`               // Refunded amount; less than the payment amount for partial refunds
+	Status            string          `json:"status"`               // constants.REFUND_STATUS_*
+	Reason            sql.NullString  `json:"reason"`               // Why the money is returned (usually the cancellation reason)
+	IdempotenceKey    string          `json:"idempotence_key"`      // Key sent with the refund request
+	RequestedByUserID sql.NullInt64   `json:"requested_by_user_id"` // Operator/admin who initiated the refund
+	RequestPayload    json.RawMessage `json:"request_payload"`
+	ResponsePayload   json.RawMessage `json:"response_payload"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
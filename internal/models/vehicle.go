@@ -0,0 +1,167 @@
+package models
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Vehicle - машина автопарка. Норма расхода задается в литрах на 100 км,
+// цена литра нужна, чтобы пересчитать сумму из отчета водителя в литры.
+type Vehicle struct {
+	ID                  int64          `json:"id"`
+	Plate               string         `json:"plate"`
+	Model               sql.NullString `json:"model"`
+	CapacityM3          float64        `json:"capacity_m3"`
+	CapacityTonnes      float64        `json:"capacity_tonnes"`
+	FuelNormLPer100Km   float64        `json:"fuel_norm_l_per_100km"`
+	FuelPricePerL       float64        `json:"fuel_price_per_l"`
+	DefaultDriverUserID sql.NullInt64  `json:"default_driver_user_id"`
+	DefaultDriverName   string         `json:"default_driver_name,omitempty"` // Для отображения, из users
+	OsagoExpiresOn      sql.NullTime   `json:"osago_expires_on"`
+	InspectionExpiresOn sql.NullTime   `json:"inspection_expires_on"`
+	ServiceIntervalKm   int64          `json:"service_interval_km"`   // 0 - интервал ТО по пробегу не отслеживается
+	ServiceIntervalDays int            `json:"service_interval_days"` // 0 - интервал ТО по времени не отслеживается
+	LastServiceOn       sql.NullTime   `json:"last_service_on"`
+	LastServiceOdometer sql.NullInt64  `json:"last_service_odometer"`
+	IsActive            bool           `json:"is_active"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+}
+
+// DisplayName - госномер и модель для меню и отчетов.
+func (v Vehicle) DisplayName() string {
+	if v.Model.Valid && v.Model.String != "" {
+		return v.Plate + " (" + v.Model.String + ")"
+	}
+	return v.Plate
+}
+
+// FitsCapacity - помещается ли груз; нулевая потребность или грузоподъемность не ограничивают.
+func (v Vehicle) FitsCapacity(requiredM3, requiredTonnes float64) bool {
+	if requiredM3 > 0 && v.CapacityM3 > 0 && requiredM3 > v.CapacityM3 {
+		return false
+	}
+	if requiredTonnes > 0 && v.CapacityTonnes > 0 && requiredTonnes > v.CapacityTonnes {
+		return false
+	}
+	return true
+}
+
+// VehicleCompliance - допуск машины к работе на дату. Issues блокируют назначение на заказ,
+// Warnings - приближающиеся сроки, о которых планировщик предупреждает владельца.
+type VehicleCompliance struct {
+	Issues   []string `json:"issues"`
+	Warnings []string `json:"warnings"`
+}
+
+// Allowed - машину можно назначать на заказ.
+func (c VehicleCompliance) Allowed() bool {
+	return len(c.Issues) == 0
+}
+
+// CheckCompliance проверяет ОСАГО, техосмотр и интервалы ТО на дату asOf.
+// odometer - последние известные показания одометра; незаданные сроки и интервалы не проверяются.
+func (v Vehicle) CheckCompliance(asOf time.Time, odometer sql.NullInt64, warningDays int, warningKm int64) VehicleCompliance {
+	var c VehicleCompliance
+	day := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, asOf.Location())
+	checkDate := func(name string, until sql.NullTime) {
+		if !until.Valid {
+			return
+		}
+		end := time.Date(until.Time.Year(), until.Time.Month(), until.Time.Day(), 0, 0, 0, 0, asOf.Location())
+		switch {
+		case end.Before(day):
+			c.Issues = append(c.Issues, fmt.Sprintf("%s истек %s", name, end.Format("02.01.2006")))
+		case !end.After(day.AddDate(0, 0, warningDays)):
+			c.Warnings = append(c.Warnings, fmt.Sprintf("%s истекает %s", name, end.Format("02.01.2006")))
+		}
+	}
+	checkDate("срок ОСАГО", v.OsagoExpiresOn)
+	checkDate("срок техосмотра", v.InspectionExpiresOn)
+
+	if v.ServiceIntervalDays > 0 {
+		if v.LastServiceOn.Valid {
+			checkDate("срок ТО", sql.NullTime{Time: v.LastServiceOn.Time.AddDate(0, 0, v.ServiceIntervalDays), Valid: true})
+		} else {
+			c.Warnings = append(c.Warnings, "нет данных о последнем ТО")
+		}
+	}
+	if v.ServiceIntervalKm > 0 && v.LastServiceOdometer.Valid && odometer.Valid {
+		sinceService := odometer.Int64 - v.LastServiceOdometer.Int64
+		switch {
+		case sinceService >= v.ServiceIntervalKm:
+			c.Issues = append(c.Issues, fmt.Sprintf("ТО просрочено по пробегу: %d км с последнего ТО", sinceService))
+		case sinceService >= v.ServiceIntervalKm-warningKm:
+			c.Warnings = append(c.Warnings, fmt.Sprintf("до ТО осталось %d км", v.ServiceIntervalKm-sinceService))
+		}
+	}
+	return c
+}
+
+// VehicleMaintenance - запись об обслуживании машины: ТО, ремонт, страховка, техосмотр.
+// Стоимость проводится расходом из кассы компании.
+type VehicleMaintenance struct {
+	ID              int64          `json:"id"`
+	VehicleID       int64          `json:"vehicle_id"`
+	Kind            string         `json:"kind"` // constants.VEHICLE_MAINTENANCE_*
+	PerformedOn     time.Time      `json:"performed_on"`
+	Odometer        sql.NullInt64  `json:"odometer"`
+	Cost            float64        `json:"cost"`
+	Description     sql.NullString `json:"description"`
+	ValidUntil      sql.NullTime   `json:"valid_until"` // Для ОСАГО и техосмотра - новый срок действия
+	CreatedByUserID sql.NullInt64  `json:"created_by_user_id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	CanceledAt      sql.NullTime   `json:"canceled_at"`
+}
+
+// VehicleSuggestion - машина в подсказке при назначении на заказ.
+type VehicleSuggestion struct {
+	Vehicle       Vehicle `json:"vehicle"`
+	FitsCapacity  bool    `json:"fits_capacity"`
+	BusyOrderIDs  []int64 `json:"busy_order_ids"` // Другие заказы на ту же дату, где уже стоит эта машина
+	DriverMatches bool    `json:"driver_matches"` // Закреплена за водителем, назначенным на заказ
+	IsAssigned    bool    `json:"is_assigned"`    // Уже выбрана для этого заказа
+	// ComplianceIssues - причины, по которым машину нельзя назначить (истекла страховка, просрочено ТО)
+	ComplianceIssues []string `json:"compliance_issues"`
+}
+
+// VehicleFuelStat - расход топлива машины за период по отчетам водителей с показаниями одометра.
+type VehicleFuelStat struct {
+	VehicleID            int64   `json:"vehicle_id"`
+	Vehicle              string  `json:"vehicle"`
+	FuelNormLPer100Km    float64 `json:"fuel_norm_l_per_100km"`
+	SettlementsCount     int     `json:"settlements_count"`
+	WithoutOdometerCount int     `json:"without_odometer_count"` // Отчеты без показаний одометра в расчет не входят
+	MileageKm            int64   `json:"mileage_km"`
+	FuelExpense          float64 `json:"fuel_expense"`
+	FuelLiters           float64 `json:"fuel_liters"` // Оценка: сумма / цена литра; 0, если цена не задана
+	RubPer100Km          float64 `json:"rub_per_100km"`
+	LitersPer100Km       float64 `json:"liters_per_100km"`
+	DeviationPct         float64 `json:"deviation_pct"` // Отклонение от нормы в процентах; 0, если норма или цена не заданы
+	IsAnomalous          bool    `json:"is_anomalous"`
+	MaintenanceCost      float64 `json:"maintenance_cost"` // ТО, ремонт и документы за тот же период
+}
+
+// VehicleFuelAnomaly - отдельный отчет водителя с расходом вне допустимого отклонения от нормы.
+type VehicleFuelAnomaly struct {
+	SettlementID   int64     `json:"settlement_id"`
+	VehicleID      int64     `json:"vehicle_id"`
+	Vehicle        string    `json:"vehicle"`
+	DriverName     string    `json:"driver_name"`
+	ReportDate     time.Time `json:"report_date"`
+	MileageKm      int64     `json:"mileage_km"`
+	FuelExpense    float64   `json:"fuel_expense"`
+	LitersPer100Km float64   `json:"liters_per_100km"`
+	DeviationPct   float64   `json:"deviation_pct"`
+	Reason         string    `json:"reason"`
+}
+
+// VehicleFuelReport - отчет владельца о расходе топлива на 100 км.
+type VehicleFuelReport struct {
+	From      time.Time            `json:"from"`
+	To        time.Time            `json:"to"`
+	Tolerance float64              `json:"tolerance"`
+	Vehicles  []VehicleFuelStat    `json:"vehicles"`
+	Anomalies []VehicleFuelAnomaly `json:"anomalies"`
+}
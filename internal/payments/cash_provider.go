@@ -0,0 +1,45 @@
+package payments
+
+import (
+	"fmt"
+
+	"Original/internal/constants"
+)
+
+// CashProvider - оплата наличными исполнителю по выполнению заказа.
+// Платеж записывается в журнал со статусом pending, когда клиент принимает стоимость,
+// и считается полученным, когда водитель сдает отчет по заказу (см. db.SettleCashPaymentsInTx).
+// Провайдер не обращается к внешним системам.
+type CashProvider struct{}
+
+// Name возвращает ключ провайдера.
+func (p *CashProvider) Name() string { return constants.PAYMENT_PROVIDER_CASH }
+
+// Title возвращает название способа оплаты.
+func (p *CashProvider) Title() string { return MethodDisplayName(p.Name()) }
+
+// Prepaid - наличные принимаются по выполнению.
+func (p *CashProvider) Prepaid() bool { return false }
+
+// CreatePayment регистрирует ожидаемую оплату наличными. Ссылки на оплату нет.
+func (p *CashProvider) CreatePayment(intent PaymentIntent) (ProviderPayment, error) {
+	return ProviderPayment{
+		ExternalID: fmt.Sprintf("cash-order-%d-%s", intent.OrderID, intent.IdempotenceKey),
+		Status:     StatusPending,
+	}, nil
+}
+
+// GetStatus не поддерживается: получение наличных подтверждается отчетом водителя.
+func (p *CashProvider) GetStatus(externalID string) (ProviderPayment, error) {
+	return ProviderPayment{}, ErrNotSupported
+}
+
+// Refund не поддерживается: наличные возвращаются вручную.
+func (p *CashProvider) Refund(intent RefundIntent) (ProviderRefund, error) {
+	return ProviderRefund{}, ErrNotSupported
+}
+
+// ParseWebhook не поддерживается: у наличных нет уведомлений.
+func (p *CashProvider) ParseWebhook(body []byte) (WebhookEvent, error) {
+	return WebhookEvent{}, ErrNotSupported
+}
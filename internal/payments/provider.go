@@ -0,0 +1,193 @@
+package payments
+
+import (
+	"errors"
+	"log"
+	"strings"
+
+	"Original/internal/config"
+	"Original/internal/constants"
+)
+
+// ErrNotSupported возвращается провайдером, который не умеет выполнять запрошенную операцию
+// (например, запрос статуса наличного платежа или возврат через Telegram).
+var ErrNotSupported = errors.New("операция не поддерживается провайдером")
+
+// PaymentIntent - параметры платежа по заказу, общие для всех провайдеров.
+type PaymentIntent struct {
+	OrderID        int64
+	Amount         float64
+	Currency       string
+	Description    string
+	ReturnURL      string
+	ClientPhone    string
+	ClientEmail    string // Email для чека; передается вместе с телефоном, если клиент его указал
+	IdempotenceKey string // Сохраняется вызывающей стороной и повторно используется при повторе запроса
+	// ReceiptLines - позиции чека из расшифровки стоимости заказа; пустой список дает одну позицию с Description.
+	ReceiptLines []ReceiptLine
+	PaymentMode  string // Признак способа расчета для чека (constants.RECEIPT_MODE_*)
+}
+
+// ProviderPayment - состояние платежа у провайдера в едином для всех провайдеров виде.
+// Status принимает значения Status* (совпадают с constants.PAYMENT_STATUS_*).
+type ProviderPayment struct {
+	ExternalID      string
+	Status          string
+	ConfirmationURL string  // Ссылка для оплаты; пустая для наличных
+	RefundedAmount  float64 // Сумма уже выполненных возвратов
+	RequestPayload  []byte  // Тело запроса к провайдеру, для журнала платежей
+	RawResponse     []byte  // Тело ответа провайдера, для журнала платежей
+}
+
+// RefundIntent - параметры возврата по ранее проведенному платежу.
+type RefundIntent struct {
+	PaymentExternalID string
+	Amount            float64
+	Currency          string
+	Description       string
+	ClientPhone       string
+	ClientEmail       string
+	IdempotenceKey    string
+	ReceiptLines      []ReceiptLine // Позиции исходного чека; при частичном возврате пересчитываются на сумму возврата
+	PaymentMode       string
+}
+
+// ProviderRefund - состояние возврата у провайдера.
+type ProviderRefund struct {
+	ExternalID     string
+	Status         string
+	RequestPayload []byte
+	RawResponse    []byte
+}
+
+// WebhookEvent - разобранное уведомление провайдера.
+// Уведомление указывает лишь, какой платеж или возврат нужно перепроверить:
+// его состояние всегда запрашивается у провайдера заново, если провайдер это поддерживает.
+type WebhookEvent struct {
+	Event             string
+	PaymentExternalID string
+	RefundExternalID  string // Заполняется для уведомлений о возвратах
+	Status            string // Статус из уведомления; используется, только если GetStatus не поддерживается
+	Amount            float64
+	Raw               []byte
+}
+
+// PaymentProvider - способ оплаты заказа.
+type PaymentProvider interface {
+	// Name возвращает ключ провайдера, он же значение orders.payment и payments.provider.
+	Name() string
+	// Title возвращает название способа оплаты для клиента.
+	Title() string
+	// Prepaid сообщает, оплачивается ли заказ до начала работ (иначе - по выполнению).
+	Prepaid() bool
+	CreatePayment(intent PaymentIntent) (ProviderPayment, error)
+	GetStatus(externalID string) (ProviderPayment, error)
+	Refund(intent RefundIntent) (ProviderRefund, error)
+	ParseWebhook(body []byte) (WebhookEvent, error)
+}
+
+// Registry содержит включенные в конфигурации способы оплаты в порядке их показа клиенту.
+type Registry struct {
+	providers map[string]PaymentProvider
+	order     []string
+}
+
+// NewRegistry собирает реестр провайдеров по списку PaymentMethods из конфигурации.
+// Провайдеры без необходимых реквизитов пропускаются с предупреждением в логе.
+func NewRegistry(cfg *config.Config) *Registry {
+	r := &Registry{providers: make(map[string]PaymentProvider)}
+	yooCreds := Credentials{ShopID: cfg.YooKassaShopID, SecretKey: cfg.YooKassaSecretKey, APIURL: cfg.YooKassaAPIURL}
+	yooConfigured := cfg.YooKassaShopID != "" && cfg.YooKassaSecretKey != ""
+	receiptSettings := ReceiptSettings{VATCode: cfg.ReceiptVATCode, TaxSystemCode: cfg.ReceiptTaxSystemCode}
+
+	for _, method := range cfg.PaymentMethods {
+		method = NormalizeMethod(method)
+		if _, exists := r.providers[method]; exists {
+			continue
+		}
+		var provider PaymentProvider
+		switch method {
+		case constants.PAYMENT_PROVIDER_YOOKASSA:
+			if !yooConfigured {
+				log.Printf("[PAYMENTS] Способ оплаты '%s' пропущен: не заданы YOOKASSA_SHOP_ID/YOOKASSA_SECRET_KEY.", method)
+				continue
+			}
+			provider = &YooKassaProvider{Creds: yooCreds, Receipt: receiptSettings}
+		case constants.PAYMENT_PROVIDER_SBP:
+			if !yooConfigured {
+				log.Printf("[PAYMENTS] Способ оплаты '%s' пропущен: не заданы YOOKASSA_SHOP_ID/YOOKASSA_SECRET_KEY.", method)
+				continue
+			}
+			provider = &YooKassaProvider{Creds: yooCreds, SBP: true, Receipt: receiptSettings}
+		case constants.PAYMENT_PROVIDER_TELEGRAM:
+			if cfg.TelegramPaymentProviderToken == "" {
+				log.Printf("[PAYMENTS] Способ оплаты '%s' пропущен: не задан TELEGRAM_PAYMENT_PROVIDER_TOKEN.", method)
+				continue
+			}
+			provider = &TelegramProvider{BotToken: cfg.TelegramToken, ProviderToken: cfg.TelegramPaymentProviderToken}
+		case constants.PAYMENT_PROVIDER_CASH:
+			provider = &CashProvider{}
+		default:
+			log.Printf("[PAYMENTS] Неизвестный способ оплаты '%s' в PAYMENT_METHODS. Пропущен.", method)
+			continue
+		}
+		r.providers[method] = provider
+		r.order = append(r.order, method)
+	}
+
+	log.Printf("[PAYMENTS] Включенные способы оплаты: %s", strings.Join(r.order, ", "))
+	return r
+}
+
+// Get возвращает включенного провайдера по ключу (устаревшие "now"/"later" тоже принимаются).
+func (r *Registry) Get(method string) (PaymentProvider, bool) {
+	if r == nil {
+		return nil, false
+	}
+	provider, ok := r.providers[NormalizeMethod(method)]
+	return provider, ok
+}
+
+// Enabled возвращает включенных провайдеров в порядке из конфигурации.
+func (r *Registry) Enabled() []PaymentProvider {
+	if r == nil {
+		return nil
+	}
+	result := make([]PaymentProvider, 0, len(r.order))
+	for _, method := range r.order {
+		result = append(result, r.providers[method])
+	}
+	return result
+}
+
+// NormalizeMethod приводит значение orders.payment к ключу провайдера.
+// Заказы, созданные до появления провайдеров, хранят "now" (картой сразу) и "later" (по выполнению).
+func NormalizeMethod(method string) string {
+	method = strings.ToLower(strings.TrimSpace(method))
+	switch method {
+	case constants.PAYMENT_LEGACY_NOW, "card":
+		return constants.PAYMENT_PROVIDER_YOOKASSA
+	case constants.PAYMENT_LEGACY_LATER, "after", "":
+		return constants.PAYMENT_PROVIDER_CASH
+	}
+	return method
+}
+
+// IsPrepaidMethod сообщает, требует ли способ оплаты предоплаты до начала работ.
+// Используется там, где реестр недоступен (форматирование, API); неизвестные значения считаются оплатой по выполнению.
+func IsPrepaidMethod(method string) bool {
+	switch NormalizeMethod(method) {
+	case constants.PAYMENT_PROVIDER_YOOKASSA, constants.PAYMENT_PROVIDER_TELEGRAM, constants.PAYMENT_PROVIDER_SBP:
+		return true
+	}
+	return false
+}
+
+// MethodDisplayName возвращает название способа оплаты для отображения.
+func MethodDisplayName(method string) string {
+	key := NormalizeMethod(method)
+	if title, ok := constants.PaymentMethodDisplayMap[key]; ok {
+		return title
+	}
+	return method
+}
@@ -0,0 +1,82 @@
+package payments
+
+import (
+	"fmt"
+	"math"
+
+	"Original/internal/constants"
+)
+
+// ReceiptLine - позиция чека в терминах заказа: одна статья расшифровки стоимости.
+type ReceiptLine struct {
+	Description    string
+	Amount         float64
+	PaymentSubject string // Признак предмета расчета: service, commodity и т.д.
+}
+
+// ReceiptSettings - фискальные параметры магазина из конфигурации.
+type ReceiptSettings struct {
+	VATCode       int // Код ставки НДС (1 = без НДС)
+	TaxSystemCode int // Код системы налогообложения; 0 - не передается
+}
+
+// BuildReceipt собирает чек на сумму total из позиций lines.
+// Если позиций нет, в чек попадает одна услуга с описанием fallbackDescription.
+// Если сумма позиций не совпадает с total (частичный возврат, изменившаяся стоимость),
+// позиции пропорционально пересчитываются, а копейки округления относятся на последнюю позицию.
+// paymentMode - признак способа расчета (constants.RECEIPT_MODE_*), одинаковый для всех позиций.
+func BuildReceipt(lines []ReceiptLine, total float64, currency, fallbackDescription, paymentMode string, customer Customer, settings ReceiptSettings) *Receipt {
+	vatCode := settings.VATCode
+	if vatCode == 0 {
+		vatCode = 1
+	}
+	if len(lines) == 0 {
+		lines = []ReceiptLine{{Description: fallbackDescription, Amount: total, PaymentSubject: constants.RECEIPT_SUBJECT_SERVICE}}
+	}
+
+	var linesTotal float64
+	for _, line := range lines {
+		linesTotal += line.Amount
+	}
+	totalKopecks := int64(math.Round(total * 100))
+	linesKopecks := int64(math.Round(linesTotal * 100))
+
+	receipt := &Receipt{Customer: customer, TaxSystemCode: settings.TaxSystemCode}
+	var allocated int64
+	for i, line := range lines {
+		amountKopecks := int64(math.Round(line.Amount * 100))
+		if linesKopecks != totalKopecks && linesKopecks > 0 {
+			amountKopecks = int64(math.Round(float64(amountKopecks) * float64(totalKopecks) / float64(linesKopecks)))
+		}
+		if i == len(lines)-1 {
+			amountKopecks = totalKopecks - allocated
+		}
+		allocated += amountKopecks
+		if amountKopecks <= 0 {
+			continue
+		}
+
+		subject := line.PaymentSubject
+		if subject == "" {
+			subject = constants.RECEIPT_SUBJECT_SERVICE
+		}
+		receipt.Items = append(receipt.Items, ReceiptItem{
+			Description:    truncateReceiptDescription(line.Description),
+			Quantity:       "1.00",
+			Amount:         Amount{Value: fmt.Sprintf("%.2f", float64(amountKopecks)/100), Currency: currency},
+			VATCode:        vatCode,
+			PaymentSubject: subject,
+			PaymentMode:    paymentMode,
+		})
+	}
+	return receipt
+}
+
+// truncateReceiptDescription ограничивает название позиции 128 символами - пределом YooKassa.
+func truncateReceiptDescription(description string) string {
+	runes := []rune(description)
+	if len(runes) > 128 {
+		return string(runes[:128])
+	}
+	return description
+}
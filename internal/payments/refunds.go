@@ -0,0 +1,89 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// Статусы возврата в YooKassa.
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusSucceeded = "succeeded"
+	RefundStatusCanceled  = "canceled"
+)
+
+// RefundRequest - структура запроса на создание возврата.
+type RefundRequest struct {
+	PaymentID   string   `json:"payment_id"`
+	Amount      Amount   `json:"amount"`
+	Description string   `json:"description,omitempty"`
+	Receipt     *Receipt `json:"receipt,omitempty"` // Чек возврата обязателен, если при оплате передавался чек
+}
+
+// NewRefundRequest собирает запрос на возврат суммы amountValue по платежу paymentID с чеком возврата.
+// Для полного и частичного возврата используется один и тот же запрос - отличаются сумма и позиции чека.
+func NewRefundRequest(paymentID string, amountValue float64, currency, description string, receipt *Receipt) RefundRequest {
+	return RefundRequest{
+		PaymentID: paymentID,
+		Amount: Amount{
+			Value:    fmt.Sprintf("%.2f", amountValue),
+			Currency: currency,
+		},
+		Description: description,
+		Receipt:     receipt,
+	}
+}
+
+// CreateRefund создает возврат в YooKassa.
+// idempotenceKey должен сохраняться вызывающей стороной, чтобы повтор запроса не вернул деньги дважды.
+func CreateRefund(creds Credentials, idempotenceKey string, requestBody RefundRequest) (RefundObject, []byte, error) {
+	var refund RefundObject
+
+	payload, err := json.Marshal(requestBody)
+	if err != nil {
+		log.Printf("Ошибка маршалинга запроса на возврат к YooKassa: %v", err)
+		return refund, nil, fmt.Errorf("ошибка подготовки запроса: %w", err)
+	}
+
+	return ResendRefund(creds, idempotenceKey, payload)
+}
+
+// ResendRefund повторяет запрос на возврат с сохраненными ключом идемпотентности и телом запроса.
+// Если YooKassa уже создала возврат по этому ключу, она вернет его же, и деньги не уйдут дважды.
+func ResendRefund(creds Credentials, idempotenceKey string, payload []byte) (RefundObject, []byte, error) {
+	var refund RefundObject
+
+	responseBody, err := doRequest(creds, http.MethodPost, "/refunds", idempotenceKey, payload)
+	if err != nil {
+		return refund, responseBody, err
+	}
+
+	if err := json.Unmarshal(responseBody, &refund); err != nil {
+		log.Printf("Ошибка демаршалинга ответа на возврат от API YooKassa: %v", err)
+		return refund, responseBody, fmt.Errorf("ошибка обработки ответа API: %w", err)
+	}
+
+	log.Printf("Создан возврат YooKassa ID: %s по платежу %s, статус: %s", refund.ID, refund.PaymentID, refund.Status)
+	return refund, responseBody, nil
+}
+
+// GetRefund запрашивает актуальное состояние возврата в YooKassa.
+func GetRefund(creds Credentials, refundID string) (RefundObject, []byte, error) {
+	var refund RefundObject
+	if refundID == "" {
+		return refund, nil, fmt.Errorf("не указан ID возврата")
+	}
+
+	responseBody, err := doRequest(creds, http.MethodGet, "/refunds/"+refundID, "", nil)
+	if err != nil {
+		return refund, responseBody, err
+	}
+
+	if err := json.Unmarshal(responseBody, &refund); err != nil {
+		log.Printf("Ошибка демаршалинга возврата %s от API YooKassa: %v", refundID, err)
+		return refund, responseBody, fmt.Errorf("ошибка обработки ответа API: %w", err)
+	}
+	return refund, responseBody, nil
+}
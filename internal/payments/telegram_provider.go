@@ -0,0 +1,147 @@
+package payments
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"Original/internal/constants"
+)
+
+// telegramAPIURL - адрес Bot API для создания счетов.
+const telegramAPIURL = "https://api.telegram.org"
+
+// TelegramProvider - оплата счетом Telegram Payments через подключенного к боту платежного провайдера.
+// Счет создается ссылкой (createInvoiceLink); об оплате бот узнает из сообщения successful_payment,
+// которое передается в ParseWebhook. Запрос статуса и возвраты Bot API для таких платежей не поддерживает.
+type TelegramProvider struct {
+	BotToken      string
+	ProviderToken string
+}
+
+// Name возвращает ключ провайдера.
+func (p *TelegramProvider) Name() string { return constants.PAYMENT_PROVIDER_TELEGRAM }
+
+// Title возвращает название способа оплаты.
+func (p *TelegramProvider) Title() string { return MethodDisplayName(p.Name()) }
+
+// Prepaid - счет Telegram оплачивается до начала работ.
+func (p *TelegramProvider) Prepaid() bool { return true }
+
+// telegramInvoiceLinkRequest - параметры метода createInvoiceLink.
+type telegramInvoiceLinkRequest struct {
+	Title         string                 `json:"title"`
+	Description   string                 `json:"description"`
+	Payload       string                 `json:"payload"`
+	ProviderToken string                 `json:"provider_token"`
+	Currency      string                 `json:"currency"`
+	Prices        []telegramLabeledPrice `json:"prices"`
+}
+
+type telegramLabeledPrice struct {
+	Label  string `json:"label"`
+	Amount int64  `json:"amount"` // В копейках
+}
+
+// TelegramSuccessfulPayment - объект successful_payment из сообщения об оплате.
+type TelegramSuccessfulPayment struct {
+	Currency                string `json:"currency"`
+	TotalAmount             int64  `json:"total_amount"`
+	InvoicePayload          string `json:"invoice_payload"`
+	TelegramPaymentChargeID string `json:"telegram_payment_charge_id"`
+	ProviderPaymentChargeID string `json:"provider_payment_charge_id"`
+}
+
+// TelegramInvoicePayload возвращает payload счета; он же внешний ID платежа в журнале.
+func TelegramInvoicePayload(orderID int64, idempotenceKey string) string {
+	return fmt.Sprintf("order-%d-%s", orderID, idempotenceKey)
+}
+
+// CreatePayment создает ссылку на счет. Повтор с тем же ключом идемпотентности дает тот же payload,
+// поэтому оплата любой из выданных ссылок попадет в одну запись журнала.
+func (p *TelegramProvider) CreatePayment(intent PaymentIntent) (ProviderPayment, error) {
+	payload := TelegramInvoicePayload(intent.OrderID, intent.IdempotenceKey)
+	// Строки счета повторяют позиции чека, чтобы клиент видел расшифровку стоимости.
+	receipt := BuildReceipt(intent.ReceiptLines, intent.Amount, intent.Currency, intent.Description, intent.PaymentMode, Customer{}, ReceiptSettings{})
+	prices := make([]telegramLabeledPrice, 0, len(receipt.Items))
+	for _, item := range receipt.Items {
+		prices = append(prices, telegramLabeledPrice{Label: item.Description, Amount: int64(math.Round(ParseAmount(&item.Amount) * 100))})
+	}
+	request := telegramInvoiceLinkRequest{
+		Title:         fmt.Sprintf("Заказ №%d", intent.OrderID),
+		Description:   intent.Description,
+		Payload:       payload,
+		ProviderToken: p.ProviderToken,
+		Currency:      intent.Currency,
+		Prices:        prices,
+	}
+	requestPayload, _ := json.Marshal(request)
+	result := ProviderPayment{ExternalID: payload, RequestPayload: requestPayload}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	url := fmt.Sprintf("%s/bot%s/createInvoiceLink", telegramAPIURL, p.BotToken)
+	resp, err := client.Post(url, "application/json", bytes.NewReader(requestPayload))
+	if err != nil {
+		log.Printf("Ошибка выполнения запроса createInvoiceLink: %v", err)
+		return result, fmt.Errorf("ошибка выполнения запроса к Telegram: %w", err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return result, fmt.Errorf("ошибка чтения ответа Telegram: %w", err)
+	}
+	result.RawResponse = responseBody
+
+	var apiResponse struct {
+		OK          bool   `json:"ok"`
+		Result      string `json:"result"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(responseBody, &apiResponse); err != nil {
+		return result, fmt.Errorf("ошибка обработки ответа Telegram: %w", err)
+	}
+	if !apiResponse.OK || apiResponse.Result == "" {
+		log.Printf("Telegram отклонил создание счета для заказа #%d: %s", intent.OrderID, apiResponse.Description)
+		return result, fmt.Errorf("ошибка Telegram Payments: %s", apiResponse.Description)
+	}
+
+	result.Status = StatusPending
+	result.ConfirmationURL = apiResponse.Result
+	return result, nil
+}
+
+// GetStatus не поддерживается: Bot API не позволяет запросить состояние счета.
+func (p *TelegramProvider) GetStatus(externalID string) (ProviderPayment, error) {
+	return ProviderPayment{}, ErrNotSupported
+}
+
+// Refund не поддерживается: возврат выполняется в кабинете платежного провайдера.
+func (p *TelegramProvider) Refund(intent RefundIntent) (ProviderRefund, error) {
+	return ProviderRefund{}, ErrNotSupported
+}
+
+// ParseWebhook разбирает объект successful_payment. Telegram присылает его только после списания денег,
+// поэтому событие сразу несет статус succeeded.
+func (p *TelegramProvider) ParseWebhook(body []byte) (WebhookEvent, error) {
+	var payment TelegramSuccessfulPayment
+	if err := json.Unmarshal(body, &payment); err != nil {
+		return WebhookEvent{}, fmt.Errorf("ошибка разбора successful_payment: %w", err)
+	}
+	if !strings.HasPrefix(payment.InvoicePayload, "order-") {
+		return WebhookEvent{}, fmt.Errorf("неизвестный payload счета: '%s'", payment.InvoicePayload)
+	}
+	return WebhookEvent{
+		Event:             "successful_payment",
+		PaymentExternalID: payment.InvoicePayload,
+		Status:            StatusSucceeded,
+		Amount:            float64(payment.TotalAmount) / 100,
+		Raw:               body,
+	}, nil
+}
@@ -8,13 +8,13 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
-
-	"github.com/google/uuid"
 )
 
-// API-адрес YooKassa
-const yooKassaAPIEndpoint = "https://api.yookassa.ru/v3/payments"
+// DefaultAPIURL - базовый адрес API YooKassa.
+const DefaultAPIURL = "https://api.yookassa.ru/v3"
 
 // Определяем структуры для запроса и ответа прямо здесь.
 
@@ -22,8 +22,9 @@ const yooKassaAPIEndpoint = "https://api.yookassa.ru/v3/payments"
 
 // Receipt представляет структуру фискального чека.
 type Receipt struct {
-	Customer Customer      `json:"customer"`
-	Items    []ReceiptItem `json:"items"`
+	Customer      Customer      `json:"customer"`
+	Items         []ReceiptItem `json:"items"`
+	TaxSystemCode int           `json:"tax_system_code,omitempty"` // Код системы налогообложения магазина
 }
 
 // Customer представляет данные о покупателе.
@@ -38,6 +39,9 @@ type ReceiptItem struct {
 	Quantity    string `json:"quantity"`
 	Amount      Amount `json:"amount"`
 	VATCode     int    `json:"vat_code"` // Код ставки НДС. 1 = без НДС.
+	// PaymentSubject - признак предмета расчета (service, commodity), PaymentMode - признак способа расчета.
+	PaymentSubject string `json:"payment_subject,omitempty"`
+	PaymentMode    string `json:"payment_mode,omitempty"`
 }
 
 // --- КОНЕЦ ИЗМЕНЕНИЯ ---
@@ -50,6 +54,13 @@ type PaymentRequest struct {
 	Capture      bool            `json:"capture"`
 	Metadata     json.RawMessage `json:"metadata,omitempty"`
 	Receipt      *Receipt        `json:"receipt,omitempty"` // --- ИЗМЕНЕНИЕ: Добавлено поле для чека ---
+	// PaymentMethodData задает способ оплаты заранее (например, СБП); без него клиент выбирает способ на странице YooKassa.
+	PaymentMethodData *PaymentMethodData `json:"payment_method_data,omitempty"`
+}
+
+// PaymentMethodData - предварительно выбранный способ оплаты.
+type PaymentMethodData struct {
+	Type string `json:"type"` // e.g., "sbp", "bank_card"
 }
 
 // Amount - сумма платежа.
@@ -75,6 +86,8 @@ type PaymentResponse struct {
 	Description  string               `json:"description"`
 	Metadata     json.RawMessage      `json:"metadata"`
 	Test         bool                 `json:"test"`
+	// RefundedAmount заполняется YooKassa, если по платежу были возвраты.
+	RefundedAmount *Amount `json:"refunded_amount,omitempty"`
 }
 
 // ConfirmationResponse - содержит URL для подтверждения платежа пользователем.
@@ -83,37 +96,42 @@ type ConfirmationResponse struct {
 	ConfirmationURL string `json:"confirmation_url"`
 }
 
-// CreatePaymentLink - основная функция, которая создает платежную ссылку.
-// --- НАЧАЛО ИЗМЕНЕНИЯ: Добавлен параметр clientPhone ---
-func CreatePaymentLink(shopID, secretKey string, orderID int64, amountValue float64, currency, description, returnURL, clientPhone string) (string, error) {
-	// --- КОНЕЦ ИЗМЕНЕНИЯ ---
-	log.Printf("Создание платежной ссылки через прямой API-запрос для заказа #%d", orderID)
+// Credentials содержит параметры доступа к API YooKassa.
+// APIURL позволяет направить запросы на локальную заглушку вместо боевого API.
+type Credentials struct {
+	ShopID    string
+	SecretKey string
+	APIURL    string
+}
+
+// baseURL возвращает адрес API без завершающего слэша.
+func (c Credentials) baseURL() string {
+	if c.APIURL == "" {
+		return DefaultAPIURL
+	}
+	return strings.TrimRight(c.APIURL, "/")
+}
+
+// Статусы платежа в YooKassa.
+const (
+	StatusPending           = "pending"
+	StatusWaitingForCapture = "waiting_for_capture"
+	StatusSucceeded         = "succeeded"
+	StatusCanceled          = "canceled"
+)
+
+// IsFinalStatus сообщает, может ли платеж с таким статусом еще измениться.
+func IsFinalStatus(status string) bool {
+	return status == StatusSucceeded || status == StatusCanceled
+}
 
-	// 1. Формируем тело JSON-запроса.
+// NewPaymentRequest собирает запрос на создание платежа по заказу с переданным чеком.
+func NewPaymentRequest(orderID int64, amountValue float64, currency, description, returnURL string, receipt *Receipt) PaymentRequest {
 	metadata, _ := json.Marshal(map[string]string{
 		"order_id": fmt.Sprintf("%d", orderID),
 	})
 
-	// --- НАЧАЛО ИЗМЕНЕНИЯ: Создаем объект чека ---
-	receipt := &Receipt{
-		Customer: Customer{
-			Phone: clientPhone, // Используем номер телефона клиента
-		},
-		Items: []ReceiptItem{
-			{
-				Description: description, // Описание услуги
-				Quantity:    "1.00",
-				Amount: Amount{
-					Value:    fmt.Sprintf("%.2f", amountValue),
-					Currency: currency,
-				},
-				VATCode: 1, // 1 = НДС не облагается
-			},
-		},
-	}
-	// --- КОНЕЦ ИЗМЕНЕНИЯ ---
-
-	requestBody := PaymentRequest{
+	return PaymentRequest{
 		Amount: Amount{
 			Value:    fmt.Sprintf("%.2f", amountValue),
 			Currency: currency,
@@ -125,67 +143,158 @@ func CreatePaymentLink(shopID, secretKey string, orderID int64, amountValue floa
 		Description: description,
 		Capture:     true,
 		Metadata:    metadata,
-		Receipt:     receipt, // --- ИЗМЕНЕНИЕ: Добавляем чек в запрос ---
+		Receipt:     receipt, // Чек собирается вызывающей стороной через BuildReceipt
 	}
+}
+
+// CreatePayment создает платеж в YooKassa.
+// idempotenceKey должен сохраняться вызывающей стороной: повтор запроса с тем же ключом
+// вернет уже созданный платеж, а не новый.
+// Возвращает разобранный ответ и его исходное тело для журнала платежей.
+func CreatePayment(creds Credentials, idempotenceKey string, requestBody PaymentRequest) (PaymentResponse, []byte, error) {
+	var paymentResponse PaymentResponse
 
 	payload, err := json.Marshal(requestBody)
 	if err != nil {
 		log.Printf("Ошибка маршалинга запроса к YooKassa: %v", err)
-		return "", fmt.Errorf("ошибка подготовки запроса: %w", err)
+		return paymentResponse, nil, fmt.Errorf("ошибка подготовки запроса: %w", err)
+	}
+
+	responseBody, err := doRequest(creds, http.MethodPost, "/payments", idempotenceKey, payload)
+	if err != nil {
+		return paymentResponse, responseBody, err
+	}
+
+	if err := json.Unmarshal(responseBody, &paymentResponse); err != nil {
+		log.Printf("Ошибка демаршалинга ответа от API YooKassa: %v", err)
+		return paymentResponse, responseBody, fmt.Errorf("ошибка обработки ответа API: %w", err)
+	}
+
+	if paymentResponse.Confirmation.ConfirmationURL == "" {
+		log.Println("Критическая ошибка: API YooKassa не вернул ссылку на оплату.")
+		return paymentResponse, responseBody, fmt.Errorf("API не вернул ссылку на оплату")
+	}
+
+	log.Printf("Успешно создан платеж YooKassa ID: %s, статус: %s", paymentResponse.ID, paymentResponse.Status)
+	return paymentResponse, responseBody, nil
+}
+
+// GetPayment запрашивает актуальное состояние платежа в YooKassa.
+func GetPayment(creds Credentials, paymentID string) (PaymentResponse, []byte, error) {
+	var paymentResponse PaymentResponse
+	if paymentID == "" {
+		return paymentResponse, nil, fmt.Errorf("не указан ID платежа")
+	}
+
+	responseBody, err := doRequest(creds, http.MethodGet, "/payments/"+paymentID, "", nil)
+	if err != nil {
+		return paymentResponse, responseBody, err
+	}
+
+	if err := json.Unmarshal(responseBody, &paymentResponse); err != nil {
+		log.Printf("Ошибка демаршалинга платежа %s от API YooKassa: %v", paymentID, err)
+		return paymentResponse, responseBody, fmt.Errorf("ошибка обработки ответа API: %w", err)
+	}
+	return paymentResponse, responseBody, nil
+}
+
+// doRequest выполняет запрос к API YooKassa и возвращает тело успешного ответа.
+// При ошибке API тело ответа тоже возвращается, чтобы его можно было сохранить.
+func doRequest(creds Credentials, method, path, idempotenceKey string, payload []byte) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewBuffer(payload)
 	}
 
-	// 2. Создаем HTTP-клиент и сам запрос.
 	client := &http.Client{Timeout: 15 * time.Second}
-	req, err := http.NewRequestWithContext(context.Background(), "POST", yooKassaAPIEndpoint, bytes.NewBuffer(payload))
+	req, err := http.NewRequestWithContext(context.Background(), method, creds.baseURL()+path, body)
 	if err != nil {
 		log.Printf("Ошибка создания HTTP-запроса к YooKassa: %v", err)
-		return "", fmt.Errorf("ошибка создания HTTP-запроса: %w", err)
+		return nil, fmt.Errorf("ошибка создания HTTP-запроса: %w", err)
 	}
 
-	// 3. Устанавливаем необходимые заголовки.
-	req.SetBasicAuth(shopID, secretKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Idempotence-Key", uuid.New().String())
+	req.SetBasicAuth(creds.ShopID, creds.SecretKey)
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if idempotenceKey != "" {
+		req.Header.Set("Idempotence-Key", idempotenceKey)
+	}
 
-	// 4. Выполняем запрос.
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Printf("Ошибка выполнения HTTP-запроса к YooKassa: %v", err)
-		return "", fmt.Errorf("ошибка выполнения запроса к API YooKassa: %w", err)
+		return nil, fmt.Errorf("ошибка выполнения запроса к API YooKassa: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 5. Читаем и обрабатываем ответ.
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("Ошибка чтения ответа от API YooKassa: %v", err)
-		return "", fmt.Errorf("ошибка чтения ответа API: %w", err)
+		return nil, fmt.Errorf("ошибка чтения ответа API: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		log.Printf("API YooKassa вернул ошибку: статус %d, тело: %s", resp.StatusCode, string(responseBody))
-		return "", fmt.Errorf("ошибка API YooKassa, статус: %d", resp.StatusCode)
-	}
-
-	var paymentResponse PaymentResponse
-	if err := json.Unmarshal(responseBody, &paymentResponse); err != nil {
-		log.Printf("Ошибка демаршалинга ответа от API YooKassa: %v", err)
-		return "", fmt.Errorf("ошибка обработки ответа API: %w", err)
-	}
-
-	// 6. Проверяем и возвращаем ссылку на оплату.
-	if paymentResponse.Confirmation.ConfirmationURL == "" {
-		log.Println("Критическая ошибка: API YooKassa не вернул ссылку на оплату.")
-		return "", fmt.Errorf("API не вернул ссылку на оплату")
+		log.Printf("API YooKassa вернул ошибку: %s %s, статус %d, тело: %s", method, path, resp.StatusCode, string(responseBody))
+		return responseBody, fmt.Errorf("ошибка API YooKassa, статус: %d", resp.StatusCode)
 	}
+	return responseBody, nil
+}
 
-	log.Printf("Успешно создан платеж YooKassa ID: %s, статус: %s", paymentResponse.ID, paymentResponse.Status)
-	return paymentResponse.Confirmation.ConfirmationURL, nil
+// RefundObject - объект возврата: ответ API на создание возврата и объект уведомлений refund.*.
+type RefundObject struct {
+	ID          string    `json:"id"`
+	PaymentID   string    `json:"payment_id"`
+	Status      string    `json:"status"`
+	Amount      Amount    `json:"amount"`
+	Description string    `json:"description,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 // YooKassaNotification представляет структуру входящего уведомления от ЮKassa.
+// Object оставлен сырым: для событий payment.* это платеж, для refund.* - возврат.
 type YooKassaNotification struct {
 	Type   string          `json:"type"`   // e.g., "notification"
 	Event  string          `json:"event"`  // e.g., "payment.succeeded"
-	Object PaymentResponse `json:"object"` // Содержит полную информацию о платеже
+	Object json.RawMessage `json:"object"` // Содержит полную информацию об объекте события
+}
+
+// PaymentID возвращает ID платежа, к которому относится уведомление.
+func (n YooKassaNotification) PaymentID() (string, error) {
+	if strings.HasPrefix(n.Event, "refund.") {
+		var refund RefundObject
+		if err := json.Unmarshal(n.Object, &refund); err != nil {
+			return "", fmt.Errorf("ошибка разбора объекта возврата: %w", err)
+		}
+		return refund.PaymentID, nil
+	}
+	var payment PaymentResponse
+	if err := json.Unmarshal(n.Object, &payment); err != nil {
+		return "", fmt.Errorf("ошибка разбора объекта платежа: %w", err)
+	}
+	return payment.ID, nil
+}
+
+// RefundID возвращает ID возврата для уведомлений refund.* и пустую строку для остальных.
+func (n YooKassaNotification) RefundID() string {
+	if !strings.HasPrefix(n.Event, "refund.") {
+		return ""
+	}
+	var refund RefundObject
+	if err := json.Unmarshal(n.Object, &refund); err != nil {
+		return ""
+	}
+	return refund.ID
+}
+
+// ParseAmount переводит строковую сумму YooKassa в число.
+func ParseAmount(a *Amount) float64 {
+	if a == nil {
+		return 0
+	}
+	value, err := strconv.ParseFloat(a.Value, 64)
+	if err != nil {
+		return 0
+	}
+	return value
 }
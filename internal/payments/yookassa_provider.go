@@ -0,0 +1,126 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"Original/internal/constants"
+)
+
+// YooKassaProvider - оплата через YooKassa: картой на платежной странице или, при SBP = true, через СБП.
+// Оба варианта используют один магазин и одинаково приходят в вебхук /api/payments/yookassa/webhook.
+type YooKassaProvider struct {
+	Creds   Credentials
+	SBP     bool            // Платеж создается сразу со способом "sbp": клиенту показывается QR-код СБП
+	Receipt ReceiptSettings // Ставка НДС и система налогообложения для чеков
+}
+
+// Name возвращает ключ провайдера.
+func (p *YooKassaProvider) Name() string {
+	if p.SBP {
+		return constants.PAYMENT_PROVIDER_SBP
+	}
+	return constants.PAYMENT_PROVIDER_YOOKASSA
+}
+
+// Title возвращает название способа оплаты.
+func (p *YooKassaProvider) Title() string {
+	return MethodDisplayName(p.Name())
+}
+
+// Prepaid - оплата через YooKassa всегда вносится до начала работ.
+func (p *YooKassaProvider) Prepaid() bool { return true }
+
+// CreatePayment создает платеж в YooKassa и возвращает ссылку на оплату.
+// Для СБП страница по ссылке показывает QR-код на компьютере и список банков на телефоне.
+func (p *YooKassaProvider) CreatePayment(intent PaymentIntent) (ProviderPayment, error) {
+	receipt := BuildReceipt(intent.ReceiptLines, intent.Amount, intent.Currency, intent.Description, receiptPaymentMode(intent.PaymentMode),
+		Customer{Phone: intent.ClientPhone, Email: intent.ClientEmail}, p.Receipt)
+	requestBody := NewPaymentRequest(intent.OrderID, intent.Amount, intent.Currency, intent.Description, intent.ReturnURL, receipt)
+	if p.SBP {
+		requestBody.PaymentMethodData = &PaymentMethodData{Type: "sbp"}
+	}
+	requestPayload, _ := json.Marshal(requestBody)
+
+	paymentResponse, rawResponse, err := CreatePayment(p.Creds, intent.IdempotenceKey, requestBody)
+	result := yooKassaProviderPayment(paymentResponse, rawResponse)
+	result.RequestPayload = requestPayload
+	return result, err
+}
+
+// GetStatus запрашивает актуальное состояние платежа.
+func (p *YooKassaProvider) GetStatus(externalID string) (ProviderPayment, error) {
+	paymentResponse, rawResponse, err := GetPayment(p.Creds, externalID)
+	return yooKassaProviderPayment(paymentResponse, rawResponse), err
+}
+
+// Refund создает возврат по платежу. Чек возврата повторяет позиции чека оплаты в пропорции к сумме возврата.
+func (p *YooKassaProvider) Refund(intent RefundIntent) (ProviderRefund, error) {
+	receipt := BuildReceipt(intent.ReceiptLines, intent.Amount, intent.Currency, intent.Description, receiptPaymentMode(intent.PaymentMode),
+		Customer{Phone: intent.ClientPhone, Email: intent.ClientEmail}, p.Receipt)
+	requestBody := NewRefundRequest(intent.PaymentExternalID, intent.Amount, intent.Currency, intent.Description, receipt)
+	requestPayload, _ := json.Marshal(requestBody)
+
+	refund, rawResponse, err := CreateRefund(p.Creds, intent.IdempotenceKey, requestBody)
+	return ProviderRefund{
+		ExternalID:     refund.ID,
+		Status:         refund.Status,
+		RequestPayload: requestPayload,
+		RawResponse:    rawResponse,
+	}, err
+}
+
+// ParseWebhook извлекает из уведомления YooKassa ID платежа и, для событий refund.*, ID возврата.
+func (p *YooKassaProvider) ParseWebhook(body []byte) (WebhookEvent, error) {
+	var notification YooKassaNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return WebhookEvent{}, fmt.Errorf("ошибка разбора уведомления YooKassa: %w", err)
+	}
+	paymentID, err := notification.PaymentID()
+	if err != nil {
+		return WebhookEvent{}, err
+	}
+	if paymentID == "" {
+		return WebhookEvent{}, fmt.Errorf("в уведомлении '%s' нет ID платежа", notification.Event)
+	}
+	return WebhookEvent{
+		Event:             notification.Event,
+		PaymentExternalID: paymentID,
+		RefundExternalID:  notification.RefundID(),
+		Raw:               body,
+	}, nil
+}
+
+// receiptPaymentMode возвращает признак способа расчета; по умолчанию - полная предоплата,
+// так как онлайн-оплата вносится до выполнения заказа.
+func receiptPaymentMode(mode string) string {
+	if mode == "" {
+		return constants.RECEIPT_MODE_PREPAYMENT
+	}
+	return mode
+}
+
+// yooKassaProviderPayment переводит ответ YooKassa в общий вид.
+func yooKassaProviderPayment(paymentResponse PaymentResponse, rawResponse []byte) ProviderPayment {
+	return ProviderPayment{
+		ExternalID:      paymentResponse.ID,
+		Status:          paymentResponse.Status,
+		ConfirmationURL: paymentResponse.Confirmation.ConfirmationURL,
+		RefundedAmount:  ParseAmount(paymentResponse.RefundedAmount),
+		RawResponse:     rawResponse,
+	}
+}
+
+// MetadataOrderID извлекает ID заказа из метаданных платежа YooKassa (для платежей, созданных до журнала).
+func MetadataOrderID(rawPayment []byte) (string, bool) {
+	var paymentResponse PaymentResponse
+	if err := json.Unmarshal(rawPayment, &paymentResponse); err != nil {
+		return "", false
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal(paymentResponse.Metadata, &metadata); err != nil {
+		return "", false
+	}
+	orderID, ok := metadata["order_id"]
+	return orderID, ok
+}
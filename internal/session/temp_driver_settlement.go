@@ -10,7 +10,9 @@ import (
 type TempDriverSettlementData struct {
 	CurrentStep            string
 	CoveredOrdersRevenue   float64
+	OnlinePaidRevenue      float64 // Часть выручки, оплаченная клиентами онлайн, а не водителю
 	FuelExpense            float64
+	FuelReceiptFileIDs     []string                    // Фото чеков на топливо
 	OtherExpenses          []models.OtherExpenseDetail // Список прочих расходов
 	CurrentLoaderIndex     int
 	LoadersCount           int
@@ -21,6 +23,11 @@ type TempDriverSettlementData struct {
 	FieldToEditByOwner     string
 	DriverCalculatedSalary float64
 	AmountToCashier        float64
+	DriverShareRate        float64 // Доля водителя по правилам compensation_rules на момент начала отчета
+	ShareRuleIDs           []int64 // Примененные правила; сохраняются вместе с отчетом
+	VehicleID              sql.NullInt64
+	OdometerStart          sql.NullInt64
+	OdometerEnd            sql.NullInt64
 
 	UnsettledOrders []models.Order `json:"-"`
 	CoveredOrderIDs []int64
@@ -31,12 +38,33 @@ type TempDriverSettlementData struct {
 
 	// Поля для временного хранения прочих расходов
 	TempOtherExpenseDescription string
-	EditingOtherExpenseIndex    int // ИНДЕКС для редактирования/удаления "прочего расхода"
+	TempOtherExpenseReceipts    []string // Фото чеков, присланные до ввода суммы расхода
+	EditingOtherExpenseIndex    int      // ИНДЕКС для редактирования/удаления "прочего расхода"
 
 	OriginalPaidToOwnerAt  sql.NullTime
 	DriverUserIDForBackNav int64
 	ViewTypeForBackNav     string
 	PageForBackNav         int
+
+	// Ведомость на выплату, которую редактирует владелец
+	PayrollRunID  int64
+	PayrollLineID int64
+
+	// Машина автопарка, которую редактирует владелец (0 - новая)
+	EditingVehicleID int64
+
+	// Сдача наличных, которую оспаривает водитель, и расхождение, которое закрывает владелец
+	CashHandoverID    int64
+	CashDiscrepancyID int64
+
+	// Запрос на выплату реферальных бонусов, который отклоняет владелец
+	ReferralPayoutRequestID int64
+
+	// Закрытый месяц, который владелец открывает для исправлений
+	PeriodMonth time.Time
+
+	// Правило цены, которое заменяет владелец (0 - новое)
+	EditingPriceRuleID int64
 }
 
 // NewTempDriverSettlement создает новый экземпляр TempDriverSettlementData.
@@ -68,5 +96,6 @@ func (td *TempDriverSettlementData) RecalculateTotals(driverSharePercentage floa
 	netForDriver -= totalLoaderSalary
 
 	td.DriverCalculatedSalary = netForDriver * driverSharePercentage
-	td.AmountToCashier = netForDriver - td.DriverCalculatedSalary
+	// Оплаченное онлайн водитель не получал на руки, поэтому оно не сдается в кассу.
+	td.AmountToCashier = netForDriver - td.DriverCalculatedSalary - td.OnlinePaidRevenue
 }
@@ -16,7 +16,10 @@ type TempOrderData struct {
 	OrderAction               string
 	EphemeralMediaMessageIDs  []int
 	SelectedHourForMinuteView int
-	ActiveMediaGroupID        string // <--- НОВОЕ ПОЛЕ для отслеживания активного альбома
+	ActiveMediaGroupID        string                    // <--- НОВОЕ ПОЛЕ для отслеживания активного альбома
+	EditingDetails            bool                      // Объем и доступ меняются в уже созданном заказе: ответы сразу сохраняются в БД
+	QuoteOptions              []models.OrderQuoteOption // Варианты стоимости, которые оператор готовит к отправке клиенту
+	RepeatedFromOrderID       int64                     // Заказ, который клиент повторяет: после выбора даты и времени сразу показывается подтверждение
 	// Если у вас уже есть мьютекс для других полей TempOrderData, он может также защищать ActiveMediaGroupID.
 	// Если нет, и если TempOrderData напрямую модифицируется из разных горутин (что маловероятно, если SessionManager используется правильно),
 	// то мьютекс может понадобиться. В данном случае SessionManager синхронизирует доступ к TempOrderData.
@@ -0,0 +1,182 @@
+package statements
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"Original/internal/constants"
+	"Original/internal/models"
+)
+
+// FormatXML - выгрузка в формате обмена 1С EnterpriseData.
+const FormatXML = "xml"
+
+// enterpriseDataVersion - версия формата EnterpriseData, которую принимает "Универсальный обмен данными в формате XML".
+const enterpriseDataVersion = "1.8"
+
+var accountingCSVHeaders = []string{"GUID", "Ключ", "Тип", "Номер", "Дата", "Сумма", "Контрагент", "ID контрагента", "Статья", "Заказ", "Комментарий"}
+
+// enterpriseDataDocuments - объект EnterpriseData и вид операции по источнику документа (префиксу ключа).
+var enterpriseDataDocuments = map[string][2]string{
+	"sale":            {"Документ.РеализацияТоваровУслуг", "РеализацияУслуг"},
+	"receipt_online":  {"Документ.ПоступлениеБезналичныхДенежныхСредств", "ПоступлениеОплатыОтКлиента"},
+	"receipt_cash":    {"Документ.ПКО", "ПрочийПриход"},
+	"payout":          {"Документ.РКО", "ВыплатаЗаработнойПлатыРаботнику"},
+	"driver_salary":   {"Документ.РКО", "ВыплатаЗаработнойПлатыРаботнику"},
+	"fuel":            {"Документ.РКО", "ПрочийРасход"},
+	"driver_expense":  {"Документ.РКО", "ПрочийРасход"},
+	"vehicle":         {"Документ.РКО", "ПрочийРасход"},
+	"referral_payout": {"Документ.РКО", "ПрочийРасход"},
+}
+
+// AccountingExportFileName - имя файла выгрузки: 1c_<с>_<по>.<формат>.
+func AccountingExportFileName(export models.AccountingExport, format string) string {
+	return fmt.Sprintf("1c_%s_%s.%s", export.From.Format("20060102"), export.To.Format("20060102"), format)
+}
+
+// AccountingExportTitle - заголовок выгрузки для подписи к документу.
+func AccountingExportTitle(export models.AccountingExport) string {
+	return fmt.Sprintf("Выгрузка в 1С за %s - %s", export.From.Format("02.01.2006"), export.To.Format("02.01.2006"))
+}
+
+// RenderAccountingExport формирует выгрузку в указанном формате (FormatXML или FormatCSV).
+func RenderAccountingExport(export models.AccountingExport, format string) ([]byte, error) {
+	switch format {
+	case FormatXML:
+		return RenderAccountingXML(export)
+	case FormatCSV:
+		return RenderAccountingCSV(export)
+	default:
+		return nil, fmt.Errorf("неизвестный формат выгрузки %q", format)
+	}
+}
+
+func formatAccountingAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// RenderAccountingCSV формирует выгрузку плоской таблицей: один документ - одна строка.
+func RenderAccountingCSV(export models.AccountingExport) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Comma = ';'
+	w.Write(accountingCSVHeaders)
+	for _, doc := range export.Documents {
+		counterpartyID, orderID := "", ""
+		if doc.CounterpartyID != 0 {
+			counterpartyID = strconv.FormatInt(doc.CounterpartyID, 10)
+		}
+		if doc.OrderID != 0 {
+			orderID = strconv.FormatInt(doc.OrderID, 10)
+		}
+		w.Write([]string{
+			doc.GUID, doc.Key, constants.AccountingDocTypeDisplayMap[doc.Type], doc.Number, doc.Date.Format("02.01.2006"),
+			formatAccountingAmount(doc.Amount), doc.Counterparty, counterpartyID, doc.Item, orderID, doc.Comment,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type edMessage struct {
+	XMLName xml.Name `xml:"Message"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Header  edHeader `xml:"Header"`
+	Body    edBody   `xml:"Body"`
+}
+
+type edHeader struct {
+	Format           string `xml:"Format"`
+	CreationDate     string `xml:"CreationDate"`
+	AvailableVersion string `xml:"AvailableVersion"`
+}
+
+type edBody struct {
+	Xmlns     string       `xml:"xmlns,attr"`
+	Documents []edDocument `xml:",any"`
+}
+
+type edDocument struct {
+	XMLName      xml.Name
+	Key          edKeyProperties `xml:"КлючевыеСвойства"`
+	Operation    string          `xml:"ВидОперации"`
+	Amount       string          `xml:"СуммаДокумента"`
+	Currency     string          `xml:"Валюта>Код"`
+	Counterparty *edCounterparty `xml:"Контрагент,omitempty"`
+	Item         string          `xml:"Статья,omitempty"`
+	OrderNumber  string          `xml:"Основание,omitempty"`
+	Comment      string          `xml:"Комментарий,omitempty"`
+}
+
+type edKeyProperties struct {
+	Ref    string `xml:"Ссылка"`
+	Date   string `xml:"Дата"`
+	Number string `xml:"Номер"`
+}
+
+type edCounterparty struct {
+	Code string `xml:"Код,omitempty"`
+	Name string `xml:"Наименование"`
+}
+
+// RenderAccountingXML формирует сообщение обмена EnterpriseData. Ссылка документа - его стабильный GUID,
+// поэтому повторная загрузка того же периода обновляет документы в 1С, а не создает новые.
+func RenderAccountingXML(export models.AccountingExport) ([]byte, error) {
+	namespace := "http://v8.1c.ru/edi/edi_stnd/EnterpriseData/" + enterpriseDataVersion
+	msg := edMessage{
+		Xmlns: "http://www.1c.ru/SSL/Exchange/Message",
+		Header: edHeader{
+			Format:           namespace,
+			CreationDate:     export.ExportedAt.Format("2006-01-02T15:04:05"),
+			AvailableVersion: enterpriseDataVersion,
+		},
+		Body: edBody{Xmlns: namespace},
+	}
+	for _, doc := range export.Documents {
+		source := doc.Key
+		if i := strings.Index(source, ":"); i >= 0 {
+			source = source[:i]
+		}
+		kind, ok := enterpriseDataDocuments[source]
+		if !ok {
+			return nil, fmt.Errorf("нет соответствия документа 1С для %q", doc.Key)
+		}
+		ed := edDocument{
+			XMLName:   xml.Name{Local: kind[0]},
+			Key:       edKeyProperties{Ref: doc.GUID, Date: doc.Date.Format("2006-01-02T15:04:05"), Number: doc.Number},
+			Operation: kind[1],
+			Amount:    formatAccountingAmount(doc.Amount),
+			Currency:  "643",
+			Item:      doc.Item,
+			Comment:   doc.Comment,
+		}
+		if doc.Counterparty != "" {
+			ed.Counterparty = &edCounterparty{Name: doc.Counterparty}
+			if doc.CounterpartyID != 0 {
+				ed.Counterparty.Code = strconv.FormatInt(doc.CounterpartyID, 10)
+			}
+		}
+		if doc.OrderID != 0 {
+			ed.OrderNumber = fmt.Sprintf("Заказ №%d", doc.OrderID)
+		}
+		msg.Body.Documents = append(msg.Body.Documents, ed)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(msg); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,217 @@
+package statements
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/xuri/excelize/v2"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+
+	"Original/internal/models"
+)
+
+const (
+	FormatPDF  = "pdf"
+	FormatXLSX = "xlsx"
+)
+
+var settlementHeaders = []string{"Дата", "Отчет", "Заказы", "Выручка", "Онлайн", "Топливо", "Прочие", "Грузчики", "ЗП", "К сдаче", "Сдано", "ЗП выплачена"}
+
+// FileName - имя файла выписки: statement_<водитель>_<с>_<по>.<формат>.
+func FileName(st models.DriverStatement, format string) string {
+	return fmt.Sprintf("statement_%d_%s_%s.%s", st.DriverUserID, st.From.Format("20060102"), st.To.Format("20060102"), format)
+}
+
+// Title - заголовок выписки для файла и подписи к документу.
+func Title(st models.DriverStatement) string {
+	return fmt.Sprintf("Выписка по водителю %s за %s - %s", st.DriverName, st.From.Format("02.01.2006"), st.To.Format("02.01.2006"))
+}
+
+// Render формирует выписку в указанном формате (FormatPDF или FormatXLSX).
+func Render(st models.DriverStatement, format string) ([]byte, error) {
+	switch format {
+	case FormatPDF:
+		return RenderPDF(st)
+	case FormatXLSX:
+		return RenderXLSX(st)
+	default:
+		return nil, fmt.Errorf("неизвестный формат выписки %q", format)
+	}
+}
+
+// settlementRow - значения строки таблицы отчетов в порядке settlementHeaders.
+func settlementRow(s models.DriverStatementSettlement) []interface{} {
+	paidToOwner, salaryPaid := "-", "-"
+	if s.PaidToOwnerAt.Valid {
+		paidToOwner = s.PaidToOwnerAt.Time.Format("02.01.2006")
+	}
+	if s.DriverSalaryPaidAt.Valid {
+		salaryPaid = s.DriverSalaryPaidAt.Time.Format("02.01.2006")
+	}
+	return []interface{}{
+		s.ReportDate.Format("02.01.2006"), fmt.Sprintf("#%d", s.SettlementID), s.OrdersCount,
+		s.Revenue, s.OnlinePaidRevenue, s.FuelExpense, s.OtherExpenses, s.LoaderPayments,
+		s.DriverSalary, s.AmountToCashier, paidToOwner, salaryPaid,
+	}
+}
+
+// summaryRows - итоги выписки: подпись и сумма.
+func summaryRows(st models.DriverStatement) [][2]interface{} {
+	return [][2]interface{}{
+		{"Входящий остаток", st.OpeningBalance},
+		{"Заказов", st.Totals.OrdersCount},
+		{"Выручка", st.Totals.Revenue},
+		{"в т.ч. оплачено онлайн", st.Totals.OnlinePaidRevenue},
+		{"Топливо", st.Totals.FuelExpense},
+		{"Прочие расходы", st.Totals.OtherExpenses},
+		{"Оплата грузчикам", st.Totals.LoaderPayments},
+		{"ЗП водителя", st.Totals.DriverSalary},
+		{"Сдано в кассу", st.Totals.HandedOver},
+		{"Выплачено ЗП", st.Totals.SalaryPaid},
+		{"Исходящий остаток", st.ClosingBalance},
+	}
+}
+
+// RenderXLSX формирует выписку в Excel: итоги, отчеты и движения с остатком на отдельных листах.
+func RenderXLSX(st models.DriverStatement) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const summarySheet, settlementsSheet, movementsSheet = "Итоги", "Отчеты", "Движения"
+	index, _ := f.NewSheet(summarySheet)
+	f.DeleteSheet("Sheet1")
+	f.SetActiveSheet(index)
+	f.NewSheet(settlementsSheet)
+	f.NewSheet(movementsSheet)
+
+	f.SetCellValue(summarySheet, "A1", Title(st))
+	for i, row := range summaryRows(st) {
+		f.SetCellValue(summarySheet, fmt.Sprintf("A%d", i+3), row[0])
+		f.SetCellValue(summarySheet, fmt.Sprintf("B%d", i+3), row[1])
+	}
+	f.SetColWidth(summarySheet, "A", "A", 28)
+	f.SetColWidth(summarySheet, "B", "B", 16)
+
+	for i, header := range settlementHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(settlementsSheet, cell, header)
+	}
+	for r, s := range st.Settlements {
+		for c, value := range settlementRow(s) {
+			cell, _ := excelize.CoordinatesToCellName(c+1, r+2)
+			f.SetCellValue(settlementsSheet, cell, value)
+		}
+	}
+	f.SetColWidth(settlementsSheet, "A", "L", 13)
+
+	for i, header := range []string{"Дата", "Операция", "Сумма", "Остаток"} {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(movementsSheet, cell, header)
+	}
+	for r, m := range st.Movements {
+		row := r + 2
+		f.SetCellValue(movementsSheet, fmt.Sprintf("A%d", row), m.Date.Format("02.01.2006 15:04"))
+		f.SetCellValue(movementsSheet, fmt.Sprintf("B%d", row), m.Description)
+		f.SetCellValue(movementsSheet, fmt.Sprintf("C%d", row), m.Amount)
+		f.SetCellValue(movementsSheet, fmt.Sprintf("D%d", row), m.Balance)
+	}
+	f.SetColWidth(movementsSheet, "A", "A", 18)
+	f.SetColWidth(movementsSheet, "B", "B", 60)
+	f.SetColWidth(movementsSheet, "C", "D", 14)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderPDF формирует выписку в PDF (A4, альбомная). Шрифт Go встроен в бинарник, поэтому кириллица
+// выводится без внешних файлов.
+func RenderPDF(st models.DriverStatement) ([]byte, error) {
+	pdf := fpdf.New("L", "mm", "A4", "")
+	pdf.AddUTF8FontFromBytes("Go", "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes("Go", "B", gobold.TTF)
+	pdf.SetAutoPageBreak(true, 12)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-10)
+		pdf.SetFont("Go", "", 7)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Сформировано %s · стр. %d/{nb}", st.GeneratedAt.Format("02.01.2006 15:04"), pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+	pdf.AddPage()
+
+	pdf.SetFont("Go", "B", 13)
+	pdf.CellFormat(0, 8, Title(st), "", 1, "L", false, 0, "")
+	pdf.Ln(2)
+
+	pdf.SetFont("Go", "", 9)
+	for _, row := range summaryRows(st) {
+		pdf.CellFormat(50, 5, row[0].(string), "", 0, "L", false, 0, "")
+		pdf.CellFormat(30, 5, formatPDFValue(row[1]), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Go", "B", 10)
+	pdf.CellFormat(0, 6, "Отчеты за период", "", 1, "L", false, 0, "")
+	widths := []float64{22, 16, 16, 25, 22, 22, 22, 22, 25, 25, 25, 27}
+	pdf.SetFont("Go", "B", 8)
+	pdf.SetFillColor(230, 230, 230)
+	for i, header := range settlementHeaders {
+		pdf.CellFormat(widths[i], 6, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Go", "", 8)
+	if len(st.Settlements) == 0 {
+		pdf.CellFormat(0, 6, "Отчетов за период нет", "1", 1, "C", false, 0, "")
+	}
+	for _, s := range st.Settlements {
+		for i, value := range settlementRow(s) {
+			align := "R"
+			if i < 2 || i > 9 {
+				align = "C"
+			}
+			pdf.CellFormat(widths[i], 6, formatPDFValue(value), "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Go", "B", 10)
+	pdf.CellFormat(0, 6, "Движение денег на руках у водителя", "", 1, "L", false, 0, "")
+	movementWidths := []float64{32, 160, 40, 40}
+	pdf.SetFont("Go", "B", 8)
+	for i, header := range []string{"Дата", "Операция", "Сумма", "Остаток"} {
+		pdf.CellFormat(movementWidths[i], 6, header, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Go", "", 8)
+	pdf.CellFormat(movementWidths[0]+movementWidths[1]+movementWidths[2], 6, "Входящий остаток", "1", 0, "L", false, 0, "")
+	pdf.CellFormat(movementWidths[3], 6, formatPDFValue(st.OpeningBalance), "1", 1, "R", false, 0, "")
+	for _, m := range st.Movements {
+		pdf.CellFormat(movementWidths[0], 6, m.Date.Format("02.01.2006 15:04"), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(movementWidths[1], 6, m.Description, "1", 0, "L", false, 0, "")
+		pdf.CellFormat(movementWidths[2], 6, formatPDFValue(m.Amount), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(movementWidths[3], 6, formatPDFValue(m.Balance), "1", 1, "R", false, 0, "")
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatPDFValue выводит суммы с копейками, остальное - как есть.
+func formatPDFValue(value interface{}) string {
+	switch v := value.(type) {
+	case float64:
+		return fmt.Sprintf("%.2f", v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,110 @@
+package statements
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+
+	"github.com/xuri/excelize/v2"
+
+	"Original/internal/models"
+)
+
+// FormatCSV - реестр в CSV с разделителем ";" и BOM, чтобы Excel и клиент-банк открывали кириллицу.
+const FormatCSV = "csv"
+
+var registryHeaders = []string{"№", "ID сотрудника", "ФИО", "Номер карты", "Сумма", "Назначение платежа", "Примечание"}
+
+// RegistryFileName - имя файла реестра: payroll_<ведомость>_<с>_<по>.<формат>.
+func RegistryFileName(run models.PayrollRun, format string) string {
+	return fmt.Sprintf("payroll_%d_%s_%s.%s", run.ID, run.PeriodFrom.Format("20060102"), run.PeriodTo.Format("20060102"), format)
+}
+
+// RegistryTitle - заголовок реестра для файла и подписи к документу.
+func RegistryTitle(run models.PayrollRun) string {
+	return fmt.Sprintf("Реестр на перечисление по ведомости #%d за %s - %s", run.ID, run.PeriodFrom.Format("02.01.2006"), run.PeriodTo.Format("02.01.2006"))
+}
+
+// RenderRegistry формирует реестр в указанном формате (FormatCSV или FormatXLSX).
+func RenderRegistry(run models.PayrollRun, lines []models.PayrollRegistryLine, format string) ([]byte, error) {
+	switch format {
+	case FormatCSV:
+		return RenderRegistryCSV(lines)
+	case FormatXLSX:
+		return RenderRegistryXLSX(run, lines)
+	default:
+		return nil, fmt.Errorf("неизвестный формат реестра %q", format)
+	}
+}
+
+// registryRow - значения строки реестра в порядке registryHeaders; суммы с двумя знаками.
+func registryRow(i int, line models.PayrollRegistryLine) []string {
+	return []string{
+		strconv.Itoa(i + 1), strconv.FormatInt(line.UserID, 10), line.UserName, line.CardNumber,
+		strconv.FormatFloat(line.Amount, 'f', 2, 64), line.Purpose, line.CardError,
+	}
+}
+
+// RenderRegistryCSV формирует реестр в CSV.
+func RenderRegistryCSV(lines []models.PayrollRegistryLine) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\ufeff")
+	w := csv.NewWriter(&buf)
+	w.Comma = ';'
+	w.Write(registryHeaders)
+	total := 0.0
+	for i, line := range lines {
+		w.Write(registryRow(i, line))
+		total += line.Amount
+	}
+	w.Write([]string{"", "", "Итого", "", strconv.FormatFloat(total, 'f', 2, 64), "", ""})
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderRegistryXLSX формирует реестр в Excel на одном листе с итоговой строкой.
+func RenderRegistryXLSX(run models.PayrollRun, lines []models.PayrollRegistryLine) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const sheet = "Реестр"
+	index, _ := f.NewSheet(sheet)
+	f.DeleteSheet("Sheet1")
+	f.SetActiveSheet(index)
+
+	f.SetCellValue(sheet, "A1", RegistryTitle(run))
+	for i, header := range registryHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 3)
+		f.SetCellValue(sheet, cell, header)
+	}
+	total := 0.0
+	for r, line := range lines {
+		row := r + 4
+		f.SetCellValue(sheet, fmt.Sprintf("A%d", row), r+1)
+		f.SetCellValue(sheet, fmt.Sprintf("B%d", row), line.UserID)
+		f.SetCellValue(sheet, fmt.Sprintf("C%d", row), line.UserName)
+		// Номер карты пишется строкой, иначе Excel превратит его в число с потерей цифр
+		f.SetCellStr(sheet, fmt.Sprintf("D%d", row), line.CardNumber)
+		f.SetCellValue(sheet, fmt.Sprintf("E%d", row), line.Amount)
+		f.SetCellValue(sheet, fmt.Sprintf("F%d", row), line.Purpose)
+		f.SetCellValue(sheet, fmt.Sprintf("G%d", row), line.CardError)
+		total += line.Amount
+	}
+	totalRow := len(lines) + 4
+	f.SetCellValue(sheet, fmt.Sprintf("C%d", totalRow), "Итого")
+	f.SetCellValue(sheet, fmt.Sprintf("E%d", totalRow), total)
+	f.SetColWidth(sheet, "A", "B", 8)
+	f.SetColWidth(sheet, "C", "D", 28)
+	f.SetColWidth(sheet, "E", "E", 14)
+	f.SetColWidth(sheet, "F", "G", 40)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package statements
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+
+	"Original/internal/constants"
+	"Original/internal/models"
+)
+
+var profitLossHeaders = []string{"Месяц", "Категория", "Подкатегория", "Заказов", "Выручка", "Топливо", "Прочие расходы",
+	"ЗП грузчиков", "Доля водителя", "Реферальные бонусы", "Утилизация", "Итого расходов", "Прибыль"}
+
+var profitLossCategoryHeaders = []string{"Категория", "Подкатегория", "Заказов", "Выручка", "Расходы", "Прибыль",
+	"Заказов (пред.)", "Выручка (пред.)", "Расходы (пред.)", "Прибыль (пред.)", "Выручка, %", "Прибыль, %"}
+
+// ProfitLossFileName - имя файла P&L: pnl_<с>_<по>.xlsx.
+func ProfitLossFileName(report models.ProfitLossReport) string {
+	return fmt.Sprintf("pnl_%s_%s.%s", report.From.Format("20060102"), report.To.Format("20060102"), FormatXLSX)
+}
+
+// ProfitLossTitle - заголовок отчета о прибылях и убытках.
+func ProfitLossTitle(report models.ProfitLossReport) string {
+	return fmt.Sprintf("Прибыли и убытки за %s - %s", report.From.Format("02.01.2006"), report.To.Format("02.01.2006"))
+}
+
+// ProfitLossCategoryName - название категории заказов для отчета.
+func ProfitLossCategoryName(category string) string {
+	if name, ok := constants.CategoryDisplayMap[category]; ok {
+		return name
+	}
+	if category == "" {
+		return "Без категории"
+	}
+	return category
+}
+
+// ProfitLossSubcategoryName - название подкатегории заказов для отчета.
+func ProfitLossSubcategoryName(category, subcategory string) string {
+	var names map[string]string
+	switch category {
+	case constants.CAT_WASTE:
+		names = constants.WasteSubcategoryMap
+	case constants.CAT_DEMOLITION:
+		names = constants.DemolitionSubcategoryMap
+	}
+	if name, ok := names[subcategory]; ok {
+		return name
+	}
+	return subcategory
+}
+
+// profitLossAmountsRow - суммы строки P&L в порядке profitLossHeaders начиная с "Заказов".
+func profitLossAmountsRow(a models.ProfitLossAmounts) []interface{} {
+	return []interface{}{a.OrdersCount, a.Revenue, a.Fuel, a.OtherExpenses, a.LoaderSalaries, a.DriverShare,
+		a.ReferralBonuses, a.Disposal, a.Expenses, a.Profit}
+}
+
+// percentCell - значение процента изменения для ячейки; без базы сравнения ячейка пустая.
+func percentCell(change *float64) interface{} {
+	if change == nil {
+		return ""
+	}
+	return fmt.Sprintf("%+.1f", *change)
+}
+
+// RenderProfitLossXLSX формирует P&L в Excel: помесячные строки и сравнение категорий с предыдущим периодом.
+func RenderProfitLossXLSX(report models.ProfitLossReport) ([]byte, error) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	const monthsSheet, categoriesSheet = "По месяцам", "Сравнение"
+	index, _ := f.NewSheet(monthsSheet)
+	f.DeleteSheet("Sheet1")
+	f.SetActiveSheet(index)
+	f.NewSheet(categoriesSheet)
+
+	f.SetCellValue(monthsSheet, "A1", ProfitLossTitle(report))
+	for i, header := range profitLossHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 3)
+		f.SetCellValue(monthsSheet, cell, header)
+	}
+	for r, line := range report.Lines {
+		values := append([]interface{}{line.Month.Format("01.2006"),
+			ProfitLossCategoryName(line.Category), ProfitLossSubcategoryName(line.Category, line.Subcategory)},
+			profitLossAmountsRow(line.ProfitLossAmounts)...)
+		f.SetSheetRow(monthsSheet, fmt.Sprintf("A%d", r+4), &values)
+	}
+	totalValues := append([]interface{}{"Итого", "", ""}, profitLossAmountsRow(report.Total)...)
+	f.SetSheetRow(monthsSheet, fmt.Sprintf("A%d", len(report.Lines)+4), &totalValues)
+	f.SetColWidth(monthsSheet, "A", "A", 12)
+	f.SetColWidth(monthsSheet, "B", "C", 24)
+	f.SetColWidth(monthsSheet, "D", "M", 14)
+
+	f.SetCellValue(categoriesSheet, "A1", fmt.Sprintf("%s в сравнении с %s - %s", ProfitLossTitle(report),
+		report.PreviousFrom.Format("02.01.2006"), report.PreviousTo.Format("02.01.2006")))
+	for i, header := range profitLossCategoryHeaders {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 3)
+		f.SetCellValue(categoriesSheet, cell, header)
+	}
+	for r, c := range report.Categories {
+		values := []interface{}{ProfitLossCategoryName(c.Category), ProfitLossSubcategoryName(c.Category, c.Subcategory),
+			c.Current.OrdersCount, c.Current.Revenue, c.Current.Expenses, c.Current.Profit,
+			c.Previous.OrdersCount, c.Previous.Revenue, c.Previous.Expenses, c.Previous.Profit,
+			percentCell(c.RevenueChangePct), percentCell(c.ProfitChangePct)}
+		f.SetSheetRow(categoriesSheet, fmt.Sprintf("A%d", r+4), &values)
+	}
+	totalComparison := []interface{}{"Итого", "",
+		report.Total.OrdersCount, report.Total.Revenue, report.Total.Expenses, report.Total.Profit,
+		report.PreviousTotal.OrdersCount, report.PreviousTotal.Revenue, report.PreviousTotal.Expenses, report.PreviousTotal.Profit,
+		percentCell(report.RevenueChangePct), percentCell(report.ProfitChangePct)}
+	f.SetSheetRow(categoriesSheet, fmt.Sprintf("A%d", len(report.Categories)+4), &totalComparison)
+	f.SetColWidth(categoriesSheet, "A", "B", 24)
+	f.SetColWidth(categoriesSheet, "C", "L", 14)
+
+	buf, err := f.WriteToBuffer()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -2,7 +2,9 @@
 package utils
 
 import (
+	"os"
 	"path"
+	"path/filepath"
 	"strings" // Добавляем этот импорт
 
 	tgbotapi "github.com/OvyFlash/telegram-bot-api"
@@ -43,3 +45,25 @@ func ExtractFilenamesFromUrls(urls []string) []string {
 	}
 	return filenames
 }
+
+// MediaStoragePath возвращает путь к папке media_storage рядом с исполняемым файлом.
+// Туда сохраняются файлы, загруженные через WebApp.
+func MediaStoragePath() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(executable), "media_storage"), nil
+}
+
+// TelegramFileFromRef возвращает файл для отправки в Telegram: загруженный через WebApp
+// файл из media_storage отправляется с диска, остальное считается file_id Telegram.
+func TelegramFileFromRef(ref string) tgbotapi.RequestFileData {
+	if storagePath, err := MediaStoragePath(); err == nil && !strings.ContainsAny(ref, "/\\") {
+		localPath := filepath.Join(storagePath, ref)
+		if info, statErr := os.Stat(localPath); statErr == nil && !info.IsDir() {
+			return tgbotapi.FilePath(localPath)
+		}
+	}
+	return tgbotapi.FileID(ref)
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"Original/internal/constants"
+	"Original/internal/models"
+)
+
+// orderDetailStepApplies - задается ли вопрос step для заказа с уже введенными ответами.
+func orderDetailStepApplies(order models.Order, step string) bool {
+	if step == constants.ORDER_DETAIL_ELEVATOR {
+		return order.Floor.Valid && order.Floor.Int64 > 1
+	}
+	return true
+}
+
+// NextOrderDetailStep возвращает вопрос об объеме и доступе, следующий за after
+// (пустой after - первый вопрос). Пустая строка - вопросов больше нет.
+func NextOrderDetailStep(order models.Order, after string) string {
+	steps := constants.OrderDetailStepsMap[order.Subcategory]
+	start := 0
+	if after != "" {
+		start = len(steps)
+		for i, step := range steps {
+			if step == after {
+				start = i + 1
+				break
+			}
+		}
+	}
+	for _, step := range steps[start:] {
+		if orderDetailStepApplies(order, step) {
+			return step
+		}
+	}
+	return ""
+}
+
+// PrevOrderDetailStep возвращает вопрос, предшествующий before (пустой before - последний вопрос).
+// Пустая строка - before был первым вопросом или вопросов для подкатегории нет.
+func PrevOrderDetailStep(order models.Order, before string) string {
+	steps := constants.OrderDetailStepsMap[order.Subcategory]
+	end := len(steps)
+	if before != "" {
+		end = 0
+		for i, step := range steps {
+			if step == before {
+				end = i
+				break
+			}
+		}
+	}
+	for i := end - 1; i >= 0; i-- {
+		if orderDetailStepApplies(order, steps[i]) {
+			return steps[i]
+		}
+	}
+	return ""
+}
+
+var detailNumberRegex = regexp.MustCompile(`^\d+(\.\d+)?`)
+
+// parseDetailNumber разбирает число в начале ответа клиента ("4", "2,5 м3", "0.5 т").
+func parseDetailNumber(text string) (float64, error) {
+	number := detailNumberRegex.FindString(strings.TrimSpace(strings.ReplaceAll(text, ",", ".")))
+	if number == "" {
+		return 0, fmt.Errorf("не найдено число в '%s'", text)
+	}
+	return strconv.ParseFloat(number, 64)
+}
+
+// ParseOrderVolume разбирает объем мусора в м³.
+func ParseOrderVolume(text string) (float64, error) {
+	volume, err := parseDetailNumber(text)
+	if err != nil || volume <= 0 || volume > constants.MaxOrderVolumeM3 {
+		return 0, fmt.Errorf("объем должен быть числом от 0 до %.0f м³", constants.MaxOrderVolumeM3)
+	}
+	return volume, nil
+}
+
+// ParseOrderTonnage разбирает вес мусора в тоннах.
+func ParseOrderTonnage(text string) (float64, error) {
+	tonnage, err := parseDetailNumber(text)
+	if err != nil || tonnage <= 0 || tonnage > constants.MaxOrderTonnageT {
+		return 0, fmt.Errorf("вес должен быть числом от 0 до %.0f т", constants.MaxOrderTonnageT)
+	}
+	return tonnage, nil
+}
+
+// ParseOrderFloor разбирает этаж. Частный дом и первый этаж - 1.
+func ParseOrderFloor(text string) (int64, error) {
+	floor, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+	if err != nil || floor < 1 || floor > constants.MaxOrderFloor {
+		return 0, fmt.Errorf("этаж должен быть целым числом от 1 до %d", constants.MaxOrderFloor)
+	}
+	return floor, nil
+}
+
+// NormalizeOrderDetails проверяет объем и доступ, пришедшие целиком (из WebApp),
+// и сбрасывает ответы на вопросы, которые для подкатегории не задаются.
+func NormalizeOrderDetails(order *models.Order) error {
+	asked := make(map[string]bool)
+	for _, step := range constants.OrderDetailStepsMap[order.Subcategory] {
+		asked[step] = true
+	}
+	if !asked[constants.ORDER_DETAIL_VOLUME] {
+		order.VolumeM3.Valid = false
+	}
+	if !asked[constants.ORDER_DETAIL_TONNAGE] {
+		order.TonnageT.Valid = false
+	}
+	if !asked[constants.ORDER_DETAIL_FLOOR] {
+		order.Floor.Valid = false
+	}
+	if !asked[constants.ORDER_DETAIL_LOADERS] {
+		order.NeedsLoaders.Valid = false
+	}
+	if !orderDetailStepApplies(*order, constants.ORDER_DETAIL_ELEVATOR) || !asked[constants.ORDER_DETAIL_ELEVATOR] {
+		order.HasElevator.Valid = false
+	}
+
+	if order.VolumeM3.Valid && (order.VolumeM3.Float64 <= 0 || order.VolumeM3.Float64 > constants.MaxOrderVolumeM3) {
+		return fmt.Errorf("объем должен быть от 0 до %.0f м³", constants.MaxOrderVolumeM3)
+	}
+	if order.TonnageT.Valid && (order.TonnageT.Float64 <= 0 || order.TonnageT.Float64 > constants.MaxOrderTonnageT) {
+		return fmt.Errorf("вес должен быть от 0 до %.0f т", constants.MaxOrderTonnageT)
+	}
+	if order.Floor.Valid && (order.Floor.Int64 < 1 || order.Floor.Int64 > constants.MaxOrderFloor) {
+		return fmt.Errorf("этаж должен быть от 1 до %d", constants.MaxOrderFloor)
+	}
+	return nil
+}
+
+// FormatDetailNumber выводит объем или вес без лишних нулей: 4, 2.5, 0.1.
+func FormatDetailNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// FormatOrderDetailLines возвращает строки "объем и доступ" для карточки заказа (без Markdown).
+// Неотвеченные вопросы пропускаются.
+func FormatOrderDetailLines(order models.Order) []string {
+	var lines []string
+	if order.VolumeM3.Valid {
+		lines = append(lines, fmt.Sprintf("Объем: %s м³", FormatDetailNumber(order.VolumeM3.Float64)))
+	}
+	if order.TonnageT.Valid {
+		lines = append(lines, fmt.Sprintf("Вес: %s т", FormatDetailNumber(order.TonnageT.Float64)))
+	}
+	if order.Floor.Valid {
+		floorStr := fmt.Sprintf("Этаж: %d", order.Floor.Int64)
+		if order.Floor.Int64 <= 1 {
+			floorStr = "Этаж: 1 / частный дом"
+		} else if order.HasElevator.Valid && order.HasElevator.Bool {
+			floorStr += ", есть лифт"
+		} else if order.HasElevator.Valid {
+			floorStr += ", без лифта"
+		}
+		lines = append(lines, floorStr)
+	}
+	if order.NeedsLoaders.Valid {
+		if order.NeedsLoaders.Bool {
+			lines = append(lines, "Грузчики: нужны")
+		} else {
+			lines = append(lines, "Грузчики: не нужны")
+		}
+	}
+	return lines
+}
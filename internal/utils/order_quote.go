@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"Original/internal/constants"
+	"Original/internal/models"
+)
+
+// quoteOptionRegex - строка варианта: "<название> - <сумма> [- <водителей>/<грузчиков>]".
+var quoteOptionRegex = regexp.MustCompile(`^(.+?)\s*[-—–:=]\s*(\d+(?:[.,]\d+)?)\s*₽?\s*(?:[-—–;,]\s*(\d+)\s*/\s*(\d+))?$`)
+
+// ParseQuoteOptions разбирает варианты стоимости, введенные оператором, по одному в строке:
+//
+//	Газель, 2 грузчика - 6000 - 1/2
+//	КамАЗ без грузчиков - 9000 - 1/0
+//
+// Состав "водителей/грузчиков" можно не указывать: по умолчанию один водитель без грузчиков.
+func ParseQuoteOptions(text string) ([]models.OrderQuoteOption, error) {
+	var options []models.OrderQuoteOption
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		match := quoteOptionRegex.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("строка '%s' должна иметь вид '<название> - <сумма> - <водителей>/<грузчиков>'", line)
+		}
+		cost, err := strconv.ParseFloat(strings.ReplaceAll(match[2], ",", "."), 64)
+		if err != nil || cost <= 0 {
+			return nil, fmt.Errorf("в строке '%s' сумма должна быть больше нуля", line)
+		}
+		option := models.OrderQuoteOption{Title: strings.TrimSpace(match[1]), Cost: cost, Drivers: 1}
+		if match[3] != "" {
+			option.Drivers, _ = strconv.Atoi(match[3])
+			option.Loaders, _ = strconv.Atoi(match[4])
+			if option.Drivers+option.Loaders == 0 {
+				return nil, fmt.Errorf("в строке '%s' нужен хотя бы один исполнитель", line)
+			}
+		}
+		options = append(options, option)
+	}
+	if len(options) < constants.MinQuoteOptions || len(options) > constants.MaxQuoteOptions {
+		return nil, fmt.Errorf("нужно от %d до %d вариантов, каждый с новой строки", constants.MinQuoteOptions, constants.MaxQuoteOptions)
+	}
+	return options, nil
+}
+
+// FormatQuoteComposition - состав исполнителей варианта для сообщений: "водители: 1, грузчики: 2".
+func FormatQuoteComposition(drivers, loaders int64) string {
+	return fmt.Sprintf("водители: %d, грузчики: %d", drivers, loaders)
+}
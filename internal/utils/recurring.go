@@ -0,0 +1,31 @@
+package utils
+
+import "time"
+
+// DateOnly отбрасывает время и часовой пояс: даты графика сравниваются по календарному дню.
+func DateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// RecurringDates возвращает даты графика start + k*intervalDays, попадающие в [from, to].
+// Нулевой end означает бессрочный график, иначе даты после end не возвращаются.
+func RecurringDates(start time.Time, intervalDays int, end time.Time, from, to time.Time) []time.Time {
+	if intervalDays <= 0 {
+		return nil
+	}
+	start, from, to = DateOnly(start), DateOnly(from), DateOnly(to)
+	if !end.IsZero() && DateOnly(end).Before(to) {
+		to = DateOnly(end)
+	}
+	first := start
+	if from.After(start) {
+		daysFromStart := int(from.Sub(start).Hours() / 24)
+		steps := (daysFromStart + intervalDays - 1) / intervalDays
+		first = start.AddDate(0, 0, steps*intervalDays)
+	}
+	var dates []time.Time
+	for d := first; !d.After(to); d = d.AddDate(0, 0, intervalDays) {
+		dates = append(dates, d)
+	}
+	return dates
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/skip2/go-qrcode" // Убедитесь, что этот импорт корректен
 )
@@ -21,6 +23,19 @@ func GenerateReferralLink(botUsername string, chatID int64) (string, error) {
 	return fmt.Sprintf("https://t.me/%s?start=ref_%d", botUsername, chatID), nil
 }
 
+// ParseReferralStartPayload извлекает chat_id пригласившего из параметра /start вида "ref_<chatID>".
+func ParseReferralStartPayload(payload string) (int64, bool) {
+	rest, found := strings.CutPrefix(strings.TrimSpace(payload), "ref_")
+	if !found {
+		return 0, false
+	}
+	inviterChatID, err := strconv.ParseInt(rest, 10, 64)
+	if err != nil || inviterChatID == 0 {
+		return 0, false
+	}
+	return inviterChatID, true
+}
+
 // GenerateQRCode генерирует QR-код для реферальной ссылки.
 // botUsername также нужен здесь, так как он используется в GenerateReferralLink.
 func GenerateQRCode(botUsername string, chatID int64) ([]byte, error) {
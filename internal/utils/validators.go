@@ -7,8 +7,11 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"Original/internal/constants" // Используем Original как имя модуля
+	"Original/internal/models"
 )
 
 // localPhoneRegex (не экспортируется) используется внутри ValidatePhoneNumber.
@@ -214,6 +217,89 @@ func ValidateCardNumber(cardNumber string) error {
 	return nil
 }
 
+// ValidateEmail проверяет формат email для отправки чеков и возвращает его в нижнем регистре.
+// ValidateEmail checks the format of a receipt email and returns it lowercased.
+func ValidateEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if len(email) > 254 || !regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}$`).MatchString(email) {
+		return "", fmt.Errorf("неверный формат email")
+	}
+	return email, nil
+}
+
+// ParseCostBreakdown разбирает стоимость, введенную оператором.
+// Допускается одно число ("15000") или расшифровка по строкам вида "<название> <сумма>":
+//
+//	Вывоз 9000
+//	Погрузка 4000
+//	Утилизация 2000
+//
+// Статья определяется по ключевым словам constants.CostItemKeywords; нераспознанные строки попадают в прочие услуги.
+// Для одного числа возвращается nil вместо списка статей.
+// ParseCostBreakdown parses an operator's cost input: a single number or "<name> <amount>" lines.
+func ParseCostBreakdown(text string) (float64, []models.OrderCostItem, error) {
+	text = strings.TrimSpace(text)
+	if total, err := strconv.ParseFloat(strings.ReplaceAll(strings.ReplaceAll(text, ",", "."), " ", ""), 64); err == nil {
+		if total < 0 {
+			return 0, nil, fmt.Errorf("стоимость не может быть отрицательной")
+		}
+		return total, nil, nil
+	}
+
+	var total float64
+	var items []models.OrderCostItem
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// Сумма - хвост строки из цифр, пробелов (разделителей разрядов) и ',' '.': "Вывоз 9 500" -> "Вывоз", 9500
+		body := strings.TrimSpace(strings.TrimSuffix(line, "₽"))
+		amountStart := len(body)
+		for amountStart > 0 {
+			r, size := utf8.DecodeLastRuneInString(body[:amountStart])
+			if !unicode.IsDigit(r) && r != ' ' && r != '\u00a0' && r != '\t' && r != ',' && r != '.' {
+				break
+			}
+			amountStart -= size
+		}
+		amountStr := strings.TrimLeft(body[amountStart:], " \u00a0\t,.")
+		if amountStr == "" || amountStart == 0 {
+			return 0, nil, fmt.Errorf("строка '%s' должна иметь вид '<название> <сумма>'", line)
+		}
+		description := strings.TrimRight(strings.TrimSpace(body[:amountStart]), " :-=\t")
+		amountStr = strings.NewReplacer(" ", "", "\u00a0", "", "\t", "", ",", ".").Replace(amountStr)
+		amount, err := strconv.ParseFloat(amountStr, 64)
+		if err != nil || amount <= 0 || description == "" {
+			return 0, nil, fmt.Errorf("строка '%s' должна иметь вид '<название> <сумма>' с суммой больше нуля", line)
+		}
+		items = append(items, models.OrderCostItem{
+			Kind:        DetectCostItemKind(description),
+			Description: description,
+			Amount:      amount,
+		})
+		total += amount
+	}
+	if len(items) == 0 {
+		return 0, nil, fmt.Errorf("стоимость не указана")
+	}
+	return total, items, nil
+}
+
+// DetectCostItemKind определяет статью расшифровки по названию строки.
+// DetectCostItemKind maps a breakdown line name to a cost item kind.
+func DetectCostItemKind(description string) string {
+	lower := strings.ToLower(description)
+	for _, kind := range []string{constants.COST_ITEM_DEMOLITION, constants.COST_ITEM_LOADING, constants.COST_ITEM_DISPOSAL_FEE, constants.COST_ITEM_MATERIALS, constants.COST_ITEM_REMOVAL} {
+		for _, keyword := range constants.CostItemKeywords[kind] {
+			if strings.Contains(lower, keyword) {
+				return kind
+			}
+		}
+	}
+	return constants.COST_ITEM_OTHER
+}
+
 // IsCommandInCategory проверяет, принадлежит ли команда одной из категорий.
 // IsCommandInCategory checks if a command belongs to one of the categories.
 func IsCommandInCategory(command string, categoryCommands []string) bool {
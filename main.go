@@ -68,6 +68,21 @@ func main() {
 
 	botHandler := handlers.NewBotHandler(handlerDeps)
 
+	// Фоновая сверка незавершенных платежей с провайдером
+	go botHandler.RunPaymentReconciliation(cfg.PaymentReconcileEvery)
+	// Предупреждения владельцу о сроках ОСАГО, техосмотра и ТО машин
+	go botHandler.RunVehicleComplianceChecks(cfg.VehicleCheckEvery)
+	// Напоминания водителям о неподтвержденных сдачах наличных
+	go botHandler.RunCashHandoverAckReminders(cfg.CashAckReminderEvery)
+	// Списание реферальных бонусов с истекшим сроком
+	go botHandler.RunReferralBonusExpiry(cfg.ReferralExpiryEvery)
+	// Возврат заказов с неотвеченными предложениями стоимости в новые
+	go botHandler.RunOrderQuoteExpiry(cfg.QuoteExpiryEvery)
+	// Напоминания и автоотмена заказов без подтверждения стоимости или оплаты
+	go botHandler.RunStaleOrderExpiry(cfg.StaleOrderCheckEvery)
+	// Создание заказов по графикам регулярных заказов
+	go botHandler.RunRecurringOrders(cfg.RecurringOrdersEvery)
+
 	// --- Настройка роутера и Middleware ---
 	apiRouter := chi.NewRouter()
 
@@ -132,6 +147,9 @@ func main() {
 		} else if update.CallbackQuery != nil {
 			log.Printf("Callback от %s: %s", update.CallbackQuery.From.UserName, update.CallbackQuery.Data)
 			go botHandler.HandleCallback(update)
+		} else if update.PreCheckoutQuery != nil {
+			log.Printf("PreCheckoutQuery от %s: %s", update.PreCheckoutQuery.From.UserName, update.PreCheckoutQuery.InvoicePayload)
+			go botHandler.HandlePreCheckoutQuery(update)
 		}
 	}
 }
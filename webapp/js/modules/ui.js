@@ -1161,6 +1161,30 @@ class UIModule {
                             <textarea id="order-description" name="description" rows="4" placeholder="Подробное описание работы"></textarea>
                         </div>
                         
+                        <div class="form-row">
+                            <div class="form-group">
+                                <label for="order-volume">Объем, м³</label>
+                                <input type="number" id="order-volume" name="volume_m3" min="0.1" max="100" step="0.1" placeholder="Например: 4">
+                            </div>
+                            <div class="form-group">
+                                <label for="order-tonnage">Вес, т</label>
+                                <input type="number" id="order-tonnage" name="tonnage_t" min="0.1" max="50" step="0.1" placeholder="Для металла">
+                            </div>
+                            <div class="form-group">
+                                <label for="order-floor">Этаж</label>
+                                <input type="number" id="order-floor" name="floor" min="1" max="100" step="1" placeholder="1 - частный дом">
+                            </div>
+                        </div>
+                        
+                        <div class="form-row">
+                            <div class="form-group">
+                                <label><input type="checkbox" id="order-has-elevator" name="has_elevator"> Есть лифт</label>
+                            </div>
+                            <div class="form-group">
+                                <label><input type="checkbox" id="order-needs-loaders" name="needs_loaders"> Нужны грузчики</label>
+                            </div>
+                        </div>
+                        
                         <div class="form-row">
                             <div class="form-group">
                                 <label for="order-date">Дата*</label>
@@ -1317,6 +1341,22 @@ class UIModule {
                 Videos: []
             };
 
+            // Volume and access details (the server drops the ones not asked for the subcategory)
+            const volume = formData.get('volume_m3');
+            if (volume && !isNaN(volume)) {
+                orderData.volume_m3 = parseFloat(volume);
+            }
+            const tonnage = formData.get('tonnage_t');
+            if (tonnage && !isNaN(tonnage)) {
+                orderData.tonnage_t = parseFloat(tonnage);
+            }
+            const floor = formData.get('floor');
+            if (floor && !isNaN(floor)) {
+                orderData.floor = parseInt(floor, 10);
+                orderData.has_elevator = formData.get('has_elevator') === 'on';
+            }
+            orderData.needs_loaders = formData.get('needs_loaders') === 'on';
+
             // For owners, include status and cost
             if (user && user.Role === 'owner') {
                 orderData.Status = formData.get('status') || 'new';